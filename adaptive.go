@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+)
+
+// ==============================================================================
+// [セクション11] 適応型負荷モード: エラー率とレイテンシに基づく並行数の自動調整
+// ==============================================================================
+
+// 適応型負荷モードでは、Concurrency を初期値としてテストを開始し、一定間隔ごとに直近の区間を評価します。
+// エラー率とp99レイテンシが許容範囲内であればワーカーを step だけ増やし、どちらかが悪化した場合は
+// step の2倍だけ減らします。増加より減少を大きくすることで、過負荷の手前で振動しながら
+// 「ターゲットが持続的に捌ける並行数」の付近に留まり続けます。
+
+// 適応型負荷モードの既定値
+const (
+	defaultAdaptiveTargetErrorRate = 0.01
+	defaultAdaptiveIntervalSec     = 2
+)
+
+// 調整の結果（AdaptivePoint.Action）
+const (
+	adaptiveIncrease = "increase"
+	adaptiveDecrease = "decrease"
+	adaptiveHold     = "hold"
+)
+
+// AdaptivePoint は、適応型負荷モードにおける1回分の調整結果です。
+type AdaptivePoint struct {
+	ElapsedSec  float64 `json:"elapsed_sec"` // テスト開始からの経過秒数
+	Concurrency int     `json:"concurrency"` // 調整後のワーカー数
+	Requests    uint64  `json:"requests"`    // 評価した区間内に完了したリクエスト数
+	ErrorRate   float64 `json:"error_rate"`  // 評価した区間内のエラー率
+	P99Latency  string  `json:"p99_latency"` // 評価した区間内のp99レイテンシ
+	Action      string  `json:"action"`      // "increase" / "decrease" / "hold"
+}
+
+// adaptiveParams は、コントローラーの判定に使用するパラメーターです。
+type adaptiveParams struct {
+	minConcurrency  int
+	maxConcurrency  int
+	step            int
+	targetErrorRate float64
+	maxP99          time.Duration // 0 の場合はレイテンシを判定に使いません
+}
+
+// adaptiveWindow は、1回の評価区間で観測した値です。
+type adaptiveWindow struct {
+	requests uint64
+	errors   uint64
+	p99      time.Duration
+}
+
+// newAdaptiveParams は、設定値から未指定の項目を既定値で補ったパラメーターを生成します。
+func newAdaptiveParams(cfg *TestConfig) adaptiveParams {
+	p := adaptiveParams{
+		minConcurrency:  1,
+		maxConcurrency:  cfg.AdaptiveMaxConcurrency,
+		step:            cfg.AdaptiveStep,
+		targetErrorRate: cfg.AdaptiveTargetErrorRate,
+		maxP99:          time.Duration(cfg.AdaptiveMaxP99Ms) * time.Millisecond,
+	}
+	if p.maxConcurrency <= 0 {
+		// 既定値も、サーバーの並行数の上限（-max-concurrency）で頭打ちにします（limits.go を参照）
+		p.maxConcurrency = min(cfg.Concurrency*10, maxConcurrency)
+	}
+	if p.maxConcurrency < cfg.Concurrency {
+		p.maxConcurrency = cfg.Concurrency
+	}
+	if p.step <= 0 {
+		p.step = cfg.Concurrency / 10
+	}
+	if p.step < 1 {
+		p.step = 1
+	}
+	if p.targetErrorRate <= 0 {
+		p.targetErrorRate = defaultAdaptiveTargetErrorRate
+	}
+	return p
+}
+
+// nextConcurrency は、現在のワーカー数と直近の区間の観測値から、次のワーカー数と調整の種類を決定します。
+// 副作用を持たない純粋な関数とし、コントローラーの判定ロジックだけを切り出しています。
+func nextConcurrency(current int, w adaptiveWindow, p adaptiveParams) (int, string) {
+	// 区間内に1件も完了しなかった場合は、ターゲットが応答不能に陥っているとみなして後退します
+	degraded := w.requests == 0
+	if w.requests > 0 {
+		errorRate := float64(w.errors) / float64(w.requests)
+		if errorRate > p.targetErrorRate {
+			degraded = true
+		}
+		if p.maxP99 > 0 && w.p99 > p.maxP99 {
+			degraded = true
+		}
+	}
+
+	next, action := current+p.step, adaptiveIncrease
+	if degraded {
+		next, action = current-p.step*2, adaptiveDecrease
+	}
+
+	if next > p.maxConcurrency {
+		next = p.maxConcurrency
+	}
+	if next < p.minConcurrency {
+		next = p.minConcurrency
+	}
+	if next == current {
+		action = adaptiveHold
+	}
+	return next, action
+}
+
+// percentileOf は、レイテンシ群の指定パーセンタイル値を返します（samples はソートされます）。
+// インデックスの算出方法は summarizeLatencies と揃えています。
+func percentileOf(samples []time.Duration, q float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	idx := int(float64(len(samples)) * q)
+	if idx >= len(samples) {
+		idx = len(samples) - 1
+	}
+	return samples[idx]
+}
+
+// runAdaptiveController は、テスト終了まで一定間隔で区間の観測値を評価し、ワーカー数を調整します。
+// 調整結果はメトリクスの trajectory に追記され、レポートの concurrency_trajectory として出力されます。
+// sampler はワーカー起動前に生成しておき、起動直後の結果も区間に含めます。
+// ctx がキャンセルされると done をクローズして終了します。
+func runAdaptiveController(ctx context.Context, cfg *TestConfig, pool *workerPool, sampler *intervalSampler, metrics *ResultMetrics, done chan<- struct{}) {
+	defer close(done)
+
+	params := newAdaptiveParams(cfg)
+	interval := time.Duration(cfg.AdaptiveIntervalSec) * time.Second
+	if interval <= 0 {
+		interval = defaultAdaptiveIntervalSec * time.Second
+	}
+
+	log.Printf("[Adaptive] 適応型負荷モード: 並行数 %d〜%d, ステップ %d, 許容エラー率 %.2f%%, 調整間隔 %v\n",
+		params.minConcurrency, params.maxConcurrency, params.step, params.targetErrorRate*100, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := sampler.next()
+			if cfg.pause.isPaused() {
+				// 一時停止中の区間はリクエストがないため、並行数を調整しません
+				continue
+			}
+			w := adaptiveWindow{
+				requests: stats.requests,
+				errors:   stats.errors,
+				p99:      percentileOf(stats.samples, 0.99),
+			}
+
+			current := pool.Size()
+			next, action := nextConcurrency(current, w, params)
+			pool.Resize(next)
+
+			point := AdaptivePoint{
+				ElapsedSec:  time.Since(start).Seconds(),
+				Concurrency: next,
+				Requests:    w.requests,
+				ErrorRate:   stats.errorRate(),
+				P99Latency:  stats.p99(),
+				Action:      action,
+			}
+
+			metrics.mu.Lock()
+			metrics.trajectory = append(metrics.trajectory, point)
+			metrics.mu.Unlock()
+
+			log.Printf("[Adaptive] %d -> %d (%s) 区間: %d件, エラー率 %.2f%%, p99 %s\n",
+				current, next, action, w.requests, point.ErrorRate*100, point.P99Latency)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestNextConcurrency は、区間の観測値に応じて、健全なら step だけ増やし、エラー率や p99 が許容値を超えたか
+// 1件も完了しなかった場合は step の2倍だけ減らし、上下限で頭打ちになった場合は hold とすることを確認します。
+func TestNextConcurrency(t *testing.T) {
+	params := adaptiveParams{minConcurrency: 1, maxConcurrency: 50, step: 5, targetErrorRate: 0.01, maxP99: 200 * time.Millisecond}
+	healthy := adaptiveWindow{requests: 1000, errors: 5, p99: 100 * time.Millisecond}
+	tests := []struct {
+		name       string
+		current    int
+		window     adaptiveWindow
+		params     adaptiveParams
+		wantNext   int
+		wantAction string
+	}{
+		{"健全なら増やす", 20, healthy, params, 25, adaptiveIncrease},
+		{"エラー率がちょうど許容値なら増やす", 20, adaptiveWindow{requests: 1000, errors: 10}, params, 25, adaptiveIncrease},
+		{"上限の手前では上限まで増やす", 48, healthy, params, 50, adaptiveIncrease},
+		{"上限に達していれば hold", 50, healthy, params, 50, adaptiveHold},
+		{"エラー率が許容値を超えたら減らす", 20, adaptiveWindow{requests: 1000, errors: 11}, params, 10, adaptiveDecrease},
+		{"p99 が上限を超えたら減らす", 20, adaptiveWindow{requests: 1000, p99: 250 * time.Millisecond}, params, 10, adaptiveDecrease},
+		{"max_p99 が未指定なら p99 は判定に使わない", 20, adaptiveWindow{requests: 1000, p99: time.Hour}, adaptiveParams{minConcurrency: 1, maxConcurrency: 50, step: 5, targetErrorRate: 0.01}, 25, adaptiveIncrease},
+		{"1件も完了しなければ減らす", 20, adaptiveWindow{}, params, 10, adaptiveDecrease},
+		{"下限の手前では下限まで減らす", 6, adaptiveWindow{}, params, 1, adaptiveDecrease},
+		{"下限に達していれば hold", 1, adaptiveWindow{}, params, 1, adaptiveHold},
+	}
+	for _, tt := range tests {
+		next, action := nextConcurrency(tt.current, tt.window, tt.params)
+		if next != tt.wantNext || action != tt.wantAction {
+			t.Errorf("%s: nextConcurrency(%d) = %d, %q, want %d, %q", tt.name, tt.current, next, action, tt.wantNext, tt.wantAction)
+		}
+	}
+}
+
+// TestNewAdaptiveParams は、未指定の項目が並行数から導いた既定値で補われ、上限が並行数を下回らないことを確認します。
+func TestNewAdaptiveParams(t *testing.T) {
+	p := newAdaptiveParams(&TestConfig{Concurrency: 40})
+	if p.minConcurrency != 1 || p.maxConcurrency != min(400, maxConcurrency) || p.step != 4 || p.targetErrorRate != defaultAdaptiveTargetErrorRate || p.maxP99 != 0 {
+		t.Errorf("既定値 = %+v", p)
+	}
+
+	p = newAdaptiveParams(&TestConfig{Concurrency: 5, AdaptiveMaxConcurrency: 3, AdaptiveMaxP99Ms: 150})
+	if p.maxConcurrency != 5 || p.step != 1 || p.maxP99 != 150*time.Millisecond {
+		t.Errorf("maxConcurrency=%d step=%d maxP99=%v: 上限は並行数以上、step は1以上になるはずです", p.maxConcurrency, p.step, p.maxP99)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"time"
+)
+
+// ==============================================================================
+// [セクション41] Apdex スコア: 目標レイテンシに対する満足度を 0〜1 の1つの数値で表す
+// ==============================================================================
+
+// パーセンタイルの一覧は詳しい反面、実行同士を比べるときに「どちらが良かったのか」を一目で判断しにくくなります。
+// apdex_target に目標レイテンシ T を指定すると、レイテンシの分布から Apdex スコアを算出し、レポートの apdex に記録します。
+//
+//   Apdex = (満足 + 許容 / 2) / 全件      満足: レイテンシ ≤ T、許容: T < レイテンシ ≤ 4T、不満: 4T < レイテンシ
+//
+// 1.0 はすべての応答が目標内、0.5 前後は利用者の多くが遅さを感じている状態の目安です。
+// 母数はレイテンシ統計と同じ（応答を受信したリクエスト）で、応答を受信できなかったネットワークエラーは含まれません。
+// リザーバーサンプリングが作動した場合は、保持しているサンプルから算出した推定値になります。
+
+// apdexToleratingFactor は、「許容」とみなすレイテンシの上限を目標レイテンシの何倍にするかです（Apdex の定義で4倍）。
+const apdexToleratingFactor = 4
+
+// apdexScore は、latencies と目標レイテンシ target から Apdex スコアを算出します。
+// サンプルが0件の場合は0を返します。latencies はソートされていなくてもかまいません
+// （件数が多い場合、レポートの生成時にはパーセンタイルの位置だけを確定させるためです。selection.go を参照）。
+func apdexScore(latencies []time.Duration, target time.Duration) float64 {
+	if len(latencies) == 0 {
+		return 0
+	}
+	var satisfied, tolerable int
+	for _, d := range latencies {
+		switch {
+		case d <= target:
+			satisfied++
+		case d <= apdexToleratingFactor*target:
+			tolerable++
+		}
+	}
+	return (float64(satisfied) + float64(tolerable)/2) / float64(len(latencies))
+}
+
+// mergeApdex は、複数レポートの Apdex スコアをサンプル数で加重平均して統合します。
+// Apdex は件数の比率のため、目標レイテンシが同じレポート同士であれば正確に統合できます。
+// 目標レイテンシが異なるレポートが含まれる場合や、スコアを持つレポートがない場合は nil を返します。
+func mergeApdex(reports []*TestReport) *float64 {
+	var sum, samples float64
+	for _, report := range reports {
+		if report.Apdex == nil || report.ApdexTarget != reports[0].ApdexTarget {
+			return nil
+		}
+		sum += *report.Apdex * float64(report.LatencySamples)
+		samples += float64(report.LatencySamples)
+	}
+	if samples == 0 {
+		return nil
+	}
+	score := sum / samples
+	return &score
+}
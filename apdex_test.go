@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestApdexScore は、満足・許容・不満の件数が分かっている分布で、Apdex スコアが手計算の値と一致することを確認します
+// （T と 4T ちょうどの境界の値を含みます）。
+func TestApdexScore(t *testing.T) {
+	ms := time.Millisecond
+	target := 100 * ms
+	tests := []struct {
+		name      string
+		latencies []time.Duration
+		want      float64
+	}{
+		{"0件", nil, 0},
+		{"すべて満足", []time.Duration{1 * ms, 50 * ms, 100 * ms}, 1},
+		{"すべて不満", []time.Duration{401 * ms, 2 * time.Second}, 0},
+		{
+			// 満足 4件（20, 50, 100, 100ms）、許容 3件（101, 250, 400ms）、不満 3件（401ms, 1s, 2s）
+			// → (4 + 3/2) / 10 = 0.55
+			name: "混在",
+			latencies: []time.Duration{
+				401 * ms, 100 * ms, 250 * ms, 20 * ms, time.Second,
+				101 * ms, 50 * ms, 2 * time.Second, 400 * ms, 100 * ms,
+			},
+			want: 0.55,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := apdexScore(tt.latencies, target); got != tt.want {
+				t.Errorf("apdexScore = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMergeApdex は、目標レイテンシが同じレポートの Apdex がサンプル数で加重平均され、異なる場合は nil になることを確認します。
+func TestMergeApdex(t *testing.T) {
+	high, low := 0.9, 0.5
+	a := &TestReport{Apdex: &high, ApdexTarget: "100ms", LatencySamples: 300}
+	b := &TestReport{Apdex: &low, ApdexTarget: "100ms", LatencySamples: 100}
+
+	// (0.9×300 + 0.5×100) / 400 = 0.8
+	if got := mergeApdex([]*TestReport{a, b}); got == nil || *got != 0.8 {
+		t.Errorf("mergeApdex = %v, want 0.8", got)
+	}
+	b.ApdexTarget = "200ms"
+	if got := mergeApdex([]*TestReport{a, b}); got != nil {
+		t.Errorf("mergeApdex = %v, want nil (目標レイテンシが異なります)", *got)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ==============================================================================
+// [セクション36] JSONレスポンスのアサーション (assert_json)
+// ==============================================================================
+
+// JSON APIでは、ステータスコードが200でもボディの内容がエラー（"status": "error" など）を示すことがあります。
+// assert_json に "パス=期待値" の形式のアサーションを指定すると、2xx のレスポンスごとにボディをJSONとして解析し、
+// パスの値が期待値と一致しない（パスが存在しない、JSONとして解析できない場合を含む）リクエストを
+// エラー種別 "json_assertion" の失敗として記録します。HTTPのエラー（4xx・5xx や通信エラー）とは区別して
+// json_assertion_failures に件数を報告します。2xx 以外のレスポンスはアサーションを評価せず、通常どおり記録します。
+//
+// 【パス】 "$" で始まり、".名前"、"[インデックス]"、["名前"] を連ねて指定します（JSONPath の基本的な部分集合です）。
+// 外部のライブラリに依存しないよう、ワイルドカードやフィルタ式には対応していません。
+//   $.status        $.data.items[0].id        $["content-type"]
+// 【期待値】 JSONの値（"ok"、42、true、null など）として解釈できればその値と、解釈できなければ文字列として比較します。
+// 数値は値として比較するため、42 と 42.0 は一致します。"==" も区切りとして使えます（$.status == "ok"）。
+//
+// ボディの解析はアサーションが指定された場合のみ行い、読み込むのは先頭の maxAssertBodyBytes バイトまでです
+// （それを超える部分は読み捨てるため、途中で切れたJSONは解析できずに失敗として記録されます）。
+
+// errKindJSONAssertion は、JSONレスポンスのアサーションに失敗したリクエストのエラー種別です。
+const errKindJSONAssertion = "json_assertion"
+
+// maxAssertBodyBytes は、アサーションのために解析するレスポンスボディの最大サイズです。
+const maxAssertBodyBytes = 1 << 20
+
+// jsonPathStep は、JSONパスの1段階です。key が有効な場合はオブジェクトのキー、そうでなければ配列のインデックスです。
+type jsonPathStep struct {
+	key   string
+	index int
+	isKey bool
+}
+
+// jsonAssertion は、1件のアサーション（パスと期待値）です。
+type jsonAssertion struct {
+	path     []jsonPathStep
+	expected any
+}
+
+// parseJSONAssertions は、assert_json の各項目を解釈します。問題がある場合は利用者向けのエラーメッセージを返します。
+func parseJSONAssertions(specs []string) ([]jsonAssertion, error) {
+	assertions := make([]jsonAssertion, 0, len(specs))
+	for i, spec := range specs {
+		rawPath, rawValue, ok := strings.Cut(spec, "==")
+		if !ok {
+			rawPath, rawValue, ok = strings.Cut(spec, "=")
+		}
+		if !ok {
+			return nil, fmt.Errorf("assert_json[%d] は \"パス=期待値\" の形式で指定してください: %q", i, spec)
+		}
+		path, err := parseJSONPath(strings.TrimSpace(rawPath))
+		if err != nil {
+			return nil, fmt.Errorf("assert_json[%d] のパスを解釈できません: %w", i, err)
+		}
+
+		// JSONの値として解釈できなければ、引用符のない文字列とみなします
+		rawValue = strings.TrimSpace(rawValue)
+		var expected any
+		if err := json.Unmarshal([]byte(rawValue), &expected); err != nil {
+			expected = rawValue
+		}
+		assertions = append(assertions, jsonAssertion{path: path, expected: expected})
+	}
+	return assertions, nil
+}
+
+// parseJSONPath は、"$.a.b[0]["c"]" 形式のパスを段階の一覧に分解します。
+func parseJSONPath(p string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(p, "$") {
+		return nil, fmt.Errorf("パスは \"$\" で始めてください: %q", p)
+	}
+	var steps []jsonPathStep
+	rest := p[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : 1+end]
+			if name == "" {
+				return nil, fmt.Errorf("\".\" の後ろにキーがありません: %q", p)
+			}
+			steps = append(steps, jsonPathStep{key: name, isKey: true})
+			rest = rest[1+end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("\"[\" が閉じられていません: %q", p)
+			}
+			inner := rest[1:end]
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, jsonPathStep{key: inner[1 : len(inner)-1], isKey: true})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("\"[]\" には0以上のインデックスか引用符付きのキーを指定してください: %q", p)
+				}
+				steps = append(steps, jsonPathStep{index: index})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("\".\" または \"[\" が必要な位置に %q があります: %q", rest[0], p)
+		}
+	}
+	return steps, nil
+}
+
+// lookup は、解析済みのJSONからパスの値を取り出します。パスが存在しない場合は false を返します。
+func (a *jsonAssertion) lookup(doc any) (any, bool) {
+	v := doc
+	for _, step := range a.path {
+		if step.isKey {
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if v, ok = obj[step.key]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		arr, ok := v.([]any)
+		if !ok || step.index >= len(arr) {
+			return nil, false
+		}
+		v = arr[step.index]
+	}
+	return v, true
+}
+
+// assertsResponse は、このステータスコードのレスポンスでアサーションを評価するかどうかを返します。
+func assertsResponse(cfg *TestConfig, statusCode int) bool {
+	return len(cfg.assertions) > 0 && statusCode >= 200 && statusCode < 300
+}
+
+// checkJSONAssertions は、body の先頭 maxAssertBodyBytes バイトをJSONとして解析し、すべてのアサーションが成り立つかを返します。
+// 読み込んだバイト数も返します（呼び出し側はその続きから読み捨てます）。
+func checkJSONAssertions(body io.Reader, assertions []jsonAssertion) (ok bool, read int64) {
+	data, _ := io.ReadAll(io.LimitReader(body, maxAssertBodyBytes))
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return false, int64(len(data))
+	}
+	for i := range assertions {
+		v, found := assertions[i].lookup(doc)
+		if !found || !reflect.DeepEqual(v, assertions[i].expected) {
+			return false, int64(len(data))
+		}
+	}
+	return true, int64(len(data))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestJSONAssertionFailures は、内容の異なるJSONを順に返すターゲットに対し、期待値と一致しない・JSONとして解析できない
+// 2xx のレスポンスだけが json_assertion_failures に数えられ、5xx はアサーションを評価せずHTTPのエラーとして記録されることを確認します。
+func TestJSONAssertionFailures(t *testing.T) {
+	var served [4]atomic.Int64
+	var n atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kind := (n.Add(1) - 1) % 4
+		served[kind].Add(1)
+		switch kind {
+		case 0:
+			w.Write([]byte(`{"status":"ok","data":{"items":[{"id":1.0}]}}`))
+		case 1:
+			w.Write([]byte(`{"status":"error","data":{"items":[]}}`))
+		case 2:
+			w.Write([]byte(`<html>not json</html>`))
+		case 3:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"status":"error"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":   server.URL,
+		"concurrency":  1,
+		"duration":     "300ms",
+		"assert_json":  []string{`$.status == "ok"`, "$.data.items[0].id=1"},
+		"no_preflight": true,
+	}))
+
+	// 1ワーカーが順に送信するため、テスト終了で中断された最後の1件を除いてサーバーの応答と一致します
+	mismatched := served[1].Load() + served[2].Load()
+	if f := int64(report.JSONAssertionFailures); f == 0 || f < mismatched-1 || f > mismatched {
+		t.Errorf("json_assertion_failures = %d, want %d（期待値と異なる %d 件と解析できない %d 件）", f, mismatched, served[1].Load(), served[2].Load())
+	}
+	if report.ErrorKinds[errKindJSONAssertion] != report.JSONAssertionFailures {
+		t.Errorf("error_kinds[%s] = %d, json_assertion_failures = %d", errKindJSONAssertion, report.ErrorKinds[errKindJSONAssertion], report.JSONAssertionFailures)
+	}
+	if want := report.JSONAssertionFailures + report.StatusCodes["500"]; uint64(report.Errors) != want {
+		t.Errorf("errors = %d, want %d（アサーションの失敗と 5xx の合計）", report.Errors, want)
+	}
+	if uint64(report.Success) != report.StatusCodes["200"]-report.JSONAssertionFailures {
+		t.Errorf("success = %d: アサーションが成り立った 2xx だけが成功になるはずです", report.Success)
+	}
+}
+
+// TestCheckJSONAssertions は、パスの記法・期待値の解釈（数値の比較、引用符のない文字列、null）と、
+// パスが存在しない場合やJSONとして解析できない場合の失敗を確認します。
+func TestCheckJSONAssertions(t *testing.T) {
+	const body = `{"status":"ok","count":42,"data":{"items":[{"id":7},{"id":8}]},"content-type":"json","next":null}`
+	tests := []struct {
+		spec string
+		body string
+		want bool
+	}{
+		{`$.status=ok`, body, true},
+		{`$.status == "ok"`, body, true},
+		{`$.count=42.0`, body, true},
+		{`$.data.items[1].id=8`, body, true},
+		{`$["content-type"]=json`, body, true},
+		{`$.next=null`, body, true},
+		{`$.status=error`, body, false},
+		{`$.count="42"`, body, false},
+		{`$.data.items[2].id=8`, body, false},
+		{`$.missing=null`, body, false},
+		{`$.status=ok`, `{"status":"ok"`, false},
+	}
+	for _, tt := range tests {
+		assertions, err := parseJSONAssertions([]string{tt.spec})
+		if err != nil {
+			t.Fatalf("parseJSONAssertions(%q): %v", tt.spec, err)
+		}
+		if got, read := checkJSONAssertions(strings.NewReader(tt.body), assertions); got != tt.want || read != int64(len(tt.body)) {
+			t.Errorf("%s を %s に対して評価 = %v（%d バイト読み込み）, want %v", tt.spec, tt.body, got, read, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"$.status", "status=ok", "$.=ok", "$[x]=1", "$.items[0=1"} {
+		if _, err := parseJSONAssertions([]string{invalid}); err == nil {
+			t.Errorf("parseJSONAssertions(%q) がエラーになりません", invalid)
+		}
+	}
+}
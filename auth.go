@@ -0,0 +1,247 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ==============================================================================
+// [セクション23] 認証付きターゲット: Basic 認証と Digest 認証（チャレンジ応答）
+// ==============================================================================
+
+// 認証が必要なエンドポイントに対して何も指定しないと、401 が記録されるだけで本来の処理の性能を測れません。
+// auth_type に "basic" を指定すると、すべてのリクエストに資格情報を最初から付与します。
+// "digest" を指定すると、401 と WWW-Authenticate: Digest のチャレンジを受け取った時点で応答を計算して1回だけ再送し、
+// 以降は取得したチャレンジ（nonce）を全ワーカーで共有して、最初から認証済みのリクエストを送信します。
+// サーバーが nonce を失効させた場合（stale=true など）は、再び 401 を受け取った時点でチャレンジを更新します。
+//
+// 再送が発生したリクエストは、再送を含めた全体の時間が1件のレイテンシとして記録されます。
+
+// 認証方式（TestConfig.AuthType）
+const (
+	authBasic  = "basic"
+	authDigest = "digest"
+)
+
+// digestChallenge は、サーバーから受け取った Digest 認証のチャレンジです。
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string // "MD5"、"MD5-sess"、"SHA-256"、"SHA-256-sess"
+	qop       string // "auth" を提示された場合のみ "auth"（auth-int は未対応のため使用しません）
+	nc        uint32 // この nonce で送信した回数（アトミックに増加させます）
+}
+
+// authTransport は、設定された方式で資格情報を付与する http.RoundTripper です。
+type authTransport struct {
+	base     http.RoundTripper
+	mode     string
+	user     string
+	password string
+
+	mu        sync.Mutex
+	challenge *digestChallenge // 直近のチャレンジ（未取得の場合は nil）
+}
+
+// newAuthTransport は、cfg に認証方式が指定されている場合に base を authTransport で包んで返します。
+func newAuthTransport(base http.RoundTripper, cfg *TestConfig) http.RoundTripper {
+	if cfg.AuthType == "" {
+		return base
+	}
+	return &authTransport{base: base, mode: cfg.AuthType, user: cfg.AuthUser, password: cfg.AuthPassword}
+}
+
+// RoundTrip は、資格情報を付与してリクエストを送信し、Digest のチャレンジを受け取った場合は1回だけ再送します。
+// RoundTripper の規約に従い、元のリクエストは変更せずに複製へヘッダーを設定します。
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.mode == authBasic {
+		r := req.Clone(req.Context())
+		r.SetBasicAuth(t.user, t.password)
+		return t.base.RoundTrip(r)
+	}
+
+	r := req
+	if c := t.currentChallenge(); c != nil {
+		r = t.withDigest(req, c)
+	}
+	resp, err := t.base.RoundTrip(r)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// ボディを持つリクエストは、再送用にボディを作り直せる場合のみ再送します
+	c := parseDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+	if c == nil || (req.Body != nil && req.GetBody == nil) {
+		return resp, nil
+	}
+	// 401 のボディは小さいため読み捨て、再送に同じ接続を使えるようにします
+	drainAndClose(resp, 64<<10)
+
+	t.mu.Lock()
+	t.challenge = c
+	t.mu.Unlock()
+
+	retry := t.withDigest(req, c)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return t.base.RoundTrip(retry)
+}
+
+// CloseIdleConnections は、内側の Transport のアイドル接続を閉じます（http.Client.CloseIdleConnections から呼ばれます）。
+func (t *authTransport) CloseIdleConnections() {
+	if ci, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// currentChallenge は、共有している直近のチャレンジを返します。
+func (t *authTransport) currentChallenge() *digestChallenge {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.challenge
+}
+
+// withDigest は、チャレンジ c に対する Authorization ヘッダーを付与したリクエストの複製を返します。
+func (t *authTransport) withDigest(req *http.Request, c *digestChallenge) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", c.authorize(t.user, t.password, req.Method, req.URL.RequestURI()))
+	return r
+}
+
+// authorize は、RFC 7616 に従って Authorization ヘッダーの値を計算します。
+func (c *digestChallenge) authorize(user, password, method, uri string) string {
+	var h func() hash.Hash = md5.New
+	if strings.HasPrefix(strings.ToUpper(c.algorithm), "SHA-256") {
+		h = sha256.New
+	}
+	digest := func(s string) string {
+		d := h()
+		d.Write([]byte(s))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	nc := fmt.Sprintf("%08x", atomic.AddUint32(&c.nc, 1))
+	cnonce := newCnonce()
+
+	ha1 := digest(user + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(strings.ToLower(c.algorithm), "-sess") {
+		ha1 = digest(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := digest(method + ":" + uri)
+
+	var response string
+	if c.qop != "" {
+		response = digest(ha1 + ":" + c.nonce + ":" + nc + ":" + cnonce + ":" + c.qop + ":" + ha2)
+	} else {
+		response = digest(ha1 + ":" + c.nonce + ":" + ha2)
+	}
+
+	parts := []string{
+		fmt.Sprintf("username=%q", user),
+		fmt.Sprintf("realm=%q", c.realm),
+		fmt.Sprintf("nonce=%q", c.nonce),
+		fmt.Sprintf("uri=%q", uri),
+		fmt.Sprintf("response=%q", response),
+	}
+	if c.algorithm != "" {
+		parts = append(parts, "algorithm="+c.algorithm)
+	}
+	if c.opaque != "" {
+		parts = append(parts, fmt.Sprintf("opaque=%q", c.opaque))
+	}
+	if c.qop != "" {
+		parts = append(parts, "qop="+c.qop, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+	return "Digest " + strings.Join(parts, ", ")
+}
+
+// newCnonce は、クライアント側の使い捨て乱数（cnonce）を生成します。
+func newCnonce() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// parseDigestChallenge は、WWW-Authenticate ヘッダーの中から Digest のチャレンジを探して解析します。
+// 見つからない場合や、未対応のアルゴリズム・qop のみを提示された場合は nil を返します。
+func parseDigestChallenge(headers []string) *digestChallenge {
+	for _, h := range headers {
+		scheme, params, ok := strings.Cut(strings.TrimSpace(h), " ")
+		if !ok || !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		p := parseAuthParams(params)
+		c := &digestChallenge{realm: p["realm"], nonce: p["nonce"], opaque: p["opaque"], algorithm: p["algorithm"]}
+		switch strings.ToUpper(c.algorithm) {
+		case "", "MD5", "MD5-SESS", "SHA-256", "SHA-256-SESS":
+		default:
+			continue
+		}
+		if qop, ok := p["qop"]; ok {
+			for _, q := range strings.Split(qop, ",") {
+				if strings.TrimSpace(q) == "auth" {
+					c.qop = "auth"
+				}
+			}
+			if c.qop == "" {
+				continue
+			}
+		}
+		if c.nonce != "" {
+			return c
+		}
+	}
+	return nil
+}
+
+// parseAuthParams は、key=value または key="quoted value" をカンマで区切った認証パラメーターを解析します。
+// 引用符内のカンマやエスケープ（\"）も扱います。キーは小文字に正規化します。
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params
+		}
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			return params
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			s = rest[end:]
+		}
+		params[key] = value.String()
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestDigestServer は、RFC 7616 の Digest 認証（MD5・qop=auth）を要求するターゲットを起動し、
+// 401 でチャレンジを返した回数のカウンタを返します。資格情報は user / pass です。
+func newTestDigestServer(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	const realm, nonce, opaque = "test realm, with comma", "dcd98b7102dd2f0e8b11d0f600bfb0c093", "5ccc069c403ebaf9f0171e9517f40e41"
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	var challenges atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if scheme, params, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && scheme == "Digest" {
+			p := parseAuthParams(params)
+			ha1 := md5hex("user:" + realm + ":pass")
+			ha2 := md5hex(r.Method + ":" + p["uri"])
+			want := md5hex(ha1 + ":" + nonce + ":" + p["nc"] + ":" + p["cnonce"] + ":auth:" + ha2)
+			if p["username"] == "user" && p["realm"] == realm && p["nonce"] == nonce && p["opaque"] == opaque &&
+				p["qop"] == "auth" && p["uri"] == r.URL.RequestURI() && p["response"] == want {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+		}
+		challenges.Add(1)
+		w.Header().Set("WWW-Authenticate", `Digest realm="`+realm+`", qop="auth,auth-int", nonce="`+nonce+`", opaque="`+opaque+`"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(server.Close)
+	return server, &challenges
+}
+
+// TestDigestAuth は、Digest 認証のターゲットに対し、401 のチャレンジに応答して再送したリクエストが成功として記録され、
+// 取得したチャレンジを全ワーカーで共有して、以降のリクエストは最初から認証済みで送信されることを確認します。
+func TestDigestAuth(t *testing.T) {
+	server, challenges := newTestDigestServer(t)
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":    server.URL + "/private?x=1",
+		"concurrency":   2,
+		"duration":      "300ms",
+		"auth_type":     authDigest,
+		"auth_user":     "user",
+		"auth_password": "pass",
+		"no_preflight":  true,
+	}))
+	if report.TotalRequests < 10 || report.Errors != 0 || report.StatusCodes["200"] != uint64(report.TotalRequests) {
+		t.Fatalf("total=%d errors=%d status_codes=%v: 再送した認証済みのリクエストがすべて成功するはずです",
+			report.TotalRequests, report.Errors, report.StatusCodes)
+	}
+	// チャレンジを受け取るのは、最初に並行して送信した各ワーカーの1件目だけです
+	if n := challenges.Load(); n == 0 || n > 2 {
+		t.Errorf("401 のチャレンジが %d 回返されました, want 1〜2 回（以降はチャレンジを共有して最初から認証するはずです）", n)
+	}
+
+	// 誤ったパスワードでは再送も 401 になり、そのまま記録します
+	wrong := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":    server.URL,
+		"concurrency":   1,
+		"duration":      "100ms",
+		"auth_type":     authDigest,
+		"auth_user":     "user",
+		"auth_password": "wrong",
+		"no_preflight":  true,
+	}))
+	if wrong.TotalRequests == 0 || wrong.StatusCodes["401"] != uint64(wrong.TotalRequests) {
+		t.Errorf("status_codes=%v: 誤ったパスワードではすべて 401 になるはずです", wrong.StatusCodes)
+	}
+}
+
+// TestParseAuthParams は、認証パラメーターの引用符・引用符内のカンマとエスケープ・引用符なしの値・キーの正規化を確認します。
+func TestParseAuthParams(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]string
+	}{
+		{`realm="a, b", nonce="xyz"`, map[string]string{"realm": "a, b", "nonce": "xyz"}},
+		{`realm="say \"hi\"", qop=auth`, map[string]string{"realm": `say "hi"`, "qop": "auth"}},
+		{`Realm = "x" ,  Algorithm=MD5-sess ,stale=TRUE`, map[string]string{"realm": "x", "algorithm": "MD5-sess", "stale": "TRUE"}},
+		{`qop="auth,auth-int"`, map[string]string{"qop": "auth,auth-int"}},
+		{`realm="unterminated`, map[string]string{"realm": "unterminated"}},
+		{`realm="x", garbage`, map[string]string{"realm": "x"}},
+		{``, map[string]string{}},
+	}
+	for _, tt := range tests {
+		if got := parseAuthParams(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAuthParams(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestParseDigestChallenge は、複数の WWW-Authenticate から対応できる Digest のチャレンジを選び、
+// 未対応のアルゴリズムや qop=auth-int のみのチャレンジを読み飛ばすことを確認します。
+func TestParseDigestChallenge(t *testing.T) {
+	c := parseDigestChallenge([]string{
+		`Basic realm="x"`,
+		`Digest realm="r", nonce="n1", algorithm=SHA-512`,
+		`Digest realm="r", nonce="n2", qop="auth-int"`,
+		`Digest realm="r", nonce="n3", qop="auth-int, auth", algorithm=SHA-256`,
+	})
+	if c == nil || c.nonce != "n3" || c.qop != "auth" || c.algorithm != "SHA-256" {
+		t.Errorf("選ばれたチャレンジ = %+v, want nonce n3・qop auth・SHA-256", c)
+	}
+	if c := parseDigestChallenge([]string{`Digest realm="r"`}); c != nil {
+		t.Errorf("nonce のないチャレンジ = %+v, want nil", c)
+	}
+}
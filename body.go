@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ==============================================================================
+// [セクション48] リクエストボディと Content-Type (body / form / content_type / -content-type)
+// ==============================================================================
+
+// POST や PUT でボディを送る際に Content-Type を付け忘れると、ターゲットがボディを正しく解釈できず、
+// 本番とは異なるエラー応答（400 や 415）ばかりを計測してしまいます。そこで、ボディを指定したテストには
+// Content-Type ヘッダーを自動で付与します。
+//   - 設定JSONの body         : 送信するボディ（文字列）。content_type を省略すると -content-type の値（既定は application/json）を付与します
+//   - 設定JSONの form         : キーと値のオブジェクト。URLエンコードしてボディにし、application/x-www-form-urlencoded を付与します
+//   - 設定JSONの content_type : 付与する Content-Type を明示します（ボディがない場合も、指定すれば付与します）
+//   - サーバーの -content-type : body の content_type を省略した場合の既定値
+//
+// body と form は同時に指定できません。ボディはベースリクエストに1度だけ持たせ、送信のたびに GetBody で
+// 読み出し位置の独立したものを作り直します（sendRequest を参照）。request_file の各行のボディには適用しません
+// （行ごとに headers と body を指定するため）。
+
+// headers を指定すると、各リクエストに任意のヘッダー（API キーや Accept など）を付与します。名前の規則は request_file の
+// headers と同じで、値に改行は含められません。"Host" は、送信先のホスト名を変えずに Host ヘッダーだけを上書きします。
+
+// formContentType は、form を指定した場合に付与する Content-Type です。
+const formContentType = "application/x-www-form-urlencoded"
+
+// defaultContentType は、body の content_type を省略した場合に付与する Content-Type です（-content-type フラグ）。
+var defaultContentType = "application/json"
+
+// resolveBody は、body と form を検証し、送信するボディと Content-Type を cfg.Body と cfg.ContentType に確定させます。
+func resolveBody(cfg *TestConfig) error {
+	if cfg.Body == "" && len(cfg.Form) == 0 {
+		return nil
+	}
+	if cfg.Body != "" && len(cfg.Form) > 0 {
+		return errors.New("body と form は同時に指定できません（どちらか一方を指定してください）")
+	}
+	if cfg.Mode != modeHTTP || cfg.RequestFile != "" {
+		return errors.New("body と form は HTTPモードでのみ使用でき、request_file とは併用できません（request_file では各行の body を指定してください）")
+	}
+	if len(cfg.Form) > 0 {
+		values := url.Values{}
+		for key, value := range cfg.Form {
+			if key == "" {
+				return errors.New("form のキーが空です")
+			}
+			values.Set(key, value)
+		}
+		// url.Values.Encode はキーの昇順で出力するため、同じ設定からは常に同じボディになります
+		cfg.Body = values.Encode()
+		if cfg.ContentType == "" {
+			cfg.ContentType = formContentType
+		}
+		return nil
+	}
+	if cfg.ContentType == "" {
+		cfg.ContentType = defaultContentType
+	}
+	return nil
+}
+
+// validateHeaders は、headers の名前と値を検証します。
+func validateHeaders(cfg *TestConfig) error {
+	if len(cfg.Headers) == 0 {
+		return nil
+	}
+	if cfg.Mode != modeHTTP {
+		return errors.New("headers は HTTPモードでのみ指定できます")
+	}
+	for name, value := range cfg.Headers {
+		// ヘッダー名の規則（トークン文字のみ）はメソッド名と同じです
+		if !isValidMethod(name) {
+			return fmt.Errorf("headers にヘッダー名として使用できない文字列があります: %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("headers の %s の値に改行は含められません", name)
+		}
+	}
+	return nil
+}
+
+// newBaseRequest は、cfg のボディと Content-Type を持たせたリクエストを生成します。
+// ボディのないリクエストに Content-Length: 0 が付かないよう、ボディが空の場合は nil のまま生成します。
+func newBaseRequest(ctx context.Context, method, rawURL string, cfg *TestConfig) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	if cfg.Body != "" {
+		req, err = http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader([]byte(cfg.Body)))
+	} else if cfg.genBody != nil {
+		// 生成ボディは、送信のたびに GetBody でブロックを先頭から読み出すリーダーを作り直します（genbody.go を参照）
+		req, err = http.NewRequestWithContext(ctx, method, rawURL, nil)
+		if err == nil {
+			req.Body, _ = cfg.genBody.newReader()
+			req.GetBody = cfg.genBody.newReader
+			req.ContentLength = cfg.genBody.size
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, rawURL, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ContentType != "" {
+		req.Header.Set("Content-Type", cfg.ContentType)
+	}
+	for name, value := range cfg.Headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+	return req, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// receivedBody は、ターゲットが受信したリクエストの Content-Type とボディです。
+type receivedBody struct {
+	contentType string
+	body        string
+}
+
+// newBodyRecordingServer は、受信したリクエストの Content-Type とボディを、異なるものごとに記録するターゲットを起動します。
+func newBodyRecordingServer(t *testing.T) (*httptest.Server, func() []receivedBody) {
+	t.Helper()
+	var mu sync.Mutex
+	seen := make(map[receivedBody]bool)
+	var order []receivedBody
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got := receivedBody{r.Header.Get("Content-Type"), string(body)}
+		mu.Lock()
+		if !seen[got] {
+			seen[got] = true
+			order = append(order, got)
+		}
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []receivedBody {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]receivedBody(nil), order...)
+	}
+}
+
+// TestBodyContentType は、body と form のそれぞれで、正しい Content-Type とエンコード済みのボディが
+// すべてのリクエストでそのままターゲットに届くことを確認します。
+func TestBodyContentType(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]any
+		want   receivedBody
+	}{
+		{
+			"body の既定は application/json",
+			map[string]any{"body": `{"name":"太郎"}`},
+			receivedBody{"application/json", `{"name":"太郎"}`},
+		},
+		{
+			"body に content_type を明示",
+			map[string]any{"body": "a,b\n1,2\n", "content_type": "text/csv; charset=utf-8"},
+			receivedBody{"text/csv; charset=utf-8", "a,b\n1,2\n"},
+		},
+		{
+			"form は URL エンコード",
+			map[string]any{"form": map[string]string{"user": "山田 太郎", "expr": "a&b=c+d", "empty": ""}},
+			receivedBody{formContentType, "empty=&expr=a%26b%3Dc%2Bd&user=%E5%B1%B1%E7%94%B0+%E5%A4%AA%E9%83%8E"},
+		},
+		{
+			"ボディなし",
+			map[string]any{},
+			receivedBody{"", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, received := newBodyRecordingServer(t)
+			config := map[string]any{
+				"target_url":   server.URL,
+				"method":       http.MethodPost,
+				"concurrency":  2,
+				"duration":     "100ms",
+				"no_preflight": true,
+			}
+			for key, value := range tt.config {
+				config[key] = value
+			}
+			report := runTestLoad(newTestConfig(t, config))
+			if report.TotalRequests == 0 || report.Errors != 0 {
+				t.Fatalf("total=%d errors=%d", report.TotalRequests, report.Errors)
+			}
+			// 送信のたびに GetBody で作り直すため、何件目のリクエストでも同じボディが届くはずです
+			if got := received(); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("受信した Content-Type とボディ = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBodyValidation は、body と form の同時指定と、form の空のキーを拒否することを確認します。
+func TestBodyValidation(t *testing.T) {
+	for _, body := range []string{
+		`{"target_url":"http://127.0.0.1:1","method":"POST","body":"{}","form":{"a":"1"}}`,
+		`{"target_url":"http://127.0.0.1:1","method":"POST","form":{"":"1"}}`,
+	} {
+		if cfg, rec := postConfig(t, body, false); cfg != nil || rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		} else if !strings.Contains(rec.Body.String(), "form") {
+			t.Errorf("%s: エラー = %s", body, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション18] キャッシュバスティング: リクエストごとに一意な値を付与してキャッシュを回避
+// ==============================================================================
+
+// CDNやリバースプロキシのキャッシュが効いているターゲットでは、オリジンまで届かずにキャッシュから応答が返り、
+// 非現実的に低いレイテンシが計測されてしまいます。cache_bust を指定すると、リクエストごとに一意な値を付与します。
+//   - "query"  : URLに _cb=<一意な値> のクエリパラメーターを追加します（最も確実に別のキャッシュキーになります）
+//   - "header" : Cache-Control: no-cache / Pragma: no-cache と、X-Cache-Bust: <一意な値> ヘッダーを付与します
+//                （URLを変えたくない場合用。キャッシュがこれらのヘッダーを尊重するかは実装次第です）
+
+// キャッシュバスティングの方式（TestConfig.CacheBust）
+const (
+	cacheBustQuery  = "query"
+	cacheBustHeader = "header"
+)
+
+// cacheBustParam は、"query" 方式で追加するクエリパラメーター名です。
+const cacheBustParam = "_cb"
+
+// cacheBuster は、リクエストごとに一意な値を生成して付与します。
+// カウンタはアトミックに増加させるため、複数のGoroutineから共有しても安全です。
+type cacheBuster struct {
+	mode   string
+	prefix string // 実行・ワーカーごとに異なる接頭辞（前回のテストで温まったキャッシュにも当たらないようにします）
+	n      uint64
+}
+
+// newCacheBuster は、ワーカー番号 index 用の cacheBuster を生成します。mode が空の場合は nil を返します。
+func newCacheBuster(mode string, index int) *cacheBuster {
+	if mode == "" {
+		return nil
+	}
+	prefix := strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.Itoa(index) + "-"
+	return &cacheBuster{mode: mode, prefix: prefix}
+}
+
+// apply は、複製済みのリクエストに一意な値を付与します（nil の場合は何もしません）。
+// http.Request.Clone は URL とヘッダーを複製するため、ベースリクエストには影響しません。
+func (cb *cacheBuster) apply(req *http.Request) {
+	if cb == nil {
+		return
+	}
+	value := cb.prefix + strconv.FormatUint(atomic.AddUint64(&cb.n, 1), 36)
+
+	switch cb.mode {
+	case cacheBustQuery:
+		if req.URL.RawQuery == "" {
+			req.URL.RawQuery = cacheBustParam + "=" + value
+		} else {
+			req.URL.RawQuery += "&" + cacheBustParam + "=" + value
+		}
+	case cacheBustHeader:
+		req.Header.Set("Cache-Control", "no-cache")
+		req.Header.Set("Pragma", "no-cache")
+		req.Header.Set("X-Cache-Bust", value)
+	}
+}
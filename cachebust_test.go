@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestCacheBustUniqueValues は、cache_bust の query・header の各方式で、連続するリクエスト（複数のワーカーにまたがるものを含む）が
+// すべて異なる値をターゲットに届け、元のクエリパラメーターを残すことを確認します。
+func TestCacheBustUniqueValues(t *testing.T) {
+	for _, mode := range []string{cacheBustQuery, cacheBustHeader} {
+		t.Run(mode, func(t *testing.T) {
+			var mu sync.Mutex
+			seen := make(map[string]int)
+			var received int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				value := r.URL.Query().Get(cacheBustParam)
+				if mode == cacheBustHeader {
+					value = r.Header.Get("X-Cache-Bust")
+					if r.Header.Get("Cache-Control") != "no-cache" || r.Header.Get("Pragma") != "no-cache" {
+						t.Errorf("Cache-Control=%q Pragma=%q, want no-cache", r.Header.Get("Cache-Control"), r.Header.Get("Pragma"))
+					}
+				}
+				if r.URL.Query().Get("page") != "1" {
+					t.Errorf("クエリ %q: 元のパラメーターが失われています", r.URL.RawQuery)
+				}
+				mu.Lock()
+				seen[value]++
+				received++
+				mu.Unlock()
+			}))
+			t.Cleanup(server.Close)
+
+			runTestLoad(newTestConfig(t, map[string]any{
+				"target_url":   server.URL + "/items?page=1",
+				"concurrency":  4,
+				"duration":     "200ms",
+				"cache_bust":   mode,
+				"no_preflight": true,
+			}))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if received < 10 {
+				t.Fatalf("リクエストが %d 件しか届きません", received)
+			}
+			if seen[""] > 0 {
+				t.Errorf("値のないリクエストが %d 件届きました", seen[""])
+			}
+			if len(seen) != received {
+				t.Errorf("%d 件のリクエストに対し、一意な値は %d 種類です: 値が重複しています", received, len(seen))
+			}
+		})
+	}
+}
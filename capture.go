@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション28] リクエスト／レスポンスのヘッダー捕捉: デバッグ用の少数サンプル
+// ==============================================================================
+
+// 「なぜ 403 になるのか」「キャッシュが効いているのか」を調べるには、集計値よりも実際のやり取りの中身が役に立ちます。
+// capture_samples に件数を指定すると、テスト開始直後の最初の N 件のリクエストについて、
+// リクエスト行とヘッダー、レスポンスのステータス行とヘッダー（通信エラーの場合はエラー内容）を
+// レポートの sample_exchanges に記録します。
+// ヘッダーの複製は捕捉対象のリクエストだけで行い、枠が埋まった後はアトミックな読み込み1回で素通りするため、
+// 捕捉を指定しないテストや枠が埋まった後のホットパスには影響しません。
+
+// maxCaptureSamples は、capture_samples に指定できる件数の上限です（レポートの肥大化を防ぐため）。
+const maxCaptureSamples = 100
+
+// redactedHeaders は、レポートに値を残さないリクエストヘッダーです（共有されたレポートから資格情報が漏れないようにするため）。
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// SampleExchange は、捕捉した1件のリクエストとレスポンスのやり取りです。
+type SampleExchange struct {
+	Request         string      `json:"request"`                    // リクエスト行（例: "GET https://example.com/ HTTP/1.1"）
+	RequestHeaders  http.Header `json:"request_headers"`            // 送信したリクエストヘッダー（資格情報は伏せ字）
+	Status          string      `json:"status,omitempty"`           // ステータス行（例: "HTTP/1.1 200 OK"）
+	ResponseHeaders http.Header `json:"response_headers,omitempty"` // 受信したレスポンスヘッダー
+	Error           string      `json:"error,omitempty"`            // 応答を受信できなかった場合のエラー
+	Latency         string      `json:"latency"`                    // 応答ヘッダーを受信するまで（エラーの場合は失敗するまで）の時間
+}
+
+// captureExchange は、捕捉の枠が残っている場合に限り、1件のやり取りを記録します。
+// resp と err はいずれか一方のみが設定されている前提です（http.Client.Do の戻り値）。
+func (rm *ResultMetrics) captureExchange(req *http.Request, resp *http.Response, err error, latency time.Duration) {
+	limit := rm.captureLimit
+	if limit <= 0 || atomic.LoadInt64(&rm.captured) >= limit {
+		return
+	}
+	// 複数のワーカーが同時に最後の枠を取り合っても、件数がちょうど limit 件になるよう予約してから記録します
+	if atomic.AddInt64(&rm.captured, 1) > limit {
+		return
+	}
+
+	exchange := SampleExchange{
+		Request:        req.Method + " " + req.URL.String() + " " + req.Proto,
+		RequestHeaders: req.Header.Clone(),
+		Latency:        formatDuration(latency),
+	}
+	for _, name := range redactedHeaders {
+		if exchange.RequestHeaders.Get(name) != "" {
+			exchange.RequestHeaders.Set(name, "[REDACTED]")
+		}
+	}
+	if err != nil {
+		exchange.Error = err.Error()
+	} else {
+		exchange.Status = resp.Proto + " " + resp.Status
+		exchange.ResponseHeaders = resp.Header.Clone()
+	}
+
+	rm.mu.Lock()
+	rm.exchanges = append(rm.exchanges, exchange)
+	rm.mu.Unlock()
+}
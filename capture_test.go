@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestCaptureSamples は、capture_samples を指定すると、複数のワーカーが並行して送信してもちょうど N 件のやり取りが
+// sample_exchanges に記録され、送受信したヘッダーとステータス行が正しく、資格情報は伏せ字になることを確認します。
+func TestCaptureSamples(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 伏せ字にするのはレポートに記録する複製だけで、送信するヘッダーはそのままです
+		if r.Header.Get("X-Test") != "" && r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q: 送信するヘッダーが書き換えられています", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("X-Echo", r.Header.Get("X-Test"))
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(server.Close)
+
+	const samples = 3
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":      server.URL + "/items",
+		"method":          http.MethodPost,
+		"concurrency":     4,
+		"duration":        "200ms",
+		"headers":         map[string]string{"X-Test": "hello", "Authorization": "Bearer secret", "Cookie": "session=secret"},
+		"capture_samples": samples,
+	}))
+
+	if report.TotalRequests <= samples || len(report.SampleExchanges) != samples {
+		t.Fatalf("total=%d sample_exchanges=%d 件, want %d 件", report.TotalRequests, len(report.SampleExchanges), samples)
+	}
+	for i, ex := range report.SampleExchanges {
+		if want := "POST " + server.URL + "/items HTTP/1.1"; ex.Request != want {
+			t.Errorf("[%d] request = %q, want %q", i, ex.Request, want)
+		}
+		if got := ex.RequestHeaders.Get("X-Test"); got != "hello" {
+			t.Errorf("[%d] request_headers の X-Test = %q, want hello", i, got)
+		}
+		for _, name := range []string{"Authorization", "Cookie"} {
+			if got := ex.RequestHeaders.Get(name); got != "[REDACTED]" {
+				t.Errorf("[%d] request_headers の %s = %q: 資格情報は伏せ字にするはずです", i, name, got)
+			}
+		}
+		if ex.Status != "HTTP/1.1 201 Created" || ex.Error != "" {
+			t.Errorf("[%d] status = %q, error = %q, want HTTP/1.1 201 Created", i, ex.Status, ex.Error)
+		}
+		if ex.ResponseHeaders.Get("X-Echo") != "hello" || ex.ResponseHeaders.Get("Cache-Control") != "max-age=60" {
+			t.Errorf("[%d] response_headers = %v: 受信したヘッダーが記録されていません", i, ex.ResponseHeaders)
+		}
+		if ex.Latency == "" || strings.HasPrefix(ex.Latency, "N/A") {
+			t.Errorf("[%d] latency = %q", i, ex.Latency)
+		}
+	}
+
+	// 捕捉を指定しないテストでは記録しません
+	none := runTestLoad(newTestConfig(t, map[string]any{"target_url": server.URL, "concurrency": 1, "duration": "100ms"}))
+	if len(none.SampleExchanges) != 0 {
+		t.Errorf("sample_exchanges = %d 件: capture_samples を指定しない場合は記録しないはずです", len(none.SampleExchanges))
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// ==============================================================================
+// [セクション32] 接続あたりのリクエスト数の上限: 接続の確立・切断の負荷試験
+// ==============================================================================
+
+// 通常のテストは Keep-Alive で接続を使い回すため、ターゲットの接続確立・切断の処理（accept、TLSハンドシェイク、
+// TIME_WAIT の蓄積など）にはほとんど負荷がかかりません。一方、接続を長く保持しないクライアント
+// （モバイル回線やプロキシ経由の利用者など）が多い実環境では、この処理がボトルネックになることがあります。
+// max_requests_per_conn に N を指定すると、全体で N 件に1件のリクエストに Connection: close を付与し、
+// そのリクエストに使われた接続を応答後に切断させます。接続は全ワーカーで共有されるプールから均等に使われるため、
+// 1接続あたりの平均リクエスト数はおおむね N になり、新規接続数は 総リクエスト数 / N に比例します。
+// 完全な Keep-Alive（未指定）と、毎回切断する N=1 の間を連続的に調整できます。
+
+// connChurn は、max_requests_per_conn に従って切断させるリクエストを選びます。
+type connChurn struct {
+	n    uint64 // 1接続あたりのリクエスト数の上限
+	sent uint64 // これまでに送信したリクエスト数（アトミックに更新）
+}
+
+// newConnChurn は、maxRequestsPerConn 件に1件の割合で接続を切断させる connChurn を生成します。
+// 0以下の場合は切断させないため nil を返します（nil の connChurn は何もしません）。
+func newConnChurn(maxRequestsPerConn int) *connChurn {
+	if maxRequestsPerConn <= 0 {
+		return nil
+	}
+	return &connChurn{n: uint64(maxRequestsPerConn)}
+}
+
+// apply は、このリクエストが N 件目にあたる場合に、応答後に接続を切断するよう指定します。
+func (c *connChurn) apply(req *http.Request) {
+	if c == nil {
+		return
+	}
+	if atomic.AddUint64(&c.sent, 1)%c.n == 0 {
+		req.Close = true
+	}
+}
+
+// requestsPerConnection は、新規接続1本あたりの平均リクエスト数を返します（接続が0本の場合は0）。
+func requestsPerConnection(total int, connections uint64) float64 {
+	if connections == 0 {
+		return 0
+	}
+	return float64(total) / float64(connections)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestMaxRequestsPerConnScalesConnections は、max_requests_per_conn に N を指定すると、新規接続数が 総リクエスト数 / N
+// （に最初に開く接続の分を加えた数）になり、サーバー側で観測した接続数とも一致することを確認します。
+func TestMaxRequestsPerConnScalesConnections(t *testing.T) {
+	const workers = 4
+	for _, n := range []int{1, 5, 20} {
+		t.Run(fmt.Sprintf("N=%d", n), func(t *testing.T) {
+			server := newInflightServer(t, time.Millisecond)
+			report := runTestLoad(newTestConfig(t, map[string]any{
+				"target_url":            server.URL,
+				"concurrency":           workers,
+				"duration":              "400ms",
+				"max_requests_per_conn": n,
+				"no_preflight":          true,
+			}))
+			if report.TotalRequests < 100 || report.Errors != 0 {
+				t.Fatalf("total=%d errors=%d", report.TotalRequests, report.Errors)
+			}
+
+			// N 件に1件の応答で接続を切断し、切断のたびに1本開き直します。各ワーカーの最初の接続の分だけ多くなり得ます
+			closed := uint64(report.TotalRequests / n)
+			if opened := report.ConnectionsOpened; opened < closed || opened > closed+workers {
+				t.Errorf("connections_opened = %d (total=%d), want %d〜%d", opened, report.TotalRequests, closed, closed+workers)
+			}
+			// テスト終了の直前に開き直した接続は、確立の途中で中断されるとクライアント側では数えられず、
+			// 確立後にリクエストを送る前に中断されるとサーバー側では数えられないため、どちらにもワーカー数の分まで前後します
+			if got, opened := server.connCount(), int(report.ConnectionsOpened); got < opened-workers || got > opened+workers {
+				t.Errorf("サーバー側の接続数 = %d, connections_opened = %d", got, report.ConnectionsOpened)
+			}
+			// 終了の直前に確立の途中で中断された接続はクライアント側で数えられないため、N をわずかに上回ることがあります
+			if rpc := report.RequestsPerConnection; rpc > float64(n)*1.05 || rpc < float64(n)/2 {
+				t.Errorf("requests_per_connection = %.3f, want 約 %d", rpc, n)
+			}
+		})
+	}
+}
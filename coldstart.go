@@ -0,0 +1,39 @@
+package main
+
+import (
+	"time"
+)
+
+// ==============================================================================
+// [セクション55] ワーカーごとの初回リクエストの除外 (exclude_cold_start)
+// ==============================================================================
+
+// 各ワーカーの最初のリクエストは、コネクションプールに使い回せる接続がないため、TCP の接続確立と TLS のハンドシェイクの
+// 費用を含みます。ウォームアップ（テスト全体の最初の一定時間）とは異なり、この費用はワーカーの数だけ発生し、
+// ランプアップでワーカーが増えるたびにテストの途中でも現れるため、p99 などの裾のパーセンタイルを押し上げます。
+//
+// exclude_cold_start を指定すると、各ワーカーの最初の1件（応答の成否にかかわらず最初に送信したリクエスト）の
+// レイテンシを、レポートのレイテンシ統計（最小・平均・パーセンタイル・最大）と区間ごとの統計から除外し、
+// 代わりに cold_start_latency として別に集計します。リクエスト数・ステータスコード・エラーの集計には通常どおり含めます。
+// これにより、接続の確立を含まない定常状態のレイテンシと、接続の確立を含む初回のレイテンシを分けて評価できます。
+//
+// 【制約】 ワーカーを持たないオープンモデルでは使用できません（HTTPのクローズドモデルのみ）。
+// targets で複数のターゲットを指定した場合に除外されるのは各ワーカーの最初の1件だけで、
+// 2つ目以降のターゲットへの最初の接続の費用は、通常のレイテンシに含まれます。
+
+// addColdStart は、ワーカーの最初のリクエストのレイテンシを、通常のレイテンシとは別に記録します。
+func (rm *ResultMetrics) addColdStart(d time.Duration) {
+	rm.mu.Lock()
+	rm.coldStartLatencies = append(rm.coldStartLatencies, d)
+	rm.mu.Unlock()
+}
+
+// addSampleLatency は、応答を受信したリクエストのレイテンシを、ワーカーの最初のリクエストであれば cold_start_latency に、
+// それ以外であれば通常のレイテンシ統計に記録します。
+func (rm *ResultMetrics) addSampleLatency(s sample) {
+	if s.coldStart {
+		rm.addColdStart(s.dur)
+		return
+	}
+	rm.addLatency(s.dur)
+}
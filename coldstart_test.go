@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestExcludeColdStart は、接続ごとの最初のリクエストにだけ 200ms かかるターゲットに対し、exclude_cold_start を指定すると
+// 各ワーカーの最初の1件が cold_start_latency に分けて集計され、定常状態の p99 と最大値がそれを含まないことを、
+// 指定しない場合と比べて確認します。
+func TestExcludeColdStart(t *testing.T) {
+	const workers, coldDelay = 4, 200 * time.Millisecond
+	for _, exclude := range []bool{true, false} {
+		var mu sync.Mutex
+		seen := make(map[string]bool)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			first := !seen[r.RemoteAddr]
+			seen[r.RemoteAddr] = true
+			mu.Unlock()
+			if first {
+				time.Sleep(coldDelay)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		report := runTestLoad(newTestConfig(t, map[string]any{
+			"target_url":         server.URL,
+			"concurrency":        workers,
+			"duration":           "600ms",
+			"exclude_cold_start": exclude,
+			"no_preflight":       true,
+		}))
+		if report.TotalRequests < 100 || report.Errors != 0 {
+			t.Fatalf("total=%d errors=%d", report.TotalRequests, report.Errors)
+		}
+		p99 := parseSummaryDuration(t, report.P99Latency)
+		maxLatency := parseSummaryDuration(t, report.MaxLatency)
+
+		if !exclude {
+			if report.ColdStart != nil || maxLatency < coldDelay || report.LatencySamples != report.TotalRequests {
+				t.Errorf("cold_start_latency=%+v max=%s latency_samples=%d: 除外しない場合は最初の1件も通常の統計に含めるはずです",
+					report.ColdStart, report.MaxLatency, report.LatencySamples)
+			}
+			continue
+		}
+		cold := report.ColdStart
+		if cold == nil || cold.Samples != workers {
+			t.Fatalf("cold_start_latency = %+v, want ワーカー数と同じ %d 件", cold, workers)
+		}
+		if got := parseSummaryDuration(t, cold.Min); got < coldDelay {
+			t.Errorf("cold_start_latency の最小値 = %s, want %v 以上", cold.Min, coldDelay)
+		}
+		if p99 >= coldDelay/2 || maxLatency >= coldDelay/2 {
+			t.Errorf("p99=%s max=%s: 定常状態のレイテンシは最初の1件を含まないはずです", report.P99Latency, report.MaxLatency)
+		}
+		if report.LatencySamples != report.TotalRequests-workers {
+			t.Errorf("latency_samples = %d, total = %d: 最初の %d 件だけを除外するはずです", report.LatencySamples, report.TotalRequests, workers)
+		}
+	}
+}
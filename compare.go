@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==============================================================================
+// [セクション64] 比較ターゲット: 2つのエンドポイントを同じ条件で並べて計測する (compare_target_url)
+// ==============================================================================
+
+// 旧サービスと新サービスのどちらが速いかを、別々に実行したテストのレポートで比べると、実行した時間帯のネットワークや
+// テスター自身の負荷の違いが結果に混ざります。compare_target_url を指定すると、target_url（A）と compare_target_url（B）に
+// 同じ設定（並行数・実行時間・ヘッダー・ボディなど）のテストを同じプロセスから実行し、A のレポートの comparison に
+// B のレポートと主要な指標の差分（B − A）を並べて記録します。
+//
+//   compare_mode = "concurrent"（既定）: A と B を同時に実行します。同じ時間帯・同じマシンの条件で比較できますが、
+//                                        テスターの CPU と帯域を分け合うため、それぞれの絶対値は単独で実行した場合より低めになります。
+//   compare_mode = "sequential"        : A の後に B を実行します。テスターの資源を占有できますが、時間帯の違いが入ります（所要時間は2倍）。
+//
+// slower には、p50 のレイテンシが comparisonTiePct を超えて大きい側（"a" または "b"）を記録します。差がそれ以下の場合は空です。
+// p50 を基準にするのは、少数の外れ値に左右されず、典型的な応答の速さの違いを表すためです（p99 の差は deltas で確認できます）。
+//
+// 【制約】 ファイルへ書き出す設定（export_path・trace_out・latency_histogram_path）や、送信先を別に指定する設定
+// （targets・request_file・replay_trace）とは併用できません。forever とも併用できません。
+// ジョブ（/api/start）の進捗は A のテストの値を表示します。
+
+// 比較の実行方法（TestConfig.CompareMode）
+const (
+	compareConcurrent = "concurrent"
+	compareSequential = "sequential"
+)
+
+// comparisonTiePct は、p50 の差がこの割合（%）以下の場合に、どちらが遅いとも判定しない閾値です。
+const comparisonTiePct = 5.0
+
+// ComparisonReport は、比較ターゲットのテストの結果と、A に対する差分です。
+type ComparisonReport struct {
+	Mode    string            `json:"mode"`     // concurrent / sequential
+	TargetA string            `json:"target_a"` // target_url
+	TargetB string            `json:"target_b"` // compare_target_url
+	B       *TestReport       `json:"b"`        // B のテストのレポート
+	Deltas  []ComparisonDelta `json:"deltas"`   // 主要な指標の差分（B − A）
+	Slower  string            `json:"slower,omitempty"`
+	Verdict string            `json:"verdict"` // 比較結果の要約
+}
+
+// ComparisonDelta は、1つの指標の A と B の値と差分です。
+type ComparisonDelta struct {
+	Metric   string   `json:"metric"`
+	A        float64  `json:"a"`
+	B        float64  `json:"b"`
+	Delta    float64  `json:"delta"`               // B − A
+	DeltaPct *float64 `json:"delta_pct,omitempty"` // A に対する変化率（%）。A が0の場合は省略
+}
+
+// validateComparison は、compare_target_url と compare_mode を検証し、compare_mode の既定値を補います。
+func validateComparison(cfg *TestConfig) error {
+	if cfg.CompareTargetURL == "" {
+		if cfg.CompareMode != "" {
+			return fmt.Errorf("compare_mode を指定する場合は compare_target_url も指定してください")
+		}
+		return nil
+	}
+	u, err := url.Parse(cfg.CompareTargetURL)
+	schemes := []string{"http", "https"}
+	if cfg.Mode == modeWebSocket {
+		schemes = []string{"ws", "wss"}
+	}
+	if err != nil || u.Host == "" || (u.Scheme != schemes[0] && u.Scheme != schemes[1]) {
+		return fmt.Errorf("compare_target_url には %s または %s の URL を指定してください: %q", schemes[0], schemes[1], cfg.CompareTargetURL)
+	}
+	switch cfg.CompareMode {
+	case "":
+		cfg.CompareMode = compareConcurrent
+	case compareConcurrent, compareSequential:
+	default:
+		return fmt.Errorf("未対応の compare_mode です: %q (concurrent または sequential を指定してください)", cfg.CompareMode)
+	}
+	if cfg.ExportPath != "" || cfg.TraceOut != "" || cfg.LatencyHistogramPath != "" ||
+		len(cfg.Targets) > 0 || cfg.RequestFile != "" || cfg.ReplayTrace != "" || cfg.Forever {
+		return fmt.Errorf("compare_target_url は、export_path・trace_out・latency_histogram_path・targets・request_file・replay_trace・forever とは併用できません")
+	}
+	return nil
+}
+
+// runComparison は、A（cfg）と B（compare_target_url）のテストを compare_mode に従って実行し、
+// B の結果と差分を comparison に記録した A のレポートを返します。metrics は A のテストに使います。
+func runComparison(parent context.Context, cfg *TestConfig, metrics *ResultMetrics) *TestReport {
+	// B は送信先だけを差し替えた設定の複製です（実行時の状態は runLoadTest がそれぞれに設定します）
+	cfgA, cfgB := *cfg, *cfg
+	cfgA.CompareTargetURL = ""
+	cfgB.CompareTargetURL = ""
+	cfgB.TargetURL = cfg.CompareTargetURL
+	// 警告の一覧は各テストのレポートへ追記されるため、同時に実行しても同じ配列へ書き込まないよう容量を切り詰めます
+	cfgA.warnings, cfgB.warnings = slices.Clip(cfg.warnings), slices.Clip(cfg.warnings)
+	metricsB := NewResultMetrics(estimateTotalRequests(&cfgB))
+
+	log.Printf("[Compare] A: %s と B: %s を比較します (%s)\n", cfgA.TargetURL, cfgB.TargetURL, cfg.CompareMode)
+	var reportA, reportB *TestReport
+	if cfg.CompareMode == compareSequential {
+		reportA = runLoadTest(parent, &cfgA, metrics)
+		if parent.Err() == nil {
+			reportB = runLoadTest(parent, &cfgB, metricsB)
+		} else {
+			reportB = &TestReport{StatusCodes: make(map[string]uint64), ErrorMsg: "A のテストの途中で停止されたため、B は実行していません"}
+		}
+	} else {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reportB = runLoadTest(parent, &cfgB, metricsB)
+		}()
+		reportA = runLoadTest(parent, &cfgA, metrics)
+		wg.Wait()
+	}
+
+	comparison := compareReports(reportA, reportB)
+	comparison.Mode = cfg.CompareMode
+	comparison.TargetA, comparison.TargetB = cfgA.TargetURL, cfgB.TargetURL
+	reportA.Comparison = comparison
+	for _, line := range strings.Split(strings.TrimRight(formatComparison(comparison, reportA), "\n"), "\n") {
+		log.Printf("[Compare] %s\n", line)
+	}
+	return reportA
+}
+
+// compareReports は、A と B のレポートの主要な指標を比較します。
+func compareReports(a, b *TestReport) *ComparisonReport {
+	c := &ComparisonReport{B: b}
+	add := func(metric string, va, vb float64) {
+		d := ComparisonDelta{Metric: metric, A: va, B: vb, Delta: vb - va}
+		if va != 0 {
+			pct := (vb - va) / va * 100
+			d.DeltaPct = &pct
+		}
+		c.Deltas = append(c.Deltas, d)
+	}
+	add("throughput_rps", a.ThroughputRPS, b.ThroughputRPS)
+	add("mean_latency_ms", latencyMs(a.MeanLatency), latencyMs(b.MeanLatency))
+	add("p50_latency_ms", latencyMs(a.P50Latency), latencyMs(b.P50Latency))
+	add("p90_latency_ms", latencyMs(a.P90Latency), latencyMs(b.P90Latency))
+	add("p99_latency_ms", latencyMs(a.P99Latency), latencyMs(b.P99Latency))
+	add("error_rate_pct", errorRatePct(a), errorRatePct(b))
+
+	p50a, p50b := latencyMs(a.P50Latency), latencyMs(b.P50Latency)
+	switch {
+	case a.LatencySamples == 0 || b.LatencySamples == 0:
+		c.Verdict = "応答を受信できなかった側があるため、レイテンシを比較できません"
+	case math.Abs(p50b-p50a) <= max(p50a, p50b)*comparisonTiePct/100:
+		c.Verdict = fmt.Sprintf("p50 の差は %.0f%% 以内で、速さに明確な差はありません", comparisonTiePct)
+	case p50b > p50a:
+		c.Slower = "b"
+		c.Verdict = fmt.Sprintf("B の方が遅い（p50: %.2fms → %.2fms, %+.1f%%）", p50a, p50b, (p50b-p50a)/p50a*100)
+	default:
+		c.Slower = "a"
+		c.Verdict = fmt.Sprintf("A の方が遅い（p50: %.2fms → %.2fms, %+.1f%%）", p50a, p50b, (p50b-p50a)/p50a*100)
+	}
+	return c
+}
+
+// latencyMs は、レポートのレイテンシの文字列をミリ秒に換算します（"N/A" などの解釈できない値は0）。
+func latencyMs(s string) float64 {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0
+	}
+	return float64(d) / float64(time.Millisecond)
+}
+
+// errorRatePct は、総リクエスト数に対するエラーの割合（%）を返します。
+func errorRatePct(report *TestReport) float64 {
+	if report.TotalRequests == 0 {
+		return 0
+	}
+	return float64(report.Errors) / float64(report.TotalRequests) * 100
+}
+
+// formatComparison は、比較結果を A と B を並べた表の文字列にします（ログ出力用）。
+func formatComparison(c *ComparisonReport, a *TestReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "A: %s\n", c.TargetA)
+	fmt.Fprintf(&b, "B: %s\n", c.TargetB)
+	fmt.Fprintf(&b, "%-16s %12s %12s %12s %9s\n", "metric", "A", "B", "B-A", "%")
+	for _, d := range c.Deltas {
+		pct := "-"
+		if d.DeltaPct != nil {
+			pct = fmt.Sprintf("%+.1f%%", *d.DeltaPct)
+		}
+		fmt.Fprintf(&b, "%-16s %12.2f %12.2f %+12.2f %9s\n", d.Metric, d.A, d.B, d.Delta, pct)
+	}
+	fmt.Fprintf(&b, "総リクエスト数: A %d / B %d\n", a.TotalRequests, c.B.TotalRequests)
+	if c.B.ErrorMsg != "" {
+		fmt.Fprintf(&b, "B のエラー: %s\n", c.B.ErrorMsg)
+	}
+	fmt.Fprintf(&b, "判定: %s\n", c.Verdict)
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newDelayServer は、すべてのリクエストに delay だけ遅れて応答するターゲットを起動します。
+func newDelayServer(t *testing.T, delay time.Duration) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestComparisonIdentifiesSlower は、応答の速さの異なる2つのターゲットを compare_target_url で比較すると、
+// A と B の順序や compare_mode にかかわらず遅い側が slower に記録され、p50 の差分がその向きになることを確認します。
+func TestComparisonIdentifiesSlower(t *testing.T) {
+	fast, slow := newDelayServer(t, 2*time.Millisecond), newDelayServer(t, 20*time.Millisecond)
+	tests := []struct {
+		name       string
+		a, b       *httptest.Server
+		mode       string
+		wantSlower string
+	}{
+		{"B が遅い・同時", fast, slow, compareConcurrent, "b"},
+		{"A が遅い・同時", slow, fast, compareConcurrent, "a"},
+		{"B が遅い・順番", fast, slow, compareSequential, "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := runTestLoad(newTestConfig(t, map[string]any{
+				"target_url":         tt.a.URL,
+				"compare_target_url": tt.b.URL,
+				"compare_mode":       tt.mode,
+				"concurrency":        2,
+				"duration":           "300ms",
+			}))
+			c := report.Comparison
+			if c == nil || c.B == nil {
+				t.Fatalf("comparison が記録されていません: error_msg=%q", report.ErrorMsg)
+			}
+			if report.TotalRequests == 0 || c.B.TotalRequests == 0 || report.Errors != 0 || c.B.Errors != 0 {
+				t.Fatalf("A: total=%d errors=%d, B: total=%d errors=%d", report.TotalRequests, report.Errors, c.B.TotalRequests, c.B.Errors)
+			}
+			if c.Mode != tt.mode || c.TargetA != tt.a.URL || c.TargetB != tt.b.URL {
+				t.Errorf("mode=%q target_a=%q target_b=%q", c.Mode, c.TargetA, c.TargetB)
+			}
+			if c.Slower != tt.wantSlower {
+				t.Errorf("slower = %q, want %q（verdict: %s）", c.Slower, tt.wantSlower, c.Verdict)
+			}
+
+			var p50 *ComparisonDelta
+			for i := range c.Deltas {
+				if c.Deltas[i].Metric == "p50_latency_ms" {
+					p50 = &c.Deltas[i]
+				}
+			}
+			if p50 == nil {
+				t.Fatalf("deltas に p50_latency_ms がありません: %+v", c.Deltas)
+			}
+			// B − A の差分は、B が遅い場合は正、A が遅い場合は負です
+			if (tt.wantSlower == "b") != (p50.Delta > 0) || p50.Delta != p50.B-p50.A {
+				t.Errorf("p50 の差分 = %+v", *p50)
+			}
+		})
+	}
+}
+
+// TestCompareReportsTie は、p50 の差が comparisonTiePct 以内の場合はどちらも遅いと判定しないことと、
+// 応答を受信できなかった側がある場合はレイテンシを比較しないことを確認します。
+func TestCompareReportsTie(t *testing.T) {
+	a := &TestReport{P50Latency: "10.00ms", LatencySamples: 100, TotalRequests: 100}
+	b := &TestReport{P50Latency: "10.40ms", LatencySamples: 100, TotalRequests: 100}
+	if c := compareReports(a, b); c.Slower != "" || !strings.Contains(c.Verdict, "明確な差はありません") {
+		t.Errorf("slower=%q verdict=%q: 4%% の差は引き分けのはずです", c.Slower, c.Verdict)
+	}
+	b = &TestReport{P50Latency: "N/A", TotalRequests: 100, Errors: 100}
+	if c := compareReports(a, b); c.Slower != "" || !strings.Contains(c.Verdict, "比較できません") {
+		t.Errorf("slower=%q verdict=%q: 応答のない側がある場合は比較しないはずです", c.Slower, c.Verdict)
+	}
+}
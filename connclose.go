@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// ==============================================================================
+// [セクション58] Connection: close の検出: 接続の使い回しを妨げるターゲット
+// ==============================================================================
+
+// テスターは常に keep-alive で接続を使い回しますが、ターゲット（またはその手前のプロキシ）が応答に
+// "Connection: close" を付けると、その接続は応答の後に閉じられ、次のリクエストは新しい接続（TCP と TLS の
+// ハンドシェイク）からやり直しになります。エラーにはならないため気づきにくく、スループットが想定より低い、
+// TIME_WAIT が大量に残る、といった症状の原因になります。
+//
+// HTTP/1.x の応答のうち、ターゲットが接続を閉じると通知したもの（Connection: close、または keep-alive に対応しない
+// HTTP/1.0 の応答）の件数を connection_close_responses に、総リクエスト数に対する割合を connection_close_pct に記録します。
+// max_requests_per_conn によってテスター自身が接続の切断を求めたリクエストは数えません。
+// 割合が connectionCloseWarnPct 以上の場合は、1接続あたりのリクエスト数（requests_per_connection）を添えて warnings に警告を記録します。
+
+// connectionCloseWarnPct は、Connection: close の応答の割合がこれ以上の場合に警告を記録する閾値（%）です。
+const connectionCloseWarnPct = 10.0
+
+// recordConnectionClose は、ターゲットが応答の後に接続を閉じると通知した場合に、その件数を数えます。
+func recordConnectionClose(metrics *ResultMetrics, req *http.Request, resp *http.Response) {
+	// HTTP/2・HTTP/3 には Connection ヘッダーがなく、resp.Close は HTTP/1.x の応答でのみ意味を持ちます
+	if resp.Close && !req.Close && resp.ProtoMajor == 1 {
+		atomic.AddUint64(&metrics.ConnectionCloseResponses, 1)
+	}
+}
+
+// connectionClosePct は、総リクエスト数に対する Connection: close の応答の割合（%）を返します。
+func connectionClosePct(closed uint64, total int) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(closed) / float64(total) * 100
+}
+
+// connectionCloseWarning は、Connection: close の応答の割合が閾値以上の場合に、レポートに記録する警告を返します（閾値未満の場合は空文字）。
+func connectionCloseWarning(report *TestReport) string {
+	if report.ConnectionClosePct < connectionCloseWarnPct {
+		return ""
+	}
+	return fmt.Sprintf("ターゲットの応答の %.1f%% が Connection: close を返し、接続を使い回せませんでした（1接続あたり平均 %.1f リクエスト）。"+
+		"ターゲットやプロキシの keep-alive の設定（上限のリクエスト数やタイムアウト）を確認してください。想定よりスループットが低い原因になっている可能性があります",
+		report.ConnectionClosePct, report.RequestsPerConnection)
+}
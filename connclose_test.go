@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// hasConnectionCloseWarning は、warnings に Connection: close の警告が含まれているかどうかを返します。
+func hasConnectionCloseWarning(warnings []string) bool {
+	for _, w := range warnings {
+		if strings.Contains(w, "Connection: close") {
+			return true
+		}
+	}
+	return false
+}
+
+// TestConnectionClose は、Connection: close を返すターゲットの応答の件数と割合が記録され、割合が閾値以上の場合だけ
+// 警告が出ることと、max_requests_per_conn でテスター自身が切断を求めた応答は数えないことを確認します。
+func TestConnectionClose(t *testing.T) {
+	tests := []struct {
+		name        string
+		closeEvery  int64 // この件数に1件、Connection: close を返します（0 の場合は返しません）
+		config      map[string]any
+		wantPct     float64
+		wantWarning bool
+	}{
+		{"すべての応答", 1, nil, 100, true},
+		{"20件に1件", 20, nil, 5, false},
+		{"max_requests_per_conn による切断", 0, map[string]any{"max_requests_per_conn": 1}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if n := requests.Add(1); tt.closeEvery > 0 && n%tt.closeEvery == 0 {
+					w.Header().Set("Connection", "close")
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			config := map[string]any{
+				"target_url":   server.URL,
+				"concurrency":  1,
+				"duration":     "300ms",
+				"no_preflight": true,
+			}
+			for key, value := range tt.config {
+				config[key] = value
+			}
+			report := runTestLoad(newTestConfig(t, config))
+			if report.TotalRequests < 40 || report.Errors != 0 {
+				t.Fatalf("total=%d errors=%d", report.TotalRequests, report.Errors)
+			}
+			// ワーカーは1つなので、記録された応答のうち Connection: close のものはほぼ正確に closeEvery 件に1件です
+			if math.Abs(report.ConnectionClosePct-tt.wantPct) > 1 {
+				t.Errorf("connection_close_pct = %.2f (connection_close_responses=%d, total=%d), want 約 %.0f",
+					report.ConnectionClosePct, report.ConnectionCloseResponses, report.TotalRequests, tt.wantPct)
+			}
+			if got := hasConnectionCloseWarning(report.Warnings); got != tt.wantWarning {
+				t.Errorf("warnings = %q, 警告あり = %v, want %v", report.Warnings, got, tt.wantWarning)
+			}
+			// ターゲットが接続を閉じるたびに、次のリクエストは新しい接続から始まります
+			if tt.closeEvery == 1 && report.ConnectionsOpened < uint64(report.TotalRequests) {
+				t.Errorf("connections_opened = %d, total = %d: すべてのリクエストが新しい接続で送信されるはずです", report.ConnectionsOpened, report.TotalRequests)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+)
+
+// ==============================================================================
+// [セクション68] 接続数の上限: ワーカー数とコネクションプールの大きさを分ける (connections)
+// ==============================================================================
+
+// 従来は concurrency がそのまま「ワーカー（Goroutine）の数」と「コネクションプールの大きさ」の両方を決めていたため、
+// 「1000人の利用者が、手前のプロキシが張る100本の接続を共有する」ような状況を再現できませんでした。
+// connections を指定すると、ワーカー数とは別に、1ホストあたりに張る接続の上限（Transport の MaxConnsPerHost と
+// アイドル接続の保持数）を指定できます。
+//
+//   concurrency : ワーカー（Goroutine）の数です。クローズドモデルの各ワーカーは、応答を受け取るまで次を送信しないため、
+//                 同時に送信しようとするリクエストの数の上限になります（オープンモデルでは max_in_flight が同じ役割です）。
+//   connections : 1ホストあたりの TCP 接続の上限です。省略した場合（0）は従来どおり、ワーカー数の2倍まで接続します。
+//
+// connections がワーカー数より少ない場合、HTTP/1.1 では1本の接続で同時に1リクエストしか送れないため、
+// ターゲットが同時に受け付けるリクエストの数は connections に制限され、残りのワーカーは接続が空くまで待機します。
+// 待機した時間は、接続プールの順番待ちとしてレイテンシに含まれます（peak_inflight・avg_inflight にも待機中のリクエストを含みます）。
+// HTTP/2 では1本の接続で多数のリクエストを同時に送れるため、connections は接続の本数だけを制限します。
+//
+// isolated_clients（ワーカーごとに専用のプール）、http3、WebSocket モードとは併用できません。
+// 上限はサーバーの -max-concurrency 以下です（limits.go を参照）。
+
+// validateConnections は、connections が指定されている場合に、併用できない設定と値の範囲を検証します。
+func validateConnections(cfg *TestConfig) error {
+	if cfg.Connections == 0 {
+		return nil
+	}
+	if cfg.Connections < 0 {
+		return fmt.Errorf("connections には1以上の値を指定してください（0または省略した場合はワーカー数の2倍まで接続します）: %d", cfg.Connections)
+	}
+	if cfg.Mode != modeHTTP || cfg.HTTP3 || cfg.IsolatedClients {
+		return fmt.Errorf("connections は、共有のコネクションプールを使う HTTP/1.1・HTTP/2 のテストでのみ指定できます（http3・isolated_clients・WebSocket モードとは併用できません）")
+	}
+	return nil
+}
+
+// connsPerHost は、poolSize（ワーカー数、または通信中のリクエスト数の上限）のクライアントで、1ホストあたりに張る接続の上限を返します。
+func connsPerHost(poolSize int, cfg *TestConfig) int {
+	if cfg.Connections > 0 {
+		return cfg.Connections
+	}
+	return poolSize * 2
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// inflightServer は、同時に処理しているリクエストの最大数と、リクエストを受けた接続の数をサーバー側で数えるターゲットです。
+// レポートの peak_inflight は接続の空きを待っているリクエストも含むため、ターゲットに同時に届いた数はサーバー側で数えます。
+type inflightServer struct {
+	*httptest.Server
+	inflight, peak atomic.Int64
+
+	mu    sync.Mutex
+	conns map[string]bool // リクエストを受けた接続（クライアントのアドレス）
+}
+
+// newInflightServer は、各リクエストに delay だけかけて応答する inflightServer を起動します。
+func newInflightServer(t *testing.T, delay time.Duration) *inflightServer {
+	t.Helper()
+	s := &inflightServer{conns: make(map[string]bool)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := s.inflight.Add(1)
+		defer s.inflight.Add(-1)
+		for {
+			peak := s.peak.Load()
+			if n <= peak || s.peak.CompareAndSwap(peak, n) {
+				break
+			}
+		}
+		s.mu.Lock()
+		s.conns[r.RemoteAddr] = true
+		s.mu.Unlock()
+
+		time.Sleep(delay)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// connCount は、リクエストを受けた接続の数を返します。
+func (s *inflightServer) connCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// TestConnectionsCapInFlight は、connections をワーカー数より少なく指定すると、ターゲットに同時に届くリクエストの数と
+// 接続の本数が connections 以下に抑えられることを確認します（省略した場合はワーカー数まで同時に届くことと比べます）。
+func TestConnectionsCapInFlight(t *testing.T) {
+	const workers = 8
+	tests := []struct {
+		name        string
+		connections int
+		wantPeak    int64 // サーバー側で観測する同時処理数の上限
+	}{
+		{"connections=2", 2, 2},
+		{"省略", 0, workers},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newInflightServer(t, 20*time.Millisecond)
+			fields := map[string]any{
+				"target_url":   server.URL,
+				"concurrency":  workers,
+				"duration":     "400ms",
+				"no_preflight": true,
+			}
+			if tt.connections > 0 {
+				fields["connections"] = tt.connections
+			}
+			report := runTestLoad(newTestConfig(t, fields))
+
+			if report.TotalRequests == 0 || report.Errors != 0 {
+				t.Fatalf("total=%d errors=%d: %s", report.TotalRequests, report.Errors, report.ErrorMsg)
+			}
+			peak := server.peak.Load()
+			if peak > tt.wantPeak {
+				t.Errorf("サーバーでの同時処理数の最大 = %d, want <= %d", peak, tt.wantPeak)
+			}
+			if tt.connections > 0 {
+				if conns := server.connCount(); conns > tt.connections {
+					t.Errorf("接続の本数 = %d, want <= %d", conns, tt.connections)
+				}
+				// 上限まで使い切っていること（待機中のワーカーが空いた接続を順に使うこと）
+				if peak != int64(tt.connections) {
+					t.Errorf("サーバーでの同時処理数の最大 = %d, want %d", peak, tt.connections)
+				}
+			} else if peak <= 2 {
+				t.Errorf("サーバーでの同時処理数の最大 = %d: connections を省略した場合はワーカー数まで同時に届くはずです", peak)
+			}
+		})
+	}
+}
+
+// TestIsolatedClientsConnections は、isolated_clients を指定すると、各ワーカーが専用のプールの接続を使い回し、
+// 確立される接続の数（トレースの新規接続の数と、サーバー側で観測する接続の数）が並行数に比例することを確認します。
+func TestIsolatedClientsConnections(t *testing.T) {
+	for _, workers := range []int{2, 8} {
+		server := newInflightServer(t, 10*time.Millisecond)
+		report := runTestLoad(newTestConfig(t, map[string]any{
+			"target_url":       server.URL,
+			"concurrency":      workers,
+			"duration":         "300ms",
+			"isolated_clients": true,
+			"no_preflight":     true,
+		}))
+		if report.TotalRequests < workers*5 || report.Errors != 0 {
+			t.Fatalf("concurrency=%d: total=%d errors=%d: 各ワーカーが接続を使い回して送信するはずです (%s)", workers, report.TotalRequests, report.Errors, report.ErrorMsg)
+		}
+		if report.ConnectionsOpened != uint64(workers) {
+			t.Errorf("concurrency=%d: connections_opened = %d, want %d", workers, report.ConnectionsOpened, workers)
+		}
+		if conns := server.connCount(); conns != workers {
+			t.Errorf("concurrency=%d: サーバーで観測した接続の数 = %d, want %d", workers, conns, workers)
+		}
+	}
+}
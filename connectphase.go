@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ==============================================================================
+// [セクション72] フェーズごとの接続タイムアウト: 立ち上がりの間だけ接続の確立を長めに待つ (warmup_connect_timeout)
+// ==============================================================================
+
+// 接続の確立（名前解決と TCP のハンドシェイク）には、従来はリクエストのタイムアウト（timeout）以外の上限がありませんでした。
+// connect_timeout を指定すると、接続の確立をその時間で打ち切ります。定常状態のターゲットが接続を受け付けるのは速いはずなので、
+// 短い値にすると、accept キューのあふれなどによる接続の詰まりを、リクエストのタイムアウトを待たずに接続エラーとして検出できます。
+//
+// ところが、テストの開始直後はターゲットが温まっておらず（オートスケールの途中、JIT の最適化前、接続の殺到など）、
+// 接続の受け付けが遅れがちです。同じ短い値のままでは、立ち上がりの間だけ接続エラーが大量に発生し、結果を歪めます。
+// warmup_period を指定すると、テストの開始からその時間の間（ウォームアップのフェーズ）は warmup_connect_timeout を、
+// それ以降（定常のフェーズ）は connect_timeout を使います。warmup_connect_timeout を省略した場合、ウォームアップの間は
+// 接続の確立に個別の上限を設けません（リクエストのタイムアウトだけが上限になります）。
+// ダイヤラーは、接続を確立し始めた時点のフェーズのタイムアウトを使います（prewarm_connections の接続はウォームアップに含みます）。
+//
+// レポートの connect_phases に、各タイムアウトと、接続の確立に失敗した数（dial_errors）をフェーズごとに分けて記録します。
+// 接続の確立は dial_concurrency の空きを待った後から数えます（空きを待つ時間はタイムアウトに含みません）。
+// http3（QUIC）は TCP の接続を使わないため、併用できません。
+
+// ConnectPhaseReport は、フェーズごとの接続タイムアウトと、接続の確立に失敗した数です。
+type ConnectPhaseReport struct {
+	ConnectTimeout       string  `json:"connect_timeout"`                  // 定常のフェーズの接続タイムアウト
+	WarmupConnectTimeout string  `json:"warmup_connect_timeout,omitempty"` // ウォームアップのフェーズの接続タイムアウト（省略時は timeout のみ）
+	WarmupPeriodSec      float64 `json:"warmup_period_sec,omitempty"`      // ウォームアップのフェーズの長さ（秒）
+	WarmupDialErrors     uint64  `json:"warmup_dial_errors"`               // ウォームアップのフェーズに確立を始めて失敗した接続の数
+	SteadyDialErrors     uint64  `json:"steady_dial_errors"`               // 定常のフェーズに確立を始めて失敗した接続の数
+}
+
+// validateConnectPhases は、connect_timeout・warmup_connect_timeout・warmup_period の組み合わせと値の範囲を検証します。
+func validateConnectPhases(cfg *TestConfig) error {
+	if cfg.ConnectTimeout < 0 || cfg.WarmupConnectTimeout < 0 || cfg.WarmupPeriod < 0 {
+		return fmt.Errorf("connect_timeout・warmup_connect_timeout・warmup_period には0以上の値を指定してください")
+	}
+	if cfg.ConnectTimeout == 0 {
+		if cfg.WarmupConnectTimeout != 0 || cfg.WarmupPeriod != 0 {
+			return fmt.Errorf("warmup_connect_timeout・warmup_period を指定する場合は connect_timeout（定常のフェーズの接続タイムアウト）も指定してください")
+		}
+		return nil
+	}
+	if cfg.HTTP3 {
+		return fmt.Errorf("connect_timeout は http3 とは併用できません（QUIC は TCP の接続を確立しないためです）")
+	}
+	if cfg.WarmupConnectTimeout != 0 && cfg.WarmupPeriod == 0 {
+		return fmt.Errorf("warmup_connect_timeout を指定する場合は warmup_period（ウォームアップのフェーズの長さ）も指定してください")
+	}
+	if cfg.WarmupConnectTimeout != 0 && cfg.WarmupConnectTimeout < cfg.ConnectTimeout {
+		return fmt.Errorf("warmup_connect_timeout には connect_timeout 以上の値を指定してください（ウォームアップの間の方を長く待つためのものです）: %v < %v",
+			time.Duration(cfg.WarmupConnectTimeout), time.Duration(cfg.ConnectTimeout))
+	}
+	return nil
+}
+
+// connectPhases は、テストの開始からの経過時間に応じた接続タイムアウトです。nil の connectPhases はタイムアウトを設けません。
+type connectPhases struct {
+	steady time.Duration
+	warmup time.Duration // 0の場合は、ウォームアップの間は上限を設けません
+	period time.Duration
+}
+
+// newConnectPhases は、connect_timeout が指定されている場合に connectPhases を生成します（未指定の場合は nil）。
+func newConnectPhases(cfg *TestConfig) *connectPhases {
+	if cfg.ConnectTimeout <= 0 {
+		return nil
+	}
+	return &connectPhases{
+		steady: time.Duration(cfg.ConnectTimeout),
+		warmup: time.Duration(cfg.WarmupConnectTimeout),
+		period: time.Duration(cfg.WarmupPeriod),
+	}
+}
+
+// inWarmup は、経過時間 elapsed がウォームアップのフェーズかどうかを返します。
+func (p *connectPhases) inWarmup(elapsed time.Duration) bool {
+	return p != nil && elapsed < p.period
+}
+
+// timeout は、経過時間 elapsed の時点で確立を始める接続のタイムアウトを返します（0の場合は上限を設けません）。
+func (p *connectPhases) timeout(elapsed time.Duration) time.Duration {
+	switch {
+	case p == nil:
+		return 0
+	case p.inWarmup(elapsed):
+		return p.warmup
+	}
+	return p.steady
+}
+
+// report は、レポートの connect_phases を返します（connect_timeout を指定しない場合は nil）。
+func (p *connectPhases) report(warmupErrors, steadyErrors uint64) *ConnectPhaseReport {
+	if p == nil {
+		return nil
+	}
+	r := &ConnectPhaseReport{
+		ConnectTimeout:   p.steady.String(),
+		WarmupPeriodSec:  p.period.Seconds(),
+		WarmupDialErrors: warmupErrors,
+		SteadyDialErrors: steadyErrors,
+	}
+	if p.warmup > 0 {
+		r.WarmupConnectTimeout = p.warmup.String()
+	}
+	return r
+}
+
+// mergeConnectPhases は、-merge で統合するレポートの connect_phases について、接続の確立に失敗した数を合計します
+// （タイムアウトとフェーズの長さは、最初に見つかったレポートの値を使います）。
+func mergeConnectPhases(merged, report *ConnectPhaseReport) *ConnectPhaseReport {
+	if report == nil {
+		return merged
+	}
+	if merged == nil {
+		copied := *report
+		return &copied
+	}
+	merged.WarmupDialErrors += report.WarmupDialErrors
+	merged.SteadyDialErrors += report.SteadyDialErrors
+	return merged
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConnectPhasesSlowStart は、開始直後の 300ms だけ接続の受け付けに 150ms かかる（その後は即座に受け付ける）
+// 立ち上がりの遅いターゲットを模したダイヤル関数に対し、50ms の connect_timeout だけでは立ち上がりの間の接続が
+// タイムアウトで失敗し続け、warmup_period の間だけ warmup_connect_timeout で長めに待つと接続エラーが減ることと、
+// 失敗した数がフェーズごとに分けて connect_phases に出ることを確認します。
+func TestConnectPhasesSlowStart(t *testing.T) {
+	const (
+		workers   = 4
+		coldFor   = 300 * time.Millisecond
+		coldDelay = 150 * time.Millisecond
+		runFor    = 600 * time.Millisecond
+	)
+
+	run := func(phases *connectPhases) *dialGate {
+		gate := newDialGate(0)
+		gate.phases = phases
+		slowStart := func(ctx context.Context) (net.Conn, error) {
+			delay := time.Millisecond
+			if time.Since(gate.start) < coldFor {
+				delay = coldDelay
+			}
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		}
+
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for time.Since(gate.start) < runFor {
+					conn, err := gate.dial(context.Background(), slowStart)
+					if err == nil {
+						conn.Close()
+					} else if !errors.Is(err, context.DeadlineExceeded) {
+						t.Errorf("想定外のエラー: %v", err)
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+			}()
+		}
+		wg.Wait()
+		return gate
+	}
+
+	strict := run(&connectPhases{steady: 50 * time.Millisecond})
+	if strict.errors == 0 || strict.warmupErrors != 0 {
+		t.Errorf("connect_timeout のみ: errors=%d warmup=%d: 立ち上がりの間の接続がタイムアウトで失敗し、すべて定常のフェーズに数えられるはずです",
+			strict.errors, strict.warmupErrors)
+	}
+
+	lenient := run(&connectPhases{steady: 50 * time.Millisecond, warmup: time.Second, period: 400 * time.Millisecond})
+	if lenient.errors >= strict.errors {
+		t.Errorf("warmup_connect_timeout あり: errors=%d, connect_timeout のみ: errors=%d: 立ち上がりの接続エラーが減るはずです",
+			lenient.errors, strict.errors)
+	}
+	if lenient.warmupErrors != 0 {
+		t.Errorf("warmup_connect_timeout あり: warmup=%d, want 0（ウォームアップの間は 1s まで待つはずです）", lenient.warmupErrors)
+	}
+
+	report := lenient.phases.report(lenient.warmupErrors, lenient.errors-lenient.warmupErrors)
+	if report.ConnectTimeout != "50ms" || report.WarmupConnectTimeout != "1s" || report.WarmupPeriodSec != 0.4 {
+		t.Errorf("connect_phases = %+v: 指定したタイムアウトとウォームアップの長さが出力されるはずです", report)
+	}
+	if report.WarmupDialErrors+report.SteadyDialErrors != lenient.errors {
+		t.Errorf("connect_phases = %+v, dial_errors = %d: フェーズごとの失敗数の合計は dial_errors と一致するはずです", report, lenient.errors)
+	}
+}
+
+// TestConnectPhasesValidation は、connect_timeout・warmup_connect_timeout・warmup_period の組み合わせの検証を確認します。
+func TestConnectPhasesValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     TestConfig
+		wantErr bool
+	}{
+		{"未指定", TestConfig{}, false},
+		{"connect_timeout のみ", TestConfig{ConnectTimeout: configDuration(time.Second)}, false},
+		{"ウォームアップあり", TestConfig{ConnectTimeout: configDuration(time.Second), WarmupConnectTimeout: configDuration(5 * time.Second), WarmupPeriod: configDuration(10 * time.Second)}, false},
+		{"connect_timeout なしのウォームアップ", TestConfig{WarmupPeriod: configDuration(10 * time.Second)}, true},
+		{"warmup_period なし", TestConfig{ConnectTimeout: configDuration(time.Second), WarmupConnectTimeout: configDuration(5 * time.Second)}, true},
+		{"定常より短いウォームアップのタイムアウト", TestConfig{ConnectTimeout: configDuration(time.Second), WarmupConnectTimeout: configDuration(500 * time.Millisecond), WarmupPeriod: configDuration(10 * time.Second)}, true},
+		{"負の値", TestConfig{ConnectTimeout: configDuration(-time.Second)}, true},
+		{"http3 との併用", TestConfig{ConnectTimeout: configDuration(time.Second), HTTP3: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateConnectPhases(&tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateConnectPhases() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// withCounterShards は、テストの間だけ counterShards を n に変更します。
+func withCounterShards(tb testing.TB, n int) {
+	tb.Helper()
+	prev := counterShards
+	counterShards = n
+	tb.Cleanup(func() { counterShards = prev })
+}
+
+// TestShardedCounterShards は、シャード数が指定値以上の最小の2のべき乗（上限は maxCounterShards）になることを確認します。
+func TestShardedCounterShards(t *testing.T) {
+	auto := 1
+	for auto < runtime.GOMAXPROCS(0) {
+		auto <<= 1
+	}
+	tests := []struct{ shards, want int }{
+		{0, auto},
+		{1, 1},
+		{3, 4},
+		{8, 8},
+		{maxCounterShards + 1, maxCounterShards},
+	}
+	for _, tt := range tests {
+		withCounterShards(t, tt.shards)
+		if got := len(newShardedCounter().shards); got != tt.want {
+			t.Errorf("counter_shards=%d: シャード数 = %d, want %d", tt.shards, got, tt.want)
+		}
+	}
+}
+
+// TestShardedCounterConcurrentAdd は、多数の Goroutine から同時に加算しても、合計が加算した総数と一致することを確認します。
+func TestShardedCounterConcurrentAdd(t *testing.T) {
+	const (
+		goroutines = 64
+		adds       = 10000
+	)
+	for _, shards := range []int{1, 0, 16} {
+		withCounterShards(t, shards)
+		c := newShardedCounter()
+		var wg sync.WaitGroup
+		for range goroutines {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range adds {
+					c.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if got := c.Load(); got != goroutines*adds {
+			t.Errorf("counter_shards=%d: Load = %d, want %d", shards, got, goroutines*adds)
+		}
+	}
+}
+
+// BenchmarkCounter は、全 Goroutine から同時に加算する場合の、シャーディングしたカウンターと単一の atomic.Uint64 の費用を比べます。
+// コア数の多いマシンで -cpu を変えて実行すると、単一のカウンターではキャッシュラインの奪い合いで遅くなる様子が分かります。
+//
+//	go test -run '^$' -bench Counter -cpu 1,4,16
+func BenchmarkCounter(b *testing.B) {
+	b.Run("atomic", func(b *testing.B) {
+		var n atomic.Uint64
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				n.Add(1)
+			}
+		})
+	})
+	for _, shards := range []int{1, 0} {
+		name := fmt.Sprintf("sharded/shards=%d", shards)
+		if shards == 0 {
+			name = "sharded/shards=auto"
+		}
+		b.Run(name, func(b *testing.B) {
+			withCounterShards(b, shards)
+			c := newShardedCounter()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					c.Add(1)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション29] 接続確立の同時実行数制限: テスト開始直後の接続の殺到を平準化
+// ==============================================================================
+
+// 5万ワーカーを一斉に起動すると、テスト開始直後に5万件の接続要求（SYN）が同時にターゲットへ届きます。
+// ターゲットの accept キュー（listen の backlog）があふれると、サーバーの処理能力とは無関係な接続エラーや
+// SYN の再送による1秒・3秒単位の遅延が t=0 付近に集中し、テスト結果を歪めます。
+// dial_concurrency を指定すると、全ワーカー（isolated_clients の場合も含む）で共有するセマフォにより、
+// 同時に進行する接続確立（TCPの3ウェイハンドシェイク）の数をその値までに制限します。
+// ランプアップ（リクエストの送信開始を遅らせる）とは独立に、接続の立ち上がりだけをなだらかにできます。
+// TLSハンドシェイクは接続確立後に Transport が行うため、制限の対象外です。
+//
+// 効果を確認できるよう、制限の有無にかかわらず接続確立の失敗数と、そのうちテスト開始直後
+// （dialOpeningWindow 以内）に発生した件数をレポートに記録します。
+
+// dialOpeningWindow は、接続確立の失敗を「テスト開始直後」として数える期間です。
+const dialOpeningWindow = 5 * time.Second
+
+// dialGate は、1回のテストの全ダイヤラーで共有する、接続確立の同時実行数の制限と失敗数の集計です。
+type dialGate struct {
+	sem   chan struct{} // 容量が同時に接続確立できる数です（nil の場合は制限しません）
+	start time.Time     // テストの開始時刻（dialOpeningWindow の起点）
+
+	errors        uint64 // 接続確立に失敗した数（アトミックに更新）
+	openingErrors uint64 // そのうち、テスト開始から dialOpeningWindow 以内に発生した数（アトミックに更新）
+
+	// phases は、connect_timeout の指定時のフェーズごとの接続タイムアウトです（nil の場合は上限を設けません。connectphase.go を参照）
+	phases       *connectPhases
+	warmupErrors uint64 // 接続確立に失敗した数のうち、ウォームアップのフェーズに確立を始めた数（アトミックに更新）
+}
+
+// newDialGate は、同時に concurrency 件まで接続確立を許す dialGate を生成します（0以下の場合は制限しません）。
+func newDialGate(concurrency int) *dialGate {
+	g := &dialGate{start: time.Now()}
+	if concurrency > 0 {
+		g.sem = make(chan struct{}, concurrency)
+	}
+	return g
+}
+
+// dial は、セマフォを獲得してから dial を呼び出し、失敗した場合は件数を記録します。
+// セマフォの空きを待っている間に ctx（タイムアウトやテスト終了）が終了した場合は、ctx のエラーを返します。
+// connect_timeout が指定されている場合、dial に渡すコンテキストには、確立を始めた時点のフェーズの接続タイムアウトを設定します。
+func (g *dialGate) dial(ctx context.Context, dial func(context.Context) (net.Conn, error)) (net.Conn, error) {
+	if g == nil {
+		return dial(ctx)
+	}
+
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-g.sem }()
+	}
+
+	elapsed := time.Since(g.start)
+	dialCtx := ctx
+	if timeout := g.phases.timeout(elapsed); timeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	conn, err := dial(dialCtx)
+	// テスト終了による中断は、ターゲットの問題ではないため数えません。名前解決の失敗は dns_errors として別に数えます
+	// （接続タイムアウトによる失敗は、dialCtx だけが終了しているため数えます）
+	if err != nil && ctx.Err() == nil && !isDNSError(err) {
+		atomic.AddUint64(&g.errors, 1)
+		if elapsed <= dialOpeningWindow {
+			atomic.AddUint64(&g.openingErrors, 1)
+		}
+		if g.phases.inWarmup(elapsed) {
+			atomic.AddUint64(&g.warmupErrors, 1)
+		}
+	}
+	return conn, err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestDialGateSmoothsOpeningBurst は、accept キューに同時に backlog 件までしか収まらない（超えた接続要求は失敗する）
+// 受け付けの遅いターゲットを模したダイヤル関数に、多数のワーカーが一斉に接続すると、制限なしでは開始直後の接続エラーが
+// 多発し、dial_concurrency をキューの大きさ以下にすると発生しないことを確認します。
+func TestDialGateSmoothsOpeningBurst(t *testing.T) {
+	const workers, backlog = 50, 4
+	errQueueFull := errors.New("accept キューがあふれました")
+
+	run := func(concurrency int) *dialGate {
+		gate := newDialGate(concurrency)
+		var queued atomic.Int64
+		slowAccept := func(ctx context.Context) (net.Conn, error) {
+			defer queued.Add(-1)
+			if queued.Add(1) > backlog {
+				return nil, errQueueFull
+			}
+			time.Sleep(5 * time.Millisecond) // ターゲットが1件ずつ accept するまでの時間
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		}
+
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if conn, err := gate.dial(context.Background(), slowAccept); err == nil {
+					conn.Close()
+				} else if !errors.Is(err, errQueueFull) {
+					t.Errorf("想定外のエラー: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		return gate
+	}
+
+	unlimited := run(0)
+	if unlimited.errors == 0 || unlimited.openingErrors != unlimited.errors {
+		t.Errorf("制限なし: errors=%d opening=%d: 開始直後の接続エラーが記録されるはずです", unlimited.errors, unlimited.openingErrors)
+	}
+	if limited := run(backlog); limited.errors != 0 {
+		t.Errorf("dial_concurrency=%d: errors=%d, want 0（同時に確立する接続がキューに収まるはずです）", backlog, limited.errors)
+	}
+}
+
+// TestDialGateReport は、接続を拒否するターゲットへの接続確立の失敗が dial_errors・dial_errors_opening に記録され、
+// 指定した dial_concurrency がレポートに出力されることを確認します。
+func TestDialGateReport(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":       "http://" + addr,
+		"concurrency":      4,
+		"duration":         "200ms",
+		"dial_concurrency": 2,
+		"no_preflight":     true,
+	}))
+	if report.DialConcurrency != 2 {
+		t.Errorf("dial_concurrency = %d, want 2", report.DialConcurrency)
+	}
+	// テスト終了の直前に失敗した接続確立は、リクエストとしてはテスト終了による中断になるため、ワーカー数の分まで多くなり得ます
+	if report.DialErrors == 0 || report.DialErrorsOpening != report.DialErrors || report.DialErrors > uint64(report.Errors)+4 {
+		t.Errorf("dial_errors=%d dial_errors_opening=%d errors=%d: 開始直後の接続拒否が数えられるはずです",
+			report.DialErrors, report.DialErrorsOpening, report.Errors)
+	}
+}
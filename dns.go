@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション38] 名前解決: 独自のDNSサーバーの指定と解決時間の計測
+// ==============================================================================
+
+// スプリットホライズンDNS（社内と社外で異なるアドレスを返す構成）のターゲットや、特定のリゾルバーの挙動を検証したい場合、
+// OSのリゾルバー（/etc/resolv.conf や nscd などのキャッシュ）を経由すると、どのサーバーが何を返したのかが分からなくなります。
+// dns_server に "8.8.8.8:53" のようにDNSサーバーを指定すると、OSのリゾルバーを経由せず、Goのリゾルバーから
+// そのサーバーへ直接問い合わせます（ポートを省略した場合は53番）。
+//
+// 新規接続ごとの名前解決はダイヤラー自身が行い、解決にかかった時間と失敗数を計測してレポートに記録します
+// （dns_server を指定しない場合もOSのリゾルバーでの解決時間を計測します。ターゲットがIPアドレスの場合は解決しません）。
+// 解決に失敗したリクエストは、エラー種別 "dns" として接続の失敗と区別して記録します。
+// 解決したアドレスが複数ある場合は、接続できるまで先頭から順に試します（spread_ips の指定時は、ラウンドロビンで選んだアドレスから順に試します）。
+
+// errKindDNS は、名前解決に失敗したリクエストのエラー種別です。
+const errKindDNS = "dns"
+
+// normalizeDNSServer は、dns_server の値を検証し、ポートが省略されている場合は53番を補います。
+// リゾルバー自体の名前解決が必要にならないよう、IPアドレスのみを受け付けます。
+func normalizeDNSServer(server string) (string, error) {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		host, port = server, "53"
+	}
+	if _, err := netip.ParseAddr(host); err != nil {
+		return "", fmt.Errorf("dns_server には IPアドレス（またはIPアドレス:ポート）を指定してください: %q", server)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// newDNSResolver は、server へ直接問い合わせるリゾルバーを返します。server が空の場合はOSの設定に従う既定のリゾルバーです。
+func newDNSResolver(server string) *net.Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// dnsStats は、テスト中の名前解決の回数・所要時間・失敗数です（全ダイヤラーで共有し、アトミックに更新します）。
+type dnsStats struct {
+	lookups  uint64
+	failures uint64
+	nanos    int64
+}
+
+// record は、1回の名前解決の結果を記録します（nil の dnsStats は何もしません）。
+func (s *dnsStats) record(d time.Duration, err error) {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.lookups, 1)
+	atomic.AddInt64(&s.nanos, int64(d))
+	if err != nil {
+		atomic.AddUint64(&s.failures, 1)
+	}
+}
+
+// avgMs は、名前解決1回あたりの平均所要時間（ミリ秒）を返します（解決していない場合は0）。
+func (s *dnsStats) avgMs() float64 {
+	lookups := atomic.LoadUint64(&s.lookups)
+	if lookups == 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&s.nanos)) / float64(lookups) / float64(time.Millisecond)
+}
+
+// dialResolved は、address のホスト名を自身のリゾルバーで解決し、得られたアドレスへ順に接続を試みます。
+// ホストがIPアドレスの場合は、解決せずにそのまま接続します。
+func (d *tunedDialer) dialResolved(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+
+	var addrs []netip.Addr
+	if d.spread != nil {
+		// spread_ips の指定時は、最初に解決した全アドレスへ新規接続をラウンドロビンで割り当てます（spreadips.go を参照）
+		addrs, err = d.spread.resolve(host, func() ([]netip.Addr, error) { return d.lookupHost(ctx, network, host) })
+		addrs = d.spread.order(addrs)
+	} else {
+		addrs, err = d.lookupHost(ctx, network, host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addr.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// isDNSError は、err が名前解決の失敗によるものかどうかを返します。
+func isDNSError(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeDNSServer は、名前ごとに決めた IPv4 アドレスを A レコードとして返す、UDP のみの最小限のDNSサーバーです。
+// 登録されていない名前には NXDOMAIN を、A 以外の問い合わせ（AAAA など）には回答なしを返します。
+type fakeDNSServer struct {
+	addr    string
+	queries atomic.Int64 // 受信した問い合わせの数
+}
+
+// newFakeDNSServer は、records（末尾の "." を除いた名前 → IPv4 アドレスの一覧）を返す fakeDNSServer を起動します。
+func newFakeDNSServer(t *testing.T, records map[string][]string) *fakeDNSServer {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	s := &fakeDNSServer{addr: conn.LocalAddr().String()}
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			s.queries.Add(1)
+			if resp := s.answer(buf[:n], records); resp != nil {
+				conn.WriteTo(resp, from)
+			}
+		}
+	}()
+	return s
+}
+
+// answer は、1件の問い合わせに対する応答を組み立てます（解釈できない問い合わせには nil を返します）。
+func (s *fakeDNSServer) answer(query []byte, records map[string][]string) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+	// 質問セクションの名前（ラベルの列）を読み、その後ろのタイプとクラスまでを応答にそのまま含めます
+	var labels []string
+	i := 12
+	for i < len(query) && query[i] != 0 {
+		l := int(query[i])
+		if i+1+l > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[i+1:i+1+l]))
+		i += 1 + l
+	}
+	if i+5 > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[i+1:])
+	question := query[12 : i+5]
+
+	addrs, found := records[strings.ToLower(strings.Join(labels, "."))]
+	resp := binary.BigEndian.AppendUint16(nil, binary.BigEndian.Uint16(query)) // ID
+	flags := uint16(0x8180)                                                    // 応答・再帰要求・再帰可能
+	if !found {
+		flags |= 3 // NXDOMAIN
+	}
+	if qtype != 1 {
+		addrs = nil
+	}
+	resp = binary.BigEndian.AppendUint16(resp, flags)
+	resp = binary.BigEndian.AppendUint16(resp, 1)
+	resp = binary.BigEndian.AppendUint16(resp, uint16(len(addrs)))
+	resp = binary.BigEndian.AppendUint16(resp, 0)
+	resp = binary.BigEndian.AppendUint16(resp, 0)
+	resp = append(resp, question...)
+	for _, a := range addrs {
+		ip := netip.MustParseAddr(a).As4()
+		resp = append(resp, 0xc0, 12)                // 質問セクションの名前への圧縮ポインタ
+		resp = append(resp, 0, 1, 0, 1, 0, 0, 0, 60) // A・IN・TTL 60秒
+		resp = append(resp, 0, 4)
+		resp = append(resp, ip[:]...)
+	}
+	return resp
+}
+
+// TestDNSServer は、dns_server に指定したDNSサーバーへ名前解決の問い合わせが送られ、その回答のアドレスへ接続することと、
+// 解決できない名前のリクエストが dns エラーとして接続の失敗と区別して記録されることを、ローカルの偽のDNSサーバーで確認します。
+func TestDNSServer(t *testing.T) {
+	dns := newFakeDNSServer(t, map[string][]string{"api.ultraload.test": {"127.0.0.1"}})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":  "http://api.ultraload.test:" + port,
+		"concurrency": 2,
+		"duration":    "200ms",
+		"dns_server":  dns.addr,
+	}))
+	if report.TotalRequests == 0 || report.Errors != 0 {
+		t.Fatalf("total=%d errors=%d error_kinds=%v: 偽のDNSサーバーの回答で接続できるはずです", report.TotalRequests, report.Errors, report.ErrorKinds)
+	}
+	if dns.queries.Load() == 0 || report.DNSLookups == 0 || report.DNSErrors != 0 || report.DNSServer != dns.addr {
+		t.Errorf("問い合わせ %d 件, dns_server=%q dns_lookups=%d dns_errors=%d: 指定したDNSサーバーで解決するはずです",
+			dns.queries.Load(), report.DNSServer, report.DNSLookups, report.DNSErrors)
+	}
+
+	missing := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":   "http://missing.ultraload.test:" + port,
+		"concurrency":  1,
+		"duration":     "100ms",
+		"dns_server":   dns.addr,
+		"no_preflight": true,
+	}))
+	if missing.Errors == 0 || missing.ErrorKinds[errKindDNS] != uint64(missing.Errors) || missing.DNSErrors == 0 || missing.DialErrors != 0 {
+		t.Errorf("errors=%d error_kinds=%v dns_errors=%d dial_errors=%d: 解決できない名前は dns エラーとして記録するはずです",
+			missing.Errors, missing.ErrorKinds, missing.DNSErrors, missing.DialErrors)
+	}
+}
+
+// TestNormalizeDNSServer は、dns_server のポートの補完と、IPアドレス以外の拒否を確認します。
+func TestNormalizeDNSServer(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"8.8.8.8", "8.8.8.8:53"},
+		{"127.0.0.1:5353", "127.0.0.1:5353"},
+		{"::1", "[::1]:53"},
+		{"[2001:db8::1]:5353", "[2001:db8::1]:5353"},
+	}
+	for _, tt := range tests {
+		if got, err := normalizeDNSServer(tt.in); err != nil || got != tt.want {
+			t.Errorf("normalizeDNSServer(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	for _, invalid := range []string{"dns.google", "dns.google:53", ""} {
+		if _, err := normalizeDNSServer(invalid); err == nil {
+			t.Errorf("normalizeDNSServer(%q) がエラーになりません", invalid)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+	"net/http"
+)
+
+// ==============================================================================
+// [セクション40] レスポンスボディの読み捨てと後始末 (drainAndClose)
+// ==============================================================================
+
+// HTTP/1.1 では、ボディを最後まで読まずに閉じると、その接続はプールへ返却されずに切断されます。
+// HTTP/2 では1本の接続を多数のストリームで共有するため、読まれずに残ったデータがフロー制御のウィンドウを
+// 占有したままになると、同じ接続上の他のストリームまで送信を待たされることがあります。
+// JSONアサーションのようにボディの一部だけを読む機能を追加しても、残りを読み捨ててから閉じる処理が漏れないよう、
+// レスポンスを処理し終えた箇所はすべて drainAndClose を経由させます（no_drain_body を指定した場合のみ、意図的に読まずに閉じます）。
+//
+// limit を指定した場合は、それを超える部分は読まずに閉じます。HTTP/2 ではそのストリームだけが RST_STREAM で打ち切られ、
+// 受信済みの未読データの分のウィンドウは接続へ返却されるため、接続全体が止まることはありません。
+
+// drainAll は、drainAndClose でボディの残りをすべて読み捨てる場合に limit に指定する値です。
+const drainAll = -1
+
+// drainAndClose は、resp のボディの残りを最大 limit バイト（drainAll の場合は無制限）まで読み捨ててから閉じ、
+// 読み捨てたバイト数を返します。
+func drainAndClose(resp *http.Response, limit int64) int64 {
+	var body io.Reader = resp.Body
+	if limit >= 0 {
+		body = io.LimitReader(resp.Body, limit)
+	}
+	n, _ := io.Copy(io.Discard, body)
+	resp.Body.Close()
+	return n
+}
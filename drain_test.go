@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestDrainAndCloseHTTP2 は、HTTP/2 のターゲットに対し、ボディの先頭だけを読んでから drainAndClose で閉じるリクエストを
+// ストリームの同時数の上限（250）を超える件数だけ繰り返しても、ストリームやフロー制御のウィンドウが枯渇して止まることなく、
+// すべて1本の接続の上で完了することを確認します。残りをすべて読み捨てる場合と、limit で打ち切る場合の両方を確認します。
+func TestDrainAndCloseHTTP2(t *testing.T) {
+	const bodySize, requests = 128 << 10, 300
+	body := bytes.Repeat([]byte("x"), bodySize)
+
+	var mu sync.Mutex
+	conns := make(map[net.Conn]bool)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	server.EnableHTTP2 = true
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns[c] = true
+			mu.Unlock()
+		}
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name      string
+		limit     int64
+		wantDrain int64 // 1件あたりに読み捨てるバイト数
+	}{
+		{"残りをすべて読み捨てる", drainAll, bodySize - 1024},
+		{"limit で打ち切る", 4 << 10, 4 << 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := server.Client()
+			t.Cleanup(client.CloseIdleConnections)
+			mu.Lock()
+			clear(conns)
+			mu.Unlock()
+
+			for i := 0; i < requests; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+				resp, err := client.Do(req)
+				if err != nil {
+					cancel()
+					t.Fatalf("%d 件目: %v", i+1, err)
+				}
+				if resp.ProtoMajor != 2 {
+					cancel()
+					t.Fatalf("%s で応答しました, want HTTP/2", resp.Proto)
+				}
+				// JSONアサーションのように、ボディの先頭だけを読んでから後始末を drainAndClose に任せます
+				io.ReadFull(resp.Body, make([]byte, 1024))
+				n := drainAndClose(resp, tt.limit)
+				cancel()
+				if n != tt.wantDrain {
+					t.Fatalf("%d 件目: 読み捨てたバイト数 = %d, want %d", i+1, n, tt.wantDrain)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(conns) != 1 {
+				t.Errorf("接続数 = %d, want 1（ストリームが解放されず、新しい接続を開いています）", len(conns))
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ==============================================================================
+// [セクション27] 実行時間の指定: 整数秒と時間文字列（"500ms" など）の両対応
+// ==============================================================================
+
+// 実行時間（duration）は従来、整数の秒数でしか指定できず、500ミリ秒だけのバーストテストのような
+// 1秒未満のテストを実行できませんでした。"duration": "500ms" や "1m30s" のように time.ParseDuration の形式の
+// 文字列でも指定できるようにします。後方互換性のため、"duration": 10 のような数値は従来どおり秒数として解釈します
+// （"duration": 0.5 のような小数の秒数も受け付けます）。
+
+// errInvalidDuration は、duration を実行時間として解釈できなかったことを示します。
+var errInvalidDuration = errors.New("duration の形式が正しくありません")
+
+// configDuration は、TestConfig の実行時間です。JSONでは秒数（数値）と時間文字列のどちらでも指定できます。
+type configDuration time.Duration
+
+// UnmarshalJSON は、数値を秒数として、文字列を time.ParseDuration の形式として解釈します。
+func (d *configDuration) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%w（\"500ms\" や \"1m30s\" の形式、または秒数で指定してください）: %q", errInvalidDuration, s)
+		}
+		*d = configDuration(parsed)
+		return nil
+	}
+
+	sec, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("%w（秒数、または \"500ms\" のような文字列で指定してください）: %s", errInvalidDuration, data)
+	}
+	// 極端に大きな秒数で time.Duration があふれないよう、表現できる範囲に収まるかを確認します
+	if sec > time.Duration(1<<63-1).Seconds() || sec < -time.Duration(1<<63-1).Seconds() {
+		return fmt.Errorf("%w（指定できる範囲を超えています）: %s", errInvalidDuration, data)
+	}
+	*d = configDuration(sec * float64(time.Second))
+	return nil
+}
+
+// MarshalJSON は、実行時間を "1m30s" のような時間文字列として出力します（UnmarshalJSON でそのまま読み戻せます）。
+func (d configDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Seconds は、実行時間を秒数で返します。
+func (d configDuration) Seconds() float64 {
+	return time.Duration(d).Seconds()
+}
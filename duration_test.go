@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestConfigDurationUnmarshal は、duration を時間文字列と（整数・小数の）秒数のどちらでも解釈でき、
+// 解釈できない値や範囲外の値を errInvalidDuration として拒否することを確認します。
+func TestConfigDurationUnmarshal(t *testing.T) {
+	tests := []struct {
+		json string
+		want time.Duration
+	}{
+		{`"500ms"`, 500 * time.Millisecond},
+		{`"1m30s"`, 90 * time.Second},
+		{`10`, 10 * time.Second},
+		{`0.5`, 500 * time.Millisecond},
+		{`0`, 0},
+	}
+	for _, tt := range tests {
+		var d configDuration
+		if err := json.Unmarshal([]byte(tt.json), &d); err != nil || time.Duration(d) != tt.want {
+			t.Errorf("duration %s = %v, %v, want %v", tt.json, time.Duration(d), err, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{`"10"`, `"soon"`, `true`, `1e300`} {
+		var d configDuration
+		if err := json.Unmarshal([]byte(invalid), &d); !errors.Is(err, errInvalidDuration) {
+			t.Errorf("duration %s: err = %v, want errInvalidDuration", invalid, err)
+		}
+	}
+
+	// 出力した時間文字列は、そのまま読み戻せます
+	out, err := json.Marshal(configDuration(1500 * time.Millisecond))
+	if err != nil || string(out) != `"1.5s"` {
+		t.Fatalf("MarshalJSON = %s, %v, want \"1.5s\"", out, err)
+	}
+	var back configDuration
+	if err := json.Unmarshal(out, &back); err != nil || time.Duration(back) != 1500*time.Millisecond {
+		t.Errorf("読み戻した duration = %v, %v", time.Duration(back), err)
+	}
+}
+
+// TestSubSecondDuration は、"duration": "500ms" のテストが約0.5秒で終了し、整数の秒数のテストは従来どおり
+// その秒数だけ実行されることを確認します。
+func TestSubSecondDuration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	for _, tt := range []struct {
+		duration any
+		want     float64
+	}{
+		{"500ms", 0.5},
+		{1, 1},
+	} {
+		report := runTestLoad(newTestConfig(t, map[string]any{"target_url": server.URL, "concurrency": 1, "duration": tt.duration}))
+		if report.TotalRequests == 0 || math.Abs(report.ActualDurationSec-tt.want) > 0.25 {
+			t.Errorf("duration %v: total=%d actual_duration_sec=%.2f, want 約 %.1f 秒", tt.duration, report.TotalRequests, report.ActualDurationSec, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ==============================================================================
+// [セクション65] エラーログの集約: 同じエラーの重複を省略して件数を定期的に出力する (-error-log-interval)
+// ==============================================================================
+
+// ターゲットが停止している場合などは、テスト中のすべてのリクエストが同じ理由で失敗するため、エラーを1件ずつログへ出力すると
+// 数百万行のログで端末やログファイルが埋まり、ログの書き込み自体がテスターの送信を遅くしてしまいます。
+// テストごとのエラーログ（errorLog）は、エラーのメッセージごとに初回だけをそのまま出力し、2回目以降は件数だけを数えます。
+// 数えた件数は -error-log-interval（既定は10秒）ごとと、テストの終了時に「同じエラーがさらに N 件発生しました」の形で
+// まとめて出力するため、同じエラーが1万件発生しても、ログの行数は「初回の1行 + 間隔ごとの1行」に収まります。
+//
+// メッセージの種類は errorLogMaxKeys までしか区別しません（接続元のポート番号を含むエラーなど、メッセージが1件ごとに
+// 異なる場合に、結局すべてを出力してしまわないためです）。上限を超えた新しい種類のエラーは「その他」として件数だけを数えます。
+// -error-log-interval に0を指定すると集約を無効にし、すべてのエラーをそのまま出力します。
+//
+// 集約の対象は、ワーカーの初期化の失敗（[Worker Error] など）と、通信に失敗したリクエスト（[Request Error]）です。
+// エラーの件数そのものは、これまでどおりレポートの errors・error_kinds で正確に集計します。
+
+// defaultErrorLogInterval は、-error-log-interval を指定しない場合に、省略したエラーの件数を出力する間隔です。
+const defaultErrorLogInterval = 10 * time.Second
+
+// errorLogInterval は、省略したエラーの件数を出力する間隔です（-error-log-interval フラグ。0の場合は集約しません）。
+var errorLogInterval = defaultErrorLogInterval
+
+// errorLogMaxKeys は、1つのテストで区別するエラーのメッセージの種類の上限です。
+const errorLogMaxKeys = 100
+
+// errorLog は、同じメッセージのエラーの出力を初回だけに絞り、省略した件数を定期的に出力します。
+// 複数の Goroutine から同時に使用できます。nil の errorLog は、すべてのエラーをそのまま出力します。
+type errorLog struct {
+	interval time.Duration
+
+	mu         sync.Mutex
+	suppressed map[string]uint64 // メッセージ（タグを含む）ごとの、前回の出力以降に省略した件数
+	others     uint64            // 種類の上限を超えたため、メッセージを区別せずに数えた件数
+	lastFlush  time.Time
+}
+
+// newErrorLog は、-error-log-interval の間隔で件数を出力する errorLog を生成します（集約しない設定の場合は nil）。
+func newErrorLog() *errorLog {
+	if errorLogInterval <= 0 {
+		return nil
+	}
+	return &errorLog{interval: errorLogInterval, suppressed: make(map[string]uint64), lastFlush: time.Now()}
+}
+
+// printf は、tag（"[Worker Error]" など）を付けたエラーを出力します。
+// 同じメッセージを既に出力している場合は件数だけを数え、前回の件数の出力から interval が経過していればまとめて出力します。
+func (l *errorLog) printf(tag, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if l == nil {
+		log.Printf("%s %s\n", tag, msg)
+		return
+	}
+	key := tag + " " + msg
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if count, seen := l.suppressed[key]; seen {
+		l.suppressed[key] = count + 1
+	} else if len(l.suppressed) < errorLogMaxKeys {
+		l.suppressed[key] = 0
+		log.Printf("%s\n", key)
+	} else {
+		l.others++
+	}
+	if time.Since(l.lastFlush) >= l.interval {
+		l.flushLocked()
+	}
+}
+
+// flush は、省略したエラーの件数を出力します（テストの終了時に呼び出します）。
+func (l *errorLog) flush() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.flushLocked()
+}
+
+// flushLocked は、省略した件数のあるメッセージごとに1行を出力し、件数を0に戻します。l.mu を保持して呼び出します。
+// 出力済みのメッセージは覚えておくため、以降も同じエラーは件数だけを数えます。
+func (l *errorLog) flushLocked() {
+	for key, count := range l.suppressed {
+		if count > 0 {
+			log.Printf("%s (同じエラーがさらに %d 件発生しました)\n", key, count)
+			l.suppressed[key] = 0
+		}
+	}
+	if l.others > 0 {
+		log.Printf("[Error Log] エラーの種類が多いため、その他のエラー %d 件の出力を省略しました\n", l.others)
+		l.others = 0
+	}
+	l.lastFlush = time.Now()
+}
+
+// requestErrorMessage は、通信に失敗したリクエストのエラーを、集約のキーに使うメッセージにします。
+// *url.Error のメッセージには URL が含まれ、キャッシュバスティングなどでリクエストごとに異なるため、メソッドと原因だけにします。
+func requestErrorMessage(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Sprintf("%s: %v", urlErr.Op, urlErr.Err)
+	}
+	return err.Error()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer は、複数の Goroutine から書き込まれるログを受け取るバッファです。
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// lines は、pattern を含むログの行を返します。
+func (b *syncBuffer) lines(pattern string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var matched []string
+	for _, line := range strings.Split(b.buf.String(), "\n") {
+		if strings.Contains(line, pattern) {
+			matched = append(matched, line)
+		}
+	}
+	return matched
+}
+
+// captureLog は、テストの間だけ標準のロガーの出力を横取りします。
+func captureLog(t *testing.T) *syncBuffer {
+	t.Helper()
+	buf := &syncBuffer{}
+	saved := log.Writer()
+	log.SetOutput(buf)
+	t.Cleanup(func() { log.SetOutput(saved) })
+	return buf
+}
+
+// TestErrorLogDeduplicates は、同じエラーを1万件出力しても、ログは初回の1行と、間隔ごと・終了時の件数の行だけに収まり、
+// 件数の行の合計が省略した件数と一致することを確認します。
+func TestErrorLogDeduplicates(t *testing.T) {
+	buf := captureLog(t)
+	l := &errorLog{interval: 20 * time.Millisecond, suppressed: make(map[string]uint64), lastFlush: time.Now()}
+	start := time.Now()
+	for i := range 10000 {
+		l.printf("[Request Error]", "dial tcp: connection refused")
+		// 約 100ms にわたって出力し、間隔ごとの件数の出力を数回発生させます
+		if i%1000 == 999 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	l.flush()
+	elapsed := time.Since(start)
+
+	lines := buf.lines("[Request Error] dial tcp: connection refused")
+	// 初回の1行と、間隔（20ms）ごとの件数の行、終了時の1行です
+	if maxLines := 2 + int(elapsed/l.interval); len(lines) < 2 || len(lines) > maxLines {
+		t.Errorf("ログの行数 = %d, want 2〜%d（%v の間に出力）", len(lines), maxLines, elapsed)
+	}
+	var total uint64
+	for _, line := range lines[1:] {
+		var n uint64
+		if _, err := fmt.Sscanf(line[strings.Index(line, "さらに "):], "さらに %d 件", &n); err != nil {
+			t.Fatalf("件数の行を解釈できません: %q", line)
+		}
+		total += n
+	}
+	if total != 9999 {
+		t.Errorf("省略した件数の合計 = %d, want 9999", total)
+	}
+}
+
+// TestErrorLogMaxKeys は、メッセージの種類が上限を超えると、新しい種類のエラーを「その他」として件数だけ出力することと、
+// 集約しない設定（nil の errorLog）ではすべてのエラーを出力することを確認します。
+func TestErrorLogMaxKeys(t *testing.T) {
+	buf := captureLog(t)
+	l := &errorLog{interval: time.Hour, suppressed: make(map[string]uint64), lastFlush: time.Now()}
+	for i := range errorLogMaxKeys + 50 {
+		l.printf("[Request Error]", "read tcp 127.0.0.1:%d: connection reset", 10000+i)
+	}
+	l.flush()
+	if got := len(buf.lines("[Request Error] read tcp")); got != errorLogMaxKeys {
+		t.Errorf("ログの行数 = %d, want %d", got, errorLogMaxKeys)
+	}
+	if others := buf.lines("その他のエラー 50 件"); len(others) != 1 {
+		t.Errorf("その他のエラーの行 = %q, want 1 行", others)
+	}
+
+	var none *errorLog
+	for range 5 {
+		none.printf("[Worker Error]", "same")
+	}
+	if got := len(buf.lines("[Worker Error] same")); got != 5 {
+		t.Errorf("集約しない場合のログの行数 = %d, want 5", got)
+	}
+}
+
+// TestErrorLogDuringLoad は、接続できないターゲットへのテストで大量の通信エラーが発生しても、
+// [Request Error] のログは数行に収まり、エラーの件数自体は正確に集計されることを確認します。
+func TestErrorLogDuringLoad(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	buf := captureLog(t)
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":   "http://" + addr,
+		"concurrency":  4,
+		"duration":     "300ms",
+		"no_preflight": true,
+	}))
+	if report.Errors < 1000 {
+		t.Fatalf("errors = %d: 大量の通信エラーが発生するはずです", report.Errors)
+	}
+	if lines := buf.lines("[Request Error]"); len(lines) > 5 {
+		t.Errorf("[Request Error] のログが %d 行出力されました（errors=%d）: 同じエラーは集約するはずです", len(lines), report.Errors)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// [セクション75] 必要なリソースの見積もり: テストを実行せずに fd・メモリ・帯域を見積もる (-estimate)
+// ==============================================================================
+
+// 並行数や実行時間を大きくしたテストは、負荷をかけ始めてから「too many open files」やメモリ不足で落ちることがあり、
+// それまでの時間と計測結果が無駄になります。-estimate にテストの設定JSON（/api/run に送るものと同じ）を指定すると、
+// テストを実行せずに次の値を見積もって標準出力へ表示し、このマシンの制限を超えそうな項目を警告して終了します。
+//
+//   ファイルディスクリプタ : 開く接続の数（ホストの数 × 1ホストあたりの接続数）に、サーバー自身が使う分を加えた数
+//                          （RLIMIT_NOFILE のソフトリミットと比較します）
+//   レイテンシの保持メモリ : 推定総リクエスト数 × サンプル1件の大きさ（全体のレイテンシと TTFB、trace 指定時は読み終わりまでの時間）
+//                          スライスの拡張中は拡張前と拡張後が同時に存在するため、その2倍を最大値とします（/proc/meminfo の MemAvailable と比較します）
+//   送信の帯域             : 想定レート × 1リクエストの大きさ（リクエスト行・ヘッダーの概算とボディ）
+//   エフェメラルポート     : 1つの送信先に張る接続の数（/proc/sys/net/ipv4/ip_local_port_range の範囲と比較します）
+//
+// 想定レートは、rate_limit を指定した場合はその値（traffic_shape の場合は最大の目標レートも）、指定しない場合は
+// メモリの事前割り当てと同じく 並行数 × expected_rps_per_worker（既定は100）です。forever のテストは1時間あたりで見積もります。
+// 受信の帯域はレスポンスの大きさが分からないため見積もりません。設定は readTestConfig で API と同じ既定値と検証を適用してから見積もります。
+// 制限を超えそうな項目がある場合は終了コード1、ない場合は0で終了します（設定の誤りは2です）。
+
+// estimateServerFDs は、接続以外にテスター自身が開くファイルディスクリプタ（待ち受けソケット、標準入出力、ログ、トレースなど）の見込みです。
+const estimateServerFDs = 64
+
+// estimateHeaderBytes は、リクエスト行の URL 以外の部分とヘッダー（Host・User-Agent・Accept-Encoding など）の大きさの概算です。
+const estimateHeaderBytes = 160
+
+// estimateForeverDuration は、forever のテストを見積もる時間です。
+const estimateForeverDuration = time.Hour
+
+// resourceEstimate は、1つのテスト設定に必要なリソースの見積もりです。
+type resourceEstimate struct {
+	duration      time.Duration
+	rps           float64 // 平均の想定レート（リクエスト/秒）
+	peakRPS       float64 // 最大の想定レート（traffic_shape の場合。それ以外は rps と同じ）
+	totalRequests float64
+	samples       float64 // メモリに保持するレイテンシのサンプル数（max_samples で頭打ち）
+	sampleBytes   float64 // サンプル1件あたりのバイト数（TTFB などを含む）
+	latencyBytes  float64
+	peakBytes     float64
+	hosts         int
+	connsPerHost  int
+	fds           int
+	requestBytes  float64 // 1リクエストの送信バイト数の概算
+	uploadBps     float64 // 平均の送信帯域（バイト/秒）
+	peakUploadBps float64
+}
+
+// estimateResources は、検証済みの cfg から必要なリソースを見積もります。
+func estimateResources(cfg *TestConfig) resourceEstimate {
+	e := resourceEstimate{duration: time.Duration(cfg.Duration)}
+	if cfg.Forever {
+		e.duration = estimateForeverDuration
+	}
+
+	e.rps = float64(cfg.Concurrency) * float64(cfg.ExpectedRPSPerWorker)
+	if cfg.ExpectedRPSPerWorker <= 0 {
+		e.rps = float64(cfg.Concurrency) * defaultExpectedRPSPerWorker
+	}
+	if cfg.RateLimit > 0 {
+		e.rps = cfg.RateLimit
+	}
+	e.peakRPS = e.rps
+	switch cfg.TrafficShape {
+	case shapeSine:
+		e.peakRPS = cfg.RateLimit * (1 + cfg.ShapeAmplitude)
+	case shapeBurst:
+		e.peakRPS = cfg.RateLimit * cfg.ShapeBurstFactor
+		// バーストの間はレートが上がるため、平均のレートも基準のレートより高くなります
+		burstRatio := time.Duration(cfg.ShapeBurstLength).Seconds() / time.Duration(cfg.ShapePeriod).Seconds()
+		e.rps = cfg.RateLimit * (1 + (cfg.ShapeBurstFactor-1)*burstRatio)
+	}
+	e.totalRequests = e.rps * e.duration.Seconds()
+
+	// 全体のレイテンシは max_samples で頭打ちになりますが、TTFB（と trace 指定時の読み終わりまでの時間）は全件を保持します
+	const durationBytes = 8 // time.Duration 1件の大きさ
+	e.samples = e.totalRequests
+	if cfg.MaxSamples > 0 {
+		e.samples = min(e.samples, float64(cfg.MaxSamples))
+	}
+	perRequest := float64(durationBytes)
+	if cfg.Trace {
+		perRequest += durationBytes
+	}
+	e.latencyBytes = e.samples*durationBytes + e.totalRequests*perRequest
+	e.sampleBytes = e.latencyBytes / max(e.totalRequests, 1)
+	e.peakBytes = e.latencyBytes * 2
+
+	e.hosts, e.connsPerHost = estimateConnections(cfg)
+	e.fds = e.hosts*e.connsPerHost + estimateServerFDs
+
+	e.requestBytes = estimateRequestBytes(cfg)
+	e.uploadBps = e.rps * e.requestBytes
+	e.peakUploadBps = e.peakRPS * e.requestBytes
+	return e
+}
+
+// estimateConnections は、送信先のホストの数と、1ホストあたりに開く接続の数の見込みを返します。
+// HTTP/1.1 の各ワーカー（または通信中のリクエスト）は同時に1本の接続しか使わないため、プールの大きさ（connections の指定時はその値）までとします。
+func estimateConnections(cfg *TestConfig) (hosts, perHost int) {
+	poolSize := cfg.Concurrency
+	if cfg.Adaptive {
+		poolSize = newAdaptiveParams(cfg).maxConcurrency
+	}
+	if cfg.LoadModel == loadModelOpen {
+		poolSize = cfg.MaxInFlight
+	}
+	perHost = poolSize
+	if cfg.Connections > 0 {
+		perHost = min(perHost, cfg.Connections)
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range cfg.Targets {
+		if u, err := url.Parse(t.URL); err == nil {
+			seen[poolHost(u)] = true
+		}
+	}
+	hosts = max(len(seen), 1)
+	return hosts, perHost
+}
+
+// estimateRequestBytes は、1リクエストの送信バイト数（リクエスト行・ヘッダーの概算とボディ）を返します。
+func estimateRequestBytes(cfg *TestConfig) float64 {
+	n := float64(len(cfg.Method) + len(cfg.TargetURL) + estimateHeaderBytes)
+	if req, err := newBaseRequest(context.Background(), cfg.Method, cfg.TargetURL, cfg); err == nil && req.ContentLength > 0 {
+		n += float64(req.ContentLength)
+	}
+	return n
+}
+
+// systemLimits は、見積もりと比較するこのマシンの制限です（取得できない項目は0）。
+type systemLimits struct {
+	fds            uint64 // RLIMIT_NOFILE のソフトリミット
+	memAvailable   uint64 // /proc/meminfo の MemAvailable（バイト）
+	ephemeralPorts int    // ip_local_port_range の範囲に含まれるポートの数
+}
+
+// readSystemLimits は、このマシンの制限を読み込みます。対応していない OS の項目は0のままです。
+func readSystemLimits() systemLimits {
+	var l systemLimits
+	l.fds, _ = fdLimit()
+	if f, err := os.Open("/proc/meminfo"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			// 例: "MemAvailable:   12345678 kB"
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+				if kb, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+					l.memAvailable = kb << 10
+				}
+			}
+		}
+	}
+	if data, err := os.ReadFile("/proc/sys/net/ipv4/ip_local_port_range"); err == nil {
+		if fields := strings.Fields(string(data)); len(fields) == 2 {
+			low, errLow := strconv.Atoi(fields[0])
+			high, errHigh := strconv.Atoi(fields[1])
+			if errLow == nil && errHigh == nil && high >= low {
+				l.ephemeralPorts = high - low + 1
+			}
+		}
+	}
+	return l
+}
+
+// warnings は、見積もりが limits を超える項目の警告を返します。
+func (e resourceEstimate) warnings(limits systemLimits) []string {
+	var warnings []string
+	if limits.fds > 0 && uint64(e.fds) > limits.fds {
+		warnings = append(warnings, fmt.Sprintf("ファイルディスクリプタの見込み (%d) が上限 (ulimit -n: %d) を超えます。ulimit -n を引き上げるか、connections で接続数を減らしてください",
+			e.fds, limits.fds))
+	}
+	if limits.memAvailable > 0 && e.peakBytes > float64(limits.memAvailable) {
+		warnings = append(warnings, fmt.Sprintf("レイテンシの保持に最大 %s が必要ですが、利用可能なメモリは %s です。max_samples でサンプル数を抑えるか、実行時間を短くしてください",
+			formatEstimateBytes(e.peakBytes), formatEstimateBytes(float64(limits.memAvailable))))
+	}
+	if limits.ephemeralPorts > 0 && e.connsPerHost > limits.ephemeralPorts {
+		warnings = append(warnings, fmt.Sprintf("1つの送信先への接続の見込み (%d) が、エフェメラルポートの範囲 (%d 個) を超えます。connections で接続数を減らすか、ip_local_port_range を広げてください",
+			e.connsPerHost, limits.ephemeralPorts))
+	}
+	return warnings
+}
+
+// formatEstimateBytes は、バイト数を 1KB = 1024 バイトの単位付きの文字列にします。
+func formatEstimateBytes(n float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f%s", n, units[i])
+	}
+	return fmt.Sprintf("%.2f%s", n, units[i])
+}
+
+// writeEstimate は、見積もりと、比較したこのマシンの制限を w へ書き出します。
+func writeEstimate(w io.Writer, cfg *TestConfig, e resourceEstimate, limits systemLimits, warnings []string) {
+	limitText := func(v uint64, format func(uint64) string) string {
+		if v == 0 {
+			return "不明"
+		}
+		return format(v)
+	}
+	durationNote := ""
+	if cfg.Forever {
+		durationNote = "（forever のため1時間あたり）"
+	}
+
+	fmt.Fprintf(w, "見積もり: %s, 並行数: %d, 実行時間: %s%s\n", cfg.TargetURL, cfg.Concurrency, e.duration, durationNote)
+	fmt.Fprintf(w, "  想定レート            : %.0f リクエスト/秒 (最大 %.0f)\n", e.rps, e.peakRPS)
+	fmt.Fprintf(w, "  推定総リクエスト数    : %.0f 件\n", e.totalRequests)
+	fmt.Fprintf(w, "  ファイルディスクリプタ: %d (%d ホスト × %d 接続 + %d)  上限: %s\n", e.fds, e.hosts, e.connsPerHost, estimateServerFDs,
+		limitText(limits.fds, func(v uint64) string { return strconv.FormatUint(v, 10) }))
+	fmt.Fprintf(w, "  レイテンシの保持メモリ: %s (最大 %s, %.0f 件のサンプル, 1リクエストあたり %.0f バイト)  利用可能: %s\n",
+		formatEstimateBytes(e.latencyBytes), formatEstimateBytes(e.peakBytes), e.samples, e.sampleBytes,
+		limitText(limits.memAvailable, func(v uint64) string { return formatEstimateBytes(float64(v)) }))
+	fmt.Fprintf(w, "  送信の帯域            : %s/秒 (最大 %s/秒, 1リクエストあたり %s)\n",
+		formatEstimateBytes(e.uploadBps), formatEstimateBytes(e.peakUploadBps), formatEstimateBytes(e.requestBytes))
+	if limits.ephemeralPorts > 0 {
+		fmt.Fprintf(w, "  エフェメラルポート    : 1送信先あたり %d 接続  範囲: %d 個\n", e.connsPerHost, limits.ephemeralPorts)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "[Estimate Warning] %s\n", warning)
+	}
+	if len(warnings) == 0 {
+		fmt.Fprintln(w, "このマシンの制限を超える項目はありません。")
+	}
+}
+
+// runEstimateCommand は、-estimate の処理本体です。path の設定JSON（"-" の場合は標準入力）からリソースを見積もって表示し、終了コードを返します。
+func runEstimateCommand(path string) int {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[Estimate Error] 設定ファイルを読み込めません: %v\n", err)
+		return 2
+	}
+
+	// API経由のテストと同じ既定値と検証を適用するため、設定は readTestConfig で組み立てます
+	rec := httptest.NewRecorder()
+	cfg, ok := readTestConfig(rec, httptest.NewRequest(http.MethodPost, "/api/run", strings.NewReader(string(data))))
+	if !ok {
+		var failed TestReport
+		json.Unmarshal(rec.Body.Bytes(), &failed)
+		fmt.Fprintf(os.Stderr, "[Estimate Error] %s\n", failed.ErrorMsg)
+		return 2
+	}
+
+	e := estimateResources(cfg)
+	limits := readSystemLimits()
+	warnings := e.warnings(limits)
+	writeEstimate(os.Stdout, cfg, e, limits, warnings)
+	if len(warnings) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// fdLimit は、RLIMIT_NOFILE のない非対応プラットフォームでは上限を取得できないため、false を返します。
+func fdLimit() (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestEstimateHugeConfig は、並行数 50000 で 24 時間のテストの見積もりが、ファイルディスクリプタ・レイテンシの保持メモリ・
+// エフェメラルポートのすべてでこのマシンの制限（ここでは固定の値）を超えると警告され、小さなテストでは警告されないことを確認します。
+func TestEstimateHugeConfig(t *testing.T) {
+	limits := systemLimits{fds: 1024, memAvailable: 8 << 30, ephemeralPorts: 28232}
+
+	huge, rec := postConfig(t, `{"target_url":"http://127.0.0.1:1/","concurrency":50000,"duration":"24h"}`, false)
+	if huge == nil {
+		t.Fatalf("設定が受け付けられません: %s", rec.Body.String())
+	}
+	e := estimateResources(huge)
+	if want := 50000 * defaultExpectedRPSPerWorker * 86400.0; e.totalRequests != want {
+		t.Errorf("推定総リクエスト数 = %.0f, want %.0f（並行数 × expected_rps_per_worker × 実行時間のはずです）", e.totalRequests, want)
+	}
+	if want := 50000 + estimateServerFDs; e.fds != want {
+		t.Errorf("ファイルディスクリプタ = %d, want %d", e.fds, want)
+	}
+	if e.peakBytes != e.latencyBytes*2 {
+		t.Errorf("最大のメモリ = %.0f, want %.0f（スライスの拡張中の2倍のはずです）", e.peakBytes, e.latencyBytes*2)
+	}
+
+	warnings := e.warnings(limits)
+	for _, concern := range []string{"ファイルディスクリプタ", "レイテンシの保持", "エフェメラルポート"} {
+		found := false
+		for _, w := range warnings {
+			found = found || strings.Contains(w, concern)
+		}
+		if !found {
+			t.Errorf("warnings = %q: %s の警告が含まれるはずです", warnings, concern)
+		}
+	}
+	var out bytes.Buffer
+	writeEstimate(&out, huge, e, limits, warnings)
+	if got := strings.Count(out.String(), "[Estimate Warning]"); got != len(warnings) {
+		t.Errorf("出力の警告 = %d 件, want %d 件\n%s", got, len(warnings), out.String())
+	}
+
+	small, rec := postConfig(t, `{"target_url":"http://127.0.0.1:1/","concurrency":10,"duration":"10s"}`, false)
+	if small == nil {
+		t.Fatalf("設定が受け付けられません: %s", rec.Body.String())
+	}
+	if warnings := estimateResources(small).warnings(limits); len(warnings) != 0 {
+		t.Errorf("小さなテストの warnings = %q, want なし", warnings)
+	}
+}
+
+// TestEstimateMaxSamples は、max_samples を指定すると全体のレイテンシのサンプル数がその値で頭打ちになり、
+// 保持メモリの見積もりが小さくなることを確認します（TTFB は全件を保持するため、その分は減りません）。
+func TestEstimateMaxSamples(t *testing.T) {
+	unbounded, _ := postConfig(t, `{"target_url":"http://127.0.0.1:1/","concurrency":100,"duration":"1h"}`, false)
+	bounded, _ := postConfig(t, `{"target_url":"http://127.0.0.1:1/","concurrency":100,"duration":"1h","max_samples":1000}`, false)
+	if unbounded == nil || bounded == nil {
+		t.Fatal("設定が受け付けられません")
+	}
+	u, b := estimateResources(unbounded), estimateResources(bounded)
+	if b.samples != 1000 {
+		t.Errorf("samples = %.0f, want 1000", b.samples)
+	}
+	if want := 1000*8 + b.totalRequests*8; b.latencyBytes != want || b.latencyBytes >= u.latencyBytes {
+		t.Errorf("latency_bytes = %.0f (max_samples なし: %.0f), want %.0f", b.latencyBytes, u.latencyBytes, want)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// fdLimit は、このプロセスが開けるファイルディスクリプタの数の上限（RLIMIT_NOFILE のソフトリミット）を返します。
+func fdLimit() (uint64, bool) {
+	var rlim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlim); err != nil {
+		return 0, false
+	}
+	return uint64(rlim.Cur), true
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+)
+
+// ==============================================================================
+// [セクション17] 結果のバイナリエクスポートとインポート (-import)
+// ==============================================================================
+
+// 数百万件規模のテストでは、レイテンシの生サンプルをJSONやCSVで保存すると巨大になります。
+// export_path を指定すると、テスト終了後にレポートと全サンプルをコンパクトなバイナリ形式で書き出し、
+// -import で読み込み直して、テストを再実行せずにレポートを再生成・統合できます。
+// -merge と異なり生サンプルを保持しているため、複数ファイルを統合してもパーセンタイルは正確な値になります。
+//
+// 【フォーマット (ULS1)】 数値はすべて符号なし可変長整数（encoding/binary の uvarint）です。
+//   1. マジックナンバー  : ASCII の "ULS1"（4バイト）
+//   2. レポート長        : uvarint
+//   3. レポート          : 上記の長さのJSON（/api/run のレポートと同じ形式）
+//   4. サンプル数        : uvarint
+//   5. サンプル          : 昇順に並べたレイテンシ（ナノ秒）の、直前の値との差分 × サンプル数
+// 昇順に並べて差分を取ることで大半の値が1〜3バイトに収まり、1サンプルあたり8バイトの固定長と比べて大幅に小さくなります。
+
+// exportMagic は、エクスポートファイルの先頭に書き込まれるマジックナンバーです。
+const exportMagic = "ULS1"
+
+// exportMaxReportBytes は、読み込むレポートJSONの上限サイズです（壊れたファイルで巨大な確保をしないため）。
+const exportMaxReportBytes = 64 << 20
+
+// writeExport は、レポートとレイテンシのサンプルをエクスポートファイルへ書き出します。
+// samples は昇順に並べ替えられます。
+func writeExport(path string, report *TestReport, samples []time.Duration) error {
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	buf := make([]byte, binary.MaxVarintLen64)
+	writeUvarint := func(v uint64) {
+		n := binary.PutUvarint(buf, v)
+		w.Write(buf[:n])
+	}
+
+	w.WriteString(exportMagic)
+	writeUvarint(uint64(len(reportJSON)))
+	w.Write(reportJSON)
+
+	slices.Sort(samples)
+	writeUvarint(uint64(len(samples)))
+	var prev time.Duration
+	for _, d := range samples {
+		writeUvarint(uint64(d - prev))
+		prev = d
+	}
+
+	// bufio.Writer は最初に発生したエラーを保持しているため、最後にまとめて確認します
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// readExport は、エクスポートファイルからレポートとレイテンシのサンプル（昇順）を読み込みます。
+func readExport(path string) (*TestReport, []time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	magic := make([]byte, len(exportMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != exportMagic {
+		return nil, nil, errors.New("エクスポートファイルの形式ではありません（マジックナンバーが一致しません）")
+	}
+
+	reportLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("レポート長の読み込みに失敗しました: %w", err)
+	}
+	if reportLen > exportMaxReportBytes {
+		return nil, nil, fmt.Errorf("レポートが大きすぎます (%d バイト)", reportLen)
+	}
+	reportJSON := make([]byte, reportLen)
+	if _, err := io.ReadFull(r, reportJSON); err != nil {
+		return nil, nil, fmt.Errorf("レポートの読み込みに失敗しました: %w", err)
+	}
+	var report TestReport
+	if err := json.Unmarshal(reportJSON, &report); err != nil {
+		return nil, nil, fmt.Errorf("レポートのJSONが正しくありません: %w", err)
+	}
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("サンプル数の読み込みに失敗しました: %w", err)
+	}
+	// 壊れたファイルで巨大なスライスを確保しないよう、初期容量はレポートの件数までにとどめます
+	capacity := count
+	if max := uint64(report.LatencySamples); capacity > max {
+		capacity = max
+	}
+	samples := make([]time.Duration, 0, capacity)
+	var prev time.Duration
+	for i := uint64(0); i < count; i++ {
+		delta, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%d 件目のサンプルの読み込みに失敗しました: %w", i+1, err)
+		}
+		prev += time.Duration(delta)
+		samples = append(samples, prev)
+	}
+	return &report, samples, nil
+}
+
+// importReports は、エクスポートファイルを読み込んでレポートを再生成します。
+// 複数のファイルを指定した場合は -merge と同様に統合し、パーセンタイルは全サンプルから正確に算出し直します。
+func importReports(paths []string) (*TestReport, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("読み込むエクスポートファイルが指定されていません")
+	}
+	var reports []*TestReport
+	var allSamples []time.Duration
+	sampled := false
+	for _, path := range paths {
+		report, samples, err := readExport(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s の読み込みに失敗しました: %w", path, err)
+		}
+		reports = append(reports, report)
+		allSamples = append(allSamples, samples...)
+		sampled = sampled || report.SamplingEngaged
+	}
+
+	result := reports[0]
+	if len(reports) > 1 {
+		result = mergeReports(reports)
+	}
+
+	values := latencyDurations(allSamples, reportedPercentiles(result)...)
+	summary := values.summary(len(allSamples))
+	result.LatencySamples = summary.Samples
+	result.P50Latency, result.P90Latency, result.P99Latency = summary.P50, summary.P90, summary.P99
+	result.PercentilesApproximate = false
+	if sampled {
+		// リザーバーサンプリングが作動していた場合、最小値・平均値・標準偏差・最大値は全件から算出された
+		// 元のレポート（統合時はその統合結果）の値を維持します。
+		// 観測数の異なるリザーバーを単純に結合するため、複数ファイルの統合時はパーセンタイルも近似値になります。
+		result.PercentilesApproximate = len(reports) > 1
+		if base := result.latencyValues(); values != nil && base != nil {
+			values.min, values.mean, values.max = base.min, base.mean, base.max
+			values.stdDev, values.hasStdDev = base.stdDev, base.hasStdDev
+		} else {
+			values = nil
+		}
+	} else {
+		result.MinLatency, result.MeanLatency, result.MaxLatency = summary.Min, summary.Mean, summary.Max
+		result.StdDevLatency = summary.StdDev
+	}
+	result.latency = values
+	// summarizeLatencies で各パーセンタイルの位置は確定済みのため、記録されていたパーセンタイルを全サンプルから算出し直します
+	result.TailPercentiles, result.PercentileWarnings = tailPercentiles(allSamples, reportedPercentiles(result))
+	if target, err := time.ParseDuration(result.ApdexTarget); err == nil && target > 0 && len(allSamples) > 0 {
+		score := apdexScore(allSamples, target)
+		result.Apdex = &score
+	}
+	return result, nil
+}
+
+// runImportCommand は -import サブコマンドのエントリーポイントです。
+// 再生成したレポートをJSONとして標準出力へ書き出し、プロセスの終了コードを返します。
+func runImportCommand(paths []string) int {
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "[Import Error] 読み込むエクスポートファイルを指定してください (例: -import run1.uls run2.uls)")
+		return 2
+	}
+
+	report, err := importReports(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[Import Error] %v\n", err)
+		return 1
+	}
+
+	if err := writeReportOutput(report, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "[Import Error] レポートの出力に失敗しました: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestExportRoundTrip は、書き出したサンプルが読み込み後も1件残らず同じ値（昇順）で戻ることを確認します。
+func TestExportRoundTrip(t *testing.T) {
+	samples := []time.Duration{
+		3 * time.Millisecond, 1, 0, 250 * time.Microsecond, 3 * time.Millisecond, 2 * time.Second, 999 * time.Nanosecond,
+	}
+	report := &TestReport{TotalRequests: len(samples), Success: len(samples), LatencySamples: len(samples)}
+	path := filepath.Join(t.TempDir(), "run.uls")
+
+	if err := writeExport(path, report, slices.Clone(samples)); err != nil {
+		t.Fatalf("writeExport: %v", err)
+	}
+	gotReport, gotSamples, err := readExport(path)
+	if err != nil {
+		t.Fatalf("readExport: %v", err)
+	}
+
+	want := slices.Clone(samples)
+	slices.Sort(want)
+	if !slices.Equal(gotSamples, want) {
+		t.Errorf("samples = %v, want %v", gotSamples, want)
+	}
+	if gotReport.TotalRequests != report.TotalRequests || gotReport.LatencySamples != report.LatencySamples {
+		t.Errorf("report = %+v, want %+v", gotReport, report)
+	}
+}
+
+// TestExportImportReport は、実際のテストの結果を export_path へ書き出して -import で読み込み直すと、
+// 元のテストと同じレポートが再生成されることを確認します。
+func TestExportImportReport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "run.uls")
+	cfg := newTestConfig(t, map[string]any{
+		"target_url":   server.URL,
+		"concurrency":  2,
+		"duration":     (200 * time.Millisecond).String(),
+		"export_path":  path,
+		"apdex_target": "1ms",
+	})
+	report := runTestLoad(cfg)
+	if report.ExportError != "" || report.ExportedTo != path {
+		t.Fatalf("書き出しに失敗しました: exported_to=%q export_error=%q", report.ExportedTo, report.ExportError)
+	}
+
+	imported, err := importReports([]string{path})
+	if err != nil {
+		t.Fatalf("importReports: %v", err)
+	}
+
+	// exported_to は書き出した後に設定されるため、書き出したレポートには含まれません
+	original := *report
+	original.ExportedTo = ""
+	want, _ := json.Marshal(&original)
+	got, _ := json.Marshal(imported)
+	if string(got) != string(want) {
+		t.Errorf("再生成したレポートが元のレポートと一致しません\n got: %s\nwant: %s", got, want)
+	}
+}
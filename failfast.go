@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ==============================================================================
+// [セクション45] 最初のエラーで即座に停止する (fail_fast)
+// ==============================================================================
+
+// デプロイ直後のスモークテストやCIでは、指定した時間いっぱい負荷をかけるよりも、1件でも失敗したら
+// すぐに止めて、何が起きたのかを詳しく知りたい場合があります。fail_fast を指定すると、最初にエラー
+// （通信エラー、4xx・5xx、JSONアサーションの失敗など、errors に数えられるもの）になったリクエストを記録した時点で
+// テスト全体を停止し、そのリクエストのメソッド・URL・ステータスコード・エラー内容をレポートの fail_fast に記録します。
+// 停止の時点で通信中だった他のリクエストは記録しません。
+
+// FailFastError は、fail_fast でテストを停止させた最初のエラーの詳細です。
+type FailFastError struct {
+	Method     string  `json:"method"`
+	URL        string  `json:"url"`
+	StatusCode int     `json:"status_code,omitempty"` // 応答を受信できなかった場合は省略
+	ErrorKind  string  `json:"error_kind,omitempty"`  // エラー種別（error_kinds のキー）を特定できた場合のみ
+	Message    string  `json:"message"`
+	AtSec      float64 `json:"at_sec"` // テスト開始からエラーが記録されるまでの時間（秒）
+}
+
+// failFast は、最初のエラーを1件だけ記録してテストを停止させます。nil の failFast は何もしません。
+type failFast struct {
+	once   sync.Once
+	cancel context.CancelFunc
+	start  time.Time
+
+	mu    sync.Mutex
+	first *FailFastError
+}
+
+// newFailFast は、最初のエラーで cancel を呼び出す failFast を生成します。enabled が false の場合は nil を返します。
+func newFailFast(enabled bool, cancel context.CancelFunc, start time.Time) *failFast {
+	if !enabled {
+		return nil
+	}
+	return &failFast{cancel: cancel, start: start}
+}
+
+// failedStatus は、このステータスコードの応答がエラーとして記録されるかどうかを返します（recordResponse と同じ基準です）。
+func failedStatus(cfg *TestConfig, statusCode int) bool {
+	if cfg.RedirectsAreErrors && statusCode >= 300 && statusCode < 400 {
+		return true
+	}
+	return statusCode < 200 || statusCode >= 400
+}
+
+// checkResponse は、応答を受信したリクエストがエラーとして記録された場合にテストを停止させます。
+// kind は、レスポンスサイズの超過などで失敗として記録した場合のエラー種別です（それ以外は空文字列）。
+func (f *failFast) checkResponse(cfg *TestConfig, req *http.Request, statusCode int, kind string) {
+	if f == nil || (kind == "" && !failedStatus(cfg, statusCode)) {
+		return
+	}
+	message := fmt.Sprintf("HTTP %d %s", statusCode, http.StatusText(statusCode))
+	if kind != "" {
+		message += "（" + kind + "）"
+	}
+	f.trigger(&FailFastError{Method: req.Method, URL: req.URL.String(), StatusCode: statusCode, ErrorKind: kind, Message: message})
+}
+
+// checkNetworkError は、応答を受信できなかったリクエストでテストを停止させます。
+func (f *failFast) checkNetworkError(req *http.Request, err error) {
+	if f == nil {
+		return
+	}
+	f.trigger(&FailFastError{Method: req.Method, URL: req.URL.String(), ErrorKind: classifyNetworkError(err), Message: err.Error()})
+}
+
+// trigger は、最初の1件だけを記録してテストを停止させます。
+func (f *failFast) trigger(e *FailFastError) {
+	f.once.Do(func() {
+		e.AtSec = time.Since(f.start).Seconds()
+		f.mu.Lock()
+		f.first = e
+		f.mu.Unlock()
+		log.Printf("[FailFast] 最初のエラーでテストを停止します: %s %s: %s\n", e.Method, e.URL, e.Message)
+		f.cancel()
+	})
+}
+
+// result は、テストを停止させたエラーを返します（エラーが発生しなかった場合は nil）。
+func (f *failFast) result() *FailFastError {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.first
+}
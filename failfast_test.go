@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestFailFast は、fail_fast を指定すると、10秒のテストでも1件だけエラーを返すターゲットでは直後に停止し、
+// そのリクエストのメソッド・URL・ステータスコードがレポートの fail_fast に記録されることを確認します。
+func TestFailFast(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 5件目だけが失敗します
+		if requests.Add(1) == 5 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	start := time.Now()
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":   server.URL + "/smoke?check=1",
+		"concurrency":  1,
+		"duration":     "10s",
+		"fail_fast":    true,
+		"no_preflight": true,
+	}))
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("テストに %v かかりました: 最初のエラーで直後に停止するはずです", elapsed)
+	}
+	if report.AbortedReason != "fail_fast" || report.Errors != 1 || report.StatusCodes["500"] != 1 {
+		t.Errorf("aborted_reason=%q errors=%d status_codes=%v", report.AbortedReason, report.Errors, report.StatusCodes)
+	}
+	ff := report.FailFast
+	if ff == nil {
+		t.Fatal("fail_fast が記録されていません")
+	}
+	if ff.Method != http.MethodGet || ff.URL != server.URL+"/smoke?check=1" || ff.StatusCode != http.StatusInternalServerError ||
+		!strings.Contains(ff.Message, "500") || ff.AtSec <= 0 || ff.AtSec > 2 {
+		t.Errorf("fail_fast = %+v", ff)
+	}
+}
+
+// TestFailFastNetworkError は、応答を受信できなかった最初のリクエストでも停止し、ステータスコードなしでエラー内容が記録されることと、
+// エラーが発生しなかった場合は最後まで実行して fail_fast を記録しないことを確認します。
+func TestFailFastNetworkError(t *testing.T) {
+	// 接続を受け付けずに閉じたポートを使います
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":   "http://" + addr,
+		"concurrency":  2,
+		"duration":     "10s",
+		"fail_fast":    true,
+		"no_preflight": true,
+	}))
+	if ff := report.FailFast; ff == nil || ff.StatusCode != 0 || ff.URL != "http://"+addr || !strings.Contains(ff.Message, "connection refused") || report.AbortedReason != "fail_fast" {
+		t.Errorf("fail_fast = %+v aborted_reason=%q: 接続エラーで停止するはずです", ff, report.AbortedReason)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	ok := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":  server.URL,
+		"concurrency": 1,
+		"duration":    "200ms",
+		"fail_fast":   true,
+	}))
+	if ok.FailFast != nil || ok.AbortedReason != "" || ok.TotalRequests == 0 {
+		t.Errorf("fail_fast = %+v aborted_reason=%q: エラーがなければ最後まで実行するはずです", ok.FailFast, ok.AbortedReason)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"slices"
+)
+
+// ==============================================================================
+// [セクション31] ワーカー間の公平性: ワーカーごとの完了リクエスト数の分布
+// ==============================================================================
+
+// 全ワーカーは同じループを回しているため、本来は完了したリクエスト数もほぼ等しくなるはずです。
+// 一部のワーカーだけが突出して多い（または少ない）場合は、特定の接続だけが速いバックエンドに振り分けられている
+// （コネクションアフィニティ）、コネクションプールの偏り、Goroutine のスケジューリングの偏りなどを示唆します。
+// HTTPのクローズドモデルでは、各ワーカーが自身の完了数をローカル変数で数え（ホットパスで共有カウンタを更新しません）、
+// 終了時にメトリクスへ登録します。レポートの worker_request_distribution は、その最小・最大・平均・標準偏差です。
+// 適応型負荷モードやランプアップでは、途中で起動・停止したワーカーの稼働時間が異なるため、分布は広がって見えます。
+
+// WorkerDistribution は、ワーカーごとの完了リクエスト数の分布です。
+type WorkerDistribution struct {
+	Workers int     `json:"workers"` // 集計したワーカー数
+	Min     uint64  `json:"min"`     // 最も少なかったワーカーの完了数
+	Max     uint64  `json:"max"`     // 最も多かったワーカーの完了数
+	Mean    float64 `json:"mean"`    // 平均完了数
+	StdDev  float64 `json:"stddev"`  // 標準偏差
+	CV      float64 `json:"cv"`      // 変動係数（標準偏差 / 平均）。0に近いほど均等で、0.1を超える場合は偏りを疑います
+}
+
+// addWorkerCount は、終了したワーカー1つ分の完了リクエスト数を登録します。
+func (rm *ResultMetrics) addWorkerCount(completed uint64) {
+	rm.mu.Lock()
+	rm.workerCounts = append(rm.workerCounts, completed)
+	rm.mu.Unlock()
+}
+
+// workerDistribution は、ワーカーごとの完了数から分布を算出します（ワーカーが0件の場合は nil）。
+func workerDistribution(counts []uint64) *WorkerDistribution {
+	if len(counts) == 0 {
+		return nil
+	}
+	var sum float64
+	for _, c := range counts {
+		sum += float64(c)
+	}
+	mean := sum / float64(len(counts))
+	var sq float64
+	for _, c := range counts {
+		d := float64(c) - mean
+		sq += d * d
+	}
+
+	dist := &WorkerDistribution{
+		Workers: len(counts),
+		Min:     slices.Min(counts),
+		Max:     slices.Max(counts),
+		Mean:    mean,
+		StdDev:  math.Sqrt(sq / float64(len(counts))),
+	}
+	if mean > 0 {
+		dist.CV = dist.StdDev / mean
+	}
+	return dist
+}
+
+// mergeWorkerDistributions は、複数マシンの分布を、全ワーカーを1つの母集団とみなして統合します。
+// 平均と標準偏差は、各マシンの平均・分散からプールして正確に算出します（ワーカーごとの値は不要です）。
+func mergeWorkerDistributions(dists []*WorkerDistribution) *WorkerDistribution {
+	var merged *WorkerDistribution
+	var sum, sumSq float64
+	for _, d := range dists {
+		if d == nil || d.Workers == 0 {
+			continue
+		}
+		if merged == nil {
+			merged = &WorkerDistribution{Min: d.Min, Max: d.Max}
+		}
+		merged.Workers += d.Workers
+		merged.Min = min(merged.Min, d.Min)
+		merged.Max = max(merged.Max, d.Max)
+		n := float64(d.Workers)
+		sum += d.Mean * n
+		sumSq += (d.StdDev*d.StdDev + d.Mean*d.Mean) * n
+	}
+	if merged == nil {
+		return nil
+	}
+
+	n := float64(merged.Workers)
+	merged.Mean = sum / n
+	merged.StdDev = math.Sqrt(max(sumSq/n-merged.Mean*merged.Mean, 0))
+	if merged.Mean > 0 {
+		merged.CV = merged.StdDev / merged.Mean
+	}
+	return merged
+}
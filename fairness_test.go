@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestWorkerDistributionEven は、一定時間で応答する均一なターゲットに対し、各ワーカーの完了数がほぼ等しく、
+// worker_request_distribution の合計が総リクエスト数と一致し、変動係数が偏りの目安（0.1）を下回ることを確認します。
+func TestWorkerDistributionEven(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	}))
+	t.Cleanup(server.Close)
+
+	const workers = 8
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":  server.URL,
+		"concurrency": workers,
+		"duration":    "1s",
+	}))
+	dist := report.WorkerDistribution
+	if dist == nil || dist.Workers != workers {
+		t.Fatalf("worker_request_distribution = %+v: %d ワーカー分の分布があるはずです", dist, workers)
+	}
+	if total := dist.Mean * workers; math.Abs(total-float64(report.TotalRequests)) > 0.5 {
+		t.Errorf("平均 %.1f × %d ワーカー = %.0f, want total_requests %d", dist.Mean, workers, total, report.TotalRequests)
+	}
+	if dist.Min == 0 || float64(dist.Min) < dist.Mean*0.8 || float64(dist.Max) > dist.Mean*1.2 || dist.CV >= 0.1 {
+		t.Errorf("worker_request_distribution = %+v: 均一なターゲットでは各ワーカーの完了数がほぼ等しくなるはずです", dist)
+	}
+}
+
+// TestWorkerDistribution は、ワーカーごとの完了数からの分布の算出と、複数マシンの分布の統合が、
+// 全ワーカーを1つの母集団として算出した場合と一致することを確認します。
+func TestWorkerDistribution(t *testing.T) {
+	if dist := workerDistribution(nil); dist != nil {
+		t.Errorf("ワーカーなし: %+v, want nil", dist)
+	}
+
+	got := workerDistribution([]uint64{2, 4, 4, 4, 5, 5, 7, 9})
+	want := &WorkerDistribution{Workers: 8, Min: 2, Max: 9, Mean: 5, StdDev: 2, CV: 0.4}
+	if *got != *want {
+		t.Errorf("workerDistribution = %+v, want %+v", got, want)
+	}
+
+	merged := mergeWorkerDistributions([]*WorkerDistribution{
+		workerDistribution([]uint64{2, 4, 4}),
+		nil,
+		workerDistribution([]uint64{4, 5, 5, 7, 9}),
+	})
+	if merged.Workers != want.Workers || merged.Min != want.Min || merged.Max != want.Max ||
+		math.Abs(merged.Mean-want.Mean) > 1e-9 || math.Abs(merged.StdDev-want.StdDev) > 1e-9 || math.Abs(merged.CV-want.CV) > 1e-9 {
+		t.Errorf("mergeWorkerDistributions = %+v, want %+v", merged, want)
+	}
+}
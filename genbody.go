@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ==============================================================================
+// [セクション66] 生成ボディ: 指定サイズのボディを生成して送信する (gen_body_size / gen_body_fill)
+// ==============================================================================
+
+// アップロードや書き込み系の API のスループットを測るには、数百KB〜数百MBのボディを送り続ける必要がありますが、
+// body に巨大な文字列を書いたり、ファイルを用意したりするのは手間がかかります。gen_body_size を指定すると、
+// 指定したサイズのボディを生成して各リクエストで送信します。中身は gen_body_fill で選びます。
+//
+//   gen_body_fill = "random"（既定）: 乱数のバイト列（圧縮が効かないため、経路上の圧縮に左右されずに帯域を測れます）
+//   gen_body_fill = "zero"           : 0x00 の繰り返し
+//   gen_body_fill = "pattern"        : gen_body_pattern の文字列の繰り返し（省略時は "ultraload"）
+//
+// gen_body_size は、バイト数（数値）か、"512KB"・"1MB"・"2GiB" のような単位付きの文字列で指定します。
+// 単位は B・KB・MB・GB（KiB・MiB・GiB も同じ意味）で、-log-max-size-mb などと同じく 1KB = 1024 バイトとして扱います。
+// Content-Type は、content_type を省略すると application/octet-stream を付与します。
+//
+// ボディの中身は、テストの開始前に最大 genBodyChunkSize のブロックとして1度だけ生成し、各リクエストのボディは
+// そのブロックを先頭から繰り返し読み出すリーダーです（送信のたびに GetBody で作り直し、ブロックのコピーは発生しません）。
+// そのため、gen_body_size が大きくてもテスターのメモリはブロック1つ分しか使わず、すべてのリクエストは同じ中身を送ります
+// （HMAC 署名の {body} も送信する中身と一致します）。random の場合、ブロックより大きいボディは同じ乱数列の繰り返しになります。
+//
+// 送信したボディのバイト数（Transport が読み出したバイト数の合計）は、レポートの bytes_uploaded に記録します。
+// 送信の途中で失敗したリクエストは、失敗までに読み出した分を含みます。プリフライトのプローブと、HMAC 署名の計算のための読み出しは含みません。
+//
+// サーバーの -gen-body-size・-gen-body-fill フラグで、ボディを指定しないテストの既定値を指定できます
+// （body・form・request_file を指定したテストと、WebSocket モードのテストには適用しません）。
+// body・form・request_file とは併用できず、HTTPモードでのみ使用できます。
+
+// 生成ボディの中身（TestConfig.GenBodyFill）
+const (
+	genBodyRandom  = "random"
+	genBodyZero    = "zero"
+	genBodyPattern = "pattern"
+)
+
+// genBodyContentType は、生成ボディを送るテストで content_type を省略した場合に付与する Content-Type です。
+const genBodyContentType = "application/octet-stream"
+
+// defaultGenBodyPattern は、gen_body_fill が "pattern" で gen_body_pattern を省略した場合に繰り返す文字列です。
+const defaultGenBodyPattern = "ultraload"
+
+// genBodyChunkSize は、生成ボディのブロックの最大サイズです。これより大きいボディは、ブロックを繰り返して送ります。
+const genBodyChunkSize = 1 << 20
+
+// 生成ボディの既定値です（-gen-body-size・-gen-body-fill フラグ）。
+var (
+	defaultGenBodySize byteSize
+	defaultGenBodyFill = genBodyRandom
+)
+
+// errInvalidByteSize は、サイズ（gen_body_size など）をバイト数として解釈できなかったことを示します。
+var errInvalidByteSize = errors.New("サイズの形式が正しくありません")
+
+// byteSize は、バイト数です。JSON やフラグでは、数値（バイト数）と単位付きの文字列（"1MB" など）のどちらでも指定できます。
+type byteSize int64
+
+// byteSizeUnits は、単位の表記と倍率の対応表です（長い表記から順に照合します）。
+var byteSizeUnits = []struct {
+	suffix string
+	scale  int64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"B", 1},
+}
+
+// parseByteSize は、"1MB" や "512KiB"、"4096" のような文字列をバイト数に変換します（単位の大文字・小文字は区別しません）。
+func parseByteSize(s string) (byteSize, error) {
+	text := strings.ToUpper(strings.TrimSpace(s))
+	scale := int64(1)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(text, unit.suffix) {
+			text, scale = strings.TrimSpace(strings.TrimSuffix(text, unit.suffix)), unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil || n < 0 || n*float64(scale) >= 1<<62 {
+		return 0, fmt.Errorf("%w（4096・\"512KB\"・\"1MB\" のように指定してください）: %q", errInvalidByteSize, s)
+	}
+	return byteSize(n * float64(scale)), nil
+}
+
+// UnmarshalJSON は、数値をバイト数として、文字列を単位付きのサイズとして解釈します。
+func (b *byteSize) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := parseByteSize(s)
+		if err != nil {
+			return err
+		}
+		*b = parsed
+		return nil
+	}
+	var n int64
+	if err := json.Unmarshal(data, &n); err != nil || n < 0 {
+		return fmt.Errorf("%w（0以上のバイト数、または \"1MB\" のような文字列で指定してください）: %s", errInvalidByteSize, data)
+	}
+	*b = byteSize(n)
+	return nil
+}
+
+// String は、フラグの既定値の表示用にバイト数を返します。
+func (b *byteSize) String() string {
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+// Set は、-gen-body-size フラグの値を解釈します（flag.Value の実装）。
+func (b *byteSize) Set(s string) error {
+	parsed, err := parseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = parsed
+	return nil
+}
+
+// genBody は、生成ボディのブロックと、送信したバイト数の集計です。
+type genBody struct {
+	size     int64
+	chunk    []byte         // 繰り返して送るブロック（テスト中は変更しないため、複数のリーダーで共有します）
+	random   bool           // ブロックが乱数で埋められている場合は true（テストごとにシード値から作り直します）
+	uploaded *atomic.Uint64 // リーダーが読み出したバイト数の合計（nil の場合は数えません）
+}
+
+// resolveGenBody は、生成ボディの設定にサーバーの既定値を補って検証し、gen_body_size が指定されている場合は
+// ブロックを生成して cfg.genBody を設定します。resolveBody の後に呼び出します。
+func resolveGenBody(cfg *TestConfig) error {
+	explicit := cfg.GenBodySize > 0
+	if !explicit && defaultGenBodySize > 0 && cfg.Mode == modeHTTP && cfg.Body == "" && cfg.RequestFile == "" {
+		cfg.GenBodySize = defaultGenBodySize
+	}
+	if cfg.GenBodySize == 0 {
+		if cfg.GenBodyFill != "" || cfg.GenBodyPattern != "" {
+			return fmt.Errorf("gen_body_fill・gen_body_pattern を指定する場合は gen_body_size も指定してください")
+		}
+		return nil
+	}
+	if explicit && (cfg.Body != "" || cfg.Mode != modeHTTP || cfg.RequestFile != "") {
+		return fmt.Errorf("gen_body_size は HTTPモードでのみ使用でき、body・form・request_file とは併用できません")
+	}
+	if cfg.GenBodyFill == "" {
+		cfg.GenBodyFill = defaultGenBodyFill
+	}
+	if cfg.GenBodyFill != genBodyPattern && cfg.GenBodyPattern != "" {
+		return fmt.Errorf("gen_body_pattern は gen_body_fill が \"pattern\" の場合にのみ指定できます")
+	}
+
+	chunkSize := min(int64(cfg.GenBodySize), genBodyChunkSize)
+	var chunk []byte
+	switch cfg.GenBodyFill {
+	case genBodyRandom:
+		// プリフライトのプローブ用のブロックです。テストで送るブロックは forRun がシード値から作り直します
+		chunk = randomGenBodyChunk(chunkSize, rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())))
+	case genBodyZero:
+		chunk = make([]byte, chunkSize)
+	case genBodyPattern:
+		if cfg.GenBodyPattern == "" {
+			cfg.GenBodyPattern = defaultGenBodyPattern
+		}
+		if int64(len(cfg.GenBodyPattern)) > genBodyChunkSize {
+			return fmt.Errorf("gen_body_pattern は %d バイト以下にしてください", genBodyChunkSize)
+		}
+		// ブロックの長さをパターンの長さの倍数にして、ブロックの継ぎ目でもパターンが途切れないようにします
+		chunk = bytes.Repeat([]byte(cfg.GenBodyPattern), int(max(1, chunkSize/int64(len(cfg.GenBodyPattern)))))
+	default:
+		return fmt.Errorf("未対応の gen_body_fill です: %q (random・zero・pattern のいずれかを指定してください)", cfg.GenBodyFill)
+	}
+
+	if cfg.ContentType == "" {
+		cfg.ContentType = genBodyContentType
+	}
+	cfg.genBody = &genBody{size: int64(cfg.GenBodySize), chunk: chunk, random: cfg.GenBodyFill == genBodyRandom}
+	return nil
+}
+
+// newGenBodyRand は、random のブロックを埋める乱数生成器を、シード値から導出して返します。
+// ワーカーやサンプリングの乱数生成器と系列が重ならないよう、専用の系列番号を使います。
+func newGenBodyRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, math.MaxUint64-2))
+}
+
+// randomGenBodyChunk は、rng から引いた乱数で埋めた size バイトのブロックを返します。
+func randomGenBodyChunk(size int64, rng *rand.Rand) []byte {
+	chunk := make([]byte, (size+7)&^7)
+	for i := 0; i < len(chunk); i += 8 {
+		binary.LittleEndian.PutUint64(chunk[i:], rng.Uint64())
+	}
+	return chunk[:size]
+}
+
+// forRun は、送信したバイト数を新しく数え直す genBody を返します（テストの開始時に呼び出します）。
+// random のブロックは rng から作り直すため、同じシード値のテストでは同じ中身のボディを送ります。
+// それ以外のブロックは共有します。nil の genBody に対しては nil を返します。
+func (g *genBody) forRun(rng *rand.Rand) *genBody {
+	if g == nil {
+		return nil
+	}
+	chunk := g.chunk
+	if g.random {
+		chunk = randomGenBodyChunk(int64(len(g.chunk)), rng)
+	}
+	return &genBody{size: g.size, chunk: chunk, random: g.random, uploaded: new(atomic.Uint64)}
+}
+
+// uploadedBytes は、送信したボディのバイト数の合計を返します。
+func (g *genBody) uploadedBytes() uint64 {
+	if g == nil || g.uploaded == nil {
+		return 0
+	}
+	return g.uploaded.Load()
+}
+
+// newReader は、1リクエスト分のボディのリーダーを返します（http.Request.GetBody として使います）。
+func (g *genBody) newReader() (io.ReadCloser, error) {
+	return &genBodyReader{chunk: g.chunk, remaining: g.size, uploaded: g.uploaded}, nil
+}
+
+// genBodyReader は、ブロックを先頭から繰り返して size バイトを読み出します。
+type genBodyReader struct {
+	chunk     []byte
+	remaining int64
+	offset    int            // ブロック内の次に読み出す位置
+	uploaded  *atomic.Uint64 // 読み出したバイト数を加算する集計（nil の場合は数えません）
+}
+
+// Read は、ブロックの続きを p へコピーします。
+func (r *genBodyReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n := 0
+	for n < len(p) {
+		copied := copy(p[n:], r.chunk[r.offset:])
+		n += copied
+		r.offset = (r.offset + copied) % len(r.chunk)
+	}
+	r.remaining -= int64(n)
+	if r.uploaded != nil {
+		r.uploaded.Add(uint64(n))
+	}
+	return n, nil
+}
+
+// Close は何もしません（ブロックは共有しているため、解放しません）。
+func (r *genBodyReader) Close() error {
+	return nil
+}
+
+// genBodyCurlData は、buildCurlCommand 用に、同じサイズ・中身のボディを生成する curl の --data-binary の値を返します
+// （bash などのプロセス置換を使います。random の中身は同じ乱数列ではありません）。
+func genBodyCurlData(cfg *TestConfig) string {
+	var source string
+	switch cfg.GenBodyFill {
+	case genBodyZero:
+		source = "head -c " + strconv.FormatInt(int64(cfg.GenBodySize), 10) + " /dev/zero"
+	case genBodyPattern:
+		source = "yes -- " + shellQuote(cfg.GenBodyPattern) + " | tr -d '\\n' | head -c " + strconv.FormatInt(int64(cfg.GenBodySize), 10)
+	default:
+		source = "head -c " + strconv.FormatInt(int64(cfg.GenBodySize), 10) + " /dev/urandom"
+	}
+	return "@<(" + source + ")"
+}
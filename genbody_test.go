@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestGenBodyReceived は、gen_body_size と gen_body_fill を指定すると、ターゲットが各リクエストで指定したバイト数の
+// 指定した中身のボディを受信し（ブロックより大きいボディでは継ぎ目でも中身が途切れず）、その合計が bytes_uploaded と一致することを確認します。
+func TestGenBodyReceived(t *testing.T) {
+	const size = 3 << 19 // 1.5MB（ブロックの 1MB を超えるサイズ）
+	tests := []struct {
+		fill, pattern string
+		check         func(body []byte) error
+	}{
+		{genBodyZero, "", func(body []byte) error {
+			if i := bytes.IndexFunc(body, func(r rune) bool { return r != 0 }); i >= 0 {
+				return fmt.Errorf("%d バイト目が 0x00 ではありません", i)
+			}
+			return nil
+		}},
+		{genBodyPattern, "abc", func(body []byte) error {
+			if want := bytes.Repeat([]byte("abc"), size/3+1)[:size]; !bytes.Equal(body, want) {
+				return fmt.Errorf("\"abc\" の繰り返しではありません（先頭: %q）", body[:min(len(body), 12)])
+			}
+			return nil
+		}},
+		{genBodyRandom, "", func(body []byte) error {
+			if bytes.Count(body[:4096], []byte{0}) > 64 {
+				return fmt.Errorf("乱数のバイト列に見えません（先頭: %x）", body[:16])
+			}
+			// ブロック（1MB）より後ろは、同じ乱数列の繰り返しです
+			if !bytes.Equal(body[genBodyChunkSize:], body[:size-genBodyChunkSize]) {
+				return fmt.Errorf("ブロックより後ろが先頭の繰り返しになっていません")
+			}
+			return nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fill, func(t *testing.T) {
+			var mu sync.Mutex
+			var received uint64
+			var first []byte
+			var problems []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				defer mu.Unlock()
+				received += uint64(len(body))
+				switch {
+				case len(body) != size:
+					problems = append(problems, fmt.Sprintf("ボディのサイズ = %d, want %d", len(body), size))
+				case r.Header.Get("Content-Type") != genBodyContentType:
+					problems = append(problems, "Content-Type = "+r.Header.Get("Content-Type"))
+				case first == nil:
+					first = body
+					if err := tt.check(body); err != nil {
+						problems = append(problems, err.Error())
+					}
+				case !bytes.Equal(body, first):
+					// 同じテストのリクエストは、すべて同じ中身のボディを送ります
+					problems = append(problems, "リクエストごとにボディの中身が異なります")
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			config := map[string]any{
+				"target_url":    server.URL,
+				"method":        http.MethodPost,
+				"concurrency":   2,
+				"duration":      "300ms",
+				"gen_body_size": "1.5MB",
+				"gen_body_fill": tt.fill,
+				"no_preflight":  true,
+			}
+			if tt.pattern != "" {
+				config["gen_body_pattern"] = tt.pattern
+			}
+			report := runTestLoad(newTestConfig(t, config))
+			if report.TotalRequests == 0 || report.Errors != 0 {
+				t.Fatalf("total=%d errors=%d", report.TotalRequests, report.Errors)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(problems) > 0 {
+				t.Errorf("受信したボディの問題: %q", problems[:min(len(problems), 3)])
+			}
+			// テスト終了で中断した送信中のリクエストの分だけ、記録されたリクエスト数を上回ることがあります
+			if report.BytesUploaded < uint64(report.TotalRequests)*size || report.BytesUploaded < received {
+				t.Errorf("bytes_uploaded = %d, total=%d × %d = %d, ターゲットが受信した合計 = %d",
+					report.BytesUploaded, report.TotalRequests, size, uint64(report.TotalRequests)*size, received)
+			}
+		})
+	}
+}
+
+// TestParseByteSize は、単位付きのサイズの解釈と、誤った形式の拒否を確認します。
+func TestParseByteSize(t *testing.T) {
+	for in, want := range map[string]byteSize{"4096": 4096, "512KB": 512 << 10, "1MiB": 1 << 20, "1.5mb": 3 << 19, "2G": 2 << 30, "10 B": 10} {
+		if got, err := parseByteSize(in); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+	for _, invalid := range []string{"", "MB", "-1KB", "1TB", "abc"} {
+		if _, err := parseByteSize(invalid); err == nil {
+			t.Errorf("parseByteSize(%q) がエラーになりません", invalid)
+		}
+	}
+}
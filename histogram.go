@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// [セクション61] レイテンシのヒストグラム: Prometheus 形式での出力 (latency_histogram)
+// ==============================================================================
+
+// パーセンタイルはテストごとの要約値のため、ダッシュボード上で複数のテストを合算したり、本番の監視で使っている
+// Prometheus のヒストグラムと同じ形で比較したりできません。latency_histogram を指定すると、応答を受信したリクエストの
+// レイテンシを Prometheus の標準のバケット境界（client_golang の DefBuckets: 5ms〜10s）で数え、
+// レポートの latency_histogram に {"le": 累積件数} の形式（"0.005"・"0.01"・…・"10"・"+Inf"。単位は秒）で記録します。
+// 件数は Prometheus と同じく累積（境界の値以下のリクエスト数）で、"+Inf" の件数は latency_histogram_count と一致します。
+//
+// latency_histogram_path を指定すると、同じ内容を Prometheus のテキスト形式（ultraload_request_duration_seconds の
+// _bucket・_sum・_count）でサーバーのローカルファイルへ書き出します（latency_histogram の指定を兼ねます）。
+// node_exporter の textfile コレクターや promtool で取り込めます。テストのタグは各系列のラベルとして付与します。
+//
+// バケットは記録時に全件を数えるため、リザーバーサンプリングが作動した場合も正確な値です。
+// exclude_cold_start で除外した各ワーカーの最初のリクエストは含みません（レイテンシ統計と同じ母数です）。
+// -merge で統合する場合は、バケットごとの件数を合計します（バケット境界が共通のため正確に統合できます）。
+
+// histogramBuckets は、Prometheus の標準のバケット境界（client_golang の prometheus.DefBuckets と同じ値）です。
+var histogramBuckets = [...]time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	1 * time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// histogramMetricName は、latency_histogram_path に書き出すメトリクスの名前です。
+const histogramMetricName = "ultraload_request_duration_seconds"
+
+// histogramInf は、上限のないバケットの境界の表記です。
+const histogramInf = "+Inf"
+
+// latencyHistogram は、レイテンシをバケットごとに数えます。ResultMetrics.mu で保護します。
+// nil の latencyHistogram は何も数えません。
+type latencyHistogram struct {
+	counts [len(histogramBuckets) + 1]uint64 // バケットごとの（累積ではない）件数。最後の要素は +Inf
+	sum    time.Duration
+}
+
+// newLatencyHistogram は、latency_histogram または latency_histogram_path が指定されている場合に latencyHistogram を生成します（未指定の場合は nil）。
+func newLatencyHistogram(cfg *TestConfig) *latencyHistogram {
+	if !cfg.LatencyHistogram && cfg.LatencyHistogramPath == "" {
+		return nil
+	}
+	return &latencyHistogram{}
+}
+
+// add は、1件のレイテンシを数えます。
+func (h *latencyHistogram) add(d time.Duration) {
+	if h == nil {
+		return
+	}
+	// Prometheus のバケットは「境界の値以下」のため、境界と等しい値はそのバケットに含めます
+	i, _ := slices.BinarySearch(histogramBuckets[:], d)
+	h.counts[i]++
+	h.sum += d
+}
+
+// histogramLabel は、i 番目のバケットの境界のレポート上の表記（秒。5ms → "0.005"、最後のバケットは "+Inf"）を返します。
+func histogramLabel(i int) string {
+	if i >= len(histogramBuckets) {
+		return histogramInf
+	}
+	return strconv.FormatFloat(histogramBuckets[i].Seconds(), 'f', -1, 64)
+}
+
+// fill は、数えた件数を累積してレポートの latency_histogram・latency_histogram_count・latency_histogram_sum_seconds に設定します。
+func (h *latencyHistogram) fill(report *TestReport) {
+	if h == nil {
+		return
+	}
+	report.LatencyHistogram = make(map[string]uint64, len(h.counts))
+	var cumulative uint64
+	for i, count := range h.counts {
+		cumulative += count
+		report.LatencyHistogram[histogramLabel(i)] = cumulative
+	}
+	report.LatencyHistogramCount = cumulative
+	report.LatencyHistogramSumSec = h.sum.Seconds()
+}
+
+// writeLatencyHistogram は、レポートの latency_histogram を Prometheus のテキスト形式で path へ書き出します。
+// tags は各系列のラベルとして付与します。
+func writeLatencyHistogram(path string, report *TestReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	labels := histogramTagLabels(report.Tags)
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "# HELP %s ultraload の負荷テストで計測したリクエストのレイテンシ（応答を受信したもの）\n", histogramMetricName)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", histogramMetricName)
+	for i := range len(histogramBuckets) + 1 {
+		label := histogramLabel(i)
+		fmt.Fprintf(w, "%s_bucket{%sle=%q} %d\n", histogramMetricName, labels, label, report.LatencyHistogram[label])
+	}
+	braced := ""
+	if labels != "" {
+		braced = "{" + strings.TrimSuffix(labels, ",") + "}"
+	}
+	fmt.Fprintf(w, "%s_sum%s %s\n", histogramMetricName, braced, strconv.FormatFloat(report.LatencyHistogramSumSec, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count%s %d\n", histogramMetricName, braced, report.LatencyHistogramCount)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// histogramTagLabels は、タグを Prometheus のラベルの並び（`key="value",` の連結。キーの昇順）に変換します。
+// ラベル名に使えない文字は "_" に置き換え、値は Prometheus のテキスト形式に従ってエスケープします。
+func histogramTagLabels(tags map[string]string) string {
+	var b strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(tags)) {
+		name := []byte(key)
+		for i, c := range name {
+			if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+				name[i] = '_'
+			}
+		}
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(tags[key])
+		fmt.Fprintf(&b, "%s=\"%s\",", name, value)
+	}
+	return b.String()
+}
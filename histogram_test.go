@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLatencyHistogramBuckets は、バケットの累積件数が境界の順に単調増加し、"+Inf" が総件数と一致すること、
+// 各バケットの件数が「境界の値以下」の件数を数え直した値と一致することを確認します（境界ちょうどの値を含みます）。
+func TestLatencyHistogramBuckets(t *testing.T) {
+	var latencies []time.Duration
+	latencies = append(latencies, 0, 11*time.Second)
+	latencies = append(latencies, histogramBuckets[:]...)
+	for range 5000 {
+		latencies = append(latencies, time.Duration(rand.Int64N(int64(12*time.Second))))
+	}
+
+	h := &latencyHistogram{}
+	var sum time.Duration
+	for _, d := range latencies {
+		h.add(d)
+		sum += d
+	}
+	var report TestReport
+	h.fill(&report)
+
+	// バケットごとの（累積ではない）件数の合計は、総件数と一致すること
+	var total uint64
+	for _, count := range h.counts {
+		total += count
+	}
+	if total != uint64(len(latencies)) {
+		t.Errorf("バケットの件数の合計 = %d, want %d", total, len(latencies))
+	}
+
+	var prev uint64
+	for i := range len(histogramBuckets) + 1 {
+		label := histogramLabel(i)
+		got, ok := report.LatencyHistogram[label]
+		if !ok {
+			t.Fatalf("latency_histogram に %q がありません", label)
+		}
+		if got < prev {
+			t.Errorf("le=%s の件数 %d が直前のバケットの %d より少なくなっています", label, got, prev)
+		}
+		prev = got
+
+		want := uint64(len(latencies))
+		if i < len(histogramBuckets) {
+			want = 0
+			for _, d := range latencies {
+				if d <= histogramBuckets[i] {
+					want++
+				}
+			}
+		}
+		if got != want {
+			t.Errorf("le=%s の件数 = %d, want %d", label, got, want)
+		}
+	}
+	if report.LatencyHistogram[histogramInf] != report.LatencyHistogramCount || report.LatencyHistogramCount != uint64(len(latencies)) {
+		t.Errorf("+Inf=%d count=%d, want %d", report.LatencyHistogram[histogramInf], report.LatencyHistogramCount, len(latencies))
+	}
+	if report.LatencyHistogramSumSec != sum.Seconds() {
+		t.Errorf("sum = %v, want %v", report.LatencyHistogramSumSec, sum.Seconds())
+	}
+}
+
+// TestWriteLatencyHistogram は、Prometheus のテキスト形式で書き出したバケットの系列が単調増加し、_count と一致することを確認します。
+func TestWriteLatencyHistogram(t *testing.T) {
+	h := &latencyHistogram{}
+	for _, d := range []time.Duration{time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond, 3 * time.Second, 20 * time.Second} {
+		h.add(d)
+	}
+	report := TestReport{Tags: map[string]string{"env": `stg"1`}}
+	h.fill(&report)
+
+	path := filepath.Join(t.TempDir(), "histogram.prom")
+	if err := writeLatencyHistogram(path, &report); err != nil {
+		t.Fatalf("writeLatencyHistogram: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var buckets []uint64
+	var count string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		name, value, _ := strings.Cut(line, " ")
+		switch {
+		case strings.HasPrefix(name, histogramMetricName+"_bucket{"):
+			if !strings.HasPrefix(name, histogramMetricName+`_bucket{env="stg\"1",le=`) {
+				t.Errorf("タグのラベルが正しくありません: %s", line)
+			}
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				t.Fatalf("件数を解釈できません: %s", line)
+			}
+			buckets = append(buckets, n)
+		case strings.HasPrefix(name, histogramMetricName+"_count"):
+			count = value
+		}
+	}
+	if len(buckets) != len(histogramBuckets)+1 {
+		t.Fatalf("バケットの系列 = %d 本, want %d", len(buckets), len(histogramBuckets)+1)
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] < buckets[i-1] {
+			t.Errorf("バケットの件数が単調増加していません: %v", buckets)
+			break
+		}
+	}
+	if last := strconv.FormatUint(buckets[len(buckets)-1], 10); last != "5" || count != last {
+		t.Errorf("+Inf=%s _count=%s, want 5", last, count)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestHMACSignVectors は、固定の鍵・ボディ・タイムスタンプで計算した署名が、外部（Python の hmac モジュール）で
+// 計算した値と一致することを確認します。
+func TestHMACSignVectors(t *testing.T) {
+	const body = `{"a":1}`
+	tests := []struct {
+		name      string
+		method    string
+		body      string
+		canonical string
+		encoding  string
+		want      string
+	}{
+		{
+			// "POST\n/v1/orders\n1700000000\n{"a":1}"
+			name:   "既定のテンプレート",
+			method: http.MethodPost,
+			body:   body,
+			want:   "dfa5c01049035a33590e11a22ec2c4eb93901338847b721a8b4cc5c3587b300d",
+		},
+		{
+			// "GET\n/v1/orders\n1700000000\n"
+			name:   "ボディなし",
+			method: http.MethodGet,
+			want:   "d74a21f5fbc40af2cb41fe0d5877a50890b3eb4994ba1b1abb1c85b35218ecaf",
+		},
+		{
+			// "POST|api.example.com|/v1/orders|x=1|1700000000|" + SHA-256(ボディ) の16進数
+			name:      "全プレースホルダーと Base64",
+			method:    http.MethodPost,
+			body:      body,
+			canonical: "{method}|{host}|{path}|{query}|{timestamp}|{body_sha256}",
+			encoding:  hmacEncodingBase64,
+			want:      "Q3MA7jQiw58x78yaCT3PVHPd1MMSL0ggSto4pa6QIWo=",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &TestConfig{Mode: modeHTTP, HMACKey: "secret", HMACCanonical: tt.canonical, HMACEncoding: tt.encoding}
+			if err := resolveHMAC(cfg); err != nil {
+				t.Fatalf("resolveHMAC: %v", err)
+			}
+			var reqBody io.Reader
+			if tt.body != "" {
+				reqBody = strings.NewReader(tt.body)
+			}
+			req, err := http.NewRequest(tt.method, "http://api.example.com/v1/orders?x=1", reqBody)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 同じ signer で2回計算しても（プールした状態を使い回しても）同じ値になること
+			for range 2 {
+				got, err := cfg.signer.sign(req, "1700000000")
+				if err != nil {
+					t.Fatalf("sign: %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("sign = %s, want %s", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestSigningTransport は、送信したリクエストに署名とタイムスタンプのヘッダーが付与され、署名の計算でボディが消費されずに
+// サーバーへ届くことを確認します。
+func TestSigningTransport(t *testing.T) {
+	const body = `{"a":1}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		io.WriteString(mac, r.Method+"\n"+r.URL.EscapedPath()+"\n"+r.Header.Get("X-Timestamp")+"\n"+string(got))
+		if string(got) != body || r.Header.Get("X-Signature") != hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := &TestConfig{Mode: modeHTTP, HMACKey: "secret"}
+	if err := resolveHMAC(cfg); err != nil {
+		t.Fatalf("resolveHMAC: %v", err)
+	}
+	client := &http.Client{Transport: newSigningTransport(http.DefaultTransport, cfg)}
+	resp, err := client.Post(server.URL+"/v1/orders", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200 (署名またはボディが一致しません)", resp.StatusCode)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// ==============================================================================
+// [セクション74] ホストごとのコネクションプール: 複数ターゲットの各ホストに専用の Transport を割り当てる
+// ==============================================================================
+
+// 複数ターゲットモード（targets）では、従来はすべてのターゲットが1つの http.Transport を共有していました。
+// MaxConnsPerHost・MaxIdleConnsPerHost はホストごとに適用されますが、アイドル接続の総数の上限（MaxIdleConns）は
+// 全ホストで共有のため、ホストの数が増えるほど1ホストあたりに保持できるアイドル接続が減り、応答の速いホストの接続が
+// 遅いホストの接続に押し出されて張り直しになる（1つのホストが別のホストのプールを奪う）ことがありました。
+//
+// targets のホスト（スキーム・ホスト名・ポートの組）が2つ以上ある場合は、ホストごとに専用の Transport（コネクションプール）を
+// 生成し、それぞれに単一ホストのテストと同じ上限（connections、または並行数の2倍）を設定します。
+// 同じホストの複数のターゲット（パスやメソッドが異なるだけのもの）は、1つのプールを共有します。
+// 接続確立の同時実行数の制限（dial_concurrency）、名前解決の集計、spread_ips の割り当ては、従来どおり全ホストで共有します。
+//
+// レポートの host_pools に、ホストごとの完了したリクエスト数、新規に確立した接続の数、同時に開いていた接続の最大数を記録します。
+// isolated_clients（ワーカーごとに専用のプール）と http3 は、プールの分け方が異なるため対象外です。
+// prewarm_connections は、従来どおり先頭のターゲットのホストのプールだけを温めます。
+
+// HostPoolReport は、複数ターゲットモードの1つのホストのコネクションプールの利用状況です。
+type HostPoolReport struct {
+	Host              string `json:"host"`                  // スキーム・ホスト名・ポート（例: "http://127.0.0.1:8080"）
+	Targets           int    `json:"targets"`               // このプールを共有するターゲットの数
+	Requests          uint64 `json:"requests"`              // 完了したリクエスト数
+	ConnectionsOpened uint64 `json:"connections_opened"`    // 新規に確立した接続の数
+	PeakOpenConns     int64  `json:"peak_open_connections"` // 同時に開いていた接続の最大数
+}
+
+// hostConnStats は、1つのホストのプールのダイヤラーが確立した接続の集計です。nil の hostConnStats は集計しません。
+type hostConnStats struct {
+	opened atomic.Uint64
+	open   atomic.Int64
+	peak   atomic.Int64
+}
+
+// track は、確立した接続を数え、閉じられたときに開いている接続の数を減らすよう conn を包んで返します。
+func (s *hostConnStats) track(conn net.Conn) net.Conn {
+	if s == nil {
+		return conn
+	}
+	s.opened.Add(1)
+	n := s.open.Add(1)
+	for {
+		peak := s.peak.Load()
+		if n <= peak || s.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	return &trackedConn{Conn: conn, stats: s}
+}
+
+// trackedConn は、閉じられたときに hostConnStats の開いている接続の数を減らす net.Conn です。
+type trackedConn struct {
+	net.Conn
+	stats  *hostConnStats
+	closed sync.Once
+}
+
+// Close は、接続を閉じます（Transport が複数回閉じても、数を減らすのは1回だけです）。
+func (c *trackedConn) Close() error {
+	c.closed.Do(func() { c.stats.open.Add(-1) })
+	return c.Conn.Close()
+}
+
+// hostPool は、1つのホストの専用のクライアント（コネクションプール）と、その接続の集計です。
+type hostPool struct {
+	host    string
+	client  *http.Client
+	stats   *hostConnStats
+	targets []*requestTarget
+}
+
+// hostPools は、複数ターゲットモードのホストごとのコネクションプールです。nil の hostPools は共有のクライアントを使います。
+type hostPools struct {
+	pools []*hostPool
+}
+
+// poolHost は、u の接続先をプールの単位（スキーム・ホスト名・ポート。ポートの省略時はスキームの既定値）で返します。
+func poolHost(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return u.Scheme + "://" + net.JoinHostPort(u.Hostname(), port)
+}
+
+// newHostPools は、targets のホストが2つ以上ある場合に、ホストごとに poolSize のクライアントを生成して各ターゲットに割り当てます。
+// 単一のホストの場合や、isolated_clients・http3 の場合は nil を返します（従来どおり共有のクライアントを使います）。
+func newHostPools(cfg *TestConfig, ts *targetSet, poolSize int) *hostPools {
+	if ts == nil || cfg.IsolatedClients || cfg.HTTP3 {
+		return nil
+	}
+	byHost := make(map[string]*hostPool)
+	var pools []*hostPool
+	for _, t := range ts.targets {
+		host := poolHost(t.baseReq.URL)
+		p, ok := byHost[host]
+		if !ok {
+			p = &hostPool{host: host}
+			byHost[host] = p
+			pools = append(pools, p)
+		}
+		p.targets = append(p.targets, t)
+	}
+	if len(pools) < 2 {
+		return nil
+	}
+
+	for _, p := range pools {
+		// ダイヤラーは設定から生成されるため、ホストごとの集計だけを差し替えた設定の複製でクライアントを生成します
+		// （dialGate などのテスト全体で共有する状態はポインターのため、複製しても共有されたままです）
+		hostCfg := *cfg
+		hostCfg.hostConns = &hostConnStats{}
+		p.stats = hostCfg.hostConns
+		p.client = createOptimizedHTTPClient(poolSize, &hostCfg)
+		for _, t := range p.targets {
+			t.pool = p
+		}
+	}
+	log.Printf("[Orchestrator] 複数ターゲットの %d ホストに、それぞれ専用のコネクションプールを割り当てます (1ホストあたりの接続の上限: %d)\n",
+		len(pools), connsPerHost(poolSize, cfg))
+	return &hostPools{pools: pools}
+}
+
+// clientFor は、ターゲット t へ送信するクライアントを返します（ホストごとのプールがない場合は shared）。
+func (t *requestTarget) clientFor(shared *http.Client) *http.Client {
+	if t.pool != nil {
+		return t.pool.client
+	}
+	return shared
+}
+
+// closeIdleConnections は、すべてのホストのプールのアイドル接続を閉じます。
+func (hp *hostPools) closeIdleConnections() {
+	if hp == nil {
+		return
+	}
+	for _, p := range hp.pools {
+		p.client.CloseIdleConnections()
+	}
+}
+
+// report は、レポートの host_pools を返します（ホストごとのプールがない場合は nil）。
+func (hp *hostPools) report() []HostPoolReport {
+	if hp == nil {
+		return nil
+	}
+	reports := make([]HostPoolReport, 0, len(hp.pools))
+	for _, p := range hp.pools {
+		r := HostPoolReport{
+			Host:              p.host,
+			Targets:           len(p.targets),
+			ConnectionsOpened: p.stats.opened.Load(),
+			PeakOpenConns:     p.stats.peak.Load(),
+		}
+		for _, t := range p.targets {
+			r.Requests += atomic.LoadUint64(&t.completed)
+		}
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+// addHostPoolReports は、-merge で統合するレポートの host_pools を、同じホストごとに合計します
+// （同時に開いていた接続の最大数は、別々のテストの接続が同時に開いていたとみなして合計します）。
+func addHostPoolReports(dst, src []HostPoolReport) []HostPoolReport {
+	for _, h := range src {
+		i := slices.IndexFunc(dst, func(d HostPoolReport) bool { return d.Host == h.Host })
+		if i < 0 {
+			dst = append(dst, h)
+			continue
+		}
+		dst[i].Requests += h.Requests
+		dst[i].ConnectionsOpened += h.ConnectionsOpened
+		dst[i].PeakOpenConns += h.PeakOpenConns
+	}
+	return dst
+}
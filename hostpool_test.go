@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestHostPools は、targets に2つのホストを混ぜて並行に負荷をかけると、ホストごとに専用のプールが割り当てられ、
+// 応答の遅いホストがいても、どちらのホストも connections の上限まで接続を開いてそれを使い回し続けることと、
+// host_pools のリクエスト数と接続数がそれぞれのサーバーで観測した値と一致することを確認します。
+func TestHostPools(t *testing.T) {
+	const workers, conns = 8, 3
+	type host struct {
+		server   *httptest.Server
+		requests atomic.Int64
+		opened   atomic.Int64
+	}
+	newHost := func(delay time.Duration) *host {
+		h := &host{}
+		h.server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.requests.Add(1)
+			time.Sleep(delay)
+		}))
+		h.server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				h.opened.Add(1)
+			}
+		}
+		h.server.Start()
+		t.Cleanup(h.server.Close)
+		return h
+	}
+	hosts := []*host{newHost(20 * time.Millisecond), newHost(time.Millisecond)}
+
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"targets": []map[string]any{
+			{"url": hosts[0].server.URL + "/a"},
+			{"url": hosts[0].server.URL + "/b"},
+			{"url": hosts[1].server.URL + "/"},
+		},
+		"concurrency":  workers,
+		"connections":  conns,
+		"duration":     "1s",
+		"no_preflight": true,
+	}))
+
+	if len(report.HostPools) != len(hosts) {
+		t.Fatalf("host_pools = %+v: ホストごとに %d 件あるはずです", report.HostPools, len(hosts))
+	}
+	for i, h := range hosts {
+		pool := report.HostPools[i]
+		u, _ := url.Parse(h.server.URL)
+		if pool.Host != poolHost(u) {
+			t.Errorf("host_pools[%d].host = %q, want %q", i, pool.Host, poolHost(u))
+		}
+		if wantTargets := 2 - i; pool.Targets != wantTargets {
+			t.Errorf("%s: targets = %d, want %d（同じホストのターゲットは1つのプールを共有するはずです）", pool.Host, pool.Targets, wantTargets)
+		}
+		// 他方のホストに接続を奪われていなければ、どちらのホストも上限まで接続を開き、張り直さずに使い回します
+		if pool.PeakOpenConns != conns || pool.ConnectionsOpened != conns {
+			t.Errorf("%s: peak_open_connections=%d connections_opened=%d, want どちらも %d", pool.Host, pool.PeakOpenConns, pool.ConnectionsOpened, conns)
+		}
+		if got := uint64(h.opened.Load()); got != pool.ConnectionsOpened {
+			t.Errorf("%s: サーバーが受け付けた接続 = %d, connections_opened = %d", pool.Host, got, pool.ConnectionsOpened)
+		}
+		// テスト終了の直前に送信したリクエストは、完了として数えられない場合があるため、接続数の分まで少なくてよいものとします
+		if got := uint64(h.requests.Load()); pool.Requests == 0 || pool.Requests > got || got > pool.Requests+conns {
+			t.Errorf("%s: requests = %d, サーバーに届いた数 = %d", pool.Host, pool.Requests, got)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// ==============================================================================
+// [セクション53] HTTP/3 (QUIC) のターゲット (http3)
+// ==============================================================================
+
+// CDN や大手のサービスでは、HTTP/3 で配信するのが一般的になりつつあります。http3 を指定すると、HTTPクライアントの
+// Transport を quic-go の HTTP/3 実装に差し替え、UDP 上の QUIC でターゲットと通信します。ワーカー・レート制御・
+// メトリクス・レポートはすべて従来のまま使うため、HTTP/1.1・HTTP/2 と同じ条件で結果を比較できます。
+//
+// HTTP/3 では、ホストごとに1本の QUIC 接続の上で、リクエストごとにストリームを開いて並行に送信します。
+// 同時に開けるストリームの数はサーバーが通知する上限（quic-go のサーバーでは既定で100）に従い、それを超える分は
+// ストリームが空くまで送信を待ちます（待ち時間もレイテンシに含まれます）。
+//
+// 【制約】 TCP のダイヤラーを使わないため、TCP・ソケットのチューニング（tcp_nodelay、sock_rcvbuf、sock_sndbuf、
+// tcp_keepalive）、dns_server、dial_concurrency、max_requests_per_conn、prewarm_connections とは併用できません。
+// QUIC は TLS 1.3 のみに対応するため、送信先は https:// のURLで、tls_max_version を指定する場合は 1.3 にしてください。
+// HTTP/3 に対応していないターゲットへは自動でフォールバックせず、QUIC のハンドシェイクがタイムアウトした時点で、
+// その旨をプリフライトのエラー（プリフライトを省略した場合はエラー種別 quic_timeout）として報告します。
+// ハンドシェイクの無応答は、tls_handshake_timeout（既定で10秒）とリクエストのタイムアウトの半分の短いほうで打ち切るため、
+// リクエストのタイムアウトより先に検出できます。アイドル接続は idle_conn_timeout で閉じます（timeouts.go を参照）。
+// レスポンスヘッダーと 100-Continue の個別のタイムアウト（response_header_timeout、expect_continue_timeout）には対応していません。
+
+// errKindQUICTimeout は、QUIC の接続でターゲットからの応答が途絶えてタイムアウトしたリクエストのエラー種別です
+// （ほとんどはハンドシェイク中で、ターゲットが HTTP/3 に対応していないか、UDP が遮断されている場合に発生します）。
+const errKindQUICTimeout = "quic_timeout"
+
+// http3Transport は、quic-go の HTTP/3 Transport です。
+// CloseIdleConnections では、アイドル接続だけでなく UDP ソケットも含めてすべてを閉じます。
+// クライアントを使い終えた時点（テストやプリフライトの終了時）にしか呼ばれないため、ソケットを残さないようにするためです。
+type http3Transport struct {
+	*http3.Transport
+}
+
+// CloseIdleConnections は、すべての QUIC 接続と UDP ソケットを閉じます。
+func (t http3Transport) CloseIdleConnections() {
+	t.Transport.Close()
+}
+
+// newHTTP3Transport は、cfg の TLS 設定と、HTTP/1.1・HTTP/2 の Transport と同じタイムアウトに従う HTTP/3 の Transport を生成します。
+// timeout はリクエスト全体のタイムアウトです。
+func newHTTP3Transport(cfg *TestConfig, timeout time.Duration, timeouts transportTimeouts) http.RoundTripper {
+	return http3Transport{&http3.Transport{
+		TLSClientConfig: newTLSConfig(cfg),
+		QUICConfig: &quic.Config{
+			// 応答しない（UDP が遮断された）ターゲットで、リクエストのタイムアウトより先に QUIC のエラーとして失敗させます
+			HandshakeIdleTimeout: min(timeouts.tlsHandshake, timeout/2),
+			MaxIdleTimeout:       timeouts.idleConn,
+			// NAT やロードバランサーが無通信の UDP のマッピングを消してしまわないよう、定期的に PING を送ります
+			KeepAlivePeriod: 15 * time.Second,
+		},
+	}}
+}
+
+// validateHTTP3 は、http3 と併用できない設定を検出します。
+func validateHTTP3(cfg *TestConfig) error {
+	if !cfg.HTTP3 {
+		return nil
+	}
+	if cfg.Mode != modeHTTP {
+		return errors.New("http3 は HTTPモードでのみ使用できます")
+	}
+	for _, option := range []struct {
+		name string
+		set  bool
+	}{
+		{"tcp_nodelay", cfg.TCPNoDelay != nil},
+		{"sock_rcvbuf", cfg.SockRcvBuf != 0},
+		{"sock_sndbuf", cfg.SockSndBuf != 0},
+		{"tcp_keepalive", cfg.TCPKeepAlive != 0},
+		{"dns_server", cfg.DNSServer != ""},
+		{"spread_ips", cfg.SpreadIPs},
+		{"dial_concurrency", cfg.DialConcurrency != 0},
+		{"max_requests_per_conn", cfg.MaxRequestsPerConn != 0},
+		{"prewarm_connections", cfg.PrewarmConnections},
+		{"response_header_timeout", cfg.ResponseHeaderTimeout != 0},
+		{"expect_continue_timeout", cfg.ExpectContinueTimeout != 0},
+	} {
+		if option.set {
+			return fmt.Errorf("http3 は %s と併用できません（HTTP/3 (QUIC) の Transport には該当する設定がありません）", option.name)
+		}
+	}
+	if cfg.TLSMaxVersion != "" && cfg.TLSMaxVersion != "1.3" {
+		return errors.New("http3 (QUIC) は TLS 1.3 のみに対応しています。tls_max_version は省略するか 1.3 を指定してください")
+	}
+
+	urls := []string{cfg.TargetURL}
+	for _, t := range cfg.Targets {
+		urls = append(urls, t.URL)
+	}
+	for _, spec := range cfg.requestSpecs {
+		urls = append(urls, spec.URL)
+	}
+	for _, raw := range urls {
+		if u, err := url.Parse(raw); err == nil && u.Scheme != "https" {
+			return fmt.Errorf("http3 の送信先は https:// のURLで指定してください: %s", raw)
+		}
+	}
+	return nil
+}
+
+// isQUICTimeout は、err が QUIC の接続のタイムアウト（ハンドシェイクの時間切れ、または無応答）によるものかを判定します。
+func isQUICTimeout(err error) bool {
+	var handshakeErr *quic.HandshakeTimeoutError
+	var idleErr *quic.IdleTimeoutError
+	return errors.As(err, &handshakeErr) || errors.As(err, &idleErr)
+}
+
+// explainHTTP3Error は、HTTP/3 で接続できなかった場合に、原因の候補を添えたエラーにします。
+func explainHTTP3Error(err error) error {
+	if !isQUICTimeout(err) {
+		return err
+	}
+	return fmt.Errorf("HTTP/3 (QUIC) で接続できませんでした。ターゲットが HTTP/3 に対応していないか、UDP の通信が遮断されている可能性があります（http3 を外すと HTTP/1.1・HTTP/2 で送信します）: %w", err)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newTestHTTP3Server は、httptest の自己署名証明書で HTTP/3 (QUIC) に応答するターゲットを起動し、
+// その https:// のURLと、HTTP/3 で受信したリクエストの数のカウンタを返します。
+func newTestHTTP3Server(t *testing.T) (string, *atomic.Int64) {
+	t.Helper()
+	certSource := httptest.NewTLSServer(http.NotFoundHandler())
+	certs := certSource.TLS.Certificates
+	certSource.Close()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h3Requests atomic.Int64
+	server := &http3.Server{
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: certs}),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ProtoMajor == 3 {
+				h3Requests.Add(1)
+			}
+		}),
+	}
+	go server.Serve(conn)
+	t.Cleanup(func() {
+		server.Close()
+		conn.Close()
+	})
+	return "https://" + conn.LocalAddr().String(), &h3Requests
+}
+
+// TestHTTP3Target は、http3 を指定すると HTTP/3 に対応したターゲットへ QUIC で送信し、
+// すべてのリクエストが HTTP/3 で届いてレポートに http3 が記録されることを確認します。
+func TestHTTP3Target(t *testing.T) {
+	url, h3Requests := newTestHTTP3Server(t)
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":  url,
+		"concurrency": 2,
+		"duration":    "300ms",
+		"http3":       true,
+	}))
+	if report.TotalRequests == 0 || report.Errors != 0 || !report.HTTP3 {
+		t.Fatalf("total=%d errors=%d error_kinds=%v http3=%v error_msg=%q",
+			report.TotalRequests, report.Errors, report.ErrorKinds, report.HTTP3, report.ErrorMsg)
+	}
+	// プリフライトの分だけ、サーバー側の件数が多くなります
+	if got := h3Requests.Load(); got < int64(report.TotalRequests) {
+		t.Errorf("HTTP/3 で受信したリクエスト = %d, total = %d: すべて HTTP/3 で届くはずです", got, report.TotalRequests)
+	}
+}
+
+// TestHTTP3NonH3Target は、HTTP/3 に対応していない（TCP の HTTPS のみの）ターゲットへは自動でフォールバックせず、
+// プリフライトでは原因の候補を添えたエラーでテストを中止し、プリフライトを省略した場合は quic_timeout エラーとして
+// 記録することを確認します。
+func TestHTTP3NonH3Target(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	// タイムアウトが1秒のため、QUIC のハンドシェイクは 0.5 秒で打ち切られます
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":  server.URL,
+		"concurrency": 1,
+		"duration":    "1s",
+		"timeout":     1,
+		"http3":       true,
+	}))
+	if report.TotalRequests != 0 || !strings.Contains(report.ErrorMsg, "HTTP/3 (QUIC) で接続できませんでした") {
+		t.Errorf("total=%d error_msg=%q: プリフライトで HTTP/3 に対応していない旨を報告して中止するはずです", report.TotalRequests, report.ErrorMsg)
+	}
+
+	report = runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":   server.URL,
+		"concurrency":  1,
+		"duration":     "1200ms",
+		"timeout":      1,
+		"http3":        true,
+		"no_preflight": true,
+	}))
+	if report.Errors == 0 || report.Success != 0 || report.ErrorKinds[errKindQUICTimeout] != uint64(report.Errors) {
+		t.Errorf("success=%d errors=%d error_kinds=%v: すべて %s エラーになるはずです", report.Success, report.Errors, report.ErrorKinds, errKindQUICTimeout)
+	}
+}
+
+// TestHTTP3Validation は、https:// 以外の送信先と、QUIC の Transport に該当する設定がない項目との併用を拒否することを確認します。
+func TestHTTP3Validation(t *testing.T) {
+	for _, body := range []string{
+		`{"target_url":"http://127.0.0.1:1","http3":true}`,
+		`{"target_url":"https://127.0.0.1:1","http3":true,"dns_server":"127.0.0.1"}`,
+		`{"target_url":"https://127.0.0.1:1","http3":true,"tls_max_version":"1.2"}`,
+	} {
+		if cfg, rec := postConfig(t, body, false); cfg != nil || rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "http3") {
+			t.Errorf("%s: status = %d, body = %s", body, rec.Code, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+}
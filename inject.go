@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// ==============================================================================
+// [セクション42] 遅延の注入: 遠隔地のクライアントを模した人為的なネットワーク遅延 (inject_latency)
+// ==============================================================================
+
+// 本番前の環境では、テスターとターゲットが同じデータセンター内にあることが多く、計測されるレイテンシには
+// 遠隔地の利用者が実際に体験する往復遅延（RTT）が含まれません。inject_latency を指定すると、各リクエストの送信直前に
+// 指定した時間だけ待機し、その待機時間を含めてレイテンシを計測します。これにより、レポートのレイテンシは
+// 「その地域の利用者から見た応答時間」の近似になります。inject_latency_jitter を指定すると、待機時間に
+// ±jitter の一様なばらつきを加えます（負になる場合は0とします）。
+//
+// 複数ターゲットモードでは、targets の各項目にも inject_latency を指定でき、全体の値より優先されます
+// （地域ごとに異なるエンドポイントへ、それぞれの地域の遅延を付けて送信する、など）。
+//
+// 【注意】 これはシミュレーションです。待機はテスター内で行うだけで、実際のネットワーク経路や
+// TCPのふるまい（輻輳制御やウィンドウの拡大にかかる時間など）は再現しません。注入した場合はレポートの
+// latency_simulated と warnings にその旨を記録します。同時接続数（in-flight）の集計には、待機時間を含めません。
+
+// latencyInjector は、リクエストごとに注入する遅延です。nil の latencyInjector は遅延を注入しません。
+type latencyInjector struct {
+	base   time.Duration
+	jitter time.Duration
+}
+
+// newLatencyInjector は、base ± jitter の遅延を注入する latencyInjector を生成します。base が0以下の場合は nil を返します。
+func newLatencyInjector(base, jitter time.Duration) *latencyInjector {
+	if base <= 0 {
+		return nil
+	}
+	return &latencyInjector{base: base, jitter: jitter}
+}
+
+// String は、レポートに記録する遅延の設定（"50ms" または "50ms±10ms"）を返します。
+func (li *latencyInjector) String() string {
+	if li == nil {
+		return ""
+	}
+	if li.jitter > 0 {
+		return fmt.Sprintf("%s±%s", li.base, li.jitter)
+	}
+	return li.base.String()
+}
+
+// delay は、1件のリクエストに注入する遅延を決めます（nil の latencyInjector は0を返します）。
+// ばらつきは rng（ワーカーまたはディスパッチャー専用の、seed から導出した乱数生成器）から引くため、
+// 同じ seed のテストでは同じ順序で同じ遅延を注入します。
+func (li *latencyInjector) delay(rng *rand.Rand) time.Duration {
+	if li == nil {
+		return 0
+	}
+	d := li.base
+	if li.jitter > 0 {
+		d += time.Duration(rng.Int64N(int64(2*li.jitter)+1)) - li.jitter
+	}
+	return max(d, 0)
+}
+
+// sleepInjected は、注入する遅延 d の分だけ待機し、実際に待機した時間を返します。
+// 待機中に ctx がキャンセルされた場合は false を返します（このリクエストは送信せず、記録もしません）。
+func sleepInjected(ctx context.Context, d time.Duration) (time.Duration, bool) {
+	if d <= 0 {
+		return 0, true
+	}
+	start := time.Now()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return time.Since(start), false
+	case <-timer.C:
+		return time.Since(start), true
+	}
+}
+
+// injectsLatency は、全体またはいずれかのターゲットで遅延の注入が指定されているかどうかを返します。
+func injectsLatency(cfg *TestConfig) bool {
+	if cfg.InjectLatency > 0 {
+		return true
+	}
+	for _, t := range cfg.Targets {
+		if t.InjectLatency > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// simulatedLatencyWarning は、遅延を注入したテストのレポートに記録する注意事項です。
+const simulatedLatencyWarning = "inject_latency により、レイテンシには送信前に注入した人為的な遅延が含まれています（シミュレーションであり、実際のネットワーク経路は再現していません）"
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// TestInjectLatencyShiftsPercentiles は、inject_latency を指定すると、レポートの各パーセンタイルが注入した遅延の分だけ
+// 大きくなり、latency_simulated が記録されることを確認します。
+func TestInjectLatencyShiftsPercentiles(t *testing.T) {
+	server := newTestJobServer(t)
+	run := func(inject string) *TestReport {
+		fields := map[string]any{"target_url": server.URL, "concurrency": 4, "duration": "500ms"}
+		if inject != "" {
+			fields["inject_latency"] = inject
+		}
+		report := runTestLoad(newTestConfig(t, fields))
+		if report.latency == nil || report.Errors != 0 {
+			t.Fatalf("inject_latency=%q: errors=%d, レイテンシが記録されていません (%s)", inject, report.Errors, report.ErrorMsg)
+		}
+		return report
+	}
+
+	const injected = 50 * time.Millisecond
+	base, shifted := run(""), run(injected.String())
+	if base.LatencySimulated || !shifted.LatencySimulated {
+		t.Errorf("latency_simulated: 注入なし %v, 注入あり %v", base.LatencySimulated, shifted.LatencySimulated)
+	}
+	// 注入した遅延はタイマーで待つため、差は注入した遅延以上、スケジューリングの遅れを見込んだ上限以下になります
+	for _, p := range []struct {
+		name          string
+		base, shifted time.Duration
+	}{
+		{"p50", base.latency.p50, shifted.latency.p50},
+		{"p90", base.latency.p90, shifted.latency.p90},
+		{"min", base.latency.min, shifted.latency.min},
+	} {
+		if diff := p.shifted - p.base; p.shifted < injected || diff > injected+20*time.Millisecond {
+			t.Errorf("%s: 注入なし %v, 注入あり %v: 注入した %v の分だけずれていません", p.name, p.base, p.shifted, injected)
+		}
+	}
+}
+
+// TestLatencyInjectorDelay は、注入する遅延が base ± jitter の範囲に収まり、同じシードの乱数生成器からは同じ順序で
+// 同じ遅延が引かれることを確認します。
+func TestLatencyInjectorDelay(t *testing.T) {
+	var none *latencyInjector
+	if d := none.delay(newWorkerRand(1, 0)); d != 0 {
+		t.Errorf("nil の latencyInjector の遅延 = %v, want 0", d)
+	}
+
+	li := newLatencyInjector(50*time.Millisecond, 10*time.Millisecond)
+	draw := func(rng *rand.Rand) []time.Duration {
+		delays := make([]time.Duration, 100)
+		for i := range delays {
+			delays[i] = li.delay(rng)
+		}
+		return delays
+	}
+	first, again, other := draw(newWorkerRand(7, 3)), draw(newWorkerRand(7, 3)), draw(newWorkerRand(8, 3))
+	same := true
+	for i := range first {
+		if first[i] < 40*time.Millisecond || first[i] > 60*time.Millisecond {
+			t.Fatalf("遅延 %v が 50ms±10ms の範囲外です", first[i])
+		}
+		if first[i] != again[i] {
+			t.Fatalf("同じシードで %d 件目の遅延が異なります: %v, %v", i, first[i], again[i])
+		}
+		same = same && first[i] == other[i]
+	}
+	if same {
+		t.Error("異なるシードで同じ遅延の列が引かれました")
+	}
+
+	// ばらつきで負になる場合は0とします
+	if d := newLatencyInjector(time.Millisecond, time.Second).delay(newWorkerRand(1, 0)); d < 0 {
+		t.Errorf("遅延 = %v: 負の遅延は0とするはずです", d)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ==============================================================================
+// [セクション12] 非同期ジョブ: テストの開始・停止・結果取得を分離したAPI
+// ==============================================================================
+
+// /api/run はテスト完了までHTTPリクエストをブロックするため、終了時刻の決まらないテスト（forever）や、
+// 途中で手動停止したいテストには使えません。そこで、テストをジョブとしてバックグラウンドで実行し、
+//   POST /api/start        … ジョブを開始して job_id を返す
+//   GET  /api/result/{id}  … 実行中なら進捗、完了済みならレポートを返す
+//   POST /api/stop/{id}    … ジョブを停止し、それまでの結果（部分レポート）を返す
+// の3つのエンドポイントで操作できるようにしています（一時停止と再開は pause.go を参照）。
+//
+// 完了したジョブは、結果を取得できるよう台帳に残しますが、ジョブはレイテンシの全サンプルを含む ResultMetrics を
+// 保持しているため、残し続けると長時間稼働するサーバーのメモリが増え続けます。完了したジョブは、完了から
+// jobRetention（-job-retention）が経過すると台帳から破棄し、完了したジョブが maxFinishedJobs（-max-finished-jobs）件を
+// 超えた場合は、完了の古い順に破棄します。破棄したジョブの結果は取得できなくなる（404）ため、必要な結果は期限内に取得してください。
+
+// 完了したジョブを台帳に残す期間と件数の上限です（-job-retention・-max-finished-jobs フラグ）。
+// jobRetention が0の場合は期限を設けず、件数の上限だけで破棄します。
+var (
+	jobRetention    = 10 * time.Minute
+	maxFinishedJobs = 100
+)
+
+// jobNotFoundMsg は、台帳にないジョブ（存在しない、または破棄済み）を指定された場合のエラーメッセージです。
+const jobNotFoundMsg = "指定されたジョブが見つかりません（完了したジョブは -job-retention の経過後に破棄されます）"
+
+// ジョブの状態（JobStatus.Status）
+const (
+	jobRunning = "running"
+	jobDone    = "done"
+)
+
+// JobStatus は、ジョブ系APIのレスポンスです。
+type JobStatus struct {
+	JobID         string      `json:"job_id"`
+	Status        string      `json:"status"`                 // "running" / "done"
+	ElapsedSec    float64     `json:"elapsed_sec"`            // 開始からの経過秒数
+	TotalRequests uint64      `json:"total_requests"`         // 現時点で完了したリクエスト数（実行中の進捗表示用）
+	Report        *TestReport `json:"report,omitempty"`       // 完了済みの場合のみ
+	ErrorMsg      string      `json:"error_msg,omitempty"`    // ジョブが見つからない場合など
+	Forever       bool        `json:"forever,omitempty"`      // 停止されるまで実行し続けるジョブかどうか
+	DurationSec   float64     `json:"duration_sec,omitempty"` // 指定された実行時間（秒。forever の場合は省略）
+	Paused        bool        `json:"paused,omitempty"`       // 送信を一時停止しているかどうか（pause.go を参照）
+
+	// LatestSnapshot は、実行中のジョブの直近の途中経過です（report_interval_sec を指定した場合のみ）。
+	LatestSnapshot *IntervalSnapshot `json:"latest_snapshot,omitempty"`
+}
+
+// loadJob は、バックグラウンドで実行中（または完了済み）の1つの負荷テストです。
+type loadJob struct {
+	id      string
+	cfg     *TestConfig
+	metrics *ResultMetrics
+	started time.Time
+	cancel  context.CancelFunc
+	done    chan struct{} // report の設定後にクローズされます
+	report  *TestReport
+
+	// finished は、ジョブが完了した時刻です（done のクローズ前に設定されます）。
+	finished time.Time
+
+	// progressCache は、/api/progress/{id} が直近に算出したレイテンシの要約です。
+	progressCache progressCache
+}
+
+// status は、ジョブの現在の状態を JobStatus として返します。
+func (j *loadJob) status() JobStatus {
+	st := JobStatus{
+		JobID:         j.id,
+		Status:        jobRunning,
+		ElapsedSec:    time.Since(j.started).Seconds(),
+		TotalRequests: j.metrics.TotalRequests.Load(),
+		Forever:       j.cfg.Forever,
+	}
+	if !j.cfg.Forever {
+		st.DurationSec = j.cfg.Duration.Seconds()
+	}
+	select {
+	case <-j.done:
+		st.Status = jobDone
+		st.Report = j.report
+		st.ElapsedSec = j.report.ActualDurationSec
+	default:
+		st.LatestSnapshot = j.metrics.latestSnapshot()
+		st.Paused = j.cfg.pause.isPaused()
+	}
+	return st
+}
+
+// jobRegistry は、ジョブIDからジョブを引くためのスレッドセーフな台帳です。
+type jobRegistry struct {
+	mu   sync.Mutex
+	jobs map[string]*loadJob
+}
+
+// jobs は、このプロセスで開始されたすべてのジョブの台帳です。
+var jobs = &jobRegistry{jobs: make(map[string]*loadJob)}
+
+// newJobID は、推測されにくいランダムなジョブIDを生成します。
+func newJobID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// 乱数源が使えない環境は事実上存在しませんが、念のため時刻で代替します
+		return hex.EncodeToString([]byte(time.Now().Format("150405.000000")))
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// start は、負荷テストをバックグラウンドで開始し、登録したジョブを返します。
+func (r *jobRegistry) start(cfg *TestConfig) *loadJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	// ジョブは一時停止できます（記録された時刻どおりに送信するトレースの再生を除きます）
+	if cfg.traceReplay == nil {
+		cfg.pause = newPauseState()
+	}
+	job := &loadJob{
+		id:      newJobID(),
+		cfg:     cfg,
+		metrics: NewResultMetrics(estimateTotalRequests(cfg)),
+		started: time.Now(),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	job.metrics.progress = newProgressReservoir()
+
+	r.mu.Lock()
+	r.jobs[job.id] = job
+	r.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		job.report = runLoadTest(ctx, cfg, job.metrics)
+		job.finished = time.Now()
+		close(job.done)
+		r.retire(job)
+	}()
+	return job
+}
+
+// retire は、完了した job の破棄を jobRetention 後に予約し、完了したジョブが maxFinishedJobs 件を超えていれば
+// 完了の古い順に破棄します。
+func (r *jobRegistry) retire(job *loadJob) {
+	if jobRetention > 0 {
+		time.AfterFunc(jobRetention, func() { r.remove(job) })
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var finished []*loadJob
+	for _, j := range r.jobs {
+		select {
+		case <-j.done:
+			finished = append(finished, j)
+		default:
+		}
+	}
+	if excess := len(finished) - maxFinishedJobs; excess > 0 {
+		slices.SortFunc(finished, func(a, b *loadJob) int { return a.finished.Compare(b.finished) })
+		for _, j := range finished[:excess] {
+			delete(r.jobs, j.id)
+		}
+		log.Printf("[Job] 完了したジョブが上限 (%d 件) を超えたため、古い %d 件を破棄しました\n", maxFinishedJobs, excess)
+	}
+}
+
+// remove は、job を台帳から破棄します（すでに破棄されている場合は何もしません）。
+func (r *jobRegistry) remove(job *loadJob) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.jobs[job.id] == job {
+		delete(r.jobs, job.id)
+	}
+}
+
+// get は、ジョブIDに対応するジョブを返します。
+func (r *jobRegistry) get(id string) (*loadJob, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	return job, ok
+}
+
+// stopAll は、実行中のすべてのジョブを停止し、部分レポートがそろうまで（または ctx の期限まで）待機します。
+// シグナル受信時に呼び出され、各ジョブの部分レポートをログへ出力します。
+func (r *jobRegistry) stopAll(ctx context.Context) {
+	r.mu.Lock()
+	var running []*loadJob
+	for _, job := range r.jobs {
+		select {
+		case <-job.done:
+		default:
+			running = append(running, job)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, job := range running {
+		job.cancel()
+	}
+	for _, job := range running {
+		select {
+		case <-job.done:
+			data, err := json.Marshal(job.report)
+			if err != nil {
+				log.Printf("[Job] ジョブ %s の部分レポートのエンコードに失敗しました: %v\n", job.id, err)
+				continue
+			}
+			log.Printf("[Job] ジョブ %s を停止しました。部分レポート: %s\n", job.id, data)
+		case <-ctx.Done():
+			log.Printf("[Job] ジョブ %s の停止を待機中にタイムアウトしました\n", job.id)
+			return
+		}
+	}
+}
+
+// writeJobStatus は、JobStatus をJSONとして書き込みます。
+func writeJobStatus(w http.ResponseWriter, code int, st JobStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(st); err != nil {
+		log.Printf("[API Error] ジョブ状態のJSONエンコードに失敗しました: %v\n", err)
+	}
+}
+
+// handleJobStart は、負荷テストをジョブとして開始し、即座に job_id を返すエンドポイントです。
+func handleJobStart(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error_msg": "POSTメソッドのみ許可されています"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg, ok := readTestConfig(w, r)
+	if !ok {
+		return
+	}
+
+	job := jobs.start(cfg)
+	log.Printf("[API] ジョブ %s を開始しました。ターゲット: %s", job.id, cfg.TargetURL)
+	writeJobStatus(w, http.StatusAccepted, job.status())
+}
+
+// handleJobResult は、ジョブの進捗（実行中）またはレポート（完了済み）を返すエンドポイントです。
+func handleJobResult(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	id := r.PathValue("id")
+	job, ok := jobs.get(id)
+	if !ok {
+		writeJobStatus(w, http.StatusNotFound, JobStatus{JobID: id, ErrorMsg: jobNotFoundMsg})
+		return
+	}
+	writeJobStatus(w, http.StatusOK, job.status())
+}
+
+// handleJobStop は、ジョブを停止し、停止までに収集した結果（部分レポート）を返すエンドポイントです。
+// 実行中のリクエストの中断と集計を待ってから応答します。
+func handleJobStop(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error_msg": "POSTメソッドのみ許可されています"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	job, ok := jobs.get(id)
+	if !ok {
+		writeJobStatus(w, http.StatusNotFound, JobStatus{JobID: id, ErrorMsg: jobNotFoundMsg})
+		return
+	}
+
+	job.cancel()
+	select {
+	case <-job.done:
+	case <-r.Context().Done():
+		return
+	}
+	log.Printf("[API] ジョブ %s を停止しました", job.id)
+	writeJobStatus(w, http.StatusOK, job.status())
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestJobServer は、常に 200 を返すターゲットを起動します。
+func newTestJobServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// waitFor は、cond が true になるまで（最大 timeout）待ち、なった場合に true を返します。
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cond()
+}
+
+// startForeverJob は、server への forever のジョブを global の台帳で開始し、リクエストが記録され始めるまで待ちます。
+func startForeverJob(t *testing.T, server *httptest.Server) *loadJob {
+	t.Helper()
+	cfg := newTestConfig(t, map[string]any{"target_url": server.URL, "concurrency": 2, "forever": true})
+	job := jobs.start(cfg)
+	t.Cleanup(func() {
+		job.cancel()
+		<-job.done
+	})
+	if !waitFor(5*time.Second, func() bool { return job.metrics.TotalRequests.Load() > 0 }) {
+		t.Fatal("ジョブのリクエストが記録されません")
+	}
+	return job
+}
+
+// TestJobStopForever は、forever のジョブが /api/stop/{id} で停止し、それまでの結果を部分レポートとして返すことを確認します。
+func TestJobStopForever(t *testing.T) {
+	server := newTestJobServer(t)
+	job := startForeverJob(t, server)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/stop/{id}", handleJobStop)
+	rec := httptest.NewRecorder()
+	stopped := make(chan struct{})
+	go func() {
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/stop/"+job.id, nil))
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("停止の応答が返りません")
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var st JobStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatalf("レスポンスを解析できません: %v", err)
+	}
+	if st.Status != jobDone || !st.Forever || st.Report == nil {
+		t.Fatalf("status=%s forever=%v report=%v: 停止したジョブの部分レポートが返るはずです", st.Status, st.Forever, st.Report != nil)
+	}
+	if st.Report.TotalRequests == 0 || st.Report.Success != st.Report.TotalRequests || st.Report.ActualDurationSec <= 0 {
+		t.Errorf("total=%d success=%d duration=%v: 停止までの結果が記録されていません",
+			st.Report.TotalRequests, st.Report.Success, st.Report.ActualDurationSec)
+	}
+}
+
+// TestJobStopAllForever は、停止シグナルの受信時に呼ばれる stopAll が、実行中の forever のジョブを停止して
+// 部分レポートがそろうまで待つことを確認します。
+func TestJobStopAllForever(t *testing.T) {
+	server := newTestJobServer(t)
+	job := startForeverJob(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	jobs.stopAll(ctx)
+
+	select {
+	case <-job.done:
+	default:
+		t.Fatal("stopAll から戻った時点でジョブが完了していません")
+	}
+	if job.report.TotalRequests == 0 || job.report.ErrorMsg != "" {
+		t.Errorf("total=%d error_msg=%q: 停止までの結果が記録されていません", job.report.TotalRequests, job.report.ErrorMsg)
+	}
+}
+
+// TestJobRetention は、完了したジョブが jobRetention の経過後に台帳から破棄されることを確認します。
+func TestJobRetention(t *testing.T) {
+	prev := jobRetention
+	jobRetention = 100 * time.Millisecond
+	t.Cleanup(func() { jobRetention = prev })
+
+	server := newTestJobServer(t)
+	r := &jobRegistry{jobs: make(map[string]*loadJob)}
+	job := r.start(newTestConfig(t, map[string]any{"target_url": server.URL, "concurrency": 1, "duration": "50ms"}))
+	<-job.done
+
+	if _, ok := r.get(job.id); !ok {
+		t.Fatal("完了直後のジョブが台帳にありません")
+	}
+	if !waitFor(5*time.Second, func() bool { _, ok := r.get(job.id); return !ok }) {
+		t.Error("jobRetention の経過後もジョブが破棄されません")
+	}
+}
+
+// TestMaxFinishedJobs は、完了したジョブが maxFinishedJobs 件を超えると、完了の古い順に破棄されることを確認します。
+func TestMaxFinishedJobs(t *testing.T) {
+	prevRetention, prevMax := jobRetention, maxFinishedJobs
+	jobRetention, maxFinishedJobs = 0, 2
+	t.Cleanup(func() { jobRetention, maxFinishedJobs = prevRetention, prevMax })
+
+	server := newTestJobServer(t)
+	r := &jobRegistry{jobs: make(map[string]*loadJob)}
+	var started []*loadJob
+	for range 3 {
+		job := r.start(newTestConfig(t, map[string]any{"target_url": server.URL, "concurrency": 1, "duration": "20ms"}))
+		<-job.done
+		started = append(started, job)
+	}
+
+	// 破棄は done のクローズの後に行われるため、反映されるまで待ちます
+	if !waitFor(5*time.Second, func() bool { _, ok := r.get(started[0].id); return !ok }) {
+		t.Error("最も古い完了したジョブが破棄されません")
+	}
+	for _, job := range started[1:] {
+		if _, ok := r.get(job.id); !ok {
+			t.Errorf("上限内のジョブ %s が破棄されました", job.id)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ==============================================================================
+// [セクション63] 並行数の上限: サーバー側の制限と UI との共有 (-max-concurrency, /api/limits)
+// ==============================================================================
+
+// ワーカーは Goroutine とコネクションプールの接続を1つずつ使い、レイテンシのサンプルも並行数に比例して増えるため、
+// 桁を誤った並行数（1000万など）を受け付けると、テストを始めた途端にサーバーのメモリを使い果たしてしまいます。
+// サーバーは -max-concurrency（既定は100000）を超える concurrency・max_in_flight・adaptive_max_concurrency・connections を
+// 400 で拒否します。adaptive_max_concurrency を省略した場合の既定値（concurrency の10倍）も、この上限で頭打ちにします。
+//
+// UI は読み込み時に GET /api/limits でこの上限を取得し、並行ワーカー数の入力欄の最大値・ラベル・ツールチップに反映するため、
+// 上限を超える値はテストを開始する前にブラウザ側で止められます（サーバー側の検査はそのまま残ります）。
+
+// defaultMaxConcurrency は、-max-concurrency を指定しない場合の並行数の上限です。
+const defaultMaxConcurrency = 100000
+
+// maxConcurrency は、テストで受け付ける並行数の上限です（-max-concurrency フラグ）。
+var maxConcurrency = defaultMaxConcurrency
+
+// LimitsResponse は、/api/limits が返すサーバーの制限値です。
+type LimitsResponse struct {
+	MaxConcurrency int `json:"max_concurrency"` // concurrency・max_in_flight・adaptive_max_concurrency の上限
+}
+
+// handleLimits は、サーバーの制限値を返します（UI が入力欄の制約に使います）。
+func handleLimits(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error_msg": "GETメソッドのみ許可されています"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LimitsResponse{MaxConcurrency: maxConcurrency})
+}
+
+// validateConcurrencyLimits は、並行数に関する設定値が -max-concurrency の上限以下であることを検証します。
+func validateConcurrencyLimits(cfg *TestConfig) error {
+	for _, f := range []struct {
+		name  string
+		value int
+	}{
+		{"concurrency", cfg.Concurrency},
+		{"max_in_flight", cfg.MaxInFlight},
+		{"adaptive_max_concurrency", cfg.AdaptiveMaxConcurrency},
+		{"connections", cfg.Connections},
+	} {
+		if f.value > maxConcurrency {
+			return fmt.Errorf("%s がサーバーの上限 (%d) を超えています: %d（上限はサーバーの -max-concurrency で変更できます）", f.name, maxConcurrency, f.value)
+		}
+	}
+	return nil
+}
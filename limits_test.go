@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestAPILimits は、GET /api/limits が -max-concurrency で設定した上限を返し、GET 以外のメソッドを拒否することを確認します。
+func TestAPILimits(t *testing.T) {
+	saved := maxConcurrency
+	t.Cleanup(func() { maxConcurrency = saved })
+	maxConcurrency = 500
+
+	rec := httptest.NewRecorder()
+	handleLimits(rec, httptest.NewRequest(http.MethodGet, "/api/limits", nil))
+	var limits LimitsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &limits); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s: %v", rec.Code, rec.Body.String(), err)
+	}
+	if limits.MaxConcurrency != 500 || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("/api/limits = %+v (Content-Type: %s), want max_concurrency 500", limits, rec.Header().Get("Content-Type"))
+	}
+
+	rec = httptest.NewRecorder()
+	handleLimits(rec, httptest.NewRequest(http.MethodPost, "/api/limits", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /api/limits の status = %d, want 405", rec.Code)
+	}
+}
+
+// TestConcurrencyLimit は、/api/limits が返す上限と同じ値で、並行数に関する各設定を上限まで受け付けて、
+// 上限を超えると 400 で拒否することを確認します。
+func TestConcurrencyLimit(t *testing.T) {
+	saved := maxConcurrency
+	t.Cleanup(func() { maxConcurrency = saved })
+	maxConcurrency = 500
+
+	if cfg, rec := postConfig(t, `{"target_url":"http://127.0.0.1:1","concurrency":500}`, false); cfg == nil {
+		t.Errorf("上限と同じ concurrency が拒否されました: %s", rec.Body.String())
+	}
+	for _, field := range []string{"concurrency", "connections"} {
+		body := `{"target_url":"http://127.0.0.1:1","` + field + `":501}`
+		if cfg, rec := postConfig(t, body, false); cfg != nil || rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "500") {
+			t.Errorf("%s=501: status = %d, body = %s", field, rec.Code, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+}
+
+// TestUIRespectsLimits は、UI が読み込み時に /api/limits の上限を取得して並行ワーカー数の入力欄に反映し、
+// 上限を超える値ではテストを開始しないことを、配信する HTML で確認します。
+func TestUIRespectsLimits(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleUI(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	html := rec.Body.String()
+	for _, want := range []string{
+		`fetch('/api/limits')`,
+		`maxConcurrency = limits.max_concurrency;`,
+		`input.max = maxConcurrency;`,
+		`input.title = "サーバーの上限 (-max-concurrency): "`,
+		`if (!concurrencyWithinLimit()) {`,
+		`max="` + strconv.Itoa(defaultMaxConcurrency) + `"`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("UI の HTML に %q が含まれていません", want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ==============================================================================
+// [セクション26] ログファイル出力とサイズによるローテーション (-log-file)
+// ==============================================================================
+
+// 常時稼働のサーバーとして運用する場合、標準エラー出力へのログは扱いにくくなります。
+// -log-file を指定すると、ログをファイルへ書き込み、-log-max-size-mb を超えた時点でローテーションします。
+// ローテーションしたファイルは path.1（最新）〜 path.N（最古）として -log-max-backups 世代まで保持し、それより古いものは削除します。
+// 指定しない場合は、従来どおり標準エラー出力へ書き込みます。
+
+// rotatingFile は、サイズが上限に達するとローテーションする io.Writer です。
+// log パッケージから並行して呼ばれるため、書き込みは mu で直列化します。
+// 1回の書き込み（ログ1行）が途中で分割されることはありません。
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// openRotatingFile は、path を追記モードで開いた rotatingFile を返します。
+// maxBytes が0以下の場合はローテーションしません。
+func openRotatingFile(path string, maxBytes int64, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open は、ログファイルを追記モードで開き、現在のサイズを取得します。
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file, r.size = f, info.Size()
+	return nil
+}
+
+// Write は、必要に応じてローテーションしてからログを書き込みます。
+// 1行が上限より大きい場合でも、空のファイルには書き込みます（ローテーションを繰り返さないため）。
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			// ローテーションに失敗してもログを失わないよう、現在のファイルへの書き込みを続けます
+			fmt.Fprintf(os.Stderr, "[Log Error] ログファイルのローテーションに失敗しました: %v\n", err)
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate は、現在のファイルを path.1 へ、既存の path.i を path.i+1 へずらし、新しいファイルを開きます。
+// どのファイルも開けなかった場合は、ログを失わないよう標準エラー出力へ切り替えます。
+func (r *rotatingFile) rotate() error {
+	if r.file != os.Stderr {
+		if err := r.file.Close(); err != nil {
+			// 閉じられなかったファイルは使えないため、元のパスを開き直します
+			return r.reopenAfter(err)
+		}
+	}
+	if r.maxBackups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxBackups))
+		for i := r.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return r.reopenAfter(err)
+		}
+	} else if err := os.Truncate(r.path, 0); err != nil {
+		return r.reopenAfter(err)
+	}
+	if err := r.open(); err != nil {
+		return r.fallBack(err)
+	}
+	return nil
+}
+
+// reopenAfter は、ローテーションの途中で失敗した場合に元のファイルを開き直し、err を返します。
+// 開き直せなかった場合は、標準エラー出力へ切り替えます。
+func (r *rotatingFile) reopenAfter(err error) error {
+	if openErr := r.open(); openErr != nil {
+		return r.fallBack(errors.Join(err, openErr))
+	}
+	return err
+}
+
+// fallBack は、ログファイルを開けなかった場合に書き込み先を標準エラー出力へ切り替え、err に切り替えたことを添えて返します。
+// 次にサイズの上限に達した時点で、ローテーションとしてログファイルを開き直します。
+func (r *rotatingFile) fallBack(err error) error {
+	r.file, r.size = os.Stderr, 0
+	return fmt.Errorf("%w（以降のログは標準エラー出力へ書き込みます）", err)
+}
+
+// Close は、ログファイルを閉じます。書き込みはバッファリングしていないため、閉じる前のログはすべてファイルに残ります。
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == os.Stderr {
+		return nil
+	}
+	return r.file.Close()
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRotatingFileRotates は、ログがファイルへ書き込まれ、サイズの上限を超える書き込みの前にローテーションして、
+// path.1 が直前のファイル、path.N より古い世代が削除されることを確認します。
+func TestRotatingFileRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ultraload.log")
+	r, err := openRotatingFile(path, 100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	logger := log.New(r, "", 0)
+
+	// 1行は 40 バイトのため、2行ごとに上限の 100 バイトに収まらなくなります
+	line := func(i int) string { return strings.Repeat(string(rune('a'+i)), 39) }
+	for i := range 7 {
+		logger.Println(line(i))
+	}
+
+	read := func(name string) string {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("%s を読めません: %v", filepath.Base(name), err)
+		}
+		return string(b)
+	}
+	for _, tt := range []struct {
+		name  string
+		lines []int
+	}{
+		{path, []int{6}},
+		{path + ".1", []int{4, 5}},
+		{path + ".2", []int{2, 3}},
+	} {
+		var want string
+		for _, i := range tt.lines {
+			want += line(i) + "\n"
+		}
+		if got := read(tt.name); got != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(tt.name), got, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 が残っています: 最古の世代が削除されていません (err=%v)", filepath.Base(path), err)
+	}
+}
+
+// TestRotatingFileAppends は、既存のログファイルに追記し、既存のサイズを含めてローテーションの要否を判断することを確認します。
+func TestRotatingFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ultraload.log")
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", 90)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := openRotatingFile(path, 100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	io.WriteString(r, "new line\n")
+	io.WriteString(r, "next line\n")
+
+	if b, _ := os.ReadFile(path + ".1"); string(b) != strings.Repeat("x", 90)+"\nnew line\n" {
+		t.Errorf("%s.1 = %q: 既存の内容に追記されていません", filepath.Base(path), b)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "next line\n" {
+		t.Errorf("%s = %q, want \"next line\\n\"", filepath.Base(path), b)
+	}
+}
+
+// TestRotatingFileFallsBackToStderr は、ローテーションの後にログファイルを開けない場合に、ログを失わないよう
+// 標準エラー出力へ書き込みを続けることを確認します。
+func TestRotatingFileFallsBackToStderr(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ultraload.log")
+	r, err := openRotatingFile(path, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(r, "first line\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	// ログファイルを同じ名前のディレクトリに置き換えて、切り詰めも開き直しも失敗させます
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	stderr := filepath.Join(t.TempDir(), "stderr")
+	f, err := os.Create(stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	orig := os.Stderr
+	os.Stderr = f
+	_, err = io.WriteString(r, "second line\n")
+	closeErr := r.Close()
+	os.Stderr = orig
+
+	if err != nil {
+		t.Fatalf("ログファイルを開けない場合の書き込みが失敗しました: %v", err)
+	}
+	if closeErr != nil {
+		t.Errorf("標準エラー出力へ切り替えた後の Close = %v, want nil", closeErr)
+	}
+	b, _ := os.ReadFile(stderr)
+	if !strings.Contains(string(b), "second line\n") || !strings.Contains(string(b), "[Log Error]") {
+		t.Errorf("標準エラー出力 = %q: ローテーションの失敗とログが書き込まれていません", b)
+	}
+}
@@ -4,13 +4,23 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
 	"os"
 	"os/signal"
-	"sort"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -27,28 +37,597 @@ type TestConfig struct {
 	TargetURL   string `json:"target_url"`  // 攻撃対象の完全なURL
 	Method      string `json:"method"`      // HTTPメソッド (GET, POST等)
 	Concurrency int    `json:"concurrency"` // 同時実行数 (例: 10000)
-	DurationSec int    `json:"duration"`    // 実行時間（秒）
 	TimeoutSec  int    `json:"timeout"`     // リクエストタイムアウト（秒）
+
+	// Duration は実行時間です。秒数（10）または時間文字列（"500ms"）で指定します（duration.go を参照）。
+	Duration configDuration `json:"duration"`
+
+	// ExpectedRPSPerWorker は、レイテンシ記録用スライスを事前確保するための「1ワーカーあたりの想定RPS」です。
+	// 0以下の場合は defaultExpectedRPSPerWorker が使用されます。
+	ExpectedRPSPerWorker int `json:"expected_rps_per_worker"`
+
+	// Mode は負荷テストのプロトコルです。"http"（デフォルト）または "ws"（WebSocket）を指定します。
+	Mode string `json:"mode"`
+
+	// WebSocketモード専用の設定
+	WSMessage    string `json:"ws_message"`     // 各接続が送信するメッセージ（サーバーがエコーする前提）
+	WSIntervalMs int    `json:"ws_interval_ms"` // 送信間隔（ミリ秒）。0の場合はエコー受信後ただちに次を送信
+
+	// ソケットレベルのチューニング（低レイテンシ計測・超高RPS向けの上級者向け設定）
+	TCPNoDelay *bool `json:"tcp_nodelay"` // Nagleアルゴリズムの無効化。未指定時は true（Goのデフォルト）
+	SockRcvBuf int   `json:"sock_rcvbuf"` // SO_RCVBUF（バイト）。0の場合はOSのデフォルト
+	SockSndBuf int   `json:"sock_sndbuf"` // SO_SNDBUF（バイト）。0の場合はOSのデフォルト
+
+	// TCPKeepAlive は、TCPキープアライブの無通信時間とプローブ間隔です（"30s" または秒数。0の場合はGoの既定値の15秒、負の値で無効）。
+	// これはOSが無通信の接続に送る生存確認のパケットで、1つの接続で複数のリクエストを送る HTTP の Keep-Alive
+	// （接続の再利用。常に有効です）とは別物です。長時間無通信になる接続（WebSocket など）が途中の NAT や
+	// ロードバランサーに切断されないかを検証する場合に調整します。
+	TCPKeepAlive configDuration `json:"tcp_keepalive"`
+
+	// Transport の各段階のタイムアウトです（"30s" または秒数。省略した場合はサーバーのフラグの既定値。timeouts.go を参照）。
+	IdleConnTimeout       configDuration `json:"idle_conn_timeout"`
+	TLSHandshakeTimeout   configDuration `json:"tls_handshake_timeout"`
+	ResponseHeaderTimeout configDuration `json:"response_header_timeout"`
+	ExpectContinueTimeout configDuration `json:"expect_continue_timeout"`
+
+	// DNSServer を指定すると、OSのリゾルバーを経由せず、このDNSサーバー（"8.8.8.8:53"。ポート省略時は53番）へ
+	// 直接問い合わせてターゲットのホスト名を解決します（dns.go を参照）。
+	DNSServer string `json:"dns_server"`
+
+	// SpreadIPs を指定すると、ターゲットのホスト名を1度だけ解決し、新規接続を解決した全アドレスへラウンドロビンで
+	// 割り当てます。アドレスごとのリクエスト数をレポートの requests_by_ip に記録します（HTTPモードのみ。spreadips.go を参照）。
+	SpreadIPs bool `json:"spread_ips"`
+
+	// MaxResponseBytes は、1レスポンスあたりに読み込むボディの上限（バイト）です。
+	// 超過したレスポンスは "response_too_large" エラーとして記録されます。0の場合は無制限（従来どおり）ですが、
+	// 巨大なレスポンスを返し続けるターゲットからテスターを守るため、設定を推奨します。
+	MaxResponseBytes int64 `json:"max_response_bytes"`
+
+	// TLSバージョンと暗号スイートの固定（レガシーなエンドポイントの検証や、TLS設定の堅牢性確認用）
+	// 暗号スイートは TLS 1.0〜1.2 にのみ適用されます（TLS 1.3 のスイートはGoの仕様上変更できません）。
+	TLSMinVersion   string   `json:"tls_min_version"`   // "1.0" / "1.1" / "1.2" / "1.3"。未指定時はGoのデフォルト
+	TLSMaxVersion   string   `json:"tls_max_version"`   // 同上
+	TLSCipherSuites []string `json:"tls_cipher_suites"` // 例: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"（安全でないスイートも指定可能）
+
+	// クライアント証明書（mTLS）。サーバーのローカルファイルシステム上のPEMファイルのパスを指定します。
+	ClientCert string `json:"client_cert"` // 証明書（チェーン）のPEMファイル
+	ClientKey  string `json:"client_key"`  // 秘密鍵のPEMファイル
+
+	// 適応型負荷モード。Concurrency を初期値として、エラー率とレイテンシが許容範囲内にある間はワーカーを増やし、
+	// 悪化したら減らすことで、ターゲットが持続的に捌ける並行数の限界を探り続けます。
+	Adaptive                bool    `json:"adaptive"`
+	AdaptiveMaxConcurrency  int     `json:"adaptive_max_concurrency"`   // 並行数の上限（未指定時は Concurrency の10倍）
+	AdaptiveTargetErrorRate float64 `json:"adaptive_target_error_rate"` // 許容するエラー率（0〜1、未指定時は 0.01）
+	AdaptiveMaxP99Ms        int     `json:"adaptive_max_p99_ms"`        // 許容する区間内のp99レイテンシ（ミリ秒、0の場合は判定に使いません）
+	AdaptiveStep            int     `json:"adaptive_step"`              // 1回の調整で増やすワーカー数（未指定時は Concurrency の10%、最低1）
+	AdaptiveIntervalSec     int     `json:"adaptive_interval_sec"`      // 調整間隔（秒、未指定時は 2）
+
+	// Forever を指定すると、Duration を無視して停止されるまで（/api/stop または Ctrl+C）実行し続けます。
+	// HTTPリクエストを無期限にブロックできないため、/api/start によるジョブとしてのみ実行できます。
+	Forever bool `json:"forever"`
+
+	// レート制御（HTTPモードのみ）。全体の目標レートを並行数で均等に割り、各ワーカーが一定間隔で送信します。
+	RateLimit  float64 `json:"rate_limit"`  // 全体の目標レート（リクエスト/秒、0の場合は無制限）
+	RateJitter float64 `json:"rate_jitter"` // 送信タイミングに加えるジッターの大きさ（0〜1、送信間隔に対する比率）
+
+	// NoPreflight を指定すると、テスト開始前の疎通確認（プリフライト）を省略します。
+	NoPreflight bool `json:"no_preflight"`
+
+	// 途中経過のスナップショット（RPS・エラー率・直近区間のp99）を作成する間隔（秒、0の場合は作成しません）
+	ReportIntervalSec int `json:"report_interval_sec"`
+	// Quiet を指定すると、途中経過のスナップショットをログへ出力しません（レポートには記録されます）
+	Quiet bool `json:"quiet"`
+
+	// MaxSamples は、メモリに保持するレイテンシのサンプル数の上限です（0の場合は無制限）。
+	// 上限に達した後はリザーバーサンプリングに切り替わり、パーセンタイルの統計的な代表性を保ったまま
+	// メモリ使用量を一定に抑えます。数時間に及ぶソークテストで設定してください。
+	MaxSamples int `json:"max_samples"`
+
+	// Percentiles は、p50/p90/p99 に加えて算出するパーセンタイル（%）です（未指定時は 99.9 と 99.99）。
+	// サンプル数が足りない場合は、レポートの percentile_warnings に警告が記録されます（percentiles.go を参照）。
+	Percentiles []float64 `json:"percentiles"`
+
+	// SlowThreshold を超えて応答したリクエストを slow_responses として数えます（"2s" または秒数。HTTPモードのみ）。
+	// SlowIsError を指定すると、そのうち本来は成功になる応答を slow_response エラーとして記録します（slow.go を参照）。
+	SlowThreshold configDuration `json:"slow_threshold"`
+	SlowIsError   bool           `json:"slow_is_error"`
+
+	// ApdexTarget は、Apdex スコアの目標レイテンシ T です（"200ms" または秒数。指定した場合のみレポートに apdex を記録します）。
+	ApdexTarget configDuration `json:"apdex_target"`
+
+	// IsolatedClients を指定すると、全ワーカーで1つのコネクションプールを共有する代わりに、
+	// ワーカーごとに専用のHTTPクライアント（トランスポートとコネクションプール）を持たせます。
+	// 「N人の独立した利用者」に近い接続パターンになりますが、共有プールでの使い回しが効かない分
+	// ソケット数（ファイルディスクリプタ）とTLSハンドシェイクの回数が増えます。closedモデルのみ対応です。
+	IsolatedClients bool `json:"isolated_clients"`
+
+	// Connections は、1ホストあたりに張る接続の上限です。ワーカー数（concurrency）とは別に、コネクションプールの大きさを指定します
+	// （0の場合はワーカー数の2倍。connections.go を参照）。
+	Connections int `json:"connections"`
+
+	// PrewarmConnections を指定すると、計測を始める前に並行数と同じ数の接続を確立してコネクションプールに残しておき、
+	// 計測中のリクエストがハンドシェイクの費用を含まないようにします（HTTPモードのみ。prewarm.go を参照）。
+	PrewarmConnections bool `json:"prewarm_connections"`
+
+	// HTTP3 を指定すると、HTTP/3 (QUIC) でターゲットと通信します（https:// のターゲットのみ。http3.go を参照）。
+	HTTP3 bool `json:"http3"`
+
+	// ExcludeColdStart を指定すると、各ワーカーの最初のリクエストのレイテンシをレイテンシ統計から除外し、
+	// cold_start_latency として別に集計します（HTTPのクローズドモデルのみ。coldstart.go を参照）。
+	ExcludeColdStart bool `json:"exclude_cold_start"`
+
+	// FailFast を指定すると、最初にエラーになったリクエストでテスト全体を停止し、その詳細をレポートに記録します
+	// （スモークテスト向け。HTTPモードのみ。failfast.go を参照）。
+	FailFast bool `json:"fail_fast"`
+
+	// MinRPSFloor を指定すると、直近の RPS がこの値を MinRPSGrace（既定は10秒）の間下回り続けた時点でテストを停止し、
+	// レポートの aborted_reason に "throughput_floor" を記録します（ソークテスト向け。throughputfloor.go を参照）。
+	MinRPSFloor float64        `json:"min_rps_floor"`
+	MinRPSGrace configDuration `json:"min_rps_grace"`
+
+	// TrafficShape を指定すると、rate_limit を基準に目標レートを時間とともに変化させます（"sine" または "burst"。
+	// HTTPモードのみ。trafficshape.go を参照）。ShapeAmplitude は sine の振れ幅、ShapeBurstFactor・ShapeBurstLength は
+	// burst の倍率と長さです。
+	TrafficShape     string         `json:"traffic_shape"`
+	ShapePeriod      configDuration `json:"shape_period"`
+	ShapeAmplitude   float64        `json:"shape_amplitude"`
+	ShapeBurstFactor float64        `json:"shape_burst_factor"`
+	ShapeBurstLength configDuration `json:"shape_burst_length"`
+
+	// RedirectsAreErrors を指定すると、3xx（リダイレクト）を成功ではなく "redirect" エラーとして記録します。
+	// リダイレクトが発生しないはずのAPIで、設定ミス（http→https や末尾スラッシュの転送など）を検出するためのものです。
+	RedirectsAreErrors bool `json:"redirects_are_errors"`
+
+	// ExportPath を指定すると、テスト終了後にレポートと全レイテンシサンプルをコンパクトなバイナリ形式で
+	// サーバーのローカルファイルシステムへ書き出します（-import で再集計・統合できます）。
+	ExportPath string `json:"export_path"`
+
+	// LatencyHistogram を指定すると、レイテンシを Prometheus の標準のバケット境界で数えた累積ヒストグラムをレポートに記録します。
+	// LatencyHistogramPath を指定すると、同じ内容を Prometheus のテキスト形式でサーバーのローカルファイルへ書き出します（histogram.go を参照）。
+	LatencyHistogram     bool   `json:"latency_histogram"`
+	LatencyHistogramPath string `json:"latency_histogram_path"`
+
+	// CacheBust は、キャッシュを回避するためにリクエストごとに一意な値を付与する方式です。
+	// "query"（クエリパラメーター _cb）/ "header"（no-cache ヘッダーと X-Cache-Bust）。未指定の場合は付与しません。
+	CacheBust string `json:"cache_bust"`
+
+	// Targets は、複数のエンドポイントへ負荷を振り分ける場合の送信先一覧です（HTTPのクローズドモデル専用）。
+	// 指定した場合、target_url は省略でき（省略時は先頭のターゲット）、各ワーカーは送信ごとに重みに応じてターゲットを選びます。
+	Targets []TargetSpec `json:"targets"`
+
+	// HonorRetryAfter を true にすると、429 を受け取ったワーカーは Retry-After が示す時間だけ次の送信を控えます（HTTPのクローズドモデル専用）。
+	HonorRetryAfter bool `json:"honor_retry_after"`
+
+	// 認証付きターゲット向けの設定。auth_type は "basic"（常に資格情報を付与）または "digest"（401 のチャレンジに応答して再送）です。
+	AuthType     string `json:"auth_type"`
+	AuthUser     string `json:"auth_user"`
+	AuthPassword string `json:"auth_password"`
+
+	// HMACKey を指定すると、各リクエストに正規化文字列の HMAC-SHA256 署名とタイムスタンプのヘッダーを付与します（HTTPモードのみ）。
+	// 省略した項目はサーバーの -hmac-key・-hmac-header・-hmac-canonical フラグの値を使います（hmacsign.go を参照）。
+	HMACKey             string `json:"hmac_key"`
+	HMACHeader          string `json:"hmac_header"`
+	HMACTimestampHeader string `json:"hmac_timestamp_header"`
+	HMACCanonical       string `json:"hmac_canonical"`
+	HMACEncoding        string `json:"hmac_encoding"`
+
+	// Tags は、テストの整理に使う任意のラベル（例: environment=staging）です。そのままレポートに記録されます。
+	Tags map[string]string `json:"tags"`
+
+	// Body は、各リクエストで送信するボディです。Form は、URLエンコードしてボディにするキーと値です（body と同時には指定できません）。
+	// ContentType は付与する Content-Type で、省略時は body なら -content-type の値（既定は application/json）、
+	// form なら application/x-www-form-urlencoded を付与します（HTTPモードのみ。body.go を参照）。
+	Body        string            `json:"body"`
+	Form        map[string]string `json:"form"`
+	ContentType string            `json:"content_type"`
+
+	// Headers は、各リクエストに付与するヘッダーです（"Host" は送信先の Host を上書きします。HTTPモードのみ。body.go を参照）。
+	// 同じ名前のヘッダーは、content_type や認証のヘッダーより優先します。
+	Headers map[string]string `json:"headers"`
+
+	// GenBodySize を指定すると、そのサイズのボディを生成して各リクエストで送信します（数値のバイト数、または "1MB" などの文字列）。
+	// GenBodyFill は中身（random（既定）・zero・pattern）、GenBodyPattern は pattern で繰り返す文字列です（genbody.go を参照）。
+	GenBodySize    byteSize `json:"gen_body_size"`
+	GenBodyFill    string   `json:"gen_body_fill"`
+	GenBodyPattern string   `json:"gen_body_pattern"`
+
+	// Query は、ターゲットURL（targets の各URLを含みます）に追加するクエリパラメーターです。
+	// サーバーの -q フラグで指定したパラメーターも追加され、同じキーはこちらが優先されます（query.go を参照）。
+	Query map[string]string `json:"query"`
+
+	// Seed は、テスト中のすべての乱数（ターゲットとリクエストの選択、送信タイミングのジッター、注入する遅延、
+	// random の生成ボディ、サンプリングの置き換えなど）の元になるシード値です。
+	// 各ワーカーの乱数生成器は「シード値 + ワーカー番号」から決定的に生成されるため、同じシード値を指定すれば
+	// ツール側の選択は再現されます（ただし、ターゲット側の応答時間などの揺らぎは再現されません）。
+	// 未指定の場合はランダムなシード値を使用し、レポートの seed に記録します。
+	Seed *uint64 `json:"seed"`
+
+	// 負荷モデル。"closed"（既定）は各ワーカーが応答を受け取ってから次を送信し、"open" は応答を待たずに
+	// rate_limit の到着レートでリクエストを発生させ続けます（ターゲットが遅延しても送信ペースは落ちません）。
+	LoadModel      string `json:"load_model"`
+	MaxInFlight    int    `json:"max_in_flight"`   // openモードで同時に通信中にできるリクエスト数の上限（未指定時は concurrency）
+	OverloadPolicy string `json:"overload_policy"` // 上限到達時の動作: "drop"（既定、送信せず skipped_overload として数える）/ "block"（空きが出るまで待つ）
+
+	// RampDownSec は、実行時間の最後にワーカー数を段階的に0まで減らすクールダウン期間（秒）です。
+	// 実行時間に含まれます。0の場合は、実行時間の経過とともに全ワーカーを一斉に停止します。
+	RampDownSec int `json:"ramp_down_sec"`
+
+	// MaxRPS は、全ワーカー合計の送信レートの安全上限（リクエスト/秒）です（HTTPモードのみ）。
+	// rate_limit と異なり、上限を超えそうな場合にだけ送信を待たせ、それ以外は全力で送信します。0の場合は無制限です。
+	MaxRPS float64 `json:"max_rps"`
+
+	// CaptureSamples は、ヘッダーを捕捉してレポートに記録するリクエストの件数です（最初の N 件。HTTPモードのみ、0の場合は捕捉しません）。
+	CaptureSamples int `json:"capture_samples"`
+
+	// TopSlowest は、最も時間のかかったリクエストを所要時間の降順でレポートに記録する件数です（HTTPモードのみ、0の場合は記録しません）。
+	// TopSlowestHeaders を指定すると、それらのレスポンスヘッダーも記録します（slowest.go を参照）。
+	TopSlowest        int  `json:"top_slowest"`
+	TopSlowestHeaders bool `json:"top_slowest_headers"`
+
+	// DialConcurrency は、同時に進行する接続確立（TCPハンドシェイク）の数の上限です（0の場合は無制限）。
+	DialConcurrency int `json:"dial_concurrency"`
+
+	// ConnectTimeout は、接続の確立（名前解決と TCP のハンドシェイク）のタイムアウトです（0の場合は timeout のみが上限）。
+	// WarmupPeriod を指定すると、テストの開始からその時間の間は WarmupConnectTimeout を使います（connectphase.go を参照）。
+	ConnectTimeout       configDuration `json:"connect_timeout"`
+	WarmupConnectTimeout configDuration `json:"warmup_connect_timeout"`
+	WarmupPeriod         configDuration `json:"warmup_period"`
+
+	// TraceOut を指定すると、すべてのリクエストの送信時刻・所要時間・ステータスを NDJSON として
+	// サーバーのローカルファイルシステムへ書き出します（trace.go を参照）。
+	TraceOut string `json:"trace_out"`
+
+	// MaxRequestsPerConn は、1接続あたりのリクエスト数の上限です（HTTPモードのみ、0の場合は無制限）。
+	// N 件に1件のリクエストで接続を切断させ、接続の確立・切断の負荷を再現します（churn.go を参照）。
+	MaxRequestsPerConn int `json:"max_requests_per_conn"`
+
+	// NoDrainBody を指定すると、レスポンスボディを読まずに閉じ、TTFB を記録します（HTTPモードのみ）。
+	// 大きなレスポンスでは接続が再利用されなくなるため、スループットは大きく低下します（timing.go を参照）。
+	NoDrainBody bool `json:"no_drain_body"`
+
+	// Trace を指定すると、すべてのリクエストについて TTFB とボディの読み終わりまでの時間を記録し、
+	// それぞれの分布を報告します（HTTPモードのみ。リクエストごとにトレースを仕込むため、わずかに負荷が増えます）。
+	Trace bool `json:"trace"`
+
+	// RequestFile を指定すると、1行1リクエストで定義したファイルのリクエストを再生します（HTTPのクローズドモデル専用）。
+	// RequestOrder は送信順序で、"sequential"（既定、先頭から順に）または "random" です（replay.go を参照）。
+	RequestFile  string `json:"request_file"`
+	RequestOrder string `json:"request_order"`
+
+	// ReplayTrace を指定すると、トレースファイル（trace_out と同じ NDJSON）に記録された時刻どおりにリクエストを送信します。
+	// ReplaySpeed は再生の倍率です（既定は1。2 なら2倍の速さ）。HTTPモード専用です（replaytrace.go を参照）。
+	ReplayTrace string  `json:"replay_trace"`
+	ReplaySpeed float64 `json:"replay_speed"`
+
+	// CompareTargetURL を指定すると、target_url と同じ設定のテストをこのURLにも実行し、結果の差分をレポートの comparison に記録します。
+	// CompareMode は実行方法で、"concurrent"（既定、同時に実行）または "sequential"（順に実行）です（compare.go を参照）。
+	CompareTargetURL string `json:"compare_target_url"`
+	CompareMode      string `json:"compare_mode"`
+
+	// AssertJSON は、2xx のレスポンスのJSONボディに対するアサーション（"$.status=ok" の形式）の一覧です（HTTPモードのみ）。
+	// 一致しないリクエストはエラー種別 json_assertion の失敗として記録します（assertjson.go を参照）。
+	AssertJSON []string `json:"assert_json"`
+
+	// InjectLatency は、各リクエストの送信直前に注入する人為的な遅延です（遠隔地のクライアントのRTTを模します。HTTPモードのみ）。
+	// InjectLatencyJitter を指定すると、遅延に ±jitter の一様なばらつきを加えます。注入した遅延はレイテンシに含まれます（inject.go を参照）。
+	InjectLatency       configDuration `json:"inject_latency"`
+	InjectLatencyJitter configDuration `json:"inject_latency_jitter"`
+
+	// warnings は、設定の読み込み時に自動補正した内容など、レポートで利用者に伝える注意事項です。
+	warnings []string
+
+	// dialGate は、テスト中の全ダイヤラーで共有する接続確立の制限と集計です（runLoadTest が設定します）。
+	dialGate *dialGate
+
+	// dns は、テスト中の全ダイヤラーで共有する名前解決の集計です（runLoadTest が設定します）。
+	dns *dnsStats
+
+	// certRequest は、クライアント証明書なしのテストでサーバーが証明書を要求したかどうかです（runLoadTest が設定します）。
+	certRequest *clientCertRequest
+
+	// ipSpread は、spread_ips の指定時に全ダイヤラーで共有する、接続先アドレスの割り当てです（runLoadTest が設定します）。
+	ipSpread *ipSpreader
+
+	// hostConns は、複数ターゲットモードのホストごとのプールで、ダイヤラーが確立した接続の集計です（hostpool.go を参照。nil の場合は集計しません）。
+	hostConns *hostConnStats
+
+	// churn は、max_requests_per_conn に従って切断させるリクエストを選びます（runLoadTest が設定します。nil の場合は切断させません）。
+	churn *connChurn
+
+	// inject は、inject_latency に従って送信前に注入する遅延です（runLoadTest が設定します。nil の場合は注入しません）。
+	inject *latencyInjector
+
+	// failFast は、fail_fast が指定された場合に最初のエラーでテストを停止させます（runLoadTest が設定します）。
+	failFast *failFast
+
+	// requestSpecs は、request_file から読み込んだリクエストの定義です（readTestConfig が設定します）。
+	requestSpecs []requestSpec
+
+	// signer は、hmac_key の指定時にリクエストへ署名を付与します（readTestConfig が設定します。nil の場合は署名しません）。
+	signer *hmacSigner
+
+	// genBody は、gen_body_size の指定時に送信するボディのブロックです（readTestConfig が生成し、runLoadTest がテストごとの集計を付けます）。
+	genBody *genBody
+
+	// assertions は、assert_json を解釈したアサーションです（readTestConfig が設定します）。
+	assertions []jsonAssertion
+
+	// traceReplay は、replay_trace から読み込んだトレースです（readTestConfig が設定します。nil の場合は再生しません）。
+	traceReplay *traceReplay
+
+	// replay は、request_file のリクエストを再生する送信先です（runLoadTest が設定します。nil の場合は target_url へ送信します）。
+	replay *requestReplay
+
+	// pause は、ジョブの一時停止の状態です（jobRegistry.start が設定します。nil の場合は一時停止できません。pause.go を参照）。
+	// 比較ターゲットの A と B は設定の複製を使いますが、同じ状態を共有するため、一緒に一時停止します。
+	pause *pauseState
+}
+
+// 負荷テストのプロトコル（TestConfig.Mode）
+const (
+	modeHTTP      = "http"
+	modeWebSocket = "ws"
+)
+
+// defaultExpectedRPSPerWorker は、ExpectedRPSPerWorker が未指定の場合に使われる1ワーカーあたりの想定RPSです。
+const defaultExpectedRPSPerWorker = 100
+
+// maxPreallocSamples は、レイテンシ記録用スライスを事前確保する件数の上限です（約400万件 = 32MB）。
+// 並行数10万 × 60秒のような設定では推定値が数億件（数GB）になり、テスト開始前にメモリ不足で落ちてしまうためです。
+// 上限を超えて記録される場合は、スライスの自動拡張に任せます（実際に記録された分だけメモリを使います）。
+const maxPreallocSamples = 4 << 20
+
+// estimateTotalRequests は、メモリ事前割り当てのための推定総リクエスト数を計算します。
+// (並行数 * 1ワーカーあたりの想定RPS * 秒数) で大まかなキャパシティを算出し、maxPreallocSamples で頭打ちにします。
+func estimateTotalRequests(cfg *TestConfig) int {
+	expectedRPS := cfg.ExpectedRPSPerWorker
+	if expectedRPS <= 0 {
+		expectedRPS = defaultExpectedRPSPerWorker
+	}
+
+	// forever の場合は実行時間が決まらないため、Duration（既定値）分だけ確保し、以降はスライスの自動拡張に任せます
+	// 極端な設定値の掛け算で int があふれないよう、浮動小数点数で見積もってから上限と比較します
+	estimated := float64(cfg.Concurrency) * float64(expectedRPS) * cfg.Duration.Seconds()
+	if cfg.LoadModel == loadModelOpen {
+		// オープンモデルでは到着レートが決まっているため、より正確に見積もれます
+		estimated = cfg.RateLimit * cfg.Duration.Seconds()
+	}
+	if cfg.traceReplay != nil {
+		// トレースの再生では、送信するリクエスト数がファイルから分かります
+		estimated = float64(len(cfg.traceReplay.events))
+	}
+	// サンプル数の上限を超えて確保しても使われないため、上限で頭打ちにします
+	if cfg.MaxSamples > 0 && estimated > float64(cfg.MaxSamples) {
+		estimated = float64(cfg.MaxSamples)
+	}
+	if estimated > maxPreallocSamples {
+		estimated = maxPreallocSamples
+	}
+	if estimated <= 0 {
+		return 10000 // フォールバック値
+	}
+	return int(estimated)
 }
 
 // ResultMetrics は、テストの実行結果を集約・保持するための構造体です。
 // 10万RPS環境下で数万のGoroutineが同時に結果を書き込んでもロック競合による
 // パフォーマンス低下（スロットリング）を起こさないよう、すべて atomic 操作前提で設計しています。
 type ResultMetrics struct {
-	// uint64を使用し、オーバーフローを防ぐとともにアトミック操作を可能にします
-	TotalRequests uint64
-	SuccessCount  uint64
-	ErrorCount    uint64
+	// すべてのリクエストの完了時に加算するため、加算の競合を避けるシャーディングしたカウンターで数えます（counter.go を参照）
+	TotalRequests shardedCounter
+	SuccessCount  shardedCounter
+	ErrorCount    shardedCounter
 
 	// ステータスコードごとのカウントを安全に記録するための sync.Map
 	// キー: ステータスコード (int), 値: カウンタへのポインタ (*uint64)
 	StatusCodes sync.Map
 
+	// 原因を特定できたエラー（レスポンスサイズ超過、TLSハンドシェイク失敗など）の、エラー種別ごとのカウント
+	// キー: エラー種別 (string), 値: カウンタへのポインタ (*uint64)
+	ErrorKinds sync.Map
+
 	// レイテンシ（応答時間）の記録
 	// 10万RPS × 数十秒のテストでは数百万件のデータになるため、
 	// Mutexによるロックは最小限にし、あらかじめキャパシティを確保したスライスを使用します。
 	mu        sync.Mutex
 	latencies []time.Duration
+
+	// WebSocketモードにおける接続確立時間の記録（接続ごとに1件なので事前確保は不要です）
+	connectLatencies []time.Duration
+
+	// 新規に確立したTLS接続ごとの、ネゴシエートされたバージョンと暗号スイートのカウント
+	// キー: バージョン名 / 暗号スイート名 (string), 値: カウンタへのポインタ (*uint64)
+	TLSVersions     sync.Map
+	TLSCipherSuites sync.Map
+
+	// InFlight は、現在レスポンスを待っているリクエストの数です。
+	// ランプアップ等のスケジューリングが設定どおりに動いたかを検証するため、タイムラインでサンプリングします。
+	InFlight int64
+
+	// PeakInFlight は InFlight の最大値、InFlightNanos は全リクエストの通信時間（送信開始から完了まで）の合計です。
+	// いずれも beginRequest / endRequest が更新します。
+	PeakInFlight  int64
+	InFlightNanos int64
+
+	// 1秒ごとのタイムライン（sampleTimeline が mu で保護して追記します）
+	rpsTimeline         []uint64
+	concurrencyTimeline []int64
+	successRateTimeline []*float64
+	newConnTimeline     []uint64
+	targetRPSTimeline   []float64
+
+	// shape は、traffic_shape の指定時の目標レートです（runLoadTest が設定します。未指定の場合は nil）。
+	shape *trafficShape
+
+	// 区間ごとのレイテンシを集計したい処理（適応型負荷モードなど）が登録したバッファ。
+	// 登録がない通常のテストでは、レイテンシ記録のコストは増えません。
+	windows []*latencyWindow
+
+	// 適応型負荷モードにおける並行数の推移（mu で保護）
+	trajectory []AdaptivePoint
+
+	// SentRequests は、送信を開始したリクエスト（WebSocketモードではメッセージ）の数です。
+	// 完了数ではなく送信数で数えることで、ターゲットへの到着パターン（バースト性）を計測します。
+	SentRequests uint64
+
+	// バースト性の集計結果（sampleBurstiness が mu で保護して設定します）
+	burstiness *BurstStats
+
+	// オープンモデルで通信中の上限に達した回数と、送信を見送ったリクエスト数
+	InFlightCapHits uint64
+	SkippedOverload uint64
+
+	// 途中経過のスナップショット（mu で保護）
+	snapshots []IntervalSnapshot
+
+	// 全件の逐次集計値（mu で保護）。レポートの最小値・平均値・標準偏差・最大値はここから算出するため、
+	// 集計時に全サンプルを走査し直す必要がなく、リザーバーサンプリングの作動後も正確な値を保てます。
+	latencyStats latencyStats
+
+	// リザーバーサンプリング（mu で保護）。maxSamples が0の場合は無効です。
+	// sampleRand は置き換えるサンプルを選ぶ乱数生成器で、runLoadTest がテストのシード値から導出したものに差し替えます。
+	maxSamples     int
+	samplingActive bool
+	sampleRand     *rand.Rand
+
+	// percentiles は、レポートで p50/p90/p99 に加えて算出するパーセンタイルです（runLoadTest が設定します）。
+	percentiles []float64
+	// apdexTarget は、Apdex スコアの目標レイテンシです（0の場合は算出しません）。
+	apdexTarget time.Duration
+	// histogram は、latency_histogram の指定時にレイテンシをバケットごとに数えます（runLoadTest が設定します。mu で保護）。
+	histogram *latencyHistogram
+	// progress は、/api/progress/{id} のパーセンタイルの算出に使う抽出サンプルです（ジョブの場合のみ jobRegistry.start が設定します。
+	// mu で保護。progress.go を参照）。
+	progress *progressReservoir
+
+	// errLog は、ワーカーの初期化の失敗や通信の失敗をログへ出力します。同じエラーは初回だけを出力し、件数をまとめて出力します
+	// （runLoadTest が設定します。errlog.go を参照）。
+	errLog *errorLog
+
+	// ConnectionsOpened は、新規に確立した（プールから再利用しなかった）接続の数です。
+	ConnectionsOpened uint64
+
+	// honor_retry_after が有効な場合の、429 の件数と全ワーカーの待機時間の合計（ナノ秒）
+	RateLimited  uint64
+	BackoffNanos int64
+
+	// TimedOut は、タイムアウトによって応答を受信できなかったリクエストの数です（ErrorCount の内数）。
+	TimedOut uint64
+
+	// SlowResponses は、slow_threshold を超えて応答したリクエストの数です（slow.go を参照）。
+	SlowResponses uint64
+
+	// ConnectionCloseResponses は、ターゲットが Connection: close で接続を閉じると通知した応答の数です（connclose.go を参照）。
+	ConnectionCloseResponses uint64
+
+	// spread_ips の指定時に、接続先アドレスごとのリクエスト数を数えるかどうかと、その件数です（spreadips.go を参照）。
+	recordsIPs   bool
+	RequestsByIP sync.Map
+
+	// capture_samples による捕捉の上限件数と、予約済みの件数（アトミックに更新）。捕捉したやり取りは mu で保護します。
+	captureLimit int64
+	captured     int64
+	exchanges    []SampleExchange
+
+	// slowest は、top_slowest が指定されている場合に最も遅かったリクエストを保持します（runLoadTest が設定します）。
+	slowest *slowestTracker
+
+	// trace は、trace_out が指定されている場合の全リクエストの書き出し先です（nil の場合は書き出しません）。
+	trace *traceWriter
+
+	// sink は、サーバーに外部メトリクスシンク（-statsd-addr）が設定されている場合の送信先です（nil の場合は送信しません）。
+	sink MetricsSink
+
+	// 終了したワーカーごとの完了リクエスト数（mu で保護）
+	workerCounts []uint64
+
+	// レスポンスの最初の1バイトまでの時間（TTFB を記録する設定の場合のみ。mu で保護）
+	ttfbLatencies []time.Duration
+
+	// ボディを読み終えるまでの時間（trace を指定した場合のみ。mu で保護）
+	fullLatencies []time.Duration
+
+	// 各ワーカーの最初のリクエストのレイテンシ（exclude_cold_start を指定した場合のみ。mu で保護）
+	coldStartLatencies []time.Duration
+}
+
+// latencyWindow は、前回の取り出し以降に記録されたレイテンシを溜めておくバッファです。
+type latencyWindow struct {
+	samples []time.Duration
+}
+
+// addLatencyWindow は、区間レイテンシ用のバッファを登録します。ワーカー起動前に呼び出してください。
+func (rm *ResultMetrics) addLatencyWindow() *latencyWindow {
+	w := &latencyWindow{}
+	rm.mu.Lock()
+	rm.windows = append(rm.windows, w)
+	rm.mu.Unlock()
+	return w
+}
+
+// drainLatencyWindow は、バッファに溜まったレイテンシを取り出し、バッファを空にします。
+func (rm *ResultMetrics) drainLatencyWindow(w *latencyWindow) []time.Duration {
+	rm.mu.Lock()
+	samples := w.samples
+	w.samples = nil
+	rm.mu.Unlock()
+	return samples
+}
+
+// latencyStats は、レイテンシの件数・平均・分散・最小・最大を1件ずつ逐次更新する集計値です。
+// 分散は Welford のアルゴリズムで更新するため、二乗和を単純に累積する方法と異なり桁落ちしません。
+type latencyStats struct {
+	count    int64
+	mean     float64 // 平均（ナノ秒）
+	m2       float64 // 平均からの偏差の二乗和（ナノ秒の二乗）
+	min, max time.Duration
+}
+
+// add は、1件のレイテンシを集計値へ反映します。
+func (s *latencyStats) add(d time.Duration) {
+	s.count++
+	delta := float64(d) - s.mean
+	s.mean += delta / float64(s.count)
+	s.m2 += delta * (float64(d) - s.mean)
+	if s.count == 1 || d < s.min {
+		s.min = d
+	}
+	if d > s.max {
+		s.max = d
+	}
+}
+
+// stdDev は、標準偏差（母標準偏差）を返します。
+func (s *latencyStats) stdDev() time.Duration {
+	if s.count == 0 {
+		return 0
+	}
+	return time.Duration(math.Sqrt(s.m2 / float64(s.count)))
+}
+
+// addLatency は、1件のレイテンシを全体の記録と登録済みの区間バッファへ追加します。
+func (rm *ResultMetrics) addLatency(d time.Duration) {
+	// ここは構造上 Mutex が必要ですが、処理を最小限（集計値の更新とスライスへの append のみ）にとどめています
+	rm.mu.Lock()
+	rm.latencyStats.add(d)
+	rm.histogram.add(d)
+	rm.progress.add(d)
+
+	if rm.maxSamples <= 0 || len(rm.latencies) < rm.maxSamples {
+		rm.latencies = append(rm.latencies, d)
+	} else {
+		// リザーバーサンプリング（Algorithm R）: n件目を maxSamples/n の確率で採用し、既存のサンプルと置き換えます。
+		// これにより、保持しているサンプルは常に観測した全件からの一様な無作為抽出になります。
+		rm.samplingActive = true
+		if j := rm.sampleRand.Int64N(rm.latencyStats.count); j < int64(rm.maxSamples) {
+			rm.latencies[j] = d
+		}
+	}
+
+	for _, w := range rm.windows {
+		w.samples = append(w.samples, d)
+	}
+	rm.mu.Unlock()
 }
 
 // NewResultMetrics は、パフォーマンスを最適化されたメトリクス構造体を初期化します。
@@ -58,39 +637,202 @@ func NewResultMetrics(estimatedTotal int) *ResultMetrics {
 	// 推定総リクエスト数に基づいて、スライスの初期容量（キャパシティ）を確保
 	// 完全に一致しなくても、動的拡張の回数を激減させることでパフォーマンスが飛躍的に向上します
 	return &ResultMetrics{
-		TotalRequests: 0,
-		SuccessCount:  0,
-		ErrorCount:    0,
+		TotalRequests: newShardedCounter(),
+		SuccessCount:  newShardedCounter(),
+		ErrorCount:    newShardedCounter(),
 		latencies:     make([]time.Duration, 0, estimatedTotal),
+		sampleRand:    newSamplingRand(rand.Uint64()),
 	}
 }
 
-// Record は、各ワーカー（Goroutine）から単一のリクエスト結果を受け取り、スレッドセーフに記録します。
-func (rm *ResultMetrics) Record(duration time.Duration, statusCode int, isError bool) {
+// newSamplingRand は、リザーバーサンプリングで置き換えるサンプルを選ぶ乱数生成器を、シード値から導出して返します。
+// ワーカーの乱数生成器（newWorkerRand）と系列が重ならないよう、ワーカー番号には現れない系列番号を使います。
+func newSamplingRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, math.MaxUint64))
+}
+
+// observe は、1件の結果をトレースと外部メトリクスシンクへ渡します（いずれも指定されていない場合は何もしません）。
+func (rm *ResultMetrics) observe(s sample) {
+	rm.trace.write(s)
+	if rm.sink != nil {
+		rm.sink.RecordRequest(s.dur, s.status, s.errKind)
+	}
+}
+
+// record は、各ワーカー（Goroutine）から単一のリクエスト結果を受け取り、スレッドセーフに記録します。
+// 応答の記録（recordResponse）と RecordNetworkError の共通の処理で、trace_out が指定されている場合はトレースにも書き出します。
+func (rm *ResultMetrics) record(s sample, isError bool) {
+	statusCode := s.status
+	rm.observe(s)
+
 	// 1. 総リクエスト数のアトミックなインクリメント
-	atomic.AddUint64(&rm.TotalRequests, 1)
+	rm.TotalRequests.Add(1)
 
 	// 2. 成功・エラーのアトミックな集計
 	if isError {
-		atomic.AddUint64(&rm.ErrorCount, 1)
+		rm.ErrorCount.Add(1)
 	} else {
 		// HTTP 2xx および 3xx を成功とみなす
 		if statusCode >= 200 && statusCode < 400 {
-			atomic.AddUint64(&rm.SuccessCount, 1)
+			rm.SuccessCount.Add(1)
 		} else {
-			atomic.AddUint64(&rm.ErrorCount, 1)
+			rm.ErrorCount.Add(1)
 		}
-
-		// ステータスコード分布の記録
-		// LoadOrStore を使用して、既存のカウンタを取得するか新規作成します
-		countPtr, _ := rm.StatusCodes.LoadOrStore(statusCode, new(uint64))
-		atomic.AddUint64(countPtr.(*uint64), 1)
 	}
 
+	// ステータスコード分布の記録（応答を受信できなかったネットワークエラーは statusCode=0 として記録され、
+	// レポートでは "NetworkError" として表示されます）
+	// LoadOrStore を使用して、既存のカウンタを取得するか新規作成します
+	countPtr, _ := rm.StatusCodes.LoadOrStore(statusCode, new(uint64))
+	atomic.AddUint64(countPtr.(*uint64), 1)
+
 	// 3. レイテンシデータの追加
-	// ここは構造上 Mutex が必要ですが、処理を最小限（スライスへの append のみ）にとどめています
+	// 接続拒否などのネットワークエラーは応答を受け取っていないため、所要時間がほぼ0でも
+	// 「瞬時に返ってきた正常な応答」と区別できません。統計を歪めないよう、応答を受信したリクエストのみを記録します。
+	if isError {
+		return
+	}
+
+	rm.addSampleLatency(s)
+}
+
+// エラー種別（ResultMetrics.ErrorKinds のキー）
+const (
+	errKindResponseTooLarge = "response_too_large" // レスポンスボディが MaxResponseBytes を超過した
+	errKindTLSClientCert    = "tls_client_cert"    // サーバーがクライアント証明書を要求した、または拒否した（mTLS）
+	errKindTLS              = "tls"                // その他のTLSハンドシェイク失敗
+	errKindRedirect         = "redirect"           // 3xx を受信した（redirects_are_errors 指定時のみ）
+)
+
+// classifyNetworkError は、応答を受信できなかったリクエストのエラーから、原因を示すエラー種別を推定します。
+// 特定できない場合は空文字を返します。
+func classifyNetworkError(err error) string {
+	// サーバーから受信したTLSアラートは crypto/tls の非公開型のため、メッセージで判別します。
+	// mTLS必須のサーバーに証明書なしで接続すると、TLS 1.3 では "certificate required"、
+	// TLS 1.2 以前では "bad certificate" や "handshake failure" のアラートが返ってきます。
+	// TLS 1.2 以前の "handshake failure" は、サーバーが証明書を要求していたことが分かっている場合だけ mTLS の失敗とします
+	msg := err.Error()
+	switch {
+	case errors.Is(err, errTLSClientCertRequested):
+		return errKindTLSClientCert
+	case strings.Contains(msg, "tls: certificate required"), strings.Contains(msg, "tls: bad certificate"):
+		return errKindTLSClientCert
+	case strings.Contains(msg, "remote error: tls:"):
+		return errKindTLS
+	}
+
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &recordErr) {
+		return errKindTLS
+	}
+	if isDNSError(err) {
+		return errKindDNS
+	}
+	if isQUICTimeout(err) {
+		return errKindQUICTimeout
+	}
+	return ""
+}
+
+// isTimeoutError は、エラーがタイムアウト（クライアントのタイムアウト、読み書きのデッドライン超過など）によるものかを判定します。
+// 接続拒否やTLS失敗などの他のネットワークエラーとは原因も対処も異なるため、区別して集計します。
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// RecordNetworkError は、応答を受信できなかったリクエスト（接続拒否、タイムアウト、TLS失敗など）を記録します。
+// 原因を特定できた場合は、エラー種別ごとの件数にも加算します。タイムアウトは timed_out として別途数えます。
+func (rm *ResultMetrics) RecordNetworkError(duration time.Duration, err error) {
+	kind := classifyNetworkError(err)
+	timedOut := isTimeoutError(err)
+
+	// トレースでは、原因を特定できなかったエラーもタイムアウトとそれ以外を区別できるようにします
+	traceKind := kind
+	switch {
+	case traceKind != "":
+	case timedOut:
+		traceKind = traceErrTimeout
+	default:
+		traceKind = traceErrNetwork
+	}
+	rm.record(sample{dur: duration, errKind: traceKind}, true)
+
+	if timedOut {
+		atomic.AddUint64(&rm.TimedOut, 1)
+	}
+
+	if kind != "" {
+		kindPtr, _ := rm.ErrorKinds.LoadOrStore(kind, new(uint64))
+		atomic.AddUint64(kindPtr.(*uint64), 1)
+	}
+}
+
+// RecordFailure は、応答は受信したものの失敗として扱うべきリクエスト（レスポンスサイズ超過など）を記録します。
+// ステータスコード分布とレイテンシには通常どおり含めつつ、エラー種別ごとの件数を別途集計します。
+// coldStart が true の場合、レイテンシはワーカーの最初のリクエストとして別に記録します（coldstart.go を参照）。
+func (rm *ResultMetrics) RecordFailure(duration time.Duration, statusCode int, kind string, coldStart bool) {
+	s := sample{dur: duration, status: statusCode, errKind: kind, coldStart: coldStart}
+	rm.observe(s)
+	rm.TotalRequests.Add(1)
+	rm.ErrorCount.Add(1)
+
+	countPtr, _ := rm.StatusCodes.LoadOrStore(statusCode, new(uint64))
+	atomic.AddUint64(countPtr.(*uint64), 1)
+
+	kindPtr, _ := rm.ErrorKinds.LoadOrStore(kind, new(uint64))
+	atomic.AddUint64(kindPtr.(*uint64), 1)
+
+	rm.addSampleLatency(s)
+}
+
+// RecordMessage は、WebSocketモードで1往復分のメッセージ（送信からエコー受信まで）の成功を記録します。
+// HTTPステータスコードを持たないため、ステータスコード分布には含めません。
+func (rm *ResultMetrics) RecordMessage(rtt time.Duration) {
+	rm.observe(sample{dur: rtt})
+	rm.TotalRequests.Add(1)
+	rm.SuccessCount.Add(1)
+
+	rm.addLatency(rtt)
+}
+
+// RecordTLS は、新規に確立したTLS接続でネゴシエートされたバージョンと暗号スイートを記録します。
+// TLSハンドシェイクは接続ごとに1回なので、リクエスト数ではなく接続数の分布になります。
+func (rm *ResultMetrics) RecordTLS(state tls.ConnectionState) {
+	versionPtr, _ := rm.TLSVersions.LoadOrStore(tls.VersionName(state.Version), new(uint64))
+	atomic.AddUint64(versionPtr.(*uint64), 1)
+
+	suitePtr, _ := rm.TLSCipherSuites.LoadOrStore(tls.CipherSuiteName(state.CipherSuite), new(uint64))
+	atomic.AddUint64(suitePtr.(*uint64), 1)
+}
+
+// beginRequest は、リクエスト（WebSocketモードではメッセージ）の送信開始を記録し、通信中の数とその最大値を更新します。
+func (rm *ResultMetrics) beginRequest() {
+	n := atomic.AddInt64(&rm.InFlight, 1)
+	// 最大値を更新する場合のみ CAS を行うため、定常状態ではロードのみで済みます
+	for peak := atomic.LoadInt64(&rm.PeakInFlight); n > peak; peak = atomic.LoadInt64(&rm.PeakInFlight) {
+		if atomic.CompareAndSwapInt64(&rm.PeakInFlight, peak, n) {
+			break
+		}
+	}
+}
+
+// endRequest は、通信時間 d のリクエストの完了を記録します。
+// テスト終了で中断されたリクエストも、それまで通信中だったことに変わりはないため含めます。
+func (rm *ResultMetrics) endRequest(d time.Duration) {
+	atomic.AddInt64(&rm.InFlight, -1)
+	atomic.AddInt64(&rm.InFlightNanos, int64(d))
+}
+
+// RecordConnect は、WebSocketモードで1本の接続確立に要した時間を記録します。
+func (rm *ResultMetrics) RecordConnect(duration time.Duration) {
+	atomic.AddUint64(&rm.ConnectionsOpened, 1)
+
 	rm.mu.Lock()
-	rm.latencies = append(rm.latencies, duration)
+	rm.connectLatencies = append(rm.connectLatencies, duration)
 	rm.mu.Unlock()
 }
 
@@ -108,6 +850,273 @@ type TestReport struct {
 	MaxLatency    string            `json:"max_latency"`
 	StatusCodes   map[string]uint64 `json:"status_codes"`
 	ErrorMsg      string            `json:"error_msg,omitempty"` // 致命的なエラーが発生した場合
+
+	// Status は、テスト全体の結果です（"ok" / "all_failed"）。"all_failed" の場合、throughput_rps は
+	// 失敗したリクエストの処理速度であり、ターゲットの処理能力を表しません。
+	// LatencyErrorOnly が true の場合、レイテンシの各項目はエラー応答（4xx/5xx）だけから算出された値です。
+	Status           string `json:"status,omitempty"`
+	LatencyErrorOnly bool   `json:"latency_error_only,omitempty"`
+
+	// FieldErrors は、厳格バリデーション（X-Strict-Validation）で検出されたフィールドごとの誤りです。
+	FieldErrors []FieldError `json:"field_errors,omitempty"`
+
+	// StdDevLatency は、レイテンシの標準偏差です（応答が0件の場合は省略）。
+	StdDevLatency string `json:"stddev_latency,omitempty"`
+
+	// TrimmedMean は、レイテンシの両端から TrimmedMeanFraction の割合ずつを除いた平均です（-trimmed-mean を指定した場合のみ。trimmed.go を参照）。
+	TrimmedMean         string  `json:"trimmed_mean,omitempty"`
+	TrimmedMeanFraction float64 `json:"trimmed_mean_fraction,omitempty"`
+
+	// TailPercentiles は、percentiles で指定した（未指定時は p99.9 と p99.99 の）パーセンタイルです（"p99.9": "12.34ms"）。
+	// PercentileWarnings は、サンプル数が足りず統計的に信頼できないパーセンタイルについての警告です。
+	TailPercentiles    map[string]string `json:"tail_percentiles,omitempty"`
+	PercentileWarnings []string          `json:"percentile_warnings,omitempty"`
+
+	// Apdex は、apdex_target を目標レイテンシとした Apdex スコア（0〜1）です（apdex.go を参照）。
+	ApdexTarget string   `json:"apdex_target,omitempty"`
+	Apdex       *float64 `json:"apdex,omitempty"`
+
+	// LatencySamples は、レイテンシ統計の母数（応答を受信したリクエスト数）です。
+	// ネットワークエラーは含まれないため、0件の場合は各レイテンシ項目が "N/A" になります。
+	LatencySamples int `json:"latency_samples"`
+
+	// latency は、文字列に丸める前のレイテンシの要約統計です（hey・wrk 形式の出力で使用します。outputfmt.go を参照）。
+	// JSON には含まれないため、ファイルから読み込んだだけのレポートでは nil です。
+	latency *summaryDurations
+
+	// TTFB は、レスポンスの最初の1バイトを受信するまでの時間の分布です（trace または no_drain_body を指定した場合のみ）。
+	// FullResponse は、ボディを最後まで読み終えるまでの時間の分布です（trace を指定した場合のみ）。
+	TTFB         *LatencySummary `json:"ttfb,omitempty"`
+	FullResponse *LatencySummary `json:"full_response,omitempty"`
+
+	// ColdStart は、各ワーカーの最初のリクエストのレイテンシの分布です（exclude_cold_start を指定した場合のみ）。
+	// これらのリクエストは、上記のレイテンシ統計には含まれません。
+	ColdStart *LatencySummary `json:"cold_start_latency,omitempty"`
+
+	// WSConnect は、WebSocketモードにおける接続確立（TCP/TLS + Upgradeハンドシェイク）時間の分布です。
+	// HTTPモードでは省略されます。
+	WSConnect *LatencySummary `json:"ws_connect,omitempty"`
+
+	// 複数マシンでの分散実行結果を -merge で統合した場合のみ設定されます
+	MergedReports          int  `json:"merged_reports,omitempty"`          // 統合したレポートの数
+	PercentilesApproximate bool `json:"percentiles_approximate,omitempty"` // パーセンタイルが近似値であることを示します
+
+	// ErrorKinds は、原因を特定できたエラーのエラー種別ごとの件数です。
+	ErrorKinds map[string]uint64 `json:"error_kinds,omitempty"`
+
+	// TimedOut は、タイムアウトによって応答を受信できなかったリクエストの数（errors の内数）で、
+	// TimeoutRatePct は総リクエスト数に占めるその割合（%）です。
+	// タイムアウトしたリクエストはレイテンシ統計に含まれないため、この割合が高い場合はパーセンタイルが実態より良く見えます。
+	TimedOut       uint64  `json:"timed_out"`
+	TimeoutRatePct float64 `json:"timeout_rate_pct"`
+
+	// JSONAssertionFailures は、assert_json のアサーションに失敗したリクエストの数です（errors の内数）。
+	JSONAssertionFailures uint64 `json:"json_assertion_failures,omitempty"`
+
+	// SlowThreshold は、遅い応答とみなす所要時間です（slow_threshold を指定した場合のみ）。SlowResponses はそれを超えて
+	// 応答したリクエストの数、SlowRatePct は総リクエスト数に対する割合（%）です。SlowIsError の場合、成功になるはずだった
+	// 遅い応答は slow_response エラーとして errors に含まれます。
+	SlowThreshold string  `json:"slow_threshold,omitempty"`
+	SlowResponses uint64  `json:"slow_responses,omitempty"`
+	SlowRatePct   float64 `json:"slow_rate_pct,omitempty"`
+	SlowIsError   bool    `json:"slow_is_error,omitempty"`
+
+	// HTTP3 は、HTTP/3 (QUIC) で送信したテストかどうかです。
+	HTTP3 bool `json:"http3,omitempty"`
+
+	// FailFast は、fail_fast によってテストを停止させた最初のエラーの詳細です（エラーが発生しなかった場合は省略）。
+	FailFast *FailFastError `json:"fail_fast,omitempty"`
+
+	// ThroughputFloor は、min_rps_floor を下回り続けたためにテストを停止した時点の状況です（停止しなかった場合は省略）。
+	ThroughputFloor *ThroughputFloorAbort `json:"throughput_floor,omitempty"`
+
+	// AbortedReason は、テストを予定より早く打ち切った理由（"fail_fast" または "throughput_floor"）です。最後まで実行した場合は省略します。
+	AbortedReason string `json:"aborted_reason,omitempty"`
+
+	// 1秒ごとのタイムライン。インデックスが経過秒数（0始まり）に対応します。
+	RPSTimeline         []uint64 `json:"rps_timeline,omitempty"`         // その1秒間に完了したリクエスト数
+	ConcurrencyTimeline []int64  `json:"concurrency_timeline,omitempty"` // 各秒の終わりの時点で通信中だったリクエスト数
+
+	// SuccessRateTimeline は、その1秒間に完了したリクエストのうち成功したものの割合（0〜1）です。
+	// 全体の成功率では見えない「途中から失敗し始めた」時点を特定するためのもので、その1秒間に完了したリクエストが
+	// 1件もない場合は null です（「失敗しなかった」のではなく、判定する材料がないため）。
+	SuccessRateTimeline []*float64 `json:"success_rate_timeline,omitempty"`
+
+	// NewConnectionsTimeline は、その1秒間に新規に確立した（プールから再利用しなかった）接続の数です。
+	// テストの途中から増え続ける場合は、接続が再利用されずに張り直されています（Connection: close やアイドル接続の切断など）。
+	// レイテンシの悪化と同じ時点で増えていれば、接続の確立のコストが原因の可能性があります。事前確立した接続は含みません。
+	NewConnectionsTimeline []uint64 `json:"new_connections_timeline,omitempty"`
+
+	// TargetRPSTimeline は、traffic_shape を指定したテストの、その1秒間の目標レート（リクエスト/秒）です。
+	// rps_timeline と並べて、目標の形にどこまで追従できたかを確認できます（traffic_shape を指定しない場合は省略）。
+	TargetRPSTimeline []float64 `json:"target_rps_timeline,omitempty"`
+	TrafficShape      string    `json:"traffic_shape,omitempty"`
+
+	// 新規TLS接続ごとにネゴシエートされたバージョン・暗号スイートの分布（接続数）
+	TLSVersions     map[string]uint64 `json:"tls_versions,omitempty"`
+	TLSCipherSuites map[string]uint64 `json:"tls_cipher_suites,omitempty"`
+
+	// ConcurrencyTrajectory は、適応型負荷モードにおける並行数の推移です（調整間隔ごとに1件）。
+	ConcurrencyTrajectory []AdaptivePoint `json:"concurrency_trajectory,omitempty"`
+
+	// Burstiness は、100ミリ秒ごとの送信数の偏りです（ジッターの効果の確認用）。
+	Burstiness *BurstStats `json:"burstiness,omitempty"`
+
+	// ActualDurationSec は、実際の実行時間（秒）です。スループットはこの値を基に算出されます。
+	// 停止されるまで実行するテスト（forever）や途中で停止したテストでは、指定した実行時間と異なります。
+	ActualDurationSec float64 `json:"actual_duration_sec"`
+
+	// PausedSec は、/api/pause で送信を一時停止していた時間の合計（秒）、Pauses はその回数です（一時停止しなかった場合は省略）。
+	// 一時停止した場合、スループットは実行時間からこの時間を除いた時間を基に算出されます（pause.go を参照）。
+	PausedSec float64 `json:"paused_sec,omitempty"`
+	Pauses    int     `json:"pauses,omitempty"`
+
+	// オープンモデルで、通信中のリクエスト数が上限（max_in_flight）に達した回数と、それにより送信しなかったリクエスト数です。
+	// 送信しなかったリクエストは total_requests には含まれません。
+	InFlightCapHits uint64 `json:"in_flight_cap_hits,omitempty"`
+	SkippedOverload uint64 `json:"skipped_overload,omitempty"`
+
+	// honor_retry_after が有効な場合の、429 の件数と、Retry-After に従って待機した時間の全ワーカー合計（秒）です。
+	RateLimited     uint64  `json:"rate_limited,omitempty"`
+	BackoffTotalSec float64 `json:"backoff_total_sec,omitempty"`
+
+	// max_rps を指定した場合の上限値と、上限に達して送信を待たせたリクエスト数です。
+	// max_rps_engaged が false の場合、上限はテスト結果に一切影響していません。
+	MaxRPS        float64 `json:"max_rps,omitempty"`
+	MaxRPSEngaged bool    `json:"max_rps_engaged,omitempty"`
+	MaxRPSCapHits uint64  `json:"max_rps_cap_hits,omitempty"`
+
+	// 接続確立（TCPハンドシェイク）に失敗した数と、そのうちテスト開始から5秒以内に発生した数です。
+	// dial_concurrency を指定した場合は、その上限値も記録されます。
+	DialConcurrency   int    `json:"dial_concurrency,omitempty"`
+	Connections       int    `json:"connections,omitempty"` // 指定した1ホストあたりの接続の上限（connections）
+	DialErrors        uint64 `json:"dial_errors"`
+	DialErrorsOpening uint64 `json:"dial_errors_opening"`
+
+	// ConnectPhases は、connect_timeout を指定したテストの、フェーズごとの接続タイムアウトと接続の確立に失敗した数です。
+	ConnectPhases *ConnectPhaseReport `json:"connect_phases,omitempty"`
+
+	// 新規接続のための名前解決の回数、1回あたりの平均所要時間、失敗数です（ターゲットがIPアドレスの場合は解決しません）。
+	// dns_server を指定した場合は、問い合わせたDNSサーバーも記録されます。
+	DNSServer      string  `json:"dns_server,omitempty"`
+	DNSLookups     uint64  `json:"dns_lookups,omitempty"`
+	AvgDNSLookupMs float64 `json:"avg_dns_lookup_ms,omitempty"`
+	DNSErrors      uint64  `json:"dns_errors,omitempty"`
+
+	// RequestsByIP は、spread_ips を指定した場合の、接続先アドレスごとのリクエスト数です。
+	RequestsByIP map[string]uint64 `json:"requests_by_ip,omitempty"`
+
+	// InjectedLatency は、inject_latency で送信前に注入した遅延の設定です（"50ms±10ms"）。
+	// LatencySimulated が true の場合、レイテンシの各項目には注入した人為的な遅延が含まれています（ターゲットごとの注入のみの場合も含みます）。
+	InjectedLatency  string `json:"injected_latency,omitempty"`
+	LatencySimulated bool   `json:"latency_simulated,omitempty"`
+
+	// WorkerDistribution は、ワーカーごとの完了リクエスト数の分布です（HTTPのクローズドモデルのみ）。
+	WorkerDistribution *WorkerDistribution `json:"worker_request_distribution,omitempty"`
+
+	// SampleExchanges は、capture_samples を指定した場合に捕捉した最初の N 件のやり取り（ヘッダー）です。
+	SampleExchanges []SampleExchange `json:"sample_exchanges,omitempty"`
+
+	// SlowestRequests は、top_slowest を指定した場合に記録した、最も時間のかかったリクエストです（所要時間の降順）。
+	SlowestRequests []SlowRequest `json:"slowest_requests,omitempty"`
+
+	// リザーバーサンプリング（max_samples）が作動した場合のみ設定されます。
+	// このときパーセンタイルは latency_samples 件の無作為抽出から算出された値で、
+	// 最小値・平均値・最大値は latency_observed 件すべてから算出された正確な値です。
+	SamplingEngaged bool  `json:"sampling_engaged,omitempty"`
+	LatencyObserved int64 `json:"latency_observed,omitempty"`
+
+	// ConnectionsOpened は、テスト中に新規に確立したTCP接続の数です（コネクションの使い回し具合の指標）。
+	// PrewarmedConnections は、prewarm_connections で計測開始前に確立した接続の数です（connections_opened には含みません）。
+	ConnectionsOpened    uint64 `json:"connections_opened"`
+	PrewarmedConnections int    `json:"prewarmed_connections,omitempty"`
+
+	// RequestsPerConnection は、新規接続1本あたりの平均リクエスト数です（事前に確立した接続も含めて算出します）。
+	// MaxRequestsPerConn は、max_requests_per_conn を指定した場合のその値です。
+	RequestsPerConnection float64 `json:"requests_per_connection"`
+	MaxRequestsPerConn    int     `json:"max_requests_per_conn,omitempty"`
+
+	// ConnectionCloseResponses は、ターゲットが Connection: close を返して接続を閉じた応答の数、
+	// ConnectionClosePct はその総リクエスト数に対する割合（%）です（connclose.go を参照）。
+	ConnectionCloseResponses uint64  `json:"connection_close_responses,omitempty"`
+	ConnectionClosePct       float64 `json:"connection_close_pct,omitempty"`
+
+	// 実際に同時に通信していたリクエスト数の最大値と平均値です（実効的な並行数）。
+	// レート制御や think time で大半のワーカーが待機している場合や、fd の上限で接続できない場合は、
+	// 設定した並行数（concurrency）を大きく下回ります。平均値は、全リクエストの通信時間の合計を実行時間で割った値です（リトルの法則）。
+	PeakInFlight int64   `json:"peak_inflight"`
+	AvgInFlight  float64 `json:"avg_inflight"`
+
+	// Targets は、複数ターゲットモードにおけるターゲットごとの設定レートと達成レートです。
+	Targets []TargetReport `json:"targets,omitempty"`
+
+	// HostPools は、複数ターゲットモードでホストが2つ以上ある場合の、ホストごとのコネクションプールの利用状況です（hostpool.go を参照）。
+	HostPools []HostPoolReport `json:"host_pools,omitempty"`
+
+	// RequestSpecs は、request_file から読み込んで再生したリクエストの定義の数です。
+	RequestSpecs int `json:"request_specs,omitempty"`
+
+	// CacheBust は、有効だったキャッシュバスティングの方式です（無効の場合は省略）。
+	CacheBust string `json:"cache_bust,omitempty"`
+
+	// export_path を指定した場合の書き出し結果（成功時は書き出し先、失敗時はエラー内容）
+	ExportedTo  string `json:"exported_to,omitempty"`
+	ExportError string `json:"export_error,omitempty"`
+
+	// trace_out を指定した場合の書き出し結果（書き出し先と件数、失敗時はエラー内容）
+	TracedTo     string `json:"traced_to,omitempty"`
+	TraceRecords uint64 `json:"trace_records,omitempty"`
+	TraceError   string `json:"trace_error,omitempty"`
+
+	// Comparison は、compare_target_url を指定した場合の比較ターゲット（B）の結果と、このレポート（A）との差分です。
+	Comparison *ComparisonReport `json:"comparison,omitempty"`
+
+	// replay_trace を指定した場合の再生の結果（トレースの件数、送信した件数、記録時刻からの送信の遅れ）
+	ReplayTrace      string  `json:"replay_trace,omitempty"`
+	ReplayEvents     int     `json:"replay_events,omitempty"`
+	ReplayDispatched int     `json:"replay_dispatched,omitempty"`
+	ReplayMeanLagMs  float64 `json:"replay_mean_lag_ms,omitempty"`
+	ReplayMaxLagMs   float64 `json:"replay_max_lag_ms,omitempty"`
+
+	// LatencyHistogram は、レイテンシの累積ヒストグラム（{"le": 件数}。境界は秒）です（latency_histogram を指定した場合のみ）。
+	// LatencyHistogramCount と LatencyHistogramSumSec は、Prometheus のヒストグラムの _count と _sum に当たる値です。
+	LatencyHistogram       map[string]uint64 `json:"latency_histogram,omitempty"`
+	LatencyHistogramCount  uint64            `json:"latency_histogram_count,omitempty"`
+	LatencyHistogramSumSec float64           `json:"latency_histogram_sum_seconds,omitempty"`
+
+	// BytesUploaded は、gen_body_size で生成したボディを送信したバイト数の合計です（gen_body_size を指定した場合のみ）。
+	BytesUploaded uint64 `json:"bytes_uploaded,omitempty"`
+
+	// latency_histogram_path を指定した場合の書き出し結果（成功時は書き出し先、失敗時はエラー内容）
+	LatencyHistogramTo    string `json:"latency_histogram_to,omitempty"`
+	LatencyHistogramError string `json:"latency_histogram_error,omitempty"`
+
+	// IntervalSnapshots は、report_interval_sec ごとの途中経過です。
+	IntervalSnapshots []IntervalSnapshot `json:"interval_snapshots,omitempty"`
+
+	// Seed は、このテストで使用したシード値です。同じ値を設定に指定するとテストを再現できます。
+	// -merge で統合したレポートでは、全レポートのシード値が一致する場合のみ設定されます。
+	Seed uint64 `json:"seed"`
+
+	// ToolVersion は、このレポートを生成したツールのビルド情報です（結果の比較や不具合報告のため）。
+	ToolVersion BuildInfo `json:"tool_version"`
+
+	// Warnings は、URLのスキームを補ったなど、設定を自動補正した場合の注意事項です。
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Tags は、設定JSONの tags とサーバーの -tag フラグで付与されたラベルです。
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// StatusClasses は、StatusCodes をクラス単位（2xx/3xx/4xx/5xx/network）に集約した件数です。
+	// 多数の異なるステータスコードが返る場合でも、全体の健全性をひと目で把握できます。
+	StatusClasses map[string]uint64 `json:"status_classes"`
+
+	// ErrorRate は、総リクエスト数に占めるエラー（errors。通信エラー・4xx・5xx・アサーションの失敗などをすべて含みます）の割合（0〜1）です。
+	// ServerErrorRate と ClientErrorRate は、ステータスコードの分布から求めた 5xx（サーバー側の問題）と 4xx（クライアント側の問題。
+	// 存在しないIDの 404 など、意図したものも多い）の応答の割合（0〜1）です。通信エラーはどちらにも含みません。
+	ErrorRate       float64 `json:"error_rate"`
+	ServerErrorRate float64 `json:"server_error_rate"`
+	ClientErrorRate float64 `json:"client_error_rate"`
 }
 // ==============================================================================
 // [セクション2] 10万RPS対応: 超絶チューニング済みHTTPクライアントとワーカー
@@ -116,42 +1125,51 @@ type TestReport struct {
 // createOptimizedHTTPClient は、OSのエフェメラルポート枯渇を防ぎ、
 // TCPコネクションを極限まで再利用するためのカスタムHTTPクライアントを生成します。
 // 10万RPSを達成するための最重要コンポーネントです。
-func createOptimizedHTTPClient(concurrency int, timeoutSec int) *http.Client {
-	// タイムアウト値の計算
-	timeout := time.Duration(timeoutSec) * time.Second
-	if timeout == 0 {
-		timeout = 10 * time.Second // デフォルトの安全値
-	}
+func createOptimizedHTTPClient(concurrency int, cfg *TestConfig) *http.Client {
+	// タイムアウト値の計算（リクエスト全体のタイムアウトは、送信側がリクエストごとのコンテキストで設定します。withRequestTimeout を参照）
+	timeout := requestTimeout(cfg)
+	timeouts := resolveTransportTimeouts(cfg, timeout)
 
 	// http.Transport はHTTP/TCP通信の低レイヤーを制御します
 	transport := &http.Transport{
+		// TCP_NODELAY やソケットバッファサイズを反映させるため、独自のダイヤラーで接続を確立します
+		DialContext: newTunedDialer(cfg).DialContext,
+
 		// 【重要】MaxIdleConnsPerHost を並行数以上に設定します。
 		// これを行わないと、コネクションプールが機能せず、TCPのTIME_WAITが大量発生してOSが死にます。
-		MaxIdleConns:        concurrency * 2,
-		MaxIdleConnsPerHost: concurrency * 2,
-		MaxConnsPerHost:     concurrency * 2,
+		// connections が指定されている場合は、その値を1ホストあたりの接続の上限にします（connections.go を参照）。
+		MaxIdleConns:        max(concurrency*2, connsPerHost(concurrency, cfg)),
+		MaxIdleConnsPerHost: connsPerHost(concurrency, cfg),
+		MaxConnsPerHost:     connsPerHost(concurrency, cfg),
 
 		// Keep-Alive を強制的に有効化し、ハンドシェイクのオーバーヘッドをゼロにします。
 		DisableKeepAlives: false,
 
-		// パフォーマンス向上のための各種タイムアウト設定
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ResponseHeaderTimeout: timeout,
+		// パフォーマンス向上のための各種タイムアウト設定（設定JSONとサーバーのフラグで変更できます。timeouts.go を参照）
+		IdleConnTimeout:       timeouts.idleConn,
+		TLSHandshakeTimeout:   timeouts.tlsHandshake,
+		ResponseHeaderTimeout: timeouts.responseHeader,
 
 		// どのような環境（自己署名証明書など）でもテストを止めないよう、TLS検証をスキップします。
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
+		// バージョンや暗号スイートが指定されている場合は、それらも反映されます。
+		TLSClientConfig: newTLSConfig(cfg),
 
 		// 高負荷時に100-Continueを待つオーバーヘッドを削減します
-		ExpectContinueTimeout: 1 * time.Second,
+		ExpectContinueTimeout: timeouts.expectContinue,
+	}
+
+	// HTTP/3 の場合は、TCP の Transport の代わりに QUIC の Transport を使います（http3.go を参照）
+	var base http.RoundTripper = transport
+	if cfg.HTTP3 {
+		base = newHTTP3Transport(cfg, timeout, timeouts)
 	}
 
 	client := &http.Client{
-		Transport: transport,
-		Timeout:   timeout,
-		// 負荷テストの純粋なレスポンスタイムを測るため、リダイレクトは自動追従させずにエラーとして記録します
+		// 認証方式が指定されている場合は、資格情報を付与する Transport で包みます。
+		// 署名は認証の内側で付与し、Digest 認証で再送するリクエストにも署名し直します
+		Transport: newAuthTransport(newSigningTransport(base, cfg), cfg),
+		// 負荷テストの純粋なレスポンスタイムを測るため、リダイレクトは自動追従させずに 3xx の応答そのものを記録します
+		// （redirects_are_errors を指定した場合はエラーとして記録します）
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
@@ -160,22 +1178,231 @@ func createOptimizedHTTPClient(concurrency int, timeoutSec int) *http.Client {
 	return client
 }
 
+// tlsVersions は、設定で指定可能なTLSバージョン文字列と定数の対応表です。
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// buildTLSConfig は、テスト設定のTLSバージョンと暗号スイート指定を検証し、tls.Config を組み立てます。
+// 未知のバージョン文字列や暗号スイート名はエラーとして返します。
+func buildTLSConfig(cfg *TestConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: true,
+	}
+
+	if cfg.TLSMinVersion != "" {
+		version, ok := tlsVersions[cfg.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("未対応のTLSバージョンです: tls_min_version=%q (1.0 / 1.1 / 1.2 / 1.3 のいずれかを指定してください)", cfg.TLSMinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+	if cfg.TLSMaxVersion != "" {
+		version, ok := tlsVersions[cfg.TLSMaxVersion]
+		if !ok {
+			return nil, fmt.Errorf("未対応のTLSバージョンです: tls_max_version=%q (1.0 / 1.1 / 1.2 / 1.3 のいずれかを指定してください)", cfg.TLSMaxVersion)
+		}
+		tlsConfig.MaxVersion = version
+	}
+	if tlsConfig.MinVersion != 0 && tlsConfig.MaxVersion != 0 && tlsConfig.MinVersion > tlsConfig.MaxVersion {
+		return nil, fmt.Errorf("tls_min_version (%s) が tls_max_version (%s) より新しいバージョンになっています", cfg.TLSMinVersion, cfg.TLSMaxVersion)
+	}
+
+	if cfg.ClientCert != "" || cfg.ClientKey != "" {
+		if cfg.ClientCert == "" || cfg.ClientKey == "" {
+			return nil, errors.New("client_cert と client_key は両方とも指定してください")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("クライアント証明書の読み込みに失敗しました: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	} else if cfg.certRequest != nil {
+		// 証明書を持たない場合も、要求されたことだけを記録して、従来どおり証明書なしで応じます
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cfg.certRequest.requested.Store(true)
+			return &tls.Certificate{}, nil
+		}
+	}
+
+	if len(cfg.TLSCipherSuites) > 0 {
+		// 安全でないスイートも検証目的で指定できるよう、両方の一覧から名前を引きます
+		known := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			known[suite.Name] = suite.ID
+		}
+		for _, suite := range tls.InsecureCipherSuites() {
+			known[suite.Name] = suite.ID
+		}
+
+		for _, name := range cfg.TLSCipherSuites {
+			id, ok := known[name]
+			if !ok {
+				return nil, fmt.Errorf("未知の暗号スイートです: %q", name)
+			}
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// errTLSClientCertRequested は、クライアント証明書を要求したサーバーにハンドシェイクを拒否されたことを示すエラーです。
+var errTLSClientCertRequested = errors.New("サーバーがクライアント証明書を要求しました")
+
+// clientCertRequest は、クライアント証明書を指定していないテストで、サーバーから証明書の要求を受けたかどうかです（全クライアントで共有します）。
+// TLS 1.2 以前のサーバーは、証明書のない接続を汎用の "handshake failure" のアラートで拒否するため、
+// アラートだけでは暗号スイートの不一致などと区別できません。要求を受けていた場合は、そのアラートを mTLS の失敗とみなします。
+type clientCertRequest struct {
+	requested atomic.Bool
+}
+
+// annotate は、サーバーが証明書を要求していた場合に、"handshake failure" のアラートを errTLSClientCertRequested で包んで返します。
+// それ以外のエラーと、nil の clientCertRequest に対しては err をそのまま返します。
+func (c *clientCertRequest) annotate(err error) error {
+	if c == nil || !c.requested.Load() || !strings.Contains(err.Error(), "remote error: tls: handshake failure") {
+		return err
+	}
+	return fmt.Errorf("%w: %w", errTLSClientCertRequested, err)
+}
+
+// newTLSConfig は、クライアントが使用する tls.Config を返します。
+// 設定値は readTestConfig で検証済みのため、ここでのエラーは想定外です。
+// 万一エラーになった場合は、テストを止めずに検証スキップのみの設定へフォールバックします。
+func newTLSConfig(cfg *TestConfig) *tls.Config {
+	tlsConfig, err := buildTLSConfig(cfg)
+	if err != nil {
+		log.Printf("[TLS Error] TLS設定の構築に失敗したため、デフォルト設定を使用します: %v\n", err)
+		return &tls.Config{InsecureSkipVerify: true}
+	}
+	return tlsConfig
+}
+
+// socketOptions は、ダイヤル時に各TCPソケットへ適用する設定です。
+type socketOptions struct {
+	noDelay   bool
+	rcvBuf    int
+	sndBuf    int
+	keepAlive time.Duration // TCPキープアライブのプローブ間隔（0は既定値、負の値は無効）
+}
+
+// tunedDialer は、net.Dialer にソケットレベルのチューニングを加えたダイヤラーです。
+// SO_RCVBUF/SO_SNDBUF は接続前（SYN送信前）に設定しないとTCPウィンドウスケールに反映されないため、
+// プラットフォーム固有の Control 関数で設定します。一方 TCP_NODELAY は Go が接続確立後に
+// 上書きするため、接続後に明示的に設定し直します。
+type tunedDialer struct {
+	dialer   net.Dialer
+	opts     socketOptions
+	gate     *dialGate      // 接続確立の同時実行数の制限（nil の場合は制限しません）
+	resolver *net.Resolver  // ホスト名の解決に使うリゾルバー（dns_server を指定した場合はそのサーバーへ問い合わせます）
+	dns      *dnsStats      // 名前解決の集計（nil の場合は記録しません）
+	spread   *ipSpreader    // 解決した全アドレスへの接続の分散（nil の場合は分散しません）
+	conns    *hostConnStats // ホストごとのプールの接続の集計（nil の場合は集計しません）
+}
+
+// newTunedDialer は、テスト設定からソケットオプションを解決し、ダイヤラーを生成します。
+func newTunedDialer(cfg *TestConfig) *tunedDialer {
+	opts := socketOptions{
+		noDelay:   true,
+		rcvBuf:    cfg.SockRcvBuf,
+		sndBuf:    cfg.SockSndBuf,
+		keepAlive: time.Duration(cfg.TCPKeepAlive),
+	}
+	if cfg.TCPNoDelay != nil {
+		opts.noDelay = *cfg.TCPNoDelay
+	}
+
+	d := &tunedDialer{opts: opts, gate: cfg.dialGate, resolver: newDNSResolver(cfg.DNSServer), dns: cfg.dns, spread: cfg.ipSpread, conns: cfg.hostConns}
+	// TCPキープアライブ（SO_KEEPALIVE）は Go が接続確立時に設定するため、ダイヤラーに指定するだけで反映されます。
+	// net.Dialer.KeepAlive は最初のプローブまでの無通信時間のみを変更し、プローブ間隔は15秒のままになるため、
+	// 間隔を指定した場合は KeepAliveConfig で両方に同じ値を設定します
+	switch {
+	case opts.keepAlive > 0:
+		d.dialer.KeepAliveConfig = net.KeepAliveConfig{Enable: true, Idle: opts.keepAlive, Interval: opts.keepAlive}
+	case opts.keepAlive < 0:
+		d.dialer.KeepAlive = -1
+	}
+	if opts.rcvBuf > 0 || opts.sndBuf > 0 {
+		d.dialer.Control = socketBufferControl(opts)
+	}
+	return d
+}
+
+// DialContext は、接続を確立した後に TCP_NODELAY を設定して返します。
+// dial_concurrency が指定されている場合は、同時に確立中の接続が上限未満になるまで待ってから接続します。
+func (d *tunedDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.gate.dial(ctx, func(dialCtx context.Context) (net.Conn, error) {
+		return d.dialResolved(dialCtx, network, address)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err := tcpConn.SetNoDelay(d.opts.noDelay); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	// ホストごとのプールでは、開いている接続の数を数えるため、閉じたことを検知できるよう包みます
+	return d.conns.track(conn), nil
+}
+
+// logSocketOptions は、テスト開始時に要求されたソケット設定をログへ出力します。
+// OSが実際に適用した値（Linuxでは要求値の2倍になる等）は、最初の接続確立時に別途出力されます。
+func logSocketOptions(cfg *TestConfig) {
+	opts := newTunedDialer(cfg).opts
+	rcvBuf, sndBuf := "OSデフォルト", "OSデフォルト"
+	if opts.rcvBuf > 0 {
+		rcvBuf = fmt.Sprintf("%d バイト", opts.rcvBuf)
+	}
+	if opts.sndBuf > 0 {
+		sndBuf = fmt.Sprintf("%d バイト", opts.sndBuf)
+	}
+	log.Printf("[Socket] TCP_NODELAY: %v, SO_RCVBUF: %s, SO_SNDBUF: %s\n", opts.noDelay, rcvBuf, sndBuf)
+}
+
 // executeWorker は、1つのGoroutineとして動作し、終了シグナルを受け取るまで
 // ターゲットURLに対して限界までリクエストを連射し続けます。
-func executeWorker(ctx context.Context, wg *sync.WaitGroup, client *http.Client, targetURL string, method string, metrics *ResultMetrics) {
+// targets が nil でない場合（複数ターゲットモード）は、送信のたびに重みに応じてターゲットを選びます。
+// ceiling が nil でない場合は、全ワーカー合計の送信レートが上限を超えないよう送信前に待機します。
+func executeWorker(ctx context.Context, wg *sync.WaitGroup, client *http.Client, cfg *TestConfig, metrics *ResultMetrics, targets *targetSet, ceiling *rpsCeiling, index int, rng *rand.Rand) {
 	// ワーカー終了時にWaitGroupのカウントを減らす（これはGoroutineのライフサイクルにつき1回なのでdeferでOK）
 	defer wg.Done()
 
 	// 10万RPSを出すための最適化: ループの外でベースとなるリクエストオブジェクトを作成しておく。
 	// ループ内で毎回 http.NewRequest を呼ぶと、極端な高負荷時にGC（ガベージコレクション）の対象となり、
 	// メモリのアロケーションコストが無視できなくなるためです。
-	baseReq, err := http.NewRequest(method, targetURL, nil)
+	baseReq, err := newBaseRequest(context.Background(), cfg.Method, cfg.TargetURL, cfg)
 	if err != nil {
 		// リクエスト生成に失敗した場合（URLの構文エラーなど）は、このワーカーを即座に終了します。
-		log.Printf("[Worker Error] リクエストの初期化に失敗しました: %v\n", err)
+		metrics.errLog.printf("[Worker Error]", "リクエストの初期化に失敗しました: %v", err)
 		return
 	}
 
+	// 新規TLS接続のネゴシエーション結果を記録するためのトレースを、ワーカーごとに1度だけ仕込みます。
+	// ループ内で毎回 httptrace.WithClientTrace を呼ぶとアロケーションが発生するため、
+	// トレース付きのコンテキストを使い回します。
+	traceCtx := newTraceContext(ctx, metrics)
+
+	// レート制御が有効な場合の送信タイミング制御（無効な場合は nil で、待機は発生しません）
+	pc := newPacer(ratePerWorker(cfg), cfg.RateJitter, rng)
+	if metrics.shape != nil {
+		pc = newShapedPacer(metrics.shape, 1/float64(cfg.Concurrency), cfg.RateJitter, rng)
+	}
+
+	// キャッシュバスティングが有効な場合の一意な値の付与（無効な場合は nil）
+	cb := newCacheBuster(cfg.CacheBust, index)
+
+	// このワーカーが完了させたリクエスト数。共有カウンタの競合を避けるためローカルで数え、終了時に登録します
+	var completed uint64
+	defer func() { metrics.addWorkerCount(completed) }()
+
+	// exclude_cold_start が指定されている場合、最初の1件のレイテンシは接続の確立を含むため別に記録します
+	coldStart := cfg.ExcludeColdStart
+
 	// 無限ループでリクエストを送信し続ける（ctx.Done() で安全に抜け出します）
 	for {
 		select {
@@ -186,62 +1413,405 @@ func executeWorker(ctx context.Context, wg *sync.WaitGroup, client *http.Client,
 			// ==================================================================
 			// 限界突破の通信ループ（GC負荷を最小化する設計）
 			// ==================================================================
-			start := time.Now()
+			// 一時停止中は再開まで、レート制御が有効な場合は次の送信スロットまで、安全上限に達している場合はトークンが補充されるまで待機します
+			if !waitPaused(ctx, cfg.pause, pc) || !pc.Wait(ctx) || !ceiling.Wait(ctx) {
+				return
+			}
+			if targets != nil {
+				// 選んだターゲットにレート上限がある場合は、全ワーカー共有のリミッターで空きスロットまで待機します
+				target := targets.pick(rng)
+				if !target.limiter.Wait(ctx) || !sendRequest(ctx, traceCtx, target.clientFor(client), target.baseReq, cb, target.inject.delay(rng), cfg, metrics, coldStart) {
+					return
+				}
+				atomic.AddUint64(&target.completed, 1)
+				completed++
+				coldStart = false
+				continue
+			}
+			if cfg.replay != nil {
+				if !sendRequest(ctx, traceCtx, client, cfg.replay.pick(rng), cb, cfg.inject.delay(rng), cfg, metrics, coldStart) {
+					return
+				}
+				completed++
+				coldStart = false
+				continue
+			}
+			if !sendRequest(ctx, traceCtx, client, baseReq, cb, cfg.inject.delay(rng), cfg, metrics, coldStart) {
+				return
+			}
+			completed++
+			coldStart = false
+		}
+	}
+}
 
-			// ベースリクエストをクローンし、コンテキスト（タイムアウト・キャンセル用）を付与します。
-			// 完全な新規作成よりアロケーションを抑えられます。
-			req := baseReq.Clone(ctx)
+// newTraceContext は、新規接続の数と新規TLS接続のネゴシエーション結果をメトリクスへ記録する
+// トレース付きのコンテキストを返します。
+func newTraceContext(ctx context.Context, metrics *ResultMetrics) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if !info.Reused {
+				atomic.AddUint64(&metrics.ConnectionsOpened, 1)
+			}
+			metrics.recordRequestIP(info.Conn)
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				metrics.RecordTLS(state)
+			}
+		},
+	})
+}
 
-			// リクエスト実行
-			resp, err := client.Do(req)
-			duration := time.Since(start)
+// sendRequest は、ベースリクエストを複製して1件送信し、結果をメトリクスへ記録します。
+// ctx（テストまたはワーカーのコンテキスト）がキャンセルされて中断した場合は、記録せずに false を返します。
+// traceCtx は ctx から派生させた、TLS情報記録用のトレース付きコンテキストです。
+// cb が nil でない場合は、複製したリクエストにキャッシュバスティング用の一意な値を付与します。
+// injectDelay が正の場合は、送信直前にその時間だけ遅延を注入し、その時間もレイテンシに含めます（inject.go を参照）。
+// coldStart が true の場合は、ワーカーの最初のリクエストとして、レイテンシを通常の統計とは別に記録します（coldstart.go を参照）。
+func sendRequest(ctx, traceCtx context.Context, client *http.Client, baseReq *http.Request, cb *cacheBuster, injectDelay time.Duration, cfg *TestConfig, metrics *ResultMetrics, coldStart bool) bool {
+	start := time.Now()
+
+	// 遅延の注入が有効な場合は、送信前に待機します（待機中にテストが終了した場合は送信しません）。
+	// 注入した遅延がリクエストのタイムアウトを消費しないよう、リクエストのコンテキストを生成する前に待機します
+	injected, ok := sleepInjected(ctx, injectDelay)
+	if !ok {
+		return false
+	}
 
-			if err != nil {
-				// タイムアウト、ネットワーク切断などのエラー
-				metrics.Record(duration, 0, true)
-				continue
-			}
+	// このリクエスト専用の、タイムアウト付きの子コンテキストを生成します（テスト終了時のキャンセルは traceCtx から伝わります）。
+	// ボディの読み出しもタイムアウトの対象にするため、関数を抜けるまでキャンセルしません
+	reqCtx, cancelReq := withRequestTimeout(traceCtx, cfg)
+	defer cancelReq()
+
+	// ベースリクエストをクローンし、コンテキスト（タイムアウト・キャンセル用）を付与します。
+	// 完全な新規作成よりアロケーションを抑えられます。
+	req := baseReq.Clone(reqCtx)
+	if req.GetBody != nil {
+		// ボディは複製されないため、ベースリクエストのボディから読み出し位置の独立したものを作り直します
+		req.Body, _ = req.GetBody()
+	}
+	cb.apply(req)
+	cfg.churn.apply(req)
+
+	// TTFB を記録する設定の場合は、このリクエスト専用のトレースを仕込みます
+	var timing requestTiming
+	if recordsTTFB(cfg) {
+		req = req.WithContext(withTiming(req.Context(), &timing))
+	}
+
+	// リクエスト実行（実際に通信中のリクエスト数を、タイムライン用にアトミックに増減させます）
+	// 通信中の時間の集計には、注入した遅延を含めません
+	atomic.AddUint64(&metrics.SentRequests, 1)
+	metrics.beginRequest()
+	resp, err := client.Do(req)
+	duration := time.Since(start)
+	metrics.endRequest(duration - injected)
+
+	if err != nil {
+		// テスト終了やワーカー停止によって中断されたリクエストは、ターゲットの問題ではないため記録しません
+		if ctx.Err() != nil {
+			return false
+		}
+		// タイムアウト、ネットワーク切断などのエラー
+		err = cfg.certRequest.annotate(err)
+		metrics.RecordNetworkError(duration, err)
+		metrics.errLog.printf("[Request Error]", "%s", requestErrorMessage(err))
+		metrics.captureExchange(req, nil, err, duration)
+		metrics.slowest.observe(req, nil, err, start, duration)
+		cfg.failFast.checkNetworkError(req, err)
+		return true
+	}
+	metrics.captureExchange(req, resp, nil, duration)
+	metrics.slowest.observe(req, resp, nil, start, duration)
+	recordConnectionClose(metrics, req, resp)
+	recordSlow(metrics, cfg, duration)
+	if !timing.firstByte.IsZero() {
+		metrics.addTTFB(timing.firstByte.Sub(start))
+	}
+
+	// no_drain_body が指定されている場合は、ボディを読まずに閉じます（未読の部分が大きい場合、この接続は再利用されません）
+	if cfg.NoDrainBody {
+		resp.Body.Close()
+		kind := recordResponse(metrics, cfg, duration, resp.StatusCode, coldStart)
+		cfg.failFast.checkResponse(cfg, req, resp.StatusCode, kind)
+		if cfg.HonorRetryAfter && resp.StatusCode == http.StatusTooManyRequests {
+			return honorRetryAfter(ctx, metrics, resp.Header)
+		}
+		return true
+	}
 
-			// 【重要】超高負荷対応のボディ破棄
-			// レスポンスボディを最後まで読み切らないと、TCPコネクションがプールに返却されません。
-			// io.Copy(io.Discard) を使い、データをメモリに確保せずブラックホールに捨てます。
-			_, _ = io.Copy(io.Discard, resp.Body)
-			
-			// ループ内での defer resp.Body.Close() は、スコープを抜けるまで実行が遅延し
-			// メモリリークやファイルディスクリプタの枯渇を招くため、必ず即座に手動で Close します。
-			resp.Body.Close()
+	// レスポンスサイズの上限が設定されている場合は、上限+1バイトまでしか読まずに打ち切ります。
+	// 巨大なレスポンスを延々と返す異常なターゲットから、テスター自身を保護するためです。
+	if cfg.MaxResponseBytes > 0 {
+		assertOK, n := true, int64(0)
+		if assertsResponse(cfg, resp.StatusCode) {
+			assertOK, n = checkJSONAssertions(io.LimitReader(resp.Body, cfg.MaxResponseBytes+1), cfg.assertions)
+		}
+		n += drainAndClose(resp, cfg.MaxResponseBytes+1-n)
+		if cfg.Trace {
+			metrics.addFullResponse(time.Since(start))
+		}
 
-			// 成功または HTTPステータスエラー（404や500など）の記録
-			metrics.Record(duration, resp.StatusCode, false)
+		failedKind := ""
+		if n > cfg.MaxResponseBytes {
+			failedKind = errKindResponseTooLarge
+		} else if !assertOK {
+			failedKind = errKindJSONAssertion
+		}
+		if failedKind != "" {
+			metrics.RecordFailure(duration, resp.StatusCode, failedKind, coldStart)
+		} else {
+			failedKind = recordResponse(metrics, cfg, duration, resp.StatusCode, coldStart)
 		}
+		cfg.failFast.checkResponse(cfg, req, resp.StatusCode, failedKind)
+		if cfg.HonorRetryAfter && resp.StatusCode == http.StatusTooManyRequests {
+			return honorRetryAfter(ctx, metrics, resp.Header)
+		}
+		return true
+	}
+
+	// 【重要】超高負荷対応のボディ破棄
+	// レスポンスボディを最後まで読み切らないと、TCPコネクションがプールに返却されません。
+	// io.Discard へコピーし、データをメモリに確保せずブラックホールに捨てます。
+	// JSONアサーションが指定されている場合のみ、先頭部分を読み込んで解析してから残りを捨てます。
+	assertOK := true
+	if assertsResponse(cfg, resp.StatusCode) {
+		assertOK, _ = checkJSONAssertions(resp.Body, cfg.assertions)
+	}
+
+	// 読み切った直後に手動で Close し、コネクションを1秒でも早くプールへ返却します（drain.go を参照）。
+	drainAndClose(resp, drainAll)
+	if cfg.Trace {
+		metrics.addFullResponse(time.Since(start))
+	}
+
+	// 成功または HTTPステータスエラー（404や500など）の記録
+	if assertOK {
+		kind := recordResponse(metrics, cfg, duration, resp.StatusCode, coldStart)
+		cfg.failFast.checkResponse(cfg, req, resp.StatusCode, kind)
+	} else {
+		metrics.RecordFailure(duration, resp.StatusCode, errKindJSONAssertion, coldStart)
+		cfg.failFast.checkResponse(cfg, req, resp.StatusCode, errKindJSONAssertion)
+	}
+
+	// レート制限された場合は、ターゲットが指定した時間だけこのワーカーの次の送信を控えます
+	if cfg.HonorRetryAfter && resp.StatusCode == http.StatusTooManyRequests {
+		return honorRetryAfter(ctx, metrics, resp.Header)
+	}
+	return true
+}
+
+// recordResponse は、受信した応答をステータスコードに応じて記録し、失敗として記録した場合はそのエラー種別を返します。
+// redirects_are_errors が指定されている場合、3xx は "redirect" エラーとして記録します。
+// slow_is_error が指定されている場合、slow_threshold を超えた 2xx・3xx は "slow_response" エラーとして記録します。
+func recordResponse(metrics *ResultMetrics, cfg *TestConfig, duration time.Duration, statusCode int, coldStart bool) string {
+	if cfg.RedirectsAreErrors && statusCode >= 300 && statusCode < 400 {
+		metrics.RecordFailure(duration, statusCode, errKindRedirect, coldStart)
+		return errKindRedirect
 	}
+	if cfg.SlowIsError && isSlow(cfg, duration) && statusCode >= 200 && statusCode < 400 {
+		metrics.RecordFailure(duration, statusCode, errKindSlow, coldStart)
+		return errKindSlow
+	}
+	metrics.record(sample{dur: duration, status: statusCode, coldStart: coldStart}, false)
+	return ""
 }
 // ==============================================================================
 // [セクション3] 10万RPS対応: オーケストレーターと高速集計ロジック
 // ==============================================================================
 
-// formatDuration は time.Duration をフロントエンドで表示しやすいミリ秒単位の文字列に変換します。
-// 例: 1.23ms
+// formatDuration は time.Duration を、大きさに応じた単位（ns / µs / ms / s）の表示用文字列に変換します。
+// 例: 850ns, 3.42µs, 1.23ms, 2.50s
+// 常にミリ秒で表示すると、localhost へのテストで一般的な数マイクロ秒のレイテンシが "0.00ms" になってしまうためです。
+// いずれの単位も time.ParseDuration で読み戻せる形式です（-merge はこれを利用して統合します）。
+// 小数第2位で丸めると次の単位の 1000 になる値（999.996µs など）は、"1000.00µs" ではなく次の単位で表示します。
 func formatDuration(d time.Duration) string {
-	return fmt.Sprintf("%.2fms", float64(d.Microseconds())/1000.0)
+	switch {
+	case d < time.Microsecond:
+		return fmt.Sprintf("%dns", d.Nanoseconds())
+	case d < time.Millisecond-5*time.Nanosecond:
+		return fmt.Sprintf("%.2fµs", float64(d)/float64(time.Microsecond))
+	case d < time.Second-5*time.Microsecond:
+		return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+	default:
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	}
+}
+
+// LatencySummary は、1種類のレイテンシ分布（応答時間、接続確立時間など）の要約統計です。
+type LatencySummary struct {
+	Samples int    `json:"samples"`
+	Min     string `json:"min"`
+	Mean    string `json:"mean"`
+	StdDev  string `json:"stddev,omitempty"`
+	P50     string `json:"p50"`
+	P90     string `json:"p90"`
+	P99     string `json:"p99"`
+	Max     string `json:"max"`
+}
+
+// summaryDurations は、LatencySummary の各値を文字列に丸める前の time.Duration で表したものです。
+type summaryDurations struct {
+	min, mean, p50, p90, p99, max time.Duration
+	stdDev                        time.Duration
+	hasStdDev                     bool // 標準偏差を算出できたかどうか（文字列から戻した要約に標準偏差がない場合は false）
+}
+
+// summary は、値を文字列に丸めた LatencySummary を返します。
+// nil の場合（応答を1件も受信できなかった場合）は、各項目が "N/A" の要約を返します。
+func (v *summaryDurations) summary(samples int) LatencySummary {
+	summary := LatencySummary{Samples: samples}
+	if v == nil {
+		// "0.00ms" と表示すると「瞬時に応答した」と誤解されるため、計測不能であることを明示します
+		na := "N/A"
+		summary.Min, summary.Mean, summary.P50 = na, na, na
+		summary.P90, summary.P99, summary.Max = na, na, na
+		return summary
+	}
+	summary.Min, summary.Mean, summary.Max = formatDuration(v.min), formatDuration(v.mean), formatDuration(v.max)
+	summary.P50, summary.P90, summary.P99 = formatDuration(v.p50), formatDuration(v.p90), formatDuration(v.p99)
+	if v.hasStdDev {
+		summary.StdDev = formatDuration(v.stdDev)
+	}
+	return summary
+}
+
+// summarizeLatencies は、レイテンシのスライスから最小・平均・標準偏差・パーセンタイル・最大を算出します。
+// 渡されたスライスはその場で並べ替えられ、p50・p90・p99 と percentiles（%）の各パーセンタイルの位置が
+// ソートした場合と同じ値になります（件数が少なければ全件がソートされます。selection.go を参照）。
+func summarizeLatencies(latencies []time.Duration, percentiles ...float64) LatencySummary {
+	return latencyDurations(latencies, percentiles...).summary(len(latencies))
+}
+
+// latencyDurations は、summarizeLatencies と同じ要約統計を文字列に丸める前の値で返します（0件の場合は nil）。
+// 渡されたスライスは summarizeLatencies と同じくその場で並べ替えられます。
+func latencyDurations(latencies []time.Duration, percentiles ...float64) *summaryDurations {
+	var stats latencyStats
+	for _, l := range latencies {
+		stats.add(l)
+	}
+	orderLatencies(latencies, percentiles, 0)
+	return orderedDurations(latencies, &stats)
+}
+
+// summarizeWithStats は、記録時に逐次集計済みの stats から最小・平均・標準偏差・最大を、
+// latencies からパーセンタイルを算出します。集計のための全件の走査は並べ替えの1回だけです。
+// 渡されたスライスはその場で並べ替えられます（summarizeLatencies と同じです）。
+func summarizeWithStats(latencies []time.Duration, stats *latencyStats) LatencySummary {
+	orderLatencies(latencies, nil, 0)
+	return summarizeOrdered(latencies, stats)
+}
+
+// summarizeOrdered は、orderLatencies で並べ替え済みの latencies と逐次集計値 stats から要約を算出します。
+func summarizeOrdered(latencies []time.Duration, stats *latencyStats) LatencySummary {
+	return orderedDurations(latencies, stats).summary(len(latencies))
+}
+
+// orderedDurations は、orderLatencies で並べ替え済みの latencies と逐次集計値 stats から、
+// 文字列に丸める前の要約統計を算出します（0件の場合は nil）。
+func orderedDurations(latencies []time.Duration, stats *latencyStats) *summaryDurations {
+	totalLatencies := len(latencies)
+	if totalLatencies == 0 {
+		return nil
+	}
+	// 最小値・平均値・標準偏差・最大値は逐次集計値から、
+	// パーセンタイル（p50, p90, p99）は並べ替えで確定させた位置の値から求めます
+	return &summaryDurations{
+		min:       stats.min,
+		mean:      time.Duration(stats.mean),
+		max:       stats.max,
+		stdDev:    stats.stdDev(),
+		hasStdDev: true,
+		p50:       latencies[percentileIndex(totalLatencies, 0.50)],
+		p90:       latencies[percentileIndex(totalLatencies, 0.90)],
+		p99:       latencies[percentileIndex(totalLatencies, 0.99)],
+	}
+}
+
+// loadCounterMap は、文字列キーと *uint64 カウンタを持つ sync.Map を通常のマップに変換します。
+// 1件も記録されていない場合は、JSONで省略されるよう nil を返します。
+func loadCounterMap(m *sync.Map) map[string]uint64 {
+	var result map[string]uint64
+	m.Range(func(key, value interface{}) bool {
+		if result == nil {
+			result = make(map[string]uint64)
+		}
+		result[key.(string)] = atomic.LoadUint64(value.(*uint64))
+		return true
+	})
+	return result
+}
+
+// statusClasses は、ステータスコードごとの件数（TestReport.StatusCodes 形式）を
+// "2xx" / "3xx" / "4xx" / "5xx" / "network" のクラス単位に集約します。
+// 主要な5クラスは件数が0でも常に含め、1xx など想定外のコードは "other" にまとめます。
+func statusClasses(codes map[string]uint64) map[string]uint64 {
+	classes := map[string]uint64{"2xx": 0, "3xx": 0, "4xx": 0, "5xx": 0, "network": 0}
+	for code, count := range codes {
+		if code == "NetworkError" {
+			classes["network"] += count
+			continue
+		}
+		n, err := strconv.Atoi(code)
+		if err != nil || n < 200 || n >= 600 {
+			classes["other"] += count
+			continue
+		}
+		classes[fmt.Sprintf("%dxx", n/100)] += count
+	}
+	return classes
+}
+
+// statusErrorRates は、ステータスコードごとの件数（TestReport.StatusCodes 形式）から、すべてのリクエスト（通信エラーを含みます）に
+// 占める 5xx と 4xx の応答の割合（0〜1）を求めます（リクエストが0件の場合は0）。
+func statusErrorRates(codes map[string]uint64) (server, client float64) {
+	classes := statusClasses(codes)
+	var total uint64
+	for _, count := range classes {
+		total += count
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(classes["5xx"]) / float64(total), float64(classes["4xx"]) / float64(total)
+}
+
+// errorRate は、総リクエスト数に占めるエラーの割合（0〜1）を返します（リクエストが0件の場合は0）。
+func errorRate(failed, total int) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(failed) / float64(total)
+}
+
+// timeoutRatePct は、総リクエスト数に占めるタイムアウトの割合（%）を返します（リクエストが0件の場合は0）。
+func timeoutRatePct(timedOut uint64, total int) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(timedOut) / float64(total) * 100
 }
 
 // generateReport は、収集されたメトリクスと実際の実行時間から、フロントエンドへ返すJSONレポートを生成します。
-func generateReport(metrics *ResultMetrics, actualDuration time.Duration) *TestReport {
+func generateReport(metrics *ResultMetrics, actualDuration, pausedDuration time.Duration) *TestReport {
 	report := &TestReport{
-		TotalRequests: int(atomic.LoadUint64(&metrics.TotalRequests)),
-		Success:       int(atomic.LoadUint64(&metrics.SuccessCount)),
-		Errors:        int(atomic.LoadUint64(&metrics.ErrorCount)),
+		TotalRequests: int(metrics.TotalRequests.Load()),
+		Success:       int(metrics.SuccessCount.Load()),
+		Errors:        int(metrics.ErrorCount.Load()),
 		StatusCodes:   make(map[string]uint64),
 	}
 
 	// 1. 実際のスループット (RPS: Requests Per Second) の計算
-	// 実行時間が0になるゼロ除算エラーを防ぐための安全策
-	durationSec := actualDuration.Seconds()
-	if durationSec <= 0 {
-		durationSec = 0.0001
+	// 実行時間が0の場合（開始直後にキャンセルされた場合など）は、ゼロ除算を避けるとともに、
+	// わずかな件数を極小の時間で割った非現実的なスループットを報告しないよう、レートを0とします
+	// 一時停止していた時間は送信していないため、スループットの算出からは除きます
+	report.ActualDurationSec = actualDuration.Seconds()
+	report.PausedSec = pausedDuration.Seconds()
+	durationSec := (actualDuration - pausedDuration).Seconds()
+	if durationSec > 0 {
+		report.ThroughputRPS = float64(report.TotalRequests) / durationSec
 	}
-	report.ThroughputRPS = float64(report.TotalRequests) / durationSec
 
 	// 2. HTTPステータスコード分布の集計
 	metrics.StatusCodes.Range(func(key, value interface{}) bool {
@@ -257,97 +1827,553 @@ func generateReport(metrics *ResultMetrics, actualDuration time.Duration) *TestR
 		return true
 	})
 
+	report.StatusClasses = statusClasses(report.StatusCodes)
+	report.ErrorRate = errorRate(report.Errors, report.TotalRequests)
+	report.ServerErrorRate, report.ClientErrorRate = statusErrorRates(report.StatusCodes)
+	report.InFlightCapHits = atomic.LoadUint64(&metrics.InFlightCapHits)
+	report.ConnectionsOpened = atomic.LoadUint64(&metrics.ConnectionsOpened)
+	report.RequestsPerConnection = requestsPerConnection(report.TotalRequests, report.ConnectionsOpened)
+	report.PeakInFlight = atomic.LoadInt64(&metrics.PeakInFlight)
+	if durationSec > 0 {
+		report.AvgInFlight = float64(atomic.LoadInt64(&metrics.InFlightNanos)) / float64(time.Second) / durationSec
+	}
+	report.SkippedOverload = atomic.LoadUint64(&metrics.SkippedOverload)
+	report.RateLimited = atomic.LoadUint64(&metrics.RateLimited)
+	report.BackoffTotalSec = time.Duration(atomic.LoadInt64(&metrics.BackoffNanos)).Seconds()
+	report.ErrorKinds = loadCounterMap(&metrics.ErrorKinds)
+	report.TimedOut = atomic.LoadUint64(&metrics.TimedOut)
+	report.TimeoutRatePct = timeoutRatePct(report.TimedOut, report.TotalRequests)
+	report.JSONAssertionFailures = report.ErrorKinds[errKindJSONAssertion]
+	report.SlowResponses = atomic.LoadUint64(&metrics.SlowResponses)
+	report.SlowRatePct = slowRatePct(report.SlowResponses, report.TotalRequests)
+	report.ConnectionCloseResponses = atomic.LoadUint64(&metrics.ConnectionCloseResponses)
+	report.ConnectionClosePct = connectionClosePct(report.ConnectionCloseResponses, report.TotalRequests)
+
+	report.TLSVersions = loadCounterMap(&metrics.TLSVersions)
+	report.TLSCipherSuites = loadCounterMap(&metrics.TLSCipherSuites)
+	report.RequestsByIP = loadCounterMap(&metrics.RequestsByIP)
+
 	// 3. レイテンシ（応答時間）のパーセンタイルと統計計算
 	metrics.mu.Lock()
 	// 高速化のため、ここでスライスの参照だけを取得し、以後はロック不要で処理します
 	latencies := metrics.latencies
+	sampling := metrics.samplingActive
+	stats := metrics.latencyStats
+	metrics.histogram.fill(report)
+	report.RPSTimeline = metrics.rpsTimeline
+	report.ConcurrencyTimeline = metrics.concurrencyTimeline
+	report.SuccessRateTimeline = metrics.successRateTimeline
+	report.NewConnectionsTimeline = metrics.newConnTimeline
+	report.TargetRPSTimeline = metrics.targetRPSTimeline
+	report.ConcurrencyTrajectory = metrics.trajectory
+	report.Burstiness = metrics.burstiness
+	report.IntervalSnapshots = metrics.snapshots
+	report.SampleExchanges = metrics.exchanges
+	report.SlowestRequests = metrics.slowest.result()
+	report.WorkerDistribution = workerDistribution(metrics.workerCounts)
 	metrics.mu.Unlock()
 
-	totalLatencies := len(latencies)
+	// 最小値・平均値・標準偏差・最大値は、記録時の逐次集計値（全件）から求めます。
+	// リザーバーサンプリングが作動した場合も、パーセンタイル以外は全件から算出された正確な値になります
+	// レポートで参照するすべてのパーセンタイルとトリム平均の境界の位置を、1度の並べ替えで確定させます
+	orderLatencies(latencies, metrics.percentiles, trimmedMeanFraction)
+	values := orderedDurations(latencies, &stats)
+	summary := values.summary(len(latencies))
+	report.latency = values
+	report.LatencySamples = summary.Samples
+	report.MinLatency, report.MeanLatency, report.P50Latency = summary.Min, summary.Mean, summary.P50
+	report.P90Latency, report.P99Latency, report.MaxLatency = summary.P90, summary.P99, summary.Max
+	report.StdDevLatency = summary.StdDev
+	// 裾のパーセンタイルとトリム平均の位置は orderLatencies で確定済みです
+	report.TailPercentiles, report.PercentileWarnings = tailPercentiles(latencies, metrics.percentiles)
+	if metrics.apdexTarget > 0 && len(latencies) > 0 {
+		score := apdexScore(latencies, metrics.apdexTarget)
+		report.ApdexTarget, report.Apdex = metrics.apdexTarget.String(), &score
+	}
+	if trimmedMeanFraction > 0 {
+		if mean, ok := trimmedMean(latencies, trimmedMeanFraction); ok {
+			report.TrimmedMean, report.TrimmedMeanFraction = formatDuration(mean), trimmedMeanFraction
+		}
+	}
 
-	if totalLatencies > 0 {
-		// スライスを昇順にソート（数百万件でもGoの標準ソートは非常に高速です）
-		sort.Slice(latencies, func(i, j int) bool {
-			return latencies[i] < latencies[j]
-		})
-
-		// 最小値と最大値
-		report.MinLatency = formatDuration(latencies[0])
-		report.MaxLatency = formatDuration(latencies[totalLatencies-1])
-
-		// 平均値の計算（オーバーフローを防ぐため、マイクロ秒単位で合算して平均を取ります）
-		var sumMicro int64
-		for _, l := range latencies {
-			sumMicro += l.Microseconds()
-		}
-		meanMicro := float64(sumMicro) / float64(totalLatencies)
-		report.MeanLatency = fmt.Sprintf("%.2fms", meanMicro/1000.0)
-
-		// パーセンタイル（p50, p90, p99）のインデックス計算
-		p50Idx := int(float64(totalLatencies) * 0.50)
-		p90Idx := int(float64(totalLatencies) * 0.90)
-		p99Idx := int(float64(totalLatencies) * 0.99)
-
-		// インデックスが配列の範囲を超えないよう安全装置（フェイルセーフ）を設ける
-		if p50Idx >= totalLatencies { p50Idx = totalLatencies - 1 }
-		if p90Idx >= totalLatencies { p90Idx = totalLatencies - 1 }
-		if p99Idx >= totalLatencies { p99Idx = totalLatencies - 1 }
-
-		report.P50Latency = formatDuration(latencies[p50Idx])
-		report.P90Latency = formatDuration(latencies[p90Idx])
-		report.P99Latency = formatDuration(latencies[p99Idx])
-	} else {
-		// リクエストが1件も成功・記録されなかった場合のフォールバック
-		zero := "0.00ms"
-		report.MinLatency, report.MeanLatency, report.P50Latency = zero, zero, zero
-		report.P90Latency, report.P99Latency, report.MaxLatency = zero, zero, zero
+	if sampling {
+		report.SamplingEngaged = true
+		report.LatencyObserved = stats.count
+	}
+
+	// 4. WebSocketモードの場合は、接続確立時間の分布も併せて集計します
+	metrics.mu.Lock()
+	connectLatencies := metrics.connectLatencies
+	ttfbLatencies := metrics.ttfbLatencies
+	fullLatencies := metrics.fullLatencies
+	coldStartLatencies := metrics.coldStartLatencies
+	metrics.mu.Unlock()
+
+	if len(fullLatencies) > 0 {
+		fullSummary := summarizeLatencies(fullLatencies)
+		report.FullResponse = &fullSummary
+	}
+
+	if len(ttfbLatencies) > 0 {
+		ttfbSummary := summarizeLatencies(ttfbLatencies)
+		report.TTFB = &ttfbSummary
+	}
+
+	if len(connectLatencies) > 0 {
+		connectSummary := summarizeLatencies(connectLatencies)
+		report.WSConnect = &connectSummary
+	}
+
+	if len(coldStartLatencies) > 0 {
+		coldStartSummary := summarizeLatencies(coldStartLatencies)
+		report.ColdStart = &coldStartSummary
 	}
 
+	applyRunStatus(report)
 	return report
 }
 
+// テスト全体の結果（TestReport.Status）
+const (
+	runStatusOK        = "ok"         // 1件以上のリクエストが成功した（またはリクエストが0件だった）
+	runStatusAllFailed = "all_failed" // 送信したリクエストがすべて失敗した
+)
+
+// applyRunStatus は、成功数からテスト全体の結果を判定し、レポートに設定します。
+// ターゲットが停止している場合などにすべてのリクエストが失敗すると、スループットは「失敗を返す速さ」にすぎず、
+// レイテンシも（4xx/5xx を受信していれば）エラー応答だけから算出された値になります。
+// 通常の結果と取り違えないよう、その旨を status と latency_error_only で明示します。
+func applyRunStatus(report *TestReport) {
+	report.Status = runStatusOK
+	report.LatencyErrorOnly = false
+	if report.TotalRequests > 0 && report.Success == 0 {
+		report.Status = runStatusAllFailed
+		report.LatencyErrorOnly = report.LatencySamples > 0
+	}
+}
+
+// workerPool は、テスト実行中にワーカー数を増減できるよう、ワーカーごとの停止関数を保持します。
+// 停止は後から起動したワーカーから順に行います（スタック）。
+type workerPool struct {
+	ctx   context.Context
+	wg    *sync.WaitGroup
+	spawn func(ctx context.Context, wg *sync.WaitGroup, index int)
+
+	mu      sync.Mutex
+	cancels []context.CancelFunc
+}
+
+// newWorkerPool は、ctx の終了とともに全ワーカーが停止するワーカープールを生成します。
+// spawn は、渡されたコンテキストで1ワーカーを起動し、終了時に wg.Done を呼ぶ必要があります。
+// index はワーカーの番号（0始まり）で、停止後に同じ枠へ再起動したワーカーには同じ番号が渡されます。
+func newWorkerPool(ctx context.Context, wg *sync.WaitGroup, spawn func(ctx context.Context, wg *sync.WaitGroup, index int)) *workerPool {
+	return &workerPool{ctx: ctx, wg: wg, spawn: spawn}
+}
+
+// Resize は、稼働中のワーカー数が n になるようにワーカーを起動または停止します。
+// テスト終了後（ctx のキャンセル後）は新たなワーカーを起動しません。
+func (p *workerPool) Resize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.cancels) < n && p.ctx.Err() == nil {
+		workerCtx, cancel := context.WithCancel(p.ctx)
+		index := len(p.cancels)
+		p.cancels = append(p.cancels, cancel)
+		p.wg.Add(1)
+		p.spawn(workerCtx, p.wg, index)
+	}
+	for len(p.cancels) > n {
+		last := len(p.cancels) - 1
+		p.cancels[last]()
+		p.cancels = p.cancels[:last]
+	}
+}
+
+// newWorkerRand は、シード値とワーカー番号から決定的に導出した、ワーカー専用の乱数生成器を返します。
+// ワーカーごとに独立した生成器を持たせることで、高RPS時のグローバルな乱数源のロック競合も避けられます。
+func newWorkerRand(seed uint64, index int) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, uint64(index)))
+}
+
+// Size は、稼働中（停止を指示していない）のワーカー数を返します。
+func (p *workerPool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.cancels)
+}
+
+// sampleTimeline は、テスト実行中に1秒ごとのスループット（完了リクエスト数の差分）と成功率（成功数の差分の割合）、
+// 通信中のリクエスト数、新規接続の数（newTraceContext が数える累計の差分）をサンプリングし、メトリクスのタイムラインへ追記します。
+// ctx がキャンセルされると done をクローズして終了します（端数の1秒未満は記録しません）。
+func sampleTimeline(ctx context.Context, metrics *ResultMetrics, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var lastTotal, lastSuccess, lastConns uint64
+	var lastTarget float64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// 成功数を先に読むことで、直後に完了したリクエストの分だけ成功数が総数を上回ることを防ぎます
+			success := metrics.SuccessCount.Load()
+			total := metrics.TotalRequests.Load()
+			inFlight := atomic.LoadInt64(&metrics.InFlight)
+			conns := atomic.LoadUint64(&metrics.ConnectionsOpened)
+
+			var successRate *float64
+			if completed := total - lastTotal; completed > 0 {
+				rate := min(float64(success-lastSuccess)/float64(completed), 1)
+				successRate = &rate
+			}
+
+			metrics.mu.Lock()
+			metrics.rpsTimeline = append(metrics.rpsTimeline, total-lastTotal)
+			metrics.concurrencyTimeline = append(metrics.concurrencyTimeline, inFlight)
+			metrics.successRateTimeline = append(metrics.successRateTimeline, successRate)
+			metrics.newConnTimeline = append(metrics.newConnTimeline, conns-lastConns)
+			if metrics.shape != nil {
+				// 目標レートは、その1秒間の目標の累計件数の増分です
+				target := metrics.shape.cumulative(now.Sub(metrics.shape.start))
+				metrics.targetRPSTimeline = append(metrics.targetRPSTimeline, target-lastTarget)
+				lastTarget = target
+			}
+			metrics.mu.Unlock()
+
+			lastTotal, lastSuccess, lastConns = total, success, conns
+		}
+	}
+}
+
 // runLoadTest はフロントエンドからの設定を受け取り、負荷テスト全体を指揮（オーケストレーション）します。
-func runLoadTest(cfg *TestConfig) *TestReport {
-	// メモリ事前割り当てのための推定総リクエスト数を計算
-	// (並行数 * 予想RPS * 秒数) で大まかなキャパシティを算出します
-	estimatedTotal := cfg.Concurrency * 100 * cfg.DurationSec
-	if estimatedTotal <= 0 {
-		estimatedTotal = 10000 // フォールバック値
+// テストは指定された実行時間が経過するか、parent がキャンセルされた時点で終了します（forever の場合は後者のみ）。
+// metrics には NewResultMetrics で初期化したものを渡します。実行中の進捗を外部から参照するためです。
+func runLoadTest(parent context.Context, cfg *TestConfig, metrics *ResultMetrics) *TestReport {
+	// 比較ターゲットが指定されている場合は、A と B のテストをそれぞれ実行して比較します（compare.go を参照）
+	if cfg.CompareTargetURL != "" {
+		return runComparison(parent, cfg, metrics)
+	}
+	metrics.maxSamples = cfg.MaxSamples
+	metrics.captureLimit = int64(cfg.CaptureSamples)
+	metrics.percentiles = cfg.Percentiles
+	metrics.apdexTarget = time.Duration(cfg.ApdexTarget)
+	metrics.histogram = newLatencyHistogram(cfg)
+	metrics.errLog = newErrorLog()
+
+	// ワーカーを起動する前に、ターゲットへ到達できることを少数のプローブで確認します
+	if !cfg.NoPreflight {
+		for _, pc := range preflightConfigs(cfg) {
+			if err := runPreflight(parent, pc); err != nil {
+				log.Printf("[Preflight] %v\n", err)
+				return &TestReport{StatusCodes: make(map[string]uint64), ErrorMsg: err.Error()}
+			}
+		}
 	}
 
-	// ゼロアロケーションを目指すメトリクス構造体の初期化
-	metrics := NewResultMetrics(estimatedTotal)
+	// 全ワーカー合計の送信レートの安全上限（未指定の場合は nil）
+	ceiling := newRPSCeiling(cfg.MaxRPS)
+
+	// 複数ターゲットモードの送信先（未指定の場合は nil）
+	targets, err := newTargetSet(cfg)
+	if err != nil {
+		log.Printf("[Orchestrator Error] %v\n", err)
+		return &TestReport{StatusCodes: make(map[string]uint64), ErrorMsg: err.Error()}
+	}
+
+	// 接続確立の同時実行数の制限と失敗数の集計は、以降に生成するすべてのダイヤラーで共有します
+	cfg.dialGate = newDialGate(cfg.DialConcurrency)
+	cfg.dialGate.phases = newConnectPhases(cfg)
+	cfg.dns = &dnsStats{}
+	cfg.certRequest = &clientCertRequest{}
+	cfg.ipSpread = newIPSpreader(cfg.SpreadIPs)
+	metrics.recordsIPs = cfg.SpreadIPs
+	cfg.churn = newConnChurn(cfg.MaxRequestsPerConn)
+	cfg.inject = newLatencyInjector(time.Duration(cfg.InjectLatency), time.Duration(cfg.InjectLatencyJitter))
+
+	// リクエストファイルの再生（未指定の場合は nil）
+	cfg.replay, err = newRequestReplay(cfg.requestSpecs, cfg.RequestOrder)
+	if err != nil {
+		log.Printf("[Orchestrator Error] %v\n", err)
+		return &TestReport{StatusCodes: make(map[string]uint64), ErrorMsg: err.Error()}
+	}
 
 	// OSリソースを極限まで使い倒す最適化済みHTTPクライアントの生成
-	client := createOptimizedHTTPClient(cfg.Concurrency, cfg.TimeoutSec)
+	// 適応型負荷モードでは、ワーカーが上限まで増えても接続数で頭打ちにならないよう上限値でプールを確保します
+	poolSize := cfg.Concurrency
+	if cfg.Adaptive {
+		poolSize = newAdaptiveParams(cfg).maxConcurrency
+	}
+	// オープンモデルとトレースの再生では、通信中のリクエスト数の上限に合わせます
+	if cfg.LoadModel == loadModelOpen || cfg.traceReplay != nil {
+		poolSize = cfg.MaxInFlight
+	}
+	client := createOptimizedHTTPClient(poolSize, cfg)
+	defer client.CloseIdleConnections()
+	// 複数ターゲットのホストが2つ以上ある場合は、ホストごとに専用のプールを割り当てます（hostpool.go を参照）
+	pools := newHostPools(cfg, targets, poolSize)
+	defer pools.closeIdleConnections()
+
+	// 指定されている場合は、計測を始める前にコネクションプールを温めておきます（ここでの通信は一切記録しません）
+	var prewarmed int
+	if cfg.PrewarmConnections {
+		// 接続の上限がワーカー数より少ない場合は、上限の本数だけを温めます（上限を超える分は接続の空きを待ち続けてしまうためです）
+		n := cfg.Concurrency
+		if cfg.Connections > 0 {
+			n = min(n, cfg.Connections)
+		}
+		prewarmClient := client
+		if targets != nil {
+			// 複数ターゲットモードでは先頭のターゲットへ送信するため、そのホストのプールを温めます
+			prewarmClient = targets.targets[0].clientFor(client)
+		}
+		prewarmed = prewarmConnections(parent, prewarmClient, cfg, n)
+	}
 
 	// コンテキストによる実行時間の厳格な管理
 	// 指定された秒数が経過すると、全ワーカーへ一斉にキャンセルシグナルが送信されます
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.DurationSec)*time.Second)
+	// forever の場合はタイマーを設けず、停止要求（parent のキャンセル）のみで終了します
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if cfg.Forever {
+		ctx, cancel = context.WithCancel(parent)
+	} else {
+		ctx, cancel = context.WithTimeout(parent, time.Duration(cfg.Duration))
+	}
 	defer cancel()
 
 	var wg sync.WaitGroup
 
-	log.Printf("[Orchestrator] テストを開始します: %s, 並行数: %d, 実行時間: %d秒\n", cfg.TargetURL, cfg.Concurrency, cfg.DurationSec)
+	if cfg.Forever {
+		log.Printf("[Orchestrator] テストを開始します: %s, 並行数: %d, 実行時間: 停止されるまで\n", cfg.TargetURL, cfg.Concurrency)
+	} else {
+		log.Printf("[Orchestrator] テストを開始します: %s, 並行数: %d, 実行時間: %s\n", cfg.TargetURL, cfg.Concurrency, time.Duration(cfg.Duration))
+	}
+	logSocketOptions(cfg)
+
+	// 乱数のシード値を決定します（未指定の場合はランダムに選び、再現できるようレポートに記録します）
+	// ブラウザのJavaScriptで桁落ちせずに扱えるよう、自動生成するシード値は 2^53 未満にします
+	seed := rand.Uint64() >> 11
+	if cfg.Seed != nil {
+		seed = *cfg.Seed
+	}
+	log.Printf("[Orchestrator] 乱数シード: %d\n", seed)
+	// サンプリングの置き換えもシード値から決めるため、同じシード値のテストでは同じ結果の列から同じサンプルが残ります
+	metrics.mu.Lock()
+	metrics.sampleRand = newSamplingRand(seed)
+	metrics.mu.Unlock()
+	// 生成ボディの送信量は、プリフライトのプローブを除いてテストごとに数えます（random の中身もシード値から作り直します）
+	cfg.genBody = cfg.genBody.forRun(newGenBodyRand(seed))
 
 	// 正確なスループット計算のための開始時間記録
 	startTime := time.Now()
+	cfg.failFast = newFailFast(cfg.FailFast, cancel, startTime)
+	floor := newThroughputFloor(cfg, cancel, startTime)
+	metrics.shape = newTrafficShape(cfg, startTime)
+	if metrics.shape != nil {
+		log.Printf("[Traffic Shape] 目標レートを %s で変化させます (基準: %.2f リクエスト/秒, 周期: %v)\n",
+			cfg.TrafficShape, cfg.RateLimit, time.Duration(cfg.ShapePeriod))
+	}
+	metrics.slowest = newSlowestTracker(cfg.TopSlowest, cfg.TopSlowestHeaders, startTime)
+
+	// 指定されている場合は、全リクエストのトレースの書き出しを開始します（オフセットの起点はテスト開始時刻です）
+	if cfg.TraceOut != "" {
+		trace, err := openTraceWriter(cfg.TraceOut, startTime)
+		if err != nil {
+			log.Printf("[Trace Error] トレースファイルを作成できません: %v\n", err)
+			return &TestReport{StatusCodes: make(map[string]uint64), ErrorMsg: fmt.Sprintf("トレースファイルを作成できません: %v", err)}
+		}
+		metrics.trace = trace
+	}
+	metrics.sink = newMetricsSink()
+
+	// 1秒ごとのスループットと同時実行数のサンプリングを開始
+	timelineDone := make(chan struct{})
+	go sampleTimeline(ctx, metrics, timelineDone)
+
+	// 100ミリ秒ごとの送信数（バースト性）の計測を開始
+	burstDone := make(chan struct{})
+	go sampleBurstiness(ctx, metrics, burstDone)
+
+	// スループットの下限が指定されている場合は、直近の RPS の監視を開始します
+	floorDone := make(chan struct{})
+	go floor.monitor(ctx, metrics, floorDone)
+
+	// 途中経過のスナップショットと適応型負荷モードは、直近の区間の結果を使用します。
+	// 起動直後の結果も区間に含めるため、ワーカーより先に区間の集計を開始しておきます
+	snapshotDone := make(chan struct{})
+	if cfg.ReportIntervalSec > 0 {
+		go runIntervalReporter(ctx, cfg, newIntervalSampler(metrics), metrics, snapshotDone)
+	} else {
+		close(snapshotDone)
+	}
+	var adaptiveSampler *intervalSampler
+	if cfg.Adaptive {
+		adaptiveSampler = newIntervalSampler(metrics)
+	}
 
 	// 限界突破のワーカー一斉起動（GoのGoroutineは非常に軽量なため、数万個でも瞬時に起動します）
-	for i := 0; i < cfg.Concurrency; i++ {
+	pool := newWorkerPool(ctx, &wg, func(workerCtx context.Context, wg *sync.WaitGroup, index int) {
+		switch {
+		case cfg.Mode == modeWebSocket:
+			go executeWSWorker(workerCtx, wg, cfg, metrics, newWorkerRand(seed, index))
+		case cfg.IsolatedClients:
+			// ワーカー専用のクライアントは、ワーカーの終了とともにアイドル接続を閉じて解放します
+			workerClient := createOptimizedHTTPClient(1, cfg)
+			go func() {
+				defer workerClient.CloseIdleConnections()
+				executeWorker(workerCtx, wg, workerClient, cfg, metrics, targets, ceiling, index, newWorkerRand(seed, index))
+			}()
+		default:
+			go executeWorker(workerCtx, wg, client, cfg, metrics, targets, ceiling, index, newWorkerRand(seed, index))
+		}
+	})
+	if cfg.traceReplay != nil {
+		// トレースの再生では、ワーカーの代わりに1つのディスパッチャーが記録された時刻どおりにリクエストを発生させます
+		wg.Add(1)
+		go executeTraceDispatcher(ctx, &wg, client, cfg, metrics, newWorkerRand(seed, 0))
+	} else if cfg.LoadModel == loadModelOpen {
+		// オープンモデルでは、ワーカーの代わりに1つのディスパッチャーがリクエストを発生させます
 		wg.Add(1)
-		go executeWorker(ctx, &wg, client, cfg.TargetURL, cfg.Method, metrics)
+		go executeOpenDispatcher(ctx, &wg, client, cfg, metrics, ceiling, newWorkerRand(seed, 0))
+	} else {
+		pool.Resize(cfg.Concurrency)
+	}
+
+	// ランプダウンが指定されている場合は、実行時間の終盤にワーカー数を段階的に減らします
+	rampDownDone := make(chan struct{})
+	if cfg.RampDownSec > 0 {
+		go runRampDown(ctx, cfg, pool, startTime, rampDownDone)
+	} else {
+		close(rampDownDone)
+	}
+
+	// 適応型負荷モードでは、コントローラーが一定間隔でワーカー数を調整します
+	controllerDone := make(chan struct{})
+	if cfg.Adaptive {
+		go runAdaptiveController(ctx, cfg, pool, adaptiveSampler, metrics, controllerDone)
+	} else {
+		close(controllerDone)
 	}
 
 	// すべてのワーカーが終了（またはタイムアウトでキャンセル）するまでブロックして待機
+	// コントローラーが WaitGroup へワーカーを追加し終えてから待機する必要があるため、先に終了を待ちます
+	<-controllerDone
 	wg.Wait()
+	// 省略したエラーの件数を、集計結果の前に出力します
+	metrics.errLog.flush()
+	if cfg.traceReplay != nil {
+		// トレースの再生は、最後のリクエストの応答を受け取った時点で終了します（実行時間の残りを待ちません）
+		cancel()
+	}
+	<-timelineDone
+	<-burstDone
+	<-floorDone
+	<-snapshotDone
+	<-rampDownDone
 
 	// 実際の実行時間を計測（コンテキストによる停止処理にかかったわずかな時間も含みます）
 	actualDuration := time.Since(startTime)
 	log.Printf("[Orchestrator] テスト完了。実際の実行時間: %v. 結果を集計中...\n", actualDuration)
 
 	// 収集したメトリクスから最終レポートを生成して返す
-	return generateReport(metrics, actualDuration)
+	pausedDuration, pauses := cfg.pause.pausedTotal()
+	report := generateReport(metrics, actualDuration, pausedDuration)
+	report.Pauses = pauses
+	report.Seed = seed
+	report.ToolVersion = toolVersion
+	report.Tags = cfg.Tags
+	report.Warnings = cfg.warnings
+	report.BytesUploaded = cfg.genBody.uploadedBytes()
+	report.CacheBust = cfg.CacheBust
+	report.DialConcurrency = cfg.DialConcurrency
+	report.Connections = cfg.Connections
+	report.MaxRequestsPerConn = cfg.MaxRequestsPerConn
+	report.RequestSpecs = len(cfg.requestSpecs)
+	report.DialErrors = atomic.LoadUint64(&cfg.dialGate.errors)
+	report.DialErrorsOpening = atomic.LoadUint64(&cfg.dialGate.openingErrors)
+	warmupDialErrors := atomic.LoadUint64(&cfg.dialGate.warmupErrors)
+	report.ConnectPhases = cfg.dialGate.phases.report(warmupDialErrors, report.DialErrors-warmupDialErrors)
+	report.DNSServer = cfg.DNSServer
+	report.DNSLookups = atomic.LoadUint64(&cfg.dns.lookups)
+	report.DNSErrors = atomic.LoadUint64(&cfg.dns.failures)
+	report.AvgDNSLookupMs = cfg.dns.avgMs()
+	report.PrewarmedConnections = prewarmed
+	report.FailFast = cfg.failFast.result()
+	report.ThroughputFloor = floor.result()
+	report.TrafficShape = cfg.TrafficShape
+	report.AbortedReason = abortedReason(report)
+	report.RequestsPerConnection = requestsPerConnection(report.TotalRequests, report.ConnectionsOpened+uint64(prewarmed))
+	if warning := cfg.traceReplay.fillReport(report, cfg.ReplayTrace); warning != "" {
+		log.Printf("[Replay Warning] %s\n", warning)
+		report.Warnings = append(report.Warnings, warning)
+	}
+	if warning := connectionCloseWarning(report); warning != "" {
+		log.Printf("[Connection Warning] %s\n", warning)
+		report.Warnings = append(report.Warnings, warning)
+	}
+	report.InjectedLatency = cfg.inject.String()
+	report.LatencySimulated = injectsLatency(cfg)
+	report.HTTP3 = cfg.HTTP3
+	if cfg.SlowThreshold > 0 {
+		report.SlowThreshold = time.Duration(cfg.SlowThreshold).String()
+		report.SlowIsError = cfg.SlowIsError
+	}
+	if metrics.sink != nil {
+		if err := metrics.sink.Close(); err != nil {
+			log.Printf("[Sink Error] メトリクスシンクのクローズに失敗しました: %v\n", err)
+		}
+	}
+	if metrics.trace != nil {
+		records, err := metrics.trace.Close()
+		if err != nil {
+			log.Printf("[Trace Error] トレースの書き出しに失敗しました: %v\n", err)
+			report.TraceError = err.Error()
+		} else {
+			log.Printf("[Trace] %d 件のリクエストを %s へ書き出しました\n", records, cfg.TraceOut)
+			report.TracedTo = cfg.TraceOut
+			report.TraceRecords = records
+		}
+	}
+	if targets != nil {
+		report.Targets = targets.report(actualDuration - pausedDuration)
+		report.HostPools = pools.report()
+	}
+	if ceiling != nil {
+		report.MaxRPS = cfg.MaxRPS
+		report.MaxRPSCapHits = ceiling.Hits()
+		report.MaxRPSEngaged = report.MaxRPSCapHits > 0
+	}
+
+	// 指定されている場合は、レイテンシのヒストグラムを Prometheus のテキスト形式で書き出します
+	if cfg.LatencyHistogramPath != "" {
+		if err := writeLatencyHistogram(cfg.LatencyHistogramPath, report); err != nil {
+			log.Printf("[Histogram Error] ヒストグラムの書き出しに失敗しました: %v\n", err)
+			report.LatencyHistogramError = err.Error()
+		} else {
+			log.Printf("[Histogram] レイテンシのヒストグラムを %s へ書き出しました (%d 件)\n", cfg.LatencyHistogramPath, report.LatencyHistogramCount)
+			report.LatencyHistogramTo = cfg.LatencyHistogramPath
+		}
+	}
+
+	// 指定されている場合は、生サンプルを含む結果をバイナリ形式で書き出します
+	if cfg.ExportPath != "" {
+		metrics.mu.Lock()
+		samples := metrics.latencies
+		metrics.mu.Unlock()
+
+		if err := writeExport(cfg.ExportPath, report, samples); err != nil {
+			log.Printf("[Export Error] 結果の書き出しに失敗しました: %v\n", err)
+			report.ExportError = err.Error()
+		} else {
+			log.Printf("[Export] 結果を %s へ書き出しました (%d サンプル)\n", cfg.ExportPath, len(samples))
+			report.ExportedTo = cfg.ExportPath
+		}
+	}
+	return report
 }
 // ==============================================================================
 // [セクション4] 10万RPS対応: APIサーバー基盤（CORS突破・JSONハンドリング）
@@ -358,69 +2384,193 @@ func runLoadTest(cfg *TestConfig) *TestReport {
 func enableCORS(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+strictValidationHeader)
 }
 
-// handleAPI は、フロントエンド（Web UI）からの負荷テスト実行リクエストを受け付けるエンドポイントです。
-func handleAPI(w http.ResponseWriter, r *http.Request) {
-	// 1. CORS制限の解除設定
-	enableCORS(w)
+// serverWriteTimeout は、APIサーバーのレスポンス書き込みのタイムアウトです。
+// テストはジョブ（/api/start）として非同期に実行され、各レスポンスは小さく即座に返るため、短い値で十分です。
+// 読み込みの遅いクライアントが接続を占有し続けることを防ぎます。
+const serverWriteTimeout = 30 * time.Second
+
+// newAPIServer は、addr で待ち受けるAPIサーバーを生成します。
+// タイムアウトを適切に設定し、スローロリス攻撃(Slowloris)などのコネクション枯渇攻撃からシステムを守ります。
+// writeTimeout には通常 serverWriteTimeout を指定します（テストでは短い値に差し替えます）。
+func newAPIServer(addr string, handler http.Handler, writeTimeout time.Duration) *http.Server {
+	return &http.Server{
+		Addr:        addr,             // 待ち受けるポート番号
+		Handler:     handler,          // カスタムマルチプレクサを指定
+		ReadTimeout: 10 * time.Second, // リクエストヘッダーの読み込みタイムアウト
+		// テストは非同期ジョブとして実行されるため、WriteTimeout は短く保ちます。
+		// 同期API（/api/run）はリクエストごとに extendWriteDeadline で期限を延長します。
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  120 * time.Second, // キープアライブ通信時の待機タイムアウト
+	}
+}
 
-	// ブラウザからのプリフライトリクエスト (OPTIONS) には 200 OK を返して即終了
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
+// extendWriteDeadline は、テストの完了までレスポンスを返せない同期APIのために、
+// このリクエストに限って書き込み期限を「今から d + serverWriteTimeout」まで延長します。
+// 処理の所要時間が事前に分かっているリクエストだけを延長するため、他のエンドポイントの保護は弱まりません。
+func extendWriteDeadline(w http.ResponseWriter, d time.Duration) {
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(d + serverWriteTimeout)); err != nil {
+		log.Printf("[API Error] 書き込み期限の延長に失敗しました: %v\n", err)
 	}
+}
 
-	// 負荷テストの実行指示は POST メソッドのみ受け付けます
-	if r.Method != http.MethodPost {
-		http.Error(w, `{"error_msg": "POSTメソッドのみ許可されています"}`, http.StatusMethodNotAllowed)
-		return
+// isValidMethod は、method がHTTPメソッドとして送信可能なトークン（RFC 9110 の token）かどうかを判定します。
+// http.NewRequest と同じ規則で、PATCH や PURGE などの標準外のメソッドも受け付けます。
+func isValidMethod(method string) bool {
+	if method == "" {
+		return false
+	}
+	for _, c := range []byte(method) {
+		isAlnum := 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+		if !isAlnum && !strings.ContainsRune("!#$%&'*+-.^_`|~", rune(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeTargetURL は、ターゲットURLを検証し、スキームが省略されている場合は補います。
+// 不正なURLのまま起動すると、全ワーカーが同じエラーで失敗したり、何も送信せずに終了したりするため、
+// ワーカーを起動する前にここで1度だけ分かりやすいエラーとして報告します。
+// スキームを補った場合は、その旨を warning で返します。
+func normalizeTargetURL(raw, mode string) (normalized, warning string, err error) {
+	raw = strings.TrimSpace(raw)
+	allowed := []string{"http", "https"}
+	if mode == modeWebSocket {
+		allowed = []string{"ws", "wss", "http", "https"}
 	}
 
-	// 2. フロントエンドからのJSONペイロードの読み込みと解析
+	if !strings.Contains(raw, "://") {
+		// "example.com" や "localhost:8080/path" のようにスキームが省略された場合は、安全側の https（wss）を補います
+		scheme := "https"
+		if mode == modeWebSocket {
+			scheme = "wss"
+		}
+		warning = fmt.Sprintf("URLにスキームが指定されていないため %s:// を補いました: %s://%s", scheme, scheme, raw)
+		raw = scheme + "://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("ターゲットURLの形式が正しくありません: %v", err)
+	}
+	if !slices.Contains(allowed, strings.ToLower(u.Scheme)) {
+		return "", "", fmt.Errorf("ターゲットURLのスキーム %q には対応していません (%s のいずれかを指定してください)", u.Scheme, strings.Join(allowed, " / "))
+	}
+	if u.Hostname() == "" {
+		return "", "", fmt.Errorf("ターゲットURLにホスト名がありません: %q", raw)
+	}
+	return u.String(), warning, nil
+}
+
+// readTestConfig は、リクエストボディのJSONを TestConfig として読み込み、
+// 入力値のバリデーションと安全なデフォルト値へのフォールバックを行います。
+// 不正な入力の場合はエラーレスポンスを書き込み済みの状態で ok=false を返します。
+func readTestConfig(w http.ResponseWriter, r *http.Request) (*TestConfig, bool) {
 	var cfg TestConfig
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		log.Printf("[API Error] リクエストボディの読み込みに失敗しました: %v\n", err)
 		http.Error(w, `{"error_msg": "無効なリクエストボディです"}`, http.StatusBadRequest)
-		return
+		return nil, false
 	}
 	defer r.Body.Close()
 
+	// 厳格モードでは、デフォルト値を適用する前の値を検査し、誤りがあれば一覧を 422 で返します
+	if strictRequested(r) {
+		if errs := validateStrict(body); len(errs) > 0 {
+			log.Printf("[API Error] 厳格バリデーションで %d 件の誤りを検出しました\n", len(errs))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(TestReport{ErrorMsg: fmt.Sprintf("設定に %d 件の誤りがあります", len(errs)), FieldErrors: errs})
+			return nil, false
+		}
+	}
+
 	if err := json.Unmarshal(body, &cfg); err != nil {
 		log.Printf("[API Error] JSONの解析に失敗しました: %v\n", err)
+		if errors.Is(err, errInvalidDuration) || errors.Is(err, errInvalidByteSize) {
+			writeConfigError(w, err.Error())
+			return nil, false
+		}
 		http.Error(w, `{"error_msg": "JSONフォーマットが正しくありません"}`, http.StatusBadRequest)
-		return
+		return nil, false
 	}
 
-	// 3. 入力値の厳格なバリデーションと安全なデフォルト値へのフォールバック
-	if cfg.TargetURL == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(TestReport{ErrorMsg: "ターゲットURLが指定されていません"})
+	if err := validateConfig(&cfg); err != nil {
+		writeConfigError(w, err.Error())
+		return nil, false
+	}
+	return &cfg, true
+}
+
+// handleAPI は、フロントエンド（Web UI）からの負荷テスト実行リクエストを受け付けるエンドポイントです。
+func handleAPI(w http.ResponseWriter, r *http.Request) {
+	// 1. CORS制限の解除設定
+	enableCORS(w)
+
+	// ブラウザからのプリフライトリクエスト (OPTIONS) には 200 OK を返して即終了
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
 		return
 	}
-	if cfg.Method == "" {
-		cfg.Method = "GET"
+
+	// 負荷テストの実行指示は POST メソッドのみ受け付けます
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error_msg": "POSTメソッドのみ許可されています"}`, http.StatusMethodNotAllowed)
+		return
 	}
-	if cfg.Concurrency <= 0 {
-		cfg.Concurrency = 100 // 安全なデフォルト値
+
+	// レポートのフォーマット（?format=hey など。未指定時はJSON）は、テストを実行する前に検証します
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = outputFormatJSON
 	}
-	if cfg.DurationSec <= 0 {
-		cfg.DurationSec = 10 // 安全なデフォルト値
+	if !validOutputFormat(format) {
+		writeConfigError(w, "format には json・hey・wrk のいずれかを指定してください")
+		return
 	}
-	if cfg.TimeoutSec <= 0 {
-		cfg.TimeoutSec = 5 // デフォルトのタイムアウト
+
+	// 2. フロントエンドからのJSONペイロードの読み込み・解析・バリデーション
+	cfg, ok := readTestConfig(w, r)
+	if !ok {
+		return
 	}
 
 	log.Printf("[API] 負荷テストのリクエストを受信しました。ターゲット: %s", cfg.TargetURL)
 
-	// 4. 負荷テストエンジンの起動（オーケストレーターの呼び出し）
-	// ここでメインスレッドはテスト完了までブロックされます
-	report := runLoadTest(&cfg)
+	// 停止されるまで実行するテストは、レスポンスを返せないため同期APIでは受け付けません
+	if cfg.Forever {
+		writeConfigError(w, "forever は /api/start（ジョブ実行）でのみ指定できます")
+		return
+	}
 
-	// 5. テスト結果（レポート）をJSONとしてフロントエンドへ返却
+	// 3. 負荷テストエンジンの起動（オーケストレーターの呼び出し）
+	// ここでメインスレッドはテスト完了までブロックされます
+	// サーバーの WriteTimeout は短いため、プリフライトを含むテストの最大所要時間だけ書き込み期限を延長します
+	timeout := time.Duration(cfg.TimeoutSec) * time.Second
+	testTime := time.Duration(cfg.Duration) + (preflightProbes+1)*timeout
+	if cfg.CompareMode == compareSequential {
+		// 比較ターゲットを順に実行する場合は、2回分のテストの時間がかかります
+		testTime *= 2
+	}
+	extendWriteDeadline(w, testTime)
+	// ゼロアロケーションを目指すメトリクス構造体の初期化（推定総リクエスト数は ExpectedRPSPerWorker のヒントを基に算出します）
+	report := runLoadTest(context.Background(), cfg, NewResultMetrics(estimateTotalRequests(cfg)))
+
+	// 4. テスト結果（レポート）をJSONとしてフロントエンドへ返却
+	if format != outputFormatJSON {
+		// hey / wrk 互換のテキストは、既存の解析スクリプトへそのまま渡せるようプレーンテキストで返します
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		if err := writeReport(w, report, format, cfg.TargetURL); err != nil {
+			log.Printf("[API Error] レポートの書き出しに失敗しました: %v\n", err)
+		}
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	
@@ -463,20 +2613,23 @@ const indexHTML = `<!DOCTYPE html>
         .form-group { display: flex; flex-direction: column; }
         .form-group.full { grid-column: span 2; }
         label { font-weight: 600; margin-bottom: 0.5rem; font-size: 0.95rem; color: #4b5563; }
-        input, select {
+        input, select, textarea {
             padding: 0.75rem;
             border: 1px solid #d1d5db;
             border-radius: 6px;
             font-size: 1rem;
             transition: border-color 0.2s;
         }
-        input:focus, select:focus { outline: none; border-color: #2563eb; box-shadow: 0 0 0 3px rgba(37,99,235,0.1); }
+        textarea { font-family: 'Courier New', monospace; font-size: 0.9rem; }
+        input:focus, select:focus, textarea:focus { outline: none; border-color: #2563eb; box-shadow: 0 0 0 3px rgba(37,99,235,0.1); }
         button {
             background-color: #2563eb; color: white; border: none; padding: 1rem;
             width: 100%; border-radius: 6px; font-size: 1.1rem; font-weight: bold;
             cursor: pointer; transition: background-color 0.2s;
         }
         button:hover { background-color: #1d4ed8; }
+        button.secondary { background-color: #4b5563; margin-top: 0.75rem; font-size: 1rem; }
+        button.secondary:hover { background-color: #374151; }
         button:disabled { background-color: #9ca3af; cursor: not-allowed; }
         
         #results { margin-top: 2rem; display: none; }
@@ -485,6 +2638,7 @@ const indexHTML = `<!DOCTYPE html>
             border-radius: 8px; font-family: 'Courier New', monospace;
             white-space: pre-wrap; word-break: break-all; font-size: 0.95rem;
         }
+        .ws-only { display: none; }
         .status-error { color: #ef4444; }
         .status-loading { color: #f59e0b; }
     </style>
@@ -503,41 +2657,258 @@ const indexHTML = `<!DOCTYPE html>
             <input type="text" id="url" placeholder="https://example.com/api" required>
         </div>
         
+        <div class="form-group">
+            <label for="mode">プロトコル</label>
+            <select id="mode" onchange="toggleMode()">
+                <option value="http">HTTP</option>
+                <option value="ws">WebSocket</option>
+            </select>
+        </div>
+
+        <div class="form-group ws-only">
+            <label for="wsMessage">WebSocket 送信メッセージ</label>
+            <input type="text" id="wsMessage" value="ping">
+        </div>
+
+        <div class="form-group ws-only">
+            <label for="wsInterval">WebSocket 送信間隔 (ミリ秒, 0 = エコー受信後すぐ)</label>
+            <input type="number" id="wsInterval" value="0" min="0">
+        </div>
+
         <div class="form-group">
             <label for="method">HTTP メソッド</label>
-            <select id="method">
+            <select id="method" onchange="toggleMethod()">
                 <option value="GET">GET</option>
                 <option value="POST">POST</option>
                 <option value="PUT">PUT</option>
+                <option value="PATCH">PATCH</option>
                 <option value="DELETE">DELETE</option>
+                <option value="HEAD">HEAD</option>
+                <option value="OPTIONS">OPTIONS</option>
+                <option value="CUSTOM">カスタム...</option>
             </select>
+            <input type="text" id="customMethod" placeholder="例: PURGE" style="display: none; margin-top: 0.5rem;">
         </div>
         
         <div class="form-group">
-            <label for="concurrency">並行ワーカー数 (1 - 100000)</label>
-            <input type="number" id="concurrency" value="1000" min="1">
+            <label for="concurrency" id="concurrencyLabel">並行ワーカー数 (1 - 100000)</label>
+            <input type="number" id="concurrency" value="1000" min="1" max="100000" title="サーバーの上限 (-max-concurrency): 100000">
         </div>
         
         <div class="form-group">
-            <label for="duration">実行時間 (秒)</label>
-            <input type="number" id="duration" value="10" min="1">
+            <label for="duration">実行時間 (秒、または 500ms / 1m30s のような指定)</label>
+            <input type="text" id="duration" value="10">
+            <label><input type="checkbox" id="forever" onchange="document.getElementById('duration').disabled = this.checked"> 停止ボタンを押すまで実行し続ける</label>
         </div>
 
         <div class="form-group">
             <label for="timeout">タイムアウト (秒)</label>
             <input type="number" id="timeout" value="5" min="1">
         </div>
+
+        <div class="form-group">
+            <label for="maxResponseBytes">最大レスポンスサイズ (バイト, 0 = 無制限)</label>
+            <input type="number" id="maxResponseBytes" value="0" min="0">
+        </div>
+        <div class="form-group full">
+            <label for="advanced">詳細設定 (JSON, 任意)</label>
+            <textarea id="advanced" rows="3" placeholder='例: {"client_cert": "/path/to/client.pem", "client_key": "/path/to/client-key.pem", "tls_max_version": "1.2"}'></textarea>
+        </div>
     </div>
 
     <button id="runBtn" onclick="startTest()">🔥 限界負荷テストを開始</button>
+    <button id="stopBtn" class="secondary" onclick="stopTest()" style="display: none;">⏹ テストを停止 (途中までの結果を表示)</button>
+    <button id="pauseBtn" class="secondary" onclick="togglePause()" style="display: none;">⏸ 一時停止 (接続と集計は保持)</button>
+    <button id="explainBtn" class="secondary" onclick="explainTest()">🔍 curlで確認 (1リクエストだけ送信)</button>
 
     <div id="results">
         <h2 style="font-size: 1.5rem; color: #374151;">📊 実行レポート</h2>
         <div id="output" class="result-box"></div>
+        <div id="timelineBox" style="display: none; margin-top: 1.5rem;">
+            <h3 style="font-size: 1.1rem; color: #374151;">📈 タイムライン (1秒ごと)</h3>
+            <canvas id="timeline" width="720" height="220" style="width: 100%; background: #1f2937; border-radius: 8px;"></canvas>
+            <div id="timelineLegend" style="font-size: 0.85rem; color: #6b7280; margin-top: 0.5rem;"></div>
+        </div>
     </div>
 </div>
 
 <script>
+    // フォームの入力値からAPIへ送信するJSONペイロードを組み立てます
+    // 詳細設定欄に入力されたJSONは、フォームの値を上書きする形でマージされます
+    // 実行時間の入力欄は、数字だけなら秒数として、それ以外（"500ms" など）は文字列のままサーバーへ渡します
+    function parseDurationInput(value) {
+        const trimmed = value.trim();
+        return /^[0-9]+(\.[0-9]+)?$/.test(trimmed) ? parseFloat(trimmed) : trimmed;
+    }
+
+    function buildPayload() {
+        const payload = {
+            target_url: document.getElementById('url').value,
+            method: selectedMethod(),
+            concurrency: parseInt(document.getElementById('concurrency').value, 10),
+            duration: parseDurationInput(document.getElementById('duration').value),
+            timeout: parseInt(document.getElementById('timeout').value, 10),
+            mode: document.getElementById('mode').value,
+            ws_message: document.getElementById('wsMessage').value,
+            ws_interval_ms: parseInt(document.getElementById('wsInterval').value, 10) || 0,
+            max_response_bytes: parseInt(document.getElementById('maxResponseBytes').value, 10) || 0,
+            forever: document.getElementById('forever').checked
+        };
+
+        const advanced = document.getElementById('advanced').value.trim();
+        if (advanced) {
+            Object.assign(payload, JSON.parse(advanced));
+        }
+        return payload;
+    }
+
+    // 「カスタム...」を選んだ場合は自由入力欄のメソッドを使います（大文字・小文字は区別されるため入力のまま送信します）
+    function selectedMethod() {
+        const method = document.getElementById('method').value;
+        return method === 'CUSTOM' ? document.getElementById('customMethod').value.trim() : method;
+    }
+
+    // メソッドの選択に応じて、カスタムメソッドの入力欄の表示を切り替えます
+    function toggleMethod() {
+        const isCustom = document.getElementById('method').value === 'CUSTOM';
+        document.getElementById('customMethod').style.display = isCustom ? 'block' : 'none';
+    }
+
+    // プロトコルの選択に応じて、WebSocket専用の入力欄の表示を切り替えます
+    function toggleMode() {
+        const isWS = document.getElementById('mode').value === 'ws';
+        document.querySelectorAll('.ws-only').forEach(el => el.style.display = isWS ? 'flex' : 'none');
+    }
+
+    // 負荷をかける前に、同等の curl コマンドと1リクエスト分の結果を表示します
+    async function explainTest() {
+        const btn = document.getElementById('explainBtn');
+        const resultsDiv = document.getElementById('results');
+        const output = document.getElementById('output');
+
+        if (!document.getElementById('url').value) {
+            alert("ターゲットURLを入力してください。");
+            return;
+        }
+
+        btn.disabled = true;
+        resultsDiv.style.display = "block";
+        output.className = "result-box status-loading";
+        output.innerText = "[Explain] 1リクエストだけ送信して診断しています...";
+
+        try {
+            const response = await fetch('/api/explain', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(buildPayload())
+            });
+            const data = await response.json();
+
+            let text = "[同等の curl コマンド]\n" + (data.curl_command || "-") + "\n\n";
+            if (data.error_msg) {
+                output.className = "result-box status-error";
+                text += "[Error] " + data.error_msg + "\n";
+                if (data.latency) {
+                    text += "経過時間: " + data.latency + "\n";
+                }
+            } else {
+                output.className = "result-box";
+                text += "[レスポンス]\n";
+                text += "HTTP " + data.status_code + " (" + data.latency + ")\n";
+                for (const [key, value] of Object.entries(data.response_headers || {})) {
+                    text += key + ": " + value + "\n";
+                }
+                if (data.body_preview) {
+                    text += "\n" + data.body_preview;
+                }
+            }
+            output.innerText = text;
+        } catch (error) {
+            output.className = "result-box status-error";
+            output.innerText = "[Fatal Error] バックエンドとの通信に失敗しました。\n" + error.message;
+        } finally {
+            btn.disabled = false;
+        }
+    }
+
+    // 1秒ごとのタイムラインを折れ線グラフとして描画します。
+    // 単位の異なる系列を重ねるため、各系列はそれぞれの最大値で正規化し、凡例に最大値を表示します。
+    // max を指定した系列（成功率など）は、その値を上端として描画します。null の点は線を途切れさせます。
+    function drawTimeline(series) {
+        const box = document.getElementById('timelineBox');
+        const canvas = document.getElementById('timeline');
+        const legend = document.getElementById('timelineLegend');
+        const visible = series.filter(s => s.data && s.data.length > 0);
+        if (visible.length === 0) {
+            box.style.display = "none";
+            return;
+        }
+        box.style.display = "block";
+
+        const ctx = canvas.getContext('2d');
+        const pad = 20;
+        const w = canvas.width - pad * 2;
+        const h = canvas.height - pad * 2;
+        ctx.clearRect(0, 0, canvas.width, canvas.height);
+
+        legend.innerHTML = "";
+        for (const s of visible) {
+            const max = s.max || Math.max(...s.data, 1);
+            const step = s.data.length > 1 ? w / (s.data.length - 1) : 0;
+            ctx.strokeStyle = s.color;
+            ctx.lineWidth = 2;
+            ctx.beginPath();
+            let drawing = false;
+            s.data.forEach((v, i) => {
+                if (v === null) { drawing = false; return; }
+                const x = pad + i * step;
+                const y = pad + h - (v / max) * h;
+                if (!drawing) { ctx.moveTo(x, y); drawing = true; } else { ctx.lineTo(x, y); }
+            });
+            ctx.stroke();
+
+            const item = document.createElement('span');
+            item.style.color = s.color;
+            item.style.marginRight = "1.5rem";
+            item.innerText = "■ " + s.label + (s.max ? " (0〜" + s.max.toLocaleString() + ")" : " (最大 " + max.toLocaleString() + ")");
+            legend.appendChild(item);
+        }
+    }
+
+    // 実行中のジョブID（停止ボタン用）
+    let currentJobId = null;
+
+    // サーバーの並行数の上限（-max-concurrency）を取得し、並行ワーカー数の入力欄の最大値・ラベル・ツールチップに反映します。
+    // 取得できない場合（古いサーバーなど）は、HTMLに書かれた既定の上限のままにします
+    let maxConcurrency = 100000;
+    async function loadLimits() {
+        try {
+            const response = await fetch('/api/limits');
+            if (!response.ok) return;
+            const limits = await response.json();
+            if (!(limits.max_concurrency > 0)) return;
+            maxConcurrency = limits.max_concurrency;
+            const input = document.getElementById('concurrency');
+            input.max = maxConcurrency;
+            input.title = "サーバーの上限 (-max-concurrency): " + maxConcurrency.toLocaleString();
+            document.getElementById('concurrencyLabel').innerText = "並行ワーカー数 (1 - " + maxConcurrency + ")";
+            if (parseInt(input.value, 10) > maxConcurrency) input.value = maxConcurrency;
+        } catch (e) {
+            // 上限を取得できなくても、サーバー側の検査で拒否されるため、テストの実行には支障ありません
+        }
+    }
+    loadLimits();
+
+    // 並行ワーカー数がサーバーの上限を超えている場合は、送信せずに知らせます
+    function concurrencyWithinLimit() {
+        const value = parseInt(document.getElementById('concurrency').value, 10);
+        if (value > maxConcurrency) {
+            alert("並行ワーカー数はサーバーの上限 (" + maxConcurrency.toLocaleString() + ") 以下で指定してください。");
+            return false;
+        }
+        return true;
+    }
+
     async function startTest() {
         const btn = document.getElementById('runBtn');
         const resultsDiv = document.getElementById('results');
@@ -548,74 +2919,385 @@ const indexHTML = `<!DOCTYPE html>
             alert("ターゲットURLを入力してください。");
             return;
         }
+        if (!concurrencyWithinLimit()) {
+            return;
+        }
 
         // UIを待機状態に変更
         btn.disabled = true;
         btn.innerText = "⏳ テスト実行中 (エンジン稼働中)...";
         resultsDiv.style.display = "block";
+        document.getElementById('timelineBox').style.display = "none";
         output.className = "result-box status-loading";
-        output.innerText = "[Orchestrator] バックエンドエンジンにテストを指示しました...\nターゲット: " + url + "\n(指定された実行時間が経過するまでお待ちください)";
-
-        const payload = {
-            target_url: url,
-            method: document.getElementById('method').value,
-            concurrency: parseInt(document.getElementById('concurrency').value, 10),
-            duration: parseInt(document.getElementById('duration').value, 10),
-            timeout: parseInt(document.getElementById('timeout').value, 10)
-        };
+        output.innerText = "[Orchestrator] バックエンドエンジンにテストを指示しました...\nターゲット: " + url + "\n(指定された実行時間が経過するか、停止ボタンを押すまでお待ちください)";
 
         try {
-            // Go言語のAPIハンドラーへPOSTリクエストを送信
-            const response = await fetch('/api/run', {
+            // 詳細設定のJSONが不正な場合もここで捕捉し、エラーとして表示します
+            const payload = buildPayload();
+
+            // テストをジョブとして開始し、完了（または停止）するまで1秒ごとに進捗を問い合わせます
+            const response = await fetch('/api/start', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify(payload)
             });
 
-            const data = await response.json();
+            let job = await response.json();
 
-            if (!response.ok || data.error_msg) {
+            if (!response.ok || job.error_msg) {
                 output.className = "result-box status-error";
-                output.innerText = "[Error] テストに失敗しました:\n" + (data.error_msg || "Unknown Server Error");
+                output.innerText = "[Error] テストに失敗しました:\n" + (job.error_msg || "Unknown Server Error");
+                for (const fe of job.field_errors || []) {
+                    output.innerText += "\n  - " + (fe.field || "(全体)") + ": " + fe.message;
+                }
                 return;
             }
 
-            // 結果のフォーマットと表示
-            output.className = "result-box";
-            let reportText = "==================================================\n";
-            reportText += "✅ テスト完了 (Go Engine API)\n";
-            reportText += "==================================================\n\n";
-            reportText += "[基本統計]\n";
-            reportText += "総リクエスト数 : " + data.total_requests.toLocaleString() + "\n";
-            reportText += "成功 (2xx/3xx) : " + data.success.toLocaleString() + "\n";
-            reportText += "エラー (4xx/5xx): " + data.errors.toLocaleString() + "\n";
-            reportText += "スループット   : " + data.throughput_rps.toFixed(2) + " RPS (リクエスト/秒)\n\n";
-            
-            reportText += "[レイテンシ (応答時間)]\n";
-            reportText += "最小 (Min)   : " + data.min_latency + "\n";
-            reportText += "平均 (Mean)  : " + data.mean_latency + "\n";
-            reportText += "中央値 (p50) : " + data.p50_latency + "\n";
-            reportText += "p90          : " + data.p90_latency + "\n";
-            reportText += "p99          : " + data.p99_latency + "\n";
-            reportText += "最大 (Max)   : " + data.max_latency + "\n\n";
-
-            reportText += "[ステータスコード分布]\n";
-            for (const [code, count] of Object.entries(data.status_codes)) {
-                reportText += "HTTP " + code + " : " + count.toLocaleString() + " 件\n";
+            currentJobId = job.job_id;
+            document.getElementById('stopBtn').style.display = "block";
+            document.getElementById('pauseBtn').style.display = "block";
+            while (job.status !== "done") {
+                const limit = job.forever ? "停止ボタンを押すまで" : job.duration_sec + " 秒";
+                currentJobPaused = !!job.paused;
+                updatePauseButton();
+                output.innerText = "[Orchestrator] " + (job.paused ? "⏸ 一時停止中" : "テスト実行中") + " (ジョブID: " + job.job_id + ")\nターゲット: " + url +
+                    "\n経過時間: " + job.elapsed_sec.toFixed(0) + " 秒 / " + limit +
+                    "\n完了リクエスト数: " + job.total_requests.toLocaleString();
+                if (job.latest_snapshot) {
+                    const snap = job.latest_snapshot;
+                    output.innerText += "\n直近の区間: RPS " + snap.rps.toFixed(1) + " / エラー率 " + (snap.error_rate * 100).toFixed(2) + "% / p99 " + snap.p99_latency;
+                }
+                await new Promise(resolve => setTimeout(resolve, 1000));
+                const poll = await fetch('/api/result/' + job.job_id);
+                job = await poll.json();
+                if (!poll.ok || job.error_msg) {
+                    throw new Error(job.error_msg || "ジョブの状態を取得できませんでした");
+                }
             }
-            reportText += "==================================================";
 
-            output.innerText = reportText;
+            if (job.report.error_msg) {
+                output.className = "result-box status-error";
+                output.innerText = "[Error] テストに失敗しました:\n" + job.report.error_msg;
+                return;
+            }
+            renderReport(job.report);
 
         } catch (error) {
             output.className = "result-box status-error";
             output.innerText = "[Fatal Error] バックエンドとの通信に失敗しました。\n" + error.message;
         } finally {
             // UIの状態をリセット
+            currentJobId = null;
+            currentJobPaused = false;
+            updatePauseButton();
+            document.getElementById('stopBtn').style.display = "none";
+            document.getElementById('pauseBtn').style.display = "none";
             btn.disabled = false;
             btn.innerText = "🔥 限界負荷テストを開始";
         }
     }
+
+    // 実行中のジョブに停止を指示します。結果の表示はポーリング側（startTest）が行います
+    async function stopTest() {
+        if (!currentJobId) {
+            return;
+        }
+        const stopBtn = document.getElementById('stopBtn');
+        stopBtn.disabled = true;
+        try {
+            await fetch('/api/stop/' + currentJobId, { method: 'POST' });
+        } finally {
+            stopBtn.disabled = false;
+        }
+    }
+
+    // 実行中のジョブが一時停止中かどうか（一時停止ボタンの表示用）
+    let currentJobPaused = false;
+
+    // 一時停止ボタンの表示を、現在の状態に合わせます
+    function updatePauseButton() {
+        document.getElementById('pauseBtn').innerText = currentJobPaused ? "▶ 再開" : "⏸ 一時停止 (接続と集計は保持)";
+    }
+
+    // 実行中のジョブの送信を一時停止、または再開します
+    async function togglePause() {
+        if (!currentJobId) {
+            return;
+        }
+        const pauseBtn = document.getElementById('pauseBtn');
+        pauseBtn.disabled = true;
+        try {
+            const response = await fetch((currentJobPaused ? '/api/resume/' : '/api/pause/') + currentJobId, { method: 'POST' });
+            const job = await response.json();
+            if (response.ok) {
+                currentJobPaused = !!job.paused;
+                updatePauseButton();
+            }
+        } finally {
+            pauseBtn.disabled = false;
+        }
+    }
+
+    // 完了したテストのレポートを整形して表示します
+    function renderReport(data) {
+        const output = document.getElementById('output');
+        output.className = "result-box";
+        const allFailed = data.status === "all_failed";
+        let reportText = "==================================================\n";
+        reportText += allFailed ? "❌ テスト完了: すべてのリクエストが失敗しました (Go Engine API)\n" : "✅ テスト完了 (Go Engine API)\n";
+        reportText += "==================================================\n\n";
+        if (allFailed) {
+            reportText += "❌ 成功したリクエストが1件もありません。ターゲットの状態とURLを確認してください。\n";
+            reportText += "   スループットは失敗を返す速さであり、ターゲットの処理能力ではありません。\n\n";
+        }
+        for (const warning of data.warnings || []) {
+            reportText += "⚠️ " + warning + "\n";
+        }
+        if (data.warnings && data.warnings.length > 0) {
+            reportText += "\n";
+        }
+        reportText += "[基本統計]\n";
+        reportText += "総リクエスト数 : " + data.total_requests.toLocaleString() + "\n";
+        reportText += "成功 (2xx/3xx) : " + data.success.toLocaleString() + "\n";
+        reportText += "エラー (4xx/5xx): " + data.errors.toLocaleString() + "\n";
+        if (data.timed_out) {
+            reportText += "  うちタイムアウト: " + data.timed_out.toLocaleString() + " 件 (" + data.timeout_rate_pct.toFixed(2) + "%)\n";
+        }
+        if (data.json_assertion_failures) {
+            reportText += "  うちJSONアサーション失敗: " + data.json_assertion_failures.toLocaleString() + " 件\n";
+        }
+        if (data.slow_threshold) {
+            reportText += "遅い応答 (" + data.slow_threshold + " 超): " + (data.slow_responses || 0).toLocaleString() + " 件 (" + (data.slow_rate_pct || 0).toFixed(2) + "%)" + (data.slow_is_error ? " ※エラーとして記録" : "") + "\n";
+        }
+        if (data.throughput_floor) {
+            const tf = data.throughput_floor;
+            reportText += "⛔ スループットが下限を下回り続けたため停止しました (開始 " + tf.at_sec.toFixed(1) + " 秒後): 直近 " + tf.observed_rps.toFixed(1) + " RPS < 下限 " + tf.floor_rps.toFixed(1) + " RPS (" + tf.below_sec.toFixed(1) + " 秒間)\n";
+        }
+        if (data.fail_fast) {
+            const ff = data.fail_fast;
+            reportText += "⛔ 最初のエラーで停止しました (開始 " + ff.at_sec.toFixed(3) + " 秒後): " + ff.method + " " + ff.url + "\n";
+            reportText += "   " + ff.message + "\n";
+        }
+        reportText += "スループット   : " + data.throughput_rps.toFixed(2) + " RPS (リクエスト/秒)\n";
+        reportText += "実行時間       : " + data.actual_duration_sec.toFixed(2) + " 秒\n";
+        if (data.paused_sec) {
+            reportText += "一時停止       : " + data.paused_sec.toFixed(2) + " 秒 (" + data.pauses + " 回、スループットはこの時間を除いて算出)\n";
+        }
+        if (data.http3) {
+            reportText += "プロトコル     : HTTP/3 (QUIC)\n";
+        } else {
+            reportText += "新規接続数     : " + data.connections_opened.toLocaleString() + " (1接続あたり平均 " + data.requests_per_connection.toFixed(1) + " リクエスト" + (data.max_requests_per_conn ? ", 上限 " + data.max_requests_per_conn : "") + (data.connections ? ", 接続数の上限 " + data.connections.toLocaleString() + " 本" : "") + (data.prewarmed_connections ? ", 事前確立 " + data.prewarmed_connections.toLocaleString() + " 本" : "") + ")\n";
+        }
+        if (data.dns_lookups) {
+            reportText += "名前解決       : 平均 " + data.avg_dns_lookup_ms.toFixed(2) + " ms (" + data.dns_lookups.toLocaleString() + " 回" + (data.dns_errors ? ", 失敗 " + data.dns_errors.toLocaleString() + " 回" : "") + (data.dns_server ? ", DNSサーバー " + data.dns_server : "") + ")\n";
+        }
+        if (data.bytes_uploaded) {
+            reportText += "送信したボディ : " + (data.bytes_uploaded / 1048576).toFixed(1) + " MB (" + (data.bytes_uploaded / 1048576 / (data.actual_duration_sec - (data.paused_sec || 0))).toFixed(1) + " MB/秒)\n";
+        }
+        if (data.latency_simulated) {
+            reportText += "注入した遅延   : " + (data.injected_latency || "ターゲット別") + " (シミュレーション。レイテンシに含まれています)\n";
+        }
+        if (data.dial_errors) {
+            reportText += "接続確立の失敗 : " + data.dial_errors.toLocaleString() + " 件 (うち開始5秒以内: " + data.dial_errors_opening.toLocaleString() + " 件)\n";
+        }
+        if (data.connect_phases) {
+            const cp = data.connect_phases;
+            reportText += "接続タイムアウト: " + cp.connect_timeout;
+            if (cp.warmup_period_sec) {
+                reportText += " (開始 " + cp.warmup_period_sec + " 秒間は " + (cp.warmup_connect_timeout || "timeout のみ") + ")" +
+                    " / 失敗: ウォームアップ " + cp.warmup_dial_errors.toLocaleString() + " 件・定常 " + cp.steady_dial_errors.toLocaleString() + " 件";
+            }
+            reportText += "\n";
+        }
+        if (data.worker_request_distribution) {
+            const wd = data.worker_request_distribution;
+            reportText += "ワーカー間の偏り: 最小 " + wd.min.toLocaleString() + " / 最大 " + wd.max.toLocaleString() + " / 平均 " + wd.mean.toFixed(1) + " 件 (変動係数 " + wd.cv.toFixed(3) + (wd.cv > 0.1 ? " ⚠️ 偏りがあります" : "") + ")\n";
+        }
+        reportText += "実効並行数     : 平均 " + data.avg_inflight.toFixed(1) + " / 最大 " + data.peak_inflight.toLocaleString() + " (通信中だったリクエスト数)\n";
+        if (data.tags && Object.keys(data.tags).length > 0) {
+            reportText += "タグ           : " + Object.entries(data.tags).map(([k, v]) => k + "=" + v).join(", ") + "\n";
+        }
+        if (data.tool_version) {
+            reportText += "ツール         : UltraLoad " + data.tool_version.version + (data.tool_version.commit ? " (" + data.tool_version.commit.slice(0, 12) + ")" : "") + "\n";
+        }
+        if (data.targets && data.targets.length > 0) {
+            reportText += "\n[ターゲット別のレート]\n";
+            for (const t of data.targets) {
+                const limit = t.configured_rps ? t.configured_rps.toFixed(1) + " req/s" : "無制限";
+                reportText += "  " + t.method + " " + t.url + " : " + t.achieved_rps.toFixed(1) + " req/s (上限: " + limit + ", " + t.requests.toLocaleString() + " 件" + (t.injected_latency ? ", 注入遅延 " + t.injected_latency : "") + ")\n";
+            }
+            reportText += "\n";
+        }
+        if (data.cache_bust) {
+            reportText += "キャッシュ回避 : 有効 (" + data.cache_bust + ")\n";
+        }
+        reportText += "乱数シード     : " + data.seed + " (詳細設定に {\"seed\": " + data.seed + "} を指定すると再現できます)\n\n";
+        
+        reportText += data.latency_error_only ? "[レイテンシ (応答時間) ※エラー応答のみから算出]\n" : "[レイテンシ (応答時間)]\n";
+        reportText += "最小 (Min)   : " + data.min_latency + "\n";
+        reportText += "平均 (Mean)  : " + data.mean_latency + (data.stddev_latency ? " (標準偏差 " + data.stddev_latency + ")" : "") + "\n";
+        if (data.trimmed_mean) {
+            reportText += "トリム平均   : " + data.trimmed_mean + " (両端 " + (data.trimmed_mean_fraction * 100).toFixed(1) + "% を除外)\n";
+        }
+        reportText += "中央値 (p50) : " + data.p50_latency + "\n";
+        reportText += "p90          : " + data.p90_latency + "\n";
+        reportText += "p99          : " + data.p99_latency + "\n";
+        if (data.tail_percentiles) {
+            Object.keys(data.tail_percentiles)
+                .sort((a, b) => parseFloat(a.slice(1)) - parseFloat(b.slice(1)))
+                .forEach(label => {
+                    reportText += (label + "             ").slice(0, 13) + ": " + data.tail_percentiles[label] + "\n";
+                });
+        }
+        (data.percentile_warnings || []).forEach(w => {
+            reportText += "  ⚠ " + w + "\n";
+        });
+        if (data.apdex !== undefined) {
+            reportText += "Apdex        : " + data.apdex.toFixed(2) + " (T = " + data.apdex_target + ")\n";
+        }
+        reportText += "最大 (Max)   : " + data.max_latency + "\n";
+        reportText += "計測対象     : " + data.latency_samples.toLocaleString() + " 件 (応答を受信したリクエストのみ)\n";
+        if (data.sampling_engaged) {
+            reportText += "※ サンプル数の上限に達したため、パーセンタイルは " + data.latency_observed.toLocaleString() + " 件からの無作為抽出で算出しています\n";
+        }
+        reportText += "\n";
+
+        if (data.ttfb) {
+            reportText += "[TTFB (最初の1バイトまでの時間)]\n";
+            reportText += "平均: " + data.ttfb.mean + " / p50: " + data.ttfb.p50 + " / p90: " + data.ttfb.p90 + " / p99: " + data.ttfb.p99 + " / 最大: " + data.ttfb.max + "\n\n";
+        }
+        if (data.cold_start_latency) {
+            reportText += "[各ワーカーの初回リクエスト (上記の統計から除外)]\n";
+            reportText += "件数: " + data.cold_start_latency.samples.toLocaleString() + " / 平均: " + data.cold_start_latency.mean + " / p50: " + data.cold_start_latency.p50 + " / p99: " + data.cold_start_latency.p99 + " / 最大: " + data.cold_start_latency.max + "\n\n";
+        }
+        if (data.full_response) {
+            reportText += "[ボディの読み終わりまでの時間]\n";
+            reportText += "平均: " + data.full_response.mean + " / p50: " + data.full_response.p50 + " / p90: " + data.full_response.p90 + " / p99: " + data.full_response.p99 + " / 最大: " + data.full_response.max + "\n\n";
+        }
+        if (data.ws_connect) {
+            reportText += "[WebSocket 接続確立時間]\n";
+            reportText += "接続数 : " + data.ws_connect.samples.toLocaleString() + "\n";
+            reportText += "平均   : " + data.ws_connect.mean + " / p50: " + data.ws_connect.p50 + " / p99: " + data.ws_connect.p99 + " / 最大: " + data.ws_connect.max + "\n\n";
+        }
+
+        if (data.burstiness) {
+            reportText += "[送信のバースト性 (" + data.burstiness.window_ms + "ms 区間)]\n";
+            reportText += "最大: " + data.burstiness.max_per_window.toLocaleString() + " 件 / 平均: " + data.burstiness.mean_per_window.toFixed(1) + " 件 / ピーク対平均比: " + data.burstiness.peak_to_mean.toFixed(2) + "\n\n";
+        }
+
+        if (data.in_flight_cap_hits) {
+            reportText += "[オープンモデルの過負荷]\n";
+            reportText += "通信中の上限到達: " + data.in_flight_cap_hits.toLocaleString() + " 回 / 送信を見送ったリクエスト: " + (data.skipped_overload || 0).toLocaleString() + " 件\n\n";
+        }
+
+        if (data.max_rps) {
+            reportText += "[安全上限 (max_rps)]\n";
+            reportText += "上限: " + data.max_rps.toLocaleString() + " RPS / " + (data.max_rps_engaged ? "作動しました (待機したリクエスト: " + data.max_rps_cap_hits.toLocaleString() + " 件)" : "一度も作動しませんでした") + "\n\n";
+        }
+
+        if (data.rate_limited) {
+            reportText += "[レート制限 (429)]\n";
+            reportText += "429 の件数: " + data.rate_limited.toLocaleString() + " 件 / Retry-After による待機時間の合計: " + (data.backoff_total_sec || 0).toFixed(1) + " 秒\n\n";
+        }
+
+        if (data.sample_exchanges) {
+            reportText += "[捕捉したやり取り (最初の " + data.sample_exchanges.length + " 件)]\n";
+            for (const ex of data.sample_exchanges) {
+                reportText += "> " + ex.request + "\n";
+                for (const [name, values] of Object.entries(ex.request_headers || {})) {
+                    reportText += ">   " + name + ": " + values.join(", ") + "\n";
+                }
+                reportText += "< " + (ex.error ? "エラー: " + ex.error : ex.status) + " (" + ex.latency + ")\n";
+                for (const [name, values] of Object.entries(ex.response_headers || {})) {
+                    reportText += "<   " + name + ": " + values.join(", ") + "\n";
+                }
+            }
+            reportText += "\n";
+        }
+
+        if (data.slowest_requests) {
+            reportText += "[最も遅かったリクエスト (上位 " + data.slowest_requests.length + " 件)]\n";
+            for (const sr of data.slowest_requests) {
+                reportText += sr.latency.padStart(10) + "  " + (sr.error ? "エラー: " + sr.error : sr.status_code) + "  " + sr.method + " " + sr.url + "  (開始 " + sr.at_sec.toFixed(3) + " 秒後)\n";
+                for (const [name, values] of Object.entries(sr.response_headers || {})) {
+                    reportText += "            " + name + ": " + values.join(", ") + "\n";
+                }
+            }
+            reportText += "\n";
+        }
+
+        reportText += "[ステータスクラス]\n";
+        reportText += Object.entries(data.status_classes).map(([cls, count]) => cls + ": " + count.toLocaleString()).join(" / ") + "\n";
+        reportText += "エラー率: " + (data.error_rate * 100).toFixed(2) + "% (5xx サーバー側: " + (data.server_error_rate * 100).toFixed(2) + "% / 4xx クライアント側: " + (data.client_error_rate * 100).toFixed(2) + "%)\n\n";
+
+        reportText += "[ステータスコード分布]\n";
+        for (const [code, count] of Object.entries(data.status_codes)) {
+            reportText += "HTTP " + code + " : " + count.toLocaleString() + " 件\n";
+        }
+        if (data.tls_versions) {
+            reportText += "\n[TLS ネゴシエーション (新規接続数)]\n";
+            for (const [version, count] of Object.entries(data.tls_versions)) {
+                reportText += version + " : " + count.toLocaleString() + " 接続\n";
+            }
+            for (const [suite, count] of Object.entries(data.tls_cipher_suites || {})) {
+                reportText += suite + " : " + count.toLocaleString() + " 接続\n";
+            }
+        }
+        if (data.requests_by_ip) {
+            reportText += "\n[接続先アドレスごとのリクエスト数 (spread_ips)]\n";
+            for (const [ip, count] of Object.entries(data.requests_by_ip)) {
+                reportText += ip + " : " + count.toLocaleString() + " 件\n";
+            }
+        }
+        if (data.comparison) {
+            const c = data.comparison;
+            reportText += "\n[比較ターゲット (" + c.mode + ")]\n";
+            reportText += "A: " + c.target_a + "\nB: " + c.target_b + "\n";
+            reportText += "指標".padEnd(18) + "A".padStart(12) + "B".padStart(12) + "B-A".padStart(12) + "%".padStart(10) + "\n";
+            for (const d of c.deltas) {
+                const pct = d.delta_pct === undefined ? "-" : (d.delta_pct >= 0 ? "+" : "") + d.delta_pct.toFixed(1) + "%";
+                reportText += d.metric.padEnd(18) + d.a.toFixed(2).padStart(12) + d.b.toFixed(2).padStart(12) +
+                    ((d.delta >= 0 ? "+" : "") + d.delta.toFixed(2)).padStart(12) + pct.padStart(10) + "\n";
+            }
+            if (c.b.error_msg) reportText += "B のエラー: " + c.b.error_msg + "\n";
+            reportText += "判定: " + c.verdict + "\n";
+        }
+        if (data.latency_histogram) {
+            reportText += "\n[レイテンシのヒストグラム (累積, le = 秒)]\n";
+            const le = (key) => key === "+Inf" ? Infinity : Number(key);
+            const buckets = Object.entries(data.latency_histogram).sort((x, y) => le(x[0]) - le(y[0]));
+            for (const [bound, count] of buckets) {
+                reportText += "le=" + bound + " : " + count.toLocaleString() + " 件\n";
+            }
+            if (data.latency_histogram_to) reportText += "書き出し先: " + data.latency_histogram_to + "\n";
+            if (data.latency_histogram_error) reportText += "書き出しエラー: " + data.latency_histogram_error + "\n";
+        }
+        if (data.error_kinds) {
+            reportText += "\n[エラー種別]\n";
+            for (const [kind, count] of Object.entries(data.error_kinds)) {
+                reportText += kind + " : " + count.toLocaleString() + " 件\n";
+            }
+        }
+        if (data.exported_to) {
+            reportText += "\n[エクスポート]\n結果を書き出しました: " + data.exported_to + "\n";
+        }
+        if (data.export_error) {
+            reportText += "\n[エクスポート]\n書き出しに失敗しました: " + data.export_error + "\n";
+        }
+        reportText += "==================================================";
+
+        output.innerText = reportText;
+        // traffic_shape の指定時は、目標レートと実際の RPS を同じ目盛りで重ねて描画します
+        const target = data.target_rps_timeline;
+        const rpsMax = target && target.length > 0 ? Math.ceil(Math.max(...(data.rps_timeline || []), ...target, 1)) : undefined;
+        drawTimeline([
+            { label: "RPS", color: "#10b981", max: rpsMax, data: data.rps_timeline },
+            { label: "目標RPS (" + data.traffic_shape + ")", color: "#8b5cf6", max: rpsMax, data: target },
+            { label: "通信中のリクエスト数", color: "#f59e0b", data: data.concurrency_timeline },
+            { label: "成功率 (%)", color: "#3b82f6", max: 100, data: data.success_rate_timeline && data.success_rate_timeline.map(v => v === null ? null : v * 100) },
+            { label: "新規接続数", color: "#ef4444", data: data.new_connections_timeline }
+        ]);
+    }
 </script>
 </body>
 </html>`
@@ -644,30 +3326,56 @@ func handleUI(w http.ResponseWriter, r *http.Request) {
 
 // setupGracefulShutdown は、OSからの割り込みシグナル（Ctrl+Cなど）を監視し、
 // 通信中のリクエストが強制切断されるのを防ぐためのシャットダウンプロセスを管理します。
-func setupGracefulShutdown(server *http.Server) {
+// 返されるチャネルは、停止処理（最後のログ出力を含む）が完了するとクローズされます。
+// timeout は処理中のリクエストとジョブの完了を待つ猶予時間（-shutdown-timeout）です。
+// 停止処理中に2回目のシグナルを受信した場合は、猶予時間の経過を待たずに直ちに強制終了します。
+func setupGracefulShutdown(server *http.Server, timeout time.Duration) <-chan struct{} {
 	// OSシグナルを受信するためのバッファ付きチャネルを作成
 	quit := make(chan os.Signal, 1)
 	
 	// SIGINT (Ctrl+Cによる割り込み) と SIGTERM (システムによる終了要求) を監視対象に設定
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
+	return handleShutdownSignals(server, timeout, quit, os.Exit)
+}
+
+// handleShutdownSignals は、quit に届いたシグナルに従って setupGracefulShutdown の停止処理を行います。
+// 強制終了や停止処理の失敗では exit を呼び出します（テストではプロセスを終了させずに呼び出しを検知できます）。
+func handleShutdownSignals(server *http.Server, timeout time.Duration, quit <-chan os.Signal, exit func(code int)) <-chan struct{} {
+	done := make(chan struct{})
+
 	// メインスレッドをブロックしないよう、専用のGoroutineでシグナルを待機します
 	go func() {
+		defer close(done)
+
 		// シグナルが受信されるまでここで待機（ブロック）
 		sig := <-quit
 		log.Printf("\n[System] シグナル (%v) を受信しました。サーバーを安全に停止します...\n", sig)
 
-		// 現在処理中のリクエスト（最大10万RPSの負荷テストなど）が完了するのを待つための猶予時間（15秒）を設定
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		// 停止処理中にもう一度 Ctrl+C が押された場合は、猶予時間を待たずに強制終了します
+		go func() {
+			sig := <-quit
+			log.Printf("\n[System] シグナル (%v) を再度受信しました。停止処理を待たずに強制終了します\n", sig)
+			exit(1)
+		}()
+
+		// 現在処理中のリクエスト（最大10万RPSの負荷テストなど）が完了するのを待つための猶予時間を設定
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
 
+		// 実行中のジョブ（停止されるまで実行するテストを含む）を停止し、部分レポートをログへ残します
+		jobs.stopAll(ctx)
+
 		// サーバーの新規リクエスト受付を停止し、処理中のコネクションが完了するまで待機（Graceful Shutdown）
 		if err := server.Shutdown(ctx); err != nil {
-			log.Fatalf("[System Error] サーバーのシャットダウン中に致命的なエラーが発生しました: %v\n", err)
+			log.Printf("[System Error] サーバーのシャットダウン中に致命的なエラーが発生しました: %v\n", err)
+			exit(1)
+			return
 		}
 
 		log.Println("[System] サーバープロセスが正常に終了しました。")
 	}()
+	return done
 }
 // ==============================================================================
 // [セクション7] メイン関数 (Entry Point) とサーバー起動
@@ -676,6 +3384,152 @@ func setupGracefulShutdown(server *http.Server) {
 // main はこのプログラムのエントリーポイントです。
 // ルーティングの設定、サーバーの構成、および起動処理を一元管理します。
 func main() {
+	// 0. コマンドラインフラグの解析
+	// サーバーを起動せずに完結するサブコマンド（レポートの統合など）は、ここで処理して終了します
+	mergeMode := flag.Bool("merge", false, "複数のレポートJSONを1つに統合して標準出力へ出力します (例: -merge r1.json r2.json)")
+	selfTestMode := flag.Bool("selftest", false, "ループバックのサーバーへ負荷をかけ、このマシンでテスター自身が生成できる最大RPSを計測して終了します")
+	selfTestDuration := flag.Duration("selftest-duration", 5*time.Second, "-selftest で負荷をかける時間")
+	selfTestConcurrency := flag.Int("selftest-concurrency", 0, "-selftest の並行数（0の場合はCPUコア数の16倍）")
+	selfTestSeed := flag.Int64("selftest-seed", -1, "-selftest の乱数のシード値。ワーカーの乱数と -selftest-latency の遅延を再現できます（負の値の場合はランダムに選び、レポートの seed に記録します）")
+	selfTestLatency := flag.String("selftest-latency", "", "-selftest のサーバーが応答を遅らせる時間の分布。レポートの統計を理論値と比較して検証します (例: constant:20ms / uniform:10ms,30ms / normal:20ms,5ms / bimodal:5ms,50ms,0.1)")
+	replayTrace := flag.String("replay", "", "トレースファイル（trace_out と同じ NDJSON）に記録された時刻どおりにリクエストを再生し、レポートを標準出力へ出力して終了します (例: -replay trace.ndjson)")
+	replayTarget := flag.String("replay-target", "", "-replay で u（URL）を省略した行の送信先（省略時はトレースの最初の u）")
+	replaySpeed := flag.Float64("replay-speed", 1, "-replay の再生の倍率（2 なら2倍の速さで再生します）")
+	estimateConfig := flag.String("estimate", "", "テストの設定JSON（\"-\" の場合は標準入力）から、必要なファイルディスクリプタ・メモリ・帯域を見積もって表示し、テストを実行せずに終了します (例: -estimate config.json)")
+	importMode := flag.Bool("import", false, "export_path で書き出した結果ファイルからレポートを再生成して標準出力へ出力します (例: -import r1.uls r2.uls)")
+	urlsStdin := flag.Bool("urls-stdin", false, "起動時に標準入力から送信先の一覧（1行に1つのURL、または \"<メソッド> <URL>\"）を読み込み、送信先を指定しなかったテストで使います (例: cat urls.txt | ultraload -urls-stdin)")
+	flag.IntVar(&maxConcurrency, "max-concurrency", maxConcurrency, "テストで受け付ける並行数（concurrency・max_in_flight・adaptive_max_concurrency）の上限。超える値は 400 で拒否します")
+	flag.IntVar(&counterShards, "counter-shards", counterShards, "総リクエスト数などのカウンターを分割するシャード数（0の場合は GOMAXPROCS に合わせて自動、1の場合は分割しません）")
+	flag.Float64Var(&trimmedMeanFraction, "trimmed-mean", 0, "レイテンシの両端からこの割合ずつを除いたトリム平均をレポートの trimmed_mean に記録します（0〜0.5未満。例: -trimmed-mean 0.01。0の場合は算出しません）")
+	flag.DurationVar(&defaultIdleConnTimeout, "idle-conn-timeout", defaultIdleConnTimeout, "idle_conn_timeout を省略したテストで、プールに残したアイドル接続を閉じるまでの時間")
+	flag.DurationVar(&defaultTLSHandshakeTimeout, "tls-handshake-timeout", defaultTLSHandshakeTimeout, "tls_handshake_timeout を省略したテストで、TLSハンドシェイクの完了を待つ時間")
+	flag.DurationVar(&defaultResponseHeaderTimeout, "response-header-timeout", defaultResponseHeaderTimeout, "response_header_timeout を省略したテストで、レスポンスヘッダーの受信を待つ時間（0の場合はテストの timeout と同じ）")
+	flag.DurationVar(&defaultExpectContinueTimeout, "expect-continue-timeout", defaultExpectContinueTimeout, "expect_continue_timeout を省略したテストで、100-Continue の応答を待つ時間")
+	flag.StringVar(&defaultContentType, "content-type", defaultContentType, "body を指定したテストで content_type を省略した場合に付与する Content-Type")
+	flag.Var(&defaultGenBodySize, "gen-body-size", "ボディを指定しないテストで、このサイズのボディを生成して送信します (例: -gen-body-size 1MB)")
+	flag.StringVar(&defaultGenBodyFill, "gen-body-fill", defaultGenBodyFill, "-gen-body-size で生成するボディの中身 (random・zero・pattern)")
+	flag.StringVar(&defaultHMACKey, "hmac-key", "", "hmac_key を省略したテストで、リクエストの HMAC-SHA256 署名に使う共有鍵（空の場合は署名しません）")
+	flag.StringVar(&defaultHMACHeader, "hmac-header", defaultHMACHeader, "hmac_header を省略したテストで、署名を付与するヘッダー")
+	flag.StringVar(&defaultHMACCanonical, "hmac-canonical", defaultHMACCanonical, "hmac_canonical を省略したテストで、署名する正規化文字列のテンプレート（\\n は改行として扱います。例: \"{method}\\n{path}\\n{timestamp}\\n{body_sha256}\"）")
+	flag.Var(defaultQuery, "q", "すべてのテストのターゲットURLに追加するクエリパラメーター (key=value、繰り返し指定可。例: -q api_key=abc -q lang=ja)")
+	flag.Var(defaultTags, "tag", "すべてのテストのレポートに付与するタグ (key=value、繰り返し指定可。例: -tag environment=staging -tag version=1.4.2)")
+	logFile := flag.String("log-file", "", "ログを標準エラー出力ではなく指定したファイルへ書き込みます（サイズによるローテーション付き）")
+	logMaxSizeMB := flag.Int("log-max-size-mb", 100, "-log-file のファイルがこのサイズ（MB）を超えたらローテーションします（0の場合はローテーションしません）")
+	logMaxBackups := flag.Int("log-max-backups", 5, "-log-file のローテーションで保持する古いファイルの数")
+	flag.Float64Var(&defaultMinRPSFloor, "min-rps-floor", defaultMinRPSFloor, "min_rps_floor を省略したテストで、直近の RPS がこの値を下回り続けたらテストを停止します（0の場合は監視しません）")
+	flag.DurationVar(&defaultMinRPSGrace, "min-rps-grace", defaultMinRPSGrace, "min_rps_grace を省略したテストで、RPS が下限を下回ってから停止するまでの猶予時間")
+	flag.StringVar(&defaultTrafficShape, "shape", "", "rate_limit を指定したテストで traffic_shape を省略した場合に、目標レートを変化させる形 (sine・burst。空の場合は一定のレート)")
+	flag.DurationVar(&defaultShapePeriod, "shape-period", defaultShapePeriod, "shape_period を省略したテストで、-shape・traffic_shape の目標レートが1周する時間")
+	flag.DurationVar(&errorLogInterval, "error-log-interval", errorLogInterval, "同じエラーのログは初回だけを出力し、省略した件数をこの間隔でまとめて出力します（0の場合はすべてのエラーを出力します）")
+	flag.StringVar(&statsdAddr, "statsd-addr", "", "テスト中の計測値を StatsD (UDP) へ送信します (例: -statsd-addr 127.0.0.1:8125)")
+	flag.StringVar(&statsdPrefix, "statsd-prefix", statsdPrefix, "StatsD へ送信するメトリクス名の接頭辞")
+	flag.DurationVar(&jobRetention, "job-retention", jobRetention, "完了したジョブ (/api/start) の結果を保持する期間。経過後は破棄して /api/result などで取得できなくなります（0の場合は期限なし）")
+	flag.IntVar(&maxFinishedJobs, "max-finished-jobs", maxFinishedJobs, "保持する完了したジョブの件数の上限。超えた場合は完了の古い順に破棄します")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 15*time.Second, "停止シグナルの受信後、処理中のリクエストと実行中のテストの完了を待つ猶予時間（猶予中にもう一度 Ctrl+C で強制終了）")
+	showVersion := flag.Bool("version", false, "ビルド情報（バージョン、コミット、ビルド日時）を表示して終了します")
+	flag.StringVar(&outputFormat, "o", outputFormat, "-merge・-import・-selftest が標準出力へ書き出すレポートのフォーマット (json / hey / wrk。末尾に .gz を付けると gzip で圧縮します)")
+	flag.StringVar(&outputPath, "out", "", "-merge・-import・-selftest のレポートを標準出力ではなく指定したファイルへ書き出します（拡張子が .gz の場合は gzip で圧縮します）")
+	flag.BoolVar(&strictValidationDefault, "strict", false, "すべてのAPIリクエストで厳格バリデーション（不明なフィールドや範囲外の値を 422 で拒否）を行います")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(toolVersion)
+		return
+	}
+	parseOutputFlags()
+	if !validOutputFormat(outputFormat) {
+		fmt.Fprintf(os.Stderr, "[System Error] -o には json・hey・wrk のいずれか（圧縮する場合は json.gz など）を指定してください: %q\n", outputFormat)
+		os.Exit(2)
+	}
+	if maxFinishedJobs <= 0 || jobRetention < 0 {
+		fmt.Fprintf(os.Stderr, "[System Error] -max-finished-jobs には1以上、-job-retention には0以上の値を指定してください: %d, %s\n", maxFinishedJobs, jobRetention)
+		os.Exit(2)
+	}
+	if maxConcurrency <= 0 {
+		fmt.Fprintf(os.Stderr, "[System Error] -max-concurrency には1以上の値を指定してください: %d\n", maxConcurrency)
+		os.Exit(2)
+	}
+	switch defaultGenBodyFill {
+	case genBodyRandom, genBodyZero, genBodyPattern:
+	default:
+		fmt.Fprintf(os.Stderr, "[System Error] -gen-body-fill には random・zero・pattern のいずれかを指定してください: %q\n", defaultGenBodyFill)
+		os.Exit(2)
+	}
+	if defaultMinRPSFloor < 0 || defaultMinRPSGrace <= 0 {
+		fmt.Fprintf(os.Stderr, "[System Error] -min-rps-floor には0以上、-min-rps-grace には正の値を指定してください: %v, %v\n", defaultMinRPSFloor, defaultMinRPSGrace)
+		os.Exit(2)
+	}
+	switch defaultTrafficShape {
+	case "", shapeSine, shapeBurst:
+	default:
+		fmt.Fprintf(os.Stderr, "[System Error] -shape には sine または burst を指定してください: %q\n", defaultTrafficShape)
+		os.Exit(2)
+	}
+	if defaultShapePeriod <= 0 {
+		fmt.Fprintf(os.Stderr, "[System Error] -shape-period には正の値を指定してください: %v\n", defaultShapePeriod)
+		os.Exit(2)
+	}
+	if errorLogInterval < 0 {
+		fmt.Fprintf(os.Stderr, "[System Error] -error-log-interval には0以上の値を指定してください: %v\n", errorLogInterval)
+		os.Exit(2)
+	}
+	if counterShards < 0 || counterShards > maxCounterShards {
+		fmt.Fprintf(os.Stderr, "[System Error] -counter-shards は 0〜%d の範囲で指定してください: %d\n", maxCounterShards, counterShards)
+		os.Exit(2)
+	}
+	if err := validateTimeoutFlags(); err != nil {
+		fmt.Fprintf(os.Stderr, "[System Error] %v\n", err)
+		os.Exit(2)
+	}
+	if err := validateHMACFlags(); err != nil {
+		fmt.Fprintf(os.Stderr, "[System Error] %v\n", err)
+		os.Exit(2)
+	}
+	if trimmedMeanFraction < 0 || trimmedMeanFraction >= maxTrimmedMeanFraction {
+		fmt.Fprintf(os.Stderr, "[System Error] -trimmed-mean は 0 以上 %g 未満の割合で指定してください: %g\n", maxTrimmedMeanFraction, trimmedMeanFraction)
+		os.Exit(2)
+	}
+	if *logFile != "" {
+		lf, err := openRotatingFile(*logFile, int64(*logMaxSizeMB)<<20, *logMaxBackups)
+		if err != nil {
+			log.Fatalf("[System Fatal] ログファイルを開けません: %v\n", err)
+		}
+		defer lf.Close()
+		log.SetOutput(lf)
+	}
+	if *mergeMode {
+		os.Exit(runMergeCommand(flag.Args()))
+	}
+	if *importMode {
+		os.Exit(runImportCommand(flag.Args()))
+	}
+	if *estimateConfig != "" {
+		os.Exit(runEstimateCommand(*estimateConfig))
+	}
+	if *replayTrace != "" {
+		os.Exit(runReplayCommand(*replayTrace, *replayTarget, *replaySpeed))
+	}
+	if *selfTestMode {
+		var dist *latencyDistribution
+		if *selfTestLatency != "" {
+			var err error
+			if dist, err = parseLatencyDistribution(*selfTestLatency); err != nil {
+				fmt.Fprintf(os.Stderr, "[System Error] -selftest-latency: %v\n", err)
+				os.Exit(2)
+			}
+		}
+		os.Exit(runSelfTest(*selfTestDuration, *selfTestConcurrency, dist, *selfTestSeed))
+	}
+	if *urlsStdin {
+		specs, err := loadStdinTargets(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[System Error] %v\n", err)
+			os.Exit(2)
+		}
+		stdinRequests = specs
+		log.Printf("[Stdin] 標準入力から %d 件の送信先を読み込みました（先頭: %s %s）\n", len(specs), specs[0].Method, specs[0].URL)
+	}
+
 	// 1. ルーティングの設定 (マルチプレクサの作成)
 	// http.DefaultServeMux を避けることで、意図しないエンドポイントの公開を防ぎます (セキュリティ対策)
 	mux := http.NewServeMux()
@@ -686,26 +3540,31 @@ func main() {
 	// フロントエンドからの負荷テスト実行要求を受け付けるAPIルート
 	mux.HandleFunc("/api/run", handleAPI)
 
+	// 負荷をかける前の単発診断（curl互換コマンドの生成と1リクエストの実行）を行うAPIルート
+	mux.HandleFunc("/api/explain", handleExplain)
+
+	// サーバーの制限値（並行数の上限など）を返すAPIルート。UI が入力欄の制約に使います
+	mux.HandleFunc("/api/limits", handleLimits)
+
+	// テストをジョブとして非同期に開始・停止・一時停止・結果取得するAPIルート（停止されるまで実行するテストもここから）
+	mux.HandleFunc("/api/start", handleJobStart)
+	mux.HandleFunc("/api/result/{id}", handleJobResult)
+	mux.HandleFunc("/api/progress/{id}", handleJobProgress)
+	mux.HandleFunc("/api/stop/{id}", handleJobStop)
+	mux.HandleFunc("/api/pause/{id}", handleJobPause)
+	mux.HandleFunc("/api/resume/{id}", handleJobResume)
+
 	// 2. HTTPサーバーの設定
-	// タイムアウトを適切に設定し、スローロリス攻撃(Slowloris)などのコネクション枯渇攻撃からシステムを守ります
-	server := &http.Server{
-		Addr:         ":8080",           // 待ち受けるポート番号
-		Handler:      mux,               // カスタムマルチプレクサを指定
-		ReadTimeout:  10 * time.Second,  // リクエストヘッダーの読み込みタイムアウト
-		// WriteTimeoutは、最長のテスト実行時間（DurationSec）を考慮して十分に長くするか、
-		// 完全にストリーミング処理する場合はタイムアウトを外す設計もありますが、今回は安全のため設定します。
-		// ※超長時間のテストを行う場合は、ここを適宜伸ばしてください。
-		WriteTimeout: 3600 * time.Second, 
-		IdleTimeout:  120 * time.Second, // キープアライブ通信時の待機タイムアウト
-	}
+	server := newAPIServer(":8080", mux, serverWriteTimeout)
 
 	// 3. Graceful Shutdown（安全な終了処理）のセットアップ
 	// サーバーインスタンスを渡し、OSシグナル（Ctrl+C等）を監視するバックグラウンド処理を開始します
-	setupGracefulShutdown(server)
+	shutdownDone := setupGracefulShutdown(server, *shutdownTimeout)
 
 	// 4. サーバーの起動と運用案内
 	log.Println("======================================================")
 	log.Println("🚀 UltraLoad Engine - Professional Load Tester started")
+	log.Println("[INFO] " + toolVersion.String())
 	log.Println("======================================================")
 	log.Println("[INFO] ブラウザで以下のURLにアクセスしてUIを開いてください:")
 	log.Println("[INFO] http://localhost:8080")
@@ -717,4 +3576,155 @@ func main() {
 		// それ以外の予期せぬエラー（ポートが既に使用されている等）のみを Fatal として扱います
 		log.Fatalf("[System Fatal] サーバーの起動または実行中に致命的なエラーが発生しました: %v\n", err)
 	}
+
+	// Shutdown が呼ばれると ListenAndServe はすぐに戻るため、停止処理の最後のログが書き込まれるまで待ってから終了します
+	<-shutdownDone
+}
+
+// ==============================================================================
+// [セクション8] 単発診断モード: curl互換コマンドの生成と1リクエストの実行
+// ==============================================================================
+
+// ExplainResult は、単発診断（/api/explain）の結果をフロントエンドへ返すためのJSON構造体です。
+// 「設定の問題なのか、ターゲット側の問題なのか」を切り分けられるよう、
+// 同等の curl コマンドと、実際に1回だけ送信したリクエストの結果をセットで返します。
+type ExplainResult struct {
+	CurlCommand     string            `json:"curl_command"`
+	StatusCode      int               `json:"status_code,omitempty"`
+	Latency         string            `json:"latency,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	BodyPreview     string            `json:"body_preview,omitempty"`
+	ErrorMsg        string            `json:"error_msg,omitempty"`
+}
+
+// explainBodyPreviewBytes は、診断結果に含めるレスポンスボディの先頭バイト数です。
+const explainBodyPreviewBytes = 1024
+
+// shellQuote は、文字列をPOSIXシェルのシングルクォートで安全に囲みます。
+// 内部のシングルクォートは '\'' に置き換えるため、どのような値でもそのまま貼り付けて実行できます。
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// buildCurlCommand は、負荷テストのワーカーと同じ条件で1リクエストを送る curl コマンドを生成します。
+// TLS検証のスキップ (-k)、リダイレクト非追従、タイムアウトなど、createOptimizedHTTPClient の挙動に揃えています。
+// リクエストのヘッダー（content_type・headers・Basic 認証・HMAC 署名）は、ワーカーと同じく newBaseRequest で組み立てたものを
+// 名前順の -H で出力します。HMAC 署名は now のタイムスタンプで計算するため、生成したコマンドは署名の有効期間内に実行してください。
+func buildCurlCommand(cfg *TestConfig, now time.Time) string {
+	args := []string{"curl", "-sS", "-i", "-k"}
+
+	// HEAD は -X HEAD で送るとボディを待ち続けてしまうため、curl の作法に従い -I を使用します
+	if cfg.Method == http.MethodHead {
+		args = append(args, "-I")
+	} else {
+		args = append(args, "-X", shellQuote(cfg.Method))
+	}
+
+	if cfg.TimeoutSec > 0 {
+		args = append(args, "--max-time", fmt.Sprintf("%d", cfg.TimeoutSec))
+	}
+	// Digest 認証はチャレンジへの応答が必要なため、ヘッダーではなく curl の --digest に任せます
+	if cfg.AuthType == authDigest {
+		args = append(args, "--digest", "-u", shellQuote(cfg.AuthUser+":"+cfg.AuthPassword))
+	}
+
+	if req, err := newBaseRequest(context.Background(), cfg.Method, cfg.TargetURL, cfg); err == nil {
+		// ワーカーの authTransport と同じく、Basic 認証は headers の Authorization より優先します
+		if cfg.AuthType == authBasic {
+			req.SetBasicAuth(cfg.AuthUser, cfg.AuthPassword)
+		}
+		if cfg.signer != nil {
+			ts := strconv.FormatInt(now.Unix(), 10)
+			if signature, err := cfg.signer.sign(req, ts); err == nil {
+				req.Header.Set(cfg.signer.timestampHeader, ts)
+				req.Header.Set(cfg.signer.header, signature)
+			}
+		}
+		if req.Host != "" && req.Host != req.URL.Host {
+			args = append(args, "-H", shellQuote("Host: "+req.Host))
+		}
+		for _, name := range slices.Sorted(maps.Keys(req.Header)) {
+			for _, value := range req.Header[name] {
+				args = append(args, "-H", shellQuote(name+": "+value))
+			}
+		}
+	}
+
+	if cfg.Body != "" {
+		args = append(args, "--data-raw", shellQuote(cfg.Body))
+	} else if cfg.genBody != nil {
+		args = append(args, "--data-binary", genBodyCurlData(cfg))
+	}
+
+	args = append(args, shellQuote(cfg.TargetURL))
+	return strings.Join(args, " ")
+}
+
+// runExplain は、指定された設定で実際に1回だけリクエストを送信し、診断結果を返します。
+func runExplain(cfg *TestConfig) *ExplainResult {
+	result := &ExplainResult{CurlCommand: buildCurlCommand(cfg, time.Now())}
+
+	client := createOptimizedHTTPClient(1, cfg)
+	defer client.CloseIdleConnections()
+
+	ctx, cancel := withRequestTimeout(context.Background(), cfg)
+	defer cancel()
+	req, err := newBaseRequest(ctx, cfg.Method, cfg.TargetURL, cfg)
+	if err != nil {
+		result.ErrorMsg = fmt.Sprintf("リクエストの初期化に失敗しました: %v", err)
+		return result
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Latency = formatDuration(time.Since(start))
+		result.ErrorMsg = fmt.Sprintf("リクエストの送信に失敗しました: %v", err)
+		return result
+	}
+
+	// ボディの先頭だけをプレビューとして保持し、残りは読み捨てて計測をワーカーと揃えます
+	preview, _ := io.ReadAll(io.LimitReader(resp.Body, explainBodyPreviewBytes))
+	drainAndClose(resp, drainAll)
+	result.Latency = formatDuration(time.Since(start))
+
+	result.StatusCode = resp.StatusCode
+	result.BodyPreview = string(preview)
+	result.ResponseHeaders = make(map[string]string, len(resp.Header))
+	for key, values := range resp.Header {
+		result.ResponseHeaders[key] = strings.Join(values, ", ")
+	}
+
+	return result
+}
+
+// handleExplain は、負荷をかける前に設定を確認するための単発診断エンドポイントです。
+func handleExplain(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error_msg": "POSTメソッドのみ許可されています"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg, ok := readTestConfig(w, r)
+	if !ok {
+		return
+	}
+
+	log.Printf("[API] 単発診断のリクエストを受信しました。ターゲット: %s", cfg.TargetURL)
+	extendWriteDeadline(w, time.Duration(cfg.TimeoutSec)*time.Second)
+	result := runExplain(cfg)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("[API Error] 診断結果のJSONエンコードに失敗しました: %v\n", err)
+	}
 }
@@ -0,0 +1,987 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestConfig は、fields を /api/run と同じ JSON として readTestConfig に渡し、既定値を適用した設定を返します。
+// 途中経過のログを抑えるため、quiet を既定で指定します。
+func newTestConfig(t testing.TB, fields map[string]any) *TestConfig {
+	t.Helper()
+	payload := map[string]any{"quiet": true}
+	for k, v := range fields {
+		payload[k] = v
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("設定を JSON にできません: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	cfg, ok := readTestConfig(rec, httptest.NewRequest(http.MethodPost, "/api/run", strings.NewReader(string(body))))
+	if !ok {
+		t.Fatalf("設定の検証に失敗しました: %s", strings.TrimSpace(rec.Body.String()))
+	}
+	return cfg
+}
+
+// runTestLoad は、cfg のテストを実行してレポートを返します。
+func runTestLoad(cfg *TestConfig) *TestReport {
+	return runLoadTest(context.Background(), cfg, NewResultMetrics(estimateTotalRequests(cfg)))
+}
+
+// TestVetAndBuild は、モジュール全体が go vet と go build を通ることを確認します（ビルドできることの受け入れ条件です）。
+// go コマンドを呼び出すため、-short の場合と go コマンドが見つからない場合はスキップします。
+func TestVetAndBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("-short のため go vet・go build の確認をスキップします")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go コマンドが見つからないため、スキップします")
+	}
+	for _, args := range [][]string{
+		{"vet", "./..."},
+		{"build", "-o", os.DevNull, "./..."},
+	} {
+		out, err := exec.Command(goBin, args...).CombinedOutput()
+		if err != nil {
+			t.Errorf("go %v に失敗しました: %v\n%s", args, err, out)
+		}
+	}
+}
+
+// TestRunLoadTestSmoke は、httptest のサーバーへ短時間の負荷をかけ、レポートが一通り埋まることを確認します。
+func TestRunLoadTestSmoke(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := newTestConfig(t, map[string]any{
+		"target_url":  server.URL,
+		"concurrency": 4,
+		"duration":    (300 * time.Millisecond).String(),
+	})
+	report := runTestLoad(cfg)
+
+	if report.ErrorMsg != "" {
+		t.Fatalf("テストが失敗しました: %s", report.ErrorMsg)
+	}
+	if report.TotalRequests == 0 || report.Success != report.TotalRequests || report.Errors != 0 {
+		t.Errorf("total=%d success=%d errors=%d: すべて成功するはずです", report.TotalRequests, report.Success, report.Errors)
+	}
+	if report.StatusCodes["200"] != uint64(report.TotalRequests) {
+		t.Errorf("status_codes[200] = %d, want %d", report.StatusCodes["200"], report.TotalRequests)
+	}
+	if report.ThroughputRPS <= 0 {
+		t.Errorf("throughput_rps = %v, want > 0", report.ThroughputRPS)
+	}
+	if report.P50Latency == "" || report.P50Latency == "N/A" {
+		t.Errorf("p50_latency = %q: レイテンシが記録されていません", report.P50Latency)
+	}
+}
+
+// TestReportToolVersion は、レポートのJSONに、生成したツールのバージョンと Go のバージョンを含む tool_version が出力されることを確認します。
+func TestReportToolVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	report := runTestLoad(newTestConfig(t, map[string]any{"target_url": server.URL, "concurrency": 1, "duration": "100ms"}))
+
+	raw, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		ToolVersion *BuildInfo `json:"tool_version"`
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ToolVersion == nil || decoded.ToolVersion.Version == "" || decoded.ToolVersion.GoVersion != runtime.Version() {
+		t.Errorf("tool_version = %+v: バージョンと Go のバージョン (%s) が出力されていません", decoded.ToolVersion, runtime.Version())
+	}
+}
+
+// TestBuildCurlCommand は、explain の curl コマンドにメソッド・ヘッダー・認証・ボディがシェルで安全な形で含まれることを確認します。
+func TestBuildCurlCommand(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("POST 1700000000"))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name   string
+		fields map[string]any
+		want   string
+	}{
+		{
+			name:   "GET",
+			fields: map[string]any{"target_url": "http://127.0.0.1:8080/health", "timeout": 5},
+			want:   `curl -sS -i -k -X 'GET' --max-time 5 'http://127.0.0.1:8080/health'`,
+		},
+		{
+			name:   "HEAD",
+			fields: map[string]any{"target_url": "http://127.0.0.1:8080/", "method": "HEAD", "timeout": 5},
+			want:   `curl -sS -i -k -I --max-time 5 'http://127.0.0.1:8080/'`,
+		},
+		{
+			name: "ヘッダーとボディ",
+			fields: map[string]any{
+				"target_url":   "http://127.0.0.1:8080/api",
+				"method":       "POST",
+				"timeout":      5,
+				"content_type": "application/json",
+				"body":         "{\"msg\":\"it's\"}\n",
+				"headers":      map[string]string{"X-Api-Key": "k'1", "Accept": "*/*", "Host": "api.example.com"},
+			},
+			want: `curl -sS -i -k -X 'POST' --max-time 5 -H 'Host: api.example.com' -H 'Accept: */*' -H 'Content-Type: application/json' -H 'X-Api-Key: k'\''1' ` +
+				"--data-raw '{\"msg\":\"it'\\''s\"}\n' 'http://127.0.0.1:8080/api'",
+		},
+		{
+			name: "Basic 認証",
+			fields: map[string]any{
+				"target_url": "http://127.0.0.1:8080/", "timeout": 5,
+				"auth_type": "basic", "auth_user": "user", "auth_password": "pass",
+			},
+			want: `curl -sS -i -k -X 'GET' --max-time 5 -H 'Authorization: Basic dXNlcjpwYXNz' 'http://127.0.0.1:8080/'`,
+		},
+		{
+			name: "Digest 認証",
+			fields: map[string]any{
+				"target_url": "http://127.0.0.1:8080/", "timeout": 5,
+				"auth_type": "digest", "auth_user": "user", "auth_password": "pass",
+			},
+			want: `curl -sS -i -k -X 'GET' --max-time 5 --digest -u 'user:pass' 'http://127.0.0.1:8080/'`,
+		},
+		{
+			name: "HMAC 署名",
+			fields: map[string]any{
+				"target_url": "http://127.0.0.1:8080/", "method": "POST", "timeout": 5,
+				"hmac_key": "secret", "hmac_canonical": "{method} {timestamp}",
+			},
+			want: `curl -sS -i -k -X 'POST' --max-time 5 -H 'X-Signature: ` + signature + `' -H 'X-Timestamp: 1700000000' 'http://127.0.0.1:8080/'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, tt.fields)
+			if got := buildCurlCommand(cfg, now); got != tt.want {
+				t.Errorf("buildCurlCommand()\n got: %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReportAllRequestsFail は、すべてのリクエストが失敗したテストのレポートが、エラー率 1 になり、
+// 応答を受信していない場合はレイテンシを "0ms" ではなく "N/A" と表すことを確認します（各出力フォーマットでも panic しないこと）。
+func TestReportAllRequestsFail(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		wantCode    string
+		wantLatency bool // 応答を受信するため、レイテンシが記録されるかどうか
+	}{
+		{
+			name:        "500",
+			handler:     func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) },
+			wantCode:    "500",
+			wantLatency: true,
+		},
+		{
+			name: "接続の切断",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					conn.Close()
+				}
+			},
+			wantCode: "NetworkError",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			cfg := newTestConfig(t, map[string]any{
+				"target_url":  server.URL,
+				"concurrency": 2,
+				"duration":    (200 * time.Millisecond).String(),
+				// 接続を切断するサーバーではプリフライトで中止されるため、省略して負荷をかけます
+				"no_preflight": true,
+			})
+			report := runTestLoad(cfg)
+
+			if report.TotalRequests == 0 {
+				t.Fatalf("リクエストが送信されていません: %s", report.ErrorMsg)
+			}
+			if report.Success != 0 || report.Errors != report.TotalRequests {
+				t.Errorf("total=%d success=%d errors=%d: すべて失敗するはずです", report.TotalRequests, report.Success, report.Errors)
+			}
+			if report.ErrorRate != 1 {
+				t.Errorf("error_rate = %v, want 1", report.ErrorRate)
+			}
+			if report.StatusCodes[tt.wantCode] != uint64(report.TotalRequests) {
+				t.Errorf("status_codes = %v, want %s がすべて", report.StatusCodes, tt.wantCode)
+			}
+
+			latencies := []string{report.MinLatency, report.MeanLatency, report.P50Latency, report.P90Latency, report.P99Latency, report.MaxLatency}
+			for _, l := range latencies {
+				if tt.wantLatency && (l == "N/A" || l == "") {
+					t.Errorf("latencies = %v: 応答を受信したレイテンシが記録されていません", latencies)
+					break
+				}
+				if !tt.wantLatency && l != "N/A" {
+					t.Errorf("latencies = %v: 応答を受信していないため、すべて N/A のはずです", latencies)
+					break
+				}
+			}
+			if !tt.wantLatency && report.LatencySamples != 0 {
+				t.Errorf("latency_samples = %d, want 0", report.LatencySamples)
+			}
+
+			for _, format := range []string{outputFormatJSON, outputFormatHey, outputFormatWrk} {
+				if err := writeReport(io.Discard, report, format, server.URL); err != nil {
+					t.Errorf("%s 形式で書き出せません: %v", format, err)
+				}
+			}
+		})
+	}
+}
+
+// TestMaxResponseBytes は、max_response_bytes を超えるボディを流し続けるサーバーへの応答が、上限+1バイトで読み込みを
+// 打ち切って response_too_large として記録され、上限ちょうどのボディは成功として記録されることを確認します。
+func TestMaxResponseBytes(t *testing.T) {
+	const limit = 4096
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/exact" {
+			w.Write(make([]byte, limit))
+			return
+		}
+		// 上限を大きく超えるボディを、クライアントが接続を切るまで少しずつ流し続けます
+		chunk := make([]byte, 1024)
+		for range 10 * 1024 {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	run := func(path string) *TestReport {
+		return runTestLoad(newTestConfig(t, map[string]any{
+			"target_url":         server.URL + path,
+			"concurrency":        2,
+			"duration":           "300ms",
+			"max_response_bytes": limit,
+		}))
+	}
+	tooLarge := run("/stream")
+	if tooLarge.TotalRequests == 0 || tooLarge.Errors != tooLarge.TotalRequests || tooLarge.ErrorKinds[errKindResponseTooLarge] != uint64(tooLarge.Errors) {
+		t.Errorf("total=%d errors=%d error_kinds=%v: すべて %s として記録されるはずです",
+			tooLarge.TotalRequests, tooLarge.Errors, tooLarge.ErrorKinds, errKindResponseTooLarge)
+	}
+	exact := run("/exact")
+	if exact.TotalRequests == 0 || exact.Errors != 0 {
+		t.Errorf("total=%d errors=%d error_kinds=%v: 上限ちょうどのボディは成功するはずです", exact.TotalRequests, exact.Errors, exact.ErrorKinds)
+	}
+}
+
+// TestConcurrencyTimeline は、concurrency_timeline に各秒の終わりの時点で通信中だったリクエスト数が1秒ごとに記録されることを、
+// テストの終了まで応答しないサーバー（全ワーカーが常に通信中になります）で確認します。
+func TestConcurrencyTimeline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	const workers = 4
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":   server.URL,
+		"concurrency":  workers,
+		"duration":     "2500ms",
+		"no_preflight": true,
+	}))
+	if want := []int64{workers, workers}; !slices.Equal(report.ConcurrencyTimeline, want) {
+		t.Errorf("concurrency_timeline = %v, want %v", report.ConcurrencyTimeline, want)
+	}
+	if len(report.RPSTimeline) != len(report.ConcurrencyTimeline) {
+		t.Errorf("rps_timeline (%d 秒) と concurrency_timeline (%d 秒) の長さが異なります", len(report.RPSTimeline), len(report.ConcurrencyTimeline))
+	}
+}
+
+// TestAchievedInFlight は、厳しいレート制御で大半のワーカーが待機している場合、peak_inflight・avg_inflight が
+// 設定した並行数を大きく下回り、制御しない場合は peak_inflight が並行数に達することを確認します。
+func TestAchievedInFlight(t *testing.T) {
+	const workers = 100
+	server := newInflightServer(t, 20*time.Millisecond)
+
+	// 10 RPS × 20ms では、平均して 0.2 件しか通信中になりません。
+	// rate_jitter で各ワーカーの送信の位相をずらし、開始直後に全ワーカーが一斉に送信しないようにします
+	// （それでも一部のワーカーは開始直後に送信するため、peak_inflight は並行数の 1/4 までを許容します）
+	limited := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":   server.URL,
+		"concurrency":  workers,
+		"rate_limit":   10,
+		"rate_jitter":  1,
+		"duration":     "1s",
+		"no_preflight": true,
+	}))
+	if limited.PeakInFlight < 1 || limited.PeakInFlight > workers/4 {
+		t.Errorf("peak_inflight = %d, want 1〜%d（並行数 %d を大きく下回るはずです）", limited.PeakInFlight, workers/4, workers)
+	}
+	if limited.AvgInFlight <= 0 || limited.AvgInFlight > 1 {
+		t.Errorf("avg_inflight = %.2f, want 0〜1", limited.AvgInFlight)
+	}
+
+	unlimited := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":   server.URL,
+		"concurrency":  workers,
+		"duration":     "500ms",
+		"no_preflight": true,
+	}))
+	if unlimited.PeakInFlight != workers || unlimited.AvgInFlight < workers/2 {
+		t.Errorf("peak_inflight=%d avg_inflight=%.2f: レート制御なしでは並行数 %d 近くに達するはずです", unlimited.PeakInFlight, unlimited.AvgInFlight, workers)
+	}
+}
+
+// TestTCPKeepAliveDialer は、tcp_keepalive の指定がダイヤラーに反映されること（正の値は無通信時間とプローブ間隔の両方、
+// 負の値は無効、省略時は Go の既定値）と、設定したダイヤラーで実際に接続できることを確認します。
+func TestTCPKeepAliveDialer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		keepAlive     any
+		wantKeepAlive time.Duration
+		wantConfig    net.KeepAliveConfig
+	}{
+		{nil, 0, net.KeepAliveConfig{}},
+		{"45s", 0, net.KeepAliveConfig{Enable: true, Idle: 45 * time.Second, Interval: 45 * time.Second}},
+		{5, 0, net.KeepAliveConfig{Enable: true, Idle: 5 * time.Second, Interval: 5 * time.Second}},
+		{"-1s", -1, net.KeepAliveConfig{}},
+	}
+	for _, tt := range tests {
+		fields := map[string]any{"target_url": server.URL}
+		if tt.keepAlive != nil {
+			fields["tcp_keepalive"] = tt.keepAlive
+		}
+		d := newTunedDialer(newTestConfig(t, fields))
+		if d.dialer.KeepAlive != tt.wantKeepAlive || d.dialer.KeepAliveConfig != tt.wantConfig {
+			t.Errorf("tcp_keepalive=%v: KeepAlive=%v KeepAliveConfig=%+v, want %v %+v",
+				tt.keepAlive, d.dialer.KeepAlive, d.dialer.KeepAliveConfig, tt.wantKeepAlive, tt.wantConfig)
+		}
+		conn, err := d.DialContext(context.Background(), "tcp", server.Listener.Addr().String())
+		if err != nil {
+			t.Errorf("tcp_keepalive=%v: 接続できません: %v", tt.keepAlive, err)
+			continue
+		}
+		conn.Close()
+	}
+}
+
+// TestRedirectsAreErrors は、302 を返すターゲットへの応答が、redirects_are_errors を指定しない場合は（転送先をたどらずに）成功、
+// 指定した場合は redirect エラーとして記録されることを確認します。
+func TestRedirectsAreErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			t.Error("転送先へのリクエストが届きました: リダイレクトはたどらないはずです")
+		}
+		http.Redirect(w, r, "/moved", http.StatusFound)
+	}))
+	t.Cleanup(server.Close)
+
+	for _, asErrors := range []bool{false, true} {
+		report := runTestLoad(newTestConfig(t, map[string]any{
+			"target_url":           server.URL,
+			"concurrency":          2,
+			"duration":             "200ms",
+			"redirects_are_errors": asErrors,
+		}))
+		if report.TotalRequests == 0 || report.StatusCodes["302"] != uint64(report.TotalRequests) {
+			t.Fatalf("redirects_are_errors=%v: total=%d status_codes=%v: すべて 302 として記録されるはずです", asErrors, report.TotalRequests, report.StatusCodes)
+		}
+		if asErrors {
+			if report.Success != 0 || report.ErrorKinds[errKindRedirect] != uint64(report.TotalRequests) {
+				t.Errorf("success=%d error_kinds=%v: すべて %s エラーになるはずです", report.Success, report.ErrorKinds, errKindRedirect)
+			}
+		} else if report.Errors != 0 || report.ErrorKinds[errKindRedirect] != 0 {
+			t.Errorf("errors=%d error_kinds=%v: 指定しない場合、3xx は成功として記録されるはずです", report.Errors, report.ErrorKinds)
+		}
+	}
+}
+
+// TestRequestMethods は、HEAD（ボディを返さない応答）と標準外のメソッドがそのままターゲットへ送信され、
+// ボディのない HEAD の応答もボディを待たずに成功として計測されることを確認します。
+func TestRequestMethods(t *testing.T) {
+	for _, method := range []string{http.MethodHead, http.MethodOptions, "PURGE"} {
+		t.Run(method, func(t *testing.T) {
+			var mu sync.Mutex
+			received := make(map[string]int)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				received[r.Method]++
+				mu.Unlock()
+				// HEAD では Content-Length だけを返し、ボディは送信されません
+				w.Header().Set("Content-Length", "1000")
+				w.Write(make([]byte, 1000))
+			}))
+			t.Cleanup(server.Close)
+
+			report := runTestLoad(newTestConfig(t, map[string]any{
+				"target_url":  server.URL,
+				"method":      method,
+				"concurrency": 2,
+				"duration":    "200ms",
+			}))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(received) != 1 || received[method] == 0 {
+				t.Fatalf("サーバーに届いたメソッド = %v, want %s のみ", received, method)
+			}
+			if report.TotalRequests < 10 || report.Errors != 0 || report.StatusCodes["200"] != uint64(report.TotalRequests) {
+				t.Errorf("total=%d errors=%d status_codes=%v: すべて成功として記録されるはずです", report.TotalRequests, report.Errors, report.StatusCodes)
+			}
+			if report.P50Latency == "" || report.P50Latency == "N/A" {
+				t.Errorf("p50_latency = %q: レイテンシが記録されていません", report.P50Latency)
+			}
+		})
+	}
+}
+
+// TestTimedOutCount は、timeout を超えて応答するサーバーへのリクエストが timed_out として数えられ、timeout_rate_pct に
+// その割合が出力されることと、接続拒否のようなタイムアウト以外のネットワークエラーは timed_out に含めないことを確認します。
+func TestTimedOutCount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(3 * time.Second):
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	// 各ワーカーは 1 秒ごとにタイムアウトし、2.5 秒の間に少なくとも 2 回ずつタイムアウトします
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":   server.URL,
+		"concurrency":  2,
+		"duration":     "2500ms",
+		"timeout":      1,
+		"no_preflight": true,
+	}))
+	if report.TimedOut < 4 || report.TimedOut > uint64(report.Errors) {
+		t.Errorf("timed_out=%d errors=%d: 遅延したリクエストが4件以上タイムアウトとして数えられるはずです", report.TimedOut, report.Errors)
+	}
+	if want := float64(report.TimedOut) / float64(report.TotalRequests) * 100; math.Abs(report.TimeoutRatePct-want) > 0.01 {
+		t.Errorf("timeout_rate_pct = %.2f, want %.2f", report.TimeoutRatePct, want)
+	}
+
+	// 接続を拒否されたリクエストはエラーですが、タイムアウトではありません
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	refused := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":   "http://" + addr,
+		"concurrency":  1,
+		"duration":     "100ms",
+		"no_preflight": true,
+	}))
+	if refused.Errors == 0 || refused.TimedOut != 0 || refused.TimeoutRatePct != 0 {
+		t.Errorf("errors=%d timed_out=%d timeout_rate_pct=%.2f: 接続拒否はタイムアウトに含めないはずです", refused.Errors, refused.TimedOut, refused.TimeoutRatePct)
+	}
+}
+
+// TestAPIServerWriteTimeout は、APIサーバーの書き込みのタイムアウトにより、レスポンスを読まないクライアントが接続を
+// 占有し続けられないことと、extendWriteDeadline で延長したリクエストだけは期限を超えて応答できることを確認します。
+func TestAPIServerWriteTimeout(t *testing.T) {
+	const writeTimeout = 200 * time.Millisecond
+	writeErr := make(chan error, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/flood", func(w http.ResponseWriter, r *http.Request) {
+		// ソケットのバッファに収まらない大きさのレスポンスを、読まないクライアントへ書き込み続けます
+		chunk := make([]byte, 1<<20)
+		for range 256 {
+			if _, err := w.Write(chunk); err != nil {
+				writeErr <- err
+				return
+			}
+		}
+		writeErr <- nil
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		extendWriteDeadline(w, time.Second)
+		time.Sleep(2 * writeTimeout)
+		io.WriteString(w, "done")
+	})
+	server := httptest.NewUnstartedServer(nil)
+	server.Config = newAPIServer("", mux, writeTimeout)
+	server.Start()
+	t.Cleanup(server.Close)
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /flood HTTP/1.1\r\nHost: test\r\n\r\n")
+	select {
+	case err := <-writeErr:
+		if err == nil {
+			t.Error("読まないクライアントへの書き込みが最後まで成功しました")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("読まないクライアントへの書き込みがタイムアウトしません")
+	}
+
+	resp, err := http.Get(server.URL + "/slow")
+	if err != nil {
+		t.Fatalf("期限を延長したリクエストが失敗しました: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "done" {
+		t.Errorf("body = %q, %v: 延長した期限内の応答を受け取れるはずです", body, err)
+	}
+}
+
+// TestFormatDuration は、レイテンシが大きさに応じた単位で、0 にならずに表示されることを確認します（各単位の境界を含みます）。
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0ns"},
+		{1, "1ns"},
+		{850 * time.Nanosecond, "850ns"},
+		{999 * time.Nanosecond, "999ns"},
+		{time.Microsecond, "1.00µs"},
+		{3420 * time.Nanosecond, "3.42µs"},
+		{999994 * time.Nanosecond, "999.99µs"},
+		{999995 * time.Nanosecond, "1.00ms"},
+		{time.Millisecond, "1.00ms"},
+		{1234567 * time.Nanosecond, "1.23ms"},
+		{999994 * time.Microsecond, "999.99ms"},
+		{999995 * time.Microsecond, "1.00s"},
+		{time.Second, "1.00s"},
+		{2500 * time.Millisecond, "2.50s"},
+		{90 * time.Second, "90.00s"},
+	}
+	for _, tt := range tests {
+		got := formatDuration(tt.d)
+		if got != tt.want {
+			t.Errorf("formatDuration(%d) = %q, want %q", int64(tt.d), got, tt.want)
+		}
+		// -merge が読み戻せる形式であること
+		if _, err := time.ParseDuration(got); err != nil {
+			t.Errorf("formatDuration(%d) = %q を time.ParseDuration で読み戻せません: %v", int64(tt.d), got, err)
+		}
+	}
+}
+
+// TestTinySampleReports は、サンプルが 0・1・2 件の場合に、要約統計・レポートの生成・各フォーマットの書き出し・
+// 統合のいずれも panic せず、妥当な値（0件は N/A、1件はすべて同じ値）になることを確認します。
+func TestTinySampleReports(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name    string
+		samples []time.Duration
+		want    LatencySummary
+	}{
+		{
+			name: "0件",
+			want: LatencySummary{Min: "N/A", Mean: "N/A", P50: "N/A", P90: "N/A", P99: "N/A", Max: "N/A"},
+		},
+		{
+			name:    "1件",
+			samples: []time.Duration{5 * ms},
+			want:    LatencySummary{Samples: 1, Min: "5.00ms", Mean: "5.00ms", StdDev: "0ns", P50: "5.00ms", P90: "5.00ms", P99: "5.00ms", Max: "5.00ms"},
+		},
+		{
+			name:    "2件",
+			samples: []time.Duration{4 * ms, 2 * ms},
+			// percentileIndex(2, 0.5) は 1 のため、p50 以上はすべて大きい方の値です
+			want: LatencySummary{Samples: 2, Min: "2.00ms", Mean: "3.00ms", StdDev: "1.00ms", P50: "4.00ms", P90: "4.00ms", P99: "4.00ms", Max: "4.00ms"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarizeLatencies(slices.Clone(tt.samples)); got != tt.want {
+				t.Errorf("summarizeLatencies = %+v, want %+v", got, tt.want)
+			}
+
+			sorted := slices.Sorted(slices.Values(tt.samples))
+			tail, warnings := tailPercentiles(sorted, []float64{99.9})
+			if len(tt.samples) == 0 {
+				if tail != nil || warnings != nil {
+					t.Errorf("tailPercentiles = %v, %v: 0件では何も返さないはずです", tail, warnings)
+				}
+			} else if tail["p99.9"] != tt.want.Max || len(warnings) != 1 {
+				t.Errorf("tailPercentiles = %v, %v: p99.9 は最大値、サンプル不足の警告が1件のはずです", tail, warnings)
+			}
+
+			metrics := NewResultMetrics(0)
+			for _, d := range tt.samples {
+				metrics.record(sample{dur: d, status: http.StatusOK}, false)
+			}
+			report := generateReport(metrics, time.Second, 0)
+			if report.LatencySamples != len(tt.samples) || report.P50Latency != tt.want.P50 || report.MaxLatency != tt.want.Max {
+				t.Errorf("generateReport: samples=%d p50=%s max=%s, want %d/%s/%s",
+					report.LatencySamples, report.P50Latency, report.MaxLatency, len(tt.samples), tt.want.P50, tt.want.Max)
+			}
+			for _, format := range []string{outputFormatJSON, outputFormatHey, outputFormatWrk} {
+				if err := writeReport(io.Discard, report, format, "http://127.0.0.1/"); err != nil {
+					t.Errorf("%s 形式で書き出せません: %v", format, err)
+				}
+			}
+
+			merged := mergeReports([]*TestReport{report, report})
+			if merged.LatencySamples != 2*len(tt.samples) || merged.P50Latency != tt.want.P50 || merged.MaxLatency != tt.want.Max {
+				t.Errorf("mergeReports: samples=%d p50=%s max=%s, want %d/%s/%s",
+					merged.LatencySamples, merged.P50Latency, merged.MaxLatency, 2*len(tt.samples), tt.want.P50, tt.want.Max)
+			}
+		})
+	}
+
+	// 統合するレポートが1件もない場合も panic しないこと
+	if merged := mergeReports(nil); merged.TotalRequests != 0 || merged.P50Latency != "N/A" {
+		t.Errorf("mergeReports(nil): total=%d p50=%s, want 0/N/A", merged.TotalRequests, merged.P50Latency)
+	}
+}
+
+// TestReservoirSamplingPreservesDistribution は、max_samples を超えてリザーバーサンプリングが作動しても、
+// 保持したサンプルのパーセンタイルが全件をソートした正確な値から許容誤差内に収まることを確認します。
+func TestReservoirSamplingPreservesDistribution(t *testing.T) {
+	const (
+		total      = 200000
+		maxSamples = 5000
+	)
+	// 0〜total-1 µs の一様分布を無作為な順に記録するため、値 v の正確な順位は v/total です
+	values := make([]time.Duration, total)
+	for i := range values {
+		values[i] = time.Duration(i) * time.Microsecond
+	}
+	rand.Shuffle(total, func(i, j int) { values[i], values[j] = values[j], values[i] })
+
+	metrics := NewResultMetrics(maxSamples)
+	metrics.maxSamples = maxSamples
+	for _, d := range values {
+		metrics.record(sample{dur: d, status: http.StatusOK}, false)
+	}
+	if !metrics.samplingActive || len(metrics.latencies) != maxSamples {
+		t.Fatalf("sampling=%v samples=%d: サンプリングが作動し、%d 件を保持するはずです", metrics.samplingActive, len(metrics.latencies), maxSamples)
+	}
+
+	reservoir := slices.Sorted(slices.Values(metrics.latencies))
+	for _, q := range []float64{0.10, 0.50, 0.90, 0.99} {
+		got := reservoir[percentileIndex(maxSamples, q)]
+		exact := time.Duration(percentileIndex(total, q)) * time.Microsecond
+		// 順位の誤差の標準偏差は √(q(1-q)/maxSamples) 程度（p50 で約 0.7%）のため、その5倍程度を許容します
+		tolerance := 5*math.Sqrt(q*(1-q)/maxSamples) + 0.001
+		if rankErr := math.Abs(float64(got-exact)) / float64(total*time.Microsecond); rankErr > tolerance {
+			t.Errorf("p%.0f = %v, 正確な値 %v (順位の誤差 %.4f > 許容 %.4f)", q*100, got, exact, rankErr, tolerance)
+		}
+	}
+
+	// 最小値・最大値・件数は、サンプリングに関係なく全件から求めた正確な値になること
+	report := generateReport(metrics, time.Second, 0)
+	if !report.SamplingEngaged || report.LatencyObserved != total || report.LatencySamples != maxSamples {
+		t.Errorf("sampling_engaged=%v latency_observed=%d latency_samples=%d, want true/%d/%d",
+			report.SamplingEngaged, report.LatencyObserved, report.LatencySamples, total, maxSamples)
+	}
+	if want := formatDuration(time.Duration(total-1) * time.Microsecond); report.MinLatency != "0ns" || report.MaxLatency != want {
+		t.Errorf("min=%s max=%s, want 0ns/%s", report.MinLatency, report.MaxLatency, want)
+	}
+}
+
+// TestReservoirSamplingSeeded は、リザーバーサンプリングで残るサンプルがテストのシード値から決まり、
+// 同じシード値・同じ結果の列では同じサンプルが、異なるシード値では異なるサンプルが残ることを確認します。
+func TestReservoirSamplingSeeded(t *testing.T) {
+	const maxSamples = 100
+	reservoir := func(seed uint64) []time.Duration {
+		metrics := NewResultMetrics(maxSamples)
+		metrics.maxSamples = maxSamples
+		metrics.sampleRand = newSamplingRand(seed)
+		for i := range 10000 {
+			metrics.record(sample{dur: time.Duration(i) * time.Microsecond, status: http.StatusOK}, false)
+		}
+		return metrics.latencies
+	}
+	first, again, other := reservoir(42), reservoir(42), reservoir(43)
+	if !slices.Equal(first, again) {
+		t.Error("同じシード値で残ったサンプルが異なります")
+	}
+	if slices.Equal(first, other) {
+		t.Error("異なるシード値で同じサンプルが残りました")
+	}
+}
+
+// TestSeedReproducesSelection は、同じシード値のテストでは、重み付きの targets と request_file（random）から
+// 同じ順序で同じメソッド・URLが選ばれ、異なるシード値では異なる順序になることを確認します。
+// 並行数1のクローズドモデルでは送信順が選択順と一致するため、サーバーに届いた順序で比較します。
+func TestSeedReproducesSelection(t *testing.T) {
+	sources := map[string]func(serverURL string) map[string]any{
+		"targets": func(serverURL string) map[string]any {
+			return map[string]any{"targets": []map[string]any{
+				{"url": serverURL + "/a", "method": "GET", "weight": 1},
+				{"url": serverURL + "/b", "method": "POST", "weight": 2},
+				{"url": serverURL + "/c", "method": "PUT", "weight": 1},
+			}}
+		},
+		"request_file": func(serverURL string) map[string]any {
+			requestFile := filepath.Join(t.TempDir(), "requests.txt")
+			lines := "GET " + serverURL + "/r1\nPOST " + serverURL + "/r2\nDELETE " + serverURL + "/r3\n"
+			if err := os.WriteFile(requestFile, []byte(lines), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			return map[string]any{"target_url": serverURL, "request_file": requestFile, "request_order": requestOrderRandom}
+		},
+	}
+	for name, source := range sources {
+		// テストの終了時に中断されたリクエストが次の実行の記録に紛れ込まないよう、実行ごとにサーバーを分けます
+		run := func(seed uint64) []string {
+			var mu sync.Mutex
+			var received []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				received = append(received, r.Method+" "+r.URL.Path)
+				mu.Unlock()
+			}))
+			defer server.Close()
+
+			fields := source(server.URL)
+			fields["concurrency"], fields["duration"], fields["no_preflight"], fields["seed"] = 1, "200ms", true, seed
+			runTestLoad(newTestConfig(t, fields))
+			mu.Lock()
+			defer mu.Unlock()
+			return slices.Clone(received)
+		}
+		first, again, other := run(42), run(42), run(43)
+		// 終了時に中断された最後のリクエストはサーバーに届く場合と届かない場合があるため、比較から除きます
+		n := min(len(first), len(again), len(other)) - 1
+		if n < 20 {
+			t.Fatalf("%s: 比較に足りるリクエストが届きません (%d 件)", name, n)
+		}
+		if !slices.Equal(first[:n], again[:n]) {
+			t.Errorf("%s: 同じシード値で選択の順序が異なります\n%v\n%v", name, first[:n], again[:n])
+		}
+		if slices.Equal(first[:n], other[:n]) {
+			t.Errorf("%s: 異なるシード値で同じ選択の順序になりました", name)
+		}
+	}
+}
+
+// TestSeedReproducesGeneratedBody は、gen_body_fill が random の生成ボディの中身が、同じシード値から同じに作られることを確認します。
+func TestSeedReproducesGeneratedBody(t *testing.T) {
+	g := &genBody{size: 4096, chunk: make([]byte, 4096), random: true}
+	first, again, other := g.forRun(newGenBodyRand(42)), g.forRun(newGenBodyRand(42)), g.forRun(newGenBodyRand(43))
+	if !bytes.Equal(first.chunk, again.chunk) {
+		t.Error("同じシード値で生成ボディの中身が異なります")
+	}
+	if bytes.Equal(first.chunk, other.chunk) {
+		t.Error("異なるシード値で生成ボディの中身が同じになりました")
+	}
+}
+
+// TestStatusClasses は、ステータスコードごとの件数がクラス単位に集約され、主要な5クラスが0件でも含まれ、
+// 想定外のコードが "other" にまとめられることを確認します。
+func TestStatusClasses(t *testing.T) {
+	tests := []struct {
+		name  string
+		codes map[string]uint64
+		want  map[string]uint64
+	}{
+		{
+			name:  "空",
+			codes: nil,
+			want:  map[string]uint64{"2xx": 0, "3xx": 0, "4xx": 0, "5xx": 0, "network": 0},
+		},
+		{
+			name:  "各クラス",
+			codes: map[string]uint64{"200": 10, "204": 5, "301": 2, "404": 3, "429": 4, "500": 1, "503": 6, "NetworkError": 7},
+			want:  map[string]uint64{"2xx": 15, "3xx": 2, "4xx": 7, "5xx": 7, "network": 7},
+		},
+		{
+			name:  "境界と想定外のコード",
+			codes: map[string]uint64{"199": 1, "101": 2, "200": 1, "599": 3, "600": 4, "abc": 5},
+			want:  map[string]uint64{"2xx": 1, "3xx": 0, "4xx": 0, "5xx": 3, "network": 0, "other": 12},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statusClasses(tt.codes); !maps.Equal(got, tt.want) {
+				t.Errorf("statusClasses = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestStatusErrorRates は、5xx と 4xx の割合が、通信エラーや 2xx・3xx・想定外のコードを含むすべてのリクエストを母数に求められることを確認します。
+func TestStatusErrorRates(t *testing.T) {
+	tests := []struct {
+		name                   string
+		codes                  map[string]uint64
+		wantServer, wantClient float64
+	}{
+		{"空", nil, 0, 0},
+		{"すべて成功", map[string]uint64{"200": 100}, 0, 0},
+		{"すべて 5xx", map[string]uint64{"500": 3, "503": 1}, 1, 0},
+		// 全 200 件: 2xx 100・3xx 10・4xx 30（404: 20, 429: 10）・5xx 40（500: 25, 502: 15）・通信エラー 15・その他 5
+		{
+			name:       "混在",
+			codes:      map[string]uint64{"200": 100, "302": 10, "404": 20, "429": 10, "500": 25, "502": 15, "NetworkError": 15, "101": 5},
+			wantServer: 40.0 / 200,
+			wantClient: 30.0 / 200,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := statusErrorRates(tt.codes)
+			if server != tt.wantServer || client != tt.wantClient {
+				t.Errorf("statusErrorRates = (%v, %v), want (%v, %v)", server, client, tt.wantServer, tt.wantClient)
+			}
+		})
+	}
+}
+
+// TestEstimateTotalRequests は、事前確保するサンプル数の見積もりと、max_samples・maxPreallocSamples による頭打ちを確認します。
+func TestEstimateTotalRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  TestConfig
+		want int
+	}{
+		{"既定の想定RPS", TestConfig{Concurrency: 10, Duration: configDuration(10 * time.Second)}, 10 * defaultExpectedRPSPerWorker * 10},
+		{"想定RPSの指定", TestConfig{Concurrency: 10, Duration: configDuration(10 * time.Second), ExpectedRPSPerWorker: 5}, 500},
+		{"オープンモデル", TestConfig{Concurrency: 1000, Duration: configDuration(10 * time.Second), LoadModel: loadModelOpen, RateLimit: 250}, 2500},
+		{"トレースの再生", TestConfig{Concurrency: 10, Duration: configDuration(time.Hour), traceReplay: &traceReplay{events: make([]traceEvent, 42)}}, 42},
+		{"max_samples で頭打ち", TestConfig{Concurrency: 100, Duration: configDuration(time.Minute), MaxSamples: 1000}, 1000},
+		{"maxPreallocSamples で頭打ち", TestConfig{Concurrency: 50000, Duration: configDuration(time.Hour)}, maxPreallocSamples},
+		// int であふれる掛け算でも、負の値や巨大な確保にならないこと
+		{"桁あふれ", TestConfig{Concurrency: math.MaxInt32, Duration: math.MaxInt64, ExpectedRPSPerWorker: math.MaxInt32}, maxPreallocSamples},
+		{"実行時間なし", TestConfig{Concurrency: 10}, 10000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := estimateTotalRequests(&tt.cfg); got != tt.want {
+				t.Errorf("estimateTotalRequests = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// BenchmarkRecordLatencies は、100万件のレイテンシを記録する費用を、見積もりで事前確保した場合と確保しない場合で比べます
+// （事前確保しない場合は、スライスの拡張のたびに全件のコピーがロックの内側で発生します）。
+//
+//	go test -run '^$' -bench RecordLatencies -benchmem
+func BenchmarkRecordLatencies(b *testing.B) {
+	cfg := &TestConfig{Concurrency: 100, Duration: configDuration(10 * time.Second), ExpectedRPSPerWorker: 1000}
+	const samples = 1_000_000
+	for _, bm := range []struct {
+		name     string
+		prealloc int
+	}{
+		{"prealloc", estimateTotalRequests(cfg)},
+		{"grow", 0},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				metrics := NewResultMetrics(bm.prealloc)
+				for i := range samples {
+					metrics.addLatency(time.Duration(i))
+				}
+			}
+		})
+	}
+}
+
+// TestLatencyStatsMatchesTwoPass は、記録時に逐次更新した平均・標準偏差・最小・最大が、全件を2回走査して求めた値と一致することを確認します
+// （平均が大きく分散が小さい、二乗和の単純な累積では桁落ちする分布を含みます）。
+func TestLatencyStatsMatchesTwoPass(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	latencies := make([]time.Duration, 100000)
+	for i := range latencies {
+		latencies[i] = 10*time.Second + time.Duration(r.IntN(1000))
+	}
+
+	var stats latencyStats
+	for _, d := range latencies {
+		stats.add(d)
+	}
+
+	var sum float64
+	for _, d := range latencies {
+		sum += float64(d)
+	}
+	mean := sum / float64(len(latencies))
+	var sq float64
+	for _, d := range latencies {
+		sq += (float64(d) - mean) * (float64(d) - mean)
+	}
+	stdDev := math.Sqrt(sq / float64(len(latencies)))
+
+	if math.Abs(stats.mean-mean) > 1 {
+		t.Errorf("mean = %v, 2回の走査では %v", stats.mean, mean)
+	}
+	if got := float64(stats.stdDev()); math.Abs(got-stdDev) > 1 {
+		t.Errorf("stdDev = %v, 2回の走査では %v", got, stdDev)
+	}
+	if stats.min != slices.Min(latencies) || stats.max != slices.Max(latencies) || stats.count != int64(len(latencies)) {
+		t.Errorf("min=%v max=%v count=%d, want %v/%v/%d", stats.min, stats.max, stats.count, slices.Min(latencies), slices.Max(latencies), len(latencies))
+	}
+}
+
+// BenchmarkSummarizeLatencies は、500万件のサンプルからレポートの要約を求める費用を、記録時に逐次集計した値を使う場合
+// （summarizeWithStats）と、レポートの生成時に全件を走査して集計する場合（summarizeLatencies）で比べます。
+// いずれもパーセンタイルの位置を確定させる並べ替えを含みます。
+//
+//	go test -run '^$' -bench SummarizeLatencies -benchtime 5x
+func BenchmarkSummarizeLatencies(b *testing.B) {
+	const samples = 5_000_000
+	r := rand.New(rand.NewPCG(1, 2))
+	src := make([]time.Duration, samples)
+	var stats latencyStats
+	for i := range src {
+		src[i] = time.Duration(r.Int64N(int64(time.Second)))
+		stats.add(src[i])
+	}
+	latencies := make([]time.Duration, samples)
+
+	b.Run("incremental", func(b *testing.B) {
+		for range b.N {
+			copy(latencies, src)
+			summarizeWithStats(latencies, &stats)
+		}
+	})
+	b.Run("full_pass", func(b *testing.B) {
+		for range b.N {
+			copy(latencies, src)
+			summarizeLatencies(latencies)
+		}
+	})
+}
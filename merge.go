@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// [セクション10] 分散実行の集計: 複数レポートJSONの統合 (-merge)
+// ==============================================================================
+
+// 複数マシンから同じターゲットへ同時に負荷をかけた場合、各マシンのレポートを統合して全体像を把握します。
+//
+// 【精度に関する注意】
+// レポートJSONには生のレイテンシサンプルが含まれないため、パーセンタイルを厳密に再計算することはできません。
+// 統合後の p50/p90/p99 は、各レポートのパーセンタイルをサンプル数で加重平均した「近似値」です。
+// 各マシンのレイテンシ分布が大きく異なる場合（片方だけネットワーク的に遠い等）は誤差が大きくなるため、
+// percentiles_approximate フィールドで近似値であることを明示しています。
+// 件数・RPS・ステータスコード分布・最小値・最大値は正確に統合されます。
+
+// runMergeCommand は -merge サブコマンドのエントリーポイントです。
+// 統合したレポートをJSONとして標準出力へ書き出し、プロセスの終了コードを返します。
+func runMergeCommand(paths []string) int {
+	if len(paths) < 2 {
+		fmt.Fprintln(os.Stderr, "[Merge Error] 統合するレポートJSONを2つ以上指定してください (例: -merge r1.json r2.json)")
+		return 2
+	}
+
+	reports := make([]*TestReport, 0, len(paths))
+	for _, path := range paths {
+		report, err := loadReport(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[Merge Error] %s の読み込みに失敗しました: %v\n", path, err)
+			return 1
+		}
+		reports = append(reports, report)
+	}
+
+	merged := mergeReports(reports)
+
+	if err := writeReportOutput(merged, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "[Merge Error] 統合レポートの出力に失敗しました: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintln(os.Stderr, "[Merge] 注意: 統合後のパーセンタイルは各レポートの加重平均による近似値です。")
+	return 0
+}
+
+// loadReport は、/api/run が返したレポートJSONをファイルから読み込みます。
+func loadReport(path string) (*TestReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var report TestReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("JSONフォーマットが正しくありません: %w", err)
+	}
+	return &report, nil
+}
+
+// mergeReports は、同時刻に実行された複数のレポートを1つに統合します。
+// 各マシンは並行して負荷をかけていた前提のため、スループット（RPS）は単純に合算します。
+func mergeReports(reports []*TestReport) *TestReport {
+	merged := &TestReport{
+		StatusCodes:            make(map[string]uint64),
+		MergedReports:          len(reports),
+		PercentilesApproximate: true,
+	}
+
+	latencySummaries := make([]LatencySummary, 0, len(reports))
+	var connectSummaries, ttfbSummaries, fullSummaries, coldStartSummaries []LatencySummary
+	var workerDists []*WorkerDistribution
+	var errorMsgs []string
+
+	for _, report := range reports {
+		merged.TotalRequests += report.TotalRequests
+		merged.Success += report.Success
+		merged.Errors += report.Errors
+		merged.TimedOut += report.TimedOut
+		merged.JSONAssertionFailures += report.JSONAssertionFailures
+		merged.SlowResponses += report.SlowResponses
+		merged.ConnectionCloseResponses += report.ConnectionCloseResponses
+		merged.ThroughputRPS += report.ThroughputRPS
+		merged.InFlightCapHits += report.InFlightCapHits
+		merged.ConnectionsOpened += report.ConnectionsOpened
+		merged.PrewarmedConnections += report.PrewarmedConnections
+		merged.DialErrors += report.DialErrors
+		merged.DialErrorsOpening += report.DialErrorsOpening
+		merged.ConnectPhases = mergeConnectPhases(merged.ConnectPhases, report.ConnectPhases)
+		merged.DialConcurrency += report.DialConcurrency
+		// 平均の名前解決時間は、解決回数による加重平均で統合します（いったん合計時間として足し込みます）
+		merged.AvgDNSLookupMs += report.AvgDNSLookupMs * float64(report.DNSLookups)
+		merged.DNSLookups += report.DNSLookups
+		merged.DNSErrors += report.DNSErrors
+		// 同時に実行した前提なので平均値は合計になります。最大値は各マシンで同時に達したとは限らないため、合計は上限の目安です
+		merged.PeakInFlight += report.PeakInFlight
+		merged.AvgInFlight += report.AvgInFlight
+
+		// いずれかのレポートでサンプリングが作動していれば、統合後もその旨を示します
+		if report.SamplingEngaged {
+			merged.SamplingEngaged = true
+			merged.LatencyObserved += report.LatencyObserved
+		} else {
+			merged.LatencyObserved += int64(report.LatencySamples)
+		}
+		merged.SkippedOverload += report.SkippedOverload
+		merged.RateLimited += report.RateLimited
+		merged.BackoffTotalSec += report.BackoffTotalSec
+		merged.MaxRPS += report.MaxRPS
+		merged.Connections += report.Connections
+		merged.MaxRPSCapHits += report.MaxRPSCapHits
+		merged.MaxRPSEngaged = merged.MaxRPSEngaged || report.MaxRPSEngaged
+		merged.LatencySimulated = merged.LatencySimulated || report.LatencySimulated
+		// fail_fast で停止したレポートがあれば、最初に見つかったものの詳細を引き継ぎます
+		if merged.FailFast == nil {
+			merged.FailFast = report.FailFast
+		}
+		if merged.ThroughputFloor == nil {
+			merged.ThroughputFloor = report.ThroughputFloor
+		}
+		if merged.AbortedReason == "" {
+			merged.AbortedReason = report.AbortedReason
+		}
+
+		// 同時に実行した前提なので、実行時間は最も長かったマシンの値を採用します
+		if report.ActualDurationSec > merged.ActualDurationSec {
+			merged.ActualDurationSec = report.ActualDurationSec
+		}
+
+		for code, count := range report.StatusCodes {
+			merged.StatusCodes[code] += count
+		}
+		merged.ErrorKinds = addCounts(merged.ErrorKinds, report.ErrorKinds)
+		merged.TLSVersions = addCounts(merged.TLSVersions, report.TLSVersions)
+		merged.TLSCipherSuites = addCounts(merged.TLSCipherSuites, report.TLSCipherSuites)
+		merged.RequestsByIP = addCounts(merged.RequestsByIP, report.RequestsByIP)
+		merged.LatencyHistogram = addCounts(merged.LatencyHistogram, report.LatencyHistogram)
+		merged.LatencyHistogramCount += report.LatencyHistogramCount
+		merged.LatencyHistogramSumSec += report.LatencyHistogramSumSec
+		merged.BytesUploaded += report.BytesUploaded
+		merged.Targets = addTargetReports(merged.Targets, report.Targets)
+		merged.HostPools = addHostPoolReports(merged.HostPools, report.HostPools)
+		merged.SampleExchanges = append(merged.SampleExchanges, report.SampleExchanges...)
+		workerDists = append(workerDists, report.WorkerDistribution)
+
+		latencySummaries = append(latencySummaries, LatencySummary{
+			Samples: report.LatencySamples,
+			Min:     report.MinLatency,
+			Mean:    report.MeanLatency,
+			StdDev:  report.StdDevLatency,
+			P50:     report.P50Latency,
+			P90:     report.P90Latency,
+			P99:     report.P99Latency,
+			Max:     report.MaxLatency,
+		})
+		if report.WSConnect != nil {
+			connectSummaries = append(connectSummaries, *report.WSConnect)
+		}
+		if report.TTFB != nil {
+			ttfbSummaries = append(ttfbSummaries, *report.TTFB)
+		}
+		if report.FullResponse != nil {
+			fullSummaries = append(fullSummaries, *report.FullResponse)
+		}
+		if report.ColdStart != nil {
+			coldStartSummaries = append(coldStartSummaries, *report.ColdStart)
+		}
+		if report.ErrorMsg != "" {
+			errorMsgs = append(errorMsgs, report.ErrorMsg)
+		}
+		merged.Warnings = append(merged.Warnings, report.Warnings...)
+	}
+
+	values, samples := mergeLatencyDurations(latencySummaries)
+	summary := values.summary(samples)
+	merged.latency = values
+	merged.LatencySamples = summary.Samples
+	merged.MinLatency, merged.MeanLatency, merged.P50Latency = summary.Min, summary.Mean, summary.P50
+	merged.P90Latency, merged.P99Latency, merged.MaxLatency = summary.P90, summary.P99, summary.Max
+	merged.StdDevLatency = summary.StdDev
+	merged.TailPercentiles, merged.PercentileWarnings = mergeTailPercentiles(reports, merged.LatencySamples)
+	if merged.Apdex = mergeApdex(reports); merged.Apdex != nil {
+		merged.ApdexTarget = reports[0].ApdexTarget
+	}
+	if merged.TrimmedMean = mergeTrimmedMean(reports); merged.TrimmedMean != "" {
+		merged.TrimmedMeanFraction = reports[0].TrimmedMeanFraction
+	}
+
+	if len(connectSummaries) > 0 {
+		connectSummary := mergeLatencySummaries(connectSummaries)
+		merged.WSConnect = &connectSummary
+	}
+	if len(ttfbSummaries) > 0 {
+		ttfbSummary := mergeLatencySummaries(ttfbSummaries)
+		merged.TTFB = &ttfbSummary
+	}
+	if len(fullSummaries) > 0 {
+		fullSummary := mergeLatencySummaries(fullSummaries)
+		merged.FullResponse = &fullSummary
+	}
+	if len(coldStartSummaries) > 0 {
+		coldStartSummary := mergeLatencySummaries(coldStartSummaries)
+		merged.ColdStart = &coldStartSummary
+	}
+
+	// 先頭のレポートを基準にする項目は、統合するレポートが1件もない場合は空のままにします
+	if len(reports) > 0 {
+		// シード値は、全マシンで同じ値を指定していた場合のみ引き継ぎます
+		merged.Seed = reports[0].Seed
+		for _, report := range reports[1:] {
+			if report.Seed != merged.Seed {
+				merged.Seed = 0
+				break
+			}
+		}
+
+		// ビルド情報と設定値は先頭のレポートの値を引き継ぎます
+		merged.ToolVersion = reports[0].ToolVersion
+		merged.MaxRequestsPerConn = reports[0].MaxRequestsPerConn
+		merged.RequestSpecs = reports[0].RequestSpecs
+		merged.DNSServer = reports[0].DNSServer
+		merged.InjectedLatency = reports[0].InjectedLatency
+		merged.HTTP3 = reports[0].HTTP3
+		merged.SlowThreshold = reports[0].SlowThreshold
+		merged.SlowIsError = reports[0].SlowIsError
+
+		// タグは、全レポートで値が一致するものだけを引き継ぎます
+		merged.Tags = commonTags(reports)
+	}
+
+	if !merged.SamplingEngaged {
+		merged.LatencyObserved = 0
+	}
+
+	merged.StatusClasses = statusClasses(merged.StatusCodes)
+	merged.ErrorRate = errorRate(merged.Errors, merged.TotalRequests)
+	merged.ServerErrorRate, merged.ClientErrorRate = statusErrorRates(merged.StatusCodes)
+	merged.TimeoutRatePct = timeoutRatePct(merged.TimedOut, merged.TotalRequests)
+	merged.SlowRatePct = slowRatePct(merged.SlowResponses, merged.TotalRequests)
+	merged.ConnectionClosePct = connectionClosePct(merged.ConnectionCloseResponses, merged.TotalRequests)
+	if merged.DNSLookups > 0 {
+		merged.AvgDNSLookupMs /= float64(merged.DNSLookups)
+	}
+	merged.WorkerDistribution = mergeWorkerDistributions(workerDists)
+	merged.SlowestRequests = mergeSlowest(reports)
+	merged.RequestsPerConnection = requestsPerConnection(merged.TotalRequests, merged.ConnectionsOpened+uint64(merged.PrewarmedConnections))
+	applyRunStatus(merged)
+	merged.ErrorMsg = strings.Join(errorMsgs, " / ")
+	merged.Warnings = uniqueStrings(merged.Warnings)
+	return merged
+}
+
+// addTargetReports は、src のターゲットごとの結果を、URLとメソッドが一致する dst の項目へ加算して返します。
+// 各マシンが同じ設定で同時に実行した前提のため、設定レートは合計、重みは先頭の値を維持します。
+func addTargetReports(dst, src []TargetReport) []TargetReport {
+	for _, t := range src {
+		i := slices.IndexFunc(dst, func(d TargetReport) bool { return d.URL == t.URL && d.Method == t.Method })
+		if i < 0 {
+			dst = append(dst, t)
+			continue
+		}
+		dst[i].ConfiguredRPS += t.ConfiguredRPS
+		dst[i].Requests += t.Requests
+		dst[i].AchievedRPS += t.AchievedRPS
+	}
+	return dst
+}
+
+// addCounts は、src の各カウントを dst に加算して返します。dst が nil の場合は必要に応じて生成します。
+func addCounts(dst, src map[string]uint64) map[string]uint64 {
+	for key, count := range src {
+		if dst == nil {
+			dst = make(map[string]uint64)
+		}
+		dst[key] += count
+	}
+	return dst
+}
+
+// mergeLatencySummaries は、複数のレイテンシ要約統計を統合します。
+// 最小値・最大値は正確に、平均値はサンプル数による加重平均で正確に求まりますが、
+// パーセンタイルは加重平均による近似値となります。
+func mergeLatencySummaries(summaries []LatencySummary) LatencySummary {
+	values, total := mergeLatencyDurations(summaries)
+	return values.summary(total)
+}
+
+// mergeLatencyDurations は、mergeLatencySummaries と同じ統合を文字列に丸める前の値で行い、統合後のサンプル数とともに返します
+// （統合できるサンプルがない場合は nil）。
+func mergeLatencyDurations(summaries []LatencySummary) (*summaryDurations, int) {
+	var (
+		total                  int
+		minLatency, maxLatency time.Duration
+		mean, p50, p90, p99    float64
+		hasMin                 bool
+
+		// 標準偏差は、各分布の「分散 + 平均の二乗」の加重和から正確に統合できます（全レポートが値を持つ場合のみ）
+		secondMoment float64
+		hasStdDev    = true
+	)
+
+	for _, s := range summaries {
+		// サンプルが0件のレポート（全リクエストがネットワークエラー等）は統計に影響させません
+		if s.Samples == 0 {
+			continue
+		}
+
+		values, err := parseSummaryDurations(s)
+		if err != nil {
+			continue
+		}
+
+		weight := float64(s.Samples)
+		total += s.Samples
+		mean += float64(values.mean) * weight
+		p50 += float64(values.p50) * weight
+		p90 += float64(values.p90) * weight
+		p99 += float64(values.p99) * weight
+
+		if values.hasStdDev {
+			secondMoment += (float64(values.stdDev)*float64(values.stdDev) + float64(values.mean)*float64(values.mean)) * weight
+		} else {
+			hasStdDev = false
+		}
+
+		if !hasMin || values.min < minLatency {
+			minLatency = values.min
+			hasMin = true
+		}
+		if values.max > maxLatency {
+			maxLatency = values.max
+		}
+	}
+
+	if total == 0 {
+		return nil, 0
+	}
+
+	weightTotal := float64(total)
+	merged := &summaryDurations{
+		min:  minLatency,
+		mean: time.Duration(mean / weightTotal),
+		p50:  time.Duration(p50 / weightTotal),
+		p90:  time.Duration(p90 / weightTotal),
+		p99:  time.Duration(p99 / weightTotal),
+		max:  maxLatency,
+	}
+	if hasStdDev {
+		m := mean / weightTotal
+		merged.stdDev = time.Duration(math.Sqrt(max(secondMoment/weightTotal-m*m, 0)))
+		merged.hasStdDev = true
+	}
+	return merged, total
+}
+
+// parseSummaryDurations は、レポート内の "1.23ms" 形式の文字列を time.Duration に変換します。
+func parseSummaryDurations(s LatencySummary) (summaryDurations, error) {
+	var values summaryDurations
+	fields := []struct {
+		raw string
+		dst *time.Duration
+	}{
+		{s.Min, &values.min},
+		{s.Mean, &values.mean},
+		{s.P50, &values.p50},
+		{s.P90, &values.p90},
+		{s.P99, &values.p99},
+		{s.Max, &values.max},
+	}
+
+	for _, f := range fields {
+		d, err := time.ParseDuration(f.raw)
+		if err != nil {
+			return values, errors.Join(fmt.Errorf("レイテンシ値 %q を解釈できません", f.raw), err)
+		}
+		*f.dst = d
+	}
+	// 標準偏差は省略されている場合があるため（0件のレポートなど）、解釈できた場合だけ設定します
+	if sd, err := time.ParseDuration(s.StdDev); err == nil {
+		values.stdDev, values.hasStdDev = sd, true
+	}
+	return values, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// syntheticReport は、mergeReports のテスト用に、件数とレイテンシ要約統計だけを持つレポートを生成します。
+func syntheticReport(success, errors int, rps, durationSec float64, samples int, minL, mean, p50, p90, p99, maxL time.Duration) *TestReport {
+	return &TestReport{
+		TotalRequests:     success + errors,
+		Success:           success,
+		Errors:            errors,
+		ThroughputRPS:     rps,
+		ActualDurationSec: durationSec,
+		StatusCodes:       map[string]uint64{"200": uint64(success), "503": uint64(errors)},
+		LatencySamples:    samples,
+		MinLatency:        formatDuration(minL),
+		MeanLatency:       formatDuration(mean),
+		P50Latency:        formatDuration(p50),
+		P90Latency:        formatDuration(p90),
+		P99Latency:        formatDuration(p99),
+		MaxLatency:        formatDuration(maxL),
+	}
+}
+
+// TestMergeReports は、2つのレポートの件数・RPS・ステータスコードが合計され、最小値・最大値は正確に、
+// 平均値とパーセンタイルはサンプル数による加重平均で統合されることを確認します。
+func TestMergeReports(t *testing.T) {
+	ms := time.Millisecond
+	a := syntheticReport(90, 10, 100, 1.0, 100, 1*ms, 10*ms, 10*ms, 20*ms, 40*ms, 50*ms)
+	b := syntheticReport(300, 0, 250, 1.2, 300, 2*ms, 30*ms, 20*ms, 40*ms, 60*ms, 80*ms)
+
+	merged := mergeReports([]*TestReport{a, b})
+
+	if merged.TotalRequests != 400 || merged.Success != 390 || merged.Errors != 10 {
+		t.Errorf("total=%d success=%d errors=%d, want 400/390/10", merged.TotalRequests, merged.Success, merged.Errors)
+	}
+	if merged.ThroughputRPS != 350 {
+		t.Errorf("throughput_rps = %v, want 350", merged.ThroughputRPS)
+	}
+	if merged.ActualDurationSec != 1.2 {
+		t.Errorf("actual_duration_sec = %v, want 1.2 (長い方)", merged.ActualDurationSec)
+	}
+	if merged.StatusCodes["200"] != 390 || merged.StatusCodes["503"] != 10 {
+		t.Errorf("status_codes = %v, want 200:390 503:10", merged.StatusCodes)
+	}
+	if merged.ErrorRate != 10.0/400 {
+		t.Errorf("error_rate = %v, want %v", merged.ErrorRate, 10.0/400)
+	}
+	if merged.MergedReports != 2 || !merged.PercentilesApproximate {
+		t.Errorf("merged_reports=%d percentiles_approximate=%v, want 2/true", merged.MergedReports, merged.PercentilesApproximate)
+	}
+	if merged.LatencySamples != 400 {
+		t.Errorf("latency_samples = %d, want 400", merged.LatencySamples)
+	}
+
+	// 加重平均: (100×a + 300×b) / 400
+	latencies := []struct {
+		name      string
+		got, want string
+	}{
+		{"min", merged.MinLatency, formatDuration(1 * ms)},
+		{"mean", merged.MeanLatency, formatDuration(25 * ms)},
+		{"p50", merged.P50Latency, formatDuration(17500 * time.Microsecond)},
+		{"p90", merged.P90Latency, formatDuration(35 * ms)},
+		{"p99", merged.P99Latency, formatDuration(55 * ms)},
+		{"max", merged.MaxLatency, formatDuration(80 * ms)},
+	}
+	for _, l := range latencies {
+		if l.got != l.want {
+			t.Errorf("%s = %s, want %s", l.name, l.got, l.want)
+		}
+	}
+}
+
+// TestMergeReportsSkipsEmptyLatency は、応答を1件も受信できなかったレポート（レイテンシが N/A）を統合しても、
+// 件数には含めつつ、レイテンシの統計には影響しないことを確認します。
+func TestMergeReportsSkipsEmptyLatency(t *testing.T) {
+	ms := time.Millisecond
+	a := syntheticReport(100, 0, 100, 1.0, 100, 1*ms, 10*ms, 10*ms, 20*ms, 40*ms, 50*ms)
+	empty := &TestReport{
+		TotalRequests: 50,
+		Errors:        50,
+		StatusCodes:   map[string]uint64{"NetworkError": 50},
+		MinLatency:    "N/A", MeanLatency: "N/A", P50Latency: "N/A",
+		P90Latency: "N/A", P99Latency: "N/A", MaxLatency: "N/A",
+	}
+
+	merged := mergeReports([]*TestReport{a, empty})
+
+	if merged.TotalRequests != 150 || merged.Errors != 50 {
+		t.Errorf("total=%d errors=%d, want 150/50", merged.TotalRequests, merged.Errors)
+	}
+	if merged.LatencySamples != 100 || merged.P50Latency != a.P50Latency || merged.MaxLatency != a.MaxLatency {
+		t.Errorf("samples=%d p50=%s max=%s: 空のレポートがレイテンシに影響しています", merged.LatencySamples, merged.P50Latency, merged.MaxLatency)
+	}
+}
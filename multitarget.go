@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション20] 複数ターゲット: エンドポイントの混在と、ターゲットごとのレート上限
+// ==============================================================================
+
+// 実際のサービスでは、読み込み系と書き込み系など複数のエンドポイントに同時にアクセスが発生し、
+// エンドポイントごとに許容できるレートも異なります（書き込み系は読み込み系より低く抑えたい、など）。
+// targets を指定すると、各ワーカーは送信のたびに重み（weight）に応じてターゲットを無作為に選び、
+// そのターゲットに rate_limit が設定されていれば、全ワーカーで共有するターゲット専用のリミッターで送信を待機します。
+// すべてのターゲットにレート上限がある場合、全体のレートは各ターゲットのレートの合計になります。
+//
+// weight を省略した場合は、rate_limit が設定されていればその値（レートに比例して選ぶ）、なければ1になります。
+// レートに比例して選ぶことで、上限の低いターゲットの待機にワーカーが偏って滞留することを防ぎます。
+
+// TargetSpec は、複数ターゲットモードの1つの送信先です。
+type TargetSpec struct {
+	URL       string  `json:"url"`        // 送信先の完全なURL
+	Method    string  `json:"method"`     // HTTPメソッド（未指定時は全体の method）
+	Weight    float64 `json:"weight"`     // 選択される比率（未指定時は rate_limit、それもなければ1）
+	RateLimit float64 `json:"rate_limit"` // このターゲットへの最大レート（リクエスト/秒）。0の場合は無制限
+
+	// InjectLatency は、このターゲットへの送信前に注入する遅延です（未指定時は全体の inject_latency。inject.go を参照）
+	InjectLatency configDuration `json:"inject_latency"`
+}
+
+// TargetReport は、ターゲットごとの設定レートと実際に達成したレートです。
+type TargetReport struct {
+	URL           string  `json:"url"`
+	Method        string  `json:"method"`
+	Weight        float64 `json:"weight"`
+	ConfiguredRPS float64 `json:"configured_rps,omitempty"` // 設定されたレート上限（無制限の場合は省略）
+	Requests      uint64  `json:"requests"`                 // 完了したリクエスト数
+	AchievedRPS   float64 `json:"achieved_rps"`             // 実際に達成したレート
+
+	InjectedLatency string `json:"injected_latency,omitempty"` // 送信前に注入した遅延の設定（注入しない場合は省略）
+}
+
+// targetLimiter は、1つのターゲットへの送信間隔を全ワーカーで共有して制御するリミッターです（バースト1のトークンバケット相当）。
+// 送信のたびに次の空きスロットを予約するため、多数のワーカーが同時に待機しても設定レートを超えることはありません。
+type targetLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time // 次に予約可能なスロット
+}
+
+// newTargetLimiter は、rate リクエスト/秒の targetLimiter を生成します。rate が0以下の場合は nil を返します（待機しません）。
+func newTargetLimiter(rate float64) *targetLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &targetLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// Wait は、次の空きスロットを予約してその時刻まで待機します。ctx がキャンセルされた場合は false を返します。
+// 待機中にキャンセルされた場合、予約したスロットは消費されたままになります（テスト終了時のみのため問題ありません）。
+func (l *targetLimiter) Wait(ctx context.Context) bool {
+	if l == nil {
+		return ctx.Err() == nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		// 遊休期間の分をまとめて送信できないよう、過去のスロットは繰り越しません
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	d := at.Sub(now)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// requestTarget は、実行時の1ターゲットの状態です。ベースリクエストは複製してのみ使うため、全ワーカーで共有できます。
+type requestTarget struct {
+	spec      TargetSpec
+	baseReq   *http.Request
+	limiter   *targetLimiter
+	inject    *latencyInjector // 送信前に注入する遅延（nil の場合は注入しません）
+	pool      *hostPool        // ホストごとのプール（nil の場合は共有のクライアントを使います。hostpool.go を参照）
+	completed uint64           // 完了したリクエスト数（アトミックに更新）
+}
+
+// targetSet は、複数ターゲットモードのターゲット一覧と、重み付き選択のための累積重みです。
+type targetSet struct {
+	targets    []*requestTarget
+	cumWeights []float64
+}
+
+// newTargetSet は、設定された targets から targetSet を生成します。targets が未指定の場合は nil を返します。
+// 各フィールドの既定値は readTestConfig で適用済みである前提です。
+func newTargetSet(cfg *TestConfig) (*targetSet, error) {
+	if len(cfg.Targets) == 0 {
+		return nil, nil
+	}
+	ts := &targetSet{}
+	var sum float64
+	for _, spec := range cfg.Targets {
+		baseReq, err := newBaseRequest(context.Background(), spec.Method, spec.URL, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("ターゲット %s のリクエストを初期化できません: %w", spec.URL, err)
+		}
+		sum += spec.Weight
+		// ターゲットごとの遅延の指定は、全体の inject_latency より優先します（ばらつきは全体の指定を共有します）
+		injectLatency := cfg.InjectLatency
+		if spec.InjectLatency > 0 {
+			injectLatency = spec.InjectLatency
+		}
+		ts.targets = append(ts.targets, &requestTarget{
+			spec:    spec,
+			baseReq: baseReq,
+			limiter: newTargetLimiter(spec.RateLimit),
+			inject:  newLatencyInjector(time.Duration(injectLatency), time.Duration(cfg.InjectLatencyJitter)),
+		})
+		ts.cumWeights = append(ts.cumWeights, sum)
+	}
+	return ts, nil
+}
+
+// pick は、重みに応じてターゲットを1つ無作為に選びます。
+func (ts *targetSet) pick(rng *rand.Rand) *requestTarget {
+	r := rng.Float64() * ts.cumWeights[len(ts.cumWeights)-1]
+	for i, cum := range ts.cumWeights {
+		if r < cum {
+			return ts.targets[i]
+		}
+	}
+	return ts.targets[len(ts.targets)-1]
+}
+
+// report は、ターゲットごとの達成レートを elapsed（実際の実行時間）から算出します。
+func (ts *targetSet) report(elapsed time.Duration) []TargetReport {
+	reports := make([]TargetReport, 0, len(ts.targets))
+	for _, t := range ts.targets {
+		n := atomic.LoadUint64(&t.completed)
+		r := TargetReport{
+			URL:           t.spec.URL,
+			Method:        t.spec.Method,
+			Weight:        t.spec.Weight,
+			ConfiguredRPS: t.spec.RateLimit,
+			Requests:      n,
+
+			InjectedLatency: t.inject.String(),
+		}
+		if elapsed > 0 {
+			r.AchievedRPS = float64(n) / elapsed.Seconds()
+		}
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+// normalizeTargets は、targets の各項目を検証し、method と weight の既定値を適用します。
+// 問題がある場合は、利用者向けのエラーメッセージを返します。
+func normalizeTargets(cfg *TestConfig) error {
+	for i := range cfg.Targets {
+		t := &cfg.Targets[i]
+		normalized, warning, err := normalizeTargetURL(t.URL, modeHTTP)
+		if err != nil {
+			return fmt.Errorf("targets[%d]: %w", i, err)
+		}
+		if warning != "" {
+			cfg.warnings = append(cfg.warnings, fmt.Sprintf("targets[%d]: %s", i, warning))
+		}
+		t.URL = normalized
+		if t.Method == "" {
+			t.Method = cfg.Method
+		}
+		if !isValidMethod(t.Method) {
+			return fmt.Errorf("targets[%d] の method はHTTPメソッドとして使用できない文字列です: %q", i, t.Method)
+		}
+		if t.RateLimit < 0 || t.Weight < 0 || t.InjectLatency < 0 {
+			return fmt.Errorf("targets[%d] の rate_limit、weight、inject_latency は0以上で指定してください", i)
+		}
+		if t.Weight == 0 {
+			t.Weight = 1
+			if t.RateLimit > 0 {
+				t.Weight = t.RateLimit
+			}
+		}
+	}
+	return nil
+}
+
+// preflightConfigs は、プリフライトで確認する送信先ごとの設定を返します。
+// 複数ターゲットモードでは、いずれか1つでも到達できなければ意味のある結果にならないため、すべてのターゲットを確認します。
+func preflightConfigs(cfg *TestConfig) []*TestConfig {
+	if len(cfg.Targets) == 0 {
+		return []*TestConfig{cfg}
+	}
+	configs := make([]*TestConfig, 0, len(cfg.Targets))
+	for _, t := range cfg.Targets {
+		c := *cfg
+		c.TargetURL, c.Method = t.URL, t.Method
+		configs = append(configs, &c)
+	}
+	return configs
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestTargetRateLimits は、targets の各ターゲットの rate_limit が全ワーカーで共有され、即座に応答するターゲットに対しても
+// それぞれのターゲットへの送信が自分の上限に抑えられることを確認します。
+func TestTargetRateLimits(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received[r.URL.Path]++
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	caps := map[string]float64{"/slow": 20, "/fast": 80}
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"targets": []map[string]any{
+			{"url": server.URL + "/slow", "rate_limit": caps["/slow"]},
+			{"url": server.URL + "/fast", "rate_limit": caps["/fast"]},
+		},
+		"concurrency":  8,
+		"duration":     "1s",
+		"no_preflight": true,
+	}))
+
+	if len(report.Targets) != 2 {
+		t.Fatalf("targets = %+v: ターゲットごとの結果が2件あるはずです", report.Targets)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, target := range report.Targets {
+		path := target.URL[len(server.URL):]
+		limit := caps[path]
+		if target.ConfiguredRPS != limit {
+			t.Errorf("%s: configured_rps = %v, want %v", path, target.ConfiguredRPS, limit)
+		}
+		// 初回の送信は待たずに行うため、上限の1件分までは超えてよいものとします
+		if max := limit*report.ActualDurationSec + 1; float64(received[path]) > max {
+			t.Errorf("%s: %d 件届きました: 上限 %v RPS を超えています (最大 %.0f 件)", path, received[path], limit, max)
+		}
+		if target.Requests == 0 || uint64(received[path]) < target.Requests {
+			t.Errorf("%s: requests=%d, サーバーに届いた数 %d: 完了した数が記録されていません", path, target.Requests, received[path])
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション15] オープンモデル: 応答を待たずに一定の到着レートでリクエストを発生させる負荷生成
+// ==============================================================================
+
+// 通常のワーカー方式（クローズドモデル）では、各ワーカーが応答を受け取ってから次を送信するため、
+// ターゲットが遅くなると送信ペースも自動的に落ちてしまい、「遅くなったときに何が起きるか」を観測できません。
+// オープンモデルでは、1つのディスパッチャーが rate_limit の到着レートでリクエストを発生させ続け、
+// 各リクエストを個別のGoroutineで送信します。
+//
+// ただし、ターゲットが応答しなくなると通信中のリクエストが際限なく増え、ファイルディスクリプタやメモリを
+// 使い果たしてしまいます。そこで、送信前にセマフォ（max_in_flight）を獲得し、上限に達している場合は
+//   - "drop"  : そのリクエストを送信せず skipped_overload として数える（到着レートは維持されます）
+//   - "block" : 空きが出るまでディスパッチャーが待機する（バックプレッシャー。到着レートは低下します）
+// のいずれかの動作をとります。上限に達した回数（in_flight_cap_hits）は、ターゲットが過負荷に陥ったことを示す指標です。
+
+// 負荷モデル（TestConfig.LoadModel）
+const (
+	loadModelClosed = "closed"
+	loadModelOpen   = "open"
+)
+
+// 通信中のリクエスト数が上限に達した場合の動作（TestConfig.OverloadPolicy）
+const (
+	overloadDrop  = "drop"
+	overloadBlock = "block"
+)
+
+// executeOpenDispatcher は、オープンモデルのディスパッチャーとして、テスト終了まで一定の到着レートでリクエストを発生させます。
+// 送信したリクエストのGoroutineも wg で管理するため、テスト終了時には通信中のリクエストの中断まで待機できます。
+// ceiling が nil でない場合は、到着レートが安全上限（max_rps）を超えないよう発生前に待機します。
+func executeOpenDispatcher(ctx context.Context, wg *sync.WaitGroup, client *http.Client, cfg *TestConfig, metrics *ResultMetrics, ceiling *rpsCeiling, rng *rand.Rand) {
+	defer wg.Done()
+
+	baseReq, err := newBaseRequest(context.Background(), cfg.Method, cfg.TargetURL, cfg)
+	if err != nil {
+		metrics.errLog.printf("[Open Model Error]", "リクエストの初期化に失敗しました: %v", err)
+		return
+	}
+	traceCtx := newTraceContext(ctx, metrics)
+	cb := newCacheBuster(cfg.CacheBust, 0)
+
+	// セマフォの容量が、同時に通信中にできるリクエスト数の上限になります
+	sem := make(chan struct{}, cfg.MaxInFlight)
+	pc := newPacer(cfg.RateLimit, cfg.RateJitter, rng)
+	if metrics.shape != nil {
+		pc = newShapedPacer(metrics.shape, 1, cfg.RateJitter, rng)
+	}
+
+	log.Printf("[Open Model] 到着レート: %.2f リクエスト/秒, 通信中の上限: %d, 上限到達時の動作: %s\n",
+		cfg.RateLimit, cfg.MaxInFlight, cfg.OverloadPolicy)
+
+	for waitPaused(ctx, cfg.pause, pc) && pc.Wait(ctx) && ceiling.Wait(ctx) {
+		if !acquireInFlight(ctx, sem, cfg, metrics) {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		// ディスパッチャー自身が wg のカウントを保持しているため、ここでの Add が Wait と競合することはありません
+		// 送信する Goroutine は並行に動くため、注入する遅延はディスパッチャーの乱数生成器で先に決めておきます
+		wg.Add(1)
+		go func(injectDelay time.Duration) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			sendRequest(ctx, traceCtx, client, baseReq, cb, injectDelay, cfg, metrics, false)
+		}(cfg.inject.delay(rng))
+	}
+}
+
+// acquireInFlight は、送信前にセマフォ sem を獲得します。上限に達している場合は overload_policy に従い、
+// "drop" ではそのリクエストを skipped_overload として数えて false を、"block" では空きが出るまで待機して true を返します
+// （待機中にテストが終了した場合は false を返します）。
+func acquireInFlight(ctx context.Context, sem chan struct{}, cfg *TestConfig, metrics *ResultMetrics) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+	}
+	atomic.AddUint64(&metrics.InFlightCapHits, 1)
+	if cfg.OverloadPolicy != overloadBlock {
+		atomic.AddUint64(&metrics.SkippedOverload, 1)
+		return false
+	}
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestOpenModelInFlightCap は、オープンモデルで応答の遅いターゲットへ送信すると、通信中のリクエスト数が max_in_flight で
+// 頭打ちになり、上限に達した回数が in_flight_cap_hits に、"drop" で送信しなかった数が skipped_overload に記録されることを確認します。
+func TestOpenModelInFlightCap(t *testing.T) {
+	const maxInFlight = 5
+	for _, policy := range []string{overloadDrop, overloadBlock} {
+		t.Run(policy, func(t *testing.T) {
+			// 到着レート 100/秒 に対し、200ms の応答では同時に 20 件が通信中になるはずのところを 5 件に抑えます
+			server := newInflightServer(t, 200*time.Millisecond)
+			report := runTestLoad(newTestConfig(t, map[string]any{
+				"target_url":      server.URL,
+				"load_model":      loadModelOpen,
+				"rate_limit":      100,
+				"max_in_flight":   maxInFlight,
+				"overload_policy": policy,
+				"duration":        "1s",
+				"no_preflight":    true,
+			}))
+
+			if peak := server.peak.Load(); peak > maxInFlight {
+				t.Errorf("サーバーでの同時処理数の最大 = %d, want <= %d", peak, maxInFlight)
+			}
+			if report.InFlightCapHits == 0 {
+				t.Errorf("in_flight_cap_hits = 0: 上限に達したことが記録されていません")
+			}
+			switch policy {
+			case overloadDrop:
+				// 上限に達した発生はすべて送信せずに数えます
+				if report.SkippedOverload == 0 || report.SkippedOverload != report.InFlightCapHits {
+					t.Errorf("skipped_overload=%d in_flight_cap_hits=%d: 上限に達した発生をすべて skipped_overload として数えるはずです",
+						report.SkippedOverload, report.InFlightCapHits)
+				}
+			case overloadBlock:
+				if report.SkippedOverload != 0 {
+					t.Errorf("skipped_overload = %d: block では空きを待って送信するはずです", report.SkippedOverload)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// [セクション43] 出力フォーマット: hey / wrk 互換のテキストレポート (-o / ?format=)
+// ==============================================================================
+
+// hey や wrk から移行するチームには、それらの標準出力を解析する既存のスクリプトやダッシュボードがあります。
+// -o hey / -o wrk を指定すると、-merge・-import・-selftest が標準出力へ書き出すレポートを、
+// /api/run?format=hey（または wrk）を指定すると API のレスポンスを、それぞれのツールの出力とほぼ同じレイアウトの
+// テキストで返します。統計値の算出方法は変えず、算出済みのレポートを整形し直すだけです。
+//
+// 【hey との対応】
+//   Total / Slowest / Fastest / Average / Requests/sec  ← actual_duration_sec / max / min / mean / throughput_rps
+//   Latency distribution の 50% / 90% / 99%              ← p50 / p90 / p99（10%・25%・75%・95% は算出していないため出力しません）
+//   Status code distribution                             ← status_codes（ネットワークエラーを除く）
+//   Error distribution                                   ← error_kinds（エラー種別ごとの件数。hey はエラーメッセージごと）
+//   Response time histogram と Details はレポートに元データがないため、Total data はボディのサイズを計測していないため出力しません。
+//
+// 【wrk との対応】（wrk --latency の出力形式）
+//   Running ... test @ <URL>                ← actual_duration_sec と送信先（-merge・-import では不明のため "-"）
+//   threads and connections                 ← 1 スレッド、peak_inflight（同時に通信中だったリクエスト数の最大値）
+//   Latency の Avg / Stdev / Max            ← mean / stddev / max（+/- Stdev はサンプルがないため N/A）
+//   Req/Sec の Avg / Stdev / Max / +/- Stdev ← rps_timeline（1秒ごとの完了数）から算出
+//   Latency Distribution の 50% / 90% / 99% ← p50 / p90 / p99（75% は算出していないため出力しません）
+//   Socket errors                           ← connect: dial_errors、timeout: timed_out、read: その他のネットワークエラー
+//   Non-2xx or 3xx responses                ← 4xx・5xx の件数
+//   read / Transfer/sec                     ← 受信バイト数は計測していないため 0B
+
+// レポートの出力フォーマット（-o フラグ、/api/run の format パラメーター）
+const (
+	outputFormatJSON = "json"
+	outputFormatHey  = "hey"
+	outputFormatWrk  = "wrk"
+)
+
+// outputFormat は、-merge・-import・-selftest が標準出力へ書き出すレポートのフォーマットです（-o フラグ）。
+var outputFormat = outputFormatJSON
+
+// validOutputFormat は、format が対応している出力フォーマットかどうかを返します。
+func validOutputFormat(format string) bool {
+	switch format {
+	case outputFormatJSON, outputFormatHey, outputFormatWrk:
+		return true
+	}
+	return false
+}
+
+// writeReport は、report を format のフォーマットで w へ書き出します。
+// target は wrk 形式の見出しに表示する送信先です（不明な場合は空文字列）。
+func writeReport(w io.Writer, report *TestReport, format, target string) error {
+	switch format {
+	case outputFormatHey:
+		_, err := io.WriteString(w, formatHeyReport(report))
+		return err
+	case outputFormatWrk:
+		_, err := io.WriteString(w, formatWrkReport(report, target))
+		return err
+	default:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+}
+
+// latencyValues は、hey・wrk 形式で出力するレイテンシの要約統計を返します。
+// 計測・統合・再生成したレポートでは文字列に丸める前の値を使い、hey が小数4桁で出力する値も丸めずに表記します。
+// 丸める前の値を持たないレポート（JSON から読み込んだだけのもの）の場合に限り、レポートの文字列から戻します（どちらもない場合は nil）。
+func (r *TestReport) latencyValues() *summaryDurations {
+	if r.latency != nil {
+		return r.latency
+	}
+	values, err := parseSummaryDurations(LatencySummary{
+		Min: r.MinLatency, Mean: r.MeanLatency, StdDev: r.StdDevLatency,
+		P50: r.P50Latency, P90: r.P90Latency, P99: r.P99Latency, Max: r.MaxLatency,
+	})
+	if err != nil {
+		return nil
+	}
+	return &values
+}
+
+// outputPercentile は、hey・wrk の Latency distribution の1行（パーセンタイルとその値）です。
+type outputPercentile struct {
+	pct   int
+	value time.Duration
+}
+
+// outputPercentiles は、Latency distribution に出力するパーセンタイルを返します（values が nil の場合は空）。
+func outputPercentiles(values *summaryDurations) []outputPercentile {
+	if values == nil {
+		return nil
+	}
+	return []outputPercentile{{50, values.p50}, {90, values.p90}, {99, values.p99}}
+}
+
+// formatHeyReport は、report を hey の標準出力と同じレイアウトのテキストにします。
+func formatHeyReport(report *TestReport) string {
+	var b strings.Builder
+	values := report.latencyValues()
+	var slowest, fastest, average time.Duration
+	if values != nil {
+		slowest, fastest, average = values.max, values.min, values.mean
+	}
+
+	b.WriteString("\nSummary:\n")
+	fmt.Fprintf(&b, "  Total:\t%4.4f secs\n", report.ActualDurationSec)
+	fmt.Fprintf(&b, "  Slowest:\t%4.4f secs\n", slowest.Seconds())
+	fmt.Fprintf(&b, "  Fastest:\t%4.4f secs\n", fastest.Seconds())
+	fmt.Fprintf(&b, "  Average:\t%4.4f secs\n", average.Seconds())
+	fmt.Fprintf(&b, "  Requests/sec:\t%4.4f\n", report.ThroughputRPS)
+
+	// hey は Total data を出力しない場合も、その行の字下げだけを出力します
+	b.WriteString("  \n\nLatency distribution:")
+	for _, p := range outputPercentiles(values) {
+		fmt.Fprintf(&b, "\n  %d%% in %4.4f secs", p.pct, p.value.Seconds())
+	}
+
+	// hey と異なり、コードの昇順で出力します（hey はマップの順序のため不定です）
+	b.WriteString("\n\nStatus code distribution:")
+	codes := make([]string, 0, len(report.StatusCodes))
+	for code := range report.StatusCodes {
+		if code != "NetworkError" {
+			codes = append(codes, code)
+		}
+	}
+	slices.Sort(codes)
+	for _, code := range codes {
+		fmt.Fprintf(&b, "\n  [%s]\t%d responses", code, report.StatusCodes[code])
+	}
+	b.WriteString("\n\n")
+
+	if len(report.ErrorKinds) > 0 {
+		b.WriteString("Error distribution:")
+		kinds := make([]string, 0, len(report.ErrorKinds))
+		for kind := range report.ErrorKinds {
+			kinds = append(kinds, kind)
+		}
+		slices.Sort(kinds)
+		for _, kind := range kinds {
+			fmt.Fprintf(&b, "\n  [%d]\t%s", report.ErrorKinds[kind], kind)
+		}
+	}
+	// hey はテンプレートの末尾の改行に加えて、もう1つ改行を出力します
+	b.WriteString("\n\n")
+	return b.String()
+}
+
+// formatWrkReport は、report を wrk --latency の標準出力と同じレイアウトのテキストにします。
+func formatWrkReport(report *TestReport, target string) string {
+	var b strings.Builder
+	if target == "" {
+		target = "-"
+	}
+	values := report.latencyValues()
+	avg, stdev, maxLatency := "N/A", "N/A", "N/A"
+	if values != nil {
+		avg, maxLatency = wrkLatency(values.mean), wrkLatency(values.max)
+		if values.hasStdDev {
+			stdev = wrkLatency(values.stdDev)
+		}
+	}
+
+	// wrk は見出しの実行時間だけを小数なしで表記します（"Running 30s test"）
+	fmt.Fprintf(&b, "Running %.0fs test @ %s\n", report.ActualDurationSec, target)
+	fmt.Fprintf(&b, "  %d threads and %d connections\n", 1, report.PeakInFlight)
+
+	fmt.Fprintf(&b, "  Thread Stats%6s%11s%8s%12s\n", "Avg", "Stdev", "Max", "+/- Stdev")
+	b.WriteString("    Latency   ")
+	b.WriteString(wrkUnits(avg, 8))
+	b.WriteString(wrkUnits(stdev, 10))
+	b.WriteString(wrkUnits(maxLatency, 9))
+	fmt.Fprintf(&b, "%9s\n", "N/A")
+
+	mean, stdevRPS, maxRPS, within := timelineStats(report.RPSTimeline)
+	b.WriteString("    Req/Sec   ")
+	b.WriteString(wrkUnits(wrkMetric(mean), 8))
+	b.WriteString(wrkUnits(wrkMetric(stdevRPS), 10))
+	b.WriteString(wrkUnits(wrkMetric(maxRPS), 9))
+	fmt.Fprintf(&b, "%8.2f%%\n", within)
+
+	b.WriteString("  Latency Distribution\n")
+	for _, p := range outputPercentiles(values) {
+		fmt.Fprintf(&b, "%7d%%", p.pct)
+		b.WriteString(wrkUnits(wrkLatency(p.value), 10))
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "  %d requests in %s, %sB read\n", report.TotalRequests, wrkTimeSeconds(report.ActualDurationSec), wrkBinary(0))
+	connectErrors := report.DialErrors
+	readErrors := report.StatusCodes["NetworkError"] - min(report.StatusCodes["NetworkError"], connectErrors+report.TimedOut)
+	if connectErrors+readErrors+report.TimedOut > 0 {
+		fmt.Fprintf(&b, "  Socket errors: connect %d, read %d, write %d, timeout %d\n", connectErrors, readErrors, 0, report.TimedOut)
+	}
+	var non2xx3xx uint64
+	for code, count := range report.StatusCodes {
+		if n, err := strconv.Atoi(code); err == nil && n > 399 {
+			non2xx3xx += count
+		}
+	}
+	if non2xx3xx > 0 {
+		fmt.Fprintf(&b, "  Non-2xx or 3xx responses: %d\n", non2xx3xx)
+	}
+	fmt.Fprintf(&b, "Requests/sec: %9.2f\n", report.ThroughputRPS)
+	fmt.Fprintf(&b, "Transfer/sec: %10sB\n", wrkBinary(0))
+	return b.String()
+}
+
+// timelineStats は、1秒ごとの完了数から wrk の Req/Sec 行の値（平均、標準偏差、最大、平均±標準偏差に収まった割合）を求めます。
+func timelineStats(timeline []uint64) (mean, stdev, maxValue, within float64) {
+	if len(timeline) == 0 {
+		return 0, 0, 0, 0
+	}
+	for _, v := range timeline {
+		mean += float64(v)
+		maxValue = max(maxValue, float64(v))
+	}
+	mean /= float64(len(timeline))
+	for _, v := range timeline {
+		stdev += (float64(v) - mean) * (float64(v) - mean)
+	}
+	if len(timeline) > 1 {
+		stdev = math.Sqrt(stdev / float64(len(timeline)-1))
+	} else {
+		stdev = 0
+	}
+	var n int
+	for _, v := range timeline {
+		if math.Abs(float64(v)-mean) <= stdev {
+			n++
+		}
+	}
+	return mean, stdev, maxValue, float64(n) / float64(len(timeline)) * 100
+}
+
+// wrkUnits は、wrk の print_units と同じく、単位の文字数に応じた余白を付けて右寄せにします。
+func wrkUnits(msg string, width int) string {
+	pad := 2
+	if n := len(msg); n > 0 && isLetter(msg[n-1]) {
+		pad--
+		if n > 1 && isLetter(msg[n-2]) {
+			pad--
+		}
+	}
+	return fmt.Sprintf("%*s%s", width-pad, msg, strings.Repeat(" ", pad))
+}
+
+// isLetter は、c がASCIIの英字かどうかを返します。
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// wrkLatency は、レイテンシを wrk の format_time の表記（"635.91us"、"12.92ms"）にします。
+func wrkLatency(d time.Duration) string {
+	return wrkScale(float64(d)/float64(time.Microsecond), []string{"us", "ms", "s"}, 1000)
+}
+
+// wrkTimeSeconds は、秒数を wrk の format_time_s の表記（"30.00s"、"1.50m"）にします。
+func wrkTimeSeconds(secs float64) string {
+	return wrkScale(secs, []string{"s", "m", "h"}, 60)
+}
+
+// wrkMetric は、件数を wrk の format_metric の表記（"56.20k"）にします。
+func wrkMetric(n float64) string {
+	return wrkScale(n, []string{"", "k", "M", "G"}, 1000)
+}
+
+// wrkBinary は、バイト数を wrk の format_binary の表記（"17.76G"。呼び出し側で "B" を付けます）にします。
+func wrkBinary(n float64) string {
+	return wrkScale(n, []string{"", "K", "M", "G", "T"}, 1024)
+}
+
+// wrkScale は、n を base ごとに次の単位へ繰り上げ、小数2桁で表記します。
+// wrk の format_units と同じく、base の 85% に達した時点で繰り上げます（890us は "0.89ms" と表記します）。
+func wrkScale(n float64, units []string, base float64) string {
+	i := 0
+	for i < len(units)-1 && n >= base*0.85 {
+		n /= base
+		i++
+	}
+	return fmt.Sprintf("%.2f%s", n, units[i])
+}
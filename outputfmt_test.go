@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// heyReferenceLatencies は、testdata/hey_reference.txt を出力した hey のレポートに与えたレイテンシ（100件）です。
+// 最速は 123.456µs、最遅は 1.234567s で、その間は 10ms 刻みに 1.234567ms を足した値です。
+func heyReferenceLatencies() []time.Duration {
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration(i)*10*time.Millisecond + 1234567*time.Nanosecond
+	}
+	latencies[0] = 123456 * time.Nanosecond
+	latencies[99] = 1234567 * time.Microsecond
+	return latencies
+}
+
+// TestHeyReferenceOutput は、hey v0.1.4 のレポート出力（同じレイテンシ・ステータスコード・エラーを与えて hey の report で
+// 出力したもの）と、同じ統計値のレポートを -o hey で出力したものが一致することを確認します。
+// ultraload が出力しない Response time histogram・Details の節と、算出していない 10%・25%・75%・95% の行は
+// 比較の前に hey の出力から取り除きます（outputfmt.go の対応表を参照）。
+func TestHeyReferenceOutput(t *testing.T) {
+	raw, err := os.ReadFile("testdata/hey_reference.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := string(raw)
+	for _, section := range []struct{ from, to string }{
+		{"Response time histogram:", "Latency distribution:"},
+		{"Details (average, fastest, slowest):", "Status code distribution:"},
+	} {
+		i, j := strings.Index(want, section.from), strings.Index(want, section.to)
+		if i < 0 || j < i {
+			t.Fatalf("hey の出力に %q の節がありません", section.from)
+		}
+		want = want[:i] + want[j:]
+	}
+	want = regexp.MustCompile(`(?m)^  (10|25|75|95)% in .*\n`).ReplaceAllString(want, "")
+
+	report := &TestReport{
+		ActualDurationSec: 2.5,
+		ThroughputRPS:     40.4,
+		StatusCodes:       map[string]uint64{"200": 98, "500": 2, "NetworkError": 1},
+		ErrorKinds:        map[string]uint64{"dial_error": 1},
+		latency:           latencyDurations(heyReferenceLatencies()),
+	}
+	if got := formatHeyReport(report); got != want {
+		t.Errorf("hey 形式の出力が hey と一致しません\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestHeyReportKeepsPrecision は、hey 形式の各値がレポートの文字列（小数2桁）ではなく丸める前の値から小数4桁で出力されることを確認します。
+func TestHeyReportKeepsPrecision(t *testing.T) {
+	report := &TestReport{latency: latencyDurations([]time.Duration{1234567 * time.Microsecond})}
+	report.MaxLatency = formatDuration(report.latency.max) // "1.23s"
+	got := formatHeyReport(report)
+	for _, line := range []string{"  Slowest:\t1.2346 secs\n", "  99% in 1.2346 secs"} {
+		if !strings.Contains(got, line) {
+			t.Errorf("出力に %q がありません:\n%s", line, got)
+		}
+	}
+
+	// 丸める前の値を持たないレポート（JSON から読み込んだもの）は、文字列から戻した値を出力します
+	loaded := &TestReport{MinLatency: "1.23s", MeanLatency: "1.23s", P50Latency: "1.23s", P90Latency: "1.23s", P99Latency: "1.23s", MaxLatency: "1.23s"}
+	if got := formatHeyReport(loaded); !strings.Contains(got, "  Slowest:\t1.2300 secs\n") {
+		t.Errorf("文字列から戻した値が出力されません:\n%s", got)
+	}
+	empty := &TestReport{MinLatency: "N/A", MeanLatency: "N/A", P50Latency: "N/A", P90Latency: "N/A", P99Latency: "N/A", MaxLatency: "N/A"}
+	if got := formatHeyReport(empty); strings.Contains(got, "% in") {
+		t.Errorf("応答のないレポートにパーセンタイルが出力されました:\n%s", got)
+	}
+}
+
+// TestWrkReferenceOutput は、wrk の README に掲載されている実行結果と同じ統計値のレポートを -o wrk で出力し、
+// 各行が wrk と一致することを確認します。ultraload が計測していない値（スレッド数、Latency の +/- Stdev、受信バイト数）は、
+// 比較の前に wrk の出力の該当箇所を ultraload の表記に置き換えます。
+func TestWrkReferenceOutput(t *testing.T) {
+	raw, err := os.ReadFile("testdata/wrk_reference.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.NewReplacer(
+		"12 threads", "1 threads",
+		"   93.69%", "      N/A",
+		"17.76GB read", "0.00B read",
+		"   606.33MB", "      0.00B",
+	).Replace(string(raw))
+
+	// Req/Sec の平均 56.20k・標準偏差 8.07k・最大 62.00k・平均±標準偏差に収まる割合 86.54%（45/52秒）になる1秒ごとの完了数です
+	var timeline []uint64
+	for range 20 {
+		timeline = append(timeline, 62000)
+	}
+	for range 25 {
+		timeline = append(timeline, 56992)
+	}
+	for range 7 {
+		timeline = append(timeline, 36800)
+	}
+	report := &TestReport{
+		TotalRequests:     22464657,
+		ActualDurationSec: 30,
+		ThroughputRPS:     748868.53,
+		PeakInFlight:      400,
+		RPSTimeline:       timeline,
+		latency: &summaryDurations{
+			mean: 635910 * time.Nanosecond, stdDev: 890 * time.Microsecond, hasStdDev: true, max: 12920 * time.Microsecond,
+			p50: 250 * time.Microsecond, p90: 700 * time.Microsecond, p99: 5800 * time.Microsecond,
+		},
+	}
+	got := formatWrkReport(report, "http://127.0.0.1:8080/index.html")
+
+	// wrk の README の例は --latency なしの実行のため、Latency Distribution の節を除いて比較します
+	distribution := "  Latency Distribution\n     50%  250.00us\n     90%  700.00us\n     99%    5.80ms\n"
+	if !strings.Contains(got, distribution) {
+		t.Errorf("Latency Distribution の節が wrk --latency の表記と一致しません:\n%s", got)
+	}
+	if got = strings.Replace(got, distribution, "", 1); got != want {
+		t.Errorf("wrk 形式の出力が wrk と一致しません\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestWrkScale は、wrk の format_units と同じく単位の 85% に達した時点で次の単位へ繰り上げることを確認します。
+func TestWrkScale(t *testing.T) {
+	tests := []struct {
+		got, want string
+	}{
+		{wrkLatency(849 * time.Microsecond), "849.00us"},
+		{wrkLatency(850 * time.Microsecond), "0.85ms"},
+		{wrkLatency(1500 * time.Millisecond), "1.50s"},
+		{wrkMetric(62000), "62.00k"},
+		{wrkBinary(17.76 * 1024 * 1024 * 1024), "17.76G"},
+		{wrkTimeSeconds(30), "30.00s"},
+		{wrkTimeSeconds(90), "1.50m"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
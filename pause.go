@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション71] 一時停止と再開: 接続と集計を保ったまま送信だけを止める (/api/pause・/api/resume)
+// ==============================================================================
+
+// Web UI で設定を変えながら試すときに、ターゲット側のログを確認したり、別の操作の影響を見たりするためだけに
+// テストを停止すると、確立済みの接続とそれまでの計測値を失ってしまいます。ジョブ（/api/start）として実行中のテストは、
+//   POST /api/pause/{id}   … 各ワーカーが次のリクエストを送信する前に待機させる
+//   POST /api/resume/{id}  … 待機中のワーカーに送信を再開させる
+// で一時停止・再開できます。各ワーカーは送信のたびに共有のフラグ（atomic.Bool）を確認するだけなので、
+// 一時停止していない間の負荷はほとんど増えません。送信済みのリクエストは応答まで待ち、接続はプールに残したままです。
+//
+// 一時停止していた時間は、テストの実行時間（duration）に含まれます（停止中もテストの終了時刻は延びません）。
+// レポートの throughput_rps・avg_inflight は、実行時間から一時停止していた時間（paused_sec）を除いた時間で算出します。
+// rate_limit・traffic_shape の送信スロットは、停止中の分を取り戻そうと再開直後に一斉に送信しないよう、停止していた分だけ先へずらします。
+// 停止中は、スループットの下限（min_rps_floor）の判定と、適応型負荷モードの並行数の調整も行いません。
+// 1秒ごとのタイムラインには、停止中の秒も0件として記録します。
+//
+// 記録された時刻どおりに送信するトレースの再生（replay_trace）は、一時停止できません。
+
+// pauseState は、1つのテストの一時停止の状態です。nil の pauseState は一時停止しません（/api/run で実行したテストなど）。
+type pauseState struct {
+	paused atomic.Bool // ワーカーが送信のたびに確認するフラグ
+
+	mu      sync.Mutex
+	resumed chan struct{} // 一時停止中に待機するチャネル（再開時にクローズします）
+	since   time.Time     // 一時停止した時刻
+	total   time.Duration // 過去の一時停止の合計（現在の一時停止は含みません）
+	count   int           // 一時停止した回数
+}
+
+// newPauseState は、一時停止していない pauseState を生成します。
+func newPauseState() *pauseState {
+	return &pauseState{}
+}
+
+// pause は、送信を一時停止します。すでに一時停止している場合は false を返します。
+func (p *pauseState) pause() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused.Load() {
+		return false
+	}
+	p.resumed = make(chan struct{})
+	p.since = time.Now()
+	p.count++
+	p.paused.Store(true)
+	return true
+}
+
+// resume は、送信を再開します。一時停止していない場合は false を返します。
+func (p *pauseState) resume() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused.Load() {
+		return false
+	}
+	p.total += time.Since(p.since)
+	p.paused.Store(false)
+	close(p.resumed)
+	return true
+}
+
+// isPaused は、一時停止中かどうかを返します。
+func (p *pauseState) isPaused() bool {
+	return p != nil && p.paused.Load()
+}
+
+// wait は、一時停止中であれば再開されるまで待機し、一時停止した時刻を返します（一時停止していなかった場合はゼロ値）。
+// 待機中に ctx がキャンセルされた場合は ok に false を返します（一時停止していない場合は、ctx を確認せずに true を返します）。
+func (p *pauseState) wait(ctx context.Context) (pausedAt time.Time, ok bool) {
+	if !p.isPaused() {
+		return time.Time{}, true
+	}
+	// ロックを取得するまでの間に再開された場合も、resumed はクローズ済みのためすぐに戻ります
+	p.mu.Lock()
+	resumed, since := p.resumed, p.since
+	p.mu.Unlock()
+	select {
+	case <-resumed:
+		return since, true
+	case <-ctx.Done():
+		return since, false
+	}
+}
+
+// pausedTotal は、一時停止していた時間の合計と回数を返します（現在一時停止中の場合は、その経過時間も含みます）。
+func (p *pauseState) pausedTotal() (time.Duration, int) {
+	if p == nil {
+		return 0, 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	total := p.total
+	if p.paused.Load() {
+		total += time.Since(p.since)
+	}
+	return total, p.count
+}
+
+// waitPaused は、ワーカーの送信ループの先頭で一時停止中であれば再開まで待機し、停止していた分だけ pc の送信スロットをずらします。
+// 待機中にテストが終了した場合は false を返します。一時停止していない場合は、フラグを1回読むだけです。
+func waitPaused(ctx context.Context, p *pauseState, pc *pacer) bool {
+	pausedAt, ok := p.wait(ctx)
+	if ok && !pausedAt.IsZero() {
+		pc.skip(pausedAt, time.Now())
+	}
+	return ok
+}
+
+// skip は、from から to までの間の送信スロットを飛ばします（一時停止からの再開時に呼び出します）。nil の pacer は何もしません。
+func (p *pacer) skip(from, to time.Time) {
+	if p == nil || !to.After(from) {
+		return
+	}
+	if p.shape != nil {
+		p.slot += p.share * (p.shape.cumulative(to.Sub(p.shape.start)) - p.shape.cumulative(from.Sub(p.shape.start)))
+		return
+	}
+	p.next = p.next.Add(to.Sub(from))
+}
+
+// handleJobPause は、実行中のジョブの送信を一時停止するエンドポイントです。
+func handleJobPause(w http.ResponseWriter, r *http.Request) {
+	handlePauseToggle(w, r, true)
+}
+
+// handleJobResume は、一時停止中のジョブの送信を再開するエンドポイントです。
+func handleJobResume(w http.ResponseWriter, r *http.Request) {
+	handlePauseToggle(w, r, false)
+}
+
+// handlePauseToggle は、/api/pause/{id}（pause が true）と /api/resume/{id} の共通処理です。
+func handlePauseToggle(w http.ResponseWriter, r *http.Request, pause bool) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error_msg": "POSTメソッドのみ許可されています"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	job, ok := jobs.get(id)
+	if !ok {
+		writeJobStatus(w, http.StatusNotFound, JobStatus{JobID: id, ErrorMsg: jobNotFoundMsg})
+		return
+	}
+	st := job.status()
+	switch {
+	case st.Status != jobRunning:
+		st.ErrorMsg = "ジョブはすでに終了しています"
+		writeJobStatus(w, http.StatusConflict, st)
+		return
+	case job.cfg.pause == nil:
+		st.ErrorMsg = "このジョブは一時停止できません（replay_trace の再生は記録された時刻どおりに送信するためです）"
+		writeJobStatus(w, http.StatusConflict, st)
+		return
+	}
+
+	if pause {
+		if job.cfg.pause.pause() {
+			log.Printf("[API] ジョブ %s の送信を一時停止しました", job.id)
+		}
+	} else if job.cfg.pause.resume() {
+		log.Printf("[API] ジョブ %s の送信を再開しました", job.id)
+	}
+	writeJobStatus(w, http.StatusOK, job.status())
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// togglePause は、mux 経由で /api/pause/{id}（pause が true）または /api/resume/{id} を呼び出し、ジョブの状態を返します。
+func togglePause(t *testing.T, job *loadJob, pause bool) JobStatus {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/pause/{id}", handleJobPause)
+	mux.HandleFunc("/api/resume/{id}", handleJobResume)
+	path := "/api/resume/"
+	if pause {
+		path = "/api/pause/"
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path+job.id, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: status = %d, want 200: %s", path, rec.Code, rec.Body.String())
+	}
+	var st JobStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatalf("レスポンスを解析できません: %v", err)
+	}
+	return st
+}
+
+// TestJobPauseResume は、一時停止中はターゲットに届くリクエストも記録されるリクエストも増えず、
+// 再開後に再び増え、一時停止していた時間がレポートの paused_sec に記録されることを確認します。
+func TestJobPauseResume(t *testing.T) {
+	var received atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	job := startForeverJob(t, server)
+
+	if st := togglePause(t, job, true); !st.Paused {
+		t.Fatal("一時停止後のジョブの状態が paused になっていません")
+	}
+	// 一時停止の時点で送信済みだったリクエストは応答まで待つため、記録される数が止まるまで待ちます
+	if !waitFor(5*time.Second, func() bool {
+		before := job.metrics.TotalRequests.Load()
+		time.Sleep(50 * time.Millisecond)
+		return job.metrics.TotalRequests.Load() == before && atomic.LoadInt64(&job.metrics.InFlight) == 0
+	}) {
+		t.Fatal("一時停止してもリクエストの記録が止まりません")
+	}
+
+	pausedTotal, pausedReceived := job.metrics.TotalRequests.Load(), received.Load()
+	time.Sleep(300 * time.Millisecond)
+	if got := job.metrics.TotalRequests.Load(); got != pausedTotal {
+		t.Errorf("一時停止中に記録されたリクエストが増えました: %d -> %d", pausedTotal, got)
+	}
+	if got := received.Load(); got != pausedReceived {
+		t.Errorf("一時停止中にターゲットへリクエストが届きました: %d -> %d", pausedReceived, got)
+	}
+
+	if st := togglePause(t, job, false); st.Paused {
+		t.Fatal("再開後のジョブの状態が paused のままです")
+	}
+	if !waitFor(5*time.Second, func() bool {
+		return job.metrics.TotalRequests.Load() > pausedTotal && received.Load() > pausedReceived
+	}) {
+		t.Fatal("再開してもリクエストが増えません")
+	}
+
+	job.cancel()
+	<-job.done
+	if job.report == nil {
+		t.Fatal("停止したジョブのレポートがありません")
+	}
+	if job.report.Pauses != 1 || job.report.PausedSec < 0.3 {
+		t.Errorf("pauses=%d paused_sec=%.3f: 一時停止の回数と時間が記録されていません", job.report.Pauses, job.report.PausedSec)
+	}
+}
+
+// TestPauseState は、pauseState の一時停止・再開の戻り値と、待機中のワーカーが再開またはキャンセルで戻ることを確認します。
+func TestPauseState(t *testing.T) {
+	var nilState *pauseState
+	if nilState.isPaused() {
+		t.Error("nil の pauseState が一時停止中になっています")
+	}
+	if _, ok := nilState.wait(context.Background()); !ok {
+		t.Error("nil の pauseState の wait が false を返しました")
+	}
+
+	p := newPauseState()
+	if p.resume() {
+		t.Error("一時停止していない状態での resume が true を返しました")
+	}
+	if !p.pause() || p.pause() {
+		t.Fatal("pause は1回目だけ true を返すはずです")
+	}
+
+	waited := make(chan bool, 1)
+	go func() {
+		_, ok := p.wait(context.Background())
+		waited <- ok
+	}()
+	select {
+	case <-waited:
+		t.Fatal("一時停止中の wait が再開前に戻りました")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if !p.resume() {
+		t.Fatal("一時停止中の resume が false を返しました")
+	}
+	select {
+	case ok := <-waited:
+		if !ok {
+			t.Error("再開された wait が false を返しました")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("再開しても wait が戻りません")
+	}
+
+	p.pause()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if pausedAt, ok := p.wait(ctx); ok || pausedAt.IsZero() {
+		t.Errorf("ok=%v pausedAt=%v: キャンセル済みの ctx では一時停止した時刻と false を返すはずです", ok, pausedAt)
+	}
+	p.resume()
+	if total, count := p.pausedTotal(); count != 2 || total < 50*time.Millisecond {
+		t.Errorf("total=%v count=%d: 一時停止の合計と回数が正しくありません", total, count)
+	}
+}
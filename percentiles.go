@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// [セクション39] 裾のパーセンタイル (p99.9 / p99.99) とサンプル数の十分性の警告
+// ==============================================================================
+
+// p99 だけでは、1000件や1万件に1件の割合で発生する極端な遅延（GCの停止、再送タイムアウトなど）は見えません。
+// p50/p90/p99 に加えて、percentiles に指定したパーセンタイル（未指定時は 99.9 と 99.99）を算出し、
+// レポートの tail_percentiles に "p99.9": "12.34ms" の形式で記録します。
+//
+// ただし、裾のパーセンタイルが意味を持つには十分なサンプル数が必要です。p99.99 は1万件に1件の値のため、
+// 1万件未満のサンプルでは実質的に最大値と同じになり、統計的に信頼できません。
+// サンプル数が 1 / (1 - q) 件に満たないパーセンタイルも算出はしますが、percentile_warnings に警告を記録します。
+
+// defaultTailPercentiles は、percentiles が未指定の場合に算出する裾のパーセンタイルです。
+var defaultTailPercentiles = []float64{99.9, 99.99}
+
+// maxPercentiles は、percentiles に指定できるパーセンタイルの数の上限です。
+const maxPercentiles = 10
+
+// validatePercentiles は、percentiles の値を検証し、未指定の場合は既定値を返します。
+func validatePercentiles(percentiles []float64) ([]float64, error) {
+	if len(percentiles) == 0 {
+		return defaultTailPercentiles, nil
+	}
+	if len(percentiles) > maxPercentiles {
+		return nil, fmt.Errorf("percentiles は %d 個まで指定できます", maxPercentiles)
+	}
+	for _, q := range percentiles {
+		if q <= 0 || q >= 100 {
+			return nil, fmt.Errorf("percentiles の値は 0 より大きく 100 未満で指定してください: %v", q)
+		}
+	}
+	return percentiles, nil
+}
+
+// percentileLabel は、パーセンタイルのレポート上の名前（99.9 → "p99.9"）を返します。
+func percentileLabel(q float64) string {
+	return "p" + strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+// parsePercentileLabel は、percentileLabel の名前からパーセンタイルの値を取り出します。
+func parsePercentileLabel(label string) (float64, bool) {
+	q, err := strconv.ParseFloat(strings.TrimPrefix(label, "p"), 64)
+	return q, err == nil && strings.HasPrefix(label, "p")
+}
+
+// minSamplesFor は、パーセンタイル q（%）を信頼できる値として算出するのに必要な最小のサンプル数です。
+func minSamplesFor(q float64) int {
+	// 99.99 → 10000 のように整数になるべき値が浮動小数点の誤差で切り上がらないよう、わずかに差し引きます
+	return int(math.Ceil(1/(1-q/100) - 1e-6))
+}
+
+// tailPercentiles は、orderLatencies で各パーセンタイルの位置を確定させた（または昇順にソート済みの）latencies から
+// 各パーセンタイルを算出し、サンプル数が足りないものについての警告と併せて返します（サンプルが0件の場合は何も返しません）。
+// インデックスは summarizeWithStats と同じ percentileIndex で求めます。
+func tailPercentiles(sorted []time.Duration, percentiles []float64) (map[string]string, []string) {
+	if len(sorted) == 0 || len(percentiles) == 0 {
+		return nil, nil
+	}
+	values := make(map[string]string, len(percentiles))
+	for _, q := range percentiles {
+		values[percentileLabel(q)] = formatDuration(sorted[percentileIndex(len(sorted), q/100)])
+	}
+	return values, percentileWarnings(len(sorted), percentiles)
+}
+
+// percentileWarnings は、サンプル数が足りないパーセンタイルについての警告を返します。
+func percentileWarnings(samples int, percentiles []float64) []string {
+	var warnings []string
+	for _, q := range percentiles {
+		if need := minSamplesFor(q); samples < need {
+			warnings = append(warnings, fmt.Sprintf("%s の算出には %d 件以上のサンプルが必要ですが、%d 件しかないため統計的に信頼できません",
+				percentileLabel(q), need, samples))
+		}
+	}
+	return warnings
+}
+
+// mergeTailPercentiles は、複数レポートの tail_percentiles をサンプル数による加重平均で統合し（近似値）、
+// 統合後のサンプル数で警告を算出し直します。
+func mergeTailPercentiles(reports []*TestReport, totalSamples int) (map[string]string, []string) {
+	sums := make(map[string]float64)
+	weights := make(map[string]float64)
+	for _, report := range reports {
+		for label, raw := range report.TailPercentiles {
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				continue
+			}
+			sums[label] += float64(d) * float64(report.LatencySamples)
+			weights[label] += float64(report.LatencySamples)
+		}
+	}
+
+	var merged map[string]string
+	var percentiles []float64
+	for label, sum := range sums {
+		q, ok := parsePercentileLabel(label)
+		if !ok || weights[label] == 0 {
+			continue
+		}
+		if merged == nil {
+			merged = make(map[string]string)
+		}
+		merged[label] = formatDuration(time.Duration(sum / weights[label]))
+		percentiles = append(percentiles, q)
+	}
+	slices.Sort(percentiles)
+	return merged, percentileWarnings(totalSamples, percentiles)
+}
+
+// reportedPercentiles は、レポートに記録されているパーセンタイルの値を昇順で返します（再算出用）。
+func reportedPercentiles(report *TestReport) []float64 {
+	var percentiles []float64
+	for label := range report.TailPercentiles {
+		if q, ok := parsePercentileLabel(label); ok {
+			percentiles = append(percentiles, q)
+		}
+	}
+	slices.Sort(percentiles)
+	return percentiles
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ascendingLatencies は、1ms から n ms まで 1ms 刻みの、昇順にソート済みのレイテンシを返します。
+func ascendingLatencies(n int) []time.Duration {
+	latencies := make([]time.Duration, n)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+	return latencies
+}
+
+// TestTailPercentilesSampleWarning は、p99.99 をサンプル 100 件で算出すると値は記録しつつ警告が付き、
+// 10万件では警告が付かないことを確認します。
+func TestTailPercentilesSampleWarning(t *testing.T) {
+	values, warnings := tailPercentiles(ascendingLatencies(100), []float64{99.99})
+	if values["p99.99"] == "" {
+		t.Errorf("tail_percentiles = %v: サンプルが足りなくても p99.99 は算出するはずです", values)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "p99.99") || !strings.Contains(warnings[0], "10000") {
+		t.Errorf("percentile_warnings = %q: 100 件のサンプルでは p99.99 に警告が付くはずです", warnings)
+	}
+
+	values, warnings = tailPercentiles(ascendingLatencies(100000), []float64{99.9, 99.99})
+	if len(warnings) != 0 {
+		t.Errorf("percentile_warnings = %q: 10万件のサンプルでは警告は付かないはずです", warnings)
+	}
+	if values["p99.9"] != formatDuration(99900*time.Millisecond) || values["p99.99"] != formatDuration(99990*time.Millisecond) {
+		t.Errorf("tail_percentiles = %v", values)
+	}
+}
+
+// TestMinSamplesFor は、各パーセンタイルに必要なサンプル数が浮動小数点の誤差で切り上がらないことを確認します。
+func TestMinSamplesFor(t *testing.T) {
+	for q, want := range map[float64]int{90: 10, 99: 100, 99.9: 1000, 99.99: 10000, 50: 2} {
+		if got := minSamplesFor(q); got != want {
+			t.Errorf("minSamplesFor(%v) = %d, want %d", q, got, want)
+		}
+	}
+}
+
+// TestPercentileWarningsInReport は、少ないリクエスト数の負荷試験のレポートに、
+// 既定の p99.9 と p99.99 の値と、その両方についての percentile_warnings が記録されることを確認します。
+func TestPercentileWarningsInReport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":  server.URL,
+		"concurrency": 1,
+		"rate_limit":  100,
+		"duration":    "300ms",
+	}))
+	if report.TailPercentiles["p99.9"] == "" || report.TailPercentiles["p99.99"] == "" {
+		t.Errorf("tail_percentiles = %v: 既定では p99.9 と p99.99 を記録するはずです", report.TailPercentiles)
+	}
+	if len(report.PercentileWarnings) != 2 {
+		t.Errorf("latency_samples=%d percentile_warnings = %q: p99.9 と p99.99 の両方に警告が付くはずです",
+			report.LatencySamples, report.PercentileWarnings)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// [セクション14] プリフライト: 本番の負荷をかける前の疎通確認
+// ==============================================================================
+
+// 数万ワーカーのテストを、DNS解決に失敗するホストや停止中のサーバーに対して開始してしまうと、
+// 接続エラーが大量に記録されるだけで何も得られません。そこで、ワーカーを起動する前に少数のプローブを送信し、
+// すべてのプローブが通信レベルで失敗した場合はテストを開始せずに中止します。
+// ステータスコードの異常（5xxなど）はターゲットの状態として計測する価値があるため、ログに残すだけで中止はしません。
+// no_preflight を指定すると省略できます。
+
+// preflightProbes は、プリフライトで送信するプローブの数です。
+const preflightProbes = 3
+
+// runPreflight は、ターゲットへプローブを送信し、すべて失敗した場合にエラーを返します。
+// 結果はプローブごとにログへ出力されます。
+func runPreflight(ctx context.Context, cfg *TestConfig) error {
+	timeout := requestTimeout(cfg)
+
+	var client *http.Client
+	var dialer *tunedDialer
+	if cfg.Mode == modeWebSocket {
+		dialer = newTunedDialer(cfg)
+	} else {
+		client = createOptimizedHTTPClient(1, cfg)
+		defer client.CloseIdleConnections()
+	}
+
+	var failures []string
+	for i := 1; i <= preflightProbes; i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		start := time.Now()
+		var result string
+		var err error
+		if cfg.Mode == modeWebSocket {
+			var conn *wsConn
+			conn, err = dialWebSocket(ctx, dialer, newTLSConfig(cfg), cfg.TargetURL, timeout)
+			if err == nil {
+				conn.Close()
+				result = "WebSocket接続成功"
+			}
+		} else {
+			var status int
+			status, err = probeHTTP(ctx, client, cfg)
+			result = fmt.Sprintf("HTTP %d", status)
+		}
+
+		if err != nil {
+			log.Printf("[Preflight] プローブ %d/%d: 失敗 (%v)\n", i, preflightProbes, err)
+			failures = append(failures, err.Error())
+			continue
+		}
+		log.Printf("[Preflight] プローブ %d/%d: %s (%s)\n", i, preflightProbes, result, formatDuration(time.Since(start)))
+	}
+
+	if len(failures) == preflightProbes {
+		return fmt.Errorf("プリフライトの全プローブ (%d件) が失敗したため、テストを中止しました。ターゲットに到達できません: %s",
+			preflightProbes, strings.Join(uniqueStrings(failures), " / "))
+	}
+	return nil
+}
+
+// probeHTTP は、設定されたメソッドでリクエストを1件送信し、ステータスコードを返します。
+func probeHTTP(ctx context.Context, client *http.Client, cfg *TestConfig) (int, error) {
+	ctx, cancel := withRequestTimeout(ctx, cfg)
+	defer cancel()
+	req, err := newBaseRequest(ctx, cfg.Method, cfg.TargetURL, cfg)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if cfg.HTTP3 {
+			return 0, explainHTTP3Error(err)
+		}
+		return 0, err
+	}
+	// 巨大なレスポンスを返すターゲットでも時間をかけないよう、読み捨てる量を制限します
+	drainAndClose(resp, 64<<10)
+	return resp.StatusCode, nil
+}
+
+// uniqueStrings は、出現順を保ったまま重複を取り除きます（同じエラーメッセージを繰り返し表示しないため）。
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var result []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
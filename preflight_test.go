@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPreflightAbortsUnreachableTarget は、到達できないターゲットへのテストが、ワーカーを起動する前にプリフライトで中止され、
+// リクエストを1件も送信せずに理由をレポートの error_msg で返すことを確認します。
+func TestPreflightAbortsUnreachableTarget(t *testing.T) {
+	// 閉じたポートへの接続は即座に拒否されます
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	cfg := newTestConfig(t, map[string]any{
+		"target_url":  "http://" + addr,
+		"concurrency": 50,
+		"duration":    "10s",
+	})
+	metrics := NewResultMetrics(0)
+	start := time.Now()
+	report := runLoadTest(context.Background(), cfg, metrics)
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("中止までに %v かかりました: テスト時間の経過を待たずに中止するはずです", elapsed)
+	}
+	if !strings.Contains(report.ErrorMsg, "プリフライト") {
+		t.Errorf("error_msg = %q: プリフライトで中止した理由が含まれていません", report.ErrorMsg)
+	}
+	if sent := metrics.SentRequests; sent != 0 || report.TotalRequests != 0 {
+		t.Errorf("sent=%d total=%d: ワーカーを起動せずに中止するはずです", sent, report.TotalRequests)
+	}
+}
+
+// TestPreflightAllowsErrorStatus は、5xx を返すターゲットはプリフライトで中止せず、テストを実行することを確認します。
+func TestPreflightAllowsErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":  server.URL,
+		"concurrency": 2,
+		"duration":    "200ms",
+	}))
+	if report.TotalRequests == 0 || report.StatusCodes["503"] == 0 {
+		t.Errorf("total=%d status_codes=%v: 5xx のターゲットにも負荷をかけるはずです (%s)", report.TotalRequests, report.StatusCodes, report.ErrorMsg)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション44] 接続の事前確立: 計測開始前にコネクションプールを温めておく (prewarm_connections)
+// ==============================================================================
+
+// テスト開始直後は、すべてのワーカーが一斉に新規接続（TCPハンドシェイクとTLSハンドシェイク）を行うため、
+// 最初の数百ミリ秒のレイテンシが突出し、短いテストほど結果が悪く見えます。prewarm_connections を指定すると、
+// 計測を始める前に並行数と同じ数の HEAD リクエストを同時に送信して接続を確立し、コネクションプールに残しておきます。
+// 計測中のリクエストは最初からこれらの接続を再利用するため、ハンドシェイクの費用を含みません。
+// サンプルを捨てるウォームアップ期間とは異なり、温めるのは接続だけで、事前のリクエストは一切計測に含めません。
+//
+// HTTP/1.1 では、応答を受け取った接続がすぐにプールへ戻って次のリクエストに再利用されてしまうと、
+// 並行数と同じ数の接続が確立されません。そこで、各リクエストが接続を取得した時点（GotConn）で、
+// すべてのリクエストが接続を取得するまで待ち合わせてから送信します。HTTP/2 では1本の接続を共有するため、
+// 確立される接続は通常1本です。複数ターゲットモードでは、先頭のターゲットへの接続のみを温めます。
+
+// prewarmConnections は、client のコネクションプールに n 本の接続を確立し、新たに確立した接続の数を返します。
+// 確立に失敗したリクエストは無視します（計測中に改めて接続されます）。
+func prewarmConnections(ctx context.Context, client *http.Client, cfg *TestConfig, n int) int {
+	// 待ち合わせが終わらない場合（一部の接続が確立できない場合など）に備えて、リクエストのタイムアウトで打ち切ります
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.TimeoutSec)*time.Second)
+	defer cancel()
+
+	var opened int64
+	var gotConn sync.WaitGroup
+	gotConn.Add(n)
+	allGot := make(chan struct{})
+	go func() {
+		gotConn.Wait()
+		close(allGot)
+	}()
+
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// 接続を取得した時点と、取得できずに失敗した時点のどちらか一方でのみ、待ち合わせの完了を通知します
+			arrived := sync.OnceFunc(gotConn.Done)
+			defer arrived()
+
+			traceCtx := httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) {
+					if !info.Reused {
+						atomic.AddInt64(&opened, 1)
+					}
+					arrived()
+					select {
+					case <-allGot:
+					case <-ctx.Done():
+					}
+				},
+			})
+			reqCtx, cancel := withRequestTimeout(traceCtx, cfg)
+			defer cancel()
+			req, err := http.NewRequestWithContext(reqCtx, http.MethodHead, cfg.TargetURL, nil)
+			if err != nil {
+				return
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return
+			}
+			drainAndClose(resp, drainAll)
+		}()
+	}
+	wg.Wait()
+
+	count := int(atomic.LoadInt64(&opened))
+	log.Printf("[Prewarm] 計測開始前に %d 本の接続を確立しました（要求: %d 本）\n", count, n)
+	return count
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newFirstMethodServer は、接続（リモートアドレス）ごとに最初に受けたリクエストのメソッドを記録するターゲットを起動します。
+func newFirstMethodServer(t *testing.T) (*httptest.Server, func() map[string]string) {
+	t.Helper()
+	var mu sync.Mutex
+	first := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if _, ok := first[r.RemoteAddr]; !ok {
+			first[r.RemoteAddr] = r.Method
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() map[string]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return maps.Clone(first)
+	}
+}
+
+// TestPrewarmConnections は、prewarm_connections を指定すると、並行数と同じ数の接続を計測開始前に確立し、
+// 計測中のリクエストは最初の1件から（計測中の httptrace で新規接続が1本も数えられずに）それらの接続を再利用することを、
+// 指定しない場合と比べて確認します。
+func TestPrewarmConnections(t *testing.T) {
+	const workers = 4
+	for _, prewarm := range []bool{true, false} {
+		server, firstMethods := newFirstMethodServer(t)
+		report := runTestLoad(newTestConfig(t, map[string]any{
+			"target_url":          server.URL,
+			"concurrency":         workers,
+			"duration":            "200ms",
+			"prewarm_connections": prewarm,
+			"no_preflight":        true,
+		}))
+		if report.TotalRequests == 0 || report.Errors != 0 {
+			t.Fatalf("total=%d errors=%d", report.TotalRequests, report.Errors)
+		}
+
+		methods := firstMethods()
+		if !prewarm {
+			if report.PrewarmedConnections != 0 || report.ConnectionsOpened != workers {
+				t.Errorf("prewarmed_connections=%d connections_opened=%d: 事前確立しない場合は計測中に %d 本開くはずです",
+					report.PrewarmedConnections, report.ConnectionsOpened, workers)
+			}
+			continue
+		}
+		if report.PrewarmedConnections != workers || report.ConnectionsOpened != 0 {
+			t.Errorf("prewarmed_connections=%d connections_opened=%d: 事前に %d 本確立し、計測中は新規に接続しないはずです",
+				report.PrewarmedConnections, report.ConnectionsOpened, workers)
+		}
+		// サーバー側でも、どの接続も事前確立の HEAD リクエストで始まり、計測中の GET で開かれた接続がないはずです
+		if len(methods) != workers {
+			t.Errorf("サーバー側の接続数 = %d, want %d", len(methods), workers)
+		}
+		for addr, method := range methods {
+			if method != http.MethodHead {
+				t.Errorf("接続 %s の最初のリクエスト = %s, want HEAD", addr, method)
+			}
+		}
+	}
+}
+
+// TestPrewarmConnectionsReuse は、prewarmConnections の直後に同時に送信したリクエストが、
+// httptrace の GotConn ですべて再利用された接続として報告されることを確認します。
+func TestPrewarmConnectionsReuse(t *testing.T) {
+	const n = 5
+	server, _ := newFirstMethodServer(t)
+	cfg := newTestConfig(t, map[string]any{"target_url": server.URL, "concurrency": n})
+	client := createOptimizedHTTPClient(n, cfg)
+	defer client.CloseIdleConnections()
+
+	if opened := prewarmConnections(context.Background(), client, cfg, n); opened != n {
+		t.Fatalf("prewarmConnections = %d, want %d", opened, n)
+	}
+
+	var mu sync.Mutex
+	var fresh int
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) {
+					if !info.Reused {
+						mu.Lock()
+						fresh++
+						mu.Unlock()
+					}
+				},
+			})
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			drainAndClose(resp, drainAll)
+		}()
+	}
+	wg.Wait()
+	if fresh != 0 {
+		t.Errorf("事前確立の直後のリクエストのうち %d 件が新規接続でした, want 0", fresh)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション47] 実行中のジョブの途中結果 (GET /api/progress/{id})
+// ==============================================================================
+
+// /api/result/{id} は、実行中のジョブについては完了数と直近の区間スナップショットしか返しません。
+// GET /api/progress/{id} は、実行中のジョブの累計（総リクエスト数・成功・エラー・平均RPS・直近1秒のRPS・通信中の数）と、
+// テスト開始からの全サンプルによるレイテンシのパーセンタイルを、完了を待たずに返します。
+// ポーリングする監視スクリプトやダッシュボードから、数秒おきに呼び出す使い方を想定しています。
+//
+// 累計値はアトミック変数から読むだけで、記録処理を妨げません。パーセンタイルは、記録したレイテンシ全体ではなく、
+// 記録時に一様に無作為抽出して progressSampleLimit 件までに抑えたサンプル（リザーバー）から求めます。
+// 全サンプルを複製すると、数百万件のテストでは記録処理と同じロックを複製の間ずっと保持することになり、
+// ポーリングのたびにワーカーの記録が止まってしまうためです。途中結果のパーセンタイルは推定値で、
+// 最小値・平均値・標準偏差・最大値と samples は全件の逐次集計値による正確な値です。
+// 算出はジョブごとに最大で progressRefreshInterval に1度だけ行って使い回し（上限件数の複製の間だけロックを取得し、
+// ソートはロックの外で行います）、完了済みのジョブには最終レポートの値を返します。
+
+// progressRefreshInterval は、実行中のジョブのパーセンタイルを算出し直す最短の間隔です。
+const progressRefreshInterval = time.Second
+
+// progressSampleLimit は、途中結果のパーセンタイルの算出に使うサンプルの上限です
+// （1万件で p50 の順位の誤差は ±1% 程度で、複製は数十マイクロ秒で済みます）。
+const progressSampleLimit = 10000
+
+// progressReservoir は、途中結果のパーセンタイルのために、記録したレイテンシから一様に無作為抽出したサンプルを
+// progressSampleLimit 件まで保持します。ResultMetrics.mu で保護します。nil の progressReservoir は何も保持しません。
+type progressReservoir struct {
+	samples []time.Duration
+	seen    int64
+}
+
+// newProgressReservoir は、空のリザーバーを生成します。
+func newProgressReservoir() *progressReservoir {
+	return &progressReservoir{samples: make([]time.Duration, 0, progressSampleLimit)}
+}
+
+// add は、1件のレイテンシをリザーバーへ反映します（Algorithm R。max_samples のリザーバーサンプリングと同じです）。
+func (r *progressReservoir) add(d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.seen++
+	if len(r.samples) < progressSampleLimit {
+		r.samples = append(r.samples, d)
+		return
+	}
+	if j := rand.Int64N(r.seen); j < progressSampleLimit {
+		r.samples[j] = d
+	}
+}
+
+// Progress は、/api/progress/{id} のレスポンスです。
+type Progress struct {
+	JobID         string  `json:"job_id"`
+	Status        string  `json:"status"` // "running" / "done"
+	ElapsedSec    float64 `json:"elapsed_sec"`
+	TotalRequests uint64  `json:"total_requests"`
+	Success       uint64  `json:"success"`
+	Errors        uint64  `json:"errors"`
+	AvgRPS        float64 `json:"avg_rps"`             // 開始からの平均スループット
+	CurrentRPS    uint64  `json:"current_rps"`         // 直近の1秒間に完了したリクエスト数（最初の1秒が経過するまでは0）
+	InFlight      int64   `json:"in_flight"`           // 現時点で通信中のリクエスト数
+	ErrorMsg      string  `json:"error_msg,omitempty"` // ジョブが見つからない場合など
+
+	// Latency は、算出した時点までのレイテンシの要約です（応答が0件の場合は省略）。実行中のジョブのパーセンタイルは、
+	// 抽出したサンプル（最大 progressSampleLimit 件）による推定値です。
+	// LatencyAtSec は、その算出時点の経過秒数です（最大で progressRefreshInterval だけ古い値になります）。
+	Latency      *LatencySummary `json:"latency,omitempty"`
+	LatencyAtSec float64         `json:"latency_at_sec,omitempty"`
+}
+
+// progressCache は、ジョブごとに直近に算出したレイテンシの要約です。
+type progressCache struct {
+	mu      sync.Mutex
+	at      time.Time
+	atSec   float64
+	summary *LatencySummary
+}
+
+// latency は、直近に算出したレイテンシの要約を返します。progressRefreshInterval 以上経過していれば算出し直します。
+func (c *progressCache) latency(metrics *ResultMetrics, started time.Time) (*LatencySummary, float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.at.IsZero() && time.Since(c.at) < progressRefreshInterval {
+		return c.summary, c.atSec
+	}
+
+	// 記録処理と競合しないよう、上限件数までのサンプルと逐次集計値の複製だけをロック内で行います
+	metrics.mu.Lock()
+	var samples []time.Duration
+	if metrics.progress != nil {
+		samples = slices.Clone(metrics.progress.samples)
+	}
+	stats := metrics.latencyStats
+	metrics.mu.Unlock()
+
+	c.at = time.Now()
+	c.atSec = c.at.Sub(started).Seconds()
+	c.summary = nil
+	if len(samples) > 0 {
+		summary := summarizeWithStats(samples, &stats)
+		summary.Samples = int(stats.count)
+		c.summary = &summary
+	}
+	return c.summary, c.atSec
+}
+
+// progress は、ジョブの現在の途中結果を返します。
+func (j *loadJob) progress() Progress {
+	select {
+	case <-j.done:
+		r := j.report
+		summary := LatencySummary{
+			Samples: r.LatencySamples,
+			Min:     r.MinLatency,
+			Mean:    r.MeanLatency,
+			StdDev:  r.StdDevLatency,
+			P50:     r.P50Latency,
+			P90:     r.P90Latency,
+			P99:     r.P99Latency,
+			Max:     r.MaxLatency,
+		}
+		p := Progress{
+			JobID:         j.id,
+			Status:        jobDone,
+			ElapsedSec:    r.ActualDurationSec,
+			TotalRequests: uint64(r.TotalRequests),
+			Success:       uint64(r.Success),
+			Errors:        uint64(r.Errors),
+			AvgRPS:        r.ThroughputRPS,
+			LatencyAtSec:  r.ActualDurationSec,
+		}
+		if r.LatencySamples > 0 {
+			p.Latency = &summary
+		}
+		if n := len(r.RPSTimeline); n > 0 {
+			p.CurrentRPS = r.RPSTimeline[n-1]
+		}
+		return p
+	default:
+	}
+
+	elapsed := time.Since(j.started)
+	p := Progress{
+		JobID:         j.id,
+		Status:        jobRunning,
+		ElapsedSec:    elapsed.Seconds(),
+		TotalRequests: j.metrics.TotalRequests.Load(),
+		Success:       j.metrics.SuccessCount.Load(),
+		Errors:        j.metrics.ErrorCount.Load(),
+		InFlight:      atomic.LoadInt64(&j.metrics.InFlight),
+	}
+	if elapsed > 0 {
+		p.AvgRPS = float64(p.TotalRequests) / elapsed.Seconds()
+	}
+	j.metrics.mu.Lock()
+	if n := len(j.metrics.rpsTimeline); n > 0 {
+		p.CurrentRPS = j.metrics.rpsTimeline[n-1]
+	}
+	j.metrics.mu.Unlock()
+	p.Latency, p.LatencyAtSec = j.progressCache.latency(j.metrics, j.started)
+	return p
+}
+
+// handleJobProgress は、実行中のジョブの途中結果（完了済みの場合は最終結果）を返すエンドポイントです。
+func handleJobProgress(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	id := r.PathValue("id")
+	job, ok := jobs.get(id)
+	if !ok {
+		writeProgress(w, http.StatusNotFound, Progress{JobID: id, ErrorMsg: jobNotFoundMsg})
+		return
+	}
+	writeProgress(w, http.StatusOK, job.progress())
+}
+
+// writeProgress は、Progress をJSONとして書き込みます。
+func writeProgress(w http.ResponseWriter, code int, p Progress) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Printf("[API Error] 途中結果のJSONエンコードに失敗しました: %v\n", err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestJobProgressIncreases は、実行中のジョブの途中結果の件数が時間とともに増え、レイテンシの要約が得られることを確認します。
+func TestJobProgressIncreases(t *testing.T) {
+	server := newTestJobServer(t)
+	job := startForeverJob(t, server)
+
+	first := job.progress()
+	if first.Status != jobRunning {
+		t.Fatalf("status = %s, want running", first.Status)
+	}
+	var second Progress
+	if !waitFor(5*time.Second, func() bool {
+		second = job.progress()
+		return second.TotalRequests > first.TotalRequests
+	}) {
+		t.Fatalf("total_requests が増えません: %d → %d", first.TotalRequests, second.TotalRequests)
+	}
+	if second.Success < first.Success || second.ElapsedSec <= first.ElapsedSec {
+		t.Errorf("success %d → %d, elapsed %v → %v: 途中結果が進んでいません", first.Success, second.Success, first.ElapsedSec, second.ElapsedSec)
+	}
+	if second.Latency == nil || second.Latency.Samples == 0 || second.Latency.P50 == "N/A" {
+		t.Errorf("latency = %+v: 実行中のレイテンシの要約がありません", second.Latency)
+	}
+}
+
+// TestProgressReservoirBounded は、途中結果用のサンプルが上限件数を超えて増えず、全件を数えていることを確認します。
+func TestProgressReservoirBounded(t *testing.T) {
+	metrics := NewResultMetrics(0)
+	metrics.progress = newProgressReservoir()
+	const total = 3 * progressSampleLimit
+	for i := range total {
+		metrics.addLatency(time.Duration(i))
+	}
+	if got := len(metrics.progress.samples); got != progressSampleLimit {
+		t.Errorf("サンプル数 = %d, want %d", got, progressSampleLimit)
+	}
+
+	var cache progressCache
+	summary, _ := cache.latency(metrics, time.Now())
+	if summary == nil || summary.Samples != total || summary.Max != formatDuration(total-1) {
+		t.Errorf("summary = %+v, want samples=%d max=%s", summary, total, formatDuration(total-1))
+	}
+}
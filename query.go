@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ==============================================================================
+// [セクション46] クエリパラメーターの指定 (-q / query)
+// ==============================================================================
+
+// API キーやバージョン、検索条件などのクエリパラメーターを毎回URLに書き込むと、URLが長く読みにくくなり、
+// 特殊文字（"&"、"="、空白、日本語など）のエンコード漏れも起こりがちです。クエリパラメーターはURLとは別に指定でき、
+// 設定の読み込み時に1度だけ、正しくエンコードしてターゲットURL（targets の各URLを含みます）へ追加します。
+//   - 設定JSONの query     : そのテストだけに追加します（{"api_key": "abc", "q": "東京 & 大阪"}）
+//   - サーバーの -q フラグ : そのサーバーで実行するすべてのテストに追加します（繰り返し指定可。同じキーは設定JSONが優先）
+//
+// URLに既に同じ名前のパラメーターがある場合は、指定した値で置き換えます。それ以外のURLのクエリ文字列は、
+// 元の表記（エンコードや順序）のまま残します。request_file の各行のURLには追加しません（行ごとに完全なURLを指定するため）。
+
+// queryFlags は、繰り返し指定できる -q key=value フラグの値です（flag.Value を実装します）。
+type queryFlags url.Values
+
+// defaultQuery は、-q フラグで指定された、すべてのテストのURLに追加するクエリパラメーターです。
+var defaultQuery = queryFlags{}
+
+// String は、指定済みのクエリパラメーターをエンコード済みのクエリ文字列として返します。
+func (q queryFlags) String() string {
+	return url.Values(q).Encode()
+}
+
+// Set は、key=value 形式の1つのクエリパラメーターを追加します（同じキーを繰り返すと複数の値になります）。
+func (q queryFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("クエリパラメーターは key=value の形式で指定してください: %q", value)
+	}
+	if key == "" {
+		return errors.New("クエリパラメーターのキーが空です")
+	}
+	url.Values(q).Add(key, val)
+	return nil
+}
+
+// resolveQuery は、設定JSONの query を検証し、-q フラグのパラメーターを補って返します（同じキーは設定JSONが優先）。
+// パラメーターが1つもない場合は nil を返します。
+func resolveQuery(query map[string]string) (url.Values, error) {
+	if len(query) == 0 && len(defaultQuery) == 0 {
+		return nil, nil
+	}
+	params := url.Values{}
+	for key, values := range defaultQuery {
+		params[key] = slices.Clone(values)
+	}
+	for _, key := range slices.Sorted(maps.Keys(query)) {
+		if key == "" {
+			return nil, errors.New("query のキーが空です")
+		}
+		params.Set(key, query[key])
+	}
+	return params, nil
+}
+
+// mergeQuery は、rawURL のクエリ文字列に params を追加したURLを返します。
+// 同じ名前のパラメーターは置き換え、それ以外の既存のパラメーターは元の表記のまま残します。
+func mergeQuery(rawURL string, params url.Values) (string, error) {
+	if len(params) == 0 {
+		return rawURL, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("ターゲットURLの形式が正しくありません: %v", err)
+	}
+
+	var kept []string
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		rawKey, _, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		if _, replaced := params[key]; !replaced {
+			kept = append(kept, pair)
+		}
+	}
+	u.RawQuery = strings.Join(append(kept, params.Encode()), "&")
+	return u.String(), nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// TestQueryMerged は、-q フラグと設定JSONの query のパラメーターが、特殊文字を正しくエンコードしてターゲットURLの既存の
+// クエリ文字列へ追加され（同じキーは設定JSONが、URLにある同じ名前のパラメーターは指定した値が優先）、
+// ターゲットが受信したリクエストのURLで元の値として読めることを確認します。
+func TestQueryMerged(t *testing.T) {
+	var mu sync.Mutex
+	var rawQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		rawQuery = r.URL.RawQuery
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	saved := defaultQuery
+	t.Cleanup(func() { defaultQuery = saved })
+	defaultQuery = queryFlags{}
+	for _, flag := range []string{"api_key=k/1+2", "version=1", "tag=a", "tag=b"} {
+		if err := defaultQuery.Set(flag); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":   server.URL + "/search?page=2&q=old&raw=%7Ex",
+		"concurrency":  1,
+		"duration":     "100ms",
+		"query":        map[string]string{"q": "東京 & 大阪=1", "version": "2", "empty": ""},
+		"no_preflight": true,
+	}))
+	if report.TotalRequests == 0 || report.Errors != 0 {
+		t.Fatalf("total=%d errors=%d", report.TotalRequests, report.Errors)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	got, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		t.Fatalf("受信したクエリ文字列 %q を解釈できません: %v", rawQuery, err)
+	}
+	want := url.Values{
+		"page":    {"2"},
+		"raw":     {"~x"},
+		"q":       {"東京 & 大阪=1"},
+		"api_key": {"k/1+2"},
+		"version": {"2"},
+		"tag":     {"a", "b"},
+		"empty":   {""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("受信したクエリ = %v, want %v（受信したクエリ文字列: %s）", got, want, rawQuery)
+	}
+}
+
+// TestMergeQuery は、既存のパラメーターの元の表記と順序を残したまま、同じ名前のパラメーターだけを置き換えて追加することを確認します。
+func TestMergeQuery(t *testing.T) {
+	tests := []struct {
+		url    string
+		params url.Values
+		want   string
+	}{
+		{"http://h/p", url.Values{"a": {"x y"}}, "http://h/p?a=x+y"},
+		{"http://h/p?z=%7E&b=1", url.Values{"a": {"&="}}, "http://h/p?z=%7E&b=1&a=%26%3D"},
+		{"http://h/p?a=old&b=1&a=older", url.Values{"a": {"new"}}, "http://h/p?b=1&a=new"},
+		{"http://h/p?a%20b=1&c", url.Values{"a b": {"2"}}, "http://h/p?c&a+b=2"},
+		{"http://h/p?x=1#frag", url.Values{"y": {"日本"}}, "http://h/p?x=1&y=%E6%97%A5%E6%9C%AC#frag"},
+		{"http://h/p?x=1", nil, "http://h/p?x=1"},
+	}
+	for _, tt := range tests {
+		got, err := mergeQuery(tt.url, tt.params)
+		if err != nil || got != tt.want {
+			t.Errorf("mergeQuery(%q, %v) = %q, %v, want %q", tt.url, tt.params, got, err, tt.want)
+		}
+	}
+}
+
+// TestQueryValidation は、-q フラグの形式の誤りと、query の空のキーを拒否することを確認します。
+func TestQueryValidation(t *testing.T) {
+	for _, invalid := range []string{"novalue", "=value"} {
+		if err := (queryFlags{}).Set(invalid); err == nil {
+			t.Errorf("-q %q がエラーになりません", invalid)
+		}
+	}
+	if _, err := resolveQuery(map[string]string{"": "x"}); err == nil {
+		t.Error("query の空のキーがエラーになりません")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ==============================================================================
+// [セクション25] ランプダウン: テスト終盤に負荷を段階的に下げるクールダウン
+// ==============================================================================
+
+// 通常のテストは、実行時間が経過した瞬間に全ワーカーを一斉に停止するため、負荷が急にゼロになり、
+// 大量の接続が同時に切断されるスパイクがターゲットに発生します。ramp_down_sec を指定すると、
+// 実行時間の最後の ramp_down_sec 秒間でワーカー数を並行数から0まで直線的に減らし、
+// トラフィックが徐々に引いていく様子を再現します（タイムラインのRPSも段階的に下がります）。
+// ランプダウンの期間は実行時間（duration）に含まれます。
+
+// rampDownTick は、ランプダウン中にワーカー数を見直す間隔です。
+const rampDownTick = 100 * time.Millisecond
+
+// runRampDown は、テスト開始時刻 start から数えて実行時間の最後の ramp_down_sec 秒間、
+// プールのワーカー数を直線的に減らします。ctx がキャンセルされると done をクローズして終了します。
+func runRampDown(ctx context.Context, cfg *TestConfig, pool *workerPool, start time.Time, done chan<- struct{}) {
+	defer close(done)
+
+	rampDown := time.Duration(cfg.RampDownSec) * time.Second
+	end := start.Add(time.Duration(cfg.Duration))
+	rampStart := end.Add(-rampDown)
+
+	timer := time.NewTimer(time.Until(rampStart))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+	}
+	log.Printf("[Ramp Down] 残り %d 秒でワーカー数を %d から段階的に減らします\n", cfg.RampDownSec, cfg.Concurrency)
+
+	ticker := time.NewTicker(rampDownTick)
+	defer ticker.Stop()
+	for {
+		// 残り時間に比例したワーカー数まで減らします（切り上げにより、期間の最後まで少なくとも1ワーカーが残ります）
+		remaining := time.Until(end)
+		target := int((int64(cfg.Concurrency)*int64(remaining) + int64(rampDown) - 1) / int64(rampDown))
+		pool.Resize(max(target, 0))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
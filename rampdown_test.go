@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestRampDownTapersRPS は、ramp_down_sec を指定すると、rps_timeline の各秒の完了数がクールダウン期間で段階的に減り、
+// 最後の1秒まで0にならない（全ワーカーを一斉に止めない）ことを、一定時間で応答するサーバーで確認します。
+func TestRampDownTapersRPS(t *testing.T) {
+	// 1ワーカーあたり約20件/秒になり、完了数がワーカー数にほぼ比例します
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	t.Cleanup(server.Close)
+
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":    server.URL,
+		"concurrency":   8,
+		"duration":      "6s",
+		"ramp_down_sec": 4,
+		"no_preflight":  true,
+	}))
+
+	timeline := report.RPSTimeline
+	// 最後の1秒はテスト終了と集計のタイミングによって記録されないことがあるため、最初の5秒分だけを見ます
+	if len(timeline) < 5 {
+		t.Fatalf("rps_timeline = %v: 5秒分以上の記録があるはずです", timeline)
+	}
+	full := max(timeline[0], timeline[1])
+	ramp := timeline[2:5]
+	// 開始から2〜3秒・3〜4秒・4〜5秒の平均ワーカー数は、並行数のおよそ 7/8・5/8・3/8 です
+	if ramp[0] >= full || ramp[1] >= ramp[0] || ramp[2] >= ramp[1] {
+		t.Errorf("rps_timeline = %v: クールダウン期間で完了数が段階的に減るはずです", timeline)
+	}
+	if ramp[1] < full*3/8 || ramp[1] > full*7/8 {
+		t.Errorf("開始から3〜4秒の完了数 %d: 全負荷時 %d のおよそ 5/8 になるはずです", ramp[1], full)
+	}
+	if ramp[2] < full/8 {
+		t.Errorf("rps_timeline = %v: 一斉に停止せず、終了間際まで一部のワーカーが送信を続けるはずです", timeline)
+	}
+}
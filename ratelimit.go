@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション13] レート制御: ワーカーごとのペーシングとジッター、バースト性の計測
+// ==============================================================================
+
+// rate_limit を指定すると、全体の目標レートを並行数で割り、各ワーカーが一定間隔（interval = 並行数 / rate_limit）で
+// リクエストを送信します。ただし全ワーカーが同じ時刻に起動するため、そのままでは全員が同じタイミングで
+// 一斉に送信する「足並みのそろったバースト」になり、実際の利用者のトラフィックとはかけ離れた到着パターンになります。
+//
+// rate_jitter (0〜1) を指定すると、
+//   - ワーカーごとの送信タイミングの位相を [0, rate_jitter × interval) の範囲でずらし、
+//   - 各送信時刻を本来のスロットから ±rate_jitter × interval / 2 の範囲でランダムに前後させます。
+// スロット自体は等間隔のまま（ずれは累積しません）なので、平均レートを保ったまま瞬間的なバーストだけを抑えられます。
+//
+// max_rps は、これらとは別の「安全上限」です。通常は制限なしで全力で送信し、全ワーカー合計の送信レートが
+// 上限を超えそうな場合にだけ、全ワーカーで共有するトークンバケットで送信を待たせます。
+// 壊れやすいターゲットを保護しつつ、上限に達しない範囲ではワーカーの挙動を一切変えません。
+
+// burstWindow は、バースト性を計測する区間の長さです。
+const burstWindow = 100 * time.Millisecond
+
+// BurstStats は、送信したリクエストの瞬間的な偏り（バースト性）を表します。
+// 100ミリ秒ごとの固定区間で送信数を集計したものです（区間をまたぐバーストは2区間に分かれて計上されます）。
+type BurstStats struct {
+	WindowMs      int     `json:"window_ms"`       // 集計区間の長さ（ミリ秒）
+	MaxPerWindow  uint64  `json:"max_per_window"`  // 1区間あたりの最大送信数
+	MeanPerWindow float64 `json:"mean_per_window"` // 1区間あたりの平均送信数
+	PeakToMean    float64 `json:"peak_to_mean"`    // 最大 / 平均。1に近いほど送信が均等に分散しています
+}
+
+// pacer は、1ワーカーの送信タイミングを制御します。1つのワーカーからのみ使用されます。
+type pacer struct {
+	interval time.Duration
+	jitter   float64
+	rng      *rand.Rand
+	next     time.Time // 次のスロット（ジッター適用前）
+
+	// traffic_shape が指定されている場合は、一定の間隔の代わりに目標レートの累計件数に従って送信します（trafficshape.go を参照）
+	shape *trafficShape
+	share float64 // 目標レートのうちこの pacer が受け持つ割合
+	slot  float64 // 次のスロット（累計件数のうち自分の割合で数えた値。ジッター適用前）
+}
+
+// newPacer は、1ワーカーあたり ratePerWorker リクエスト/秒で送信する pacer を生成します。
+// ratePerWorker が0以下の場合はレート制御を行わないため nil を返します（nil の pacer は待機しません）。
+func newPacer(ratePerWorker, jitter float64, rng *rand.Rand) *pacer {
+	if ratePerWorker <= 0 {
+		return nil
+	}
+	p := &pacer{
+		interval: time.Duration(float64(time.Second) / ratePerWorker),
+		jitter:   jitter,
+		rng:      rng,
+		next:     time.Now(),
+	}
+	// ジッターが有効な場合は、ワーカー間で送信の位相が重ならないよう最初のスロットをずらします
+	if jitter > 0 {
+		p.next = p.next.Add(time.Duration(rng.Float64() * jitter * float64(p.interval)))
+	}
+	return p
+}
+
+// Wait は、次の送信スロットまで待機します。ctx がキャンセルされた場合は false を返します。
+// 応答が遅れてスロットを過ぎていた場合は待たずに送信しますが、過ぎたスロットの分をまとめて送信することはしません。
+// 遅れた間の分を取り戻そうと連続して送信すると、ジッターで散らしたはずの一斉送信がかえって生じるためです。
+func (p *pacer) Wait(ctx context.Context) bool {
+	if p == nil {
+		return ctx.Err() == nil
+	}
+	if p.shape != nil {
+		return p.waitShaped(ctx)
+	}
+
+	if now := time.Now(); p.next.Before(now) {
+		// 遅れた間の分をまとめて送信できないよう、過去のスロットは繰り越しません
+		p.next = now
+	}
+	at := p.next
+	if p.jitter > 0 {
+		offset := (p.rng.Float64() - 0.5) * p.jitter * float64(p.interval)
+		at = at.Add(time.Duration(offset))
+	}
+	p.next = p.next.Add(p.interval)
+
+	d := time.Until(at)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// ratePerWorker は、設定された全体の目標レートを1ワーカーあたりのレートに換算します。
+func ratePerWorker(cfg *TestConfig) float64 {
+	if cfg.RateLimit <= 0 || cfg.Concurrency <= 0 {
+		return 0
+	}
+	return cfg.RateLimit / float64(cfg.Concurrency)
+}
+
+// sampleBurstiness は、テスト実行中に100ミリ秒ごとの送信数を集計し、最大値と平均値を記録します。
+// ctx がキャンセルされると done をクローズして終了します（端数の区間は記録しません）。
+func sampleBurstiness(ctx context.Context, metrics *ResultMetrics, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(burstWindow)
+	defer ticker.Stop()
+
+	var last, max, sum, windows uint64
+	for {
+		select {
+		case <-ctx.Done():
+			if windows > 0 {
+				stats := &BurstStats{
+					WindowMs:      int(burstWindow / time.Millisecond),
+					MaxPerWindow:  max,
+					MeanPerWindow: float64(sum) / float64(windows),
+				}
+				if stats.MeanPerWindow > 0 {
+					stats.PeakToMean = float64(max) / stats.MeanPerWindow
+				}
+				metrics.mu.Lock()
+				metrics.burstiness = stats
+				metrics.mu.Unlock()
+			}
+			return
+		case <-ticker.C:
+			sent := atomic.LoadUint64(&metrics.SentRequests)
+			n := sent - last
+			last = sent
+			sum += n
+			windows++
+			if n > max {
+				max = n
+			}
+		}
+	}
+}
+
+// rpsCeiling は、全ワーカー合計の送信レートの上限（max_rps）を守るトークンバケットです。
+// バケットの容量は burstWindow 分のトークン（最低1）で、短時間のバーストは容量の範囲でのみ許容します。
+type rpsCeiling struct {
+	mu     sync.Mutex
+	rate   float64   // 1秒あたりに補充されるトークン数
+	burst  float64   // バケットの容量
+	tokens float64   // 現在のトークン数（負の値は、すでに予約済みの待ち行列を表します）
+	last   time.Time // 最後にトークンを補充した時刻
+
+	hits uint64 // 上限に達して待機したリクエストの数（アトミックに更新）
+}
+
+// newRPSCeiling は、maxRPS リクエスト/秒を上限とする rpsCeiling を生成します。
+// maxRPS が0以下の場合は上限を設けないため nil を返します（nil の rpsCeiling は待機しません）。
+func newRPSCeiling(maxRPS float64) *rpsCeiling {
+	if maxRPS <= 0 {
+		return nil
+	}
+	burst := max(maxRPS*burstWindow.Seconds(), 1)
+	return &rpsCeiling{rate: maxRPS, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Wait は、トークンを1つ予約し、不足している場合は補充されるまで待機します。
+// ctx がキャンセルされた場合は false を返します。
+func (c *rpsCeiling) Wait(ctx context.Context) bool {
+	if c == nil {
+		return ctx.Err() == nil
+	}
+
+	c.mu.Lock()
+	now := time.Now()
+	c.tokens = min(c.tokens+now.Sub(c.last).Seconds()*c.rate, c.burst)
+	c.last = now
+	c.tokens--
+	deficit := -c.tokens
+	c.mu.Unlock()
+
+	if deficit <= 0 {
+		return ctx.Err() == nil
+	}
+	atomic.AddUint64(&c.hits, 1)
+
+	timer := time.NewTimer(time.Duration(deficit / c.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Hits は、上限に達して待機したリクエストの数を返します（nil の場合は0）。
+func (c *rpsCeiling) Hits() uint64 {
+	if c == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.hits)
+}
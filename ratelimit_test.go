@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// TestRateJitterLowersPeakToMean は、rate_jitter を指定すると、全ワーカーが同じ時刻に送信する足並みのそろったバーストが
+// 散らされ、100ミリ秒ごとの送信数の peak_to_mean が下がることを確認します。
+func TestRateJitterLowersPeakToMean(t *testing.T) {
+	server := newTestJobServer(t)
+	peakToMean := func(jitter float64) float64 {
+		// 20ワーカーが 400ms ごとに送信するため、ジッターがなければ4区間に1度、20件が1つの区間に集中します
+		cfg := newTestConfig(t, map[string]any{
+			"target_url":  server.URL,
+			"concurrency": 20,
+			"duration":    "2s",
+			"rate_limit":  50,
+			"rate_jitter": jitter,
+			"seed":        1,
+		})
+		report := runTestLoad(cfg)
+		if report.Burstiness == nil {
+			t.Fatalf("rate_jitter=%v: レポートに burstiness がありません", jitter)
+		}
+		return report.Burstiness.PeakToMean
+	}
+
+	synchronized, jittered := peakToMean(0), peakToMean(1)
+	if synchronized < 2.5 {
+		t.Errorf("ジッターなしの peak_to_mean = %.2f: 一斉送信のバーストが計測されていません", synchronized)
+	}
+	if jittered >= synchronized*0.75 {
+		t.Errorf("peak_to_mean: ジッターなし %.2f, ジッターあり %.2f: ジッターでバーストが抑えられていません", synchronized, jittered)
+	}
+}
+
+// TestPacerDoesNotBurstAfterStall は、送信が遅れてスロットを過ぎた後に、過ぎたスロットの分を連続して送信しないことを確認します。
+func TestPacerDoesNotBurstAfterStall(t *testing.T) {
+	p := newPacer(100, 0, rand.New(rand.NewPCG(1, 2))) // 10ms 間隔
+	ctx := context.Background()
+	if !p.Wait(ctx) {
+		t.Fatal("Wait が false を返しました")
+	}
+
+	// 応答の遅れで 10 スロット分を過ぎた後も、待たずに送信できるのは1回だけです
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	for range 3 {
+		p.Wait(ctx)
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("遅れた後の3回の送信が %v で終わりました: 過去のスロットの分をまとめて送信しています", elapsed)
+	}
+}
+
+// TestMaxRPSClampsThroughput は、即座に応答するターゲットに対しても、全ワーカー合計のスループットが max_rps
+// （と初期のバースト分）に抑えられ、上限が作動したことがレポートに記録されることを確認します。
+func TestMaxRPSClampsThroughput(t *testing.T) {
+	server := newTestJobServer(t)
+	const maxRPS = 100
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":  server.URL,
+		"concurrency": 8,
+		"duration":    "1s",
+		"max_rps":     maxRPS,
+	}))
+
+	// バケットの容量（100ms 分の 10 件）だけは上限を超えて送信できます
+	limit := maxRPS*report.ActualDurationSec + maxRPS*burstWindow.Seconds() + 1
+	if float64(report.TotalRequests) > limit || report.TotalRequests < maxRPS/2 {
+		t.Errorf("total=%d (throughput %.1f RPS): max_rps %d に抑えられていません", report.TotalRequests, report.ThroughputRPS, maxRPS)
+	}
+	if !report.MaxRPSEngaged || report.MaxRPSCapHits == 0 || report.MaxRPS != maxRPS {
+		t.Errorf("max_rps=%v engaged=%v cap_hits=%d: 上限の作動が記録されていません", report.MaxRPS, report.MaxRPSEngaged, report.MaxRPSCapHits)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// ==============================================================================
+// [セクション35] リクエストファイル: 1行1リクエストの定義を再生する
+// ==============================================================================
+
+// 実際のアクセスログやシナリオを再現したい場合、単一のURLや重み付きの targets では表現しきれません。
+// request_file にサーバーのローカルファイルのパスを指定すると、1行ごとにメソッド・URL・ヘッダー・ボディを
+// 定義したリクエストを、各ワーカーが request_order に従って順番に（sequential、既定）または無作為に（random）送信します。
+// sequential では全ワーカーで1つの位置を共有するため、ファイル全体が先頭から順に送信され、末尾に達すると先頭に戻ります
+// （並行して送信されるため、完了順は前後します）。ファイルは設定の読み込み時に1度だけ読み込み、すべての行を検証します。
+//
+// 【フォーマット】 1行に1リクエストで、空行と "#" で始まる行は無視します。
+//   <メソッド> <URL> [オプション]
+// オプションは省略可能で、指定する場合は headers（ヘッダー名と値のオブジェクト）と body（文字列）を持つJSONオブジェクトです。
+//   GET https://example.com/items?page=1
+//   POST https://example.com/items {"headers":{"Content-Type":"application/json"},"body":"{\"name\":\"apple\"}"}
+//   DELETE https://example.com/items/42 {"headers":{"Authorization":"Bearer xxx"}}
+
+// リクエストファイルの送信順序（request_order）
+const (
+	requestOrderSequential = "sequential"
+	requestOrderRandom     = "random"
+)
+
+// maxRequestLineBytes は、リクエストファイルの1行の最大サイズです（ボディを含みます）。
+const maxRequestLineBytes = 1 << 20
+
+// requestSpec は、リクエストファイルの1行で定義された1件のリクエストです。
+type requestSpec struct {
+	Method  string            `json:"-"`
+	URL     string            `json:"-"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// loadRequestFile は、path のリクエストファイルを読み込み、すべての行を検証して返します。
+// 問題がある場合は、行番号を含む利用者向けのエラーメッセージを返します。
+// スキームを補ったURLがある場合は、その行数と最初の行の内容をまとめた警告を1件だけ返します（行ごとに警告すると大量になるため）。
+func loadRequestFile(path string) (specs []requestSpec, warning string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("request_file を開けません: %w", err)
+	}
+	defer f.Close()
+
+	specs, warning, err = readRequestSpecs(f, "request_file", false)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(specs) == 0 {
+		return nil, "", fmt.Errorf("request_file にリクエストが1件も定義されていません: %s", path)
+	}
+	return specs, warning, nil
+}
+
+// readRequestSpecs は、r から1行1リクエストの定義を終端まで読み込み、すべての行を検証して返します。
+// source はエラーメッセージと警告で示す読み込み元の名前です。bareURL が true の場合は、メソッドを省略して
+// URLだけを書いた行も GET として受け付けます。
+func readRequestSpecs(r io.Reader, source string, bareURL bool) (specs []requestSpec, warning string, err error) {
+	var warned int
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRequestLineBytes)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, rest := cutField(line); bareURL && rest == "" {
+			line = http.MethodGet + " " + line
+		}
+		spec, lineWarning, err := parseRequestLine(line)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s の %d 行目: %w", source, lineNo, err)
+		}
+		if lineWarning != "" {
+			if warned == 0 {
+				warning = fmt.Sprintf("%s の %d 行目: %s", source, lineNo, lineWarning)
+			}
+			warned++
+		}
+		specs = append(specs, spec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", fmt.Errorf("%s の読み込みに失敗しました: %w", source, err)
+	}
+	if warned > 1 {
+		warning += fmt.Sprintf("（ほか %d 行も同様）", warned-1)
+	}
+	return specs, warning, nil
+}
+
+// parseRequestLine は、"<メソッド> <URL> [オプションのJSON]" の1行を解釈して検証します。
+// URLのスキームを補った場合は、その旨の警告を返します。
+func parseRequestLine(line string) (spec requestSpec, warning string, err error) {
+	method, rest := cutField(line)
+	rawURL, options := cutField(rest)
+	if rawURL == "" {
+		return spec, "", fmt.Errorf("\"<メソッド> <URL>\" の形式で指定してください: %q", line)
+	}
+	if options != "" {
+		dec := json.NewDecoder(strings.NewReader(options))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&spec); err != nil {
+			return spec, "", fmt.Errorf("URLの後ろのオプションは headers と body を持つJSONオブジェクトで指定してください: %v", err)
+		}
+		if dec.More() {
+			return spec, "", fmt.Errorf("オプションのJSONの後ろに余分なデータがあります")
+		}
+	}
+
+	if !isValidMethod(method) {
+		return spec, "", fmt.Errorf("HTTPメソッドとして使用できない文字列です: %q", method)
+	}
+	normalized, warning, err := normalizeTargetURL(rawURL, modeHTTP)
+	if err != nil {
+		return spec, "", err
+	}
+	for name := range spec.Headers {
+		// ヘッダー名の規則（トークン文字のみ）はメソッド名と同じです
+		if !isValidMethod(name) {
+			return spec, "", fmt.Errorf("ヘッダー名として使用できない文字列です: %q", name)
+		}
+	}
+	spec.Method, spec.URL = method, normalized
+	return spec, warning, nil
+}
+
+// cutField は、先頭の空白区切りのフィールドと、その後ろの残り（前後の空白を除く）を返します。
+func cutField(s string) (field, rest string) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i:])
+	}
+	return s, ""
+}
+
+// requestReplay は、リクエストファイルの各行から生成したベースリクエストと、送信位置です。
+// ベースリクエストは複製してのみ使うため、全ワーカーで共有できます（ボディは複製のたびに GetBody で作り直します）。
+type requestReplay struct {
+	reqs   []*http.Request
+	random bool
+	next   uint64 // sequential で次に送信する位置（アトミックに更新）
+}
+
+// newRequestReplay は、読み込み済みのリクエスト定義から requestReplay を生成します。定義がない場合は nil を返します。
+func newRequestReplay(specs []requestSpec, order string) (*requestReplay, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	rp := &requestReplay{random: order == requestOrderRandom}
+	for _, spec := range specs {
+		req, err := http.NewRequest(spec.Method, spec.URL, bytes.NewReader([]byte(spec.Body)))
+		if err != nil {
+			return nil, fmt.Errorf("request_file のリクエスト %s %s を初期化できません: %w", spec.Method, spec.URL, err)
+		}
+		if spec.Body == "" {
+			// ボディのないリクエストに Content-Length: 0 を付けないよう、空のボディは持たせません
+			req.Body, req.GetBody, req.ContentLength = http.NoBody, nil, 0
+		}
+		for name, value := range spec.Headers {
+			if strings.EqualFold(name, "Host") {
+				req.Host = value
+				continue
+			}
+			req.Header.Set(name, value)
+		}
+		rp.reqs = append(rp.reqs, req)
+	}
+	return rp, nil
+}
+
+// pick は、次に送信するベースリクエストを返します。
+func (rp *requestReplay) pick(rng *rand.Rand) *http.Request {
+	if rp.random {
+		return rp.reqs[rng.IntN(len(rp.reqs))]
+	}
+	i := atomic.AddUint64(&rp.next, 1) - 1
+	return rp.reqs[i%uint64(len(rp.reqs))]
+}
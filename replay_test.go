@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeRequestFile は、lines を1行ずつ書いたリクエストファイルを一時ディレクトリに作成し、そのパスを返します。
+func writeRequestFile(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "requests.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestRequestFileReplay は、request_file の各行のメソッド・パス・ヘッダー・ボディのとおりにリクエストが送信され、
+// sequential では先頭から順に、random ではすべての行が送信されることを確認します。
+func TestRequestFileReplay(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got := r.Method + " " + r.URL.RequestURI()
+		if r.Header.Get("X-Item") != "" || len(body) > 0 {
+			got += " " + r.Header.Get("X-Item") + " " + string(body)
+		}
+		mu.Lock()
+		received = append(received, got)
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	path := writeRequestFile(t,
+		"# 商品のシナリオ",
+		"GET "+server.URL+"/items?page=1",
+		"",
+		"POST "+server.URL+`/items {"headers":{"X-Item":"apple"},"body":"{\"name\":\"apple\"}"}`,
+		"DELETE "+server.URL+"/items/42",
+	)
+	want := []string{
+		"GET /items?page=1",
+		`POST /items apple {"name":"apple"}`,
+		"DELETE /items/42",
+	}
+
+	for _, order := range []string{requestOrderSequential, requestOrderRandom} {
+		t.Run(order, func(t *testing.T) {
+			mu.Lock()
+			received = nil
+			mu.Unlock()
+			concurrency := 1
+			if order == requestOrderRandom {
+				concurrency = 2
+			}
+			report := runTestLoad(newTestConfig(t, map[string]any{
+				"request_file":  path,
+				"request_order": order,
+				"concurrency":   concurrency,
+				"duration":      "200ms",
+				"no_preflight":  true,
+			}))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if report.Errors != 0 || len(received) < 10 {
+				t.Fatalf("errors=%d, %d 件しか届きません: %s", report.Errors, len(received), report.ErrorMsg)
+			}
+			counts := make(map[string]int)
+			for i, got := range received {
+				counts[got]++
+				if order == requestOrderSequential && got != want[i%len(want)] {
+					t.Fatalf("%d 件目 = %q, want %q（ファイルの先頭から順に送信するはずです）", i+1, got, want[i%len(want)])
+				}
+			}
+			for _, w := range want {
+				if counts[w] == 0 {
+					t.Errorf("%q が送信されていません: %v", w, counts)
+				}
+			}
+			if len(counts) != len(want) {
+				t.Errorf("届いたリクエスト = %v: ファイルに定義したリクエストだけが送信されるはずです", counts)
+			}
+		})
+	}
+}
+
+// TestRequestFileValidation は、request_file のすべての行を設定の読み込み時に検証し、問題のある行を行番号つきで報告することを確認します。
+func TestRequestFileValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"URLなし", []string{"GET http://127.0.0.1/ok", "GET"}, "2 行目"},
+		{"不正なメソッド", []string{"G(T http://127.0.0.1/"}, "HTTPメソッド"},
+		{"未知のオプション", []string{`POST http://127.0.0.1/ {"bodyy":"x"}`}, "オプション"},
+		{"余分なデータ", []string{`POST http://127.0.0.1/ {"body":"x"} {}`}, "余分なデータ"},
+		{"不正なヘッダー名", []string{`GET http://127.0.0.1/ {"headers":{"X Bad":"1"}}`}, "ヘッダー名"},
+		{"リクエストなし", []string{"# コメントだけ", ""}, "1件も定義されていません"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := loadRequestFile(writeRequestFile(t, tt.lines...))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q を含むエラー", err, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := loadRequestFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("存在しないファイルを読み込めました")
+	}
+
+	// 設定の読み込み時に検証するため、テストを開始する前に 400 として返します
+	body := `{"request_file":` + strconv.Quote(writeRequestFile(t, "GET http://127.0.0.1/", "PUT")) + `}`
+	if cfg, rec := postConfig(t, body, false); cfg != nil || rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "2 行目") {
+		t.Errorf("status=%d body=%q: 問題のある行を 400 で報告するはずです", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==============================================================================
+// [セクション62] トレースの再生: 記録した時刻どおりにリクエストを送信する (replay_trace / -replay)
+// ==============================================================================
+
+// rate_limit のオープンモデルは一定の到着レートしか表現できず、本番のトラフィックに特有の形（朝のピーク、
+// 数秒間のバースト、バッチ処理の一斉アクセスなど）は再現できません。replay_trace にトレースファイルのパスを指定すると、
+// ファイルに記録された各リクエストを、テスト開始からの記録どおりのオフセットで（応答を待たずに）送信します。
+// 時刻で駆動するオープンモデルのため、ターゲットが遅くなっても送信のペースは落ちません。
+// 通信中のリクエスト数は max_in_flight で制限し、上限に達した場合は overload_policy に従います（openmodel.go を参照）。
+// replay_speed に倍率を指定すると、時間軸を縮めて（2 なら2倍の速さで）再生します。
+//
+// 【フォーマット】 trace_out の出力と同じ NDJSON で、1行に1件、次のフィールドを持つJSONオブジェクトです。
+//   o : 再生開始からの送信時刻（ナノ秒。必須）
+//   m : メソッド（省略時はテストの method）
+//   u : URL（省略時はテストの target_url）
+// 他のフィールド（trace_out の d・s・e など）は無視し、空行は読み飛ばします。行の順序は問わず、読み込み時に o で並べ替えます。
+// 最小の o が再生開始の時刻になります（先頭の空白の時間は詰めて再生します）。
+// trace_out で記録したファイルはそのまま再生できるほか、アクセスログの時刻とパスから m・u を付けて生成することもできます。
+//   {"o":0,"m":"GET","u":"https://example.com/items"}
+//   {"o":1500000,"m":"POST","u":"https://example.com/orders"}
+//   {"o":1500000}
+//
+// duration を省略した場合は、トレースの最後のリクエストまでを再生し終える時間（に応答を待つ timeout を加えた時間）を
+// 実行時間とします。duration を指定した場合は、その時間までに記録されたリクエストだけを再生します。
+// 最後のリクエストの応答を受け取った時点でテストを終了します。
+// 送信が記録時刻からどれだけ遅れたか（replay_mean_lag_ms・replay_max_lag_ms）をレポートに記録するため、
+// テスター側が記録どおりのペースで送信できたかを確認できます。
+//
+// -replay <trace> を指定すると、サーバーを起動せずにトレースを再生し、レポートを標準出力へ書き出して終了します。
+// 送信先は -replay-target（省略時はトレースの最初の u）、倍率は -replay-speed で指定します。
+
+// replayLagWarnThreshold は、送信の平均の遅れがこれ以上の場合に、記録どおりのペースで再生できなかったことを警告する閾値です。
+const replayLagWarnThreshold = 10 * time.Millisecond
+
+// traceEvent は、トレースファイルの1行に記録された1件のリクエストです。
+type traceEvent struct {
+	at  time.Duration // 再生開始からの送信時刻（replay_speed を適用する前の値）
+	req int           // 送信するリクエスト（traceReplay.requests のインデックス）
+}
+
+// traceRequest は、再生するリクエストのメソッドとURLの組です（同じ組は1つにまとめます）。
+type traceRequest struct {
+	method, url string
+}
+
+// traceReplay は、読み込んだトレースと、再生中の送信の遅れの集計です。
+type traceReplay struct {
+	events   []traceEvent
+	requests []traceRequest
+
+	// 以下はディスパッチャーだけが更新し、テスト終了後に読み出します
+	dispatched int
+	totalLag   time.Duration
+	maxLag     time.Duration
+}
+
+// traceLine は、トレースファイルの1行のうち、再生に使うフィールドです。
+type traceLine struct {
+	Offset *int64 `json:"o"`
+	Method string `json:"m"`
+	URL    string `json:"u"`
+}
+
+// loadReplayTrace は、path のトレースファイルを読み込み、すべての行を検証して送信時刻の順に並べて返します。
+// method と target は、m・u を省略した行に使うテストのメソッドとURLです。
+func loadReplayTrace(path, method, target string) (*traceReplay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("replay_trace を開けません: %w", err)
+	}
+	defer f.Close()
+
+	replay := &traceReplay{}
+	index := make(map[traceRequest]int)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRequestLineBytes)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var tl traceLine
+		if err := json.Unmarshal([]byte(line), &tl); err != nil {
+			return nil, fmt.Errorf("replay_trace の %d 行目をJSONとして解釈できません: %v", lineNo, err)
+		}
+		if tl.Offset == nil || *tl.Offset < 0 {
+			return nil, fmt.Errorf("replay_trace の %d 行目: o（送信時刻のナノ秒）に0以上の値を指定してください", lineNo)
+		}
+		r := traceRequest{method: strings.ToUpper(tl.Method), url: tl.URL}
+		if r.method == "" {
+			r.method = method
+		}
+		if r.url == "" {
+			r.url = target
+		}
+		if !isValidMethod(r.method) {
+			return nil, fmt.Errorf("replay_trace の %d 行目: HTTPメソッドとして使用できない文字列です: %q", lineNo, r.method)
+		}
+		if u, err := url.Parse(r.url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("replay_trace の %d 行目: http または https の URL を指定してください: %q", lineNo, r.url)
+		}
+		i, ok := index[r]
+		if !ok {
+			i = len(replay.requests)
+			index[r] = i
+			replay.requests = append(replay.requests, r)
+		}
+		replay.events = append(replay.events, traceEvent{at: time.Duration(*tl.Offset), req: i})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("replay_trace の読み込みに失敗しました: %w", err)
+	}
+	if len(replay.events) == 0 {
+		return nil, fmt.Errorf("replay_trace にリクエストが1件も記録されていません: %s", path)
+	}
+
+	// trace_out は完了順に書き込まれるため、送信時刻の順に並べ替え、最初の送信を再生開始の時刻にそろえます
+	slices.SortStableFunc(replay.events, func(a, b traceEvent) int { return cmp.Compare(a.at, b.at) })
+	first := replay.events[0].at
+	for i := range replay.events {
+		replay.events[i].at -= first
+	}
+	return replay, nil
+}
+
+// firstTraceURL は、path のトレースファイルで最初に u が記録された行のURLを返します（見つからない場合は空文字）。
+func firstTraceURL(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRequestLineBytes)
+	for scanner.Scan() {
+		var tl traceLine
+		if json.Unmarshal(scanner.Bytes(), &tl) == nil && tl.URL != "" {
+			return tl.URL
+		}
+	}
+	return ""
+}
+
+// span は、最初のリクエストから最後のリクエストまでの、speed 倍で再生した場合の時間を返します。
+func (r *traceReplay) span(speed float64) time.Duration {
+	return time.Duration(float64(r.events[len(r.events)-1].at) / speed)
+}
+
+// executeTraceDispatcher は、トレースのディスパッチャーとして、記録された送信時刻どおりにリクエストを発生させます。
+// 送信したリクエストのGoroutineも wg で管理するため、テスト終了時には通信中のリクエストの中断まで待機できます。
+// rng は、inject_latency のばらつきを引く、seed から導出した乱数生成器です。
+func executeTraceDispatcher(ctx context.Context, wg *sync.WaitGroup, client *http.Client, cfg *TestConfig, metrics *ResultMetrics, rng *rand.Rand) {
+	defer wg.Done()
+
+	replay := cfg.traceReplay
+	bases := make([]*http.Request, len(replay.requests))
+	for i, r := range replay.requests {
+		baseReq, err := newBaseRequest(context.Background(), r.method, r.url, cfg)
+		if err != nil {
+			metrics.errLog.printf("[Replay Error]", "リクエストの初期化に失敗しました (%s %s): %v", r.method, r.url, err)
+			return
+		}
+		bases[i] = baseReq
+	}
+	traceCtx := newTraceContext(ctx, metrics)
+	cb := newCacheBuster(cfg.CacheBust, 0)
+	sem := make(chan struct{}, cfg.MaxInFlight)
+
+	log.Printf("[Replay] トレースを再生します: %d 件 (%d 種類のリクエスト), 再生時間: %s, 倍率: %gx, 通信中の上限: %d\n",
+		len(replay.events), len(replay.requests), replay.span(cfg.ReplaySpeed).Round(time.Millisecond), cfg.ReplaySpeed, cfg.MaxInFlight)
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	start := time.Now()
+	for _, ev := range replay.events {
+		at := time.Duration(float64(ev.at) / cfg.ReplaySpeed)
+		if wait := time.Until(start.Add(at)); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				return
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+
+		// 記録どおりの時刻からの遅れを集計します（前のリクエストの発生が遅れた場合は、待たずに続けて送信して追いつきます）
+		lag := max(time.Since(start)-at, 0)
+		replay.dispatched++
+		replay.totalLag += lag
+		replay.maxLag = max(replay.maxLag, lag)
+
+		if !acquireInFlight(ctx, sem, cfg, metrics) {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		// ディスパッチャー自身が wg のカウントを保持しているため、ここでの Add が Wait と競合することはありません
+		wg.Add(1)
+		go func(baseReq *http.Request, injectDelay time.Duration) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			sendRequest(ctx, traceCtx, client, baseReq, cb, injectDelay, cfg, metrics, false)
+		}(bases[ev.req], cfg.inject.delay(rng))
+	}
+}
+
+// fillReport は、再生の結果をレポートに記録し、記録どおりのペースで再生できなかった場合は警告を返します（問題がない場合は空文字）。
+func (r *traceReplay) fillReport(report *TestReport, path string) string {
+	if r == nil {
+		return ""
+	}
+	report.ReplayTrace = path
+	report.ReplayEvents = len(r.events)
+	report.ReplayDispatched = r.dispatched
+	if r.dispatched == 0 {
+		return ""
+	}
+	meanLag := r.totalLag / time.Duration(r.dispatched)
+	report.ReplayMeanLagMs = float64(meanLag) / float64(time.Millisecond)
+	report.ReplayMaxLagMs = float64(r.maxLag) / float64(time.Millisecond)
+	if meanLag < replayLagWarnThreshold {
+		return ""
+	}
+	return fmt.Sprintf("トレースの送信が記録時刻から平均 %.1fms 遅れました（最大 %.1fms）。テスター側の CPU や max_in_flight が不足し、記録どおりのペースで再生できていない可能性があります",
+		report.ReplayMeanLagMs, report.ReplayMaxLagMs)
+}
+
+// runReplayCommand は -replay サブコマンドのエントリーポイントです。
+// トレースを再生したレポートを標準出力へ、再生の要約を標準エラー出力へ書き出し、プロセスの終了コードを返します。
+// target が空の場合は、トレースの最初の u を送信先にします。
+func runReplayCommand(path, target string, speed float64) int {
+	// API経由のテストと同じ既定値と検証を適用するため、設定はJSONから readTestConfig で組み立てます
+	body, _ := json.Marshal(map[string]any{
+		"target_url":   target,
+		"replay_trace": path,
+		"replay_speed": speed,
+		"quiet":        true,
+	})
+	rec := httptest.NewRecorder()
+	cfg, ok := readTestConfig(rec, httptest.NewRequest(http.MethodPost, "/api/run", strings.NewReader(string(body))))
+	if !ok {
+		var failed TestReport
+		json.Unmarshal(rec.Body.Bytes(), &failed)
+		fmt.Fprintf(os.Stderr, "[Replay Error] %s\n", failed.ErrorMsg)
+		return 2
+	}
+
+	report := runLoadTest(context.Background(), cfg, NewResultMetrics(estimateTotalRequests(cfg)))
+	if err := writeReportOutput(report, cfg.TargetURL); err != nil {
+		fmt.Fprintf(os.Stderr, "[Replay Error] レポートの出力に失敗しました: %v\n", err)
+		return 1
+	}
+	if report.ErrorMsg != "" {
+		fmt.Fprintf(os.Stderr, "[Replay Error] 再生を完了できませんでした: %s\n", report.ErrorMsg)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "[Replay] %d / %d 件を送信しました (送信の遅れ: 平均 %.2fms, 最大 %.2fms, エラー: %d 件)\n",
+		report.ReplayDispatched, report.ReplayEvents, report.ReplayMeanLagMs, report.ReplayMaxLagMs, report.Errors)
+	if report.ReplayDispatched < report.ReplayEvents {
+		fmt.Fprintln(os.Stderr, "[Replay] 注意: 実行時間内に送信しきれなかったリクエストがあります")
+		return 1
+	}
+	return 0
+}
+
+// resolveReplayTrace は、replay_trace が指定されている場合にトレースを読み込んで検証し、cfg.traceReplay を設定します。
+// target_url を省略した場合は、トレースの最初の u を代表のURLにします。
+func resolveReplayTrace(cfg *TestConfig) error {
+	if cfg.ReplayTrace == "" {
+		if cfg.ReplaySpeed != 0 {
+			return errors.New("replay_speed を指定する場合は replay_trace も指定してください")
+		}
+		return nil
+	}
+	if len(cfg.Targets) > 0 || len(cfg.requestSpecs) > 0 || (cfg.Mode != "" && cfg.Mode != modeHTTP) ||
+		cfg.LoadModel != "" || cfg.RateLimit > 0 || cfg.Adaptive || cfg.Forever || cfg.IsolatedClients {
+		return errors.New("replay_trace はHTTPモードでのみ使用でき、targets・request_file・load_model・rate_limit・adaptive・forever・isolated_clients とは併用できません")
+	}
+	if cfg.ReplaySpeed == 0 {
+		cfg.ReplaySpeed = 1
+	}
+	if cfg.ReplaySpeed < 0 {
+		return errors.New("replay_speed には0より大きい倍率を指定してください")
+	}
+	if cfg.TargetURL == "" {
+		cfg.TargetURL = firstTraceURL(cfg.ReplayTrace)
+	}
+	replay, err := loadReplayTrace(cfg.ReplayTrace, cmp.Or(strings.ToUpper(cfg.Method), "GET"), cfg.TargetURL)
+	if err != nil {
+		return err
+	}
+	cfg.traceReplay = replay
+	return nil
+}
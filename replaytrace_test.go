@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestReplayTraceTiming は、順序を入れ替えて記録した小さなトレースを再生すると、各リクエストが記録どおりのオフセット
+// （replay_speed=2 ではその半分）でターゲットに届き、すべて送信し終えた時点でテストが終了することを確認します。
+func TestReplayTraceTiming(t *testing.T) {
+	offsets := []time.Duration{0, 150 * time.Millisecond, 400 * time.Millisecond, 700 * time.Millisecond}
+	const tolerance = 60 * time.Millisecond
+
+	for _, speed := range []float64{1, 2} {
+		t.Run(fmt.Sprintf("replay_speed=%v", speed), func(t *testing.T) {
+			var mu sync.Mutex
+			arrivals := make(map[string]time.Time)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				arrivals[r.Method+" "+r.URL.Path] = time.Now()
+				mu.Unlock()
+			}))
+			t.Cleanup(server.Close)
+
+			// 読み込み時に o で並べ替えるため、ファイル内の順序は記録時刻と一致していなくても構いません
+			var lines []byte
+			for _, i := range []int{2, 0, 3, 1} {
+				method := http.MethodGet
+				if i%2 == 1 {
+					method = http.MethodPost
+				}
+				lines = fmt.Appendf(lines, "{\"o\":%d,\"m\":%q,\"u\":%q,\"s\":200}\n", offsets[i].Nanoseconds(), method, fmt.Sprintf("%s/%d", server.URL, i))
+			}
+			path := filepath.Join(t.TempDir(), "trace.ndjson")
+			if err := os.WriteFile(path, lines, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			report := runTestLoad(newTestConfig(t, map[string]any{
+				"target_url":   server.URL,
+				"replay_trace": path,
+				"replay_speed": speed,
+				"no_preflight": true,
+			}))
+			elapsed := time.Since(start)
+			if report.ReplayEvents != len(offsets) || report.ReplayDispatched != len(offsets) || report.TotalRequests != len(offsets) || report.Errors != 0 {
+				t.Fatalf("replay_events=%d replay_dispatched=%d total=%d errors=%d error_msg=%q",
+					report.ReplayEvents, report.ReplayDispatched, report.TotalRequests, report.Errors, report.ErrorMsg)
+			}
+			last := time.Duration(float64(offsets[len(offsets)-1]) / speed)
+			if elapsed > last+500*time.Millisecond {
+				t.Errorf("テストに %v かかりました: 最後のリクエスト（%v）の応答を受け取った時点で終了するはずです", elapsed, last)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			first := arrivals["GET /0"]
+			if first.IsZero() {
+				t.Fatalf("最初のリクエストが届いていません（受信: %v）", arrivals)
+			}
+			for i, offset := range offsets {
+				method := http.MethodGet
+				if i%2 == 1 {
+					method = http.MethodPost
+				}
+				at, ok := arrivals[fmt.Sprintf("%s /%d", method, i)]
+				if !ok {
+					t.Errorf("%s /%d が届いていません（受信: %v）", method, i, arrivals)
+					continue
+				}
+				want := time.Duration(float64(offset) / speed)
+				if got := at.Sub(first); got < want-tolerance || got > want+tolerance {
+					t.Errorf("/%d の到着 = 再生開始から %v, want %v ± %v", i, got, want, tolerance)
+				}
+			}
+		})
+	}
+}
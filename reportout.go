@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ==============================================================================
+// [セクション73] レポートの gzip 圧縮: 長時間のテストの大きなレポートを圧縮して書き出す (-o json.gz / -out)
+// ==============================================================================
+
+// 長時間のソークテストのレポートは、1秒ごとのタイムラインやエンドポイント別の集計などで数十MBになることがあり、
+// そのまま保存したり転送したりすると場所と時間を取ります。テキストのレポートは圧縮がよく効くため、
+// -merge・-import・-selftest・-replay が書き出すレポートを gzip で圧縮できるようにしています。
+//
+//   -o json.gz（hey.gz・wrk.gz も同様）: フォーマットの末尾の .gz で、そのフォーマットのレポートを圧縮して書き出します
+//   -out report.json.gz                : レポートを標準出力ではなくファイルへ書き出します（拡張子が .gz の場合は圧縮します）
+//
+// 圧縮したレポートは、gunzip や zcat でそのまま元のレポートに戻せます（圧縮しない場合と同じ内容です）。
+// gzip のフッター（CRC とサイズ）は Close で書き出されるため、書き出しに失敗した場合だけでなく、
+// Close に失敗した場合もエラーとして扱います（末尾が欠けたファイルは展開時に unexpected EOF になるためです）。
+// /api/run?format= は対象外です（HTTP では Accept-Encoding による圧縮の方が適しているためです）。
+
+// gzipSuffix は、圧縮して書き出すことを表すフォーマットとファイル名の末尾です。
+const gzipSuffix = ".gz"
+
+// outputPath は、レポートを書き出すファイルです（-out フラグ。空の場合は標準出力）。
+// outputGzip は、レポートを gzip で圧縮するかどうかです（-o の .gz、または -out の拡張子から決まります）。
+var (
+	outputPath string
+	outputGzip bool
+)
+
+// parseOutputFlags は、-o の末尾の .gz と -out の拡張子から outputGzip を決め、outputFormat から .gz を取り除きます。
+func parseOutputFlags() {
+	outputFormat, outputGzip = strings.CutSuffix(outputFormat, gzipSuffix)
+	if strings.HasSuffix(outputPath, gzipSuffix) {
+		outputGzip = true
+	}
+}
+
+// writeReportOutput は、report を -o のフォーマットで -out のファイル（未指定の場合は標準出力）へ書き出します。
+// 圧縮する場合は gzip.Writer を Close してフッターまで書き出してから戻ります。
+func writeReportOutput(report *TestReport, target string) (err error) {
+	var dst io.Writer = os.Stdout
+	if outputPath != "" {
+		f, err := os.Create(outputPath)
+		if err != nil {
+			return fmt.Errorf("出力ファイルを作成できません: %w", err)
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil && cerr != nil {
+				err = fmt.Errorf("出力ファイルを閉じられません: %w", cerr)
+			}
+		}()
+		dst = f
+	}
+	if !outputGzip {
+		return writeReport(dst, report, outputFormat, target)
+	}
+
+	// 小さな書き込みが続く hey・wrk 形式でも圧縮の効率が落ちないよう、バッファを挟んでから圧縮します
+	zw := gzip.NewWriter(dst)
+	bw := bufio.NewWriter(zw)
+	if err := writeReport(bw, report, outputFormat, target); err != nil {
+		zw.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		zw.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("gzip の圧縮を完了できません: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestWriteReportOutputGzip は、-o json.gz（hey.gz・wrk.gz も同様）や拡張子 .gz の -out で書き出したレポートが、
+// 展開すると圧縮しない場合と同じ内容になり、.gz の付かない -out では圧縮されないことを確認します。
+func TestWriteReportOutputGzip(t *testing.T) {
+	savedFormat, savedPath, savedGzip := outputFormat, outputPath, outputGzip
+	t.Cleanup(func() { outputFormat, outputPath, outputGzip = savedFormat, savedPath, savedGzip })
+
+	report := &TestReport{
+		TotalRequests:     101,
+		ActualDurationSec: 2.5,
+		ThroughputRPS:     40.4,
+		StatusCodes:       map[string]uint64{"200": 98, "500": 2, "NetworkError": 1},
+		ErrorKinds:        map[string]uint64{"dial_error": 1},
+		latency:           latencyDurations(heyReferenceLatencies()),
+	}
+	const target = "http://example.com/"
+	dir := t.TempDir()
+
+	tests := []struct {
+		format, path string
+		wantFormat   string
+		wantGzip     bool
+	}{
+		{"json.gz", "report.out", outputFormatJSON, true},
+		{"hey.gz", "report.out", outputFormatHey, true},
+		{"wrk.gz", "report.out", outputFormatWrk, true},
+		{"json", "report.json.gz", outputFormatJSON, true},
+		{"json", "report.json", outputFormatJSON, false},
+	}
+	for _, tt := range tests {
+		outputFormat, outputPath = tt.format, filepath.Join(dir, tt.path)
+		parseOutputFlags()
+		if outputFormat != tt.wantFormat || outputGzip != tt.wantGzip {
+			t.Errorf("-o %s -out %s: format=%q gzip=%v, want %q %v", tt.format, tt.path, outputFormat, outputGzip, tt.wantFormat, tt.wantGzip)
+			continue
+		}
+		if err := writeReportOutput(report, target); err != nil {
+			t.Fatalf("-o %s -out %s: %v", tt.format, tt.path, err)
+		}
+
+		var want bytes.Buffer
+		if err := writeReport(&want, report, tt.wantFormat, target); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := raw
+		if tt.wantGzip {
+			zr, err := gzip.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("-o %s -out %s: gzip として読めません: %v", tt.format, tt.path, err)
+			}
+			// ReadAll がエラーなく終われば、フッターの CRC とサイズまで書き出されています
+			if got, err = io.ReadAll(zr); err != nil {
+				t.Fatalf("-o %s -out %s: 展開できません: %v", tt.format, tt.path, err)
+			}
+		}
+		if !bytes.Equal(got, want.Bytes()) {
+			t.Errorf("-o %s -out %s: 展開したレポートが圧縮しない場合と異なります\ngot:\n%s\nwant:\n%s", tt.format, tt.path, got, want.Bytes())
+		}
+		os.Remove(outputPath)
+	}
+}
+
+// TestWriteReportOutputGzipSmaller は、タイムラインの長いレポートを圧縮すると、圧縮しない場合よりも小さくなることを確認します。
+func TestWriteReportOutputGzipSmaller(t *testing.T) {
+	savedFormat, savedPath, savedGzip := outputFormat, outputPath, outputGzip
+	t.Cleanup(func() { outputFormat, outputPath, outputGzip = savedFormat, savedPath, savedGzip })
+
+	report := &TestReport{TotalRequests: 3600 * 100, latency: latencyDurations([]time.Duration{time.Millisecond})}
+	for range 3600 {
+		report.RPSTimeline = append(report.RPSTimeline, 100)
+	}
+
+	sizes := map[bool]int64{}
+	for _, compress := range []bool{false, true} {
+		outputFormat, outputPath = outputFormatJSON, filepath.Join(t.TempDir(), "report.json")
+		if compress {
+			outputPath += gzipSuffix
+		}
+		parseOutputFlags()
+		if err := writeReportOutput(report, ""); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes[compress] = info.Size()
+	}
+	if sizes[true]*10 > sizes[false] {
+		t.Errorf("圧縮後 %d バイト, 圧縮前 %d バイト: 1/10 以下になるはずです", sizes[true], sizes[false])
+	}
+}
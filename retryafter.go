@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション22] Retry-After の尊重: 429 を返したターゲットへの送信の一時停止
+// ==============================================================================
+
+// レート制限のあるAPIが 429 Too Many Requests と Retry-After を返しているのに送信を続けても、
+// 429 が積み上がるだけで計測結果が汚れてしまいます。honor_retry_after を指定すると、
+// 429 を受け取ったワーカーは Retry-After が示す時間だけ次の送信を控えます（テスト終了時はただちに中断します）。
+// Retry-After がない、または解釈できない 429 では停止しません。
+// 429 の件数（rate_limited）と、全ワーカーの待機時間の合計（backoff_total_sec）をレポートに記録します。
+
+// maxRetryAfter は、1回の待機時間の上限です。異常に長い Retry-After でワーカーがテストの残り時間を丸ごと失わないようにします。
+const maxRetryAfter = 5 * time.Minute
+
+// parseRetryAfter は、Retry-After ヘッダーの値（秒数またはHTTP日付）を待機時間に変換します。
+// 解釈できない場合は ok=false を返します。過去の日付は0として扱います。
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return min(time.Duration(secs)*time.Second, maxRetryAfter), true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return min(max(at.Sub(now), 0), maxRetryAfter), true
+}
+
+// honorRetryAfter は、429 の応答を記録し、Retry-After が示す時間だけ待機します。
+// 待機中に ctx がキャンセルされた場合は false を返します。
+func honorRetryAfter(ctx context.Context, metrics *ResultMetrics, header http.Header) bool {
+	atomic.AddUint64(&metrics.RateLimited, 1)
+
+	d, ok := parseRetryAfter(header.Get("Retry-After"), time.Now())
+	if !ok || d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	defer func() { atomic.AddInt64(&metrics.BackoffNanos, int64(time.Since(start))) }()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
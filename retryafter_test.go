@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestHonorRetryAfter は、429 と Retry-After を返すターゲットに対し、honor_retry_after を指定したワーカーが
+// Retry-After の時間だけ次の送信を控え、429 の件数と待機時間の合計がレポートに記録されることを確認します。
+func TestHonorRetryAfter(t *testing.T) {
+	run := func(honor bool) (*TestReport, int64) {
+		var received atomic.Int64
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received.Add(1)
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+		report := runTestLoad(newTestConfig(t, map[string]any{
+			"target_url":        server.URL,
+			"concurrency":       2,
+			"duration":          "1500ms",
+			"honor_retry_after": honor,
+			"no_preflight":      true,
+		}))
+		return report, received.Load()
+	}
+
+	// 各ワーカーは 0 秒と 1 秒の時点で送信し、その後は 2 秒の時点まで待機します
+	report, received := run(true)
+	if received < 2 || received > 4 {
+		t.Errorf("Retry-After を尊重した場合に %d 件届きました, want 2〜4 件", received)
+	}
+	if report.RateLimited != uint64(received) {
+		t.Errorf("rate_limited = %d, want %d", report.RateLimited, received)
+	}
+	if report.BackoffTotalSec < 2 {
+		t.Errorf("backoff_total_sec = %.2f: 2ワーカーが 1 秒ずつ以上待機したはずです", report.BackoffTotalSec)
+	}
+
+	if _, received := run(false); received < 50 {
+		t.Errorf("honor_retry_after なしで %d 件しか届きません: 待機せずに送信し続けるはずです", received)
+	}
+}
+
+// TestParseRetryAfter は、Retry-After の秒数とHTTP日付の解釈、上限での頭打ち、解釈できない値の扱いを確認します。
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"3", 3 * time.Second, true},
+		{" 0 ", 0, true},
+		{"86400", maxRetryAfter, true},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"", 0, false},
+		{"-1", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
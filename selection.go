@@ -0,0 +1,136 @@
+package main
+
+import (
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// ==============================================================================
+// [セクション69] パーセンタイルの選択: 数百万件のサンプルを全件ソートせずにパーセンタイルを求める
+// ==============================================================================
+
+// リザーバーサンプリングを使わない長時間のテストでは、レポートの生成時に数千万件のレイテンシを並べ替えることになり、
+// 全件のソート（O(n log n)）だけで数秒かかって、テストの終了からレポートが返るまでの間が空いてしまいます。
+// ところが、レポートに必要なのは p50・p90・p99・tail_percentiles の各値と、トリム平均で取り除く両端の境界だけです。
+// サンプルが selectionThreshold 件を超える場合は、全件をソートする代わりに、必要な位置（インデックス）だけを
+// 3分割のクイックセレクトで確定させます（必要な位置の数を k として、平均 O(n log k)）。
+//
+// orderLatencies の後は、指定した各位置 i について latencies[i] がソートした場合と同じ値になり、
+// 位置 i より前にはそれ以下の値だけが、後ろにはそれ以上の値だけが並びます（位置同士の間はソートされていません）。
+// そのため、ソート済みのスライスの位置を参照する tailPercentiles・trimmedMean は変更なしに同じ値を返します。
+// ソート済みであることを前提に二分探索していた apdexScore は、全件を数える方式に変えています。
+// しきい値以下の件数では、従来どおり全件をソートします（小さいスライスでは slices.Sort の方が速いためです）。
+
+// selectionThreshold は、全件のソートの代わりに必要な位置だけを選択するサンプル数の下限（この値自体は含みません）です。
+const selectionThreshold = 1 << 20
+
+// selectionInsertionCutoff は、選択の範囲がこの件数以下になったら、残りをソートして確定させる件数です。
+const selectionInsertionCutoff = 32
+
+// summaryQuantiles は、LatencySummary に記録するパーセンタイル（p50・p90・p99）の割合です。
+var summaryQuantiles = []float64{0.50, 0.90, 0.99}
+
+// percentileIndex は、n 件のサンプルを昇順に並べたときに割合 q（0〜1）のパーセンタイルとして参照する位置を返します。
+// summarizeWithStats・tailPercentiles・orderLatencies はすべてこの位置を使います。
+func percentileIndex(n int, q float64) int {
+	return min(int(float64(n)*q), n-1)
+}
+
+// orderLatencies は、p50・p90・p99、percentiles（%）の各パーセンタイルと、両端から trimFraction の割合ずつを取り除く
+// トリム平均の境界の位置が、ソートした場合と同じ値になるよう latencies をその場で並べ替えます。
+// サンプルが selectionThreshold 件以下の場合は全件をソートします。
+func orderLatencies(latencies []time.Duration, percentiles []float64, trimFraction float64) {
+	n := len(latencies)
+	if n <= selectionThreshold {
+		slices.Sort(latencies)
+		return
+	}
+	indices := make([]int, 0, len(summaryQuantiles)+len(percentiles)+2)
+	for _, q := range summaryQuantiles {
+		indices = append(indices, percentileIndex(n, q))
+	}
+	for _, q := range percentiles {
+		indices = append(indices, percentileIndex(n, q/100))
+	}
+	if cut := int(float64(n) * trimFraction); cut > 0 && cut < n-cut {
+		// 取り除く下側の cut 件と上側の cut 件が、それぞれ両端に集まるよう境界の内側の位置を確定させます
+		indices = append(indices, cut, n-cut-1)
+	}
+	slices.Sort(indices)
+	selectIndices(latencies, slices.Compact(indices))
+}
+
+// selectIndices は、昇順で重複のない indices の各位置 i について、s[i] がソートした場合と同じ値になるよう s を並べ替えます。
+// 中央の位置を確定させてから、その左右を独立に処理します（確定させた位置より左の並べ替えは、右側に影響しません）。
+func selectIndices(s []time.Duration, indices []int) {
+	if len(indices) == 0 {
+		return
+	}
+	mid := len(indices) / 2
+	k := indices[mid]
+	selectNth(s, k)
+	selectIndices(s[:k], indices[:mid])
+
+	right := indices[mid+1:]
+	if len(right) == 0 {
+		return
+	}
+	shifted := make([]int, len(right))
+	for i, idx := range right {
+		shifted[i] = idx - (k + 1)
+	}
+	selectIndices(s[k+1:], shifted)
+}
+
+// selectNth は、s[k] がソートした場合と同じ値になり、s[:k] にはそれ以下、s[k+1:] にはそれ以上の値が並ぶよう s を並べ替えます。
+// 同じ値が大量に並ぶ分布（タイマーの分解能で丸められたレイテンシなど）でも遅くならないよう、3分割で分割します。
+func selectNth(s []time.Duration, k int) {
+	lo, hi := 0, len(s)
+	for hi-lo > selectionInsertionCutoff {
+		// 偏った並び（ソート済みに近いなど）で最悪の計算量にならないよう、ランダムな3点の中央値を基準値にします
+		pivot := medianOf3(s[lo+rand.IntN(hi-lo)], s[lo+rand.IntN(hi-lo)], s[lo+rand.IntN(hi-lo)])
+		lt, gt := partition3(s[lo:hi], pivot)
+		lt, gt = lo+lt, lo+gt
+		switch {
+		case k < lt:
+			hi = lt
+		case k >= gt:
+			lo = gt
+		default:
+			// k は基準値と等しい値の範囲にあるため、確定しています
+			return
+		}
+	}
+	slices.Sort(s[lo:hi])
+}
+
+// partition3 は、s を基準値 pivot より小さい値・等しい値・大きい値の順に並べ替え、等しい値の範囲 [lt, gt) を返します。
+func partition3(s []time.Duration, pivot time.Duration) (lt, gt int) {
+	lt, i, gt := 0, 0, len(s)
+	for i < gt {
+		switch {
+		case s[i] < pivot:
+			s[lt], s[i] = s[i], s[lt]
+			lt++
+			i++
+		case s[i] > pivot:
+			gt--
+			s[i], s[gt] = s[gt], s[i]
+		default:
+			i++
+		}
+	}
+	return lt, gt
+}
+
+// medianOf3 は、3つの値の中央値を返します。
+func medianOf3(a, b, c time.Duration) time.Duration {
+	if a > b {
+		a, b = b, a
+	}
+	if b > c {
+		b = c
+	}
+	return max(a, b)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+// selectionInputs は、選択のテストとベンチマークで使う n 件のレイテンシの分布です。
+var selectionInputs = []struct {
+	name string
+	gen  func(r *rand.Rand, n int) []time.Duration
+}{
+	{"一様", func(r *rand.Rand, n int) []time.Duration {
+		s := make([]time.Duration, n)
+		for i := range s {
+			s[i] = time.Duration(r.Int64N(int64(time.Second)))
+		}
+		return s
+	}},
+	{"重複が多い", func(r *rand.Rand, n int) []time.Duration {
+		// タイマーの分解能で丸められたレイテンシのように、少数の値だけが繰り返し現れる分布です
+		s := make([]time.Duration, n)
+		for i := range s {
+			s[i] = time.Duration(r.IntN(8)) * time.Millisecond
+		}
+		return s
+	}},
+	{"昇順", func(r *rand.Rand, n int) []time.Duration {
+		s := make([]time.Duration, n)
+		for i := range s {
+			s[i] = time.Duration(i)
+		}
+		return s
+	}},
+	{"降順", func(r *rand.Rand, n int) []time.Duration {
+		s := make([]time.Duration, n)
+		for i := range s {
+			s[i] = time.Duration(n - i)
+		}
+		return s
+	}},
+}
+
+// TestSelectIndicesMatchesSort は、無作為な件数・分布・位置の組み合わせについて、selectIndices で確定させた各位置の値が
+// ソートした場合と一致し、その位置の前にはそれ以下、後ろにはそれ以上の値だけが並ぶこと（要素の集合は変わらないこと）を確認します。
+func TestSelectIndicesMatchesSort(t *testing.T) {
+	seed := uint64(time.Now().UnixNano())
+	r := rand.New(rand.NewPCG(seed, seed))
+	for _, input := range selectionInputs {
+		for trial := range 200 {
+			n := 1 + r.IntN(5000)
+			s := input.gen(r, n)
+			sorted := slices.Sorted(slices.Values(s))
+
+			var indices []int
+			for range 1 + r.IntN(8) {
+				indices = append(indices, r.IntN(n))
+			}
+			slices.Sort(indices)
+			indices = slices.Compact(indices)
+
+			selectIndices(s, indices)
+
+			if !slices.Equal(slices.Sorted(slices.Values(s)), sorted) {
+				t.Fatalf("%s (seed=%d, 試行 %d, n=%d): 並べ替えで要素の集合が変わりました", input.name, seed, trial, n)
+			}
+			for _, k := range indices {
+				if s[k] != sorted[k] {
+					t.Fatalf("%s (seed=%d, 試行 %d, n=%d): s[%d] = %v, ソートした場合 %v", input.name, seed, trial, n, k, s[k], sorted[k])
+				}
+				if k > 0 && slices.Max(s[:k]) > s[k] || k < n-1 && slices.Min(s[k+1:]) < s[k] {
+					t.Fatalf("%s (seed=%d, 試行 %d, n=%d): 位置 %d の前後が分割されていません", input.name, seed, trial, n, k)
+				}
+			}
+		}
+	}
+}
+
+// TestOrderLatenciesMatchesSort は、selectionThreshold を超える件数で orderLatencies を通した場合に、
+// 要約統計とパーセンタイルが全件をソートした場合と同じ値になることを確認します。
+func TestOrderLatenciesMatchesSort(t *testing.T) {
+	if testing.Short() {
+		t.Skip("-short のため、大きなサンプルでの確認をスキップします")
+	}
+	r := rand.New(rand.NewPCG(1, 2))
+	percentiles := []float64{99.9, 99.99}
+	s := selectionInputs[0].gen(r, selectionThreshold+selectionThreshold/4)
+	sorted := slices.Sorted(slices.Values(s))
+
+	orderLatencies(s, percentiles, 0.01)
+	for _, q := range append(slices.Clone(summaryQuantiles), 0.999, 0.9999) {
+		k := percentileIndex(len(s), q)
+		if s[k] != sorted[k] {
+			t.Errorf("p%v = %v, ソートした場合 %v", q*100, s[k], sorted[k])
+		}
+	}
+	got, _ := tailPercentiles(s, percentiles)
+	want, _ := tailPercentiles(sorted, percentiles)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("tailPercentiles = %v, ソートした場合 %v", got, want)
+	}
+}
+
+// BenchmarkOrderLatencies は、レポートの生成時に必要な位置だけを選択する場合と、全件をソートする場合を比べます。
+//
+//	go test -run '^$' -bench OrderLatencies -benchtime 5x
+func BenchmarkOrderLatencies(b *testing.B) {
+	for _, n := range []int{1 << 20, 5_000_000} {
+		for _, input := range selectionInputs[:2] {
+			src := input.gen(rand.New(rand.NewPCG(1, 2)), n)
+			s := make([]time.Duration, n)
+			b.Run(fmt.Sprintf("n=%d/%s/select", n, input.name), func(b *testing.B) {
+				for range b.N {
+					copy(s, src)
+					// selectionThreshold に関係なく、選択のアルゴリズムを通します
+					indices := []int{percentileIndex(n, 0.5), percentileIndex(n, 0.9), percentileIndex(n, 0.99), percentileIndex(n, 0.999)}
+					selectIndices(s, indices)
+				}
+			})
+			b.Run(fmt.Sprintf("n=%d/%s/sort", n, input.name), func(b *testing.B) {
+				for range b.N {
+					copy(s, src)
+					slices.Sort(s)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// [セクション37] セルフテスト: このマシンでテスター自身が出せる最大RPSの計測 (-selftest)
+// ==============================================================================
+
+// 実際のターゲットに対する結果が頭打ちになったとき、それがターゲットの限界なのか、テスター（このマシンのCPUや
+// ネットワークスタック）の限界なのかを切り分ける必要があります。-selftest は、プロセス内に即座に 200 を返す
+// HTTPサーバー（httptest.Server）を起動し、通常と同じ経路（readTestConfig による既定値の適用から runLoadTest まで）で
+// 負荷をかけて、このマシンで生成できるRPSの上限を報告します。実際のテストのスループットがこの値に近い場合は、
+// テスター側がボトルネックになっている可能性が高く、複数マシンからの分散実行（-merge）を検討してください。
+//
+// サーバーも同じプロセスでCPUを消費するため、報告される値はテスター単体の上限よりやや低い、控えめな目安です。
+// 通信はループバックインターフェースのみで行い、外部へは一切送信しません。
+// -selftest-latency を指定した場合は、サーバーが指定した分布で応答を遅らせ、最大RPSの代わりに
+// レポートの統計を理論値と比較して検証します（selftestdist.go を参照）。
+
+// runSelfTest は -selftest サブコマンドのエントリーポイントです。
+// レポートをJSONとして標準出力へ、最大RPSの要約を標準エラー出力へ書き出し、プロセスの終了コードを返します。
+// dist が nil でない場合は、サーバーがその分布で応答を遅らせ、最大RPSの代わりに統計の検証結果を書き出します。
+// seed が負の場合は乱数のシード値をランダムに選びます（選んだ値はレポートの seed に記録されます）。
+func runSelfTest(duration time.Duration, concurrency int, dist *latencyDistribution, seed int64) int {
+	if seed < 0 {
+		// runLoadTest と同じく、ブラウザのJavaScriptで桁落ちせずに扱えるよう 2^53 未満にします
+		seed = int64(rand.Uint64() >> 11)
+	}
+	report, serverURL, err := selfTestLoad(duration, concurrency, dist, uint64(seed))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[SelfTest Error] %v\n", err)
+		return 2
+	}
+
+	if err := writeReportOutput(report, serverURL); err != nil {
+		fmt.Fprintf(os.Stderr, "[SelfTest Error] レポートの出力に失敗しました: %v\n", err)
+		return 1
+	}
+
+	if report.ErrorMsg != "" || report.TotalRequests == 0 {
+		fmt.Fprintf(os.Stderr, "[SelfTest Error] セルフテストを完了できませんでした: %s\n", report.ErrorMsg)
+		return 1
+	}
+	if dist != nil {
+		if err := checkLatencyDistribution(os.Stderr, dist, report); err != nil {
+			fmt.Fprintf(os.Stderr, "[SelfTest Error] %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "[SelfTest] このマシンで生成できる最大RPS（目安）: %.0f req/s (p99: %s, エラー: %d 件)\n", report.ThroughputRPS, report.P99Latency, report.Errors)
+	if report.Errors > 0 {
+		// ループバックのサーバーへのエラーは、fd の上限やエフェメラルポートの枯渇などテスター側の問題を示します
+		fmt.Fprintln(os.Stderr, "[SelfTest] 注意: ループバックへのリクエストでエラーが発生しました。fd の上限（ulimit -n）や並行数を見直してください")
+		return 1
+	}
+	return 0
+}
+
+// selfTestLoad は、ループバックのサーバーを起動して concurrency の並行数で duration の間負荷をかけ、
+// レポートとサーバーのURLを返します。dist が nil でない場合は、サーバーがその分布で応答を遅らせます。
+// ワーカーの乱数と応答の遅延は、どちらも seed から導出した乱数生成器から引きます。
+func selfTestLoad(duration time.Duration, concurrency int, dist *latencyDistribution, seed uint64) (*TestReport, string, error) {
+	if concurrency <= 0 {
+		// ループバックでは1リクエストの往復が非常に短いため、CPUを使い切るにはコア数の数倍の並行数が必要です
+		concurrency = runtime.NumCPU() * 16
+	}
+	if dist != nil {
+		dist.seed(seed)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if dist != nil {
+			time.Sleep(dist.sample())
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// API経由のテストと同じ既定値と検証を適用するため、設定はJSONから readTestConfig で組み立てます
+	body, _ := json.Marshal(map[string]any{
+		"target_url":  server.URL,
+		"concurrency": concurrency,
+		"duration":    duration.String(),
+		"seed":        seed,
+		"quiet":       true,
+	})
+	rec := httptest.NewRecorder()
+	cfg, ok := readTestConfig(rec, httptest.NewRequest(http.MethodPost, "/api/run", strings.NewReader(string(body))))
+	if !ok {
+		return nil, "", fmt.Errorf("設定の組み立てに失敗しました: %s", strings.TrimSpace(rec.Body.String()))
+	}
+
+	fmt.Fprintf(os.Stderr, "[SelfTest] ループバックのサーバー (%s) へ並行数 %d で %s 負荷をかけます (CPU: %d コア)\n", server.URL, concurrency, duration, runtime.NumCPU())
+	return runLoadTest(context.Background(), cfg, NewResultMetrics(estimateTotalRequests(cfg))), server.URL, nil
+}
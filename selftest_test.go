@@ -0,0 +1,31 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestSelfTestLoad は、セルフテストがループバックのサーバーへエラーなく負荷をかけ、即座に応答するサーバーに対して
+// 妥当な（1コアの環境でも数千 req/s 程度の）高いRPSを報告することを確認します。
+func TestSelfTestLoad(t *testing.T) {
+	report, serverURL, err := selfTestLoad(500*time.Millisecond, 0, nil, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ErrorMsg != "" || report.Errors != 0 {
+		t.Fatalf("errors=%d error_msg=%q: ループバックへのリクエストはエラーにならないはずです", report.Errors, report.ErrorMsg)
+	}
+	if serverURL == "" {
+		t.Error("ループバックのサーバーのURLが返されません")
+	}
+	if report.ThroughputRPS < 1000 {
+		t.Errorf("throughput_rps = %.0f: 即座に応答するループバックのサーバーに対しては 1000 req/s を超えるはずです", report.ThroughputRPS)
+	}
+	if want := float64(report.TotalRequests) / report.ActualDurationSec; math.Abs(report.ThroughputRPS-want) > want*0.05 {
+		t.Errorf("throughput_rps = %.0f, want 約 %.0f（総リクエスト数 %d / %.2f 秒）", report.ThroughputRPS, want, report.TotalRequests, report.ActualDurationSec)
+	}
+	if report.Seed != 7 {
+		t.Errorf("seed = %d, want 7", report.Seed)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==============================================================================
+// [セクション57] セルフテストの応答遅延の分布: 統計処理の正しさの検証 (-selftest-latency)
+// ==============================================================================
+
+// レポートのパーセンタイルが正しいかどうかは、本物のターゲットでは確かめようがありません（真の分布が分からないため）。
+// -selftest-latency を指定すると、-selftest のループバックのサーバーが、指定した分布に従う時間だけ待ってから応答します。
+// テストの終了後、レポートの平均・p50・p90・p99 を分布の理論値と並べて標準エラー出力に表示し、許容誤差を超えたものがあれば
+// 終了コード 1 で終了します。ソート・インデックス計算・リザーバーサンプリングなどの統計処理の正しさを、既知の分布で検証できます。
+//
+//   constant:20ms           常に 20ms
+//   uniform:10ms,30ms       10ms〜30ms の一様分布
+//   normal:20ms,5ms         平均 20ms・標準偏差 5ms の正規分布（負になった値は0とします）
+//   bimodal:5ms,50ms,0.1    90% が 5ms、10% が 50ms の二峰分布（キャッシュのヒットとミスなど）
+//
+// 計測されるレイテンシには、待機時間に加えてループバックの往復・タイマーの誤差・スケジューリングの遅れ（CPU が少ない
+// マシンでは p99 で 2〜3ms 程度）が上乗せされるため、許容誤差は理論値の5% + 3ms としています。
+// 並行数が CPU コア数に比べて多すぎると、スケジューリングの遅れで計測値が上振れするため、
+// 検証が失敗する場合は -selftest-concurrency を下げてください。
+// bimodal では、パーセンタイルが2つの値の境目（たとえば 0.1 に対する p90）に当たると、どちらの値にもなり得るため、
+// 境目から離れた割合を指定してください。
+
+// selfTestTolerance は、理論値に対する許容誤差の割合です（これに selfTestToleranceFloor を加えます）。
+const selfTestTolerance = 0.05
+
+// selfTestToleranceFloor は、ループバックの往復・タイマーの誤差・スケジューリングの遅れを見込んだ、許容誤差の下限です。
+const selfTestToleranceFloor = 3 * time.Millisecond
+
+// latencyDistribution は、セルフテストのサーバーが応答を遅らせる時間の分布です。
+type latencyDistribution struct {
+	kind string
+	a, b time.Duration // constant: a / uniform: 下限 a・上限 b / normal: 平均 a・標準偏差 b / bimodal: 速い値 a・遅い値 b
+	frac float64       // bimodal で遅い値になる割合
+
+	// rng は、待機時間を引く乱数生成器です（seed で設定します）。サーバーのハンドラーから並行に呼ばれるため mu で保護します
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// parseLatencyDistribution は、"normal:20ms,5ms" のような分布の指定を解釈します。
+func parseLatencyDistribution(spec string) (*latencyDistribution, error) {
+	kind, rawParams, _ := strings.Cut(spec, ":")
+	var params []string
+	if rawParams != "" {
+		params = strings.Split(rawParams, ",")
+	}
+
+	arity := map[string]int{"constant": 1, "uniform": 2, "normal": 2, "bimodal": 3}
+	n, ok := arity[kind]
+	if !ok {
+		return nil, fmt.Errorf("未知の分布です: %q (constant / uniform / normal / bimodal のいずれかを指定してください)", kind)
+	}
+	if len(params) != n {
+		return nil, fmt.Errorf("%s には %d 個のパラメーターを指定してください (例: constant:20ms, uniform:10ms,30ms, normal:20ms,5ms, bimodal:5ms,50ms,0.1): %q", kind, n, spec)
+	}
+
+	dist := &latencyDistribution{kind: kind}
+	for i, dst := range []*time.Duration{&dist.a, &dist.b}[:min(n, 2)] {
+		d, err := time.ParseDuration(strings.TrimSpace(params[i]))
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("%s の %d 番目のパラメーターには 0 以上の時間を指定してください: %q", kind, i+1, params[i])
+		}
+		*dst = d
+	}
+	switch kind {
+	case "uniform":
+		if dist.b < dist.a {
+			return nil, fmt.Errorf("uniform の上限 (%s) が下限 (%s) より小さくなっています", dist.b, dist.a)
+		}
+	case "bimodal":
+		frac, err := strconv.ParseFloat(strings.TrimSpace(params[2]), 64)
+		if err != nil || frac <= 0 || frac >= 1 {
+			return nil, fmt.Errorf("bimodal の遅い値の割合には 0 より大きく 1 より小さい値を指定してください: %q", params[2])
+		}
+		dist.frac = frac
+	}
+	return dist, nil
+}
+
+// String は、分布を表示用の文字列で返します。
+func (d *latencyDistribution) String() string {
+	switch d.kind {
+	case "constant":
+		return fmt.Sprintf("constant(%s)", d.a)
+	case "uniform":
+		return fmt.Sprintf("uniform(%s〜%s)", d.a, d.b)
+	case "normal":
+		return fmt.Sprintf("normal(平均 %s, 標準偏差 %s)", d.a, d.b)
+	default:
+		return fmt.Sprintf("bimodal(%s: %.0f%%, %s: %.0f%%)", d.a, (1-d.frac)*100, d.b, d.frac*100)
+	}
+}
+
+// seed は、待機時間を引く乱数生成器をシード値から導出して設定します（同じシード値では同じ順序で同じ待機時間になります）。
+// ワーカーの乱数生成器（newWorkerRand）と系列が重ならないよう、ワーカー番号には現れない系列番号を使います。
+func (d *latencyDistribution) seed(seed uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rng = rand.New(rand.NewPCG(seed, math.MaxUint64-1))
+}
+
+// sample は、分布に従う待機時間を1つ生成します。複数の Goroutine から同時に呼び出せます。
+// seed を呼び出していない場合は、シード値 0 の乱数生成器を使います。
+func (d *latencyDistribution) sample() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.rng == nil {
+		d.rng = rand.New(rand.NewPCG(0, math.MaxUint64-1))
+	}
+	switch d.kind {
+	case "uniform":
+		return d.a + time.Duration(d.rng.Int64N(int64(d.b-d.a)+1))
+	case "normal":
+		return max(d.a+time.Duration(d.rng.NormFloat64()*float64(d.b)), 0)
+	case "bimodal":
+		if d.rng.Float64() < d.frac {
+			return d.b
+		}
+		return d.a
+	default:
+		return d.a
+	}
+}
+
+// quantile は、分布の p 分位点（0 < p < 1）の理論値を返します。
+func (d *latencyDistribution) quantile(p float64) time.Duration {
+	switch d.kind {
+	case "uniform":
+		return d.a + time.Duration(p*float64(d.b-d.a))
+	case "normal":
+		// 標準正規分布の分位点は、誤差関数の逆関数から求められます
+		z := math.Sqrt2 * math.Erfinv(2*p-1)
+		return max(d.a+time.Duration(z*float64(d.b)), 0)
+	case "bimodal":
+		if p < 1-d.frac {
+			return d.a
+		}
+		return d.b
+	default:
+		return d.a
+	}
+}
+
+// mean は、分布の平均の理論値を返します（normal で0に切り詰める影響は無視します）。
+func (d *latencyDistribution) mean() time.Duration {
+	switch d.kind {
+	case "uniform":
+		return (d.a + d.b) / 2
+	case "bimodal":
+		return time.Duration((1-d.frac)*float64(d.a) + d.frac*float64(d.b))
+	default:
+		return d.a
+	}
+}
+
+// checkLatencyDistribution は、レポートの平均とパーセンタイルを分布の理論値と比較した結果を w に書き出し、
+// 許容誤差を超えたものがあればエラーを返します。
+func checkLatencyDistribution(w io.Writer, dist *latencyDistribution, report *TestReport) error {
+	fmt.Fprintf(w, "[SelfTest] 応答遅延の分布 %s に対する統計の検証（許容誤差: 理論値の%.0f%% + %s）:\n", dist, selfTestTolerance*100, selfTestToleranceFloor)
+
+	failed := 0
+	for _, c := range []struct {
+		label    string
+		expected time.Duration
+		actual   string
+	}{
+		{"平均", dist.mean(), report.MeanLatency},
+		{"p50 ", dist.quantile(0.50), report.P50Latency},
+		{"p90 ", dist.quantile(0.90), report.P90Latency},
+		{"p99 ", dist.quantile(0.99), report.P99Latency},
+	} {
+		actual, err := time.ParseDuration(c.actual)
+		if err != nil {
+			return fmt.Errorf("%s の計測値 %q を解釈できません", strings.TrimSpace(c.label), c.actual)
+		}
+		diff := actual - c.expected
+		tolerance := time.Duration(float64(c.expected)*selfTestTolerance) + selfTestToleranceFloor
+		mark := "OK"
+		if diff.Abs() > tolerance {
+			mark = "NG"
+			failed++
+		}
+		fmt.Fprintf(w, "  %s: 理論値 %-10s 計測値 %-10s 差 %+.2fms  %s\n", c.label, formatDuration(c.expected), c.actual, float64(diff)/float64(time.Millisecond), mark)
+	}
+	if failed > 0 {
+		return errors.New("計測値が理論値の許容誤差を超えた項目があります")
+	}
+	return nil
+}
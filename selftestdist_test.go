@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestSelfTestLatencyDistribution は、既知の分布で応答を遅らせるセルフテストで、レポートの平均とパーセンタイルが
+// 分布の理論値の許容誤差に収まることを確認します。
+// 他のテストが残したGoroutineやGCによるスケジューリングの遅れは p99 に直接上乗せされるため（特に1コアの環境）、
+// 許容誤差を超えた場合は3回まで計測し直します。
+func TestSelfTestLatencyDistribution(t *testing.T) {
+	var lastErr error
+	for attempt := 1; attempt <= 3; attempt++ {
+		dist, err := parseLatencyDistribution("uniform:10ms,30ms")
+		if err != nil {
+			t.Fatal(err)
+		}
+		runtime.GC()
+		report, _, err := selfTestLoad(time.Second, 4, dist, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Errors != 0 || report.Success < 100 {
+			t.Fatalf("success=%d errors=%d: 分布の検証に足りる応答がありません (%s)", report.Success, report.Errors, report.ErrorMsg)
+		}
+		if report.Seed != 1 {
+			t.Errorf("レポートの seed = %v, want 1", report.Seed)
+		}
+		if err := checkLatencyDistribution(io.Discard, dist, report); err != nil {
+			lastErr = fmt.Errorf("%v (平均 %s, p50 %s, p90 %s, p99 %s)", err, report.MeanLatency, report.P50Latency, report.P90Latency, report.P99Latency)
+			t.Logf("%d 回目: %v", attempt, lastErr)
+			continue
+		}
+		return
+	}
+	t.Error(lastErr)
+}
+
+// TestLatencyDistributionSeed は、同じシード値からは同じ順序で同じ待機時間が引かれ、
+// 異なるシード値からは異なる待機時間の列が引かれることを確認します。
+func TestLatencyDistributionSeed(t *testing.T) {
+	draw := func(spec string, seed uint64) []time.Duration {
+		dist, err := parseLatencyDistribution(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dist.seed(seed)
+		samples := make([]time.Duration, 100)
+		for i := range samples {
+			samples[i] = dist.sample()
+		}
+		return samples
+	}
+	for _, spec := range []string{"uniform:10ms,30ms", "normal:20ms,5ms", "bimodal:5ms,50ms,0.1"} {
+		first, again, other := draw(spec, 7), draw(spec, 7), draw(spec, 8)
+		same := true
+		for i := range first {
+			if first[i] != again[i] {
+				t.Fatalf("%s: 同じシードで %d 件目の待機時間が異なります: %v, %v", spec, i, first[i], again[i])
+			}
+			same = same && first[i] == other[i]
+		}
+		if same {
+			t.Errorf("%s: 異なるシードで同じ待機時間の列が引かれました", spec)
+		}
+	}
+}
+
+// TestLatencyDistributionTheory は、分布の指定の解釈と、平均・分位点の理論値を確認します。
+func TestLatencyDistributionTheory(t *testing.T) {
+	tests := []struct {
+		spec      string
+		mean, p90 time.Duration
+	}{
+		{"constant:20ms", 20 * time.Millisecond, 20 * time.Millisecond},
+		{"uniform:10ms,30ms", 20 * time.Millisecond, 28 * time.Millisecond},
+		{"bimodal:5ms,50ms,0.05", 7250 * time.Microsecond, 5 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		dist, err := parseLatencyDistribution(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		if got := dist.mean(); got != tt.mean {
+			t.Errorf("%s: 平均 = %v, want %v", tt.spec, got, tt.mean)
+		}
+		if got := dist.quantile(0.9); got != tt.p90 {
+			t.Errorf("%s: p90 = %v, want %v", tt.spec, got, tt.p90)
+		}
+	}
+
+	// 正規分布の p90 は 平均 + 1.2816 × 標準偏差 です
+	normal, _ := parseLatencyDistribution("normal:20ms,5ms")
+	if got, want := normal.quantile(0.9), 26408*time.Microsecond; (got - want).Abs() > 10*time.Microsecond {
+		t.Errorf("normal:20ms,5ms の p90 = %v, want %v", got, want)
+	}
+
+	for _, spec := range []string{"", "gamma:1ms", "uniform:30ms,10ms", "normal:20ms", "bimodal:5ms,50ms,1.5", "constant:abc"} {
+		if _, err := parseLatencyDistribution(spec); err == nil {
+			t.Errorf("%q: 不正な指定がエラーになりません", spec)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startShutdownTarget は、/block へのリクエストを release がクローズされるまで処理し続けるAPIサーバーを起動し、
+// そのリクエストを1件送信して、サーバーが受け付けるまで待ちます。
+func startShutdownTarget(t *testing.T) (*httptest.Server, chan struct{}) {
+	t.Helper()
+	release := make(chan struct{})
+	received := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-release
+	}))
+	t.Cleanup(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+		server.Close()
+	})
+	go http.Get(server.URL + "/block")
+	<-received
+	return server, release
+}
+
+// TestShutdownSignals は、停止シグナルを受信すると、処理中のリクエストの完了を -shutdown-timeout まで待ち、
+// 完了しない場合は猶予時間の経過後に、2回目のシグナルを受信した場合は猶予時間を待たずに強制終了することを確認します。
+func TestShutdownSignals(t *testing.T) {
+	run := func(t *testing.T, timeout time.Duration, signals int) (exitCode int, elapsed time.Duration) {
+		server, _ := startShutdownTarget(t)
+		quit := make(chan os.Signal, 1)
+		exited := make(chan int, 2)
+		start := time.Now()
+		handleShutdownSignals(server.Config, timeout, quit, func(code int) { exited <- code })
+		for range signals {
+			quit <- syscall.SIGINT
+			time.Sleep(50 * time.Millisecond)
+		}
+		select {
+		case code := <-exited:
+			return code, time.Since(start)
+		case <-time.After(5 * time.Second):
+			t.Fatal("強制終了されません")
+			return 0, 0
+		}
+	}
+
+	t.Run("猶予時間の経過", func(t *testing.T) {
+		const timeout = 300 * time.Millisecond
+		code, elapsed := run(t, timeout, 1)
+		if code != 1 || elapsed < timeout || elapsed > timeout+time.Second {
+			t.Errorf("終了コード %d, %v 後に終了: 猶予時間 %v の経過後に終了コード 1 で終了するはずです", code, elapsed, timeout)
+		}
+	})
+
+	t.Run("2回目のシグナル", func(t *testing.T) {
+		code, elapsed := run(t, time.Minute, 2)
+		if code != 1 || elapsed > time.Second {
+			t.Errorf("終了コード %d, %v 後に終了: 猶予時間を待たずに直ちに終了するはずです", code, elapsed)
+		}
+	})
+}
+
+// TestShutdownCompletes は、猶予時間内に処理中のリクエストが完了した場合は、強制終了せずに停止処理を完了することを確認します。
+func TestShutdownCompletes(t *testing.T) {
+	server, release := startShutdownTarget(t)
+	quit := make(chan os.Signal, 1)
+	exited := make(chan int, 1)
+	done := handleShutdownSignals(server.Config, 5*time.Second, quit, func(code int) { exited <- code })
+
+	quit <- syscall.SIGTERM
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("停止処理が完了しません")
+	}
+	select {
+	case code := <-exited:
+		t.Errorf("終了コード %d で強制終了しました: リクエストの完了後に正常に停止するはずです", code)
+	default:
+	}
+}
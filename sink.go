@@ -0,0 +1,198 @@
+package main
+
+import (
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション34] 外部メトリクスシンク: テスト中の計測値を StatsD などへ送信
+// ==============================================================================
+
+// 既存の監視基盤（Grafana、Datadog など）でターゲット側のメトリクスと並べて見られるよう、
+// テスト中の各リクエストの結果を外部のメトリクスシンクへ送信します。サーバーを -statsd-addr 付きで起動すると、
+// すべてのテストの結果が StatsD プロトコル（UDP）で送信されます。
+//
+// シンクは記録経路（全ワーカーのホットパス）から呼び出されるため、呼び出し側を決してブロックしてはいけません。
+// StatsD の実装では、RecordRequest はバッファ付きチャネルへの非ブロッキングな送信のみを行い、
+// 送信用のGoroutineが1行ずつ整形してUDPパケット（最大 statsdMaxPacket バイト）にまとめ、
+// パケットが埋まるか flush 間隔が経過した時点で送信します。チャネルが埋まった場合は、
+// テストの計測を遅らせないよう記録を捨てて件数だけを数えます（ログに出力します）。
+//
+// 送信するメトリクス（<prefix> は -statsd-prefix、既定値は "ultraload"）:
+//   <prefix>.latency:<ミリ秒>|ms        応答を受信したリクエストごとの応答時間
+//   <prefix>.requests:<件数>|c           完了したリクエスト数（flush 間隔ごとに集計）
+//   <prefix>.status.<コード>:<件数>|c    ステータスコードごとの件数（応答がない場合は "network"、WebSocket は "ws_message"）
+//   <prefix>.errors.<種別>:<件数>|c      エラー種別ごとの件数
+
+// MetricsSink は、テスト中のリクエストの結果を外部へ送信するシンクです。
+// RecordRequest は全ワーカーから同時に呼び出されるため、スレッドセーフかつ非ブロッキングである必要があります。
+type MetricsSink interface {
+	// RecordRequest は、1件のリクエストの結果を受け取ります（status は応答がない場合0、errKind は成功時に空文字）。
+	RecordRequest(latency time.Duration, status int, errKind string)
+	// Close は、バッファに残っている結果を送信してシンクを閉じます。
+	Close() error
+}
+
+// StatsD シンクの設定（-statsd-addr / -statsd-prefix フラグ）
+var (
+	statsdAddr   string
+	statsdPrefix = "ultraload"
+)
+
+const (
+	statsdMaxPacket     = 1432                   // 1パケットの最大サイズ（一般的なMTUでフラグメントしない大きさ）
+	statsdBufferSize    = 1 << 16                // 送信待ちの結果を溜めるチャネルの容量
+	statsdFlushInterval = 500 * time.Millisecond // パケットが埋まらなくても送信する間隔
+)
+
+// newMetricsSink は、サーバーの設定に応じたシンクを生成します（設定されていない場合は nil）。
+func newMetricsSink() MetricsSink {
+	if statsdAddr == "" {
+		return nil
+	}
+	sink, err := newStatsdSink(statsdAddr, statsdPrefix)
+	if err != nil {
+		log.Printf("[Sink Error] StatsD (%s) へ接続できないため、メトリクスの送信を行いません: %v\n", statsdAddr, err)
+		return nil
+	}
+	return sink
+}
+
+// statsdSink は、StatsD プロトコルでメトリクスを送信する MetricsSink です。
+type statsdSink struct {
+	conn    net.Conn
+	prefix  string
+	samples chan sample
+	dropped uint64 // チャネルが埋まっていたため捨てた件数（アトミックに更新）
+	done    chan struct{}
+	once    sync.Once
+}
+
+// newStatsdSink は、addr の StatsD サーバーへ送信する statsdSink を生成し、送信用のGoroutineを開始します。
+func newStatsdSink(addr, prefix string) (*statsdSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &statsdSink{
+		conn:    conn,
+		prefix:  prefix,
+		samples: make(chan sample, statsdBufferSize),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// RecordRequest は、結果を送信待ちのチャネルへ入れます。チャネルが埋まっている場合は捨てます。
+func (s *statsdSink) RecordRequest(latency time.Duration, status int, errKind string) {
+	select {
+	case s.samples <- sample{dur: latency, status: status, errKind: errKind}:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// Close は、送信待ちの結果をすべて送信してから接続を閉じます。
+func (s *statsdSink) Close() error {
+	s.once.Do(func() { close(s.samples) })
+	<-s.done
+	if dropped := atomic.LoadUint64(&s.dropped); dropped > 0 {
+		log.Printf("[Sink] 送信が追いつかなかったため、%d 件の結果を StatsD へ送信しませんでした\n", dropped)
+	}
+	return s.conn.Close()
+}
+
+// run は、チャネルから結果を受け取り、パケットにまとめて送信します。チャネルが閉じられると残りを送信して終了します。
+func (s *statsdSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(statsdFlushInterval)
+	defer ticker.Stop()
+
+	packet := make([]byte, 0, statsdMaxPacket)
+	var line []byte
+	var requests uint64
+	statuses := make(map[string]uint64)
+	errKinds := make(map[string]uint64)
+
+	// write は1行をパケットへ追加し、収まらない場合は先に送信します
+	write := func(l []byte) {
+		if len(packet)+len(l)+1 > statsdMaxPacket && len(packet) > 0 {
+			s.conn.Write(packet)
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, l...)
+	}
+	// flush は集計中のカウンタを書き出し、パケットを送信します
+	flush := func() {
+		if requests > 0 {
+			write(s.counter(line[:0], "requests", requests))
+			requests = 0
+		}
+		for status, n := range statuses {
+			write(s.counter(line[:0], "status."+status, n))
+		}
+		for kind, n := range errKinds {
+			write(s.counter(line[:0], "errors."+kind, n))
+		}
+		clear(statuses)
+		clear(errKinds)
+		if len(packet) > 0 {
+			s.conn.Write(packet)
+			packet = packet[:0]
+		}
+	}
+
+	for {
+		select {
+		case smp, ok := <-s.samples:
+			if !ok {
+				flush()
+				return
+			}
+			requests++
+			// 応答を受信できなかったリクエストはエラー種別を持つため、ステータス0かつエラー種別なしは WebSocket のメッセージです
+			var status string
+			switch {
+			case smp.status != 0:
+				status = strconv.Itoa(smp.status)
+			case smp.errKind == "":
+				status = "ws_message"
+			default:
+				status = "network"
+			}
+			if status != "network" {
+				line = append(line[:0], s.prefix...)
+				line = append(line, ".latency:"...)
+				line = strconv.AppendFloat(line, float64(smp.dur)/float64(time.Millisecond), 'f', 3, 64)
+				line = append(line, "|ms"...)
+				write(line)
+			}
+			statuses[status]++
+			if smp.errKind != "" {
+				errKinds[smp.errKind]++
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// counter は、カウンタ1行（<prefix>.<name>:<n>|c）を buf に追加して返します。
+func (s *statsdSink) counter(buf []byte, name string, n uint64) []byte {
+	buf = append(buf, s.prefix...)
+	buf = append(buf, '.')
+	buf = append(buf, name...)
+	buf = append(buf, ':')
+	buf = strconv.AppendUint(buf, n, 10)
+	return append(buf, "|c"...)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenFakeStatsd は、StatsD サーバーの代わりに受信したパケットを返す UDP リスナーを起動します。
+func listenFakeStatsd(t *testing.T) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readStatsdLines は、シンクを閉じた後に届いたパケットをすべて読み、1行ずつに分けて返します。
+// パケットが statsdMaxPacket を超えていた場合はテストを失敗させます。
+func readStatsdLines(t *testing.T, conn net.PacketConn) []string {
+	t.Helper()
+	var lines []string
+	buf := make([]byte, 64*1024)
+	for {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return lines
+		}
+		if n > statsdMaxPacket {
+			t.Errorf("%d バイトのパケットを受信しました, want <= %d", n, statsdMaxPacket)
+		}
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+}
+
+// TestStatsdSinkLines は、StatsD シンクが応答時間・リクエスト数・ステータスコード・エラー種別を期待どおりの行で送信し、
+// 応答のないリクエストの応答時間は送信しないことを、UDP で待ち受ける偽の StatsD サーバーで確認します。
+func TestStatsdSinkLines(t *testing.T) {
+	conn := listenFakeStatsd(t)
+	sink, err := newStatsdSink(conn.LocalAddr().String(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.RecordRequest(12500*time.Microsecond, 200, "")
+	sink.RecordRequest(time.Millisecond, 503, "")
+	sink.RecordRequest(2*time.Second, 0, traceErrTimeout)
+	sink.RecordRequest(3*time.Millisecond, 0, "")
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readStatsdLines(t, conn)
+	want := []string{
+		"test.latency:12.500|ms",
+		"test.latency:1.000|ms",
+		"test.latency:3.000|ms",
+		"test.requests:4|c",
+		"test.status.200:1|c",
+		"test.status.503:1|c",
+		"test.status.network:1|c",
+		"test.status.ws_message:1|c",
+		"test.errors.timeout:1|c",
+	}
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("送信された行:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestStatsdSinkDuringLoad は、-statsd-addr を指定したサーバーで実行したテストの全リクエストが、
+// 1パケットの上限を超えずに StatsD へ送信されることを確認します。
+func TestStatsdSinkDuringLoad(t *testing.T) {
+	conn := listenFakeStatsd(t)
+	saved := statsdAddr
+	t.Cleanup(func() { statsdAddr = saved })
+	statsdAddr = conn.LocalAddr().String()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	// 受信側はテストの終了後にまとめて読むため、ソケットの受信バッファからあふれない件数に抑えます
+	report := runTestLoad(newTestConfig(t, map[string]any{"target_url": server.URL, "concurrency": 2, "rate_limit": 200, "duration": "600ms"}))
+
+	var latencies, requests, ok200 int
+	for _, line := range readStatsdLines(t, conn) {
+		name, value, _ := strings.Cut(line, ":")
+		n, _ := strconv.Atoi(strings.TrimSuffix(value, "|c"))
+		switch name {
+		case statsdPrefix + ".latency":
+			latencies++
+		case statsdPrefix + ".requests":
+			requests += n
+		case statsdPrefix + ".status.200":
+			ok200 += n
+		}
+	}
+	if report.TotalRequests == 0 || requests != report.TotalRequests || ok200 != requests || latencies != requests {
+		t.Errorf("total=%d: StatsD へ送信された requests=%d status.200=%d latency=%d 行: すべてのリクエストが送信されるはずです",
+			report.TotalRequests, requests, ok200, latencies)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション49] 遅い応答の検出 (slow_threshold / slow_is_error)
+// ==============================================================================
+
+// SLO に基づく試験では、5秒かかって返ってきた 200 は、利用者にとっては失敗と変わりません。
+// slow_threshold を指定すると、応答を受信するまでの時間（レイテンシと同じ基準です）がこれを超えたリクエストを
+// slow_responses として数え、総リクエスト数に対する割合を slow_rate_pct に記録します。遅い応答もレイテンシの統計には
+// 通常どおり含めます。slow_is_error を併せて指定すると、遅い応答のうち本来は成功になるもの（2xx・3xx）を
+// エラー種別 slow_response の失敗として記録します（errors に数えられ、fail_fast の対象にもなります）。
+// 4xx・5xx などもともとエラーになる応答は、遅い応答として数えるだけで、エラー種別は変えません。
+
+// errKindSlow は、slow_is_error の指定時に、slow_threshold を超えた応答を記録するエラー種別です。
+const errKindSlow = "slow_response"
+
+// isSlow は、所要時間 d が slow_threshold を超えているかどうかを返します（slow_threshold が未指定の場合は false）。
+func isSlow(cfg *TestConfig, d time.Duration) bool {
+	return cfg.SlowThreshold > 0 && d > time.Duration(cfg.SlowThreshold)
+}
+
+// recordSlow は、応答を受信したリクエストが遅い応答であれば slow_responses に数えます。
+func recordSlow(metrics *ResultMetrics, cfg *TestConfig, d time.Duration) {
+	if isSlow(cfg, d) {
+		atomic.AddUint64(&metrics.SlowResponses, 1)
+	}
+}
+
+// slowRatePct は、総リクエスト数に対する遅い応答の割合（%）を返します。
+func slowRatePct(slow uint64, total int) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(slow) / float64(total) * 100
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSlowResponses は、2件に1件を slow_threshold より遅く返すターゲットに対し、遅い応答がちょうどその半数だけ
+// slow_responses に数えられ、slow_is_error を指定した場合だけ slow_response エラーとして errors にも数えられることと、
+// もともとエラーになる遅い 5xx の応答はエラー種別を変えないことを確認します。
+func TestSlowResponses(t *testing.T) {
+	const threshold, slowDelay = 30 * time.Millisecond, 60 * time.Millisecond
+	for _, slowIsError := range []bool{false, true} {
+		var requests atomic.Int64
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1)%2 == 0 {
+				time.Sleep(slowDelay)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		report := runTestLoad(newTestConfig(t, map[string]any{
+			"target_url":     server.URL,
+			"concurrency":    1,
+			"duration":       "600ms",
+			"slow_threshold": threshold.String(),
+			"slow_is_error":  slowIsError,
+			"no_preflight":   true,
+		}))
+		if report.TotalRequests < 10 {
+			t.Fatalf("total=%d", report.TotalRequests)
+		}
+		// ワーカーは1つなので、記録されたリクエストは速い応答と遅い応答が交互に並びます
+		if half := float64(report.TotalRequests) / 2; math.Abs(float64(report.SlowResponses)-half) > 1 {
+			t.Errorf("slow_is_error=%v: slow_responses = %d (total=%d), want 約 %.0f", slowIsError, report.SlowResponses, report.TotalRequests, half)
+		}
+		if want := slowRatePct(report.SlowResponses, report.TotalRequests); report.SlowRatePct != want {
+			t.Errorf("slow_rate_pct = %.2f, want %.2f", report.SlowRatePct, want)
+		}
+
+		wantErrors := 0
+		if slowIsError {
+			wantErrors = int(report.SlowResponses)
+		}
+		if report.Errors != wantErrors || report.ErrorKinds[errKindSlow] != uint64(wantErrors) {
+			t.Errorf("slow_is_error=%v: errors=%d error_kinds=%v, want %d 件の %s エラー",
+				slowIsError, report.Errors, report.ErrorKinds, wantErrors, errKindSlow)
+		}
+	}
+
+	// もともとエラーになる応答は、遅くても遅い応答として数えるだけで、ステータスコードのエラーのままです
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(slowDelay)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":     server.URL,
+		"concurrency":    1,
+		"duration":       "300ms",
+		"slow_threshold": threshold.String(),
+		"slow_is_error":  true,
+		"no_preflight":   true,
+	}))
+	if report.TotalRequests == 0 || report.SlowResponses != uint64(report.TotalRequests) ||
+		report.ErrorKinds[errKindSlow] != 0 || report.StatusCodes["500"] != uint64(report.TotalRequests) {
+		t.Errorf("total=%d slow_responses=%d error_kinds=%v status_codes=%v: 遅い 5xx は 500 のエラーのままのはずです",
+			report.TotalRequests, report.SlowResponses, report.ErrorKinds, report.StatusCodes)
+	}
+}
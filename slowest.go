@@ -0,0 +1,159 @@
+package main
+
+import (
+	"container/heap"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション52] 最も遅かったリクエストの記録 (top_slowest)
+// ==============================================================================
+
+// p99 が悪いと分かっても、それが「どのリクエストで」「いつ」「どんな応答だったか」が分からなければ原因を追えません。
+// top_slowest に件数 N を指定すると、テスト中に最も時間のかかった N 件のリクエストについて、メソッド・URL・
+// ステータスコード（通信エラーの場合はエラー内容）・所要時間・テスト開始からの送信時刻を、所要時間の降順で
+// レポートの slowest_requests に記録します。top_slowest_headers を併せて指定すると、そのレスポンスヘッダーも記録します
+// （どのサーバーが応答したかを示す X-Served-By などの確認用）。
+//
+// 記録中のリクエストは、所要時間の最も短いものが先頭に来る最小ヒープ（最大 N 件）で保持するため、総リクエスト数に
+// 関係なくメモリ使用量は一定です。ヒープが埋まった後は、その最短の所要時間をアトミック変数に写しておき、
+// それ以下のリクエスト（ほとんどすべて）はロックを取らずに素通りさせます。
+
+// maxTopSlowest は、top_slowest に指定できる件数の上限です（レポートの肥大化を防ぐため）。
+const maxTopSlowest = 1000
+
+// SlowRequest は、最も遅かったリクエストの1件です。
+type SlowRequest struct {
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	StatusCode      int         `json:"status_code,omitempty"` // 応答を受信できなかった場合は省略
+	Error           string      `json:"error,omitempty"`       // 応答を受信できなかった場合のエラー
+	Latency         string      `json:"latency"`
+	AtSec           float64     `json:"at_sec"`                     // テスト開始から送信を開始するまでの時間（秒）
+	ResponseHeaders http.Header `json:"response_headers,omitempty"` // top_slowest_headers を指定した場合のみ
+
+	latency time.Duration
+}
+
+// slowHeap は、所要時間の最も短いリクエストを先頭に保つ最小ヒープです（container/heap.Interface を実装します）。
+type slowHeap []SlowRequest
+
+func (h slowHeap) Len() int           { return len(h) }
+func (h slowHeap) Less(i, j int) bool { return h[i].latency < h[j].latency }
+func (h slowHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *slowHeap) Push(x any)        { *h = append(*h, x.(SlowRequest)) }
+func (h *slowHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// slowestTracker は、最も遅かった limit 件のリクエストを保持します。nil の slowestTracker は何もしません。
+type slowestTracker struct {
+	limit   int
+	headers bool
+	start   time.Time
+
+	// floor は、ヒープが埋まった後の最短の所要時間（ナノ秒）です。埋まるまでは -1 です。
+	floor atomic.Int64
+
+	mu   sync.Mutex
+	heap slowHeap
+}
+
+// newSlowestTracker は、最も遅かった limit 件を保持する slowestTracker を生成します。limit が0以下の場合は nil を返します。
+func newSlowestTracker(limit int, headers bool, start time.Time) *slowestTracker {
+	if limit <= 0 {
+		return nil
+	}
+	t := &slowestTracker{limit: limit, headers: headers, start: start, heap: make(slowHeap, 0, limit)}
+	t.floor.Store(-1)
+	return t
+}
+
+// observe は、1件のリクエストの結果を候補として記録します。sent は送信を開始した時刻です。
+// resp と err はいずれか一方のみが設定されている前提です（http.Client.Do の戻り値）。
+func (t *slowestTracker) observe(req *http.Request, resp *http.Response, err error, sent time.Time, latency time.Duration) {
+	if t == nil {
+		return
+	}
+	if floor := t.floor.Load(); floor >= 0 && int64(latency) <= floor {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	full := len(t.heap) == t.limit
+	if full && latency <= t.heap[0].latency {
+		return
+	}
+
+	entry := SlowRequest{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Latency: formatDuration(latency),
+		AtSec:   sent.Sub(t.start).Seconds(),
+		latency: latency,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.StatusCode = resp.StatusCode
+		if t.headers {
+			entry.ResponseHeaders = resp.Header.Clone()
+		}
+	}
+
+	if full {
+		t.heap[0] = entry
+		heap.Fix(&t.heap, 0)
+	} else {
+		heap.Push(&t.heap, entry)
+	}
+	if len(t.heap) == t.limit {
+		t.floor.Store(int64(t.heap[0].latency))
+	}
+}
+
+// result は、記録したリクエストを所要時間の降順で返します。
+func (t *slowestTracker) result() []SlowRequest {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	requests := slices.Clone(t.heap)
+	t.mu.Unlock()
+	sortSlowest(requests)
+	return requests
+}
+
+// sortSlowest は、requests を所要時間の降順に並べ替えます。
+func sortSlowest(requests []SlowRequest) {
+	slices.SortStableFunc(requests, func(a, b SlowRequest) int {
+		return int(min(max(b.latency-a.latency, -1), 1))
+	})
+}
+
+// mergeSlowest は、複数のレポートの slowest_requests を統合し、最も遅いものから各レポートの件数の最大値までを返します。
+func mergeSlowest(reports []*TestReport) []SlowRequest {
+	var merged []SlowRequest
+	limit := 0
+	for _, report := range reports {
+		limit = max(limit, len(report.SlowestRequests))
+		for _, r := range report.SlowestRequests {
+			// レポートのJSONからは所要時間の文字列しか得られないため、並べ替え用の値を読み戻します
+			r.latency, _ = time.ParseDuration(r.Latency)
+			merged = append(merged, r)
+		}
+	}
+	sortSlowest(merged)
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSlowestRequests は、3件だけ意図的に遅く返すターゲットに対し、その3件が遅い順に slowest_requests の先頭に並び、
+// top_slowest_headers の指定でそれぞれのレスポンスヘッダーも記録されることを確認します。
+func TestSlowestRequests(t *testing.T) {
+	// 10件目・20件目・30件目を、後のものほど遅く返します
+	delays := map[int64]time.Duration{10: 60 * time.Millisecond, 20: 100 * time.Millisecond, 30: 140 * time.Millisecond}
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		if delay, ok := delays[n]; ok {
+			time.Sleep(delay)
+			w.Header().Set("X-Request-No", strconv.FormatInt(n, 10))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":          server.URL,
+		"concurrency":         1,
+		"duration":            "700ms",
+		"top_slowest":         5,
+		"top_slowest_headers": true,
+		"no_preflight":        true,
+	}))
+	if report.TotalRequests < 40 {
+		t.Fatalf("total=%d: 遅い3件の後にも送信されるはずです", report.TotalRequests)
+	}
+	slowest := report.SlowestRequests
+	if len(slowest) != 5 {
+		t.Fatalf("slowest_requests の件数 = %d, want 5", len(slowest))
+	}
+	for i, want := range []string{"30", "20", "10"} {
+		r := slowest[i]
+		if got := r.ResponseHeaders.Get("X-Request-No"); got != want || r.StatusCode != http.StatusOK || r.Method != http.MethodGet || r.URL != server.URL {
+			t.Errorf("%d 番目 = %+v, want %s 件目のリクエスト", i+1, r, want)
+		}
+	}
+	// 並びは所要時間の降順で、遅い3件の次に来るのは通常の速さのリクエストです
+	for i := 1; i < len(slowest); i++ {
+		prev, _ := time.ParseDuration(slowest[i-1].Latency)
+		cur, _ := time.ParseDuration(slowest[i].Latency)
+		if cur > prev {
+			t.Errorf("%d 番目 (%s) が %d 番目 (%s) より遅くなっています", i+1, slowest[i].Latency, i, slowest[i-1].Latency)
+		}
+	}
+	if latency, _ := time.ParseDuration(slowest[2].Latency); latency < 60*time.Millisecond {
+		t.Errorf("3 番目の所要時間 = %s, want 60ms 以上", slowest[2].Latency)
+	}
+	if slowest[3].ResponseHeaders.Get("X-Request-No") != "" || slowest[0].AtSec <= slowest[2].AtSec {
+		t.Errorf("4 番目 = %+v, 1 番目の送信時刻 = %.3f, 3 番目の送信時刻 = %.3f", slowest[3], slowest[0].AtSec, slowest[2].AtSec)
+	}
+}
+
+// TestSlowestTrackerBounded は、多数のリクエストを記録しても保持するのは limit 件だけで、それが最も遅いものであることを確認します。
+func TestSlowestTrackerBounded(t *testing.T) {
+	start := time.Now()
+	tracker := newSlowestTracker(3, false, start)
+	req := httptest.NewRequest(http.MethodGet, "http://example.test/", nil)
+	resp := &http.Response{StatusCode: http.StatusOK}
+	for i := range 10000 {
+		// 7919 は 10000 と互いに素のため、0〜9999 ms がばらばらの順で1回ずつ現れます
+		latency := time.Duration(i*7919%10000) * time.Millisecond
+		tracker.observe(req, resp, nil, start, latency)
+	}
+	got := tracker.result()
+	if len(got) != 3 || got[0].latency != 9999*time.Millisecond || got[1].latency != 9998*time.Millisecond || got[2].latency != 9997*time.Millisecond {
+		t.Errorf("slowest_requests = %+v, want 9999ms・9998ms・9997ms", got)
+	}
+	if (*slowestTracker)(nil).result() != nil {
+		t.Error("nil の slowestTracker が結果を返しました")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション16] 区間スナップショット: 長時間テスト中の定期的な途中経過
+// ==============================================================================
+
+// ソークテストのような長時間のテストでは、最終レポートを待たずに途中経過を確認したくなります。
+// report_interval_sec を指定すると、その間隔ごとに直近の区間だけを対象にした簡易スナップショット
+// （RPS・エラー率・p99）を算出し、ログへ出力するとともにレポートとジョブの進捗に記録します。
+// 最終レポートの集計には影響しません。
+
+// intervalStats は、1つの区間内に記録された結果です。
+type intervalStats struct {
+	requests uint64
+	errors   uint64
+	samples  []time.Duration // 区間内のレイテンシ（応答を受信したリクエストのみ）
+}
+
+// intervalSampler は、メトリクスの累計値から区間ごとの差分を取り出します。
+// 区間レイテンシ用のバッファを登録するため、ワーカー起動前に生成してください。
+type intervalSampler struct {
+	metrics    *ResultMetrics
+	window     *latencyWindow
+	lastTotal  uint64
+	lastErrors uint64
+}
+
+// newIntervalSampler は、区間レイテンシ用のバッファを登録した intervalSampler を生成します。
+func newIntervalSampler(metrics *ResultMetrics) *intervalSampler {
+	return &intervalSampler{metrics: metrics, window: metrics.addLatencyWindow()}
+}
+
+// next は、前回の呼び出し以降の区間の結果を返します。
+func (s *intervalSampler) next() intervalStats {
+	total := s.metrics.TotalRequests.Load()
+	errors := s.metrics.ErrorCount.Load()
+	stats := intervalStats{
+		requests: total - s.lastTotal,
+		errors:   errors - s.lastErrors,
+		samples:  s.metrics.drainLatencyWindow(s.window),
+	}
+	s.lastTotal, s.lastErrors = total, errors
+	return stats
+}
+
+// errorRate は、区間内のエラー率を返します（リクエストが0件の場合は0）。
+func (st intervalStats) errorRate() float64 {
+	if st.requests == 0 {
+		return 0
+	}
+	return float64(st.errors) / float64(st.requests)
+}
+
+// p99 は、区間内のp99レイテンシを表示用の文字列で返します（応答が0件の場合は "N/A"）。
+func (st intervalStats) p99() string {
+	if len(st.samples) == 0 {
+		return "N/A"
+	}
+	return formatDuration(percentileOf(st.samples, 0.99))
+}
+
+// IntervalSnapshot は、1区間分の途中経過です。
+type IntervalSnapshot struct {
+	ElapsedSec float64 `json:"elapsed_sec"` // テスト開始からの経過秒数
+	Requests   uint64  `json:"requests"`    // 区間内に完了したリクエスト数
+	RPS        float64 `json:"rps"`         // 区間内のスループット
+	ErrorRate  float64 `json:"error_rate"`  // 区間内のエラー率
+	P99Latency string  `json:"p99_latency"` // 区間内のp99レイテンシ
+	InFlight   int64   `json:"in_flight"`   // スナップショット時点で通信中のリクエスト数
+}
+
+// runIntervalReporter は、テスト終了まで一定間隔でスナップショットを作成し、メトリクスへ記録します。
+// quiet が指定されていない場合は、ログにも1行で出力します。
+// ctx がキャンセルされると done をクローズして終了します（端数の区間は記録しません）。
+func runIntervalReporter(ctx context.Context, cfg *TestConfig, sampler *intervalSampler, metrics *ResultMetrics, done chan<- struct{}) {
+	defer close(done)
+
+	interval := time.Duration(cfg.ReportIntervalSec) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := sampler.next()
+			snapshot := IntervalSnapshot{
+				ElapsedSec: time.Since(start).Seconds(),
+				Requests:   stats.requests,
+				RPS:        float64(stats.requests) / interval.Seconds(),
+				ErrorRate:  stats.errorRate(),
+				P99Latency: stats.p99(),
+				InFlight:   atomic.LoadInt64(&metrics.InFlight),
+			}
+
+			metrics.mu.Lock()
+			metrics.snapshots = append(metrics.snapshots, snapshot)
+			metrics.mu.Unlock()
+
+			if !cfg.Quiet {
+				log.Printf("[Snapshot] %6.0f秒経過 | RPS: %.1f | エラー率: %.2f%% | p99: %s | 通信中: %d\n",
+					snapshot.ElapsedSec, snapshot.RPS, snapshot.ErrorRate*100, snapshot.P99Latency, snapshot.InFlight)
+			}
+		}
+	}
+}
+
+// latestSnapshot は、直近のスナップショットを返します（まだ1件もない場合は nil）。
+func (rm *ResultMetrics) latestSnapshot() *IntervalSnapshot {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if len(rm.snapshots) == 0 {
+		return nil
+	}
+	latest := rm.snapshots[len(rm.snapshots)-1]
+	return &latest
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// TestIntervalSnapshots は、report_interval_sec の間隔ごとに途中経過が1件ずつレポートへ記録され、
+// 端数の区間は記録されないことと、各区間の件数・RPS が整合することを確認します。
+func TestIntervalSnapshots(t *testing.T) {
+	server := newTestJobServer(t)
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":          server.URL,
+		"concurrency":         2,
+		"duration":            "2500ms",
+		"report_interval_sec": 1,
+	}))
+
+	// 1秒・2秒の時点の2件で、2.5秒までの端数の区間は記録しません
+	if got := len(report.IntervalSnapshots); got != 2 {
+		t.Fatalf("interval_snapshots = %d 件, want 2: %+v", got, report.IntervalSnapshots)
+	}
+	var sum uint64
+	for i, s := range report.IntervalSnapshots {
+		if math.Abs(s.ElapsedSec-float64(i+1)) > 0.3 {
+			t.Errorf("%d 件目の elapsed_sec = %.2f, want 約 %d", i+1, s.ElapsedSec, i+1)
+		}
+		if s.Requests == 0 || s.RPS != float64(s.Requests) || s.ErrorRate != 0 || s.P99Latency == "N/A" {
+			t.Errorf("%d 件目のスナップショットが区間の結果と整合しません: %+v", i+1, s)
+		}
+		sum += s.Requests
+	}
+	if sum > uint64(report.TotalRequests) {
+		t.Errorf("区間の件数の合計 %d がテスト全体の %d を超えています", sum, report.TotalRequests)
+	}
+}
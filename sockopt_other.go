@@ -0,0 +1,23 @@
+//go:build !unix
+
+package main
+
+import (
+	"log"
+	"sync"
+	"syscall"
+)
+
+// warnUnsupportedOnce は、非対応プラットフォームでの警告を1回だけ出力するためのものです。
+var warnUnsupportedOnce sync.Once
+
+// socketBufferControl は、UNIX系以外のプラットフォーム向けのフォールバックです。
+// setsockopt(2) の呼び出し方がOSごとに異なるため、バッファサイズの設定は行わず警告のみを出力します。
+func socketBufferControl(opts socketOptions) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		warnUnsupportedOnce.Do(func() {
+			log.Println("[Socket] このプラットフォームでは SO_RCVBUF/SO_SNDBUF の設定に対応していないため、OSのデフォルト値を使用します")
+		})
+		return nil
+	}
+}
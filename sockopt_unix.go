@@ -0,0 +1,43 @@
+//go:build unix
+
+package main
+
+import (
+	"log"
+	"sync"
+	"syscall"
+)
+
+// logEffectiveBuffersOnce は、OSが実際に適用したバッファサイズのログ出力を最初の1接続に限定します。
+var logEffectiveBuffersOnce sync.Once
+
+// socketBufferControl は、接続前のソケットに SO_RCVBUF / SO_SNDBUF を設定する Control 関数を返します。
+// UNIX系OSでは setsockopt(2) を直接呼び出すことで、SYN送信前にバッファサイズを確定させます。
+func socketBufferControl(opts socketOptions) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var sockErr error
+		err := c.Control(func(fd uintptr) {
+			if opts.rcvBuf > 0 {
+				if sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF, opts.rcvBuf); sockErr != nil {
+					return
+				}
+			}
+			if opts.sndBuf > 0 {
+				if sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF, opts.sndBuf); sockErr != nil {
+					return
+				}
+			}
+
+			// カーネルによる丸めや倍化（Linuxでは要求値の2倍）を確認できるよう、実効値を一度だけ出力します
+			logEffectiveBuffersOnce.Do(func() {
+				rcv, _ := syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+				snd, _ := syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+				log.Printf("[Socket] OSが適用した実効値: SO_RCVBUF=%d バイト, SO_SNDBUF=%d バイト\n", rcv, snd)
+			})
+		})
+		if err != nil {
+			return err
+		}
+		return sockErr
+	}
+}
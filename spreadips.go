@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================================================
+// [セクション59] 解決した全アドレスへの接続の分散: クライアント側の負荷分散 (spread_ips)
+// ==============================================================================
+
+// DNS ラウンドロビンで複数のアドレスを返すターゲットでも、ダイヤラーは解決結果の先頭から順に接続を試すため、
+// 接続はほぼ先頭のアドレス（リゾルバーの並べ方次第）に集中し、一部のバックエンドにしか負荷がかかりません。
+// spread_ips を指定すると、ホスト名をテストの最初の接続時に1度だけ解決し、以後の新規接続を、解決した全アドレスへ
+// ラウンドロビンで割り当てます（割り当てたアドレスに接続できない場合は、次のアドレスから順に試します）。
+// 接続は keep-alive で使い回されるため、リクエストは「接続の本数」単位で分散します。並行数をアドレス数の倍数にすると均等になります。
+//
+// リクエストごとの送信先アドレスを requests_by_ip に記録するため、全バックエンドに均等に負荷がかかったかを確認できます。
+// テスト中に DNS のレコードが変わっても、最初に解決したアドレスを使い続けます。HTTPモードのみ対応で、http3 とは併用できません。
+
+// ipSpreader は、ホスト名ごとに1度だけ解決したアドレスへ、新規接続をラウンドロビンで割り当てます。
+// nil の ipSpreader は分散しません。
+type ipSpreader struct {
+	mu    sync.Mutex
+	addrs map[string][]netip.Addr // ホスト名ごとの解決結果
+
+	next atomic.Uint64
+}
+
+// newIPSpreader は、spread_ips が指定されている場合に ipSpreader を生成します（未指定の場合は nil）。
+func newIPSpreader(enabled bool) *ipSpreader {
+	if !enabled {
+		return nil
+	}
+	return &ipSpreader{addrs: make(map[string][]netip.Addr)}
+}
+
+// resolve は、host の解決結果を返します。初回のみ lookup で解決し、成功した結果を以後も使い続けます。
+// 解決に失敗した場合は記憶せず、次の接続で再び解決を試みます。
+func (s *ipSpreader) resolve(host string, lookup func() ([]netip.Addr, error)) ([]netip.Addr, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if addrs, ok := s.addrs[host]; ok {
+		return addrs, nil
+	}
+	// ロックを保持したまま解決するため、同時に確立される最初の接続群は、1回の解決の結果を待って共有します
+	addrs, err := lookup()
+	if err != nil {
+		return nil, err
+	}
+	s.addrs[host] = addrs
+	return addrs, nil
+}
+
+// order は、次の新規接続で試すアドレスの順序（ラウンドロビンで選んだアドレスから始まり、残りを順に並べたもの）を返します。
+func (s *ipSpreader) order(addrs []netip.Addr) []netip.Addr {
+	if len(addrs) <= 1 {
+		return addrs
+	}
+	start := int((s.next.Add(1) - 1) % uint64(len(addrs)))
+	ordered := make([]netip.Addr, 0, len(addrs))
+	ordered = append(ordered, addrs[start:]...)
+	return append(ordered, addrs[:start]...)
+}
+
+// lookupHost は、ダイヤラーのリゾルバーで host を解決し、その所要時間と結果を名前解決の集計に記録します。
+func (d *tunedDialer) lookupHost(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	ipNetwork := "ip"
+	switch network {
+	case "tcp4":
+		ipNetwork = "ip4"
+	case "tcp6":
+		ipNetwork = "ip6"
+	}
+	start := time.Now()
+	addrs, err := d.resolver.LookupNetIP(ctx, ipNetwork, host)
+	if err == nil && len(addrs) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	d.dns.record(time.Since(start), err)
+	return addrs, err
+}
+
+// recordRequestIP は、リクエストが使用した接続の接続先アドレスを requests_by_ip に数えます（spread_ips の指定時のみ）。
+func (rm *ResultMetrics) recordRequestIP(conn net.Conn) {
+	if !rm.recordsIPs || conn == nil {
+		return
+	}
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return
+	}
+	countPtr, _ := rm.RequestsByIP.LoadOrStore(addr.IP.String(), new(uint64))
+	atomic.AddUint64(countPtr.(*uint64), 1)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+)
+
+// TestSpreadIPs は、3つのアドレスを返す偽のDNSサーバーで解決するホスト名に対し、spread_ips を指定すると
+// 名前解決は1度だけ行い、新規接続を3つのアドレスへラウンドロビンで割り当てて、リクエストが均等に分散することを、
+// requests_by_ip とターゲット側で受信したアドレスの両方で確認します。
+func TestSpreadIPs(t *testing.T) {
+	ips := []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}
+	dns := newFakeDNSServer(t, map[string][]string{"lb.ultraload.test": ips})
+
+	// 3つのループバックアドレスのいずれでも受け付けるよう、すべてのアドレスで待ち受けます
+	listener, err := net.Listen("tcp", "0.0.0.0:0")
+	if err != nil {
+		t.Skipf("すべてのアドレスで待ち受けできません: %v", err)
+	}
+	var mu sync.Mutex
+	received := make(map[string]int)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		local := r.Context().Value(http.LocalAddrContextKey).(net.Addr).(*net.TCPAddr)
+		mu.Lock()
+		received[local.IP.String()]++
+		mu.Unlock()
+	}))
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	// ワーカー数をアドレス数の倍数にすると、接続の本数が均等になります
+	const workers = 6
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":   "http://lb.ultraload.test:" + port,
+		"concurrency":  workers,
+		"duration":     "400ms",
+		"dns_server":   dns.addr,
+		"spread_ips":   true,
+		"no_preflight": true,
+	}))
+	if report.TotalRequests < 100 || report.Errors != 0 {
+		t.Fatalf("total=%d errors=%d error_kinds=%v", report.TotalRequests, report.Errors, report.ErrorKinds)
+	}
+	if report.DNSLookups != 1 {
+		t.Errorf("dns_lookups = %d, want 1（最初の接続時に1度だけ解決するはずです）", report.DNSLookups)
+	}
+	if len(report.RequestsByIP) != len(ips) {
+		t.Fatalf("requests_by_ip = %v, want %v のすべて", report.RequestsByIP, ips)
+	}
+	// 各アドレスには2本ずつ接続するため、それぞれ全体の 1/3 前後になります（ワーカーごとの速さのばらつきを許容します）
+	var sum uint64
+	for _, ip := range ips {
+		count := report.RequestsByIP[ip]
+		sum += count
+		if share := float64(count) / float64(report.TotalRequests); share < 1.0/6 || share > 1.0/2 {
+			t.Errorf("%s へのリクエスト = %d (%.0f%%), want 約 33%%（requests_by_ip=%v）", ip, count, share*100, report.RequestsByIP)
+		}
+	}
+	// 接続を取得した時点で数えるため、テスト終了で中断した送信中のリクエストの分（最大でワーカー数）だけ多くなり得ます
+	if sum < uint64(report.TotalRequests) || sum > uint64(report.TotalRequests)+workers {
+		t.Errorf("requests_by_ip の合計 = %d, total = %d", sum, report.TotalRequests)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, ip := range ips {
+		if received[ip] == 0 {
+			t.Errorf("ターゲットが %s で受信していません（受信: %v）", ip, received)
+		}
+	}
+}
+
+// TestIPSpreaderOrder は、新規接続ごとに開始位置をずらしたアドレスの順序を返し、解決結果を1度だけ取得することを確認します。
+func TestIPSpreaderOrder(t *testing.T) {
+	s := newIPSpreader(true)
+	addrs := []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2"), netip.MustParseAddr("10.0.0.3")}
+	lookups := 0
+	for range 3 {
+		got, err := s.resolve("host", func() ([]netip.Addr, error) {
+			lookups++
+			return addrs, nil
+		})
+		if err != nil || len(got) != 3 {
+			t.Fatalf("resolve = %v, %v", got, err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("解決の回数 = %d, want 1", lookups)
+	}
+	for i, wantFirst := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.1"} {
+		order := s.order(addrs)
+		if len(order) != 3 || order[0].String() != wantFirst || order[1] != addrs[(i+1)%3] {
+			t.Errorf("%d 本目の接続の順序 = %v, want %s から始まる順", i+1, order, wantFirst)
+		}
+	}
+	if newIPSpreader(false) != nil {
+		t.Error("spread_ips を指定しない場合に ipSpreader が生成されました")
+	}
+}
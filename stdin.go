@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// ==============================================================================
+// [セクション50] 標準入力からの送信先の読み込み (-urls-stdin)
+// ==============================================================================
+
+// 送信先のURLの一覧は、アクセスログの抽出やサイトマップの展開など、他のコマンドで生成することがよくあります。
+// -urls-stdin を指定してサーバーを起動すると、標準入力を終端（EOF）まで読み込み、その一覧を送信先として保持します。
+//   cat urls.txt | ultraload -urls-stdin
+//   grep ' 200 ' access.log | awk '{print $7}' | sed 's|^|https://example.com|' | ultraload -urls-stdin
+//
+// 各行は request_file と同じ "<メソッド> <URL> [オプション]" の形式のほか、URLだけを書いた行（GET として扱います）も
+// 受け付けます。空行と "#" で始まる行は無視します。読み込んだ一覧は、target_url・targets・request_file のいずれも
+// 指定しなかったテストで、request_file と同じように request_order に従って再生します（HTTPのクローズドモデル専用）。
+//
+// 標準入力が端末（TTY）の場合は、入力を待ち続けてサーバーが起動しないように見えるため、パイプかリダイレクトを
+// 使うよう案内してエラーにします。
+
+// stdinRequests は、-urls-stdin で標準入力から読み込んだリクエストの一覧です（未指定の場合は nil）。
+var stdinRequests []requestSpec
+
+// loadStdinTargets は、in が端末でないことを確認してから、終端までリクエストの一覧を読み込みます。
+func loadStdinTargets(in *os.File) ([]requestSpec, error) {
+	info, err := in.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("標準入力の状態を取得できません: %w", err)
+	}
+	if info.Mode()&os.ModeCharDevice != 0 {
+		return nil, errors.New("-urls-stdin が指定されましたが、標準入力が端末です。送信先の一覧をパイプかリダイレクトで渡してください (例: cat urls.txt | ultraload -urls-stdin)")
+	}
+	return readStdinTargets(in)
+}
+
+// readStdinTargets は、r から送信先の一覧を終端まで読み込んで検証します。1件もない場合はエラーを返します。
+func readStdinTargets(r io.Reader) ([]requestSpec, error) {
+	specs, warning, err := readRequestSpecs(r, "標準入力", true)
+	if err != nil {
+		return nil, err
+	}
+	if len(specs) == 0 {
+		return nil, errors.New("標準入力に送信先が1件もありません（1行に1つのURL、または \"<メソッド> <URL>\" を指定してください）")
+	}
+	if warning != "" {
+		log.Printf("[Stdin Warning] %s\n", warning)
+	}
+	return specs, nil
+}
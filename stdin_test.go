@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestReadStdinTargets は、パイプから渡された送信先の一覧（URLだけの行と "<メソッド> <URL>" の行）を
+// 空行とコメントを除いて読み込み、送信先を指定しないテストがその一覧のすべてに送信することを確認します。
+func TestReadStdinTargets(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received[r.Method+" "+r.URL.Path] = true
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	input := strings.Join([]string{
+		"# cat urls.txt | ultraload -urls-stdin",
+		server.URL + "/a",
+		"",
+		"   " + server.URL + "/b   ",
+		"POST " + server.URL + "/c",
+	}, "\n")
+	specs, err := readStdinTargets(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"GET " + server.URL + "/a", "GET " + server.URL + "/b", "POST " + server.URL + "/c"}
+	if len(specs) != len(want) {
+		t.Fatalf("読み込んだ送信先 = %+v, want %q", specs, want)
+	}
+	for i, spec := range specs {
+		if got := spec.Method + " " + spec.URL; got != want[i] {
+			t.Errorf("%d 件目 = %q, want %q", i+1, got, want[i])
+		}
+	}
+
+	saved := stdinRequests
+	t.Cleanup(func() { stdinRequests = saved })
+	stdinRequests = specs
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"concurrency":  1,
+		"duration":     "100ms",
+		"no_preflight": true,
+	}))
+	if report.TotalRequests < 3 || report.Errors != 0 {
+		t.Fatalf("total=%d errors=%d", report.TotalRequests, report.Errors)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, target := range []string{"GET /a", "GET /b", "POST /c"} {
+		if !received[target] {
+			t.Errorf("%s が送信されていません（受信: %v）", target, received)
+		}
+	}
+
+	if _, err := readStdinTargets(strings.NewReader("# コメントだけ\n\n")); err == nil {
+		t.Error("送信先が1件もない入力がエラーになりません")
+	}
+	if _, err := readStdinTargets(strings.NewReader("GET ://missing-scheme\n")); err == nil {
+		t.Error("解釈できないURLの行がエラーになりません")
+	}
+}
+
+// TestLoadStdinTargets は、パイプからは終端まで読み込み、端末（キャラクターデバイス）からは読まずに
+// パイプかリダイレクトを使うよう案内するエラーを返すことを確認します。
+func TestLoadStdinTargets(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go func() {
+		w.WriteString("http://127.0.0.1:1/x\nhttp://127.0.0.1:1/y\n")
+		w.Close()
+	}()
+	if specs, err := loadStdinTargets(r); err != nil || len(specs) != 2 {
+		t.Errorf("パイプから読み込んだ送信先 = %+v, %v, want 2 件", specs, err)
+	}
+
+	// 端末と同じキャラクターデバイスの /dev/null で代用します
+	tty, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer tty.Close()
+	if _, err := loadStdinTargets(tty); err == nil || !strings.Contains(err.Error(), "パイプかリダイレクト") {
+		t.Errorf("端末からの読み込みのエラー = %v: パイプかリダイレクトを案内するはずです", err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ==============================================================================
+// [セクション24] 実行メタデータのタグ付け (-tag / tags)
+// ==============================================================================
+
+// 多数のテスト結果を整理するため、環境やバージョンなどの任意のラベルを key=value で付与できます。
+// 付与したタグはそのままレポートの tags に記録され、後段のツールでの絞り込みやグループ化に使えます。
+//   - 設定JSONの tags      : そのテストだけに付与します
+//   - サーバーの -tag フラグ : そのサーバーで実行するすべてのテストに付与します（繰り返し指定可。同じキーは設定JSONが優先）
+
+// tagFlags は、繰り返し指定できる -tag key=value フラグの値です（flag.Value を実装します）。
+type tagFlags map[string]string
+
+// defaultTags は、-tag フラグで指定された、すべてのテストに付与するタグです。
+var defaultTags = tagFlags{}
+
+// String は、指定済みのタグをキー順に key=value のカンマ区切りで返します。
+func (t tagFlags) String() string {
+	pairs := make([]string, 0, len(t))
+	for _, k := range slices.Sorted(maps.Keys(t)) {
+		pairs = append(pairs, k+"="+t[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set は、key=value 形式の1つのタグを追加します。
+func (t tagFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("タグは key=value の形式で指定してください: %q", value)
+	}
+	if err := validateTagKey(key); err != nil {
+		return err
+	}
+	t[key] = val
+	return nil
+}
+
+// validateTagKey は、タグのキーとして使える文字列かどうかを検証します。
+// 後段のツールで扱いやすいよう、空文字列・空白・"=" を含むキーは受け付けません。
+func validateTagKey(key string) error {
+	if key == "" {
+		return errors.New("タグのキーが空です")
+	}
+	if strings.ContainsAny(key, "= \t\r\n") {
+		return fmt.Errorf("タグのキーに空白や \"=\" は使用できません: %q", key)
+	}
+	return nil
+}
+
+// applyTags は、設定JSONの tags を検証し、-tag フラグのタグを補って返します（同じキーは設定JSONが優先）。
+// タグが1つもない場合は nil を返します。
+func applyTags(tags map[string]string) (map[string]string, error) {
+	for key := range tags {
+		if err := validateTagKey(key); err != nil {
+			return nil, err
+		}
+	}
+	if len(defaultTags) == 0 {
+		return tags, nil
+	}
+	merged := maps.Clone(map[string]string(defaultTags))
+	maps.Copy(merged, tags)
+	return merged, nil
+}
+
+// commonTags は、すべてのレポートで同じ値を持つタグだけを返します（-merge の統合用）。
+func commonTags(reports []*TestReport) map[string]string {
+	var common map[string]string
+	for key, value := range reports[0].Tags {
+		shared := true
+		for _, report := range reports[1:] {
+			if v, ok := report.Tags[key]; !ok || v != value {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			if common == nil {
+				common = make(map[string]string)
+			}
+			common[key] = value
+		}
+	}
+	return common
+}
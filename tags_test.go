@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestTagsRoundTrip は、設定JSONの tags と -tag フラグのタグが（同じキーは設定JSONを優先して）そのままレポートに記録され、
+// レポートJSONの tags として書き出して読み戻しても同じ値になることを確認します。
+func TestTagsRoundTrip(t *testing.T) {
+	saved := maps.Clone(defaultTags)
+	t.Cleanup(func() { defaultTags = saved })
+	defaultTags = tagFlags{}
+	for _, flagValue := range []string{"region=ap-northeast-1", "environment=default", "note=a=b, c"} {
+		if err := defaultTags.Set(flagValue); err != nil {
+			t.Fatalf("-tag %q: %v", flagValue, err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url": server.URL,
+		"duration":   "100ms",
+		"tags":       map[string]string{"environment": "staging", "version": "1.4.2", "empty": ""},
+	}))
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("レポートをJSONにできません: %v", err)
+	}
+	var decoded struct {
+		Tags map[string]string `json:"tags"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("レポートJSONを解析できません: %v", err)
+	}
+	want := map[string]string{
+		"environment": "staging",
+		"version":     "1.4.2",
+		"empty":       "",
+		"region":      "ap-northeast-1",
+		"note":        "a=b, c",
+	}
+	if !maps.Equal(decoded.Tags, want) {
+		t.Errorf("レポートJSONの tags = %v, want %v", decoded.Tags, want)
+	}
+}
+
+// TestTagValidation は、key=value の形式でない -tag と、空・空白・"=" を含むキーのタグを受け付けないことを確認します。
+func TestTagValidation(t *testing.T) {
+	for _, value := range []string{"staging", "=staging", "env ironment=staging"} {
+		if err := (tagFlags{}).Set(value); err == nil {
+			t.Errorf("-tag %q を受け付けました: エラーになるはずです", value)
+		}
+	}
+
+	for _, key := range []string{"", "bad key", "a=b"} {
+		body, _ := json.Marshal(map[string]any{"target_url": "http://127.0.0.1:1", "tags": map[string]string{key: "x"}})
+		cfg, rec := postConfig(t, string(body), false)
+		if cfg != nil || rec.Code < 400 || !strings.Contains(rec.Body.String(), "タグ") {
+			t.Errorf("tags のキー %q: status=%d body=%q: タグのキーの誤りとして拒否するはずです", key, rec.Code, rec.Body.String())
+		}
+	}
+}
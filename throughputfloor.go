@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ==============================================================================
+// [セクション67] スループットの下限: RPS が落ち込んだらテストを打ち切る (min_rps_floor)
+// ==============================================================================
+
+// 数時間のソークテストの途中でターゲットが劣化し、スループットが崩れたまま残りの時間を走り続けても、得られるのは
+// 劣化した状態の計測値だけです。min_rps_floor を指定すると、テスト中の直近 throughputFloorWindow の RPS
+// （完了したリクエスト数から算出します）を1秒ごとに監視し、下限を下回った状態が min_rps_grace（既定は10秒）の間
+// 続いた時点でテストを停止します。レポートの aborted_reason に "throughput_floor" を、throughput_floor に
+// 下限・停止を決めた時点の RPS・下回っていた時間を記録し、ログにも [Throughput Floor] で警告を出力します。
+// 下限を上回ればそれまでの経過はリセットするため、一時的な落ち込みでは停止しません。
+//
+// テスト開始直後の throughputFloorWindow の間は、窓が埋まっていないため判定しません（接続の確立などで RPS が低いためです）。
+// サーバーの -min-rps-floor・-min-rps-grace フラグで、それぞれを省略したテストの既定値を指定できます。
+//
+// aborted_reason は、テストを予定より早く打ち切った理由です。fail_fast で停止した場合は "fail_fast" を記録します。
+
+// テストを打ち切った理由（TestReport.AbortedReason）
+const (
+	abortedFailFast        = "fail_fast"
+	abortedThroughputFloor = "throughput_floor"
+)
+
+// throughputFloorWindow は、スループットの下限と比べる RPS を算出する直近の窓の長さです。
+const throughputFloorWindow = 5 * time.Second
+
+// throughputFloorTick は、RPS を監視する間隔です。
+const throughputFloorTick = time.Second
+
+// スループットの下限の既定値です（-min-rps-floor・-min-rps-grace フラグ）。
+var (
+	defaultMinRPSFloor float64
+	defaultMinRPSGrace = 10 * time.Second
+)
+
+// ThroughputFloorAbort は、スループットの下限を下回ったためにテストを停止した時点の状況です。
+type ThroughputFloorAbort struct {
+	FloorRPS    float64 `json:"floor_rps"`    // min_rps_floor
+	ObservedRPS float64 `json:"observed_rps"` // 停止を決めた時点の直近の窓の RPS
+	BelowSec    float64 `json:"below_sec"`    // 下限を下回り続けていた時間（秒）
+	AtSec       float64 `json:"at_sec"`       // テスト開始から停止を決めるまでの時間（秒）
+}
+
+// validateThroughputFloor は、min_rps_floor と min_rps_grace にサーバーの既定値を補って検証します。
+func validateThroughputFloor(cfg *TestConfig) error {
+	if cfg.MinRPSFloor == 0 {
+		cfg.MinRPSFloor = defaultMinRPSFloor
+	}
+	if cfg.MinRPSFloor < 0 || cfg.MinRPSGrace < 0 {
+		return fmt.Errorf("min_rps_floor と min_rps_grace には0以上の値を指定してください")
+	}
+	if cfg.MinRPSFloor == 0 {
+		if cfg.MinRPSGrace != 0 {
+			return fmt.Errorf("min_rps_grace を指定する場合は min_rps_floor も指定してください")
+		}
+		return nil
+	}
+	if cfg.MinRPSGrace == 0 {
+		cfg.MinRPSGrace = configDuration(defaultMinRPSGrace)
+	}
+	return nil
+}
+
+// throughputFloor は、直近の RPS を監視し、下限を下回り続けた場合にテストを停止させます。nil の throughputFloor は何もしません。
+type throughputFloor struct {
+	floor  float64
+	grace  time.Duration
+	cancel context.CancelFunc
+	start  time.Time
+	pause  *pauseState
+
+	mu      sync.Mutex
+	aborted *ThroughputFloorAbort
+}
+
+// newThroughputFloor は、min_rps_floor が指定されている場合に throughputFloor を生成します（未指定の場合は nil）。
+func newThroughputFloor(cfg *TestConfig, cancel context.CancelFunc, start time.Time) *throughputFloor {
+	if cfg.MinRPSFloor <= 0 {
+		return nil
+	}
+	return &throughputFloor{floor: cfg.MinRPSFloor, grace: time.Duration(cfg.MinRPSGrace), cancel: cancel, start: start, pause: cfg.pause}
+}
+
+// floorReading は、監視の各時点の完了リクエスト数の累計です。
+type floorReading struct {
+	at    time.Time
+	total uint64
+}
+
+// monitor は、テスト終了まで throughputFloorTick ごとに直近の窓の RPS を算出し、下限を下回り続けた場合にテストを停止させます。
+// 終了時に done を閉じます。
+func (f *throughputFloor) monitor(ctx context.Context, metrics *ResultMetrics, done chan<- struct{}) {
+	defer close(done)
+	if f == nil {
+		return
+	}
+
+	ticker := time.NewTicker(throughputFloorTick)
+	defer ticker.Stop()
+
+	// 窓の始点を求めるため、直近の窓の長さ分の累計値を古い順に保持します（先頭はテスト開始時点の0件）
+	readings := []floorReading{{at: f.start}}
+	var belowSince time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if f.pause.isPaused() {
+				// 一時停止中は判定せず、再開した時点から窓を数え直します
+				readings = []floorReading{{at: now, total: metrics.TotalRequests.Load()}}
+				belowSince = time.Time{}
+				continue
+			}
+			readings = append(readings, floorReading{at: now, total: metrics.TotalRequests.Load()})
+			if now.Sub(f.start) < throughputFloorWindow {
+				continue
+			}
+			// 窓の長さより古い値は、窓の始点になる最新の1つを残して捨てます
+			for len(readings) > 2 && now.Sub(readings[1].at) >= throughputFloorWindow {
+				readings = readings[1:]
+			}
+			oldest, latest := readings[0], readings[len(readings)-1]
+			rps := float64(latest.total-oldest.total) / latest.at.Sub(oldest.at).Seconds()
+
+			if rps >= f.floor {
+				belowSince = time.Time{}
+				continue
+			}
+			if belowSince.IsZero() {
+				belowSince = now
+				log.Printf("[Throughput Floor] 直近 %v の RPS (%.1f) が下限 (%.1f) を下回りました。%v 続いた場合はテストを停止します\n",
+					throughputFloorWindow, rps, f.floor, f.grace)
+			}
+			if below := now.Sub(belowSince); below >= f.grace {
+				f.mu.Lock()
+				f.aborted = &ThroughputFloorAbort{
+					FloorRPS:    f.floor,
+					ObservedRPS: rps,
+					BelowSec:    below.Seconds(),
+					AtSec:       now.Sub(f.start).Seconds(),
+				}
+				f.mu.Unlock()
+				log.Printf("[Throughput Floor] ⛔ RPS が下限 (%.1f) を %v 下回り続けたため、テストを停止します (直近の RPS: %.1f)\n",
+					f.floor, below.Round(time.Millisecond), rps)
+				f.cancel()
+				return
+			}
+		}
+	}
+}
+
+// result は、下限を下回ったためにテストを停止した場合にその状況を返します（停止しなかった場合は nil）。
+func (f *throughputFloor) result() *ThroughputFloorAbort {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.aborted
+}
+
+// abortedReason は、テストを予定より早く打ち切った理由を返します（最後まで実行した場合は空文字列）。
+func abortedReason(report *TestReport) string {
+	switch {
+	case report.ThroughputFloor != nil:
+		return abortedThroughputFloor
+	case report.FailFast != nil:
+		return abortedFailFast
+	}
+	return ""
+}
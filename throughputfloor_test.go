@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestThroughputFloorAborts は、最初の1秒だけ速く、その後は1件に 500ms かかるようになるターゲットに対し、
+// 直近の窓の RPS が min_rps_floor を min_rps_grace の間下回り続けた時点で、20秒のテストが途中で停止し、
+// aborted_reason に "throughput_floor" が記録されることを確認します。
+func TestThroughputFloorAborts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("窓（5秒）が埋まるまで判定しないため、数秒かかります")
+	}
+	var once sync.Once
+	var first time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { first = time.Now() })
+		if time.Since(first) > time.Second {
+			time.Sleep(500 * time.Millisecond)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	const floor = 20
+	start := time.Now()
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":    server.URL,
+		"concurrency":   2,
+		"duration":      "20s",
+		"min_rps_floor": floor,
+		"min_rps_grace": "1s",
+		"no_preflight":  true,
+	}))
+	elapsed := time.Since(start)
+
+	// 1秒後に遅くなった分が窓（5秒）から速い時期を押し出す 6 秒目に下限を下回り、猶予の 1 秒後に停止します
+	if elapsed > 10*time.Second {
+		t.Errorf("テストに %v かかりました: 下限を下回り続けた時点で停止するはずです", elapsed)
+	}
+	if report.AbortedReason != abortedThroughputFloor {
+		t.Fatalf("aborted_reason = %q, want %q", report.AbortedReason, abortedThroughputFloor)
+	}
+	abort := report.ThroughputFloor
+	if abort == nil || abort.FloorRPS != floor || abort.ObservedRPS >= floor || abort.BelowSec < 1 || abort.AtSec < 5 || abort.AtSec > 10 {
+		t.Errorf("throughput_floor = %+v", abort)
+	}
+}
+
+// TestThroughputFloorNotTriggered は、RPS が下限を上回り続ける場合は最後まで実行し、aborted_reason を記録しないことと、
+// min_rps_floor なしの min_rps_grace を拒否することを確認します。
+func TestThroughputFloorNotTriggered(t *testing.T) {
+	if testing.Short() {
+		t.Skip("窓（5秒）が埋まるまで判定しないため、数秒かかります")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":    server.URL,
+		"concurrency":   1,
+		"duration":      "6s",
+		"min_rps_floor": 20,
+		"min_rps_grace": "1s",
+	}))
+	if report.AbortedReason != "" || report.ThroughputFloor != nil {
+		t.Errorf("aborted_reason=%q throughput_floor=%+v: 下限を上回っている間は停止しないはずです", report.AbortedReason, report.ThroughputFloor)
+	}
+
+	if cfg, rec := postConfig(t, `{"target_url":"http://127.0.0.1:1","min_rps_grace":"5s"}`, false); cfg != nil || rec.Code != http.StatusBadRequest {
+		t.Errorf("min_rps_floor なしの min_rps_grace の status = %d, want 400", rec.Code)
+	}
+}
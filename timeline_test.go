@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestSuccessRateTimelineDrop は、最初のリクエストから 1.5 秒後に失敗し始めるターゲットに対し、
+// success_rate_timeline が 0 秒目は 1、失敗し始めた 1 秒目は途中から下がった値、2 秒目は 0 になり、
+// 劣化の始まった時点を1秒単位で特定できることを確認します。
+func TestSuccessRateTimelineDrop(t *testing.T) {
+	const failAfter = 1500 * time.Millisecond
+	var once sync.Once
+	var first time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { first = time.Now() })
+		time.Sleep(5 * time.Millisecond)
+		if time.Since(first) >= failAfter {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":   server.URL,
+		"concurrency":  2,
+		"duration":     "3500ms",
+		"no_preflight": true,
+	}))
+	timeline := report.SuccessRateTimeline
+	if len(timeline) < 3 || timeline[0] == nil || timeline[1] == nil || timeline[2] == nil {
+		t.Fatalf("success_rate_timeline の長さ = %d: 3 秒分の値が記録されるはずです", len(timeline))
+	}
+	if got := *timeline[0]; got != 1 {
+		t.Errorf("0 秒目の成功率 = %.3f, want 1", got)
+	}
+	// 1 秒目の途中（約 0.5 秒の時点）で失敗し始めるため、半分前後の成功率になります
+	if got := *timeline[1]; got < 0.2 || got > 0.8 {
+		t.Errorf("1 秒目の成功率 = %.3f, want 0.2〜0.8（この1秒の途中で失敗し始めたはずです）", got)
+	}
+	for i := 2; i < len(timeline); i++ {
+		if timeline[i] == nil || *timeline[i] != 0 {
+			t.Errorf("%d 秒目の成功率 = %v, want 0", i, timeline[i])
+		}
+	}
+	if len(report.RPSTimeline) != len(timeline) {
+		t.Errorf("rps_timeline の長さ = %d, success_rate_timeline の長さ = %d: 同じ1秒ごとの区切りのはずです", len(report.RPSTimeline), len(timeline))
+	}
+}
+
+// TestNewConnectionsTimeline は、すべての応答に Connection: close を返すターゲットでは new_connections_timeline が
+// 毎秒その1秒間のリクエスト数と同じだけ増え続け、keep-alive で使い回せるターゲットでは最初の1秒にワーカー数だけ開いた後は
+// 0 になることを確認します。
+func TestNewConnectionsTimeline(t *testing.T) {
+	const workers = 2
+	for _, forceClose := range []bool{true, false} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if forceClose {
+				w.Header().Set("Connection", "close")
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		report := runTestLoad(newTestConfig(t, map[string]any{
+			"target_url":   server.URL,
+			"concurrency":  workers,
+			"rate_limit":   200,
+			"duration":     "2500ms",
+			"no_preflight": true,
+		}))
+		timeline, rps := report.NewConnectionsTimeline, report.RPSTimeline
+		if len(timeline) != 2 || len(rps) != 2 {
+			t.Fatalf("new_connections_timeline = %v, rps_timeline = %v: 2 秒分が記録されるはずです", timeline, rps)
+		}
+		for i := range timeline {
+			want := uint64(0)
+			switch {
+			case forceClose:
+				// 1件ごとに接続を張り直すため、毎秒その1秒間に完了したリクエスト数（±ワーカー数）だけ開きます
+				want = rps[i]
+			case i == 0:
+				want = workers
+			}
+			if diff := int64(timeline[i]) - int64(want); diff < -workers || diff > workers || (forceClose && timeline[i] < 100) {
+				t.Errorf("Connection: close=%v: %d 秒目の新規接続数 = %d, want 約 %d（rps_timeline=%v）", forceClose, i, timeline[i], want, rps)
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ==============================================================================
+// [セクション56] トランスポートのタイムアウトの指定 (idle_conn_timeout など)
+// ==============================================================================
+
+// HTTPクライアントの Transport には、リクエスト全体のタイムアウト（timeout）とは別に、接続の各段階のタイムアウトがあります。
+// 従来は固定値（アイドル接続 90秒、TLSハンドシェイク 10秒、100-Continue 1秒、レスポンスヘッダーはリクエストのタイムアウトと同じ）
+// で、HTTP/3 の Transport では別の値を使っていました。これらを設定JSONで指定できるようにし、省略した場合の値はサーバーの
+// フラグで変更できるようにします（フラグの既定値は従来の固定値です）。
+//
+//   idle_conn_timeout        (-idle-conn-timeout)        プールに残したアイドル接続を閉じるまでの時間
+//   tls_handshake_timeout    (-tls-handshake-timeout)    TLSハンドシェイクの完了を待つ時間
+//   response_header_timeout  (-response-header-timeout)  リクエストの送信後、レスポンスヘッダーの受信を待つ時間
+//                                                          （0の場合は timeout と同じです）
+//   expect_continue_timeout  (-expect-continue-timeout)  "Expect: 100-continue" を付けた場合に、ボディの送信前に応答を待つ時間
+//
+// HTTP/3 (http3) の Transport にも同じ値を使います。アイドル接続のタイムアウトは QUIC の接続の無通信タイムアウトに、
+// TLSハンドシェイクのタイムアウトは QUIC のハンドシェイクのタイムアウトになります。ただし、応答しないターゲットを
+// リクエストのタイムアウトより先に検出するため、QUIC のハンドシェイクは timeout の半分を上限とします（http3.go を参照）。
+// HTTP/3 にはレスポンスヘッダーと 100-Continue の個別のタイムアウトがないため、これらは http3 と併用できません。
+
+// サーバーのフラグで変更できる、トランスポートのタイムアウトの既定値です（設定JSONで省略した場合に使います）。
+var (
+	defaultIdleConnTimeout       = 90 * time.Second
+	defaultTLSHandshakeTimeout   = 10 * time.Second
+	defaultResponseHeaderTimeout = time.Duration(0) // 0 の場合はリクエストのタイムアウトと同じ
+	defaultExpectContinueTimeout = 1 * time.Second
+)
+
+// transportTimeouts は、1つのテストで Transport に設定するタイムアウトです。
+type transportTimeouts struct {
+	idleConn       time.Duration
+	tlsHandshake   time.Duration
+	responseHeader time.Duration
+	expectContinue time.Duration
+}
+
+// resolveTransportTimeouts は、cfg の指定とサーバーの既定値から、Transport に設定するタイムアウトを決定します。
+// timeout はリクエスト全体のタイムアウトで、レスポンスヘッダーのタイムアウトが指定されていない場合に使います。
+func resolveTransportTimeouts(cfg *TestConfig, timeout time.Duration) transportTimeouts {
+	pick := func(v configDuration, def time.Duration) time.Duration {
+		if v > 0 {
+			return time.Duration(v)
+		}
+		return def
+	}
+	t := transportTimeouts{
+		idleConn:       pick(cfg.IdleConnTimeout, defaultIdleConnTimeout),
+		tlsHandshake:   pick(cfg.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+		responseHeader: pick(cfg.ResponseHeaderTimeout, defaultResponseHeaderTimeout),
+		expectContinue: pick(cfg.ExpectContinueTimeout, defaultExpectContinueTimeout),
+	}
+	if t.responseHeader == 0 {
+		t.responseHeader = timeout
+	}
+	return t
+}
+
+// validateTransportTimeouts は、設定JSONで指定されたトランスポートのタイムアウトを検証します。
+func validateTransportTimeouts(cfg *TestConfig) error {
+	for _, option := range []struct {
+		name  string
+		value configDuration
+	}{
+		{"idle_conn_timeout", cfg.IdleConnTimeout},
+		{"tls_handshake_timeout", cfg.TLSHandshakeTimeout},
+		{"response_header_timeout", cfg.ResponseHeaderTimeout},
+		{"expect_continue_timeout", cfg.ExpectContinueTimeout},
+	} {
+		if option.value < 0 {
+			return fmt.Errorf("%s には正の時間を指定してください（省略した場合はサーバーの既定値です）: %s", option.name, time.Duration(option.value))
+		}
+	}
+	return nil
+}
+
+// validateTimeoutFlags は、トランスポートのタイムアウトの既定値を指定するフラグを検証します。
+func validateTimeoutFlags() error {
+	for _, option := range []struct {
+		name      string
+		value     time.Duration
+		allowZero bool
+	}{
+		{"-idle-conn-timeout", defaultIdleConnTimeout, false},
+		{"-tls-handshake-timeout", defaultTLSHandshakeTimeout, false},
+		{"-response-header-timeout", defaultResponseHeaderTimeout, true},
+		{"-expect-continue-timeout", defaultExpectContinueTimeout, false},
+	} {
+		if option.value < 0 || (option.value == 0 && !option.allowZero) {
+			return fmt.Errorf("%s には正の時間を指定してください: %s", option.name, option.value)
+		}
+	}
+	return nil
+}
+
+// fallbackRequestTimeout は、timeout が0の場合に使う、1リクエストのタイムアウトの安全値です。
+const fallbackRequestTimeout = 10 * time.Second
+
+// requestTimeout は、1リクエストのタイムアウト（timeout。0の場合は fallbackRequestTimeout）を返します。
+func requestTimeout(cfg *TestConfig) time.Duration {
+	if timeout := time.Duration(cfg.TimeoutSec) * time.Second; timeout > 0 {
+		return timeout
+	}
+	return fallbackRequestTimeout
+}
+
+// withRequestTimeout は、1リクエスト専用の子コンテキストを、requestTimeout の期限付きで parent から生成します。
+//
+// リクエストのタイムアウトは http.Client.Timeout ではなく、このコンテキストの期限で設定します。
+// parent（テストのコンテキスト）のキャンセルは子へ伝わるためテスト終了時には通信中のリクエストも即座に中断され、
+// 一方で子の期限は parent とは独立しているため、テストの残り時間に関係なく、送信から timeout が経過した時点で打ち切られます。
+// 呼び出し元は、レスポンスのボディを読み終えてから（またはリクエストを諦めた時点で）cancel を呼び出してください。
+// 送信側は ctx.Err() でテスト終了による中断かどうかを判定し、リクエスト自身のタイムアウトだけを timed_out として記録します。
+func withRequestTimeout(parent context.Context, cfg *TestConfig) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, requestTimeout(cfg))
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestTransportTimeouts は、設定JSONで指定したトランスポートのタイムアウトと、省略した場合のサーバーの既定値（フラグ）が、
+// 生成した HTTP/1.1・HTTP/2 の Transport と HTTP/3 の Transport にそのまま反映されることを確認します。
+func TestTransportTimeouts(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]any
+		want   transportTimeouts
+	}{
+		{
+			"指定した値",
+			map[string]any{
+				"idle_conn_timeout":       "30s",
+				"tls_handshake_timeout":   "3s",
+				"response_header_timeout": "2s",
+				"expect_continue_timeout": "500ms",
+			},
+			transportTimeouts{idleConn: 30 * time.Second, tlsHandshake: 3 * time.Second, responseHeader: 2 * time.Second, expectContinue: 500 * time.Millisecond},
+		},
+		{
+			// レスポンスヘッダーのタイムアウトは、省略するとリクエストのタイムアウトと同じになります
+			"省略時は既定値",
+			map[string]any{},
+			transportTimeouts{idleConn: 90 * time.Second, tlsHandshake: 10 * time.Second, responseHeader: 6 * time.Second, expectContinue: time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := map[string]any{"target_url": "http://127.0.0.1:1", "timeout": 6}
+			for key, value := range tt.config {
+				config[key] = value
+			}
+			transport, ok := createOptimizedHTTPClient(1, newTestConfig(t, config)).Transport.(*http.Transport)
+			if !ok {
+				t.Fatal("Transport が *http.Transport ではありません")
+			}
+			got := transportTimeouts{
+				idleConn:       transport.IdleConnTimeout,
+				tlsHandshake:   transport.TLSHandshakeTimeout,
+				responseHeader: transport.ResponseHeaderTimeout,
+				expectContinue: transport.ExpectContinueTimeout,
+			}
+			if got != tt.want {
+				t.Errorf("Transport のタイムアウト = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// サーバーのフラグで既定値を変更すると、省略したテストにはその値が使われます
+	savedIdle, savedTLS := defaultIdleConnTimeout, defaultTLSHandshakeTimeout
+	t.Cleanup(func() { defaultIdleConnTimeout, defaultTLSHandshakeTimeout = savedIdle, savedTLS })
+	defaultIdleConnTimeout, defaultTLSHandshakeTimeout = 45*time.Second, 4*time.Second
+	transport := createOptimizedHTTPClient(1, newTestConfig(t, map[string]any{"target_url": "http://127.0.0.1:1"})).Transport.(*http.Transport)
+	if transport.IdleConnTimeout != 45*time.Second || transport.TLSHandshakeTimeout != 4*time.Second {
+		t.Errorf("idle=%v tls=%v, want フラグの既定値 45s・4s", transport.IdleConnTimeout, transport.TLSHandshakeTimeout)
+	}
+
+	// HTTP/3 では、アイドル接続のタイムアウトが QUIC の無通信タイムアウトに、TLSハンドシェイクのタイムアウトが
+	// QUIC のハンドシェイクのタイムアウトになります（ただしリクエストのタイムアウトの半分が上限です）
+	for _, tt := range []struct {
+		tlsHandshake  string
+		wantHandshake time.Duration
+	}{
+		{"2s", 2 * time.Second},
+		{"8s", 3 * time.Second},
+	} {
+		h3, ok := createOptimizedHTTPClient(1, newTestConfig(t, map[string]any{
+			"target_url":            "https://127.0.0.1:1",
+			"http3":                 true,
+			"timeout":               6,
+			"idle_conn_timeout":     "20s",
+			"tls_handshake_timeout": tt.tlsHandshake,
+		})).Transport.(http3Transport)
+		if !ok {
+			t.Fatal("http3 の Transport が http3Transport ではありません")
+		}
+		if got := h3.QUICConfig; got.MaxIdleTimeout != 20*time.Second || got.HandshakeIdleTimeout != tt.wantHandshake {
+			t.Errorf("tls_handshake_timeout=%s: QUIC の無通信タイムアウト = %v, ハンドシェイクのタイムアウト = %v, want 20s・%v",
+				tt.tlsHandshake, got.MaxIdleTimeout, got.HandshakeIdleTimeout, tt.wantHandshake)
+		}
+	}
+}
+
+// TestTransportTimeoutValidation は、負のタイムアウトを拒否することを確認します。
+func TestTransportTimeoutValidation(t *testing.T) {
+	for _, field := range []string{"idle_conn_timeout", "tls_handshake_timeout", "response_header_timeout", "expect_continue_timeout"} {
+		body := `{"target_url":"http://127.0.0.1:1","` + field + `":"-1s"}`
+		if cfg, rec := postConfig(t, body, false); cfg != nil || rec.Code != http.StatusBadRequest {
+			t.Errorf("%s に負の値を指定した場合の status = %d, want 400", field, rec.Code)
+		}
+	}
+}
+
+// newHangingServer は、クライアントが切断する（最大 5 秒）まで応答しないターゲットを起動し、
+// 各リクエストを切断されるまで保持していた時間の一覧を返す関数を返します。
+func newHangingServer(t *testing.T) (*httptest.Server, func() []time.Duration) {
+	t.Helper()
+	var mu sync.Mutex
+	var held []time.Duration
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		mu.Lock()
+		held = append(held, time.Since(start))
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Duration(nil), held...)
+	}
+}
+
+// TestRequestTimeoutIndependentOfTestEnd は、クローズドモデルとオープンモデルのどちらでも、応答しないターゲットへの
+// リクエストがテストの残り時間に関係なく timeout（1秒）の時点で打ち切られて timed_out に数えられることと、
+// テストの終了は timeout より前でも送信中のリクエストへ伝わり、タイムアウトとして記録しないことを確認します。
+func TestRequestTimeoutIndependentOfTestEnd(t *testing.T) {
+	models := []struct {
+		name   string
+		config map[string]any
+	}{
+		{"closed", map[string]any{"concurrency": 1}},
+		{"open", map[string]any{"load_model": loadModelOpen, "rate_limit": 1, "max_in_flight": 5}},
+	}
+	for _, model := range models {
+		t.Run(model.name, func(t *testing.T) {
+			server, held := newHangingServer(t)
+			config := map[string]any{
+				"target_url":   server.URL,
+				"duration":     "2500ms",
+				"timeout":      1,
+				"no_preflight": true,
+			}
+			for key, value := range model.config {
+				config[key] = value
+			}
+			report := runTestLoad(newTestConfig(t, config))
+			if report.TimedOut < 2 || report.TimedOut != uint64(report.Errors) {
+				t.Errorf("timed_out=%d errors=%d: 2.5 秒の間に 2 件以上がタイムアウトするはずです", report.TimedOut, report.Errors)
+			}
+			// タイムアウトしたリクエストは 1 秒で切断され、テスト終了で中断したリクエストはそれより短くなります
+			timedOut := 0
+			for _, d := range held() {
+				if d > 1300*time.Millisecond {
+					t.Errorf("リクエストが %v 保持されました: timeout（1秒）で打ち切られるはずです", d)
+				}
+				if d >= 950*time.Millisecond {
+					timedOut++
+				}
+			}
+			if timedOut < int(report.TimedOut) {
+				t.Errorf("1 秒で切断されたリクエスト = %d 件, timed_out = %d（保持された時間: %v）", timedOut, report.TimedOut, held())
+			}
+
+			// テストの終了（0.5 秒）は、timeout（10秒）を待たずに送信中のリクエストを中断します
+			config["duration"], config["timeout"] = "500ms", 10
+			start := time.Now()
+			ended := runTestLoad(newTestConfig(t, config))
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("テストに %v かかりました: テストの終了は送信中のリクエストへ伝わるはずです", elapsed)
+			}
+			if ended.TimedOut != 0 || ended.Errors != 0 {
+				t.Errorf("timed_out=%d errors=%d: テスト終了で中断したリクエストは記録しないはずです", ended.TimedOut, ended.Errors)
+			}
+		})
+	}
+}
+
+// TestWithRequestTimeout は、リクエストのコンテキストの期限が親の残り時間ではなく timeout で決まり、
+// 親のキャンセルは子へ伝わることを確認します。
+func TestWithRequestTimeout(t *testing.T) {
+	cfg := &TestConfig{TimeoutSec: 3}
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := withRequestTimeout(parent, cfg)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if want := time.Now().Add(3 * time.Second); !ok || deadline.Before(want.Add(-100*time.Millisecond)) || deadline.After(want) {
+		t.Errorf("期限 = %v (%v), want 約 3 秒後", deadline, ok)
+	}
+	cancelParent()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Error("親のキャンセルがリクエストのコンテキストへ伝わりません")
+	}
+	if requestTimeout(&TestConfig{}) != fallbackRequestTimeout {
+		t.Errorf("timeout を省略した場合のタイムアウト = %v, want %v", requestTimeout(&TestConfig{}), fallbackRequestTimeout)
+	}
+}
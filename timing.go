@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http/httptrace"
+	"time"
+)
+
+// ==============================================================================
+// [セクション33] 応答の最初の1バイトまでの時間 (TTFB) とボディを読まないモード
+// ==============================================================================
+
+// レポートのレイテンシは、client.Do が戻った時点（レスポンスヘッダーを受信し終えた時点）までの時間です。
+// これとは別に、httptrace の GotFirstResponseByte でレスポンスの最初の1バイトを受信した時刻を記録し、
+// TTFB (Time To First Byte) の分布として報告します。TTFB はサーバーが処理を終えて応答を返し始めるまでの時間で、
+// ヘッダーが大きい場合やボディの転送が遅い場合でも、サーバー側の処理時間をより直接的に表します。
+// 記録にはリクエストごとにトレースを仕込む必要があるため、"trace": true を指定した場合のみ行います（性能への影響を避けるため）。
+// trace を指定した場合は、ボディを最後まで読み終えるまでの時間（全体の応答時間）も full_response として報告します。
+// 大きなレスポンスでは TTFB と全体の応答時間が大きく離れ、その差がボディの転送時間になります。
+//
+// no_drain_body を指定すると、レスポンスボディを読まずに閉じます。ヘッダーだけの応答時間（HEAD リクエストや
+// TTFB のみが関心事の場合）を測る上級者向けの設定で、ボディの読み捨てにかかるCPUと帯域を節約できます。
+// ただし、ボディを読み切らずに閉じた接続は、未読の部分が小さければ Close 時に net/http が読み捨てて再利用しますが、
+// 大きなレスポンスでは再利用されずに切断されます。その場合はほぼ毎回新しい接続を確立することになり、
+// スループットは大きく低下します。このモードでは TTFB を必ず記録し、レポートに警告を表示します。
+
+// noDrainBodyWarning は、no_drain_body を指定した場合にレポートとログに表示する警告です。
+const noDrainBodyWarning = "no_drain_body が有効です。レスポンスボディを読まずに閉じるため、大きなレスポンスでは接続が再利用されず毎回新規接続になり、スループットが大きく低下します"
+
+// requestTiming は、1件のリクエストの時刻の記録です。1つのリクエストからのみ使用されます。
+type requestTiming struct {
+	firstByte time.Time // レスポンスの最初の1バイトを受信した時刻（未受信の場合はゼロ値）
+}
+
+// recordsTTFB は、この設定で TTFB を記録するかどうかを返します。
+func recordsTTFB(cfg *TestConfig) bool {
+	return cfg.Trace || cfg.NoDrainBody
+}
+
+// withTiming は、最初の1バイトの受信時刻を t に記録するトレースを ctx に追加します。
+// ctx にすでに仕込まれているトレース（接続数やTLSの記録）もそのまま呼び出されます。
+func withTiming(ctx context.Context, t *requestTiming) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			t.firstByte = time.Now()
+		},
+	})
+}
+
+// addTTFB は、1件の TTFB を記録します。
+func (rm *ResultMetrics) addTTFB(d time.Duration) {
+	rm.mu.Lock()
+	rm.ttfbLatencies = append(rm.ttfbLatencies, d)
+	rm.mu.Unlock()
+}
+
+// addFullResponse は、1件のボディの読み終わりまでの時間を記録します。
+func (rm *ResultMetrics) addFullResponse(d time.Duration) {
+	rm.mu.Lock()
+	rm.fullLatencies = append(rm.fullLatencies, d)
+	rm.mu.Unlock()
+}
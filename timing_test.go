@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newDelayedBodyServer は、ヘッダーをすぐに送信し、delay 後に size バイトのボディを送信するターゲットを起動します。
+func newDelayedBodyServer(t *testing.T, delay time.Duration, size int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Write(make([]byte, size))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// parseSummaryDuration は、LatencySummary の値（formatDuration の出力）を time.Duration に戻します。
+func parseSummaryDuration(t *testing.T, s string) time.Duration {
+	t.Helper()
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		t.Fatalf("レイテンシ %q を解析できません: %v", s, err)
+	}
+	return d
+}
+
+// TestNoDrainBody は、no_drain_body を指定すると、ボディの到着を待たずに成功として記録して TTFB を報告し、
+// 読まなかった大きなボディの接続は再利用されないことと、その旨の警告がレポートに出力されることを確認します。
+func TestNoDrainBody(t *testing.T) {
+	server := newDelayedBodyServer(t, 200*time.Millisecond, 1<<20)
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":    server.URL,
+		"concurrency":   2,
+		"duration":      "500ms",
+		"no_drain_body": true,
+		"no_preflight":  true,
+	}))
+
+	// ボディを待つ場合は各ワーカーが 200ms ごとにしか完了しません
+	if report.TotalRequests < 20 || report.Errors != 0 {
+		t.Fatalf("total=%d errors=%d: ボディを待たずに次のリクエストを送信するはずです", report.TotalRequests, report.Errors)
+	}
+	if report.TTFB == nil || report.TTFB.Samples != report.TotalRequests {
+		t.Fatalf("ttfb = %+v: すべてのリクエストの TTFB が記録されるはずです (total=%d)", report.TTFB, report.TotalRequests)
+	}
+	if p99 := parseSummaryDuration(t, report.TTFB.P99); p99 >= 100*time.Millisecond {
+		t.Errorf("ttfb.p99 = %v: ボディの遅延 (200ms) を含まないはずです", p99)
+	}
+	if report.FullResponse != nil {
+		t.Errorf("full_response = %+v: ボディを読まないため記録されないはずです", report.FullResponse)
+	}
+	if atLeast := uint64(report.TotalRequests) - 2; report.ConnectionsOpened < atLeast {
+		t.Errorf("connections_opened = %d (total=%d): 未読のボディが大きい接続は再利用されないはずです", report.ConnectionsOpened, report.TotalRequests)
+	}
+	if !slices.Contains(report.Warnings, noDrainBodyWarning) {
+		t.Errorf("warnings = %q: 接続が再利用されない旨の警告が出力されるはずです", report.Warnings)
+	}
+}
+
+// TestTraceTTFB は、trace を指定すると、ヘッダーの送信後にボディを遅らせるターゲットに対し、TTFB がボディの遅延を含まず
+// 全体の応答時間（full_response）を大きく下回ることと、trace を指定しない場合はどちらも記録しないことを確認します。
+func TestTraceTTFB(t *testing.T) {
+	const delay = 150 * time.Millisecond
+	server := newDelayedBodyServer(t, delay, 1024)
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":  server.URL,
+		"concurrency": 2,
+		"duration":    "700ms",
+		"trace":       true,
+	}))
+	if report.TTFB == nil || report.FullResponse == nil || report.TTFB.Samples == 0 || report.FullResponse.Samples != report.TTFB.Samples {
+		t.Fatalf("ttfb = %+v, full_response = %+v: 同じ件数の TTFB と全体の応答時間が記録されるはずです", report.TTFB, report.FullResponse)
+	}
+	ttfb := parseSummaryDuration(t, report.TTFB.P99)
+	full := parseSummaryDuration(t, report.FullResponse.P50)
+	if ttfb >= delay/3 || full < delay {
+		t.Errorf("ttfb.p99 = %v, full_response.p50 = %v: TTFB はボディの遅延 %v を含まず、全体の応答時間は含むはずです", ttfb, full, delay)
+	}
+
+	plain := runTestLoad(newTestConfig(t, map[string]any{"target_url": server.URL, "concurrency": 1, "duration": "200ms"}))
+	if plain.TTFB != nil || plain.FullResponse != nil {
+		t.Errorf("ttfb = %+v, full_response = %+v: trace を指定しない場合は記録しないはずです", plain.TTFB, plain.FullResponse)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestTLSServer は、TLSのバージョンを version だけに固定した HTTPS のターゲットを起動します。
+func newTestTLSServer(t *testing.T, version uint16) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{MinVersion: version, MaxVersion: version}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestTLSVersionRange は、tls_min_version・tls_max_version で指定した範囲のバージョンでのみハンドシェイクし、
+// 範囲外のバージョンに固定されたターゲットへの接続は tls エラーとして記録されることを確認します。
+func TestTLSVersionRange(t *testing.T) {
+	tls12, tls13 := newTestTLSServer(t, tls.VersionTLS12), newTestTLSServer(t, tls.VersionTLS13)
+	tests := []struct {
+		name        string
+		server      *httptest.Server
+		min, max    string
+		wantVersion string // 空の場合はハンドシェイクが失敗するはずです
+	}{
+		{"1.2 固定のターゲットへ 1.2 まで", tls12, "", "1.2", "TLS 1.2"},
+		{"1.3 固定のターゲットへ 1.3 から", tls13, "1.3", "", "TLS 1.3"},
+		{"1.2 固定のターゲットへ 1.3 から", tls12, "1.3", "", ""},
+		{"1.3 固定のターゲットへ 1.2 まで", tls13, "1.0", "1.2", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := runTestLoad(newTestConfig(t, map[string]any{
+				"target_url":      tt.server.URL,
+				"concurrency":     2,
+				"duration":        "200ms",
+				"tls_min_version": tt.min,
+				"tls_max_version": tt.max,
+				"no_preflight":    true,
+			}))
+			if report.TotalRequests == 0 {
+				t.Fatalf("リクエストが送信されていません: %s", report.ErrorMsg)
+			}
+			if tt.wantVersion == "" {
+				if report.Success != 0 || report.ErrorKinds[errKindTLS] != uint64(report.Errors) {
+					t.Errorf("success=%d error_kinds=%v: 範囲外のバージョンは %s エラーになるはずです", report.Success, report.ErrorKinds, errKindTLS)
+				}
+				return
+			}
+			if report.Errors != 0 || len(report.TLSVersions) != 1 || report.TLSVersions[tt.wantVersion] == 0 {
+				t.Errorf("errors=%d tls_versions=%v: %s でハンドシェイクするはずです", report.Errors, report.TLSVersions, tt.wantVersion)
+			}
+		})
+	}
+}
+
+// TestTLSVersionValidation は、未知のバージョン文字列と、min が max より新しい指定が 400 になることを確認します。
+func TestTLSVersionValidation(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"target_url":"https://127.0.0.1:1","tls_min_version":"1.4"}`, "tls_min_version=\"1.4\""},
+		{`{"target_url":"https://127.0.0.1:1","tls_max_version":"TLS1.2"}`, "tls_max_version=\"TLS1.2\""},
+		{`{"target_url":"https://127.0.0.1:1","tls_min_version":"1.3","tls_max_version":"1.2"}`, "より新しいバージョン"},
+	}
+	for _, tt := range tests {
+		cfg, rec := postConfig(t, tt.body, false)
+		if cfg != nil || rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", tt.body, rec.Code)
+			continue
+		}
+		var report TestReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("レスポンスを解析できません: %v", err)
+		}
+		if !strings.Contains(report.ErrorMsg, tt.want) {
+			t.Errorf("error_msg = %q, want %q を含む", report.ErrorMsg, tt.want)
+		}
+	}
+
+	for _, version := range []string{"1.0", "1.1", "1.2", "1.3"} {
+		if cfg, rec := postConfig(t, `{"target_url":"https://127.0.0.1:1","tls_min_version":"`+version+`"}`, false); cfg == nil {
+			t.Errorf("tls_min_version=%s: status = %d: 受け付けるはずです", version, rec.Code)
+		}
+	}
+}
+
+// writeTestClientCert は、自己署名のクライアント証明書と秘密鍵を dir に PEM で書き出し、そのパスと、
+// 証明書を信頼するための CertPool を返します。
+func writeTestClientCert(t *testing.T, dir string) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ultraload-test-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+// TestClientCert は、クライアント証明書を必須とするターゲットに、client_cert・client_key を指定すると接続でき、
+// 指定しないと tls_client_cert エラーとして記録されることを、TLS 1.2 と 1.3 の両方で確認します。
+func TestClientCert(t *testing.T) {
+	certFile, keyFile, pool := writeTestClientCert(t, t.TempDir())
+	for _, version := range []string{"1.2", "1.3"} {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool, MaxVersion: tlsVersions[version]}
+		server.StartTLS()
+		t.Cleanup(server.Close)
+
+		run := func(withCert bool) *TestReport {
+			fields := map[string]any{
+				"target_url":   server.URL,
+				"concurrency":  2,
+				"duration":     "200ms",
+				"no_preflight": true,
+			}
+			if withCert {
+				fields["client_cert"], fields["client_key"] = certFile, keyFile
+			}
+			return runTestLoad(newTestConfig(t, fields))
+		}
+		if report := run(true); report.TotalRequests == 0 || report.Errors != 0 {
+			t.Errorf("TLS %s: 証明書ありで total=%d errors=%d error_kinds=%v: 接続できるはずです (%s)",
+				version, report.TotalRequests, report.Errors, report.ErrorKinds, report.ErrorMsg)
+		}
+		if report := run(false); report.TotalRequests == 0 || report.Success != 0 || report.ErrorKinds[errKindTLSClientCert] != uint64(report.Errors) {
+			t.Errorf("TLS %s: 証明書なしで total=%d success=%d error_kinds=%v: すべて %s になるはずです",
+				version, report.TotalRequests, report.Success, report.ErrorKinds, errKindTLSClientCert)
+		}
+	}
+
+	// 片方だけの指定と、読み込めないファイルは設定の時点で拒否します
+	for _, body := range []string{
+		`{"target_url":"https://127.0.0.1:1","client_cert":"` + certFile + `"}`,
+		`{"target_url":"https://127.0.0.1:1","client_cert":"` + keyFile + `","client_key":"` + certFile + `"}`,
+	} {
+		if cfg, rec := postConfig(t, body, false); cfg != nil || rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ==============================================================================
+// [セクション30] 全リクエストのトレース出力 (trace_out)
+// ==============================================================================
+
+// レポートの集計値やパーセンタイルでは、「開始から12秒後の0.3秒間だけ全リクエストが遅延した」のような
+// 時間方向の構造が見えません。trace_out にファイルパスを指定すると、完了したすべてのリクエスト
+// （WebSocketモードではメッセージ）を1件1行のJSON（NDJSON）でサーバーのローカルファイルシステムへ書き出します。
+// 独自の可視化（フレームグラフ風の表示やヒートマップ）の入力として使うことを想定しています。
+//
+// 【フォーマット】 1行に1件、次のフィールドを持つJSONオブジェクトです。
+//   o : テスト開始からリクエストの送信開始までの時間（ナノ秒）
+//   d : 所要時間（ナノ秒）
+//   s : HTTPステータスコード（応答を受信できなかった場合とWebSocketのメッセージは0）
+//   e : エラー種別（"timeout" / "network" / "tls" / "response_too_large" など。成功時は省略）
+// 行はリクエストの完了順に書き込まれるため、o はおおむね昇順ですが、所要時間の長いリクエストの分だけ前後します。
+// 書き出したファイルは、replay_trace（-replay）で同じ時刻どおりに再生できます（replaytrace.go を参照）。
+//
+// 【ファイルサイズ】 1件あたり約40〜60バイトです。10万RPSで60秒のテストでは600万件・約300MBになるため、
+// 長時間・高RPSのテストでは十分な空き容量を確保してください。書き込みはバッファリングされ、
+// 全ワーカーで1つのロックを共有します（ロック内ではメモリへのコピーのみを行います）。
+
+// traceErrNetwork は、原因を特定できなかった通信エラーのトレース上のエラー種別です。
+const traceErrNetwork = "network"
+
+// traceErrTimeout は、タイムアウトによる通信エラーのトレース上のエラー種別です。
+const traceErrTimeout = "timeout"
+
+// sample は、記録経路を流れる1件のリクエストの結果です。
+type sample struct {
+	offset  time.Duration // テスト開始から送信開始までの時間（トレース出力時に設定されます）
+	dur     time.Duration // 所要時間
+	status  int           // HTTPステータスコード（応答がない場合は0）
+	errKind string        // エラー種別（成功時は空文字）
+
+	coldStart bool // ワーカーの最初のリクエスト（exclude_cold_start の指定時のみ。coldstart.go を参照）
+}
+
+// traceWriter は、sample を NDJSON としてファイルへ書き出します。複数のワーカーから同時に呼び出せます。
+type traceWriter struct {
+	mu      sync.Mutex
+	f       *os.File
+	w       *bufio.Writer
+	line    []byte // 1行分の組み立て用バッファ（mu で保護）
+	start   time.Time
+	records uint64
+}
+
+// openTraceWriter は、path にトレースファイルを作成します。start はオフセットの起点となるテスト開始時刻です。
+func openTraceWriter(path string, start time.Time) (*traceWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &traceWriter{f: f, w: bufio.NewWriterSize(f, 1<<20), start: start}, nil
+}
+
+// write は、1件の sample を書き出します（nil の traceWriter は何もしません）。
+// 書き込みのエラーは bufio.Writer が保持し、Close でまとめて返されます。
+func (t *traceWriter) write(s sample) {
+	if t == nil {
+		return
+	}
+	s.offset = time.Since(t.start) - s.dur
+
+	t.mu.Lock()
+	line := append(t.line[:0], `{"o":`...)
+	line = strconv.AppendInt(line, int64(s.offset), 10)
+	line = append(line, `,"d":`...)
+	line = strconv.AppendInt(line, int64(s.dur), 10)
+	line = append(line, `,"s":`...)
+	line = strconv.AppendInt(line, int64(s.status), 10)
+	if s.errKind != "" {
+		line = append(line, `,"e":`...)
+		line = strconv.AppendQuote(line, s.errKind)
+	}
+	line = append(line, "}\n"...)
+	t.w.Write(line)
+	t.line = line
+	t.records++
+	t.mu.Unlock()
+}
+
+// Close は、バッファを書き出してファイルを閉じ、書き出した件数を返します。
+func (t *traceWriter) Close() (uint64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.w.Flush(); err != nil {
+		t.f.Close()
+		return t.records, err
+	}
+	return t.records, t.f.Close()
+}
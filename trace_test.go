@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestTraceOut は、trace_out のファイルに完了したすべてのリクエストが1件1行で書き出され、件数がレポートと一致し、
+// オフセットが実行時間内でおおむね昇順（前後するのは所要時間の分まで）になり、ステータスが記録されることを確認します。
+func TestTraceOut(t *testing.T) {
+	const delay = 10 * time.Millisecond
+	var n atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		if n.Add(1)%2 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "trace.ndjson")
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":  server.URL,
+		"concurrency": 2,
+		"duration":    "300ms",
+		"trace_out":   path,
+	}))
+	if report.TraceError != "" || report.TracedTo != path {
+		t.Fatalf("traced_to=%q trace_error=%q", report.TracedTo, report.TraceError)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var records, maxOffset int64
+	statuses := make(map[int]int)
+	end := int64(report.ActualDurationSec*float64(time.Second)) + int64(100*time.Millisecond)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec struct {
+			O *int64 `json:"o"`
+			D int64  `json:"d"`
+			S int    `json:"s"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.O == nil {
+			t.Fatalf("%d 行目を解析できません: %q: %v", records+1, scanner.Text(), err)
+		}
+		if *rec.O < 0 || rec.D < int64(delay) || *rec.O+rec.D > end {
+			t.Errorf("%d 行目: o=%d d=%d: 実行時間内の送信開始と所要時間になるはずです", records+1, *rec.O, rec.D)
+		}
+		// 完了順に書き込むため、それまでの最大値より所要時間の分だけ小さくなることはあります
+		// （完了時刻の取得から書き込みまでの間に他のワーカーが割り込む分として、delay だけ余裕を持たせます）
+		if *rec.O < maxOffset-rec.D-int64(delay) {
+			t.Errorf("%d 行目: o=%d が、それまでの最大値 %d より所要時間 %d 以上前にさかのぼっています", records+1, *rec.O, maxOffset, rec.D)
+		}
+		maxOffset = max(maxOffset, *rec.O)
+		statuses[rec.S]++
+		records++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if records < 10 || records != int64(report.TotalRequests) || uint64(records) != report.TraceRecords {
+		t.Errorf("トレースは %d 件, total_requests=%d, trace_records=%d: すべてのリクエストが1件ずつ書き出されるはずです",
+			records, report.TotalRequests, report.TraceRecords)
+	}
+	if uint64(statuses[200]) != report.StatusCodes["200"] || uint64(statuses[503]) != report.StatusCodes["503"] {
+		t.Errorf("トレースのステータス = %v, status_codes = %v", statuses, report.StatusCodes)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// ==============================================================================
+// [セクション70] トラフィックの形: 目標レートを時間とともに変化させる (traffic_shape)
+// ==============================================================================
+
+// rate_limit による一定のレートや、ランプアップのような単調な変化は、昼夜で波打つ利用者のトラフィックや、
+// キャンペーンの開始などで瞬間的に跳ね上がるトラフィックとは形が異なります。traffic_shape を指定すると、
+// rate_limit を基準のレートとして、目標レートを時間の関数として変化させます。
+//
+//   traffic_shape = "sine" : 目標レート = rate_limit × (1 + shape_amplitude × sin(2π t / shape_period))
+//                            shape_amplitude（0〜1、既定は0.5）は振れ幅で、平均のレートは rate_limit のままです。
+//   traffic_shape = "burst": 各 shape_period の先頭の shape_burst_length（既定は周期の10%）の間だけ、
+//                            目標レートを rate_limit × shape_burst_factor（既定は5倍）に引き上げます。
+//
+// shape_period の既定値は60秒です。クローズドモデルでは各ワーカーが目標レートの 1/concurrency ずつを、
+// オープンモデルではディスパッチャーが目標レートのとおりにリクエストを発生させます。各ワーカーは、テスト開始からの
+// 目標の累計件数（目標レートの積分）に自分の送信数が追いつくまで待機するため、レートが急に変わっても平均のずれは累積しません。
+// 目標レートが低い区間でも、待機は shapeMaxSleep ごとに見直すため、レートが上がればすぐに送信を再開します。
+// rate_jitter は、ワーカーごとの位相のずらしと、各送信の前後のゆらぎ（送信間隔に対する比率）としてそのまま使えます。
+//
+// レポートの target_rps_timeline には各秒の目標レートを、rps_timeline と同じインデックスで記録します。
+// 両者を並べると、ターゲットが目標の形にどこまで追従できたか（飽和して頭打ちになった時点など）を確認できます。
+// サーバーの -shape・-shape-period フラグで、rate_limit を指定したテストの既定値を指定できます。
+// HTTPモードで rate_limit を指定したテストでのみ使用でき、replay_trace とは併用できません。
+
+// トラフィックの形（TestConfig.TrafficShape）
+const (
+	shapeSine  = "sine"
+	shapeBurst = "burst"
+)
+
+// トラフィックの形のパラメーターの既定値です。
+const (
+	defaultShapeAmplitude   = 0.5
+	defaultShapeBurstFactor = 5.0
+	shapeBurstLengthRatio   = 0.1 // shape_burst_length を省略した場合の、周期に対するバーストの長さの比率
+)
+
+// shapeMaxSleep は、目標レートに従って待機する場合に、1度に待機する時間の上限です。
+const shapeMaxSleep = 100 * time.Millisecond
+
+// トラフィックの形の既定値です（-shape・-shape-period フラグ）。
+var (
+	defaultTrafficShape string
+	defaultShapePeriod  = 60 * time.Second
+)
+
+// validateTrafficShape は、traffic_shape とそのパラメーターにサーバーの既定値を補って検証します。
+func validateTrafficShape(cfg *TestConfig) error {
+	if cfg.TrafficShape == "" && defaultTrafficShape != "" && cfg.RateLimit > 0 && cfg.Mode == modeHTTP && cfg.ReplayTrace == "" {
+		cfg.TrafficShape = defaultTrafficShape
+	}
+	if cfg.TrafficShape == "" {
+		if cfg.ShapePeriod != 0 || cfg.ShapeAmplitude != 0 || cfg.ShapeBurstFactor != 0 || cfg.ShapeBurstLength != 0 {
+			return fmt.Errorf("shape_period・shape_amplitude・shape_burst_factor・shape_burst_length を指定する場合は traffic_shape も指定してください")
+		}
+		return nil
+	}
+	if cfg.RateLimit <= 0 || cfg.Mode != modeHTTP || cfg.ReplayTrace != "" {
+		return fmt.Errorf("traffic_shape は、HTTPモードで rate_limit（基準のレート）を指定した場合のみ使用できます（replay_trace とは併用できません）")
+	}
+	if cfg.ShapePeriod < 0 || cfg.ShapeAmplitude < 0 || cfg.ShapeBurstFactor < 0 || cfg.ShapeBurstLength < 0 {
+		return fmt.Errorf("shape_period・shape_amplitude・shape_burst_factor・shape_burst_length には0以上の値を指定してください")
+	}
+	if cfg.ShapePeriod == 0 {
+		cfg.ShapePeriod = configDuration(defaultShapePeriod)
+	}
+
+	switch cfg.TrafficShape {
+	case shapeSine:
+		if cfg.ShapeBurstFactor != 0 || cfg.ShapeBurstLength != 0 {
+			return fmt.Errorf("shape_burst_factor・shape_burst_length は traffic_shape が \"burst\" の場合にのみ指定できます")
+		}
+		if cfg.ShapeAmplitude == 0 {
+			cfg.ShapeAmplitude = defaultShapeAmplitude
+		}
+		if cfg.ShapeAmplitude > 1 {
+			return fmt.Errorf("shape_amplitude は 0〜1 の範囲で指定してください（1を超えると目標レートが負になるためです）: %v", cfg.ShapeAmplitude)
+		}
+	case shapeBurst:
+		if cfg.ShapeAmplitude != 0 {
+			return fmt.Errorf("shape_amplitude は traffic_shape が \"sine\" の場合にのみ指定できます")
+		}
+		if cfg.ShapeBurstFactor == 0 {
+			cfg.ShapeBurstFactor = defaultShapeBurstFactor
+		}
+		if cfg.ShapeBurstLength == 0 {
+			cfg.ShapeBurstLength = configDuration(float64(cfg.ShapePeriod) * shapeBurstLengthRatio)
+		}
+		if cfg.ShapeBurstFactor < 1 || cfg.ShapeBurstLength >= cfg.ShapePeriod {
+			return fmt.Errorf("shape_burst_factor は1以上、shape_burst_length は shape_period より短い時間で指定してください")
+		}
+	default:
+		return fmt.Errorf("未対応の traffic_shape です: %q (sine または burst を指定してください)", cfg.TrafficShape)
+	}
+	return nil
+}
+
+// trafficShape は、テスト開始からの経過時間に対する全体の目標レートです。
+type trafficShape struct {
+	kind      string
+	base      float64 // rate_limit（リクエスト/秒）
+	period    float64 // 周期（秒）
+	amplitude float64 // sine の振れ幅
+	factor    float64 // burst の倍率
+	burstLen  float64 // burst の長さ（秒）
+	start     time.Time
+}
+
+// newTrafficShape は、traffic_shape が指定されている場合に、start を起点とする trafficShape を生成します（未指定の場合は nil）。
+func newTrafficShape(cfg *TestConfig, start time.Time) *trafficShape {
+	if cfg.TrafficShape == "" {
+		return nil
+	}
+	return &trafficShape{
+		kind:      cfg.TrafficShape,
+		base:      cfg.RateLimit,
+		period:    time.Duration(cfg.ShapePeriod).Seconds(),
+		amplitude: cfg.ShapeAmplitude,
+		factor:    cfg.ShapeBurstFactor,
+		burstLen:  time.Duration(cfg.ShapeBurstLength).Seconds(),
+		start:     start,
+	}
+}
+
+// rateAt は、経過時間 elapsed の時点の目標レート（リクエスト/秒）を返します。
+func (s *trafficShape) rateAt(elapsed time.Duration) float64 {
+	t := elapsed.Seconds()
+	if s.kind == shapeBurst {
+		if math.Mod(t, s.period) < s.burstLen {
+			return s.base * s.factor
+		}
+		return s.base
+	}
+	return s.base * (1 + s.amplitude*math.Sin(2*math.Pi*t/s.period))
+}
+
+// cumulative は、テスト開始から経過時間 elapsed までの目標の累計件数（rateAt の積分）を返します。
+func (s *trafficShape) cumulative(elapsed time.Duration) float64 {
+	t := elapsed.Seconds()
+	if s.kind == shapeBurst {
+		cycles := math.Floor(t / s.period)
+		inBurst := cycles*s.burstLen + min(t-cycles*s.period, s.burstLen)
+		return s.base*t + s.base*(s.factor-1)*inBurst
+	}
+	return s.base * (t + s.amplitude*s.period/(2*math.Pi)*(1-math.Cos(2*math.Pi*t/s.period)))
+}
+
+// newShapedPacer は、目標レートの share の割合（ワーカーの場合は 1/concurrency）で送信する pacer を生成します。
+func newShapedPacer(shape *trafficShape, share, jitter float64, rng *rand.Rand) *pacer {
+	p := &pacer{jitter: jitter, rng: rng, shape: shape, share: share}
+	// ジッターが有効な場合は、ワーカー間で送信の位相が重ならないよう最初のスロットをずらします
+	if jitter > 0 {
+		p.slot = rng.Float64() * jitter
+	}
+	return p
+}
+
+// waitShaped は、目標の累計件数のうち自分の割合が次のスロットに達するまで待機します。ctx がキャンセルされた場合は false を返します。
+// 応答が遅れてスロットを過ぎていた場合は待たずに送信します（pacer.Wait と同じです）。
+func (p *pacer) waitShaped(ctx context.Context) bool {
+	due := p.slot
+	if p.jitter > 0 {
+		due += (p.rng.Float64() - 0.5) * p.jitter
+	}
+	p.slot++
+
+	for {
+		elapsed := time.Since(p.shape.start)
+		owed := due - p.share*p.shape.cumulative(elapsed)
+		if owed <= 0 {
+			return ctx.Err() == nil
+		}
+		// 目標レートが変わり得るため、到達までの見込み時間と shapeMaxSleep の短い方だけ待って見直します
+		d := shapeMaxSleep
+		if rate := p.share * p.shape.rateAt(elapsed); rate > 0 {
+			d = min(d, time.Duration(owed/rate*float64(time.Second))+1)
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestTrafficShapeSine は、周期 2 秒・振れ幅 0.5 の sine を指定すると、1秒ごとの目標レート（target_rps_timeline）が
+// rate_limit を中心に「高い秒」と「低い秒」を交互に繰り返し、実際の rps_timeline もそれに追従して波打つことを確認します。
+func TestTrafficShapeSine(t *testing.T) {
+	if testing.Short() {
+		t.Skip("4 秒かかるため -short では省略します")
+	}
+	const (
+		base      = 100.0
+		amplitude = 0.5
+		period    = 2.0
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	report := runTestLoad(newTestConfig(t, map[string]any{
+		"target_url":      server.URL,
+		"concurrency":     4,
+		"rate_limit":      base,
+		"traffic_shape":   "sine",
+		"shape_period":    "2s",
+		"shape_amplitude": amplitude,
+		"duration":        "4s",
+		"no_preflight":    true,
+	}))
+	target, rps := report.TargetRPSTimeline, report.RPSTimeline
+	if len(target) < 3 || len(rps) != len(target) {
+		t.Fatalf("target_rps_timeline = %v, rps_timeline = %v: 3 秒分以上の同じ長さの値が記録されるはずです", target, rps)
+	}
+
+	// 周期 2 秒の sine を1秒ごとに平均すると、前半の1秒は base×(1+0.5×2/π)、後半の1秒は base×(1−0.5×2/π) になります
+	swing := base * amplitude * 2 / math.Pi
+	for i := range target {
+		want := base + swing
+		if math.Mod(float64(i), period) >= period/2 {
+			want = base - swing
+		}
+		if math.Abs(target[i]-want) > want*0.1 {
+			t.Errorf("%d 秒目の目標レート = %.1f, want 約 %.1f", i, target[i], want)
+		}
+		if got := float64(rps[i]); math.Abs(got-want) > want*0.25 {
+			t.Errorf("%d 秒目の RPS = %.0f, want 約 %.1f（目標レートに追従するはずです）", i, got, want)
+		}
+	}
+	for i := 1; i < len(rps); i++ {
+		rising := math.Mod(float64(i), period) < period/2
+		if rising && rps[i] <= rps[i-1] || !rising && rps[i] >= rps[i-1] {
+			t.Errorf("rps_timeline = %v: 高い秒と低い秒が交互に並ぶはずです", rps)
+			break
+		}
+	}
+}
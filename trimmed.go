@@ -0,0 +1,68 @@
+package main
+
+import (
+	"time"
+)
+
+// ==============================================================================
+// [セクション54] トリム平均: 両端の外れ値を除いた平均 (-trimmed-mean)
+// ==============================================================================
+
+// レイテンシの分布は右に長い裾を持つことが多く、ごく一部の極端に遅い応答（GC の停止やリトライなど）に
+// 算術平均が大きく引っ張られて、「平均的な」応答時間の感覚とずれてしまいます。
+// -trimmed-mean に割合 f（例: 0.01）を指定してサーバーを起動すると、レイテンシを昇順に並べたうえで
+// 下側と上側からそれぞれ f の割合のサンプルを取り除いた残りの平均を、通常の平均と並べてレポートの trimmed_mean に記録します。
+// f は 0 以上 0.5 未満で、0（既定）の場合は算出しません。
+//
+// 母数はパーセンタイルと同じく保持しているサンプルのため、リザーバーサンプリングが作動した場合は推定値になります。
+// -merge で統合する場合は、同じ割合で算出したレポート同士をサンプル数で加重平均した近似値です。
+
+// maxTrimmedMeanFraction は、-trimmed-mean に指定できる割合の上限（この値自体は含みません）です。
+// 両端から 0.5 ずつ取り除くと、サンプルが残らないためです。
+const maxTrimmedMeanFraction = 0.5
+
+// trimmedMeanFraction は、トリム平均で両端からそれぞれ取り除くサンプルの割合です（-trimmed-mean フラグ。0の場合は算出しません）。
+var trimmedMeanFraction = 0.0
+
+// trimmedMean は、昇順にソート済みの sorted の両端から fraction の割合ずつを取り除いた残りの平均を返します。
+// 全件がソートされていなくても、orderLatencies で境界の位置を確定させていれば同じ値になります。
+// 取り除く件数は切り捨てで求め、サンプルが少なく1件も取り除けない場合は通常の平均と同じ値になります。
+// sorted が空の場合は ok に false を返します。
+func trimmedMean(sorted []time.Duration, fraction float64) (mean time.Duration, ok bool) {
+	if len(sorted) == 0 {
+		return 0, false
+	}
+	cut := int(float64(len(sorted)) * fraction)
+	kept := sorted[cut : len(sorted)-cut]
+	if len(kept) == 0 {
+		// fraction が 0.5 未満であれば起こりませんが、念のため中央の値を返します
+		return sorted[len(sorted)/2], true
+	}
+	// 数百万件のナノ秒を int64 で合計すると桁あふれの恐れがあるため、float64 で合計します
+	var sum float64
+	for _, d := range kept {
+		sum += float64(d)
+	}
+	return time.Duration(sum / float64(len(kept))), true
+}
+
+// mergeTrimmedMean は、複数レポートのトリム平均をサンプル数で加重平均して統合します。
+// 割合が異なるレポートが含まれる場合や、トリム平均を持つレポートがない場合は空文字列を返します。
+func mergeTrimmedMean(reports []*TestReport) string {
+	var sum, samples float64
+	for _, report := range reports {
+		if report.TrimmedMean == "" || report.TrimmedMeanFraction != reports[0].TrimmedMeanFraction {
+			return ""
+		}
+		d, err := time.ParseDuration(report.TrimmedMean)
+		if err != nil {
+			return ""
+		}
+		sum += float64(d) * float64(report.LatencySamples)
+		samples += float64(report.LatencySamples)
+	}
+	if samples == 0 {
+		return ""
+	}
+	return formatDuration(time.Duration(sum / samples))
+}
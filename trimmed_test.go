@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestTrimmedMeanOutliers は、極端に遅い外れ値を含む分布で、トリム平均が算術平均よりも中央値に近くなることを確認します。
+func TestTrimmedMeanOutliers(t *testing.T) {
+	// 10.000ms〜10.094ms の95件と、2秒の外れ値5件
+	var latencies []time.Duration
+	for i := range 95 {
+		latencies = append(latencies, 10*time.Millisecond+time.Duration(i)*time.Microsecond)
+	}
+	for range 5 {
+		latencies = append(latencies, 2*time.Second)
+	}
+	slices.Sort(latencies)
+
+	var sum time.Duration
+	for _, d := range latencies {
+		sum += d
+	}
+	mean := sum / time.Duration(len(latencies))
+	median := latencies[percentileIndex(len(latencies), 0.5)]
+
+	// 両端から5件ずつ取り除くと、10.005ms〜10.094ms の90件が残ります
+	trimmed, ok := trimmedMean(latencies, 0.05)
+	if !ok {
+		t.Fatal("trimmedMean が ok=false を返しました")
+	}
+	if want := 10*time.Millisecond + 49500*time.Nanosecond; trimmed != want {
+		t.Errorf("trimmedMean = %v, want %v", trimmed, want)
+	}
+	if absDuration(trimmed-median) >= absDuration(mean-median) {
+		t.Errorf("trimmed=%v mean=%v median=%v: トリム平均の方が中央値に近いはずです", trimmed, mean, median)
+	}
+}
+
+// TestTrimmedMeanEdgeCases は、サンプルがない場合と、少なくて1件も取り除けない場合の結果を確認します。
+func TestTrimmedMeanEdgeCases(t *testing.T) {
+	if _, ok := trimmedMean(nil, 0.1); ok {
+		t.Error("0件で ok=true を返しました")
+	}
+	// 2件 × 0.1 = 0.2 件は切り捨てで0件のため、通常の平均になります
+	if got, _ := trimmedMean([]time.Duration{2 * time.Millisecond, 4 * time.Millisecond}, 0.1); got != 3*time.Millisecond {
+		t.Errorf("trimmedMean = %v, want 3ms", got)
+	}
+}
+
+// TestTrimmedMeanAfterSelection は、全件をソートせずに orderLatencies で境界の位置だけを確定させた場合も、
+// ソートした場合と同じトリム平均になることを確認します（selectionThreshold を超える件数で、選択のアルゴリズムを通します）。
+func TestTrimmedMeanAfterSelection(t *testing.T) {
+	latencies := make([]time.Duration, selectionThreshold+selectionThreshold/4)
+	for i := range latencies {
+		latencies[i] = time.Duration(rand.Int64N(int64(time.Second)))
+	}
+	sorted := slices.Sorted(slices.Values(latencies))
+
+	orderLatencies(latencies, nil, 0.05)
+	got, _ := trimmedMean(latencies, 0.05)
+	want, _ := trimmedMean(sorted, 0.05)
+	// 加算の順序が異なるため、浮動小数点の丸めによる1ns程度の差は許容します
+	if absDuration(got-want) > time.Nanosecond {
+		t.Errorf("trimmedMean = %v, ソートした場合 %v", got, want)
+	}
+}
+
+// absDuration は、d の絶対値を返します。
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
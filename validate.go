@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// [セクション19] 厳格バリデーション: 不明なフィールドや範囲外の値を 422 で拒否
+// ==============================================================================
+
+// 通常（寛容モード）のAPIは、未指定や不正な値を安全なデフォルト値へ黙って置き換え、知らないフィールドは無視します。
+// 後方互換性のためこの動作は維持しますが、フロントエンドの開発中はペイロードの誤り（フィールド名のタイプミスなど）に
+// 気付けなくなります。そこで、X-Strict-Validation: true ヘッダーを付けたリクエスト（またはサーバーを -strict で
+// 起動した場合のすべてのリクエスト）では、JSONを厳格に検査し、誤りがあればフィールドごとのエラー一覧を
+// HTTP 422 (Unprocessable Entity) で返します。
+
+// strictValidationHeader は、リクエストごとに厳格バリデーションを有効／無効にするヘッダーです。
+const strictValidationHeader = "X-Strict-Validation"
+
+// strictValidationDefault は、ヘッダーが指定されていない場合に厳格バリデーションを行うかどうかです（-strict フラグ）。
+var strictValidationDefault bool
+
+// FieldError は、厳格バリデーションで検出された1つのフィールドの誤りです。
+type FieldError struct {
+	Field   string `json:"field"`   // JSONのフィールド名
+	Message string `json:"message"` // 誤りの内容
+}
+
+// strictRequested は、このリクエストで厳格バリデーションを行うかどうかを判定します。
+// ヘッダーの指定が -strict フラグによる既定値より優先されます。
+func strictRequested(r *http.Request) bool {
+	switch strings.ToLower(r.Header.Get(strictValidationHeader)) {
+	case "true", "1":
+		return true
+	case "false", "0":
+		return false
+	}
+	return strictValidationDefault
+}
+
+// standardMethods は、標準のHTTPメソッドです。厳格モードでは、これらを小文字で指定した場合を誤りとして扱います。
+var standardMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// validateStrict は、リクエストボディを厳格に検査し、検出した誤りの一覧を返します（誤りがなければ nil）。
+// デフォルト値の適用前の値を検査するため、寛容モードでは黙って置き換えられる値もここでは誤りになります。
+// 組み合わせの矛盾など、寛容モードでも拒否される項目は validateConfig の検査に任せます。
+func validateStrict(body []byte) []FieldError {
+	var cfg TestConfig
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return []FieldError{decodeFieldError(err)}
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return []FieldError{{Field: "", Message: "JSONオブジェクトの後ろに余分なデータがあります"}}
+	}
+
+	var errs []FieldError
+	add := func(field, format string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if cfg.TargetURL == "" {
+		if len(cfg.Targets) == 0 && cfg.RequestFile == "" {
+			add("target_url", "必須です（targets または request_file を指定する場合は省略できます）")
+		}
+	} else if u, err := url.Parse(cfg.TargetURL); err != nil || u.Host == "" {
+		add("target_url", "URLとして解釈できません: %q", cfg.TargetURL)
+	} else if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ws" && u.Scheme != "wss" {
+		add("target_url", "未対応のスキームです: %q", u.Scheme)
+	}
+	// 標準外のメソッド（PURGE など）は受け付けますが、"get" のような標準メソッドの小文字表記は
+	// サーバーによっては別のメソッドとして扱われるため、誤りとして報告します
+	if cfg.Method != "" && !isValidMethod(cfg.Method) {
+		add("method", "HTTPメソッドとして使用できない文字列です: %q", cfg.Method)
+	} else if upper := strings.ToUpper(cfg.Method); upper != cfg.Method && standardMethods[upper] {
+		add("method", "標準のHTTPメソッドは大文字で指定してください: %q", cfg.Method)
+	}
+	if cfg.Concurrency < 0 || cfg.Concurrency > maxConcurrency {
+		add("concurrency", "0〜%d の範囲で指定してください（0は既定値）: %d", maxConcurrency, cfg.Concurrency)
+	}
+	if cfg.Duration < 0 {
+		add("duration", "0以上で指定してください（0は既定値）: %s", time.Duration(cfg.Duration))
+	}
+	if cfg.TimeoutSec < 0 {
+		add("timeout", "0以上で指定してください（0は既定値）: %d", cfg.TimeoutSec)
+	}
+	if cfg.ReportIntervalSec < 0 {
+		add("report_interval_sec", "0以上で指定してください: %d", cfg.ReportIntervalSec)
+	}
+	if cfg.MaxSamples < 0 {
+		add("max_samples", "0以上で指定してください: %d", cfg.MaxSamples)
+	}
+	if cfg.RateLimit < 0 {
+		add("rate_limit", "0以上で指定してください: %g", cfg.RateLimit)
+	}
+	if cfg.RateJitter < 0 || cfg.RateJitter > 1 {
+		add("rate_jitter", "0〜1 の範囲で指定してください: %g", cfg.RateJitter)
+	}
+	return errs
+}
+
+// decodeFieldError は、厳格デコードのエラーを可能な範囲でフィールド単位のエラーに変換します。
+func decodeFieldError(err error) FieldError {
+	if errors.Is(err, errInvalidDuration) {
+		return FieldError{Field: "duration", Message: err.Error()}
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return FieldError{Field: typeErr.Field, Message: fmt.Sprintf("型が正しくありません（%s を指定してください）", typeErr.Type)}
+	}
+	// DisallowUnknownFields のエラーは専用の型を持たないため、メッセージからフィールド名を取り出します
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return FieldError{Field: strings.Trim(name, `"`), Message: "不明なフィールドです"}
+	}
+	return FieldError{Message: "JSONフォーマットが正しくありません: " + err.Error()}
+}
+
+// ==============================================================================
+// [セクション76] 設定の検証: 入力値の検証と既定値の適用 (validateConfig)
+// ==============================================================================
+
+// writeConfigError は、設定の誤りを 400 Bad Request の JSON（TestReport の error_msg）として返します。
+func writeConfigError(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(TestReport{ErrorMsg: msg})
+}
+
+// validateConfig は、読み込んだ設定の入力値を検証し、省略された項目に安全なデフォルト値を適用します。
+// 不正な入力の場合は、利用者向けのエラーメッセージを返します（readTestConfig が 400 として返します）。
+func validateConfig(cfg *TestConfig) error {
+	// 入力値の厳格なバリデーションと安全なデフォルト値へのフォールバック
+	// 複数ターゲットモードでは、ログやプリフライト用の代表URLとして先頭のターゲットを使います
+	if cfg.TargetURL == "" && len(cfg.Targets) > 0 {
+		cfg.TargetURL = cfg.Targets[0].URL
+	}
+	// リクエストファイルは全行をここで検証し、同様に先頭のリクエストを代表として使います。
+	// 送信先を何も指定しなかったテストでは、-urls-stdin で読み込んだ一覧を request_file と同じように使います（stdin.go を参照）
+	useStdin := cfg.TargetURL == "" && cfg.RequestFile == "" && cfg.ReplayTrace == "" && len(stdinRequests) > 0
+	if cfg.RequestFile != "" || useStdin {
+		specs, warning, err := stdinRequests, "", error(nil)
+		if !useStdin {
+			specs, warning, err = loadRequestFile(cfg.RequestFile)
+		}
+		if err == nil && (len(cfg.Targets) > 0 || (cfg.Mode != "" && cfg.Mode != modeHTTP) || (cfg.LoadModel != "" && cfg.LoadModel != loadModelClosed)) {
+			err = errors.New("request_file は、HTTPモードのクローズドモデル（load_model=closed）でのみ使用でき、targets とは併用できません")
+		}
+		if err == nil && cfg.RequestOrder != "" && cfg.RequestOrder != requestOrderSequential && cfg.RequestOrder != requestOrderRandom {
+			err = fmt.Errorf("未対応の request_order です: %q (sequential または random を指定してください)", cfg.RequestOrder)
+		}
+		if err != nil {
+			return err
+		}
+		if warning != "" {
+			log.Printf("[API Warning] %s\n", warning)
+			cfg.warnings = append(cfg.warnings, warning)
+		}
+		cfg.requestSpecs = specs
+		if cfg.TargetURL == "" {
+			cfg.TargetURL = specs[0].URL
+			if cfg.Method == "" {
+				cfg.Method = specs[0].Method
+			}
+		}
+		if cfg.RequestOrder == "" {
+			cfg.RequestOrder = requestOrderSequential
+		}
+	}
+	if err := resolveReplayTrace(cfg); err != nil {
+		return err
+	}
+	if cfg.TargetURL == "" {
+		return errors.New("ターゲットURLが指定されていません")
+	}
+	if cfg.Method == "" {
+		cfg.Method = "GET"
+	}
+	if !isValidMethod(cfg.Method) {
+		// 不正なメソッドのままではワーカーのリクエスト生成が失敗し、何も送信されずにテストが終わってしまいます
+		return fmt.Errorf("HTTPメソッドとして使用できない文字列です: %q", cfg.Method)
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 100 // 安全なデフォルト値
+	}
+	if cfg.TimeoutSec <= 0 {
+		cfg.TimeoutSec = 5 // デフォルトのタイムアウト
+	}
+	if cfg.Duration <= 0 {
+		cfg.Duration = configDuration(10 * time.Second) // 安全なデフォルト値
+		if cfg.traceReplay != nil {
+			// トレースの再生では、最後のリクエストを送信して応答を待ち終えるまでを実行時間にします
+			cfg.Duration = configDuration(cfg.traceReplay.span(cfg.ReplaySpeed) + time.Duration(cfg.TimeoutSec)*time.Second)
+		}
+	}
+	switch cfg.Mode {
+	case "":
+		cfg.Mode = modeHTTP
+	case modeHTTP, modeWebSocket:
+	default:
+		return fmt.Errorf("未対応のモードです: %q (http または ws を指定してください)", cfg.Mode)
+	}
+	targetURL, warning, err := normalizeTargetURL(cfg.TargetURL, cfg.Mode)
+	if err != nil {
+		return err
+	}
+	if warning != "" {
+		log.Printf("[API Warning] %s\n", warning)
+		cfg.warnings = append(cfg.warnings, warning)
+	}
+	cfg.TargetURL = targetURL
+	if cfg.NoDrainBody {
+		if cfg.Mode != modeHTTP || cfg.MaxResponseBytes > 0 || cfg.Trace {
+			return errors.New("no_drain_body はHTTPモードでのみ指定でき、max_response_bytes・trace とは併用できません")
+		}
+		log.Printf("[API Warning] %s\n", noDrainBodyWarning)
+		cfg.warnings = append(cfg.warnings, noDrainBodyWarning)
+	}
+	if len(cfg.AssertJSON) > 0 {
+		assertions, err := parseJSONAssertions(cfg.AssertJSON)
+		if err == nil && (cfg.Mode != modeHTTP || cfg.NoDrainBody) {
+			err = errors.New("assert_json はHTTPモードでのみ指定でき、no_drain_body とは併用できません")
+		}
+		if err != nil {
+			return err
+		}
+		cfg.assertions = assertions
+	}
+	if cfg.InjectLatency < 0 || cfg.InjectLatencyJitter < 0 || (injectsLatency(cfg) && cfg.Mode != modeHTTP) {
+		return errors.New("inject_latency と inject_latency_jitter は0以上で指定してください（HTTPモードのみ）")
+	}
+	if cfg.SlowThreshold < 0 || (cfg.SlowIsError && cfg.SlowThreshold == 0) || (cfg.SlowThreshold > 0 && cfg.Mode != modeHTTP) {
+		return errors.New("slow_threshold は0より大きい値で指定してください（HTTPモードのみ。slow_is_error には slow_threshold が必要です）")
+	}
+	if injectsLatency(cfg) {
+		log.Printf("[API Warning] %s\n", simulatedLatencyWarning)
+		cfg.warnings = append(cfg.warnings, simulatedLatencyWarning)
+	}
+	if err := validateThroughputFloor(cfg); err != nil {
+		return err
+	}
+	if cfg.FailFast && cfg.Mode != modeHTTP {
+		return errors.New("fail_fast はHTTPモードでのみ指定できます")
+	}
+	if cfg.PrewarmConnections && (cfg.Mode != modeHTTP || cfg.IsolatedClients) {
+		// ワーカー専用のクライアントは、ワーカーの起動時に生成されるため事前に温められません
+		return errors.New("prewarm_connections はHTTPモードでのみ指定でき、isolated_clients とは併用できません")
+	}
+	if cfg.ExcludeColdStart && (cfg.Mode != modeHTTP || cfg.LoadModel == loadModelOpen) {
+		// オープンモデルにはワーカーがなく、リクエストごとに別の Goroutine が送信するため「最初の1件」を定められません
+		return errors.New("exclude_cold_start はHTTPモードのクローズドモデルでのみ指定できます")
+	}
+	if cfg.Trace && cfg.Mode != modeHTTP {
+		return errors.New("trace はHTTPモードでのみ指定できます")
+	}
+	if cfg.Mode == modeWebSocket && cfg.WSMessage == "" {
+		cfg.WSMessage = "ping"
+	}
+	if cfg.ReportIntervalSec < 0 {
+		cfg.ReportIntervalSec = 0
+	}
+	if cfg.MaxSamples < 0 {
+		cfg.MaxSamples = 0
+	}
+	tags, err := applyTags(cfg.Tags)
+	if err != nil {
+		return err
+	}
+	cfg.Tags = tags
+	switch cfg.AuthType {
+	case "", authBasic, authDigest:
+	default:
+		return fmt.Errorf("未対応の auth_type です: %q (basic または digest を指定してください)", cfg.AuthType)
+	}
+	if err := resolveHMAC(cfg); err != nil {
+		return err
+	}
+	switch cfg.CacheBust {
+	case "", cacheBustQuery, cacheBustHeader:
+	default:
+		return fmt.Errorf("未対応の cache_bust です: %q (query または header を指定してください)", cfg.CacheBust)
+	}
+	if cfg.RateLimit < 0 || cfg.RateJitter < 0 || cfg.RateJitter > 1 {
+		return errors.New("rate_limit は0以上、rate_jitter は 0〜1 の範囲で指定してください")
+	}
+	if cfg.RateLimit > 0 && cfg.Adaptive {
+		// 適応型負荷モードはワーカー数を増減させるため、ワーカーごとに割り振ったレートでは全体のレートを保てません
+		return errors.New("rate_limit と adaptive は同時に指定できません")
+	}
+	switch cfg.LoadModel {
+	case "":
+		cfg.LoadModel = loadModelClosed
+	case loadModelClosed:
+	case loadModelOpen:
+		if cfg.RateLimit <= 0 || cfg.Mode != modeHTTP || cfg.Adaptive || cfg.IsolatedClients {
+			return errors.New("load_model=open は、HTTPモードで rate_limit（到着レート）を指定した場合のみ使用できます（adaptive、isolated_clients とは併用できません）")
+		}
+	default:
+		return fmt.Errorf("未対応の負荷モデルです: %q (closed または open を指定してください)", cfg.LoadModel)
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = cfg.Concurrency
+	}
+	if err := validateTrafficShape(cfg); err != nil {
+		return err
+	}
+	if err := validateComparison(cfg); err != nil {
+		return err
+	}
+	if err := validateConnections(cfg); err != nil {
+		return err
+	}
+	if err := validateConcurrencyLimits(cfg); err != nil {
+		return err
+	}
+	if cfg.MaxRPS < 0 || (cfg.MaxRPS > 0 && cfg.Mode != modeHTTP) {
+		return errors.New("max_rps は0以上で、HTTPモードでのみ指定できます")
+	}
+	if cfg.MaxRequestsPerConn < 0 || (cfg.MaxRequestsPerConn > 0 && cfg.Mode != modeHTTP) {
+		return errors.New("max_requests_per_conn は0以上で、HTTPモードでのみ指定できます（0は無制限）")
+	}
+	if cfg.SpreadIPs && cfg.Mode != modeHTTP {
+		return errors.New("spread_ips はHTTPモードでのみ指定できます")
+	}
+	if cfg.DNSServer != "" {
+		server, err := normalizeDNSServer(cfg.DNSServer)
+		if err != nil {
+			return err
+		}
+		cfg.DNSServer = server
+	}
+	if cfg.DialConcurrency < 0 {
+		return errors.New("dial_concurrency は0以上で指定してください（0は無制限）")
+	}
+	if err := validateConnectPhases(cfg); err != nil {
+		return err
+	}
+	percentiles, err := validatePercentiles(cfg.Percentiles)
+	if err != nil {
+		return err
+	}
+	cfg.Percentiles = percentiles
+	if cfg.ApdexTarget < 0 {
+		return errors.New("apdex_target は0以上で指定してください（0の場合は算出しません）")
+	}
+	if cfg.CaptureSamples < 0 || cfg.CaptureSamples > maxCaptureSamples || (cfg.CaptureSamples > 0 && cfg.Mode != modeHTTP) {
+		return fmt.Errorf("capture_samples は0〜%d の範囲で、HTTPモードでのみ指定できます", maxCaptureSamples)
+	}
+	if cfg.TopSlowest < 0 || cfg.TopSlowest > maxTopSlowest || ((cfg.TopSlowest > 0 || cfg.TopSlowestHeaders) && cfg.Mode != modeHTTP) {
+		return fmt.Errorf("top_slowest は0〜%d の範囲で、HTTPモードでのみ指定できます", maxTopSlowest)
+	}
+	if cfg.RampDownSec < 0 || float64(cfg.RampDownSec) >= cfg.Duration.Seconds() {
+		return errors.New("ramp_down_sec は0以上、実行時間（duration）未満で指定してください")
+	}
+	if cfg.RampDownSec > 0 && (cfg.Forever || cfg.Adaptive || cfg.LoadModel != loadModelClosed) {
+		// ランプダウンは終了時刻とワーカー数が決まっているクローズドモデルでのみ意味を持ちます
+		return errors.New("ramp_down_sec は、forever・adaptive・load_model=open とは併用できません")
+	}
+	if cfg.HonorRetryAfter && (cfg.Mode != modeHTTP || cfg.LoadModel != loadModelClosed) {
+		// オープンモデルではリクエストごとに別のGoroutineが送信するため、待機しても送信ペースは変わりません
+		return errors.New("honor_retry_after は、HTTPモードのクローズドモデル（load_model=closed）でのみ使用できます")
+	}
+	if len(cfg.Targets) > 0 {
+		err := normalizeTargets(cfg)
+		if err == nil && (cfg.Mode != modeHTTP || cfg.LoadModel != loadModelClosed) {
+			err = errors.New("targets は、HTTPモードのクローズドモデル（load_model=closed）でのみ使用できます")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := validateTransportTimeouts(cfg); err != nil {
+		return err
+	}
+	if err := validateHTTP3(cfg); err != nil {
+		return err
+	}
+	if err := resolveBody(cfg); err != nil {
+		return err
+	}
+	if err := validateHeaders(cfg); err != nil {
+		return err
+	}
+	if err := resolveGenBody(cfg); err != nil {
+		return err
+	}
+	// クエリパラメーターは、URLの正規化（targets を含む）の後に1度だけ追加します
+	params, err := resolveQuery(cfg.Query)
+	if err == nil {
+		cfg.TargetURL, err = mergeQuery(cfg.TargetURL, params)
+	}
+	for i := 0; err == nil && i < len(cfg.Targets); i++ {
+		cfg.Targets[i].URL, err = mergeQuery(cfg.Targets[i].URL, params)
+	}
+	if err != nil {
+		return err
+	}
+	switch cfg.OverloadPolicy {
+	case "":
+		cfg.OverloadPolicy = overloadDrop
+	case overloadDrop, overloadBlock:
+	default:
+		return fmt.Errorf("未対応の overload_policy です: %q (drop または block を指定してください)", cfg.OverloadPolicy)
+	}
+	if cfg.AdaptiveTargetErrorRate < 0 || cfg.AdaptiveTargetErrorRate > 1 {
+		return errors.New("adaptive_target_error_rate は 0〜1 の範囲で指定してください")
+	}
+	if _, err := buildTLSConfig(cfg); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// postConfig は、body を /api/run と同じ形で readTestConfig に渡し、書き込まれたレスポンスを返します。
+func postConfig(t *testing.T, body string, strict bool) (*TestConfig, *httptest.ResponseRecorder) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/run", strings.NewReader(body))
+	if strict {
+		req.Header.Set(strictValidationHeader, "true")
+	}
+	rec := httptest.NewRecorder()
+	cfg, _ := readTestConfig(rec, req)
+	return cfg, rec
+}
+
+// TestStrictValidationRejectsUnknownField は、厳格モードでは未知のフィールドが 422 とフィールドごとの誤りになることを確認します。
+func TestStrictValidationRejectsUnknownField(t *testing.T) {
+	body := `{"target_url":"http://127.0.0.1:1","concurency":10}`
+
+	cfg, rec := postConfig(t, body, true)
+	if cfg != nil || rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d: 厳格モードでは 422 を返すはずです", rec.Code)
+	}
+	var report TestReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("レスポンスを解析できません: %v", err)
+	}
+	if len(report.FieldErrors) != 1 || !strings.Contains(report.FieldErrors[0].Message+report.FieldErrors[0].Field, "concurency") {
+		t.Errorf("field_errors = %+v: concurency の誤りだけを返すはずです", report.FieldErrors)
+	}
+
+	// 寛容モード（既定）では、未知のフィールドを無視して受け付けます
+	if cfg, rec := postConfig(t, body, false); cfg == nil {
+		t.Errorf("status = %d: 寛容モードでは受け付けるはずです", rec.Code)
+	}
+}
+
+// TestValidateConfigErrors は、検証に失敗した設定が writeConfigError により 400 の error_msg として返ることを確認します。
+func TestValidateConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"URLなし", `{}`, "ターゲットURLが指定されていません"},
+		{"不正なメソッド", `{"target_url":"http://127.0.0.1:1","method":"GE T"}`, "HTTPメソッド"},
+		{"負のレート", `{"target_url":"http://127.0.0.1:1","rate_limit":-1}`, "rate_limit"},
+		{"不正なモード", `{"target_url":"http://127.0.0.1:1","mode":"ftp"}`, "未対応のモード"},
+		{"不正な duration", `{"target_url":"http://127.0.0.1:1","duration":"zz"}`, "duration"},
+		{"不正なヘッダー名", `{"target_url":"http://127.0.0.1:1","headers":{"X Bad":"1"}}`, "ヘッダー名"},
+		{"ヘッダー値の改行", `{"target_url":"http://127.0.0.1:1","headers":{"X-A":"1\r\nX-B: 2"}}`, "改行"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, rec := postConfig(t, tt.body, false)
+			if cfg != nil || rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			var report TestReport
+			if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+				t.Fatalf("レスポンスを解析できません: %v", err)
+			}
+			if !strings.Contains(report.ErrorMsg, tt.want) {
+				t.Errorf("error_msg = %q, want %q を含む", report.ErrorMsg, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateConfigDefaults は、省略した項目に既定値が適用されることを確認します。
+func TestValidateConfigDefaults(t *testing.T) {
+	cfg := &TestConfig{TargetURL: "127.0.0.1:8080/health"}
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("validateConfig: %v", err)
+	}
+	if cfg.Method != http.MethodGet || cfg.Concurrency != 100 || cfg.TimeoutSec != 5 || cfg.Mode != modeHTTP || cfg.LoadModel != loadModelClosed {
+		t.Errorf("既定値が適用されていません: method=%q concurrency=%d timeout=%d mode=%q load_model=%q",
+			cfg.Method, cfg.Concurrency, cfg.TimeoutSec, cfg.Mode, cfg.LoadModel)
+	}
+	if cfg.TargetURL != "https://127.0.0.1:8080/health" || len(cfg.warnings) == 0 {
+		t.Errorf("target_url = %q, warnings = %v: スキームを補って警告するはずです", cfg.TargetURL, cfg.warnings)
+	}
+}
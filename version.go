@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// ==============================================================================
+// [セクション21] ビルド情報: どのビルドがレポートを生成したかの記録 (-version)
+// ==============================================================================
+
+// 結果を時系列で比較したり不具合を報告したりする際には、どのビルドで計測したかが重要です。
+// 以下の値はビルド時に -ldflags で埋め込みます。
+//
+//	go build -ldflags "-X main.version=v1.4.2 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// 埋め込まれていない場合（go run など）は、Goが記録するVCS情報から可能な範囲で補います。
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// BuildInfo は、レポートを生成したビルドの情報です。
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// toolVersion は、このバイナリのビルド情報です（起動時に1度だけ組み立てます）。
+var toolVersion = readBuildInfo()
+
+// readBuildInfo は、-ldflags で埋め込まれた値と、Goが記録したVCS情報からビルド情報を組み立てます。
+func readBuildInfo() BuildInfo {
+	info := BuildInfo{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = s.Value
+			}
+		}
+	}
+	return info
+}
+
+// String は、-version や起動ログ向けの1行表現を返します。
+func (b BuildInfo) String() string {
+	s := "UltraLoad " + b.Version
+	if b.Commit != "" {
+		s += " (commit " + b.Commit + ")"
+	}
+	if b.BuildDate != "" {
+		s += " built " + b.BuildDate
+	}
+	return fmt.Sprintf("%s, %s", s, b.GoVersion)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	mrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ==============================================================================
+// [セクション9] WebSocket負荷テストモード: gorilla/websocket のクライアントとワーカー
+// ==============================================================================
+
+// WebSocketモードでは、各ワーカーが1本の持続的な接続を維持し、メッセージを送信して
+// エコーされた応答を受信するまでの往復時間（RTT）を計測します。
+// Upgradeハンドシェイク、フレームのマスク・分割、Ping/Pong/Close の処理は gorilla/websocket に任せ、
+// ここでは接続の確立方法（ダイヤラー・TLS設定）を HTTP モードとそろえることと、往復の計測だけを行います。
+
+// wsMaxMessageBytes は、受信する1メッセージの上限サイズです。
+// 異常なサーバーが巨大なフレームを返してきた場合でも、テスターがメモリを使い果たさないよう保護します。
+const wsMaxMessageBytes = 16 << 20
+
+// 接続の確立（TCP/TLS 接続と Upgrade ハンドシェイク）に失敗した場合の再接続までの待機時間です。
+// 連続して失敗するたびに2倍にし、wsReconnectMaxBackoff で頭打ちにします（接続に成功すると初期値に戻ります）。
+// 接続を拒否するサーバーへ待機なしに再接続を繰り返すと、ワーカーの数だけ CPU を使い切るうえ、
+// 記録されるのも瞬時に失敗した接続ばかりになってしまうためです。
+const (
+	wsReconnectBaseBackoff = 10 * time.Millisecond
+	wsReconnectMaxBackoff  = time.Second
+)
+
+// errWSClosed は、サーバーからCloseフレームを受信したことを示します。
+var errWSClosed = errors.New("サーバーがWebSocket接続をクローズしました")
+
+// wsConn は、ハンドシェイク済みのWebSocket接続です。
+// 1つのワーカーからのみ使用されるため、送受信の排他制御は行いません（gorilla/websocket の並行性の制約も満たします）。
+type wsConn struct {
+	conn *websocket.Conn
+}
+
+// dialWebSocket は、HTTPモードと同じダイヤラーとTLS設定で接続を確立し、WebSocketのUpgradeハンドシェイクを行います。
+// ws:// / wss:// に加えて、利便性のため http:// / https:// も受け付けます。
+func dialWebSocket(ctx context.Context, dialer *tunedDialer, tlsConfig *tls.Config, rawURL string, timeout time.Duration) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("URLの解析に失敗しました: %w", err)
+	}
+	switch u.Scheme {
+	case "ws", "wss":
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	default:
+		return nil, fmt.Errorf("WebSocketモードでは未対応のスキームです: %q", u.Scheme)
+	}
+
+	// ハンドシェイク全体にタイムアウトを設定し、応答しないサーバーでワーカーが停止しないようにします
+	d := &websocket.Dialer{
+		NetDialContext:   dialer.DialContext,
+		TLSClientConfig:  tlsConfig, // 検証スキップ、バージョン・暗号スイートの指定を HTTP モードとそろえます
+		HandshakeTimeout: timeout,
+	}
+	conn, resp, err := d.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		if errors.Is(err, websocket.ErrBadHandshake) && resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			return nil, fmt.Errorf("WebSocketハンドシェイクが拒否されました: HTTP %d", resp.StatusCode)
+		}
+		return nil, err
+	}
+	conn.SetReadLimit(wsMaxMessageBytes)
+	return &wsConn{conn: conn}, nil
+}
+
+// writeMessage は、1件のテキストメッセージを送信します。
+func (c *wsConn) writeMessage(payload []byte) error {
+	return c.conn.WriteMessage(websocket.TextMessage, payload)
+}
+
+// readMessage は、データメッセージ（テキスト/バイナリ）を1件受信するまで読み進めます。
+// 途中で受信した Ping には gorilla/websocket が Pong を返し、Close を受信した場合は errWSClosed を返します。
+func (c *wsConn) readMessage() ([]byte, error) {
+	_, message, err := c.conn.ReadMessage()
+	if _, ok := err.(*websocket.CloseError); ok {
+		return nil, fmt.Errorf("%w (%v)", errWSClosed, err)
+	}
+	return message, err
+}
+
+// setDeadline は、送信と受信の両方のデッドラインを設定します。
+func (c *wsConn) setDeadline(t time.Time) {
+	_ = c.conn.SetWriteDeadline(t)
+	_ = c.conn.SetReadDeadline(t)
+}
+
+// tlsState は、TLS で接続している場合にその接続状態を返します。
+func (c *wsConn) tlsState() (tls.ConnectionState, bool) {
+	if tlsConn, ok := c.conn.NetConn().(*tls.Conn); ok {
+		return tlsConn.ConnectionState(), true
+	}
+	return tls.ConnectionState{}, false
+}
+
+// Close は、下位のネットワーク接続を閉じます。
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
+
+// executeWSWorker は、WebSocketモードの1ワーカーとして動作します。
+// 接続を確立して維持し、メッセージの送信とエコー受信を繰り返してRTTを記録します。
+// 接続が切断された場合はエラーとして記録し、テスト終了まで再接続を試みます。
+// rng は、再接続の待機時間の揺らぎを引く、このワーカー専用の乱数生成器です。
+func executeWSWorker(ctx context.Context, wg *sync.WaitGroup, cfg *TestConfig, metrics *ResultMetrics, rng *mrand.Rand) {
+	defer wg.Done()
+
+	timeout := requestTimeout(cfg)
+	interval := time.Duration(cfg.WSIntervalMs) * time.Millisecond
+	message := []byte(cfg.WSMessage)
+	dialer := newTunedDialer(cfg)
+	tlsConfig := newTLSConfig(cfg)
+	failures := 0
+
+	for ctx.Err() == nil && waitPaused(ctx, cfg.pause, nil) {
+		// 1. 接続確立（TCP/TLS + Upgradeハンドシェイク）の計測
+		start := time.Now()
+		conn, err := dialWebSocket(ctx, dialer, tlsConfig, cfg.TargetURL, timeout)
+		if err != nil {
+			if ctx.Err() == nil {
+				metrics.RecordNetworkError(time.Since(start), cfg.certRequest.annotate(err))
+			}
+			failures++
+			if !sleepContext(ctx, wsReconnectDelay(failures, rng)) {
+				return
+			}
+			continue
+		}
+		failures = 0
+		metrics.RecordConnect(time.Since(start))
+		if state, ok := conn.tlsState(); ok {
+			metrics.RecordTLS(state)
+		}
+
+		// 2. テスト終了時にブロック中の読み込みを即座に解除できるよう、コンテキストと接続を連動させます
+		stop := context.AfterFunc(ctx, func() { conn.Close() })
+		err = runWSSession(ctx, conn, message, interval, timeout, cfg.pause, metrics)
+		stop()
+		conn.Close()
+
+		// テスト終了による切断はエラーとして扱いません
+		if err != nil && ctx.Err() == nil {
+			metrics.RecordNetworkError(0, err)
+		}
+	}
+}
+
+// wsReconnectDelay は、接続の確立に failures 回連続して失敗した後の再接続までの待機時間を返します。
+// 同時に失敗したワーカーの再接続が同じ瞬間に重ならないよう、ワーカーの乱数生成器 rng から ±50% の揺らぎを加えます。
+func wsReconnectDelay(failures int, rng *mrand.Rand) time.Duration {
+	d := wsReconnectMaxBackoff
+	if shift := failures - 1; shift < 30 {
+		d = min(wsReconnectBaseBackoff<<max(shift, 0), wsReconnectMaxBackoff)
+	}
+	return d/2 + time.Duration(rng.Int64N(int64(d)))
+}
+
+// sleepContext は、d が経過するか ctx がキャンセルされるまで待機し、待機を終えた場合に true を返します。
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// runWSSession は、1本の接続上でメッセージの往復を繰り返し、切断またはテスト終了で戻ります。
+// pause が一時停止中の場合は、接続を保ったまま再開まで送信を待ちます。
+func runWSSession(ctx context.Context, conn *wsConn, message []byte, interval, timeout time.Duration, pause *pauseState, metrics *ResultMetrics) error {
+	var ticker *time.Ticker
+	if interval > 0 {
+		ticker = time.NewTicker(interval)
+		defer ticker.Stop()
+	}
+
+	for {
+		if ticker != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		} else if ctx.Err() != nil {
+			return nil
+		}
+		if !waitPaused(ctx, pause, nil) {
+			return nil
+		}
+
+		// 応答しないサーバーで永久に待たないよう、1往復ごとにデッドラインを設定します
+		conn.setDeadline(time.Now().Add(timeout))
+
+		start := time.Now()
+		atomic.AddUint64(&metrics.SentRequests, 1)
+		metrics.beginRequest()
+		err := conn.writeMessage(message)
+		if err == nil {
+			_, err = conn.readMessage()
+		}
+		metrics.endRequest(time.Since(start))
+		if err != nil {
+			return err
+		}
+		metrics.RecordMessage(time.Since(start))
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestWSServer は、Upgrade ハンドシェイクに応答し、接続ごとに session を実行する WebSocket サーバーを起動します。
+func newTestWSServer(t *testing.T, session func(conn *websocket.Conn)) *httptest.Server {
+	t.Helper()
+	var wg sync.WaitGroup
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		wg.Add(1)
+		defer wg.Done()
+		defer conn.Close()
+		session(conn)
+	}))
+	t.Cleanup(func() {
+		server.CloseClientConnections()
+		server.Close()
+		wg.Wait()
+	})
+	return server
+}
+
+// newTestEchoServer は、受信したメッセージをそのまま返す WebSocket サーバーを起動します。
+func newTestEchoServer(t *testing.T) *httptest.Server {
+	return newTestWSServer(t, func(conn *websocket.Conn) {
+		for {
+			typ, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(typ, msg); err != nil {
+				return
+			}
+		}
+	})
+}
+
+// dialTestWS は、テスト用のサーバーへ WebSocket で接続します。
+func dialTestWS(t *testing.T, rawURL string) (*wsConn, error) {
+	t.Helper()
+	cfg := &TestConfig{}
+	return dialWebSocket(context.Background(), newTunedDialer(cfg), newTLSConfig(cfg), rawURL, 5*time.Second)
+}
+
+// TestWSHandshake は、ws:// と http:// の両方で接続できることと、Sec-WebSocket-Accept の不一致・101 以外の応答の拒否を確認します。
+func TestWSHandshake(t *testing.T) {
+	ok := newTestEchoServer(t)
+	for _, u := range []string{"ws" + ok.URL[len("http"):] + "/echo?x=1", ok.URL} {
+		conn, err := dialTestWS(t, u)
+		if err != nil {
+			t.Fatalf("%s: ハンドシェイクに失敗しました: %v", u, err)
+		}
+		conn.Close()
+	}
+
+	// Sec-WebSocket-Accept に誤った値を返すサーバー（Upgrade の応答だけを手で書きます）
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: invalid\r\n\r\n")
+	}))
+	defer bad.Close()
+	if conn, err := dialTestWS(t, bad.URL); err == nil {
+		conn.Close()
+		t.Error("Sec-WebSocket-Accept が不正なサーバーへの接続が成功しました")
+	}
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+	if conn, err := dialTestWS(t, plain.URL); err == nil {
+		conn.Close()
+		t.Error("101 を返さないサーバーへの接続が成功しました")
+	}
+
+	if _, err := dialTestWS(t, "ftp://127.0.0.1/"); err == nil {
+		t.Error("未対応のスキームへの接続が成功しました")
+	}
+}
+
+// TestWSPingDuringRead は、メッセージの受信待ちの間に届いた Ping に同じペイロードの Pong を返すことを確認します。
+func TestWSPingDuringRead(t *testing.T) {
+	pong := make(chan string, 1)
+	server := newTestWSServer(t, func(conn *websocket.Conn) {
+		conn.SetPongHandler(func(data string) error {
+			pong <- data
+			return nil
+		})
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteControl(websocket.PingMessage, []byte("are you there"), time.Now().Add(time.Second))
+		conn.WriteMessage(websocket.TextMessage, msg)
+		// Pong を受信するため、クライアントが閉じるまで読み続けます
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+
+	conn, err := dialTestWS(t, server.URL)
+	if err != nil {
+		t.Fatalf("ハンドシェイクに失敗しました: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.writeMessage([]byte("hello, world")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := conn.readMessage()
+	if err != nil || string(got) != "hello, world" {
+		t.Fatalf("readMessage = %q, %v, want \"hello, world\"", got, err)
+	}
+	select {
+	case p := <-pong:
+		if p != "are you there" {
+			t.Errorf("Pong のペイロード = %q, want \"are you there\"", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Pong が届きません")
+	}
+}
+
+// TestWSClose は、サーバーから Close を受信すると errWSClosed を返し、Close を返信することを確認します。
+func TestWSClose(t *testing.T) {
+	reply := make(chan int, 1)
+	server := newTestWSServer(t, func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "bye"))
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var ce *websocket.CloseError
+			if errors.As(err, &ce) {
+				reply <- ce.Code
+			}
+		}
+	})
+
+	conn, err := dialTestWS(t, server.URL)
+	if err != nil {
+		t.Fatalf("ハンドシェイクに失敗しました: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.readMessage(); !errors.Is(err, errWSClosed) {
+		t.Errorf("readMessage のエラー = %v, want errWSClosed", err)
+	}
+	select {
+	case code := <-reply:
+		if code != websocket.CloseGoingAway {
+			t.Errorf("返信の Close コード = %d, want %d", code, websocket.CloseGoingAway)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Close の返信が届きません")
+	}
+}
+
+// TestWSLoadTestEcho は、WebSocketモードのテストがエコーサーバーとの往復を記録することを確認します。
+func TestWSLoadTestEcho(t *testing.T) {
+	server := newTestEchoServer(t)
+
+	cfg := newTestConfig(t, map[string]any{
+		"target_url":  "ws" + server.URL[len("http"):],
+		"mode":        modeWebSocket,
+		"concurrency": 2,
+		"duration":    "200ms",
+		"ws_message":  "ping",
+	})
+	report := runTestLoad(cfg)
+	if report.TotalRequests == 0 || report.Errors != 0 || report.Success != report.TotalRequests {
+		t.Errorf("total=%d success=%d errors=%d: エコーの往復がすべて成功するはずです (%s)",
+			report.TotalRequests, report.Success, report.Errors, report.ErrorMsg)
+	}
+}
+
+// TestWSReconnectBackoff は、接続を拒否されたワーカーが待機なしに再接続を繰り返さず、待機中のテスト終了でただちに戻ることを確認します。
+func TestWSReconnectBackoff(t *testing.T) {
+	// 閉じたポートへの接続は即座に拒否されます
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	cfg := &TestConfig{Mode: modeWebSocket, TargetURL: "ws://" + addr, TimeoutSec: 5}
+	metrics := NewResultMetrics(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go executeWSWorker(ctx, &wg, cfg, metrics, newWorkerRand(1, 0))
+
+	time.Sleep(500 * time.Millisecond)
+	cancel()
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("テスト終了後もワーカーが再接続の待機から戻りません")
+	}
+
+	// 待機が 10ms・20ms・40ms… と伸びるため、500ms の間の失敗は10件に満たないはずです（待機なしでは数千件になります）
+	if errs := metrics.ErrorCount.Load(); errs == 0 || errs > 10 {
+		t.Errorf("接続の失敗 = %d 件: 再接続の待機が効いていません", errs)
+	}
+}
+
+// TestWSReconnectAfterClose は、サーバーに切断されたワーカーが再接続して往復を続けることを確認します。
+func TestWSReconnectAfterClose(t *testing.T) {
+	var mu sync.Mutex
+	sessions := 0
+	server := newTestWSServer(t, func(conn *websocket.Conn) {
+		mu.Lock()
+		sessions++
+		mu.Unlock()
+		// 1往復だけエコーしてから切断します
+		typ, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(typ, msg)
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	})
+
+	cfg := newTestConfig(t, map[string]any{
+		"target_url":   "ws" + server.URL[len("http"):],
+		"mode":         modeWebSocket,
+		"concurrency":  1,
+		"duration":     "300ms",
+		"no_preflight": true,
+	})
+	report := runTestLoad(cfg)
+	mu.Lock()
+	defer mu.Unlock()
+	if sessions < 2 || report.Success < 2 {
+		t.Errorf("sessions=%d success=%d: 切断後に再接続して往復を続けるはずです", sessions, report.Success)
+	}
+}
+
+// TestWSReconnectDelay は、再接続の待機時間が失敗のたびに倍増し、揺らぎを含めて上限の 1.5 倍を超えないことを確認します。
+func TestWSReconnectDelay(t *testing.T) {
+	rng := newWorkerRand(1, 0)
+	for failures := 1; failures <= 64; failures++ {
+		base := min(wsReconnectBaseBackoff<<min(failures-1, 20), wsReconnectMaxBackoff)
+		for range 20 {
+			d := wsReconnectDelay(failures, rng)
+			if d < base/2 || d >= base/2+base {
+				t.Fatalf("wsReconnectDelay(%d) = %v, want [%v, %v)", failures, d, base/2, base/2+base)
+			}
+		}
+	}
+}